@@ -0,0 +1,116 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var activeTasksNotImplemented = &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support ActiveTasks interface"}
+
+// ActiveTask is a task running on the server, as returned by
+// [Client.ActiveTasks].
+type ActiveTask struct {
+	// Type is the type of task, such as "database_compaction",
+	// "view_compaction", "indexer" or "replication".
+	Type string `json:"type"`
+	Node string `json:"node"`
+	PID  string `json:"pid"`
+	// Database is the database the task operates on. For clustered
+	// databases this is the name of a shard; see [ActiveTask.DBName].
+	Database       string `json:"database"`
+	DesignDocument string `json:"design_document"`
+	Phase          string `json:"phase"`
+	// Progress is the estimated completion of the task, as a percentage.
+	Progress     int   `json:"progress"`
+	ChangesDone  int64 `json:"changes_done"`
+	TotalChanges int64 `json:"total_changes"`
+	// StartedOn and UpdatedOn are in seconds since the Unix epoch.
+	StartedOn int64 `json:"started_on"`
+	UpdatedOn int64 `json:"updated_on"`
+	// RawTask is the task as returned by the server, including any fields
+	// specific to its type.
+	RawTask json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON satisfies the [encoding/json.Unmarshaler] interface.
+func (t *ActiveTask) UnmarshalJSON(data []byte) error {
+	type alias ActiveTask
+	if err := json.Unmarshal(data, (*alias)(t)); err != nil {
+		return err
+	}
+	t.RawTask = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Started returns the time at which the task started.
+func (t *ActiveTask) Started() time.Time {
+	return time.Unix(t.StartedOn, 0)
+}
+
+// Updated returns the time at which the task last reported progress.
+func (t *ActiveTask) Updated() time.Time {
+	return time.Unix(t.UpdatedOn, 0)
+}
+
+// DBName returns the name of the database the task operates on. For tasks on
+// a shard of a clustered database, such as
+// "shards/00000000-7fffffff/mydb.1234567890", this is the name of the
+// clustered database, "mydb".
+func (t *ActiveTask) DBName() string {
+	if !strings.HasPrefix(t.Database, "shards/") {
+		return t.Database
+	}
+	parts := strings.SplitN(t.Database, "/", 3)
+	if len(parts) != 3 {
+		return t.Database
+	}
+	name := parts[2]
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// ActiveTasks returns the tasks running on the server.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#active-tasks
+func (c *Client) ActiveTasks(ctx context.Context) ([]*ActiveTask, error) {
+	if err := c.startQuery(); err != nil {
+		return nil, err
+	}
+	defer c.endQuery()
+	tasker, ok := optional(c.driverClient, (*driver.ActiveTasker)(nil)).(driver.ActiveTasker)
+	if !ok {
+		return nil, activeTasksNotImplemented
+	}
+	var raw json.RawMessage
+	err := c.invoke(ctx, &Operation{Method: "ActiveTasks", ReadOnly: true}, func(ctx context.Context) (err error) {
+		raw, err = tasker.ActiveTasks(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*ActiveTask
+	if err := json.Unmarshal(raw, &tasks); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Err: err}
+	}
+	return tasks, nil
+}
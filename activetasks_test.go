@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestActiveTasks(t *testing.T) {
+	type tst struct {
+		client   *Client
+		expected []*ActiveTask
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("not supported", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support ActiveTasks interface",
+	})
+	tests.Add("error", tst{
+		client: &Client{driverClient: &mock.ActiveTasker{
+			ActiveTasksFunc: func(context.Context) (json.RawMessage, error) {
+				return nil, &Error{Status: http.StatusUnauthorized, Message: "unauthorized"}
+			},
+		}},
+		status: http.StatusUnauthorized,
+		err:    "unauthorized",
+	})
+	tests.Add("invalid response", tst{
+		client: &Client{driverClient: &mock.ActiveTasker{
+			ActiveTasksFunc: func(context.Context) (json.RawMessage, error) {
+				return json.RawMessage(`{}`), nil
+			},
+		}},
+		status: http.StatusBadGateway,
+		err:    "json: cannot unmarshal object into Go value of type []*kivik.ActiveTask",
+	})
+	task := `{"changes_done":64438,"database":"shards/00000000-7fffffff/mailbox.1234567890","node":"node1@127.0.0.1","pid":"<0.12986.1>","progress":84,"started_on":1376116576,"total_changes":76215,"type":"database_compaction","updated_on":1376116619}`
+	tests.Add("success", tst{
+		client: &Client{driverClient: &mock.ActiveTasker{
+			ActiveTasksFunc: func(context.Context) (json.RawMessage, error) {
+				return json.RawMessage("[" + task + "]"), nil
+			},
+		}},
+		expected: []*ActiveTask{{
+			Type:         "database_compaction",
+			Node:         "node1@127.0.0.1",
+			PID:          "<0.12986.1>",
+			Database:     "shards/00000000-7fffffff/mailbox.1234567890",
+			Progress:     84,
+			ChangesDone:  64438,
+			TotalChanges: 76215,
+			StartedOn:    1376116576,
+			UpdatedOn:    1376116619,
+			RawTask:      json.RawMessage(task),
+		}},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		tasks, err := test.client.ActiveTasks(context.Background())
+		if d := testy.DiffInterface(test.expected, tasks); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, test.err, test.status, err)
+	})
+}
+
+func TestActiveTaskDBName(t *testing.T) {
+	tests := map[string]string{
+		"":        "",
+		"mailbox": "mailbox",
+		"shards/00000000-7fffffff/mailbox.1234567890": "mailbox",
+		"shards/00000000-7fffffff/a/b.c.1234567890":   "a/b.c",
+		"shards/00000000-7fffffff":                    "shards/00000000-7fffffff",
+	}
+	for database, want := range tests {
+		task := &ActiveTask{Database: database}
+		if got := task.DBName(); got != want {
+			t.Errorf("%q: expected %q, got %q", database, want, got)
+		}
+	}
+}
@@ -15,6 +15,7 @@ package kivik
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/go-kivik/kivik/v4/driver"
@@ -35,6 +36,11 @@ type BulkResult struct {
 //
 // As with [DB.Put], each individual document may be a JSON-marshable object, or
 // a raw JSON string in a [encoding/json.RawMessage], or [io.Reader].
+//
+// By default, the returned error reports only the failure of the request as a
+// whole. The failure of individual documents is reported in the Error field
+// of the corresponding result; pass [WithBulkError], or use [NewBulkError], to
+// collect them into a single error.
 func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Options) ([]BulkResult, error) {
 	if db.err != nil {
 		return nil, db.err
@@ -51,16 +57,81 @@ func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Optio
 	}
 	defer db.endQuery()
 	opts := mergeOptions(options...)
-	if bulkDocer, ok := db.driverDB.(driver.BulkDocer); ok {
-		bulki, err := bulkDocer.BulkDocs(ctx, docsi, opts)
-		if err != nil {
-			return nil, err
+	bulkError := bulkErrorOption(opts)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return nil, err
+	}
+	results, err := db.bulkDocs(ctx, docsi, opts)
+	return bulkResults(results, err, bulkError)
+}
+
+// optionBulkError is the option key used by [WithBulkError].
+const optionBulkError = "kivik:bulkError"
+
+// WithBulkError returns an option which causes [DB.BulkDocs] and
+// [DB.BulkDocsFrom] to return a [*BulkError] describing the documents which
+// failed, along with the results, if any document failed. [BulkPut] always
+// does so.
+func WithBulkError() Options {
+	return Options{optionBulkError: true}
+}
+
+// bulkErrorOption removes the [WithBulkError] option from opts, and reports
+// whether it was set.
+func bulkErrorOption(opts Options) bool {
+	set, _ := opts[optionBulkError].(bool)
+	delete(opts, optionBulkError)
+	return set
+}
+
+// bulkResults returns results and err, replacing a nil err with the
+// aggregate error of results if bulkError is true.
+func bulkResults(results []BulkResult, err error, bulkError bool) ([]BulkResult, error) {
+	if err != nil || !bulkError {
+		return results, err
+	}
+	return results, NewBulkError(results)
+}
+
+// bulkDocs stores docsi, which have been checked by BulkDocs.
+func (db *DB) bulkDocs(ctx context.Context, docsi []interface{}, opts Options) ([]BulkResult, error) {
+	if bulkDocer, ok := optional(db.driverDB, (*driver.BulkDocer)(nil)).(driver.BulkDocer); ok {
+		valid, index, rejected := db.prepareBulk(docsi)
+		var bulki []driver.BulkResult
+		if len(valid) > 0 {
+			err := db.client.invoke(ctx, &Operation{Method: "BulkDocs", DB: db.name, Options: opts}, func(ctx context.Context) (err error) {
+				bulki, err = bulkDocer.BulkDocs(ctx, valid, opts)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if rejected == nil {
+			results := make([]BulkResult, len(bulki))
+			for i, result := range bulki {
+				results[i] = BulkResult(result)
+			}
+			return results, nil
+		}
+		if len(bulki) != len(valid) {
+			// The driver did not report one result per document, as with
+			// new_edits=false, so the results cannot be interleaved.
+			results := make([]BulkResult, 0, len(docsi)-len(valid)+len(bulki))
+			for _, result := range rejected {
+				if result.Error != nil {
+					results = append(results, result)
+				}
+			}
+			for _, result := range bulki {
+				results = append(results, BulkResult(result))
+			}
+			return results, nil
 		}
-		results := make([]BulkResult, len(bulki))
-		for i, result := range bulki {
-			results[i] = BulkResult(result)
+		for j, result := range bulki {
+			rejected[index[j]] = BulkResult(result)
 		}
-		return results, nil
+		return rejected, nil
 	}
 	results := make([]BulkResult, 0, len(docsi))
 	for _, doc := range docsi {
@@ -81,6 +152,158 @@ func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Optio
 	return results, nil
 }
 
+// BulkDocError describes the failure of a single document within a bulk
+// operation.
+type BulkDocError struct {
+	// Index is the position of the document in the request.
+	Index int
+	// ID is the document ID, if known.
+	ID string
+	// Status is the HTTP status of the failure, such as 409 for a conflict.
+	Status int
+	// Err is the error reported for the document.
+	Err error
+}
+
+var _ statusCoder = &BulkDocError{}
+
+func (e *BulkDocError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("document %d: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("document %d (%s): %s", e.Index, e.ID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BulkDocError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns the HTTP status of the failure.
+func (e *BulkDocError) HTTPStatus() int {
+	return e.Status
+}
+
+// BulkError is an aggregate error, describing the documents which failed in a
+// bulk operation such as [DB.BulkDocs]. The remaining documents were saved
+// successfully.
+//
+// [errors.Is] and [errors.As] match a BulkError against each of the
+// per-document errors, so that, for instance, a conflict on any document may
+// be detected with:
+//
+//	var docErr *kivik.BulkDocError
+//	if errors.As(err, &docErr) && docErr.Status == http.StatusConflict {
+//	    // ...
+//	}
+type BulkError struct {
+	// Errors lists the documents which failed, in request order.
+	Errors []*BulkDocError
+	// Total is the total number of documents in the request.
+	Total int
+}
+
+var _ statusCoder = &BulkError{}
+
+// NewBulkError returns a *BulkError describing the failed documents in
+// results, as returned by [DB.BulkDocs], or nil if all documents were saved
+// successfully.
+func NewBulkError(results []BulkResult) error {
+	var errs []*BulkDocError
+	for i, result := range results {
+		if result.Error == nil {
+			continue
+		}
+		errs = append(errs, &BulkDocError{
+			Index:  i,
+			ID:     result.ID,
+			Status: HTTPStatus(result.Error),
+			Err:    result.Error,
+		})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &BulkError{Errors: errs, Total: len(results)}
+}
+
+func (e *BulkError) Error() string {
+	switch len(e.Errors) {
+	case 0:
+		return fmt.Sprintf("kivik: 0 of %d documents failed", e.Total)
+	case 1:
+		return fmt.Sprintf("kivik: 1 of %d documents failed: %s", e.Total, e.Errors[0])
+	}
+	return fmt.Sprintf("kivik: %d of %d documents failed; first: %s", len(e.Errors), e.Total, e.Errors[0])
+}
+
+// HTTPStatus returns the HTTP status shared by all failed documents, or
+// [net/http.StatusInternalServerError] if they differ, or if there are none.
+func (e *BulkError) HTTPStatus() int {
+	if len(e.Errors) == 0 {
+		return http.StatusInternalServerError
+	}
+	status := e.Errors[0].Status
+	for _, docErr := range e.Errors[1:] {
+		if docErr.Status != status {
+			return http.StatusInternalServerError
+		}
+	}
+	return status
+}
+
+// Is reports whether any of the per-document errors matches target.
+func (e *BulkError) Is(target error) bool {
+	for _, docErr := range e.Errors {
+		if errors.Is(docErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first per-document error which matches target, and if one is
+// found, sets target to that error value and returns true.
+func (e *BulkError) As(target interface{}) bool {
+	for _, docErr := range e.Errors {
+		if errors.As(docErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareBulk runs the registered validators on each of docs, and encodes
+// them with the database's codec, if any. It returns the prepared documents
+// and their positions in docs. If any document is invalid, or fails to
+// encode, rejected holds one result per document, with the Error field set
+// for each failed one.
+func (db *DB) prepareBulk(docs []interface{}) (valid []interface{}, index []int, rejected []BulkResult) {
+	if !db.hasValidators() && db.codec == nil {
+		return docs, nil, nil
+	}
+	valid = make([]interface{}, 0, len(docs))
+	index = make([]int, 0, len(docs))
+	results := make([]BulkResult, len(docs))
+	for i, doc := range docs {
+		doc, err := db.validate(doc)
+		if err == nil {
+			doc, err = db.encode("", doc)
+		}
+		if err != nil {
+			id, _ := extractDocID(docs[i])
+			results[i] = BulkResult{ID: id, Error: err}
+			continue
+		}
+		valid = append(valid, doc)
+		index = append(index, i)
+	}
+	if len(valid) == len(docs) {
+		return valid, nil, nil
+	}
+	return valid, index, results
+}
+
 func docsInterfaceSlice(docsi []interface{}) ([]interface{}, error) {
 	for i, doc := range docsi {
 		x, err := normalizeFromJSON(doc)
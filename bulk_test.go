@@ -195,6 +195,46 @@ func TestBulkDocs(t *testing.T) { // nolint: gocyclo
 			{ID: "foo"},
 		},
 	})
+	tests.Add("bulk error", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, _ []interface{}, opts map[string]interface{}) ([]driver.BulkResult, error) {
+					if _, ok := opts[optionBulkError]; ok {
+						return nil, errors.New("kivik options passed to driver")
+					}
+					return []driver.BulkResult{
+						{ID: "foo", Rev: "1-xxx"},
+						{ID: "bar", Error: &Error{Status: http.StatusConflict, Message: "conflict"}},
+					}, nil
+				},
+			},
+		},
+		docs: []interface{}{
+			map[string]string{"_id": "foo"},
+			map[string]string{"_id": "bar"},
+		},
+		options: WithBulkError(),
+		expected: []BulkResult{
+			{ID: "foo", Rev: "1-xxx"},
+			{ID: "bar", Error: &Error{Status: http.StatusConflict, Message: "conflict"}},
+		},
+		status: http.StatusConflict,
+		err:    "kivik: 1 of 2 documents failed: document 1 (bar): conflict",
+	})
+	tests.Add("bulk error, no failures", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error) {
+					return []driver.BulkResult{{ID: "foo", Rev: "1-xxx"}}, nil
+				},
+			},
+		},
+		docs:     []interface{}{map[string]string{"_id": "foo"}},
+		options:  WithBulkError(),
+		expected: []BulkResult{{ID: "foo", Rev: "1-xxx"}},
+	})
 	tests.Add(errClientClosed, tt{
 		db: &DB{
 			client: &Client{
@@ -251,3 +291,72 @@ func TestBulkDocs(t *testing.T) { // nolint: gocyclo
 		}
 	})
 }
+
+func TestNewBulkError(t *testing.T) {
+	t.Run("no failures", func(t *testing.T) {
+		err := NewBulkError([]BulkResult{{ID: "a", Rev: "1-a"}})
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	errNetwork := errors.New("network error")
+	results := []BulkResult{
+		{ID: "a", Rev: "1-a"},
+		{ID: "b", Error: &Error{Status: http.StatusConflict, Message: "conflict"}},
+		{ID: "c", Rev: "1-c"},
+		{Error: errNetwork},
+	}
+	err := NewBulkError(results)
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+	type docErr struct {
+		Index  int
+		ID     string
+		Status int
+	}
+	got := make([]docErr, 0, len(bulkErr.Errors))
+	for _, e := range bulkErr.Errors {
+		got = append(got, docErr{Index: e.Index, ID: e.ID, Status: e.Status})
+	}
+	want := []docErr{
+		{Index: 1, ID: "b", Status: http.StatusConflict},
+		{Index: 3, Status: http.StatusInternalServerError},
+	}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	if bulkErr.Total != 4 {
+		t.Errorf("Unexpected total: %d", bulkErr.Total)
+	}
+	if want, got := "kivik: 2 of 4 documents failed; first: document 1 (b): conflict", err.Error(); got != want {
+		t.Errorf("Unexpected error message: %s", got)
+	}
+	if !errors.Is(err, errNetwork) {
+		t.Error("errors.Is should match a per-document error")
+	}
+	var conflict *BulkDocError
+	if !errors.As(err, &conflict) || conflict.ID != "b" {
+		t.Errorf("errors.As should find the first per-document error, got %v", conflict)
+	}
+	if status := HTTPStatus(err); status != http.StatusInternalServerError {
+		t.Errorf("Unexpected status for mixed failures: %d", status)
+	}
+	if status := HTTPStatus(NewBulkError(results[:2])); status != http.StatusConflict {
+		t.Errorf("Unexpected status for a single failure: %d", status)
+	}
+	if want, got := "kivik: 1 of 2 documents failed: document 1 (b): conflict", NewBulkError(results[:2]).Error(); got != want {
+		t.Errorf("Unexpected error message: %s", got)
+	}
+	t.Run("empty", func(t *testing.T) {
+		err := &BulkError{}
+		if status := HTTPStatus(err); status != http.StatusInternalServerError {
+			t.Errorf("Unexpected status: %d", status)
+		}
+		if want, got := "kivik: 0 of 0 documents failed", err.Error(); got != want {
+			t.Errorf("Unexpected error message: %s", got)
+		}
+	})
+}
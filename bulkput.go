@@ -0,0 +1,140 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Default values used by [BulkPut] when the corresponding option is not set.
+const (
+	DefaultBulkBatchSize   = 1000
+	DefaultBulkConcurrency = 1
+)
+
+// Option keys used by [BulkPut].
+const (
+	optionBatchSize   = "kivik:batchSize"
+	optionConcurrency = "kivik:concurrency"
+)
+
+// WithBatchSize returns an option which sets the maximum number of documents
+// sent in each request by [BulkPut].
+func WithBatchSize(n int) Options {
+	return Options{optionBatchSize: n}
+}
+
+// WithConcurrency returns an option which sets the maximum number of
+// concurrent requests made by [BulkPut].
+func WithConcurrency(n int) Options {
+	return Options{optionConcurrency: n}
+}
+
+// bulkPutOption extracts the positive integer option key, described by name,
+// from opts, or returns def if it is not set.
+func bulkPutOption(opts Options, key, name string, def int) (int, error) {
+	v, ok := opts[key]
+	if !ok {
+		return def, nil
+	}
+	delete(opts, key)
+	n, ok := v.(int)
+	if !ok || n < 1 {
+		return 0, &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid %s %v: must be a positive integer", name, v)}
+	}
+	return n, nil
+}
+
+// BulkPut stores docs in db, in batches of up to [WithBatchSize] documents,
+// with up to [WithConcurrency] calls to [DB.BulkDocs] in flight at once. It is
+// meant for importing large numbers of documents. Other options are passed to
+// each call to BulkDocs.
+//
+// The returned results correspond to docs, in order. If a batch fails as a
+// whole, the error is reported for each of its documents, and the remaining
+// batches are still attempted, unless ctx is cancelled. The returned error is
+// nil if every document was stored, and otherwise a [*BulkError] describing
+// the documents which failed.
+func BulkPut(ctx context.Context, db *DB, docs []interface{}, options ...Options) ([]BulkResult, error) {
+	if len(docs) == 0 {
+		return nil, &Error{Status: http.StatusBadRequest, Err: errors.New("kivik: no documents provided")}
+	}
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size, err := bulkPutOption(opts, optionBatchSize, "batch size", DefaultBulkBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := bulkPutOption(opts, optionConcurrency, "concurrency", DefaultBulkConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	// The failures of each batch are collected below, not by BulkDocs.
+	bulkErrorOption(opts)
+
+	results := make([]BulkResult, len(docs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for start := 0; start < len(docs); start += size {
+		end := start + size
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			failBatch(results[start:], docs[start:], err)
+			break
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			batch, err := db.BulkDocs(ctx, docs[start:end], opts)
+			if err != nil {
+				failBatch(results[start:end], docs[start:end], err)
+				return
+			}
+			for i := range results[start:end] {
+				if i < len(batch) {
+					results[start+i] = batch[i]
+					continue
+				}
+				failBatch(results[start+i:start+i+1], docs[start+i:start+i+1],
+					&Error{Status: http.StatusInternalServerError, Message: "kivik: no result returned for document"})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return results, NewBulkError(results)
+}
+
+// failBatch records err as the result of each of docs.
+func failBatch(results []BulkResult, docs []interface{}, err error) {
+	for i, doc := range docs {
+		id, _ := extractDocID(doc)
+		results[i] = BulkResult{ID: id, Error: err}
+	}
+}
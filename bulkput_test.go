@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestBulkPut(t *testing.T) {
+	docs := func(n int) []interface{} {
+		docs := make([]interface{}, n)
+		for i := range docs {
+			docs[i] = map[string]interface{}{"_id": fmt.Sprintf("doc%d", i)}
+		}
+		return docs
+	}
+	// bulkDB returns a DB whose BulkDocs saves each document, unless its ID is
+	// in fail, and fails requests containing the document failBatch.
+	bulkDB := func(fail map[string]bool, failBatch string, sizes *[]int, inFlight, maxInFlight *int32) *DB {
+		var mu sync.Mutex
+		return &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, opts map[string]interface{}) ([]driver.BulkResult, error) {
+					n := atomic.AddInt32(inFlight, 1)
+					defer atomic.AddInt32(inFlight, -1)
+					for {
+						max := atomic.LoadInt32(maxInFlight)
+						if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+							break
+						}
+					}
+					if _, ok := opts[optionBatchSize]; ok {
+						return nil, errors.New("kivik options passed to driver")
+					}
+					mu.Lock()
+					*sizes = append(*sizes, len(docs))
+					mu.Unlock()
+					results := make([]driver.BulkResult, len(docs))
+					for i, doc := range docs {
+						id := doc.(map[string]interface{})["_id"].(string)
+						if id == failBatch {
+							return nil, &Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}
+						}
+						results[i] = driver.BulkResult{ID: id, Rev: "1-xxx"}
+						if fail[id] {
+							results[i] = driver.BulkResult{ID: id, Error: &Error{Status: http.StatusConflict, Message: "conflict"}}
+						}
+					}
+					return results, nil
+				},
+			},
+		}
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		var sizes []int
+		var inFlight, maxInFlight int32
+		db := bulkDB(nil, "", &sizes, &inFlight, &maxInFlight)
+		results, err := BulkPut(context.Background(), db, docs(1050), WithBatchSize(100), WithConcurrency(3), Param("new_edits", true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1050 {
+			t.Fatalf("Unexpected number of results: %d", len(results))
+		}
+		for i, result := range results {
+			if want := fmt.Sprintf("doc%d", i); result.ID != want {
+				t.Fatalf("Result %d has ID %s, want %s", i, result.ID, want)
+			}
+		}
+		total := 0
+		for _, n := range sizes {
+			total += n
+			if n > 100 {
+				t.Errorf("Batch of %d documents exceeds batch size", n)
+			}
+		}
+		if len(sizes) != 11 || total != 1050 {
+			t.Errorf("Unexpected batches: %v", sizes)
+		}
+		if maxInFlight > 3 {
+			t.Errorf("%d concurrent requests exceeds concurrency", maxInFlight)
+		}
+	})
+	t.Run("per-document and batch failures", func(t *testing.T) {
+		var sizes []int
+		var inFlight, maxInFlight int32
+		db := bulkDB(map[string]bool{"doc1": true}, "doc7", &sizes, &inFlight, &maxInFlight)
+		results, err := BulkPut(context.Background(), db, docs(10), WithBatchSize(5))
+		var bulkErr *BulkError
+		if !errors.As(err, &bulkErr) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(bulkErr.Errors) != 6 || bulkErr.Total != 10 {
+			t.Errorf("Unexpected failures: %v", err)
+		}
+		if HTTPStatus(results[1].Error) != http.StatusConflict {
+			t.Errorf("Unexpected result for doc1: %v", results[1].Error)
+		}
+		for _, result := range results[5:] {
+			if HTTPStatus(result.Error) != http.StatusServiceUnavailable {
+				t.Errorf("Unexpected result for %s: %v", result.ID, result.Error)
+			}
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var sizes []int
+		var inFlight, maxInFlight int32
+		results, err := BulkPut(ctx, bulkDB(nil, "", &sizes, &inFlight, &maxInFlight), docs(3))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if results[2].ID != "doc2" {
+			t.Errorf("Unexpected result: %v", results[2])
+		}
+	})
+	t.Run("invalid batch size", func(t *testing.T) {
+		_, err := BulkPut(context.Background(), &DB{}, docs(1), WithBatchSize(0))
+		testy.StatusError(t, "kivik: invalid batch size 0: must be a positive integer", http.StatusBadRequest, err)
+	})
+	t.Run("no documents", func(t *testing.T) {
+		_, err := BulkPut(context.Background(), &DB{}, nil)
+		testy.StatusError(t, "kivik: no documents provided", http.StatusBadRequest, err)
+	})
+}
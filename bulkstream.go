@@ -0,0 +1,170 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// BulkSource is a stream of documents, for [DB.BulkDocsFrom].
+type BulkSource interface {
+	// Next returns the next document, or [io.EOF] when there are no more
+	// documents. As with [DB.Put], a document may be any JSON-marshalable
+	// value, a [encoding/json.RawMessage] or an [io.Reader].
+	Next() (interface{}, error)
+}
+
+type ndjsonSource struct {
+	dec *json.Decoder
+}
+
+// NDJSONSource returns a source which reads newline-delimited JSON documents
+// from r. Any whitespace, not only newlines, may separate the documents.
+func NDJSONSource(r io.Reader) BulkSource {
+	return &ndjsonSource{dec: json.NewDecoder(r)}
+}
+
+func (s *ndjsonSource) Next() (interface{}, error) {
+	var doc json.RawMessage
+	if err := s.dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: invalid NDJSON document", Err: err}
+	}
+	return doc, nil
+}
+
+type chanSource <-chan interface{}
+
+// ChanSource returns a source which reads documents from ch, until it is
+// closed.
+func ChanSource(ch <-chan interface{}) BulkSource {
+	return chanSource(ch)
+}
+
+func (s chanSource) Next() (interface{}, error) {
+	doc, ok := <-s
+	if !ok {
+		return nil, io.EOF
+	}
+	return doc, nil
+}
+
+// normalizedSource passes the documents of a BulkSource through
+// normalizeFromJSON.
+type normalizedSource struct {
+	BulkSource
+}
+
+var _ driver.DocSource = &normalizedSource{}
+
+func (s *normalizedSource) Next() (interface{}, error) {
+	doc, err := s.BulkSource.Next()
+	if err != nil {
+		return nil, err
+	}
+	return normalizeFromJSON(doc)
+}
+
+// BulkDocsFrom works like [DB.BulkDocs], but reads the documents from src,
+// so that large imports need not be held in memory. If the driver implements
+// [driver.BulkDocsStreamer], the documents are streamed into a single
+// request. Otherwise, they are read in batches of up to [WithBatchSize]
+// documents, each of which is stored with BulkDocs before the next is read.
+// Batches are also used if validators have been registered with
+// [DB.AddValidator], or a [Codec] is in use.
+//
+// The results correspond to the documents read from src, in order. If src
+// returns an error, or a batch fails as a whole, BulkDocsFrom stops, and
+// returns the results of the batches already stored along with the error.
+// As with BulkDocs, the failures of individual documents are collected into
+// a single error only if [WithBulkError] is passed.
+func (db *DB) BulkDocsFrom(ctx context.Context, src BulkSource, options ...Options) ([]BulkResult, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if src == nil {
+		return nil, missingArg("src")
+	}
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size, err := bulkPutOption(opts, optionBatchSize, "batch size", DefaultBulkBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	bulkError := bulkErrorOption(opts)
+	results, err := db.bulkDocsFrom(ctx, src, size, opts)
+	return bulkResults(results, err, bulkError)
+}
+
+// bulkDocsFrom stores the documents read from src, for BulkDocsFrom.
+func (db *DB) bulkDocsFrom(ctx context.Context, src BulkSource, size int, opts Options) ([]BulkResult, error) {
+	docs := &normalizedSource{BulkSource: src}
+	if streamer, ok := optional(db.driverDB, (*driver.BulkDocsStreamer)(nil)).(driver.BulkDocsStreamer); ok && !db.hasValidators() && db.codec == nil {
+		if err := db.startQuery(); err != nil {
+			return nil, err
+		}
+		defer db.endQuery()
+		var bulki []driver.BulkResult
+		err := db.client.invoke(ctx, &Operation{Method: "BulkDocs", DB: db.name, Options: opts}, func(ctx context.Context) (err error) {
+			bulki, err = streamer.BulkDocsStream(ctx, docs, opts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		results := make([]BulkResult, len(bulki))
+		for i, result := range bulki {
+			results[i] = BulkResult(result)
+		}
+		return results, nil
+	}
+	var results []BulkResult
+	for {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		// The driver may retain each batch, so a new one is allocated.
+		batch := make([]interface{}, 0, size)
+		var srcErr error
+		for len(batch) < size {
+			doc, err := docs.Next()
+			if err != nil {
+				srcErr = err
+				break
+			}
+			batch = append(batch, doc)
+		}
+		if len(batch) > 0 {
+			batchResults, err := db.BulkDocs(ctx, batch, opts)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, batchResults...)
+		}
+		if srcErr == io.EOF {
+			return results, nil
+		}
+		if srcErr != nil {
+			return results, srcErr
+		}
+	}
+}
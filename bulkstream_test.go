@@ -0,0 +1,186 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// docIDs returns the _id field of each of docs, which must be
+// json.RawMessage or map[string]interface{} values.
+func docIDs(t *testing.T, docs []interface{}) []string {
+	t.Helper()
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		var d struct {
+			ID string `json:"_id"`
+		}
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(raw, &d); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func TestBulkDocsFrom(t *testing.T) {
+	const ndjson = `{"_id":"a"}
+{"_id":"b"}
+{"_id":"c"}
+`
+	saveAll := func(docs []interface{}) []driver.BulkResult {
+		results := make([]driver.BulkResult, len(docs))
+		for i, doc := range docs {
+			var d struct {
+				ID string `json:"_id"`
+			}
+			raw, _ := json.Marshal(doc)
+			_ = json.Unmarshal(raw, &d)
+			results[i] = driver.BulkResult{ID: d.ID, Rev: "1-xxx"}
+		}
+		return results
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		var batches [][]string
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+					batches = append(batches, docIDs(t, docs))
+					return saveAll(docs), nil
+				},
+			},
+		}
+		results, err := db.BulkDocsFrom(context.Background(), NDJSONSource(strings.NewReader(ndjson)), WithBatchSize(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([][]string{{"a", "b"}, {"c"}}, batches); d != nil {
+			t.Error(d)
+		}
+		if len(results) != 3 || results[2].ID != "c" {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("stream", func(t *testing.T) {
+		var ids []string
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocsStreamer{
+				BulkDocsStreamFunc: func(_ context.Context, src driver.DocSource, opts map[string]interface{}) ([]driver.BulkResult, error) {
+					if _, ok := opts[optionBatchSize]; ok {
+						return nil, errors.New("kivik options passed to driver")
+					}
+					var docs []interface{}
+					for {
+						doc, err := src.Next()
+						if err == io.EOF {
+							break
+						}
+						if err != nil {
+							return nil, err
+						}
+						docs = append(docs, doc)
+					}
+					ids = docIDs(t, docs)
+					return saveAll(docs), nil
+				},
+			},
+		}
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			ch <- map[string]interface{}{"_id": "x"}
+			ch <- strings.NewReader(`{"_id":"y"}`)
+		}()
+		results, err := db.BulkDocsFrom(context.Background(), ChanSource(ch), WithBatchSize(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"x", "y"}, ids); d != nil {
+			t.Error(d)
+		}
+		if len(results) != 2 {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("bulk error", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, opts map[string]interface{}) ([]driver.BulkResult, error) {
+					if _, ok := opts[optionBulkError]; ok {
+						return nil, errors.New("kivik options passed to driver")
+					}
+					results := saveAll(docs)
+					for i := range results {
+						if results[i].ID == "c" {
+							results[i] = driver.BulkResult{ID: "c", Error: &Error{Status: http.StatusConflict, Message: "conflict"}}
+						}
+					}
+					return results, nil
+				},
+			},
+		}
+		results, err := db.BulkDocsFrom(context.Background(), NDJSONSource(strings.NewReader(ndjson)), WithBatchSize(2), WithBulkError())
+		testy.StatusError(t, "kivik: 1 of 3 documents failed: document 2 (c): conflict", http.StatusConflict, err)
+		if len(results) != 3 {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("invalid NDJSON", func(t *testing.T) {
+		var batches int
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+					batches++
+					return saveAll(docs), nil
+				},
+			},
+		}
+		results, err := db.BulkDocsFrom(context.Background(), NDJSONSource(strings.NewReader(`{"_id":"a"} {"_id":`)))
+		if batches != 1 || len(results) != 1 {
+			t.Errorf("The documents read before the error should be stored; got %d batches, %d results", batches, len(results))
+		}
+		testy.StatusError(t, "kivik: invalid NDJSON document: unexpected EOF", http.StatusBadRequest, err)
+	})
+	t.Run("nil source", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.BulkDocer{}}
+		_, err := db.BulkDocsFrom(context.Background(), nil)
+		testy.StatusError(t, "kivik: src required", http.StatusBadRequest, err)
+	})
+}
+
+func TestPassthroughBulkBatchSize(t *testing.T) {
+	if passthrough.BulkBatchSize != DefaultBulkBatchSize {
+		t.Errorf("passthrough.BulkBatchSize is %d, but DefaultBulkBatchSize is %d", passthrough.BulkBatchSize, DefaultBulkBatchSize)
+	}
+}
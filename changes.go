@@ -14,7 +14,6 @@ package kivik
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -76,26 +75,47 @@ func (c *Changes) ScanDoc(dest interface{}) error {
 		return err
 	}
 	defer runlock()
-	return json.Unmarshal(c.curVal.(*driver.Change).Doc, dest)
+	return unmarshalDoc(c.curVal.(*driver.Change).Doc, dest, c.useNumber, c.codec)
 }
 
 // Changes returns an iterator over the real-time changes feed. The feed remains
 // open until explicitly closed, or an error is encountered.
 //
+// The feed may be limited to specific documents with [DocIDsFilter], to
+// documents matching a Mango selector with [SelectorFilter], or to design
+// documents with [DesignFilter]. The doc_ids and selector options imply the
+// corresponding filter, and the _doc_ids and _selector filters without their
+// option result in a 400 Bad Request error. Drivers are expected to send the
+// doc_ids and selector options in the request body.
+//
 // See http://couchdb.readthedocs.io/en/latest/api/database/changes.html#get--db-_changes
 func (db *DB) Changes(ctx context.Context, options ...Options) *Changes {
 	if db.err != nil {
 		return &Changes{iter: errIterator(db.err)}
 	}
+	opts := mergeOptions(options...)
+	if err := validateChangesOptions(opts); err != nil {
+		return &Changes{iter: errIterator(err)}
+	}
+	if err := normalizeChangesOptions(opts); err != nil {
+		return &Changes{iter: errIterator(err)}
+	}
 	if err := db.startQuery(); err != nil {
 		return &Changes{iter: errIterator(err)}
 	}
-	changesi, err := db.driverDB.Changes(ctx, mergeOptions(options...))
+	var changesi driver.Changes
+	op := &Operation{Method: "Changes", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		changesi, err = db.driverDB.Changes(ctx, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &Changes{iter: errIterator(err)}
 	}
-	return newChanges(ctx, db.endQuery, changesi)
+	it := newChanges(ctx, db.endQuery, changesi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
 // Seq returns the Seq of the current result.
@@ -336,6 +336,74 @@ func TestChanges(t *testing.T) {
 	})
 }
 
+func TestChangesFilterOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		want   Options
+		status int
+		err    string
+	}{
+		{
+			name: "no filter",
+			opts: Options{"since": "now"},
+			want: Options{"since": "now"},
+		},
+		{
+			name: "doc ids imply filter",
+			opts: Options{"doc_ids": []string{"a"}},
+			want: Options{"filter": "_doc_ids", "doc_ids": []string{"a"}},
+		},
+		{
+			name: "selector implies filter",
+			opts: ChangesOptions{Selector: map[string]interface{}{"a": 1}}.Options(),
+			want: Options{"filter": "_selector", "selector": map[string]interface{}{"a": 1}},
+		},
+		{
+			name: "design filter",
+			opts: DesignFilter(),
+			want: Options{"filter": "_design"},
+		},
+		{
+			name:   "doc ids without filter argument",
+			opts:   Param("filter", "_doc_ids"),
+			status: http.StatusBadRequest,
+			err:    "kivik: _doc_ids filter requires the doc_ids option",
+		},
+		{
+			name:   "selector without filter argument",
+			opts:   Param("filter", "_selector"),
+			status: http.StatusBadRequest,
+			err:    "kivik: _selector filter requires the selector option",
+		},
+		{
+			name:   "doc ids and selector",
+			opts:   Options{"doc_ids": []string{"a"}, "selector": `{}`},
+			status: http.StatusBadRequest,
+			err:    "kivik: doc_ids and selector options are mutually exclusive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Options
+			db := &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+						got = opts
+						return &mock.Changes{}, nil
+					},
+				},
+			}
+			err := db.Changes(context.Background(), tt.opts).Err()
+			if d := testy.DiffInterface(tt.want, got); d != nil {
+				t.Error(d)
+			}
+			testy.StatusError(t, tt.err, tt.status, err)
+		})
+	}
+}
+
 func TestChanges_uninitialized_should_not_panic(*testing.T) {
 	// These must not panic, because they can be called before iterating
 	// begins.
@@ -343,3 +411,48 @@ func TestChanges_uninitialized_should_not_panic(*testing.T) {
 	_, _ = c.Metadata()
 	_ = c.ETag()
 }
+
+func TestChangesFromFixture(t *testing.T) {
+	feed, err := mock.ChangesFeedFromJSON(`{
+		"results": [
+			{"seq": "1-x", "id": "a", "changes": [{"rev": "1-a"}]},
+			{"seq": "2-x", "id": "b", "deleted": true, "changes": [{"rev": "2-b"}]}
+		],
+		"last_seq": "2-x",
+		"pending": 3
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newChanges(context.Background(), nil, feed.Changes())
+	var got []string
+	for c.Next() {
+		got = append(got, fmt.Sprintf("%s %s %v %v", c.Seq(), c.ID(), c.Changes(), c.Deleted()))
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1-x a [1-a] false", "2-x b [2-b] true"}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	meta, err := c.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&ChangesMetadata{LastSeq: "2-x", Pending: 3}, meta); d != nil {
+		t.Error(d)
+	}
+
+	t.Run("error mid-stream", func(t *testing.T) {
+		feed := mock.NewChangesFeed(driver.Change{ID: "a"}).AddError(errors.New("timeout"))
+		c := newChanges(context.Background(), nil, feed.Changes())
+		if !c.Next() {
+			t.Fatalf("Next() returned false: %v", c.Err())
+		}
+		if c.Next() {
+			t.Fatal("Next() should return false after an error")
+		}
+		testy.Error(t, "timeout", c.Err())
+	})
+}
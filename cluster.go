@@ -29,11 +29,17 @@ func (c *Client) ClusterStatus(ctx context.Context, options ...Options) (string,
 		return "", err
 	}
 	defer c.endQuery()
-	cluster, ok := c.driverClient.(driver.Cluster)
+	cluster, ok := optional(c.driverClient, (*driver.Cluster)(nil)).(driver.Cluster)
 	if !ok {
 		return "", clusterNotImplemented
 	}
-	return cluster.ClusterStatus(ctx, mergeOptions(options...))
+	var status string
+	opts := mergeOptions(options...)
+	err := c.invoke(ctx, &Operation{Method: "ClusterStatus", Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		status, err = cluster.ClusterStatus(ctx, opts)
+		return err
+	})
+	return status, err
 }
 
 // ClusterSetup performs the requested cluster action. action should be
@@ -46,11 +52,13 @@ func (c *Client) ClusterSetup(ctx context.Context, action interface{}) error {
 		return err
 	}
 	defer c.endQuery()
-	cluster, ok := c.driverClient.(driver.Cluster)
+	cluster, ok := optional(c.driverClient, (*driver.Cluster)(nil)).(driver.Cluster)
 	if !ok {
 		return clusterNotImplemented
 	}
-	return cluster.ClusterSetup(ctx, action)
+	return c.invoke(ctx, &Operation{Method: "ClusterSetup"}, func(ctx context.Context) error {
+		return cluster.ClusterSetup(ctx, action)
+	})
 }
 
 // ClusterMembership contains the list of known nodes, and cluster nodes, as returned
@@ -70,10 +78,14 @@ func (c *Client) Membership(ctx context.Context) (*ClusterMembership, error) {
 		return nil, err
 	}
 	defer c.endQuery()
-	cluster, ok := c.driverClient.(driver.Cluster)
+	cluster, ok := optional(c.driverClient, (*driver.Cluster)(nil)).(driver.Cluster)
 	if !ok {
 		return nil, clusterNotImplemented
 	}
-	nodes, err := cluster.Membership(ctx)
+	var nodes *driver.ClusterMembership
+	err := c.invoke(ctx, &Operation{Method: "Membership", ReadOnly: true}, func(ctx context.Context) (err error) {
+		nodes, err = cluster.Membership(ctx)
+		return err
+	})
 	return (*ClusterMembership)(nodes), err
 }
@@ -0,0 +1,125 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// optionCodec is the option key used to pass [WithCodec] to [New] or
+// [Client.DB].
+const optionCodec = "kivik:codec"
+
+// Codec transforms the JSON bodies of documents as they are written to, and
+// read from, a database, for instance to encrypt sensitive fields at rest.
+// See [github.com/go-kivik/kivik/v4/x/encrypt] for an AES-GCM implementation.
+//
+// Implementations must be safe for concurrent use.
+type Codec interface {
+	// Encode returns the form of doc to be stored. doc is the complete
+	// document, including special fields such as _id, _rev and _deleted,
+	// which must be preserved so that the database can interpret them. The
+	// _id field is present whenever the ID is known before the document is
+	// stored, including for [DB.Put].
+	Encode(doc json.RawMessage) (json.RawMessage, error)
+	// Decode reverses Encode. It should return documents which were not
+	// written with Encode, such as those stored before the codec was
+	// introduced, unaltered.
+	Decode(doc json.RawMessage) (json.RawMessage, error)
+}
+
+// WithCodec returns an option which, when passed to [New] or [Client.DB],
+// causes documents to be encoded with c before they are written by [DB.Put],
+// [DB.CreateDoc] or [DB.BulkDocs], or any of the helpers built on them, and
+// decoded with c when they are read with [DB.Get], or scanned from a
+// [ResultSet] or [Changes] feed with ScanDoc. A codec passed to [Client.DB]
+// takes precedence over one passed to [New]; pass WithCodec(nil) to
+// [Client.DB] to access the encoded documents directly. Design documents and
+// local documents are stored unencoded, so that the database can run the
+// functions of design documents, and read local documents such as
+// replication checkpoints.
+//
+// Documents are encoded after any validators registered with
+// [DB.AddValidator] have been run. Data the database reads itself, such as
+// the input to views and Mango queries, is seen in its encoded form.
+func WithCodec(c Codec) Options {
+	return Options{optionCodec: c}
+}
+
+// encode returns doc encoded with the database's codec, if any. docID is the
+// ID of the document, which is added to doc if it has no _id field, or empty
+// to read it from the _id field of doc. Design and local documents are not
+// encoded, as the database must be able to read them.
+func (db *DB) encode(docID string, doc interface{}) (interface{}, error) {
+	if db.codec == nil {
+		return doc, nil
+	}
+	doc, err := normalizeFromJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) == nil {
+		if docID == "" {
+			_ = json.Unmarshal(fields["_id"], &docID)
+		} else if _, ok := fields["_id"]; !ok {
+			// Let the codec see the ID, for instance to bind to it.
+			fields["_id"], _ = json.Marshal(docID)
+			if raw, err = json.Marshal(fields); err != nil {
+				return nil, &Error{Status: http.StatusBadRequest, Err: err}
+			}
+		}
+	}
+	if strings.HasPrefix(docID, "_design/") || strings.HasPrefix(docID, "_local/") {
+		return doc, nil
+	}
+	encoded, err := db.codec.Encode(raw)
+	if err != nil {
+		return nil, &Error{Message: "kivik: failed to encode document", Err: err}
+	}
+	return encoded, nil
+}
+
+// decodeDoc decodes the document read from r into dest, first decoding it
+// with codec, if it is not nil.
+func decodeDoc(r io.Reader, dest interface{}, useNumber bool, codec Codec) error {
+	if codec == nil {
+		return decodeJSON(r, dest, useNumber)
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return unmarshalDoc(raw, dest, useNumber, codec)
+}
+
+// unmarshalDoc works like decodeDoc, for a document already read.
+func unmarshalDoc(data []byte, dest interface{}, useNumber bool, codec Codec) error {
+	if codec == nil {
+		return unmarshalJSON(data, dest, useNumber)
+	}
+	decoded, err := codec.Decode(bytes.TrimSpace(data))
+	if err != nil {
+		return &Error{Message: "kivik: failed to decode document", Err: err}
+	}
+	return unmarshalJSON(decoded, dest, useNumber)
+}
@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// wrapCodec stores each document's body in a "wrapped" field.
+type wrapCodec struct{}
+
+func (wrapCodec) Encode(doc json.RawMessage) (json.RawMessage, error) {
+	if bytes.Contains(doc, []byte("fail")) {
+		return nil, errors.New("encode failed")
+	}
+	return json.Marshal(map[string]json.RawMessage{"wrapped": doc})
+}
+
+func (wrapCodec) Decode(doc json.RawMessage) (json.RawMessage, error) {
+	var wrapper struct {
+		Wrapped json.RawMessage `json:"wrapped"`
+	}
+	if err := json.Unmarshal(doc, &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Wrapped == nil {
+		return doc, nil
+	}
+	return wrapper.Wrapped, nil
+}
+
+func TestWithCodec(t *testing.T) {
+	const plain = `{"_id":"foo","name":"Bessie"}`
+	const wrapped = `{"wrapped":{"_id":"foo","name":"Bessie"}}`
+	checkDoc := func(doc interface{}) error {
+		if d := testy.DiffAsJSON([]byte(wrapped), doc); d != nil {
+			return fmt.Errorf("Unexpected doc:\n%s", d)
+		}
+		return nil
+	}
+	db := &DB{
+		client: &Client{},
+		codec:  wrapCodec{},
+		driverDB: &mock.BulkDocer{
+			DB: &mock.DB{
+				PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+					return "1-xxx", checkDoc(doc)
+				},
+				CreateDocFunc: func(_ context.Context, doc interface{}, _ map[string]interface{}) (string, string, error) {
+					return "foo", "1-xxx", checkDoc(doc)
+				},
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					return &driver.Document{Rev: "1-xxx", Body: body(wrapped)}, nil
+				},
+				ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+					return &mock.Changes{
+						NextFunc: func(ch *driver.Change) error {
+							ch.ID = "foo"
+							ch.Doc = json.RawMessage(wrapped)
+							return nil
+						},
+					}, nil
+				},
+			},
+			BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+				if len(docs) != 1 {
+					return nil, fmt.Errorf("Unexpected docs: %v", docs)
+				}
+				return []driver.BulkResult{{ID: "foo", Rev: "1-xxx"}}, checkDoc(docs[0])
+			},
+		},
+	}
+	ctx := context.Background()
+
+	t.Run("put", func(t *testing.T) {
+		if _, err := db.Put(ctx, "foo", map[string]string{"name": "Bessie"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.Put(ctx, "foo", map[string]string{"name": "fail"})
+		testy.StatusError(t, "kivik: failed to encode document: encode failed", http.StatusInternalServerError, err)
+	})
+	t.Run("create doc", func(t *testing.T) {
+		if _, _, err := db.CreateDoc(ctx, strings.NewReader(plain)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("bulk docs", func(t *testing.T) {
+		results, err := db.BulkDocs(ctx, []interface{}{json.RawMessage(plain), map[string]string{"_id": "bar", "name": "fail"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Rev != "1-xxx" || results[1].ID != "bar" || results[1].Error == nil {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("get", func(t *testing.T) {
+		var doc json.RawMessage
+		if err := db.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffAsJSON([]byte(plain), doc); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("changes", func(t *testing.T) {
+		changes := db.Changes(ctx)
+		defer changes.Close() // nolint:errcheck
+		if !changes.Next() {
+			t.Fatal(changes.Err())
+		}
+		var doc json.RawMessage
+		if err := changes.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffAsJSON([]byte(plain), doc); d != nil {
+			t.Error(d)
+		}
+	})
+}
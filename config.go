@@ -38,8 +38,12 @@ func (c *Client) Config(ctx context.Context, node string) (Config, error) {
 		return nil, err
 	}
 	defer c.endQuery()
-	if configer, ok := c.driverClient.(driver.Configer); ok {
-		driverCf, err := configer.Config(ctx, node)
+	if configer, ok := optional(c.driverClient, (*driver.Configer)(nil)).(driver.Configer); ok {
+		var driverCf driver.Config
+		err := c.invoke(ctx, &Operation{Method: "Config", ReadOnly: true}, func(ctx context.Context) (err error) {
+			driverCf, err = configer.Config(ctx, node)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -61,8 +65,12 @@ func (c *Client) ConfigSection(ctx context.Context, node, section string) (Confi
 		return nil, err
 	}
 	defer c.endQuery()
-	if configer, ok := c.driverClient.(driver.Configer); ok {
-		sec, err := configer.ConfigSection(ctx, node, section)
+	if configer, ok := optional(c.driverClient, (*driver.Configer)(nil)).(driver.Configer); ok {
+		var sec driver.ConfigSection
+		err := c.invoke(ctx, &Operation{Method: "ConfigSection", ReadOnly: true}, func(ctx context.Context) (err error) {
+			sec, err = configer.ConfigSection(ctx, node, section)
+			return err
+		})
 		return ConfigSection(sec), err
 	}
 	return nil, configNotImplemented
@@ -76,8 +84,13 @@ func (c *Client) ConfigValue(ctx context.Context, node, section, key string) (st
 		return "", err
 	}
 	defer c.endQuery()
-	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.ConfigValue(ctx, node, section, key)
+	if configer, ok := optional(c.driverClient, (*driver.Configer)(nil)).(driver.Configer); ok {
+		var value string
+		err := c.invoke(ctx, &Operation{Method: "ConfigValue", ReadOnly: true}, func(ctx context.Context) (err error) {
+			value, err = configer.ConfigValue(ctx, node, section, key)
+			return err
+		})
+		return value, err
 	}
 	return "", configNotImplemented
 }
@@ -91,8 +104,13 @@ func (c *Client) SetConfigValue(ctx context.Context, node, section, key, value s
 		return "", err
 	}
 	defer c.endQuery()
-	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.SetConfigValue(ctx, node, section, key, value)
+	if configer, ok := optional(c.driverClient, (*driver.Configer)(nil)).(driver.Configer); ok {
+		var oldValue string
+		err := c.invoke(ctx, &Operation{Method: "SetConfigValue"}, func(ctx context.Context) (err error) {
+			oldValue, err = configer.SetConfigValue(ctx, node, section, key, value)
+			return err
+		})
+		return oldValue, err
 	}
 	return "", configNotImplemented
 }
@@ -106,8 +124,13 @@ func (c *Client) DeleteConfigKey(ctx context.Context, node, section, key string)
 		return "", err
 	}
 	defer c.endQuery()
-	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.DeleteConfigKey(ctx, node, section, key)
+	if configer, ok := optional(c.driverClient, (*driver.Configer)(nil)).(driver.Configer); ok {
+		var oldValue string
+		err := c.invoke(ctx, &Operation{Method: "DeleteConfigKey"}, func(ctx context.Context) (err error) {
+			oldValue, err = configer.DeleteConfigKey(ctx, node, section, key)
+			return err
+		})
+		return oldValue, err
 	}
 	return "", configNotImplemented
 }
@@ -0,0 +1,107 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+)
+
+// Conflicts returns the revisions of the conflicting leaves of the document,
+// other than the winning revision returned by [DB.GetRev], as reported in the
+// _conflicts field by CouchDB. It returns an empty slice if the document has
+// no conflicts.
+func (db *DB) Conflicts(ctx context.Context, docID string, options ...Options) ([]string, error) {
+	opts := mergeOptions(mergeOptions(options...), Options{"conflicts": true})
+	var doc struct {
+		Conflicts []string `json:"_conflicts"`
+	}
+	if err := db.Get(ctx, docID, opts).ScanDoc(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Conflicts == nil {
+		return []string{}, nil
+	}
+	return doc.Conflicts, nil
+}
+
+// ResolveConflicts resolves a conflicted document in a single bulk request,
+// by storing winner as the new version of the document and deleting each of
+// the losing revisions. winner must include the _rev of the leaf it replaces,
+// usually the current winning revision, which must not be among losers;
+// otherwise a 400 error is returned.
+//
+// The new revision of the winning document is returned. If winner is nil, no
+// new version of the document is written: the current winning revision is
+// kept as is, only the losers are deleted, and the returned revision is
+// empty. If any of the updates fail, the first error is returned, and the
+// other updates may have been applied.
+func (db *DB) ResolveConflicts(ctx context.Context, docID string, winner interface{}, losers ...string) (rev string, err error) {
+	if docID == "" {
+		return "", missingArg("docID")
+	}
+	docs := make([]interface{}, 0, len(losers)+1)
+	if winner != nil {
+		doc, err := conflictWinner(docID, winner, db.useNumber)
+		if err != nil {
+			return "", err
+		}
+		for _, loser := range losers {
+			if loser == doc["_rev"] {
+				return "", &Error{Status: http.StatusBadRequest, Message: "kivik: winning revision " + loser + " cannot also be a loser"}
+			}
+		}
+		docs = append(docs, doc)
+	}
+	for _, loser := range losers {
+		docs = append(docs, map[string]interface{}{
+			"_id":      docID,
+			"_rev":     loser,
+			"_deleted": true,
+		})
+	}
+	if len(docs) == 0 {
+		return "", missingArg("winner or losers")
+	}
+	results, err := db.BulkDocs(ctx, docs)
+	if err != nil {
+		return "", err
+	}
+	for i, result := range results {
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if i == 0 && winner != nil {
+			rev = result.Rev
+		}
+	}
+	return rev, nil
+}
+
+// conflictWinner converts winner to a map, with its _id set to docID. winner
+// must have a _rev, or it would be stored as a new edit, leaving the
+// conflict unresolved.
+func conflictWinner(docID string, winner interface{}, useNumber bool) (map[string]interface{}, error) {
+	doc, err := toDocMap(winner, useNumber)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := doc["_id"].(string); ok && id != docID {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: winner _id does not match docID"}
+	}
+	if rev, _ := doc["_rev"].(string); rev == "" {
+		return nil, missingArg("winner _rev")
+	}
+	doc["_id"] = docID
+	return doc, nil
+}
@@ -0,0 +1,212 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		db     *DB
+		want   []string
+		status int
+		err    string
+	}{
+		{
+			name: "get error",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+						return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+					},
+				},
+			},
+			status: http.StatusNotFound,
+			err:    "missing",
+		},
+		{
+			name: "no conflicts",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+						return &driver.Document{Body: body(`{"_id":"foo","_rev":"2-a"}`)}, nil
+					},
+				},
+			},
+			want: []string{},
+		},
+		{
+			name: "conflicts",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+						if docID != "foo" {
+							return nil, fmt.Errorf("Unexpected docID: %s", docID)
+						}
+						if d := testy.DiffInterface(map[string]interface{}{"conflicts": true}, opts); d != nil {
+							return nil, fmt.Errorf("Unexpected options:\n%s", d)
+						}
+						return &driver.Document{Body: body(`{"_id":"foo","_rev":"2-a","_conflicts":["2-b","2-c"]}`)}, nil
+					},
+				},
+			},
+			want: []string{"2-b", "2-c"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.db.Conflicts(context.Background(), "foo")
+			testy.StatusError(t, test.err, test.status, err)
+			if d := testy.DiffInterface(test.want, got); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestConflictsOptionsUnchanged(t *testing.T) {
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				return &driver.Document{Body: body(`{"_id":"foo","_rev":"2-a"}`)}, nil
+			},
+		},
+	}
+	options := make([]Options, 1, 2)
+	options[0] = Options{"rev": "2-a"}
+	if _, err := db.Conflicts(context.Background(), "foo", options...); err != nil {
+		t.Fatal(err)
+	}
+	if extra := options[:2][1]; extra != nil {
+		t.Errorf("The caller's options were modified: %v", extra)
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	type tst struct {
+		bulkDocs func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error)
+		winner   interface{}
+		losers   []string
+		rev      string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("nothing to do", tst{
+		status: http.StatusBadRequest,
+		err:    "kivik: winner or losers required",
+	})
+	tests.Add("winner is not an object", tst{
+		winner: []string{"foo"},
+		status: http.StatusBadRequest,
+		err:    "kivik: document must be a JSON object",
+	})
+	tests.Add("winner has wrong ID", tst{
+		winner: map[string]string{"_id": "bar", "_rev": "2-a"},
+		status: http.StatusBadRequest,
+		err:    "kivik: winner _id does not match docID",
+	})
+	tests.Add("winner has no rev", tst{
+		winner: map[string]string{"name": "merged"},
+		losers: []string{"2-b"},
+		status: http.StatusBadRequest,
+		err:    "kivik: winner _rev required",
+	})
+	tests.Add("winner is also a loser", tst{
+		winner: map[string]string{"_rev": "2-a"},
+		losers: []string{"2-b", "2-a"},
+		status: http.StatusBadRequest,
+		err:    "kivik: winning revision 2-a cannot also be a loser",
+	})
+	tests.Add("success", tst{
+		bulkDocs: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+			want := []interface{}{
+				map[string]interface{}{"_id": "foo", "_rev": "2-a", "name": "merged"},
+				map[string]interface{}{"_id": "foo", "_rev": "2-b", "_deleted": true},
+				map[string]interface{}{"_id": "foo", "_rev": "2-c", "_deleted": true},
+			}
+			if d := testy.DiffAsJSON(want, docs); d != nil {
+				return nil, fmt.Errorf("Unexpected docs:\n%s", d)
+			}
+			return []driver.BulkResult{
+				{ID: "foo", Rev: "3-a"},
+				{ID: "foo", Rev: "3-b"},
+				{ID: "foo", Rev: "3-c"},
+			}, nil
+		},
+		winner: map[string]string{"_rev": "2-a", "name": "merged"},
+		losers: []string{"2-b", "2-c"},
+		rev:    "3-a",
+	})
+	tests.Add("losers only", tst{
+		bulkDocs: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+			want := []interface{}{
+				map[string]interface{}{"_id": "foo", "_rev": "2-b", "_deleted": true},
+			}
+			if d := testy.DiffAsJSON(want, docs); d != nil {
+				return nil, fmt.Errorf("Unexpected docs:\n%s", d)
+			}
+			return []driver.BulkResult{{ID: "foo", Rev: "3-b"}}, nil
+		},
+		losers: []string{"2-b"},
+		// No winner is written, so no revision is returned.
+		rev: "",
+	})
+	tests.Add("bulk error", tst{
+		bulkDocs: func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error) {
+			return nil, &Error{Status: http.StatusBadGateway, Err: errors.New("bulk failed")}
+		},
+		losers: []string{"2-b"},
+		status: http.StatusBadGateway,
+		err:    "bulk failed",
+	})
+	tests.Add("update error", tst{
+		bulkDocs: func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error) {
+			return []driver.BulkResult{
+				{ID: "foo", Rev: "3-a"},
+				{ID: "foo", Error: &Error{Status: http.StatusConflict, Message: "document update conflict"}},
+			}, nil
+		},
+		winner: map[string]string{"_rev": "2-a"},
+		losers: []string{"2-b"},
+		status: http.StatusConflict,
+		err:    "document update conflict",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		db := &DB{
+			client:   &Client{},
+			driverDB: &mock.BulkDocer{DB: &mock.DB{}, BulkDocsFunc: test.bulkDocs},
+		}
+		rev, err := db.ResolveConflicts(context.Background(), "foo", test.winner, test.losers...)
+		testy.StatusError(t, test.err, test.status, err)
+		if rev != test.rev {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+}
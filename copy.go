@@ -0,0 +1,204 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	optionSelector = "kivik:selector"
+	optionProgress = "kivik:progress"
+)
+
+// WithSelector returns an option which limits [Copy] to the documents
+// matching the Mango selector, which may be any value accepted as the
+// selector of a [DB.Find] query, such as a map or a JSON string.
+func WithSelector(selector interface{}) Options {
+	return Options{optionSelector: selector}
+}
+
+// WithProgress returns an option which causes [Copy] to call fn after each
+// batch of documents is written, with the running totals.
+func WithProgress(fn func(CopyProgress)) Options {
+	return Options{optionProgress: fn}
+}
+
+// CopyProgress reports the progress of [Copy].
+type CopyProgress struct {
+	// Read is the number of documents read from the source.
+	Read int64
+	// Written is the number of documents written to the target.
+	Written int64
+	// Failed is the number of documents which could not be written.
+	Failed int64
+}
+
+// Copy copies the current revision of every document in source, including
+// design documents and attachments, to target, which may use any driver. It
+// is meant for snapshots, such as copying a CouchDB database to SQLite for
+// offline use, where a full replication is not possible.
+//
+// Documents are read with [DB.AllDocs], or with [DB.Find] when [WithSelector]
+// is used, and written with [DB.BulkDocs], in batches of up to
+// [WithBatchSize] documents. [WithProgress] may be used to observe progress.
+// Other options are passed to AllDocs or Find.
+//
+// Revision history is not copied, so the documents are given new revisions in
+// target. Documents which already exist in target are overwritten. Deleted
+// documents and local documents are not copied.
+//
+// Copy returns the final totals. If any document could not be written, the
+// error is a [*BulkError], whose indexes are the positions of the documents in
+// the order read. If reading from source, or a batch, fails as a whole, Copy
+// stops, and returns the totals so far along with the error.
+func Copy(ctx context.Context, source, target *DB, options ...Options) (*CopyProgress, error) {
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size, err := bulkPutOption(opts, optionBatchSize, "batch size", DefaultBulkBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	progress, _ := opts[optionProgress].(func(CopyProgress))
+	delete(opts, optionProgress)
+	selector, hasSelector := opts[optionSelector]
+	delete(opts, optionSelector)
+
+	var rs ResultSet
+	if hasSelector {
+		query, err := selectorQuery(selector)
+		if err != nil {
+			return nil, err
+		}
+		rs = source.Find(ctx, query, opts)
+	} else {
+		opts["include_docs"] = true
+		rs = source.AllDocs(ctx, opts)
+	}
+	defer rs.Close() // nolint:errcheck
+
+	c := &copier{source: source, target: target, progress: progress}
+	batch := make([]map[string]interface{}, 0, size)
+	for rs.Next() {
+		doc, err := c.read(ctx, rs)
+		if err != nil {
+			return &c.totals, err
+		}
+		batch = append(batch, doc)
+		if len(batch) == size {
+			if err := c.write(ctx, batch); err != nil {
+				return &c.totals, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return &c.totals, err
+	}
+	if err := c.write(ctx, batch); err != nil {
+		return &c.totals, err
+	}
+	if len(c.failures) > 0 {
+		return &c.totals, &BulkError{Errors: c.failures, Total: int(c.totals.Read)}
+	}
+	return &c.totals, nil
+}
+
+// copier holds the state of a call to [Copy].
+type copier struct {
+	source, target *DB
+	progress       func(CopyProgress)
+	totals         CopyProgress
+	failures       []*BulkDocError
+}
+
+// read returns the current document of rs, prepared for writing to the
+// target.
+func (c *copier) read(ctx context.Context, rs ResultSet) (map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := rs.ScanDoc(&raw); err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := unmarshalJSON(raw, &doc, true); err != nil {
+		return nil, err
+	}
+	if _, ok := doc["_attachments"]; ok {
+		// Query results include only attachment stubs, so the document is
+		// fetched again with the attachment content inline.
+		id, _ := doc["_id"].(string)
+		doc = map[string]interface{}{}
+		if err := c.source.Get(ctx, id, Options{"attachments": true}).ScanDoc(&raw); err != nil {
+			return nil, err
+		}
+		if err := unmarshalJSON(raw, &doc, true); err != nil {
+			return nil, err
+		}
+	}
+	delete(doc, "_rev")
+	delete(doc, "_revisions")
+	delete(doc, "_conflicts")
+	c.totals.Read++
+	return doc, nil
+}
+
+// write stores batch in the target, overwriting any existing documents.
+func (c *copier) write(ctx context.Context, batch []map[string]interface{}) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(batch))
+	for i, doc := range batch {
+		docs[i] = doc
+	}
+	results, err := c.target.BulkDocs(ctx, docs)
+	if err != nil {
+		return err
+	}
+	first := c.totals.Read - int64(len(batch))
+	for i, result := range results {
+		if HTTPStatus(result.Error) == http.StatusConflict && i < len(batch) {
+			result.Error = c.overwrite(ctx, result.ID, batch[i])
+		}
+		if result.Error != nil {
+			c.totals.Failed++
+			c.failures = append(c.failures, &BulkDocError{
+				Index:  int(first) + i,
+				ID:     result.ID,
+				Status: HTTPStatus(result.Error),
+				Err:    result.Error,
+			})
+			continue
+		}
+		c.totals.Written++
+	}
+	if c.progress != nil {
+		c.progress(c.totals)
+	}
+	return nil
+}
+
+// overwrite replaces the existing document docID in the target with doc.
+func (c *copier) overwrite(ctx context.Context, docID string, doc map[string]interface{}) error {
+	rev, err := c.target.GetRev(ctx, docID)
+	if err != nil {
+		return err
+	}
+	doc["_rev"] = rev
+	_, err = c.target.Put(ctx, docID, doc)
+	return err
+}
@@ -0,0 +1,182 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// copyRows returns driver rows over docs, which are JSON documents.
+func copyRows(docs ...string) driver.Rows {
+	return &mock.Rows{
+		NextFunc: func(r *driver.Row) error {
+			if len(docs) == 0 {
+				return io.EOF
+			}
+			var meta struct {
+				ID string `json:"_id"`
+			}
+			_ = json.Unmarshal([]byte(docs[0]), &meta)
+			*r = driver.Row{ID: meta.ID, Doc: strings.NewReader(docs[0])}
+			docs = docs[1:]
+			return nil
+		},
+	}
+}
+
+// copySource returns a database containing a design document, a document
+// with a large number, and a document with an attachment, of which only the
+// cow matches any selector.
+func copySource() *DB {
+	return &DB{
+		client: &Client{},
+		driverDB: &mock.Finder{
+			DB: &mock.DB{
+				AllDocsFunc: func(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+					if opts["include_docs"] != true {
+						return nil, errors.New("include_docs expected")
+					}
+					return copyRows(
+						`{"_id":"_design/foo","_rev":"1-a","views":{}}`,
+						`{"_id":"cow","_rev":"2-b","name":"Bessie","weight":1234567890123456789}`,
+						`{"_id":"pig","_rev":"1-c","name":"Wilbur","_attachments":{"oink.txt":{"content_type":"text/plain","stub":true}}}`,
+					), nil
+				},
+				GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+					if docID != "pig" || opts["attachments"] != true {
+						return nil, errors.New("unexpected get")
+					}
+					return &driver.Document{
+						Rev:  "1-c",
+						Body: io.NopCloser(strings.NewReader(`{"_id":"pig","_rev":"1-c","name":"Wilbur","_attachments":{"oink.txt":{"content_type":"text/plain","data":"b2luaw=="}}}`)),
+					}, nil
+				},
+			},
+			FindFunc: func(context.Context, interface{}, map[string]interface{}) (driver.Rows, error) {
+				return copyRows(`{"_id":"cow","_rev":"2-b","name":"Bessie"}`), nil
+			},
+		},
+	}
+}
+
+// copyTarget returns a database which records the documents written to it,
+// and which reports a conflict for the document existing, and a failure for
+// the document failing.
+func copyTarget(written *[]string, existing, failing string) *DB {
+	return &DB{
+		client: &Client{},
+		driverDB: &mock.BulkDocer{
+			DB: &mock.DB{
+				GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+					return &driver.Document{Body: io.NopCloser(strings.NewReader(`{"_id":"` + docID + `","_rev":"1-zzz"}`))}, nil
+				},
+				PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+					body, _ := json.Marshal(doc)
+					*written = append(*written, "put "+string(body))
+					return "2-zzz", nil
+				},
+			},
+			BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+				results := make([]driver.BulkResult, len(docs))
+				for i, doc := range docs {
+					id := doc.(map[string]interface{})["_id"].(string)
+					results[i] = driver.BulkResult{ID: id, Rev: "1-zzz"}
+					switch id {
+					case existing:
+						results[i].Error = &Error{Status: http.StatusConflict, Message: "conflict"}
+					case failing:
+						results[i].Error = &Error{Status: http.StatusForbidden, Message: "forbidden"}
+					default:
+						body, _ := json.Marshal(doc)
+						*written = append(*written, string(body))
+					}
+				}
+				return results, nil
+			},
+		},
+	}
+}
+
+func TestCopyDatabase(t *testing.T) {
+	var written []string
+	var progress []CopyProgress
+	got, err := Copy(context.Background(), copySource(), copyTarget(&written, "cow", ""),
+		WithBatchSize(2),
+		WithProgress(func(p CopyProgress) { progress = append(progress, p) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&CopyProgress{Read: 3, Written: 3}, got); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface([]CopyProgress{{Read: 2, Written: 2}, {Read: 3, Written: 3}}, progress); d != nil {
+		t.Error(d)
+	}
+	want := []string{
+		`{"_id":"_design/foo","views":{}}`,
+		`put {"_id":"cow","_rev":"1-zzz","name":"Bessie","weight":1234567890123456789}`,
+		`{"_attachments":{"oink.txt":{"content_type":"text/plain","data":"b2luaw=="}},"_id":"pig","name":"Wilbur"}`,
+	}
+	if d := testy.DiffInterface(want, written); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestCopyDatabaseSelector(t *testing.T) {
+	var written []string
+	got, err := Copy(context.Background(), copySource(), copyTarget(&written, "", ""),
+		WithSelector(`{"name":"Bessie"}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&CopyProgress{Read: 1, Written: 1}, got); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface([]string{`{"_id":"cow","name":"Bessie"}`}, written); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestCopyDatabaseFailure(t *testing.T) {
+	var written []string
+	got, err := Copy(context.Background(), copySource(), copyTarget(&written, "", "cow"))
+	if d := testy.DiffInterface(&CopyProgress{Read: 3, Written: 2, Failed: 1}, got); d != nil {
+		t.Error(d)
+	}
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("Expected a *BulkError, got %v", err)
+	}
+	if len(bulkErr.Errors) != 1 || bulkErr.Errors[0].Index != 1 || bulkErr.Errors[0].ID != "cow" {
+		t.Errorf("Unexpected errors: %v", bulkErr.Errors)
+	}
+	testy.StatusError(t, "kivik: 1 of 3 documents failed: document 1 (cow): forbidden", http.StatusForbidden, err)
+}
+
+func TestCopyDatabaseInvalidBatchSize(t *testing.T) {
+	_, err := Copy(context.Background(), copySource(), copySource(), WithBatchSize(0))
+	testy.StatusError(t, "kivik: invalid batch size 0: must be a positive integer", http.StatusBadRequest, err)
+}
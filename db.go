@@ -37,6 +37,17 @@ type DB struct {
 	closed int32
 	mu     sync.Mutex
 	wg     sync.WaitGroup
+	iters  iterators
+
+	validators []ValidateFunc
+	useNumber  bool
+	codec      Codec
+
+	// replicas caches the number of replicas of the database, for
+	// checkQuorum.
+	replicasMu    sync.Mutex
+	replicas      int
+	replicasKnown bool
 }
 
 func (db *DB) startQuery() error {
@@ -85,12 +96,24 @@ func (db *DB) AllDocs(ctx context.Context, options ...Options) ResultSet {
 	if err := db.startQuery(); err != nil {
 		return &errRS{err: err}
 	}
-	rowsi, err := db.driverDB.AllDocs(ctx, mergeOptions(options...))
+	var rowsi driver.Rows
+	opts := mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		db.endQuery()
+		return &errRS{err: err}
+	}
+	op := &Operation{Method: "AllDocs", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		rowsi, err = db.driverDB.AllDocs(ctx, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &errRS{err: err}
 	}
-	return newRows(ctx, db.endQuery, rowsi)
+	it := newRows(ctx, db.endQuery, rowsi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
 // DesignDocs returns a list of all documents in the database.
@@ -98,7 +121,7 @@ func (db *DB) DesignDocs(ctx context.Context, options ...Options) ResultSet {
 	if db.err != nil {
 		return &errRS{err: db.err}
 	}
-	ddocer, ok := db.driverDB.(driver.DesignDocer)
+	ddocer, ok := optional(db.driverDB, (*driver.DesignDocer)(nil)).(driver.DesignDocer)
 	if !ok {
 		return &errRS{err: &Error{Status: http.StatusNotImplemented, Err: errors.New("kivik: design doc view not supported by driver")}}
 	}
@@ -106,12 +129,24 @@ func (db *DB) DesignDocs(ctx context.Context, options ...Options) ResultSet {
 	if err := db.startQuery(); err != nil {
 		return &errRS{err: err}
 	}
-	rowsi, err := ddocer.DesignDocs(ctx, mergeOptions(options...))
+	var rowsi driver.Rows
+	opts := mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		db.endQuery()
+		return &errRS{err: err}
+	}
+	op := &Operation{Method: "DesignDocs", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		rowsi, err = ddocer.DesignDocs(ctx, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &errRS{err: err}
 	}
-	return newRows(ctx, db.endQuery, rowsi)
+	it := newRows(ctx, db.endQuery, rowsi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
 // LocalDocs returns a list of all documents in the database.
@@ -119,19 +154,31 @@ func (db *DB) LocalDocs(ctx context.Context, options ...Options) ResultSet {
 	if db.err != nil {
 		return &errRS{err: db.err}
 	}
-	ldocer, ok := db.driverDB.(driver.LocalDocer)
+	ldocer, ok := optional(db.driverDB, (*driver.LocalDocer)(nil)).(driver.LocalDocer)
 	if !ok {
 		return &errRS{err: &Error{Status: http.StatusNotImplemented, Err: errors.New("kivik: local doc view not supported by driver")}}
 	}
 	if err := db.startQuery(); err != nil {
 		return &errRS{err: err}
 	}
-	rowsi, err := ldocer.LocalDocs(ctx, mergeOptions(options...))
+	var rowsi driver.Rows
+	opts := mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		db.endQuery()
+		return &errRS{err: err}
+	}
+	op := &Operation{Method: "LocalDocs", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		rowsi, err = ldocer.LocalDocs(ctx, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &errRS{err: err}
 	}
-	return newRows(ctx, db.endQuery, rowsi)
+	it := newRows(ctx, db.endQuery, rowsi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
 // Query executes the specified view function from the specified design
@@ -154,32 +201,39 @@ func (db *DB) Query(ctx context.Context, ddoc, view string, options ...Options)
 	}
 	ddoc = strings.TrimPrefix(ddoc, "_design/")
 	view = strings.TrimPrefix(view, "_view/")
-	rowsi, err := db.driverDB.Query(ctx, ddoc, view, mergeOptions(options...))
+	var rowsi driver.Rows
+	opts := mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		db.endQuery()
+		return &errRS{err: err}
+	}
+	op := &Operation{Method: "Query", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		rowsi, err = db.driverDB.Query(ctx, ddoc, view, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &errRS{err: err}
 	}
-	return newRows(ctx, db.endQuery, rowsi)
+	it := newRows(ctx, db.endQuery, rowsi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
 // Get fetches the requested document. Any errors are deferred until the
 // [ResultSet.ScanDoc] call.
 func (db *DB) Get(ctx context.Context, docID string, options ...Options) ResultSet {
-	if db.err != nil {
-		return &errRS{err: db.err}
-	}
-	if err := db.startQuery(); err != nil {
-		return &errRS{err: err}
-	}
-	defer db.endQuery()
-	doc, err := db.driverDB.Get(ctx, docID, mergeOptions(options...))
+	doc, err := db.get(ctx, "Get", docID, mergeOptions(options...))
 	if err != nil {
 		return &errRS{err: err}
 	}
 	r := &row{
-		id:   docID,
-		rev:  doc.Rev,
-		body: doc.Body,
+		id:        docID,
+		rev:       doc.Rev,
+		body:      doc.Body,
+		useNumber: db.useNumber,
+		codec:     db.codec,
 	}
 	if doc.Attachments != nil {
 		r.atts = &AttachmentsIterator{atti: doc.Attachments}
@@ -187,6 +241,27 @@ func (db *DB) Get(ctx context.Context, docID string, options ...Options) ResultS
 	return r
 }
 
+// get fetches a document from the driver, on behalf of the method named
+// method.
+func (db *DB) get(ctx context.Context, method, docID string, opts Options) (*driver.Document, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if err := db.startQuery(); err != nil {
+		return nil, err
+	}
+	defer db.endQuery()
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return nil, err
+	}
+	var doc *driver.Document
+	err := db.client.invoke(ctx, &Operation{Method: method, DB: db.name, DocID: docID, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		doc, err = db.driverDB.Get(ctx, docID, opts)
+		return err
+	})
+	return doc, err
+}
+
 // GetRev returns the active rev of the specified document. GetRev accepts
 // the same options as [DB.Get].
 func (db *DB) GetRev(ctx context.Context, docID string, options ...Options) (rev string, err error) {
@@ -194,12 +269,19 @@ func (db *DB) GetRev(ctx context.Context, docID string, options ...Options) (rev
 		return "", db.err
 	}
 	opts := mergeOptions(options...)
-	if r, ok := db.driverDB.(driver.RevGetter); ok {
+	if r, ok := optional(db.driverDB, (*driver.RevGetter)(nil)).(driver.RevGetter); ok {
 		if err := db.startQuery(); err != nil {
 			return "", err
 		}
 		defer db.endQuery()
-		return r.GetRev(ctx, docID, opts)
+		if err := db.checkQuorum(ctx, opts); err != nil {
+			return "", err
+		}
+		err = db.client.invoke(ctx, &Operation{Method: "GetRev", DB: db.name, DocID: docID, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			rev, err = r.GetRev(ctx, docID, opts)
+			return err
+		})
+		return rev, err
 	}
 	row := db.Get(ctx, docID, opts)
 	var doc struct {
@@ -212,7 +294,8 @@ func (db *DB) GetRev(ctx context.Context, docID string, options ...Options) (rev
 }
 
 // CreateDoc creates a new doc with an auto-generated unique ID. The generated
-// docID and new rev are returned.
+// docID and new rev are returned. The ID is chosen by the server, unless the
+// client was created with [WithIDGenerator].
 func (db *DB) CreateDoc(ctx context.Context, doc interface{}, options ...Options) (docID, rev string, err error) {
 	if db.err != nil {
 		return "", "", db.err
@@ -221,7 +304,24 @@ func (db *DB) CreateDoc(ctx context.Context, doc interface{}, options ...Options
 		return "", "", err
 	}
 	defer db.endQuery()
-	return db.driverDB.CreateDoc(ctx, doc, mergeOptions(options...))
+	if doc, err = db.validate(doc); err != nil {
+		return "", "", err
+	}
+	if doc, err = db.assignID(doc); err != nil {
+		return "", "", err
+	}
+	if doc, err = db.encode("", doc); err != nil {
+		return "", "", err
+	}
+	opts := mergeOptions(options...)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return "", "", err
+	}
+	err = db.client.invoke(ctx, &Operation{Method: "CreateDoc", DB: db.name, Options: opts}, func(ctx context.Context) (err error) {
+		docID, rev, err = db.driverDB.CreateDoc(ctx, doc, opts)
+		return err
+	})
+	return docID, rev, err
 }
 
 // normalizeFromJSON unmarshals a []byte, json.RawMessage or io.Reader to a
@@ -298,7 +398,21 @@ func (db *DB) Put(ctx context.Context, docID string, doc interface{}, options ..
 	if err != nil {
 		return "", err
 	}
-	return db.driverDB.Put(ctx, docID, i, mergeOptions(options...))
+	if i, err = db.validate(i); err != nil {
+		return "", err
+	}
+	if i, err = db.encode(docID, i); err != nil {
+		return "", err
+	}
+	opts := mergeOptions(options...)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return "", err
+	}
+	err = db.client.invoke(ctx, &Operation{Method: "Put", DB: db.name, DocID: docID, Options: opts}, func(ctx context.Context) (err error) {
+		rev, err = db.driverDB.Put(ctx, docID, i, opts)
+		return err
+	})
+	return rev, err
 }
 
 // Delete marks the specified document as deleted. The revision may be provided
@@ -315,7 +429,14 @@ func (db *DB) Delete(ctx context.Context, docID, rev string, options ...Options)
 		return "", missingArg("docID")
 	}
 	opts := mergeOptions(Options{"rev": rev}, mergeOptions(options...))
-	return db.driverDB.Delete(ctx, docID, opts)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return "", err
+	}
+	err = db.client.invoke(ctx, &Operation{Method: "Delete", DB: db.name, DocID: docID, Options: opts}, func(ctx context.Context) (err error) {
+		newRev, err = db.driverDB.Delete(ctx, docID, opts)
+		return err
+	})
+	return newRev, err
 }
 
 // Flush requests a flush of disk cache to disk or other permanent storage.
@@ -329,8 +450,10 @@ func (db *DB) Flush(ctx context.Context) error {
 		return err
 	}
 	defer db.endQuery()
-	if flusher, ok := db.driverDB.(driver.Flusher); ok {
-		return flusher.Flush(ctx)
+	if flusher, ok := optional(db.driverDB, (*driver.Flusher)(nil)).(driver.Flusher); ok {
+		return db.client.invoke(ctx, &Operation{Method: "Flush", DB: db.name}, func(ctx context.Context) error {
+			return flusher.Flush(ctx)
+		})
 	}
 	return &Error{Status: http.StatusNotImplemented, Err: errors.New("kivik: flush not supported by driver")}
 }
@@ -386,7 +509,11 @@ func (db *DB) Stats(ctx context.Context) (*DBStats, error) {
 		return nil, err
 	}
 	defer db.endQuery()
-	i, err := db.driverDB.Stats(ctx)
+	var i *driver.DBStats
+	err := db.client.invoke(ctx, &Operation{Method: "Stats", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+		i, err = db.driverDB.Stats(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -430,7 +557,9 @@ func (db *DB) Compact(ctx context.Context) error {
 		return err
 	}
 	defer db.endQuery()
-	return db.driverDB.Compact(ctx)
+	return db.client.invoke(ctx, &Operation{Method: "Compact", DB: db.name}, func(ctx context.Context) error {
+		return db.driverDB.Compact(ctx)
+	})
 }
 
 // CompactView compats the view indexes associated with the specified design
@@ -450,7 +579,9 @@ func (db *DB) CompactView(ctx context.Context, ddocID string) error {
 		return err
 	}
 	defer db.endQuery()
-	return db.driverDB.CompactView(ctx, ddocID)
+	return db.client.invoke(ctx, &Operation{Method: "CompactView", DB: db.name}, func(ctx context.Context) error {
+		return db.driverDB.CompactView(ctx, ddocID)
+	})
 }
 
 // ViewCleanup removes view index files that are no longer required as a result
@@ -465,7 +596,9 @@ func (db *DB) ViewCleanup(ctx context.Context) error {
 		return err
 	}
 	defer db.endQuery()
-	return db.driverDB.ViewCleanup(ctx)
+	return db.client.invoke(ctx, &Operation{Method: "ViewCleanup", DB: db.name}, func(ctx context.Context) error {
+		return db.driverDB.ViewCleanup(ctx)
+	})
 }
 
 // Security returns the database's security document.
@@ -479,7 +612,11 @@ func (db *DB) Security(ctx context.Context) (*Security, error) {
 		return nil, err
 	}
 	defer db.endQuery()
-	s, err := db.driverDB.Security(ctx)
+	var s *driver.Security
+	err := db.client.invoke(ctx, &Operation{Method: "Security", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+		s, err = db.driverDB.Security(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -507,7 +644,9 @@ func (db *DB) SetSecurity(ctx context.Context, security *Security) error {
 		Admins:  driver.Members(security.Admins),
 		Members: driver.Members(security.Members),
 	}
-	return db.driverDB.SetSecurity(ctx, sec)
+	return db.client.invoke(ctx, &Operation{Method: "SetSecurity", DB: db.name}, func(ctx context.Context) error {
+		return db.driverDB.SetSecurity(ctx, sec)
+	})
 }
 
 // Copy copies the source document to a new document with an ID of targetID. If
@@ -527,12 +666,16 @@ func (db *DB) Copy(ctx context.Context, targetID, sourceID string, options ...Op
 		return "", missingArg("sourceID")
 	}
 	opts := mergeOptions(options...)
-	if copier, ok := db.driverDB.(driver.Copier); ok {
+	if copier, ok := optional(db.driverDB, (*driver.Copier)(nil)).(driver.Copier); ok {
 		if err := db.startQuery(); err != nil {
 			return "", err
 		}
 		defer db.endQuery()
-		return copier.Copy(ctx, targetID, sourceID, opts)
+		err = db.client.invoke(ctx, &Operation{Method: "Copy", DB: db.name, DocID: targetID, Options: opts}, func(ctx context.Context) (err error) {
+			targetRev, err = copier.Copy(ctx, targetID, sourceID, opts)
+			return err
+		})
+		return targetRev, err
 	}
 	var doc map[string]interface{}
 	if err = db.Get(ctx, sourceID, opts).ScanDoc(&doc); err != nil {
@@ -561,7 +704,15 @@ func (db *DB) PutAttachment(ctx context.Context, docID string, att *Attachment,
 	}
 	defer db.endQuery()
 	a := driver.Attachment(*att)
-	return db.driverDB.PutAttachment(ctx, docID, &a, mergeOptions(options...))
+	opts := mergeOptions(options...)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return "", err
+	}
+	err = db.client.invoke(ctx, &Operation{Method: "PutAttachment", DB: db.name, DocID: docID, Options: opts}, func(ctx context.Context) (err error) {
+		newRev, err = db.driverDB.PutAttachment(ctx, docID, &a, opts)
+		return err
+	})
+	return newRev, err
 }
 
 // GetAttachment returns a file attachment associated with the document.
@@ -579,7 +730,12 @@ func (db *DB) GetAttachment(ctx context.Context, docID, filename string, options
 	if filename == "" {
 		return nil, missingArg("filename")
 	}
-	att, err := db.driverDB.GetAttachment(ctx, docID, filename, mergeOptions(options...))
+	var att *driver.Attachment
+	opts := mergeOptions(options...)
+	err := db.client.invoke(ctx, &Operation{Method: "GetAttachment", DB: db.name, DocID: docID, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		att, err = db.driverDB.GetAttachment(ctx, docID, filename, opts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -609,12 +765,17 @@ func (db *DB) GetAttachmentMeta(ctx context.Context, docID, filename string, opt
 		return nil, missingArg("filename")
 	}
 	var att *Attachment
-	if metaer, ok := db.driverDB.(driver.AttachmentMetaGetter); ok {
+	if metaer, ok := optional(db.driverDB, (*driver.AttachmentMetaGetter)(nil)).(driver.AttachmentMetaGetter); ok {
 		if err := db.startQuery(); err != nil {
 			return nil, err
 		}
 		defer db.endQuery()
-		a, err := metaer.GetAttachmentMeta(ctx, docID, filename, mergeOptions(options...))
+		var a *driver.Attachment
+		opts := mergeOptions(options...)
+		err := db.client.invoke(ctx, &Operation{Method: "GetAttachmentMeta", DB: db.name, DocID: docID, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			a, err = metaer.GetAttachmentMeta(ctx, docID, filename, opts)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -652,7 +813,14 @@ func (db *DB) DeleteAttachment(ctx context.Context, docID, rev, filename string,
 		return "", missingArg("filename")
 	}
 	opts := mergeOptions(Options{"rev": rev}, mergeOptions(options...))
-	return db.driverDB.DeleteAttachment(ctx, docID, filename, opts)
+	if err := db.checkQuorum(ctx, opts); err != nil {
+		return "", err
+	}
+	err = db.client.invoke(ctx, &Operation{Method: "DeleteAttachment", DB: db.name, DocID: docID, Options: opts}, func(ctx context.Context) (err error) {
+		newRev, err = db.driverDB.DeleteAttachment(ctx, docID, filename, opts)
+		return err
+	})
+	return newRev, err
 }
 
 // PurgeResult is the result of a purge request.
@@ -681,8 +849,12 @@ func (db *DB) Purge(ctx context.Context, docRevMap map[string][]string) (*PurgeR
 		return nil, err
 	}
 	defer db.endQuery()
-	if purger, ok := db.driverDB.(driver.Purger); ok {
-		res, err := purger.Purge(ctx, docRevMap)
+	if purger, ok := optional(db.driverDB, (*driver.Purger)(nil)).(driver.Purger); ok {
+		var res *driver.PurgeResult
+		err := db.client.invoke(ctx, &Operation{Method: "Purge", DB: db.name}, func(ctx context.Context) (err error) {
+			res, err = purger.Purge(ctx, docRevMap)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -708,7 +880,7 @@ func (db *DB) BulkGet(ctx context.Context, docs []BulkGetReference, options ...O
 	if db.err != nil {
 		return &errRS{err: db.err}
 	}
-	bulkGetter, ok := db.driverDB.(driver.BulkGetter)
+	bulkGetter, ok := optional(db.driverDB, (*driver.BulkGetter)(nil)).(driver.BulkGetter)
 	if !ok {
 		return &errRS{err: &Error{Status: http.StatusNotImplemented, Message: "kivik: bulk get not supported by driver"}}
 	}
@@ -720,16 +892,26 @@ func (db *DB) BulkGet(ctx context.Context, docs []BulkGetReference, options ...O
 	for i, ref := range docs {
 		refs[i] = driver.BulkGetReference(ref)
 	}
-	rowsi, err := bulkGetter.BulkGet(ctx, refs, mergeOptions(options...))
+	var rowsi driver.Rows
+	opts := mergeOptions(options...)
+	op := &Operation{Method: "BulkGet", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+	err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+		rowsi, err = bulkGetter.BulkGet(ctx, refs, opts)
+		return err
+	})
 	if err != nil {
 		db.endQuery()
 		return &errRS{err: err}
 	}
-	return newRows(ctx, db.endQuery, rowsi)
+	it := newRows(ctx, db.endQuery, rowsi)
+	db.trackIterator(op, it.iter)
+	return it
 }
 
-// Close cleans up any resources used by the DB. The default CouchDB driver
-// does not use this, the default PouchDB driver does.
+// Close cleans up any resources used by the DB. Any open iterators returned by
+// the DB are closed, and their Err method will return ErrDatabaseClosed. If the
+// driver DB implements [driver.DBCloser], its Close method is then called. The
+// default CouchDB driver does not use this, the default PouchDB driver does.
 func (db *DB) Close() error {
 	if db.err != nil {
 		return db.err
@@ -737,8 +919,9 @@ func (db *DB) Close() error {
 	db.mu.Lock()
 	atomic.StoreInt32(&db.closed, 1)
 	db.mu.Unlock()
+	db.iters.closeAll(ErrDatabaseClosed)
 	db.wg.Wait()
-	if closer, ok := db.driverDB.(driver.DBCloser); ok {
+	if closer, ok := optional(db.driverDB, (*driver.DBCloser)(nil)).(driver.DBCloser); ok {
 		return closer.Close()
 	}
 	return nil
@@ -775,16 +958,23 @@ func (db *DB) RevsDiff(ctx context.Context, revMap interface{}) ResultSet {
 	if db.err != nil {
 		return &errRS{err: db.err}
 	}
-	if rd, ok := db.driverDB.(driver.RevsDiffer); ok {
+	if rd, ok := optional(db.driverDB, (*driver.RevsDiffer)(nil)).(driver.RevsDiffer); ok {
 		if err := db.startQuery(); err != nil {
 			return &errRS{err: err}
 		}
-		rowsi, err := rd.RevsDiff(ctx, revMap)
+		var rowsi driver.Rows
+		op := &Operation{Method: "RevsDiff", DB: db.name, ReadOnly: true, iterator: true}
+		err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+			rowsi, err = rd.RevsDiff(ctx, revMap)
+			return err
+		})
 		if err != nil {
 			db.endQuery()
 			return &errRS{err: err}
 		}
-		return newRows(ctx, db.endQuery, rowsi)
+		it := newRows(ctx, db.endQuery, rowsi)
+		db.trackIterator(op, it.iter)
+		return it
 	}
 	return &errRS{err: &Error{Status: http.StatusNotImplemented, Message: "kivik: _revs_diff not supported by driver"}}
 }
@@ -811,8 +1001,12 @@ func (db *DB) PartitionStats(ctx context.Context, name string) (*PartitionStats,
 		return nil, err
 	}
 	defer db.endQuery()
-	if pdb, ok := db.driverDB.(driver.PartitionedDB); ok {
-		stats, err := pdb.PartitionStats(ctx, name)
+	if pdb, ok := optional(db.driverDB, (*driver.PartitionedDB)(nil)).(driver.PartitionedDB); ok {
+		var stats *driver.PartitionStats
+		err := db.client.invoke(ctx, &Operation{Method: "PartitionStats", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+			stats, err = pdb.PartitionStats(ctx, name)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
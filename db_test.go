@@ -2772,6 +2772,47 @@ func TestDBClose(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("closes open iterators", func(t *testing.T) {
+		t.Parallel()
+
+		client := &Client{}
+		db := &DB{
+			client: client,
+			driverDB: &mock.DB{
+				ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+					return &mock.Changes{
+						NextFunc: func(*driver.Change) error { return nil },
+					}, nil
+				},
+			},
+		}
+		changes := db.Changes(context.Background())
+		if !changes.Next() {
+			t.Fatalf("Next() returned false: %v", changes.Err())
+		}
+
+		done := make(chan struct{})
+		go func() {
+			_ = db.Close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("db.Close() blocked on an open iterator")
+		}
+
+		if changes.Next() {
+			t.Error("Next() should return false after the database is closed")
+		}
+		if err := changes.Err(); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(client.iters.open) != 0 {
+			t.Errorf("%d iterators still registered with the client", len(client.iters.open))
+		}
+	})
 }
 
 func TestRevsDiff(t *testing.T) {
@@ -0,0 +1,214 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// DebugRecord is a sanitized record of a single operation, captured by a
+// [Debugger].
+type DebugRecord struct {
+	// Time is the time at which the operation started.
+	Time time.Time `json:"time"`
+	// Duration is the time taken by the operation, including any retries.
+	Duration time.Duration `json:"duration"`
+	// Method, DB and DocID are copied from the [Operation].
+	Method string `json:"method"`
+	DB     string `json:"db,omitempty"`
+	DocID  string `json:"docid,omitempty"`
+	// Options are the options passed to the driver, with the values of
+	// options which appear to hold credentials redacted, and long values
+	// truncated.
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Status is the HTTP status of the response, if the driver reported one
+	// with [driver.RecordResponse], or else the status of the error, if any.
+	Status int `json:"status,omitempty"`
+	// Error is the text of the error returned by the operation, if any.
+	Error string `json:"error,omitempty"`
+	// Header holds the response headers reported by the driver, if any, less
+	// those which carry credentials, such as Set-Cookie.
+	Header http.Header `json:"header,omitempty"`
+}
+
+// Debugger captures sanitized records of the operations performed by a
+// [Client], for diagnosing problems between a driver and the server. See
+// [WithDebugger].
+//
+// Only the information available to Kivik is captured: the operation, its
+// options, its outcome and, for drivers which report it, the response
+// status and headers. Request and response bodies are not captured.
+type Debugger struct {
+	mu      sync.Mutex
+	records []DebugRecord
+	next    int
+	full    bool
+	w       io.Writer
+}
+
+// NewDebugger returns a Debugger which retains the records of the last size
+// operations, and, if w is not nil, writes each record to w as a line of
+// JSON. Errors writing to w are ignored.
+func NewDebugger(size int, w io.Writer) *Debugger {
+	if size < 0 {
+		size = 0
+	}
+	return &Debugger{
+		records: make([]DebugRecord, size),
+		w:       w,
+	}
+}
+
+// Records returns the retained records, oldest first.
+func (d *Debugger) Records() []DebugRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.full {
+		return append([]DebugRecord(nil), d.records[:d.next]...)
+	}
+	records := make([]DebugRecord, 0, len(d.records))
+	records = append(records, d.records[d.next:]...)
+	return append(records, d.records[:d.next]...)
+}
+
+func (d *Debugger) add(rec DebugRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.records) > 0 {
+		d.records[d.next] = rec
+		d.next++
+		if d.next == len(d.records) {
+			d.next = 0
+			d.full = true
+		}
+	}
+	if d.w != nil {
+		_ = json.NewEncoder(d.w).Encode(rec)
+	}
+}
+
+// optionDebugger is the option key used to pass a [Debugger] to [New].
+const optionDebugger = "kivik:debugger"
+
+// WithDebugger returns an option which, when passed to [New], causes the
+// client to record each operation with d.
+func WithDebugger(d *Debugger) Options {
+	return Options{optionDebugger: d}
+}
+
+// debugMiddleware returns middleware which records each operation with d.
+func debugMiddleware(d *Debugger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			ctx = driver.WithResponseRecorder(ctx)
+			recorder := driver.ResponseRecorderFrom(ctx)
+			prev := recorder.Last()
+			start := time.Now()
+			err := next(ctx, op)
+			rec := DebugRecord{
+				Time:     start,
+				Duration: time.Since(start),
+				Method:   op.Method,
+				DB:       op.DB,
+				DocID:    op.DocID,
+				Options:  sanitizeOptions(op.Options),
+			}
+			if md := recorder.Last(); md != nil && md != prev {
+				rec.Status = md.Status
+				rec.Header = sanitizeHeader(md.Header)
+			}
+			if err != nil {
+				if rec.Status == 0 {
+					rec.Status = HTTPStatus(err)
+				}
+				rec.Error = err.Error()
+			}
+			d.add(rec)
+			return err
+		}
+	}
+}
+
+// maxDebugValue is the length beyond which option values are truncated in
+// debug records.
+const maxDebugValue = 1024
+
+// sensitiveOptions are the substrings of option names whose values are
+// redacted in debug records.
+var sensitiveOptions = []string{"password", "passwd", "token", "secret", "auth", "cookie", "credential"}
+
+func sanitizeOptions(opts Options) map[string]interface{} {
+	if len(opts) == 0 {
+		return nil
+	}
+	sanitized := make(map[string]interface{}, len(opts))
+	for k, v := range opts {
+		sanitized[k] = sanitizeOption(k, v)
+	}
+	return sanitized
+}
+
+func sanitizeOption(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, s := range sensitiveOptions {
+		if strings.Contains(lower, s) {
+			return redacted
+		}
+	}
+	var str string
+	switch t := value.(type) {
+	case nil, bool, int, int64, float64:
+		return value
+	case string:
+		str = t
+		if strings.Contains(t, "://") {
+			str = RedactDSN(t)
+		}
+	default:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%T", value)
+		}
+		if len(raw) <= maxDebugValue {
+			return json.RawMessage(raw)
+		}
+		str = string(raw)
+	}
+	if len(str) > maxDebugValue {
+		return str[:maxDebugValue] + "...(truncated)"
+	}
+	return str
+}
+
+// sensitiveHeaders are the response headers omitted from debug records.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func sanitizeHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	header = header.Clone()
+	for _, h := range sensitiveHeaders {
+		header.Del(h)
+	}
+	return header
+}
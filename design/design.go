@@ -0,0 +1,279 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package design provides types for building CouchDB design documents, for
+// use with [github.com/go-kivik/kivik/v4.DB.PutDesignDoc] and
+// [github.com/go-kivik/kivik/v4.DB.GetDesignDoc].
+//
+//	ddoc := design.New("animals").
+//	    AddView("by_type", design.View{
+//	        Map:    `function(doc) { emit(doc.type, 1) }`,
+//	        Reduce: design.ReduceCount,
+//	    }).
+//	    AddFilter("cows", `function(doc, req) { return doc.type === "cow" }`)
+//	rev, err := db.PutDesignDoc(ctx, ddoc)
+//
+// To deploy the design documents of an application at startup, pass them to
+// [github.com/go-kivik/kivik/v4.DB.SyncDesignDocs], which stores only those
+// which have changed, and optionally to
+// [github.com/go-kivik/kivik/v4.DB.WarmViews] to rebuild their indexes.
+package design
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Prefix is the prefix of the IDs of all design documents.
+const Prefix = "_design/"
+
+// Built-in reduce functions, which are executed natively by the server.
+const (
+	ReduceCount               = "_count"
+	ReduceSum                 = "_sum"
+	ReduceStats               = "_stats"
+	ReduceApproxCountDistinct = "_approx_count_distinct"
+)
+
+// LanguageJavaScript is the default language of design document functions.
+const LanguageJavaScript = "javascript"
+
+// View is a map/reduce view.
+type View struct {
+	// Map is the source of the map function.
+	Map string `json:"map"`
+	// Reduce is the source of the reduce function, or the name of one of the
+	// built-in reduce functions, such as [ReduceCount]. It is optional.
+	Reduce string `json:"reduce,omitempty"`
+}
+
+// Options are the options of a design document.
+type Options struct {
+	// Partitioned, if set, overrides whether the views of the design document
+	// are partitioned, in a partitioned database.
+	Partitioned *bool `json:"partitioned,omitempty"`
+	// LocalSeq includes local sequence numbers in view results.
+	LocalSeq bool `json:"local_seq,omitempty"`
+	// IncludeDesign includes design documents in view results.
+	IncludeDesign bool `json:"include_design,omitempty"`
+}
+
+// DesignDoc is a design document.
+type DesignDoc struct {
+	// Name is the name of the design document, without the "_design/"
+	// prefix.
+	Name string
+	// Rev is the current revision of the design document, if it exists.
+	Rev string
+	// Language is the language of the functions. The server defaults to
+	// [LanguageJavaScript].
+	Language string
+	Views    map[string]View
+	// Filters are the sources of changes feed filter functions, by name.
+	Filters map[string]string
+	// Updates are the sources of update functions, by name.
+	Updates map[string]string
+	// ValidateDocUpdate is the source of the validation function.
+	ValidateDocUpdate string
+	Options           *Options
+	// AutoUpdate, if set to false, prevents the server from automatically
+	// rebuilding the indexes of the design document.
+	AutoUpdate *bool
+}
+
+// New returns a new, empty design document with the given name. A
+// "_design/" prefix is removed from name.
+func New(name string) *DesignDoc {
+	return &DesignDoc{Name: strings.TrimPrefix(name, Prefix)}
+}
+
+// ID returns the document ID of the design document.
+func (d *DesignDoc) ID() string {
+	return Prefix + d.Name
+}
+
+// AddView adds, or replaces, the view name, and returns d.
+func (d *DesignDoc) AddView(name string, view View) *DesignDoc {
+	if d.Views == nil {
+		d.Views = map[string]View{}
+	}
+	d.Views[name] = view
+	return d
+}
+
+// AddFilter adds, or replaces, the filter function name, and returns d.
+func (d *DesignDoc) AddFilter(name, fn string) *DesignDoc {
+	if d.Filters == nil {
+		d.Filters = map[string]string{}
+	}
+	d.Filters[name] = fn
+	return d
+}
+
+// AddUpdate adds, or replaces, the update function name, and returns d.
+func (d *DesignDoc) AddUpdate(name, fn string) *DesignDoc {
+	if d.Updates == nil {
+		d.Updates = map[string]string{}
+	}
+	d.Updates[name] = fn
+	return d
+}
+
+// SetValidateDocUpdate sets the validation function, and returns d.
+func (d *DesignDoc) SetValidateDocUpdate(fn string) *DesignDoc {
+	d.ValidateDocUpdate = fn
+	return d
+}
+
+var builtinReduces = map[string]bool{
+	ReduceCount:               true,
+	ReduceSum:                 true,
+	ReduceStats:               true,
+	ReduceApproxCountDistinct: true,
+}
+
+// Validate checks d for mistakes which the server would otherwise only report
+// when the design document is used, such as views without a map function, or
+// misspelled built-in reduce functions.
+func (d *DesignDoc) Validate() error {
+	if d.Name == "" {
+		return errors.New("design document name required")
+	}
+	names := make([]string, 0, len(d.Views))
+	for name := range d.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		view := d.Views[name]
+		if name == "" {
+			return errors.New("view name required")
+		}
+		if strings.TrimSpace(view.Map) == "" {
+			return fmt.Errorf("view %q: map function required", name)
+		}
+		if strings.HasPrefix(view.Reduce, "_") && !builtinReduces[view.Reduce] {
+			return fmt.Errorf("view %q: unknown built-in reduce function %q", name, view.Reduce)
+		}
+	}
+	for kind, fns := range map[string]map[string]string{"filter": d.Filters, "update": d.Updates} {
+		for name, fn := range fns {
+			if strings.TrimSpace(fn) == "" {
+				return fmt.Errorf("%s %q: function required", kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// designDoc is the JSON representation of a DesignDoc.
+type designDoc struct {
+	ID                string            `json:"_id"`
+	Rev               string            `json:"_rev,omitempty"`
+	Language          string            `json:"language,omitempty"`
+	Views             map[string]View   `json:"views,omitempty"`
+	Filters           map[string]string `json:"filters,omitempty"`
+	Updates           map[string]string `json:"updates,omitempty"`
+	ValidateDocUpdate string            `json:"validate_doc_update,omitempty"`
+	Options           *Options          `json:"options,omitempty"`
+	AutoUpdate        *bool             `json:"autoupdate,omitempty"`
+}
+
+// MarshalJSON satisfies the [encoding/json.Marshaler] interface.
+func (d *DesignDoc) MarshalJSON() ([]byte, error) {
+	return json.Marshal(designDoc{
+		ID:                d.ID(),
+		Rev:               d.Rev,
+		Language:          d.Language,
+		Views:             d.Views,
+		Filters:           d.Filters,
+		Updates:           d.Updates,
+		ValidateDocUpdate: d.ValidateDocUpdate,
+		Options:           d.Options,
+		AutoUpdate:        d.AutoUpdate,
+	})
+}
+
+// UnmarshalJSON satisfies the [encoding/json.Unmarshaler] interface. Fields
+// not represented by DesignDoc, such as shows and lists, are ignored.
+func (d *DesignDoc) UnmarshalJSON(data []byte) error {
+	var doc designDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*d = DesignDoc{
+		Name:              strings.TrimPrefix(doc.ID, Prefix),
+		Rev:               doc.Rev,
+		Language:          doc.Language,
+		Views:             doc.Views,
+		Filters:           doc.Filters,
+		Updates:           doc.Updates,
+		ValidateDocUpdate: doc.ValidateDocUpdate,
+		Options:           doc.Options,
+		AutoUpdate:        doc.AutoUpdate,
+	}
+	return nil
+}
+
+// Equal reports whether d and other define the same design document, ignoring
+// their revisions.
+func (d *DesignDoc) Equal(other *DesignDoc) bool {
+	if d == nil || other == nil {
+		return d == other
+	}
+	a, b := *d, *other
+	a.Rev, b.Rev = "", ""
+	aJSON, err := json.Marshal(&a)
+	if err != nil {
+		return false
+	}
+	bJSON, err := json.Marshal(&b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aJSON, bJSON)
+}
+
+// Merge returns the JSON representation of d, with the fields of the JSON
+// document deployed which are not represented by DesignDoc, such as shows,
+// lists and rewrites, added to it. It is used to update a deployed design
+// document without discarding the functions it defines outside of Go.
+func (d *DesignDoc) Merge(deployed []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(deployed, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]json.RawMessage{}
+	}
+	t := reflect.TypeOf(designDoc{})
+	for i := 0; i < t.NumField(); i++ {
+		delete(doc, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	body, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package design
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestDesignDocJSON(t *testing.T) {
+	no := false
+	ddoc := New("_design/animals").
+		AddView("by_type", View{Map: "function(doc) { emit(doc.type) }", Reduce: ReduceCount}).
+		AddFilter("cows", "function(doc) { return doc.type === 'cow' }").
+		AddUpdate("touch", "function(doc) { return [doc, 'ok'] }").
+		SetValidateDocUpdate("function() {}")
+	ddoc.Rev = "1-xxx"
+	ddoc.AutoUpdate = &no
+	ddoc.Options = &Options{LocalSeq: true}
+
+	got, err := json.Marshal(ddoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+		"_id": "_design/animals",
+		"_rev": "1-xxx",
+		"views": {"by_type": {"map": "function(doc) { emit(doc.type) }", "reduce": "_count"}},
+		"filters": {"cows": "function(doc) { return doc.type === 'cow' }"},
+		"updates": {"touch": "function(doc) { return [doc, 'ok'] }"},
+		"validate_doc_update": "function() {}",
+		"options": {"local_seq": true},
+		"autoupdate": false
+	}`
+	if d := testy.DiffAsJSON([]byte(want), got); d != nil {
+		t.Error(d)
+	}
+
+	var decoded DesignDoc
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(ddoc, &decoded); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestDesignDocValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		ddoc *DesignDoc
+		err  string
+	}{
+		{
+			name: "valid",
+			ddoc: New("foo").AddView("bar", View{Map: "function(doc) {}", Reduce: "function(keys, values) {}"}),
+		},
+		{
+			name: "no name",
+			ddoc: New(""),
+			err:  "design document name required",
+		},
+		{
+			name: "no map",
+			ddoc: New("foo").AddView("bar", View{Reduce: ReduceSum}),
+			err:  `view "bar": map function required`,
+		},
+		{
+			name: "misspelled reduce",
+			ddoc: New("foo").AddView("bar", View{Map: "function(doc) {}", Reduce: "_cuont"}),
+			err:  `view "bar": unknown built-in reduce function "_cuont"`,
+		},
+		{
+			name: "empty filter",
+			ddoc: New("foo").AddFilter("bar", " "),
+			err:  `filter "bar": function required`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ddoc.Validate()
+			testy.Error(t, tt.err, err)
+		})
+	}
+}
+
+func TestDesignDocEqual(t *testing.T) {
+	a := New("foo").AddView("bar", View{Map: "function(doc) {}"})
+	b := New("foo").AddView("bar", View{Map: "function(doc) {}"})
+	b.Rev = "2-xxx"
+	if !a.Equal(b) {
+		t.Error("design documents differing only by rev should be equal")
+	}
+	b.AddFilter("baz", "function() {}")
+	if a.Equal(b) {
+		t.Error("design documents with different filters should differ")
+	}
+	if a.Equal(nil) {
+		t.Error("design document should not equal nil")
+	}
+}
+
+func TestDesignDocMerge(t *testing.T) {
+	deployed := `{"_id":"_design/foo","_rev":"1-xxx","language":"javascript","views":{"old":{"map":"function(doc) {}"}},"filters":{"f":"function() {}"},"shows":{"s":"function(doc, req) {}"},"rewrites":[{"from":"/a","to":"/b"}]}`
+	ddoc := New("foo").AddView("bar", View{Map: "function(doc) { emit(doc._id) }"})
+	ddoc.Rev = "1-xxx"
+	got, err := ddoc.Merge([]byte(deployed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_id":"_design/foo","_rev":"1-xxx","views":{"bar":{"map":"function(doc) { emit(doc._id) }"}},"shows":{"s":"function(doc, req) {}"},"rewrites":[{"from":"/a","to":"/b"}]}`
+	if d := testy.DiffAsJSON([]byte(want), got); d != nil {
+		t.Error(d)
+	}
+	if _, err := ddoc.Merge([]byte("invalid")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/design"
+)
+
+// PutDesignDoc validates and stores the design document ddoc, and returns its
+// new revision, which is also stored in ddoc.Rev. A design document which
+// fails [design.DesignDoc.Validate] results in a 400 Bad Request error, and is
+// not sent to the server.
+func (db *DB) PutDesignDoc(ctx context.Context, ddoc *design.DesignDoc, options ...Options) (string, error) {
+	if ddoc == nil {
+		return "", missingArg("ddoc")
+	}
+	if err := ddoc.Validate(); err != nil {
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: invalid design document", Err: err}
+	}
+	rev, err := db.Put(ctx, ddoc.ID(), ddoc, options...)
+	if err != nil {
+		return "", err
+	}
+	ddoc.Rev = rev
+	return rev, nil
+}
+
+// GetDesignDoc fetches the design document with the given name, which may
+// include the "_design/" prefix.
+func (db *DB) GetDesignDoc(ctx context.Context, name string, options ...Options) (*design.DesignDoc, error) {
+	name = strings.TrimPrefix(name, design.Prefix)
+	if name == "" {
+		return nil, missingArg("name")
+	}
+	ddoc := &design.DesignDoc{}
+	if err := db.Get(ctx, design.Prefix+name, options...).ScanDoc(ddoc); err != nil {
+		return nil, err
+	}
+	return ddoc, nil
+}
+
+// DesignSyncResult reports the outcome of [DB.SyncDesignDocs], by design
+// document name.
+type DesignSyncResult struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	// Revs is the current revision of each design document synced.
+	Revs map[string]string
+}
+
+// SyncDesignDocs brings the design documents of db up to date with ddocs, as
+// at application startup. Each design document is compared with the deployed
+// version, with [design.DesignDoc.Equal], and stored only if it is missing or
+// differs, so that unchanged indexes are not rebuilt. ddocs are not modified;
+// the current revision of each is reported in the result.
+//
+// All of ddocs are validated before any is stored. Design documents are
+// processed in the order given, and the first error stops the sync; the
+// result then reports the design documents processed so far. Fields of the
+// deployed design documents not represented by [design.DesignDoc], such as
+// show and list functions and rewrites, are preserved when a design document
+// is updated.
+//
+// Indexes of updated design documents are rebuilt lazily by the server. Call
+// [DB.WarmViews] to rebuild them immediately.
+func (db *DB) SyncDesignDocs(ctx context.Context, ddocs ...*design.DesignDoc) (*DesignSyncResult, error) {
+	for _, ddoc := range ddocs {
+		if ddoc == nil {
+			return nil, missingArg("ddoc")
+		}
+		if err := ddoc.Validate(); err != nil {
+			return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: invalid design document", Err: err}
+		}
+	}
+	result := &DesignSyncResult{Revs: make(map[string]string, len(ddocs))}
+	for _, ddoc := range ddocs {
+		c := *ddoc
+		var raw json.RawMessage
+		err := db.Get(ctx, c.ID()).ScanDoc(&raw)
+		if HTTPStatus(err) == http.StatusNotFound {
+			c.Rev = ""
+			if _, err := db.PutDesignDoc(ctx, &c); err != nil {
+				return result, err
+			}
+			result.Created = append(result.Created, c.Name)
+			result.Revs[c.Name] = c.Rev
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		deployed := &design.DesignDoc{}
+		if err := json.Unmarshal(raw, deployed); err != nil {
+			return result, &Error{Status: http.StatusBadGateway, Err: err}
+		}
+		c.Rev = deployed.Rev
+		if c.Equal(deployed) {
+			result.Unchanged = append(result.Unchanged, c.Name)
+			result.Revs[c.Name] = c.Rev
+			continue
+		}
+		merged, err := c.Merge(raw)
+		if err != nil {
+			return result, &Error{Status: http.StatusBadGateway, Err: err}
+		}
+		rev, err := db.Put(ctx, c.ID(), json.RawMessage(merged))
+		if err != nil {
+			return result, err
+		}
+		result.Updated = append(result.Updated, c.Name)
+		result.Revs[c.Name] = rev
+	}
+	return result, nil
+}
+
+// WarmViews builds the view indexes of ddocs, by querying one view of each
+// design document and discarding the result. As all views of a design
+// document share an index, this builds all of them. It blocks until the
+// indexes are built, or ctx is cancelled.
+func (db *DB) WarmViews(ctx context.Context, ddocs ...*design.DesignDoc) error {
+	for _, ddoc := range ddocs {
+		if ddoc == nil || len(ddoc.Views) == 0 {
+			continue
+		}
+		views := make([]string, 0, len(ddoc.Views))
+		for name := range ddoc.Views {
+			views = append(views, name)
+		}
+		sort.Strings(views)
+		opts := Options{"limit": 0}
+		if ddoc.Views[views[0]].Reduce != "" {
+			opts["reduce"] = false
+		}
+		rows := db.Query(ctx, ddoc.ID(), views[0], opts)
+		for rows.Next() { //nolint:revive // intentional empty block
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/design"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestPutDesignDoc(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				PutFunc: func(_ context.Context, docID string, doc interface{}, _ map[string]interface{}) (string, error) {
+					if docID != "_design/foo" {
+						t.Errorf("Unexpected doc ID: %s", docID)
+					}
+					body, err := json.Marshal(doc)
+					if err != nil {
+						return "", err
+					}
+					want := `{"_id":"_design/foo","views":{"bar":{"map":"function(doc) {}"}}}`
+					if d := testy.DiffAsJSON([]byte(want), body); d != nil {
+						t.Error(d)
+					}
+					return "1-xxx", nil
+				},
+			},
+		}
+		ddoc := design.New("foo").AddView("bar", design.View{Map: "function(doc) {}"})
+		rev, err := db.PutDesignDoc(context.Background(), ddoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-xxx" || ddoc.Rev != rev {
+			t.Errorf("Unexpected rev: %s, %s", rev, ddoc.Rev)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.DB{}}
+		ddoc := design.New("foo").AddView("bar", design.View{})
+		_, err := db.PutDesignDoc(context.Background(), ddoc)
+		testy.StatusError(t, `kivik: invalid design document: view "bar": map function required`, http.StatusBadRequest, err)
+	})
+	t.Run("nil", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.DB{}}
+		_, err := db.PutDesignDoc(context.Background(), nil)
+		testy.StatusError(t, "kivik: ddoc required", http.StatusBadRequest, err)
+	})
+}
+
+func TestGetDesignDoc(t *testing.T) {
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+				if docID != "_design/foo" {
+					return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+				}
+				return &driver.Document{
+					Rev:  "1-xxx",
+					Body: io.NopCloser(strings.NewReader(`{"_id":"_design/foo","_rev":"1-xxx","views":{"bar":{"map":"function(doc) {}","reduce":"_sum"}}}`)),
+				}, nil
+			},
+		},
+	}
+	t.Run("success", func(t *testing.T) {
+		ddoc, err := db.GetDesignDoc(context.Background(), "_design/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := design.New("foo").AddView("bar", design.View{Map: "function(doc) {}", Reduce: design.ReduceSum})
+		want.Rev = "1-xxx"
+		if d := testy.DiffInterface(want, ddoc); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		_, err := db.GetDesignDoc(context.Background(), "bar")
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+	t.Run("no name", func(t *testing.T) {
+		_, err := db.GetDesignDoc(context.Background(), "_design/")
+		testy.StatusError(t, "kivik: name required", http.StatusBadRequest, err)
+	})
+}
+
+// ddocStore returns a mock DB which stores documents in memory, with
+// sequential revisions.
+func ddocStore(docs map[string]string, puts *[]string) *mock.DB {
+	return &mock.DB{
+		GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+			body, ok := docs[docID]
+			if !ok {
+				return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+			}
+			return &driver.Document{Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+		PutFunc: func(_ context.Context, docID string, doc interface{}, _ map[string]interface{}) (string, error) {
+			var stored map[string]interface{}
+			body, _ := json.Marshal(doc)
+			_ = json.Unmarshal(body, &stored)
+			rev := "1-xxx"
+			if stored["_rev"] != nil {
+				rev = "2-xxx"
+			}
+			stored["_rev"] = rev
+			body, _ = json.Marshal(stored)
+			docs[docID] = string(body)
+			*puts = append(*puts, docID)
+			return rev, nil
+		},
+	}
+}
+
+func TestSyncDesignDocs(t *testing.T) {
+	docs := map[string]string{
+		"_design/same":    `{"_id":"_design/same","_rev":"1-aaa","views":{"a":{"map":"function(doc) {}"}}}`,
+		"_design/changed": `{"_id":"_design/changed","_rev":"1-bbb","views":{"a":{"map":"function(doc) {}"}},"shows":{"s":"function(doc, req) {}"}}`,
+	}
+	var puts []string
+	db := &DB{client: &Client{}, driverDB: ddocStore(docs, &puts)}
+	same := design.New("same").AddView("a", design.View{Map: "function(doc) {}"})
+	changed := design.New("changed").AddView("a", design.View{Map: "function(doc) { emit(doc._id) }"})
+	created := design.New("created").AddFilter("f", "function() { return true }")
+
+	result, err := db.SyncDesignDocs(context.Background(), same, changed, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DesignSyncResult{
+		Created:   []string{"created"},
+		Updated:   []string{"changed"},
+		Unchanged: []string{"same"},
+		Revs:      map[string]string{"same": "1-aaa", "changed": "2-xxx", "created": "1-xxx"},
+	}
+	if d := testy.DiffInterface(want, result); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface([]string{"_design/changed", "_design/created"}, puts); d != nil {
+		t.Error(d)
+	}
+	if same.Rev != "" || changed.Rev != "" || created.Rev != "" {
+		t.Errorf("ddocs should not be modified, got revs: %s, %s, %s", same.Rev, changed.Rev, created.Rev)
+	}
+	wantChanged := `{"_id":"_design/changed","_rev":"2-xxx","views":{"a":{"map":"function(doc) { emit(doc._id) }"}},"shows":{"s":"function(doc, req) {}"}}`
+	if d := testy.DiffAsJSON([]byte(wantChanged), []byte(docs["_design/changed"])); d != nil {
+		t.Errorf("Unknown fields should be preserved:\n%s", d)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		puts = nil
+		_, err := db.SyncDesignDocs(context.Background(), design.New("new"), design.New(""))
+		testy.StatusError(t, "kivik: invalid design document: design document name required", http.StatusBadRequest, err)
+		if len(puts) != 0 {
+			t.Errorf("Nothing should be stored, got %v", puts)
+		}
+	})
+}
+
+func TestWarmViews(t *testing.T) {
+	type query struct {
+		DDoc, View string
+		Options    map[string]interface{}
+	}
+	var queries []query
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			QueryFunc: func(_ context.Context, ddoc, view string, opts map[string]interface{}) (driver.Rows, error) {
+				queries = append(queries, query{DDoc: ddoc, View: view, Options: opts})
+				return &mock.Rows{}, nil
+			},
+		},
+	}
+	err := db.WarmViews(context.Background(),
+		design.New("a").
+			AddView("z", design.View{Map: "function(doc) {}"}).
+			AddView("y", design.View{Map: "function(doc) {}", Reduce: design.ReduceCount}),
+		design.New("b").AddFilter("f", "function() {}"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []query{{DDoc: "a", View: "y", Options: map[string]interface{}{"limit": 0, "reduce": false}}}
+	if d := testy.DiffInterface(want, queries); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,262 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// DiffKind describes how a document differs between two databases.
+type DiffKind int
+
+// The kinds of difference reported by [Diff].
+const (
+	// DiffMissingInTarget means the document exists only in the source.
+	DiffMissingInTarget DiffKind = iota + 1
+	// DiffMissingInSource means the document exists only in the target.
+	DiffMissingInSource
+	// DiffRevMismatch means the document exists in both databases, with
+	// different current revisions.
+	DiffRevMismatch
+	// DiffContentMismatch means the document exists in both databases, with
+	// different content. It is only reported when [WithCompareContent] is
+	// used, in place of DiffRevMismatch.
+	DiffContentMismatch
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffMissingInTarget:
+		return "missing in target"
+	case DiffMissingInSource:
+		return "missing in source"
+	case DiffRevMismatch:
+		return "rev mismatch"
+	case DiffContentMismatch:
+		return "content mismatch"
+	}
+	return "unknown"
+}
+
+// DocDiff describes a document which differs between two databases.
+type DocDiff struct {
+	ID   string
+	Kind DiffKind
+	// SourceRev and TargetRev are the current revisions of the document in
+	// each database, or empty if it is missing.
+	SourceRev string
+	TargetRev string
+}
+
+// DiffSummary counts the documents compared by [Diff].
+type DiffSummary struct {
+	// Compared is the number of documents present in both databases.
+	Compared int64
+	// Identical is the number of documents present in both databases, with
+	// the same revision, or the same content, with [WithCompareContent].
+	Identical         int64
+	MissingInTarget   int64
+	MissingInSource   int64
+	RevMismatches     int64
+	ContentMismatches int64
+}
+
+// optionCompareContent is the option key used by [WithCompareContent].
+const optionCompareContent = "kivik:compareContent"
+
+// WithCompareContent returns an option which causes [Diff] to fetch and
+// compare the content of documents whose revisions differ, and to report
+// only those whose content differs. This is useful when comparing databases
+// managed by drivers which assign revisions differently.
+func WithCompareContent() Options {
+	return Options{optionCompareContent: true}
+}
+
+// Diff compares the documents in source and target, which may use any
+// drivers, calling fn for each document which differs, in order of document
+// ID. Deleted documents are ignored. Both databases are read with
+// [DB.AllDocs], as a single streaming pass, so databases of any size may be
+// compared; options, such as [StartKey] and [EndKey] to compare a range of
+// documents, are passed to both calls to AllDocs.
+//
+// Documents are compared by revision unless [WithCompareContent] is used, in
+// which case documents whose revisions differ are fetched, and compared
+// without their _rev fields, and with attachments compared by their content
+// type and digest.
+//
+// If fn returns an error, Diff stops, and returns that error. Diff relies on
+// both drivers returning documents in the same order, as CouchDB does, and
+// fails if either does not return IDs in ascending byte order.
+func Diff(ctx context.Context, source, target *DB, fn func(*DocDiff) error, options ...Options) (*DiffSummary, error) {
+	if fn == nil {
+		return nil, missingArg("fn")
+	}
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	compareContent, _ := opts[optionCompareContent].(bool)
+	delete(opts, optionCompareContent)
+	src := newDiffCursor(source.AllDocs(ctx, opts))
+	defer src.rs.Close() // nolint:errcheck
+	tgt := newDiffCursor(target.AllDocs(ctx, opts))
+	defer tgt.rs.Close() // nolint:errcheck
+
+	summary := &DiffSummary{}
+	for {
+		if err := src.err(); err != nil {
+			return summary, err
+		}
+		if err := tgt.err(); err != nil {
+			return summary, err
+		}
+		if src.done && tgt.done {
+			return summary, nil
+		}
+		var diff *DocDiff
+		switch {
+		case tgt.done || (!src.done && src.id < tgt.id):
+			summary.MissingInTarget++
+			diff = &DocDiff{ID: src.id, Kind: DiffMissingInTarget, SourceRev: src.rev}
+			src.next()
+		case src.done || tgt.id < src.id:
+			summary.MissingInSource++
+			diff = &DocDiff{ID: tgt.id, Kind: DiffMissingInSource, TargetRev: tgt.rev}
+			tgt.next()
+		default:
+			summary.Compared++
+			diff = &DocDiff{ID: src.id, Kind: DiffRevMismatch, SourceRev: src.rev, TargetRev: tgt.rev}
+			if src.rev == tgt.rev {
+				diff = nil
+			} else if compareContent {
+				same, err := sameContent(ctx, source, target, src.id)
+				if err != nil {
+					return summary, err
+				}
+				diff.Kind = DiffContentMismatch
+				if same {
+					diff = nil
+				}
+			}
+			switch {
+			case diff == nil:
+				summary.Identical++
+			case diff.Kind == DiffRevMismatch:
+				summary.RevMismatches++
+			default:
+				summary.ContentMismatches++
+			}
+			src.next()
+			tgt.next()
+		}
+		if diff != nil {
+			if err := fn(diff); err != nil {
+				return summary, err
+			}
+		}
+	}
+}
+
+// diffCursor tracks the current row of one side of a [Diff].
+type diffCursor struct {
+	rs      ResultSet
+	id, rev string
+	done    bool
+	failure error
+}
+
+func newDiffCursor(rs ResultSet) *diffCursor {
+	c := &diffCursor{rs: rs}
+	c.next()
+	return c
+}
+
+func (c *diffCursor) next() {
+	if c.done || c.failure != nil {
+		return
+	}
+	if !c.rs.Next() {
+		c.done = true
+		return
+	}
+	prev := c.id
+	id, err := c.rs.ID()
+	if err != nil {
+		c.failure = err
+		return
+	}
+	var value struct {
+		Rev string `json:"rev"`
+	}
+	if err := c.rs.ScanValue(&value); err != nil {
+		c.failure = err
+		return
+	}
+	if prev != "" && id <= prev {
+		c.failure = &Error{Status: http.StatusInternalServerError, Message: "kivik: documents not returned in ascending order of ID: " + id + " after " + prev}
+		return
+	}
+	c.id, c.rev = id, value.Rev
+}
+
+func (c *diffCursor) err() error {
+	if c.failure != nil {
+		return c.failure
+	}
+	if c.done {
+		return c.rs.Err()
+	}
+	return nil
+}
+
+// sameContent reports whether docID has the same content in both databases.
+func sameContent(ctx context.Context, source, target *DB, docID string) (bool, error) {
+	a, err := comparableDoc(ctx, source, docID)
+	if err != nil {
+		return false, err
+	}
+	b, err := comparableDoc(ctx, target, docID)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(a, b), nil
+}
+
+// comparableDoc fetches docID, and strips the fields which are expected to
+// differ between databases with the same content.
+func comparableDoc(ctx context.Context, db *DB, docID string) (map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := db.Get(ctx, docID).ScanDoc(&raw); err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := unmarshalJSON(raw, &doc, true); err != nil {
+		return nil, err
+	}
+	delete(doc, "_rev")
+	delete(doc, "_revisions")
+	if atts, ok := doc["_attachments"].(map[string]interface{}); ok {
+		for name, att := range atts {
+			if m, ok := att.(map[string]interface{}); ok {
+				atts[name] = map[string]interface{}{
+					"content_type": m["content_type"],
+					"digest":       m["digest"],
+				}
+			}
+		}
+	}
+	return doc, nil
+}
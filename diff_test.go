@@ -0,0 +1,172 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// diffDB returns a database containing docs, a map of ID to document JSON,
+// listed by AllDocs in the order of ids, with revisions from revs.
+func diffDB(ids []string, revs map[string]string, docs map[string]string) *DB {
+	return &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+				remaining := ids
+				return &mock.Rows{
+					NextFunc: func(r *driver.Row) error {
+						if len(remaining) == 0 {
+							return io.EOF
+						}
+						id := remaining[0]
+						remaining = remaining[1:]
+						*r = driver.Row{
+							ID:    id,
+							Key:   []byte(`"` + id + `"`),
+							Value: strings.NewReader(`{"rev":"` + revs[id] + `"}`),
+						}
+						return nil
+					},
+				}, nil
+			},
+			GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+				doc, ok := docs[docID]
+				if !ok {
+					return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+				}
+				return &driver.Document{
+					Rev:  revs[docID],
+					Body: io.NopCloser(strings.NewReader(doc)),
+				}, nil
+			},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type tt struct {
+		source, target *DB
+		options        Options
+		want           []DocDiff
+		wantSummary    *DiffSummary
+		status         int
+		err            string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("identical", tt{
+		source:      diffDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "2-b"}, nil),
+		target:      diffDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "2-b"}, nil),
+		wantSummary: &DiffSummary{Compared: 2, Identical: 2},
+	})
+	tests.Add("missing and mismatched", tt{
+		source: diffDB([]string{"a", "b", "d"}, map[string]string{"a": "1-a", "b": "2-b", "d": "1-d"}, nil),
+		target: diffDB([]string{"b", "c", "d"}, map[string]string{"b": "3-b", "c": "1-c", "d": "1-d"}, nil),
+		want: []DocDiff{
+			{ID: "a", Kind: DiffMissingInTarget, SourceRev: "1-a"},
+			{ID: "b", Kind: DiffRevMismatch, SourceRev: "2-b", TargetRev: "3-b"},
+			{ID: "c", Kind: DiffMissingInSource, TargetRev: "1-c"},
+		},
+		wantSummary: &DiffSummary{Compared: 2, Identical: 1, MissingInTarget: 1, MissingInSource: 1, RevMismatches: 1},
+	})
+	tests.Add("compare content", tt{
+		source: diffDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "1-b"}, map[string]string{
+			"a": `{"_id":"a","_rev":"1-a","x":1,"_attachments":{"foo.txt":{"content_type":"text/plain","digest":"md5-xxx","revpos":1,"stub":true}}}`,
+			"b": `{"_id":"b","_rev":"1-b","x":1}`,
+		}),
+		target: diffDB([]string{"a", "b"}, map[string]string{"a": "1-z", "b": "1-y"}, map[string]string{
+			"a": `{"_id":"a","_rev":"1-z","x":1,"_attachments":{"foo.txt":{"content_type":"text/plain","digest":"md5-xxx","revpos":3,"stub":true}}}`,
+			"b": `{"_id":"b","_rev":"1-y","x":2}`,
+		}),
+		options: WithCompareContent(),
+		want: []DocDiff{
+			{ID: "b", Kind: DiffContentMismatch, SourceRev: "1-b", TargetRev: "1-y"},
+		},
+		wantSummary: &DiffSummary{Compared: 2, Identical: 1, ContentMismatches: 1},
+	})
+	tests.Add("compare content, get error", tt{
+		source:      diffDB([]string{"a"}, map[string]string{"a": "1-a"}, nil),
+		target:      diffDB([]string{"a"}, map[string]string{"a": "1-z"}, nil),
+		options:     WithCompareContent(),
+		wantSummary: &DiffSummary{Compared: 1},
+		status:      http.StatusNotFound,
+		err:         "missing",
+	})
+	tests.Add("unordered", tt{
+		source:      diffDB([]string{"b", "a"}, map[string]string{"a": "1-a", "b": "1-b"}, nil),
+		target:      diffDB([]string{"b"}, map[string]string{"b": "1-b"}, nil),
+		wantSummary: &DiffSummary{Compared: 1, Identical: 1},
+		status:      http.StatusInternalServerError,
+		err:         "kivik: documents not returned in ascending order of ID: a after b",
+	})
+	tests.Add("all docs error", tt{
+		source: diffDB(nil, nil, nil),
+		target: &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+					return nil, &Error{Status: http.StatusUnauthorized, Message: "unauthorized"}
+				},
+			},
+		},
+		wantSummary: &DiffSummary{},
+		status:      http.StatusUnauthorized,
+		err:         "unauthorized",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var got []DocDiff
+		summary, err := Diff(context.Background(), tt.source, tt.target, func(d *DocDiff) error {
+			got = append(got, *d)
+			return nil
+		}, tt.options)
+		if d := testy.DiffInterface(tt.want, got); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(tt.wantSummary, summary); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestDiffCallbackError(t *testing.T) {
+	source := diffDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "1-b"}, nil)
+	target := diffDB(nil, nil, nil)
+	var calls int
+	_, err := Diff(context.Background(), source, target, func(*DocDiff) error {
+		calls++
+		return errors.New("stop")
+	})
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	testy.Error(t, "stop", err)
+}
+
+func TestDiffKindString(t *testing.T) {
+	if got := DiffContentMismatch.String(); got != "content mismatch" {
+		t.Errorf("Unexpected string: %s", got)
+	}
+}
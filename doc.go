@@ -20,11 +20,20 @@ officially supported drivers are:
   - PouchDB: https://github.com/go-kivik/pouchdb (requires GopherJS)
   - KivikMock: https://github.com/go-kivik/kivikmock
 
-The Filesystem and Memory drivers are also available, but in early stages of
-development, and so many features do not yet work:
-
-  - Filesystem: https://github.com/go-kivik/fsdb
-  - MemroyDB: https://github.com/go-kivik/memorydb
+An in-memory driver, suitable for unit tests and ephemeral data, is included
+in the [github.com/go-kivik/kivik/v4/x/memorydb] package, and registered as
+"memory". A driver which stores databases as directories of JSON files,
+suitable for fixtures kept in version control, is included in the
+[github.com/go-kivik/kivik/v4/x/fsdb] package, and registered as "file".
+A driver backed by SQLite, with revision trees and Mango queries, is provided
+by the separate module [github.com/go-kivik/kivik/v4/x/sqlite], and registered
+as "sqlite". To expose an existing [Client] through the driver interface, for
+instance to layer additional behavior on top of it, see
+[github.com/go-kivik/kivik/v4/x/proxydb]. Driver authors may check their
+implementation against the conformance suite in
+[github.com/go-kivik/kivik/v4/x/kiviktest]. Code which uses Kivik may be unit
+tested with the expectation-based mock client in
+[github.com/go-kivik/kivik/v4/x/kivikmock].
 
 The kivik driver system is modeled after the standard library's `sql` and
 `sql/driver` packages, although the client API is completely different due to
@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DocumentHandle is a single document fetched with [DB.GetDocument]. Its body
+// may be read once, either with [DocumentHandle.ScanDoc] or
+// [DocumentHandle.Body]. A DocumentHandle which is not scanned must be
+// closed.
+type DocumentHandle struct {
+	id   string
+	rev  string
+	atts *AttachmentsIterator
+
+	useNumber bool
+	codec     Codec
+
+	mu   sync.Mutex
+	body io.ReadCloser
+}
+
+// GetDocument fetches the requested document, as for [DB.Get], and returns a
+// handle to it. Unlike [DB.Get], any error fetching the document is returned
+// immediately. GetDocument accepts the same options as [DB.Get].
+//
+//	doc, err := db.GetDocument(ctx, "cow")
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println("rev:", doc.Rev())
+//	var cow Cow
+//	err = doc.ScanDoc(&cow)
+func (db *DB) GetDocument(ctx context.Context, docID string, options ...Options) (*DocumentHandle, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	doc, err := db.get(ctx, "GetDocument", docID, mergeOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	h := &DocumentHandle{
+		id:        docID,
+		rev:       doc.Rev,
+		body:      doc.Body,
+		useNumber: db.useNumber,
+		codec:     db.codec,
+	}
+	if doc.Attachments != nil {
+		h.atts = &AttachmentsIterator{atti: doc.Attachments}
+	}
+	return h, nil
+}
+
+// ID returns the ID of the document.
+func (h *DocumentHandle) ID() string {
+	return h.id
+}
+
+// Rev returns the revision of the document.
+func (h *DocumentHandle) Rev() string {
+	return h.rev
+}
+
+// Attachments returns an iterator over the attachments included with the
+// document, when the attachments option is set, or else nil.
+func (h *DocumentHandle) Attachments() *AttachmentsIterator {
+	return h.atts
+}
+
+// takeBody returns the body, which may only be taken once.
+func (h *DocumentHandle) takeBody() (io.ReadCloser, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.body == nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: document body already consumed"}
+	}
+	body := h.body
+	h.body = nil
+	return body, nil
+}
+
+// ScanDoc unmarshals the document body into dest, as for [ResultSet.ScanDoc],
+// and closes it.
+func (h *DocumentHandle) ScanDoc(dest interface{}) error {
+	body, err := h.takeBody()
+	if err != nil {
+		return err
+	}
+	defer body.Close() // nolint:errcheck
+	return decodeDoc(body, dest, h.useNumber, h.codec)
+}
+
+// Body returns the unparsed document body, exactly as returned by the driver,
+// which the caller must close. It is not decoded by any [Codec] configured
+// with [WithCodec].
+func (h *DocumentHandle) Body() (io.ReadCloser, error) {
+	return h.takeBody()
+}
+
+// Close closes the document body, if it has not been consumed. It is not
+// necessary to call Close after [DocumentHandle.ScanDoc] or
+// [DocumentHandle.Body].
+func (h *DocumentHandle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.body == nil {
+		return nil
+	}
+	err := h.body.Close()
+	h.body = nil
+	return err
+}
@@ -0,0 +1,107 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestGetDocument(t *testing.T) {
+	t.Run("db error", func(t *testing.T) {
+		db := &DB{client: &Client{}, err: errors.New("db error")}
+		_, err := db.GetDocument(context.Background(), "foo")
+		testy.StatusError(t, "db error", http.StatusInternalServerError, err)
+	})
+	t.Run("missing doc ID", func(t *testing.T) {
+		db := &DB{client: &Client{}}
+		_, err := db.GetDocument(context.Background(), "")
+		testy.StatusError(t, "kivik: docID required", http.StatusBadRequest, err)
+	})
+	t.Run("not found", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+				},
+			},
+		}
+		_, err := db.GetDocument(context.Background(), "foo")
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+
+	newDB := func() *DB {
+		return &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					return &driver.Document{
+						Rev:  "1-xxx",
+						Body: io.NopCloser(strings.NewReader(`{"_id":"foo","_rev":"1-xxx","name":"Bessie"}`)),
+					}, nil
+				},
+			},
+		}
+	}
+	t.Run("scan", func(t *testing.T) {
+		doc, err := newDB().GetDocument(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.ID() != "foo" || doc.Rev() != "1-xxx" {
+			t.Errorf("Unexpected ID/rev: %s/%s", doc.ID(), doc.Rev())
+		}
+		if doc.Attachments() != nil {
+			t.Error("Expected no attachments")
+		}
+		var result map[string]interface{}
+		if err := doc.ScanDoc(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result["name"] != "Bessie" {
+			t.Errorf("Unexpected result: %v", result)
+		}
+		err = doc.ScanDoc(&result)
+		testy.StatusError(t, "kivik: document body already consumed", http.StatusBadRequest, err)
+	})
+	t.Run("body", func(t *testing.T) {
+		doc, err := newDB().GetDocument(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := doc.Body()
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(body)
+		_ = body.Close()
+		if string(raw) != `{"_id":"foo","_rev":"1-xxx","name":"Bessie"}` {
+			t.Errorf("Unexpected body: %s", raw)
+		}
+		if err := doc.Close(); err != nil {
+			t.Errorf("Unexpected close error: %s", err)
+		}
+		_, err = doc.Body()
+		testy.StatusError(t, "kivik: document body already consumed", http.StatusBadRequest, err)
+	})
+}
@@ -0,0 +1,230 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IDFunc returns a new document ID for doc. See [WithIDGenerator].
+type IDFunc func(doc interface{}) (string, error)
+
+// optionIDGenerator is the option key used to pass an [IDFunc] to [New].
+const optionIDGenerator = "kivik:idGenerator"
+
+// WithIDGenerator returns an option which, when passed to [New], causes
+// [DB.CreateDoc] to assign an ID generated by fn to documents which do not
+// already have one, rather than leaving the choice to the server. fn is
+// called after any validators added with [DB.AddValidator]. For example:
+//
+//	client, err := kivik.New("couch", dsn, kivik.WithIDGenerator(func(interface{}) (string, error) {
+//	    return kivik.UUIDv7(), nil
+//	}))
+//
+// [ContentHashID] may be passed directly.
+func WithIDGenerator(fn IDFunc) Options {
+	return Options{optionIDGenerator: fn}
+}
+
+// assignID returns doc with an _id field generated by the client's
+// [IDFunc], if it has one and doc has no ID.
+func (db *DB) assignID(doc interface{}) (interface{}, error) {
+	gen := db.client.idGenerator
+	if gen == nil {
+		return doc, nil
+	}
+	if _, ok := extractDocID(doc); ok {
+		return doc, nil
+	}
+	m, err := toDocMap(doc, true)
+	if err != nil {
+		return nil, err
+	}
+	id, err := gen(doc)
+	if err != nil {
+		return nil, &Error{Status: http.StatusInternalServerError, Message: "kivik: failed to generate document ID", Err: err}
+	}
+	m["_id"] = id
+	return m, nil
+}
+
+// randomBytes fills b with random bytes. crypto/rand does not fail on
+// supported platforms, so neither does randomBytes.
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("kivik: failed to read random bytes: " + err.Error())
+	}
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	randomBytes(b)
+	return hex.EncodeToString(b)
+}
+
+// randomUint32 returns a random 32-bit integer.
+func randomUint32() uint32 {
+	b := make([]byte, 4)
+	randomBytes(b)
+	return binary.BigEndian.Uint32(b)
+}
+
+func formatUUID(b []byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
+
+// UUIDv4 returns a random (version 4) UUID, as defined by RFC 9562, in its
+// canonical, hyphenated form.
+func UUIDv4() string {
+	b := make([]byte, 16)
+	randomBytes(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return formatUUID(b)
+}
+
+// UUIDv7 returns a time-ordered (version 7) UUID, as defined by RFC 9562, in
+// its canonical, hyphenated form. UUIDs generated in different milliseconds
+// sort in the order in which they were generated, which keeps inserts into
+// CouchDB's B-trees cheap; those generated within the same millisecond are
+// ordered randomly.
+func UUIDv7() string {
+	return uuidv7(time.Now())
+}
+
+func uuidv7(t time.Time) string {
+	b := make([]byte, 16)
+	randomBytes(b[6:])
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = (b[6] & 0x0f) | 0x70
+	b[8] = (b[8] & 0x3f) | 0x80
+	return formatUUID(b)
+}
+
+// timeIDEpoch is the epoch of IDs returned by [TimeOrderedID], as for KSUIDs.
+const timeIDEpoch = 1400000000
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// timeIDLength is the length of IDs returned by [TimeOrderedID].
+const timeIDLength = 27
+
+// TimeOrderedID returns a 27-character, base62-encoded ID, made of a 32-bit
+// timestamp with one second resolution followed by 128 random bits, in the
+// format of a KSUID. IDs generated in different seconds sort, as strings, in
+// the order in which they were generated.
+func TimeOrderedID() string {
+	return timeOrderedID(time.Now())
+}
+
+func timeOrderedID(t time.Time) string {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()-timeIDEpoch))
+	randomBytes(b[4:])
+	n := new(big.Int).SetBytes(b)
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	id := make([]byte, timeIDLength)
+	for i := timeIDLength - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		id[i] = base62[mod.Int64()]
+	}
+	return string(id)
+}
+
+// ContentHashID returns an ID derived from the content of doc: the
+// hex-encoded SHA-256 hash of its JSON representation, with object keys
+// sorted, and with any _id and _rev fields removed. Documents with the same
+// content are therefore given the same ID, which makes repeated inserts of
+// the same data idempotent, as the second insert conflicts with the first.
+func ContentHashID(doc interface{}) (string, error) {
+	m, err := toDocMap(doc, true)
+	if err != nil {
+		return "", err
+	}
+	delete(m, "_id")
+	delete(m, "_rev")
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return "", &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// The following generate the UUIDs of CouchDB's uuids/algorithm setting, for
+// [Client.UUIDs].
+
+// randomUUID returns a UUID generated with [UUIDRandom].
+func randomUUID() string {
+	return randomHex(16)
+}
+
+// utcUUID returns the time since the Unix epoch, in microseconds, as 14 hex
+// characters.
+func utcUUID(t time.Time) string {
+	return fmt.Sprintf("%014x", t.UnixNano()/int64(time.Microsecond))
+}
+
+// utcRandomUUID returns a UUID generated with [UUIDUTCRandom].
+func utcRandomUUID() string {
+	return utcUUID(time.Now()) + randomHex(9)
+}
+
+// sequentialUUIDs generates UUIDs with [UUIDSequential].
+type sequentialUUIDs struct {
+	mu     sync.Mutex
+	prefix string
+	seq    uint32
+}
+
+// sequentialOverflow is the suffix at which a new prefix is chosen, as in
+// CouchDB.
+const sequentialOverflow = 0xfff000
+
+func (s *sequentialUUIDs) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prefix == "" || s.seq >= sequentialOverflow {
+		s.prefix = randomHex(13)
+		s.seq = randomUint32() % 0xfff
+	}
+	s.seq += 1 + randomUint32()%0xfe
+	return fmt.Sprintf("%s%06x", s.prefix, s.seq)
+}
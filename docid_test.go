@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestUUIDv4(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := UUIDv4(), UUIDv4()
+	if !re.MatchString(a) {
+		t.Errorf("Invalid UUID: %s", a)
+	}
+	if a == b {
+		t.Errorf("Expected distinct UUIDs")
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := uuidv7(now)
+	if !re.MatchString(a) {
+		t.Errorf("Invalid UUID: %s", a)
+	}
+	if want := "018cc820-d888"; a[:13] != want {
+		t.Errorf("Unexpected timestamp: %s", a[:13])
+	}
+	if b := uuidv7(now.Add(time.Millisecond)); b <= a {
+		t.Errorf("Expected %s > %s", b, a)
+	}
+	if !re.MatchString(UUIDv7()) {
+		t.Errorf("Invalid UUID")
+	}
+}
+
+func TestTimeOrderedID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9A-Za-z]{27}$`)
+	now := time.Now()
+	a := timeOrderedID(now)
+	if !re.MatchString(a) {
+		t.Errorf("Invalid ID: %s", a)
+	}
+	if b := timeOrderedID(now.Add(time.Second)); b <= a {
+		t.Errorf("Expected %s > %s", b, a)
+	}
+	if !re.MatchString(TimeOrderedID()) {
+		t.Errorf("Invalid ID")
+	}
+}
+
+func TestContentHashID(t *testing.T) {
+	a, err := ContentHashID(map[string]interface{}{"_id": "foo", "_rev": "1-xxx", "a": 1, "b": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ContentHashID(struct {
+		B string `json:"b"`
+		A int    `json:"a"`
+	}{B: "x", A: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("Expected equal IDs, got %s and %s", a, b)
+	}
+	c, err := ContentHashID(map[string]interface{}{"a": 2, "b": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == c {
+		t.Errorf("Expected distinct IDs")
+	}
+	if len(a) != 64 {
+		t.Errorf("Unexpected ID length: %d", len(a))
+	}
+}
+
+func TestCreateDocIDGenerator(t *testing.T) {
+	newDB := func(gen IDFunc) *DB {
+		client := &Client{}
+		client.applyOptions(WithIDGenerator(gen))
+		return &DB{
+			client: client,
+			driverDB: &mock.DB{
+				CreateDocFunc: func(_ context.Context, doc interface{}, _ map[string]interface{}) (string, string, error) {
+					id, _ := extractDocID(doc)
+					return id, "1-xxx", nil
+				},
+			},
+		}
+	}
+	gen := func(interface{}) (string, error) { return "generated", nil }
+
+	t.Run("assigned", func(t *testing.T) {
+		docID, _, err := newDB(gen).CreateDoc(context.Background(), map[string]interface{}{"a": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if docID != "generated" {
+			t.Errorf("Unexpected doc ID: %s", docID)
+		}
+	})
+	t.Run("existing ID", func(t *testing.T) {
+		docID, _, err := newDB(gen).CreateDoc(context.Background(), map[string]interface{}{"_id": "foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if docID != "foo" {
+			t.Errorf("Unexpected doc ID: %s", docID)
+		}
+	})
+	t.Run("content hash", func(t *testing.T) {
+		doc := map[string]interface{}{"a": 1}
+		docID, _, err := newDB(ContentHashID).CreateDoc(context.Background(), doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, _ := ContentHashID(doc); docID != want {
+			t.Errorf("Unexpected doc ID: %s", docID)
+		}
+	})
+	t.Run("generator error", func(t *testing.T) {
+		_, _, err := newDB(func(interface{}) (string, error) {
+			return "", errors.New("boom")
+		}).CreateDoc(context.Background(), map[string]interface{}{"a": 1})
+		testy.StatusError(t, "kivik: failed to generate document ID: boom", http.StatusInternalServerError, err)
+	})
+}
@@ -0,0 +1,110 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// DocMeta is the metadata of a document, as returned by [DB.GetMeta].
+type DocMeta struct {
+	// ID is the document ID.
+	ID string
+	// Rev is the revision described.
+	Rev string
+	// Deleted is true if the revision is a deletion tombstone.
+	Deleted bool
+	// Size is the size, in bytes, of the JSON-encoded document, excluding
+	// attachment content, or -1 if the driver cannot report it.
+	Size int64
+	// RevCount is the number of known revisions of the document. It is only
+	// set when revision info is requested.
+	RevCount int
+	// RevsInfo lists the known revisions of the document, newest first, with
+	// their availability. It is only set when revision info is requested.
+	RevsInfo []RevInfo
+}
+
+// RevInfo describes a single revision of a document. Status is one of
+// "available", "missing" or "deleted".
+type RevInfo struct {
+	Rev    string `json:"rev"`
+	Status string `json:"status"`
+}
+
+// GetMeta returns the revision, deletion status and size of the requested
+// document, so that callers such as caches or synchronization code can decide
+// whether to fetch it. GetMeta accepts the same options as [DB.Get]; pass
+// [GetOptions] with RevsInfo set to also populate RevCount and RevsInfo.
+//
+// If the driver implements [driver.MetaGetter], the document body is not
+// read, and deleted documents may be reported with Deleted set. Otherwise the
+// document is fetched with [DB.Get], Size is the length of the returned body,
+// and a deleted document results in a 404 error.
+func (db *DB) GetMeta(ctx context.Context, docID string, options ...Options) (*DocMeta, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	opts := mergeOptions(options...)
+	if m, ok := optional(db.driverDB, (*driver.MetaGetter)(nil)).(driver.MetaGetter); ok {
+		if err := db.startQuery(); err != nil {
+			return nil, err
+		}
+		defer db.endQuery()
+		var meta *driver.DocMeta
+		err := db.client.invoke(ctx, &Operation{Method: "GetMeta", DB: db.name, DocID: docID, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			meta, err = m.GetMeta(ctx, docID, opts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := &DocMeta{
+			ID:      docID,
+			Rev:     meta.Rev,
+			Deleted: meta.Deleted,
+			Size:    meta.Size,
+		}
+		for _, info := range meta.RevsInfo {
+			result.RevsInfo = append(result.RevsInfo, RevInfo(info))
+		}
+		result.RevCount = len(result.RevsInfo)
+		return result, nil
+	}
+	var raw json.RawMessage
+	if err := db.Get(ctx, docID, opts).ScanDoc(&raw); err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Rev      string    `json:"_rev"`
+		Deleted  bool      `json:"_deleted"`
+		RevsInfo []RevInfo `json:"_revs_info"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &DocMeta{
+		ID:       docID,
+		Rev:      doc.Rev,
+		Deleted:  doc.Deleted,
+		Size:     int64(len(raw)),
+		RevCount: len(doc.RevsInfo),
+		RevsInfo: doc.RevsInfo,
+	}, nil
+}
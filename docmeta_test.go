@@ -0,0 +1,171 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestGetMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      *DB
+		docID   string
+		options Options
+		want    *DocMeta
+		status  int
+		err     string
+	}{
+		{
+			name: "db error",
+			db: &DB{
+				err: errors.New("db error"),
+			},
+			docID:  "foo",
+			status: http.StatusInternalServerError,
+			err:    "db error",
+		},
+		{
+			name:   "no doc ID",
+			db:     &DB{client: &Client{}, driverDB: &mock.DB{}},
+			status: http.StatusBadRequest,
+			err:    "kivik: docID required",
+		},
+		{
+			name: errClientClosed,
+			db: &DB{
+				client:   &Client{closed: 1},
+				driverDB: &mock.MetaGetter{},
+			},
+			docID:  "foo",
+			status: http.StatusServiceUnavailable,
+			err:    errClientClosed,
+		},
+		{
+			name: "meta getter error",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.MetaGetter{
+					GetMetaFunc: func(context.Context, string, map[string]interface{}) (*driver.DocMeta, error) {
+						return nil, &Error{Status: http.StatusBadGateway, Err: errors.New("get meta error")}
+					},
+				},
+			},
+			docID:  "foo",
+			status: http.StatusBadGateway,
+			err:    "get meta error",
+		},
+		{
+			name: "meta getter success",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.MetaGetter{
+					GetMetaFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.DocMeta, error) {
+						if docID != "foo" {
+							return nil, fmt.Errorf("Unexpected docID: %s", docID)
+						}
+						if d := testy.DiffInterface(map[string]interface{}{"revs_info": true}, opts); d != nil {
+							return nil, fmt.Errorf("Unexpected options:\n%s", d)
+						}
+						return &driver.DocMeta{
+							Rev:     "2-yyy",
+							Deleted: true,
+							Size:    42,
+							RevsInfo: []driver.RevInfo{
+								{Rev: "2-yyy", Status: "deleted"},
+								{Rev: "1-xxx", Status: "missing"},
+							},
+						}, nil
+					},
+				},
+			},
+			docID:   "foo",
+			options: Options{"revs_info": true},
+			want: &DocMeta{
+				ID:       "foo",
+				Rev:      "2-yyy",
+				Deleted:  true,
+				Size:     42,
+				RevCount: 2,
+				RevsInfo: []RevInfo{
+					{Rev: "2-yyy", Status: "deleted"},
+					{Rev: "1-xxx", Status: "missing"},
+				},
+			},
+		},
+		{
+			name: "fallback error",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+						return nil, &Error{Status: http.StatusNotFound, Message: "deleted"}
+					},
+				},
+			},
+			docID:  "foo",
+			status: http.StatusNotFound,
+			err:    "deleted",
+		},
+		{
+			name: "fallback success",
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+						if docID != "foo" {
+							return nil, fmt.Errorf("Unexpected docID: %s", docID)
+						}
+						if d := testy.DiffInterface(map[string]interface{}{"revs_info": true}, opts); d != nil {
+							return nil, fmt.Errorf("Unexpected options:\n%s", d)
+						}
+						return &driver.Document{
+							Rev:  "2-yyy",
+							Body: body(`{"_id":"foo","_rev":"2-yyy","_revs_info":[{"rev":"2-yyy","status":"available"},{"rev":"1-xxx","status":"missing"}]}`),
+						}, nil
+					},
+				},
+			},
+			docID:   "foo",
+			options: Options{"revs_info": true},
+			want: &DocMeta{
+				ID:       "foo",
+				Rev:      "2-yyy",
+				Size:     115,
+				RevCount: 2,
+				RevsInfo: []RevInfo{
+					{Rev: "2-yyy", Status: "available"},
+					{Rev: "1-xxx", Status: "missing"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meta, err := test.db.GetMeta(context.Background(), test.docID, test.options)
+			testy.StatusError(t, test.err, test.status, err)
+			if d := testy.DiffInterface(test.want, meta); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
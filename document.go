@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Document holds the special fields of a CouchDB document. It may be
+// embedded in a struct to give it the fields required by [DB.Save]:
+//
+//	type Note struct {
+//	    kivik.Document
+//	    Text string `json:"text"`
+//	}
+type Document struct {
+	ID          string      `json:"_id,omitempty"`
+	Rev         string      `json:"_rev,omitempty"`
+	Deleted     bool        `json:"_deleted,omitempty"`
+	Attachments Attachments `json:"_attachments,omitempty"`
+}
+
+// docFields holds the settable special fields of a document passed to Save.
+type docFields struct {
+	id, rev, attachments reflect.Value
+}
+
+// findDocFields locates the fields of the struct v which are marshaled to
+// JSON as _id, _rev and _attachments, including those of embedded structs.
+func findDocFields(v reflect.Value, fields *docFields) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		fv := v.Field(i)
+		if name == "" && f.Anonymous {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				findDocFields(fv, fields)
+			}
+			continue
+		}
+		switch {
+		case name == "_id" && fv.Kind() == reflect.String && !fields.id.IsValid():
+			fields.id = fv
+		case name == "_rev" && fv.Kind() == reflect.String && !fields.rev.IsValid():
+			fields.rev = fv
+		case name == "_attachments" && fv.Type() == reflect.TypeOf(Attachments{}) && !fields.attachments.IsValid():
+			fields.attachments = fv
+		}
+	}
+}
+
+// Save stores doc, which must be a pointer to a struct with string fields
+// marshaled to JSON as _id and _rev, such as those provided by embedding
+// [Document], or a map[string]interface{}. If the ID is empty, a new
+// document is created, as with [DB.CreateDoc], and the generated ID is
+// stored in doc. Otherwise, the document is stored as with [DB.Put]. In
+// either case, the new revision is stored in doc and returned, so doc may be
+// modified and saved again.
+//
+// After a successful save, any attachments uploaded with the document are
+// replaced with stubs in doc, so that they are not uploaded again.
+func (db *DB) Save(ctx context.Context, doc interface{}, options ...Options) (rev string, err error) {
+	if m, ok := doc.(map[string]interface{}); ok {
+		return db.saveMap(ctx, m, options...)
+	}
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: Save requires a pointer to a struct"}
+	}
+	var fields docFields
+	findDocFields(v.Elem(), &fields)
+	if !fields.id.IsValid() || !fields.rev.IsValid() {
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: Save requires string fields for _id and _rev"}
+	}
+	if docID := fields.id.String(); docID != "" {
+		rev, err = db.Put(ctx, docID, doc, options...)
+	} else {
+		docID, rev, err = db.CreateDoc(ctx, doc, options...)
+		if err == nil {
+			fields.id.SetString(docID)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	fields.rev.SetString(rev)
+	if fields.attachments.IsValid() {
+		stubAttachments(fields.attachments.Interface().(Attachments))
+	}
+	return rev, nil
+}
+
+func (db *DB) saveMap(ctx context.Context, doc map[string]interface{}, options ...Options) (rev string, err error) {
+	if doc == nil {
+		return "", missingArg("doc")
+	}
+	if docID, _ := doc["_id"].(string); docID != "" {
+		rev, err = db.Put(ctx, docID, doc, options...)
+	} else {
+		docID, rev, err = db.CreateDoc(ctx, doc, options...)
+		if err == nil {
+			doc["_id"] = docID
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	doc["_rev"] = rev
+	if atts, ok := doc["_attachments"].(Attachments); ok {
+		stubAttachments(atts)
+	}
+	return rev, nil
+}
+
+// stubAttachments marks attachments whose content has been uploaded as
+// stubs.
+func stubAttachments(atts Attachments) {
+	for _, att := range atts {
+		if att != nil && !att.Stub && !att.Follows {
+			att.Stub = true
+			att.Content = nilContent
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type testNote struct {
+	Document
+	Text string `json:"text"`
+}
+
+type taggedNote struct {
+	DocID string `json:"_id,omitempty"`
+	Rev   string `json:"_rev,omitempty"`
+	Text  string `json:"text"`
+}
+
+// saveDB returns a DB which records the JSON of the last document written,
+// and returns rev as the new revision.
+func saveDB(rev string, written *string) *DB {
+	record := func(doc interface{}) error {
+		raw, err := json.Marshal(doc)
+		*written = string(raw)
+		return err
+	}
+	return &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+				return rev, record(doc)
+			},
+			CreateDocFunc: func(_ context.Context, doc interface{}, _ map[string]interface{}) (string, string, error) {
+				return "newid", rev, record(doc)
+			},
+		},
+	}
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+	t.Run("create", func(t *testing.T) {
+		var written string
+		note := &testNote{Text: "hello"}
+		rev, err := saveDB("1-xxx", &written).Save(ctx, note)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-xxx" || note.ID != "newid" || note.Rev != "1-xxx" {
+			t.Errorf("Unexpected result: %s, %+v", rev, note)
+		}
+		if written != `{"text":"hello"}` {
+			t.Errorf("Unexpected document written: %s", written)
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		var written string
+		note := &testNote{Document: Document{ID: "foo", Rev: "1-xxx"}, Text: "hello"}
+		if _, err := saveDB("2-xxx", &written).Save(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+		if note.Rev != "2-xxx" {
+			t.Errorf("Unexpected rev: %s", note.Rev)
+		}
+		if written != `{"_id":"foo","_rev":"1-xxx","text":"hello"}` {
+			t.Errorf("Unexpected document written: %s", written)
+		}
+	})
+	t.Run("struct tags", func(t *testing.T) {
+		var written string
+		note := &taggedNote{DocID: "foo", Text: "hello"}
+		if _, err := saveDB("1-xxx", &written).Save(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+		if note.Rev != "1-xxx" {
+			t.Errorf("Unexpected rev: %s", note.Rev)
+		}
+	})
+	t.Run("map", func(t *testing.T) {
+		var written string
+		doc := map[string]interface{}{"text": "hello"}
+		if _, err := saveDB("1-xxx", &written).Save(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["_id"] != "newid" || doc["_rev"] != "1-xxx" {
+			t.Errorf("Unexpected document: %v", doc)
+		}
+	})
+	t.Run("attachments become stubs", func(t *testing.T) {
+		var written string
+		note := &testNote{Document: Document{
+			ID: "foo",
+			Attachments: Attachments{"a.txt": &Attachment{
+				Filename:    "a.txt",
+				ContentType: "text/plain",
+				Content:     io.NopCloser(strings.NewReader("hi")),
+			}},
+		}}
+		db := saveDB("1-xxx", &written)
+		if _, err := db.Save(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(written, `"data":"aGk="`) {
+			t.Errorf("Expected attachment content to be uploaded: %s", written)
+		}
+		if _, err := db.Save(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(written, `"stub":true`) {
+			t.Errorf("Expected attachment stub on second save: %s", written)
+		}
+	})
+	t.Run("put error", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+					return "", &Error{Status: http.StatusConflict, Message: "conflict"}
+				},
+			},
+		}
+		note := &testNote{Document: Document{ID: "foo", Rev: "1-xxx"}}
+		_, err := db.Save(ctx, note)
+		if HTTPStatus(err) != http.StatusConflict {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if note.Rev != "1-xxx" {
+			t.Errorf("Rev should not change on error, got %s", note.Rev)
+		}
+	})
+	for _, doc := range []interface{}{
+		testNote{},
+		(*testNote)(nil),
+		&struct{ Text string }{},
+		&struct {
+			ID  int    `json:"_id"`
+			Rev string `json:"_rev"`
+		}{},
+	} {
+		t.Run(fmt.Sprintf("invalid %T", doc), func(t *testing.T) {
+			_, err := (&DB{client: &Client{}}).Save(ctx, doc)
+			if HTTPStatus(err) != http.StatusBadRequest {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -261,6 +261,33 @@ type BulkDocer interface {
 	BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) ([]BulkResult, error)
 }
 
+// Counter is an optional interface which may be implemented by a DB to count
+// the documents matching a Mango selector without returning them. If it is
+// not implemented, the documents are counted with a [Finder] query.
+type Counter interface {
+	// Count returns the number of non-design documents matching selector,
+	// which is any value accepted as the selector of a Find query.
+	Count(ctx context.Context, selector interface{}, options map[string]interface{}) (int64, error)
+}
+
+// DocSource is a stream of documents, as passed to [BulkDocsStreamer].
+type DocSource interface {
+	// Next returns the next document, or [io.EOF] when there are no more
+	// documents.
+	Next() (interface{}, error)
+}
+
+// BulkDocsStreamer is an optional interface which may be implemented by a DB
+// to stream the documents of a bulk operation into the request body, as they
+// are read from docs, rather than collecting them in memory first. If it is
+// not implemented, documents are read in batches, each of which is passed to
+// [BulkDocer].
+type BulkDocsStreamer interface {
+	// BulkDocsStream stores each of the documents read from docs. An error
+	// returned by docs.Next, other than io.EOF, must abort the request.
+	BulkDocsStream(ctx context.Context, docs DocSource, options map[string]interface{}) ([]BulkResult, error)
+}
+
 // Finder is an optional interface which may be implemented by a DB. It provides
 // access to the new (in CouchDB 2.0) MongoDB-style query interface.
 type Finder interface {
@@ -344,6 +371,38 @@ type RevGetter interface {
 	GetRev(ctx context.Context, docID string, options map[string]interface{}) (rev string, err error)
 }
 
+// DocMeta is the metadata of a single document revision, as returned by
+// [MetaGetter].
+type DocMeta struct {
+	// Rev is the revision described.
+	Rev string
+	// Deleted is true if the revision is a deletion tombstone.
+	Deleted bool
+	// Size is the size, in bytes, of the JSON-encoded document, excluding
+	// attachment content, or -1 if unknown.
+	Size int64
+	// RevsInfo lists the known revisions of the document, newest first. It
+	// is only populated when the revs_info option is set.
+	RevsInfo []RevInfo
+}
+
+// RevInfo describes a single revision of a document.
+type RevInfo struct {
+	Rev    string `json:"rev"`
+	Status string `json:"status"`
+}
+
+// MetaGetter is an optional interface that may be implemented by a [DB] that
+// can report document metadata without reading the document body. If not
+// implemented, [DB.Get] will be used to emulate the functionality.
+type MetaGetter interface {
+	// GetMeta returns the metadata of the requested document. GetMeta should
+	// accept the same options as [DB.Get]. Unlike Get, GetMeta should
+	// report the tombstone of a deleted document, rather than returning an
+	// error, if the driver is able to.
+	GetMeta(ctx context.Context, docID string, options map[string]interface{}) (*DocMeta, error)
+}
+
 // Flusher is an optional interface that may be implemented by a [DB] that can
 // force a flush of the database backend file(s) to disk or other permanent
 // storage.
@@ -385,6 +444,50 @@ type Pinger interface {
 	Ping(ctx context.Context) (bool, error)
 }
 
+// UUIDer is an optional interface that may be implemented by a [Client] whose
+// server generates UUIDs, as CouchDB does with the /_uuids endpoint. When not
+// implemented, Kivik generates UUIDs locally.
+type UUIDer interface {
+	// UUIDs returns count UUIDs generated by the server.
+	UUIDs(ctx context.Context, count int) ([]string, error)
+}
+
+// ActiveTasker is an optional interface that may be implemented by a [Client]
+// whose server reports its running tasks, such as compactions, indexing and
+// replications, as CouchDB does with the /_active_tasks endpoint.
+type ActiveTasker interface {
+	// ActiveTasks returns the running tasks, as a JSON array in the format of
+	// the /_active_tasks endpoint.
+	ActiveTasks(ctx context.Context) (json.RawMessage, error)
+}
+
+// PoolStats are the statistics of a client's connection pool.
+type PoolStats struct {
+	// MaxOpenConnections is the maximum number of open connections, or 0 if
+	// unlimited.
+	MaxOpenConnections int
+	// OpenConnections is the number of established connections, both in use
+	// and idle.
+	OpenConnections int
+	// InUse is the number of connections currently in use.
+	InUse int
+	// Idle is the number of idle connections.
+	Idle int
+	// WaitCount is the total number of requests which waited for a
+	// connection.
+	WaitCount int64
+	// WaitDuration is the total time spent waiting for a connection.
+	WaitDuration time.Duration
+}
+
+// PoolStatser is an optional interface that may be implemented by a [Client]
+// which maintains a pool of connections, such as an HTTP transport.
+type PoolStatser interface {
+	// PoolStats returns the current statistics of the connection pool. It
+	// must not make any network requests.
+	PoolStats() *PoolStats
+}
+
 // ClusterMembership contains the list of known nodes, and cluster nodes, as
 // returned by the /_membership endpoint.
 // See https://docs.couchdb.org/en/latest/api/server/common.html#get--_membership
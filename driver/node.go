@@ -0,0 +1,31 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// NodeInspector is an optional interface that may be implemented by a
+// [Client] whose server reports statistics about its nodes, as CouchDB does
+// with the /_node/{node-name}/_stats and /_node/{node-name}/_system
+// endpoints. The responses are returned as raw JSON, and decoded by Kivik.
+type NodeInspector interface {
+	// NodeStats returns the statistics of the named node, in the format of
+	// the /_node/{node-name}/_stats endpoint.
+	NodeStats(ctx context.Context, node string) (json.RawMessage, error)
+	// NodeSystem returns the system-level metrics of the named node, in the
+	// format of the /_node/{node-name}/_system endpoint.
+	NodeSystem(ctx context.Context, node string) (json.RawMessage, error)
+}
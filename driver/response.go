@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResponseMetadata is a copy of kivik.ResponseInfo.
+type ResponseMetadata struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// ETag is the value of the ETag header, with quotes removed.
+	ETag string
+	// RequestID is the value of the X-Couch-Request-ID header.
+	RequestID string
+	// BodyTime is the value of the X-CouchDB-Body-Time header.
+	BodyTime time.Duration
+	// Header holds all of the response headers.
+	Header http.Header
+}
+
+// NewResponseMetadata returns the metadata of a response with the given
+// status and header.
+func NewResponseMetadata(status int, header http.Header) *ResponseMetadata {
+	md := &ResponseMetadata{
+		Status:    status,
+		ETag:      unquote(header.Get("ETag")),
+		RequestID: header.Get("X-Couch-Request-ID"),
+		Header:    header.Clone(),
+	}
+	if ms, err := strconv.ParseInt(header.Get("X-CouchDB-Body-Time"), 10, 64); err == nil {
+		md.BodyTime = time.Duration(ms) * time.Millisecond
+	}
+	return md
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+type responseRecorderKey struct{}
+
+// ResponseRecorder holds the metadata of the most recent response to a
+// request made with a context returned by [WithResponseRecorder].
+type ResponseRecorder struct {
+	mu sync.Mutex
+	md *ResponseMetadata
+}
+
+// Last returns the metadata of the most recent response recorded, or nil if
+// none has been recorded.
+func (r *ResponseRecorder) Last() *ResponseMetadata {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.md
+}
+
+// WithResponseRecorder returns a copy of ctx which carries a new
+// [ResponseRecorder], or ctx itself, if it already carries one.
+func WithResponseRecorder(ctx context.Context) context.Context {
+	if ResponseRecorderFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, responseRecorderKey{}, &ResponseRecorder{})
+}
+
+// ResponseRecorderFrom returns the [ResponseRecorder] carried by ctx, or nil.
+func ResponseRecorderFrom(ctx context.Context) *ResponseRecorder {
+	r, _ := ctx.Value(responseRecorderKey{}).(*ResponseRecorder)
+	return r
+}
+
+// RecordResponse records md as the metadata of the response to a request
+// made with ctx, if the caller has asked for it. Drivers which make HTTP
+// requests should call RecordResponse once for each response received.
+func RecordResponse(ctx context.Context, md *ResponseMetadata) {
+	if r := ResponseRecorderFrom(ctx); r != nil {
+		r.mu.Lock()
+		r.md = md
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,37 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import "context"
+
+// DocShard is the shard which holds a document, as returned by the
+// /{db}/_shards/{docid} endpoint.
+type DocShard struct {
+	// Range is the shard's range of document ID hashes, such as
+	// "e0000000-ffffffff".
+	Range string
+	// Nodes are the nodes which hold a copy of the shard.
+	Nodes []string
+}
+
+// Sharder is an optional interface that may be implemented by a [DB] whose
+// server is a cluster, to report how the database is sharded.
+type Sharder interface {
+	// Shards returns the nodes which hold a copy of each of the database's
+	// shards, keyed by shard range, as returned by the /{db}/_shards
+	// endpoint.
+	Shards(ctx context.Context) (map[string][]string, error)
+	// DocShard returns the shard which holds the document with the given ID,
+	// whether or not the document exists.
+	DocShard(ctx context.Context, docID string) (*DocShard, error)
+}
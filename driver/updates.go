@@ -32,6 +32,15 @@ type DBUpdates interface {
 	Close() error
 }
 
+// LastSeqer is an optional interface that may be implemented by a [DBUpdates]
+// iterator, to report the last_seq value sent by the server at the end of the
+// feed. If not implemented, the sequence of the last update received is used.
+type LastSeqer interface {
+	// LastSeq returns the last update sequence of the feed. It is only called
+	// after Next has returned io.EOF.
+	LastSeq() (string, error)
+}
+
 // DBUpdater is an optional interface that may be implemented by a
 // Client to provide access to the DB Updates feed.
 type DBUpdater interface {
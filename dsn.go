@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces passwords in redacted DSNs.
+const redacted = "xxxxx"
+
+// ParseDSN parses an HTTP(S) data source name, such as one passed to [New]
+// for the CouchDB driver, and returns it in normalized form: the scheme
+// defaults to http, the scheme and host are lower-cased, and the path is
+// never empty.
+func ParseDSN(dsn string) (*url.URL, error) {
+	if !strings.Contains(dsn, "://") {
+		dsn = "http://" + dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if u.Host == "" {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: DSN has no host"}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, nil
+}
+
+// DSNWithCredentials returns dsn with username and password embedded,
+// replacing any credentials it already contains. Special characters in the
+// credentials are escaped as required.
+func DSNWithCredentials(dsn, username, password string) (string, error) {
+	u, err := ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String(), nil
+}
+
+// RedactDSN returns dsn with any password replaced by "xxxxx", so that it
+// may be logged safely. DSNs which are not URLs, such as file paths, are
+// returned unchanged, unless they appear to contain credentials, in which
+// case everything up to the last @ is redacted.
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	if i := strings.LastIndex(dsn, "@"); i >= 0 {
+		return redacted + dsn[i:]
+	}
+	return dsn
+}
+
+// RedactedDSN returns the data source name used to connect this client, with
+// any password redacted, as by [RedactDSN].
+func (c *Client) RedactedDSN() string {
+	return RedactDSN(c.dsn)
+}
@@ -0,0 +1,197 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exportRow is the representation of a row without a document, as written by
+// [ExportNDJSON].
+type exportRow struct {
+	ID    string          `json:"id,omitempty"`
+	Key   json.RawMessage `json:"key,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// exportObject returns the current row of rs as a JSON object: the document,
+// if the row includes one, or else the row's id, key and value, or error.
+func exportObject(rs ResultSet) (json.RawMessage, error) {
+	var doc json.RawMessage
+	err := rs.ScanDoc(&doc)
+	if err == nil {
+		return doc, nil
+	}
+	var row exportRow
+	id, rowErr := rs.ID()
+	if err != errNoDoc && rowErr == nil {
+		return nil, err
+	}
+	row.ID = id
+	if key, _ := rs.Key(); key != "" {
+		row.Key = json.RawMessage(key)
+	}
+	if err := rs.ScanValue(&row.Value); err != nil {
+		row.Error = err.Error()
+	}
+	return json.Marshal(row)
+}
+
+// ExportNDJSON writes the remaining rows of rs to w as newline-delimited JSON,
+// one row per line, and closes rs. Rows which include a document, such as
+// those returned by [DB.Find], or with the include_docs option, are written
+// as the document. Other rows are written as an object with the fields id,
+// key and value, or, for rows which report an error, such as missing keys
+// requested from [DB.AllDocs], id, key and error. Rows are written as they
+// are read, without buffering the result set. The number of rows written is
+// returned.
+func ExportNDJSON(w io.Writer, rs ResultSet) (rows int64, err error) {
+	defer func() {
+		if cerr := rs.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	buf := &bytes.Buffer{}
+	for rs.Next() {
+		obj, err := exportObject(rs)
+		if err != nil {
+			return rows, err
+		}
+		buf.Reset()
+		if err := json.Compact(buf, obj); err != nil {
+			return rows, err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	return rows, rs.Err()
+}
+
+// CSVColumn describes a column written by [ExportCSV].
+type CSVColumn struct {
+	// Header is the column header. If empty, Field is used.
+	Header string
+	// Field is the path of the value within each row's JSON object, as
+	// written by [ExportNDJSON], with the names of nested fields, or the
+	// indexes of array elements, separated by dots, such as "address.city"
+	// or "tags.0".
+	Field string
+	// Format, if set, formats the value of the field, which is nil if the
+	// field is missing. Values are decoded from JSON, with numbers as
+	// [encoding/json.Number]. By default, strings and numbers are written
+	// as-is, missing fields and null as empty cells, and other values as
+	// JSON.
+	Format func(value interface{}) string
+}
+
+// ExportCSV writes the remaining rows of rs to w as CSV, with a header line
+// followed by one line per row, and closes rs. The cells of each line are
+// extracted from the row's JSON object, as written by [ExportNDJSON],
+// according to columns. Rows are written as they are read, without buffering
+// the result set. The number of rows written, not counting the header, is
+// returned.
+func ExportCSV(w io.Writer, rs ResultSet, columns []CSVColumn) (rows int64, err error) {
+	defer func() {
+		if cerr := rs.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	cw := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = col.Header
+		if record[i] == "" {
+			record[i] = col.Field
+		}
+	}
+	if err := cw.Write(record); err != nil {
+		return 0, err
+	}
+	for rs.Next() {
+		obj, err := exportObject(rs)
+		if err != nil {
+			return rows, err
+		}
+		var v interface{}
+		if err := unmarshalJSON(obj, &v, true); err != nil {
+			return rows, err
+		}
+		for i, col := range columns {
+			value := lookupField(v, col.Field)
+			if col.Format != nil {
+				record[i] = col.Format(value)
+			} else {
+				record[i] = formatCSVValue(value)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return rows, err
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return rows, err
+	}
+	return rows, rs.Err()
+}
+
+// lookupField returns the value at the dot-separated path within v, or nil.
+func lookupField(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, name := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[name]
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			v = t[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func formatCSVValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// exportRows returns a result set over the given rows.
+func exportRows(rows ...driver.Row) ResultSet {
+	return newRows(context.Background(), nil, &mock.Rows{
+		NextFunc: func(r *driver.Row) error {
+			if len(rows) == 0 {
+				return io.EOF
+			}
+			*r = rows[0]
+			rows = rows[1:]
+			return nil
+		},
+	})
+}
+
+func testExportRows() []driver.Row {
+	return []driver.Row{
+		{
+			ID:    "cow",
+			Key:   []byte(`"cow"`),
+			Value: strings.NewReader(`{"rev":"1-xxx"}`),
+			Doc:   strings.NewReader(`{"_id":"cow", "name":"Bessie", "legs":4, "tags":["moo","milk"], "address":{"city":"Farmville"}}`),
+		},
+		{
+			ID:    "pig",
+			Key:   []byte(`"pig"`),
+			Value: strings.NewReader(`{"rev":"1-yyy"}`),
+			Doc:   strings.NewReader(`{"_id":"pig","name":"Wilbur, \"some pig\"","legs":4,"weight":120.50}`),
+		},
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	t.Run("docs", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := ExportNDJSON(buf, exportRows(testExportRows()...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("Unexpected row count: %d", n)
+		}
+		want := `{"_id":"cow","name":"Bessie","legs":4,"tags":["moo","milk"],"address":{"city":"Farmville"}}
+{"_id":"pig","name":"Wilbur, \"some pig\"","legs":4,"weight":120.50}
+`
+		if d := testy.DiffText(want, buf.String()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("rows without docs", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := ExportNDJSON(buf, exportRows(
+			driver.Row{ID: "cow", Key: []byte(`"cow"`), Value: strings.NewReader(`{"rev":"1-xxx"}`)},
+			driver.Row{Key: []byte(`"dog"`), Error: &Error{Status: http.StatusNotFound, Message: "not_found"}},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("Unexpected row count: %d", n)
+		}
+		want := `{"id":"cow","key":"cow","value":{"rev":"1-xxx"}}
+{"key":"dog","error":"not_found"}
+`
+		if d := testy.DiffText(want, buf.String()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("iteration error", func(t *testing.T) {
+		rs := newRows(context.Background(), nil, &mock.Rows{
+			NextFunc: func(*driver.Row) error { return errors.New("read failed") },
+		})
+		_, err := ExportNDJSON(&bytes.Buffer{}, rs)
+		testy.Error(t, "read failed", err)
+	})
+}
+
+func TestExportCSV(t *testing.T) {
+	t.Run("docs", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := ExportCSV(buf, exportRows(testExportRows()...), []CSVColumn{
+			{Header: "ID", Field: "_id"},
+			{Field: "name"},
+			{Field: "weight"},
+			{Header: "First tag", Field: "tags.0"},
+			{Field: "address"},
+			{Header: "Legs", Field: "legs", Format: func(v interface{}) string {
+				return fmt.Sprintf("%v legs", v)
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("Unexpected row count: %d", n)
+		}
+		want := `ID,name,weight,First tag,address,Legs
+cow,Bessie,,moo,"{""city"":""Farmville""}",4 legs
+pig,"Wilbur, ""some pig""",120.50,,,4 legs
+`
+		if d := testy.DiffText(want, buf.String()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("rows without docs", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		_, err := ExportCSV(buf, exportRows(
+			driver.Row{ID: "cow", Key: []byte(`["cow",1]`), Value: strings.NewReader(`{"rev":"1-xxx"}`)},
+		), []CSVColumn{{Field: "id"}, {Field: "key.1"}, {Field: "value.rev"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "id,key.1,value.rev\ncow,1,1-xxx\n"
+		if d := testy.DiffText(want, buf.String()); d != nil {
+			t.Error(d)
+		}
+	})
+}
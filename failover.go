@@ -0,0 +1,901 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// DefaultFailoverRecoverAfter is the value used when
+// [FailoverPolicy.RecoverAfter] is unset.
+const DefaultFailoverRecoverAfter = 30 * time.Second
+
+// FailoverPolicy controls how a [Client] connected to several equivalent
+// endpoints, such as the nodes of a CouchDB cluster, moves between them.
+type FailoverPolicy struct {
+	// DSNs are the additional endpoints, in order of preference. The DSN
+	// passed to [New] is always the preferred endpoint.
+	DSNs []string
+
+	// Writes enables failover of operations which modify data. By default,
+	// a write is sent only to the preferred healthy endpoint, and is not
+	// repeated elsewhere if it fails, since it may have been applied before
+	// the failure. PutAttachment is never repeated, as its content can only
+	// be read once.
+	Writes bool
+
+	// RecoverAfter is how long an endpoint which has failed is avoided,
+	// before it is tried again.
+	RecoverAfter time.Duration
+
+	// Failover, if set, replaces the default test for whether an error
+	// indicates that the endpoint is unavailable. By default, network errors,
+	// and errors with a 5xx status other than 501, cause a failover.
+	Failover func(error) bool
+}
+
+// optionFailover is the option key used to pass a [FailoverPolicy] to [New].
+const optionFailover = "kivik:failover"
+
+// WithFailover returns an option which, when passed to [New], connects the
+// client to every DSN in the policy, in addition to the one passed to New.
+// Each operation is sent to the first healthy endpoint. Read operations which
+// fail because the endpoint is unavailable are repeated on the next endpoint,
+// and the failed endpoint is avoided until [FailoverPolicy.RecoverAfter] has
+// elapsed.
+//
+// Only the initial request of operations which return an iterator fails
+// over; errors encountered while iterating are returned to the caller as
+// usual. Options passed to New are passed to the driver for every endpoint.
+func WithFailover(policy FailoverPolicy) Options {
+	return Options{optionFailover: &policy}
+}
+
+func (p *FailoverPolicy) recoverAfter() time.Duration {
+	if p.RecoverAfter <= 0 {
+		return DefaultFailoverRecoverAfter
+	}
+	return p.RecoverAfter
+}
+
+func (p *FailoverPolicy) failover(err error) bool {
+	if p.Failover != nil {
+		return p.Failover(err)
+	}
+	return isUnavailable(err)
+}
+
+// isUnavailable returns true if err suggests that the endpoint which returned
+// it is down, rather than that the request itself was at fault.
+func isUnavailable(e error) bool {
+	if e == nil {
+		return false
+	}
+	if errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded) {
+		return false
+	}
+	var kerr err
+	if errors.As(e, &kerr) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(e, &netErr) {
+		return true
+	}
+	status := HTTPStatus(e)
+	return status >= http.StatusInternalServerError && status != http.StatusNotImplemented
+}
+
+// EndpointStatus describes the health of one endpoint of a client configured
+// with [WithFailover].
+type EndpointStatus struct {
+	// DSN is the endpoint's data source name, with any password redacted.
+	DSN string
+	// Healthy is false while the endpoint is being avoided after a failure.
+	Healthy bool
+	// LastError is the error which caused the most recent failover away from
+	// this endpoint. It is cleared when the endpoint next succeeds.
+	LastError error
+	// FailedAt is the time of the most recent failure.
+	FailedAt time.Time
+}
+
+// Endpoints returns the status of each endpoint of a client configured with
+// [WithFailover], in order of preference. For other clients, it returns nil.
+func (c *Client) Endpoints() []EndpointStatus {
+	f, ok := c.driverClient.(*failoverClient)
+	if !ok {
+		return nil
+	}
+	now := f.now()
+	status := make([]EndpointStatus, len(f.endpoints))
+	for i, ep := range f.endpoints {
+		ep.mu.Lock()
+		status[i] = EndpointStatus{
+			DSN:       RedactDSN(ep.dsn),
+			Healthy:   ep.healthy(now, f.policy.recoverAfter()),
+			LastError: ep.lastErr,
+			FailedAt:  ep.failedAt,
+		}
+		ep.mu.Unlock()
+	}
+	return status
+}
+
+type endpoint struct {
+	dsn    string
+	client driver.Client
+
+	mu       sync.Mutex
+	failedAt time.Time
+	lastErr  error
+}
+
+// healthy must be called with ep.mu held.
+func (ep *endpoint) healthy(now time.Time, recoverAfter time.Duration) bool {
+	return ep.lastErr == nil || now.Sub(ep.failedAt) >= recoverAfter
+}
+
+// endpointClient is the client of a single endpoint, along with its optional
+// features.
+type endpointClient struct {
+	driver.Client
+	*passthrough.ClientFeatures
+}
+
+func newEndpointClient(c driver.Client) endpointClient {
+	return endpointClient{Client: c, ClientFeatures: &passthrough.ClientFeatures{Base: c}}
+}
+
+// endpointDB is the database on a single endpoint, along with its optional
+// features.
+type endpointDB struct {
+	driver.DB
+	*passthrough.DBFeatures
+}
+
+func newEndpointDB(db driver.DB) endpointDB {
+	return endpointDB{DB: db, DBFeatures: &passthrough.DBFeatures{Base: db, Self: db}}
+}
+
+// failoverWrapper is implemented by failoverClient and failoverDB. As they
+// embed the features of the passthrough package, they implement every
+// optional driver interface; wrapped returns the client or database of the
+// primary endpoint, whose interfaces they actually support.
+type failoverWrapper interface {
+	wrapped() interface{}
+}
+
+// optional returns d, or nil if d is a failover wrapper whose endpoints do
+// not implement the optional driver interface to which iface, a nil pointer,
+// points. Optional interfaces are asserted on its result, so that Kivik falls
+// back to its own emulation, or fails, just as it does for the driver of the
+// endpoints.
+func optional(d, iface interface{}) interface{} {
+	if w, ok := d.(failoverWrapper); ok {
+		t := reflect.TypeOf(w.wrapped())
+		if t == nil || !t.Implements(reflect.TypeOf(iface).Elem()) {
+			return nil
+		}
+	}
+	return d
+}
+
+// failoverClient is a driver.Client which distributes calls among several
+// endpoints. Optional features without failover support of their own are
+// passed to the primary endpoint by the embedded ClientFeatures.
+type failoverClient struct {
+	passthrough.ClientFeatures
+	endpoints []*endpoint
+	policy    *FailoverPolicy
+	now       func() time.Time
+}
+
+var _ driver.Client = &failoverClient{}
+
+// newFailoverClient connects to each of the policy's DSNs, and returns a
+// client which fails over from primary to them.
+func newFailoverClient(d driver.Driver, dsn string, primary driver.Client, policy *FailoverPolicy, options map[string]interface{}) (*failoverClient, error) {
+	f := &failoverClient{
+		ClientFeatures: passthrough.ClientFeatures{Base: primary},
+		endpoints:      []*endpoint{{dsn: dsn, client: primary}},
+		policy:         policy,
+		now:            time.Now,
+	}
+	for _, dsn := range policy.DSNs {
+		client, err := d.NewClient(dsn, options)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		f.endpoints = append(f.endpoints, &endpoint{dsn: dsn, client: client})
+	}
+	return f, nil
+}
+
+// order returns the indexes of the endpoints in the order they should be
+// tried: healthy endpoints first, then those recently failed, each in order
+// of preference.
+func (f *failoverClient) order() []int {
+	now := f.now()
+	healthy := make([]int, 0, len(f.endpoints))
+	var failed []int
+	for i, ep := range f.endpoints {
+		ep.mu.Lock()
+		ok := ep.healthy(now, f.policy.recoverAfter())
+		ep.mu.Unlock()
+		if ok {
+			healthy = append(healthy, i)
+		} else {
+			failed = append(failed, i)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+// report updates the health of endpoint i after a call which returned err,
+// and returns true if the call should be repeated on another endpoint.
+func (f *failoverClient) report(i int, err error) bool {
+	ep := f.endpoints[i]
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if !f.policy.failover(err) {
+		ep.lastErr = nil
+		return false
+	}
+	ep.lastErr = err
+	ep.failedAt = f.now()
+	return true
+}
+
+// do calls fn with the index of each endpoint in turn, until it succeeds or
+// returns an error which does not call for failover. Unless retry is true,
+// fn is only called once.
+func (f *failoverClient) do(ctx context.Context, retry bool, fn func(i int) error) error {
+	var err error
+	for _, i := range f.order() {
+		err = fn(i)
+		if !f.report(i, err) || !retry || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (f *failoverClient) read(ctx context.Context, fn func(endpointClient) error) error {
+	return f.do(ctx, true, func(i int) error {
+		return fn(newEndpointClient(f.endpoints[i].client))
+	})
+}
+
+func (f *failoverClient) write(ctx context.Context, fn func(endpointClient) error) error {
+	return f.do(ctx, f.policy.Writes, func(i int) error {
+		return fn(newEndpointClient(f.endpoints[i].client))
+	})
+}
+
+func (f *failoverClient) Version(ctx context.Context) (version *driver.Version, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		version, err = c.Version(ctx)
+		return err
+	})
+	return version, err
+}
+
+func (f *failoverClient) AllDBs(ctx context.Context, options map[string]interface{}) (dbs []string, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		dbs, err = c.AllDBs(ctx, options)
+		return err
+	})
+	return dbs, err
+}
+
+func (f *failoverClient) DBExists(ctx context.Context, dbName string, options map[string]interface{}) (exists bool, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		exists, err = c.DBExists(ctx, dbName, options)
+		return err
+	})
+	return exists, err
+}
+
+func (f *failoverClient) CreateDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return f.write(ctx, func(c endpointClient) error {
+		return c.CreateDB(ctx, dbName, options)
+	})
+}
+
+func (f *failoverClient) DestroyDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return f.write(ctx, func(c endpointClient) error {
+		return c.DestroyDB(ctx, dbName, options)
+	})
+}
+
+func (f *failoverClient) DB(dbName string, options map[string]interface{}) (driver.DB, error) {
+	dbs := make([]driver.DB, len(f.endpoints))
+	for i, ep := range f.endpoints {
+		db, err := ep.client.DB(dbName, options)
+		if err != nil {
+			return nil, err
+		}
+		dbs[i] = db
+	}
+	d := &failoverDB{client: f, dbs: dbs}
+	d.DBFeatures = passthrough.DBFeatures{Base: dbs[0], Self: d}
+	return d, nil
+}
+
+func (f *failoverClient) wrapped() interface{} {
+	return f.endpoints[0].client
+}
+
+func (f *failoverClient) DBsStats(ctx context.Context, dbNames []string) (stats []*driver.DBStats, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		stats, err = c.DBsStats(ctx, dbNames)
+		return err
+	})
+	return stats, err
+}
+
+func (f *failoverClient) Replicate(ctx context.Context, targetDSN, sourceDSN string, options map[string]interface{}) (rep driver.Replication, err error) {
+	err = f.write(ctx, func(c endpointClient) (err error) {
+		rep, err = c.Replicate(ctx, targetDSN, sourceDSN, options)
+		return err
+	})
+	return rep, err
+}
+
+func (f *failoverClient) GetReplications(ctx context.Context, options map[string]interface{}) (reps []driver.Replication, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		reps, err = c.GetReplications(ctx, options)
+		return err
+	})
+	return reps, err
+}
+
+// Authenticate authenticates every endpoint. Endpoints which are unavailable
+// are tolerated, as long as at least one endpoint succeeds.
+func (f *failoverClient) Authenticate(ctx context.Context, authenticator interface{}) error {
+	var lastErr error
+	ok := false
+	for i, ep := range f.endpoints {
+		err := newEndpointClient(ep.client).Authenticate(ctx, authenticator)
+		if f.report(i, err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		ok = true
+	}
+	if ok {
+		return nil
+	}
+	return lastErr
+}
+
+func (f *failoverClient) Ping(ctx context.Context) (up bool, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		up, err = c.Ping(ctx)
+		return err
+	})
+	return up, err
+}
+
+func (f *failoverClient) ClusterStatus(ctx context.Context, options map[string]interface{}) (status string, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		status, err = c.ClusterStatus(ctx, options)
+		return err
+	})
+	return status, err
+}
+
+func (f *failoverClient) ClusterSetup(ctx context.Context, action interface{}) error {
+	return f.write(ctx, func(c endpointClient) error {
+		return c.ClusterSetup(ctx, action)
+	})
+}
+
+func (f *failoverClient) Membership(ctx context.Context) (membership *driver.ClusterMembership, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		membership, err = c.Membership(ctx)
+		return err
+	})
+	return membership, err
+}
+
+// Close closes every endpoint, and returns the first error encountered.
+func (f *failoverClient) Close() error {
+	var firstErr error
+	for _, ep := range f.endpoints {
+		if err := newEndpointClient(ep.client).Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f *failoverClient) DBUpdates(ctx context.Context, options map[string]interface{}) (updates driver.DBUpdates, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		updates, err = c.DBUpdates(ctx, options)
+		return err
+	})
+	return updates, err
+}
+
+func (f *failoverClient) Config(ctx context.Context, node string) (config driver.Config, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		config, err = c.Config(ctx, node)
+		return err
+	})
+	return config, err
+}
+
+func (f *failoverClient) ConfigSection(ctx context.Context, node, section string) (config driver.ConfigSection, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		config, err = c.ConfigSection(ctx, node, section)
+		return err
+	})
+	return config, err
+}
+
+func (f *failoverClient) ConfigValue(ctx context.Context, node, section, key string) (value string, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		value, err = c.ConfigValue(ctx, node, section, key)
+		return err
+	})
+	return value, err
+}
+
+func (f *failoverClient) SetConfigValue(ctx context.Context, node, section, key, value string) (old string, err error) {
+	err = f.write(ctx, func(c endpointClient) (err error) {
+		old, err = c.SetConfigValue(ctx, node, section, key, value)
+		return err
+	})
+	return old, err
+}
+
+func (f *failoverClient) DeleteConfigKey(ctx context.Context, node, section, key string) (old string, err error) {
+	err = f.write(ctx, func(c endpointClient) (err error) {
+		old, err = c.DeleteConfigKey(ctx, node, section, key)
+		return err
+	})
+	return old, err
+}
+
+func (f *failoverClient) Session(ctx context.Context) (session *driver.Session, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		session, err = c.Session(ctx)
+		return err
+	})
+	return session, err
+}
+
+func (f *failoverClient) UUIDs(ctx context.Context, count int) (uuids []string, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		uuids, err = c.UUIDs(ctx, count)
+		return err
+	})
+	return uuids, err
+}
+
+func (f *failoverClient) NodeStats(ctx context.Context, node string) (stats json.RawMessage, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		stats, err = c.NodeStats(ctx, node)
+		return err
+	})
+	return stats, err
+}
+
+func (f *failoverClient) NodeSystem(ctx context.Context, node string) (system json.RawMessage, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		system, err = c.NodeSystem(ctx, node)
+		return err
+	})
+	return system, err
+}
+
+func (f *failoverClient) ActiveTasks(ctx context.Context) (tasks json.RawMessage, err error) {
+	err = f.read(ctx, func(c endpointClient) (err error) {
+		tasks, err = c.ActiveTasks(ctx)
+		return err
+	})
+	return tasks, err
+}
+
+// PoolStats returns the combined statistics of the connection pools of every
+// endpoint, or nil if the driver does not report any.
+func (f *failoverClient) PoolStats() *driver.PoolStats {
+	var total *driver.PoolStats
+	unlimited := false
+	for _, ep := range f.endpoints {
+		pool := newEndpointClient(ep.client).PoolStats()
+		if pool == nil {
+			continue
+		}
+		if total == nil {
+			total = &driver.PoolStats{}
+		}
+		unlimited = unlimited || pool.MaxOpenConnections == 0
+		total.MaxOpenConnections += pool.MaxOpenConnections
+		total.OpenConnections += pool.OpenConnections
+		total.InUse += pool.InUse
+		total.Idle += pool.Idle
+		total.WaitCount += pool.WaitCount
+		total.WaitDuration += pool.WaitDuration
+	}
+	if unlimited {
+		total.MaxOpenConnections = 0
+	}
+	return total
+}
+
+// failoverDB is the driver.DB returned by failoverClient. It holds a handle
+// to the database on every endpoint. Optional features without failover
+// support of their own are passed to the primary endpoint by the embedded
+// DBFeatures.
+type failoverDB struct {
+	passthrough.DBFeatures
+	client *failoverClient
+	dbs    []driver.DB
+}
+
+var _ driver.DB = &failoverDB{}
+
+func (d *failoverDB) wrapped() interface{} {
+	return d.dbs[0]
+}
+
+func (d *failoverDB) read(ctx context.Context, fn func(endpointDB) error) error {
+	return d.client.do(ctx, true, func(i int) error {
+		return fn(newEndpointDB(d.dbs[i]))
+	})
+}
+
+func (d *failoverDB) write(ctx context.Context, fn func(endpointDB) error) error {
+	return d.client.do(ctx, d.client.policy.Writes, func(i int) error {
+		return fn(newEndpointDB(d.dbs[i]))
+	})
+}
+
+func (d *failoverDB) AllDocs(ctx context.Context, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.AllDocs(ctx, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) Get(ctx context.Context, docID string, options map[string]interface{}) (doc *driver.Document, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		doc, err = db.Get(ctx, docID, options)
+		return err
+	})
+	return doc, err
+}
+
+func (d *failoverDB) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (docID, rev string, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		docID, rev, err = db.CreateDoc(ctx, doc, options)
+		return err
+	})
+	return docID, rev, err
+}
+
+func (d *failoverDB) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (rev string, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		rev, err = db.Put(ctx, docID, doc, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) Delete(ctx context.Context, docID string, options map[string]interface{}) (rev string, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		rev, err = db.Delete(ctx, docID, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) Stats(ctx context.Context) (stats *driver.DBStats, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		stats, err = db.Stats(ctx)
+		return err
+	})
+	return stats, err
+}
+
+func (d *failoverDB) Compact(ctx context.Context) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.Compact(ctx)
+	})
+}
+
+func (d *failoverDB) CompactView(ctx context.Context, ddocID string) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.CompactView(ctx, ddocID)
+	})
+}
+
+func (d *failoverDB) ViewCleanup(ctx context.Context) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.ViewCleanup(ctx)
+	})
+}
+
+func (d *failoverDB) Security(ctx context.Context) (security *driver.Security, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		security, err = db.Security(ctx)
+		return err
+	})
+	return security, err
+}
+
+func (d *failoverDB) SetSecurity(ctx context.Context, security *driver.Security) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.SetSecurity(ctx, security)
+	})
+}
+
+func (d *failoverDB) Changes(ctx context.Context, options map[string]interface{}) (changes driver.Changes, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		changes, err = db.Changes(ctx, options)
+		return err
+	})
+	return changes, err
+}
+
+// PutAttachment is never repeated on another endpoint, since the attachment
+// content may already have been consumed.
+func (d *failoverDB) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (rev string, err error) {
+	err = d.client.do(ctx, false, func(i int) (err error) {
+		rev, err = d.dbs[i].PutAttachment(ctx, docID, att, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (att *driver.Attachment, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		att, err = db.GetAttachment(ctx, docID, filename, options)
+		return err
+	})
+	return att, err
+}
+
+func (d *failoverDB) DeleteAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (rev string, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		rev, err = db.DeleteAttachment(ctx, docID, filename, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.Query(ctx, ddoc, view, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) Purge(ctx context.Context, docRevMap map[string][]string) (result *driver.PurgeResult, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		result, err = db.Purge(ctx, docRevMap)
+		return err
+	})
+	return result, err
+}
+
+func (d *failoverDB) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) (results []driver.BulkResult, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		results, err = db.BulkDocs(ctx, docs, options)
+		return err
+	})
+	return results, err
+}
+
+func (d *failoverDB) Find(ctx context.Context, query interface{}, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.Find(ctx, query, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, options map[string]interface{}) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.CreateIndex(ctx, ddoc, name, index, options)
+	})
+}
+
+func (d *failoverDB) GetIndexes(ctx context.Context, options map[string]interface{}) (indexes []driver.Index, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		indexes, err = db.GetIndexes(ctx, options)
+		return err
+	})
+	return indexes, err
+}
+
+func (d *failoverDB) DeleteIndex(ctx context.Context, ddoc, name string, options map[string]interface{}) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.DeleteIndex(ctx, ddoc, name, options)
+	})
+}
+
+func (d *failoverDB) Explain(ctx context.Context, query interface{}, options map[string]interface{}) (plan *driver.QueryPlan, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		plan, err = db.Explain(ctx, query, options)
+		return err
+	})
+	return plan, err
+}
+
+func (d *failoverDB) GetAttachmentMeta(ctx context.Context, docID, filename string, options map[string]interface{}) (att *driver.Attachment, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		att, err = db.GetAttachmentMeta(ctx, docID, filename, options)
+		return err
+	})
+	return att, err
+}
+
+func (d *failoverDB) GetRev(ctx context.Context, docID string, options map[string]interface{}) (rev string, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rev, err = db.GetRev(ctx, docID, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) Flush(ctx context.Context) error {
+	return d.write(ctx, func(db endpointDB) error {
+		return db.Flush(ctx)
+	})
+}
+
+func (d *failoverDB) Copy(ctx context.Context, targetID, sourceID string, options map[string]interface{}) (rev string, err error) {
+	err = d.write(ctx, func(db endpointDB) (err error) {
+		rev, err = db.Copy(ctx, targetID, sourceID, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *failoverDB) DesignDocs(ctx context.Context, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.DesignDocs(ctx, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) LocalDocs(ctx context.Context, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.LocalDocs(ctx, options)
+		return err
+	})
+	return rows, err
+}
+
+// Close closes the database on every endpoint, and returns the first error
+// encountered.
+func (d *failoverDB) Close() error {
+	var firstErr error
+	for _, db := range d.dbs {
+		if err := newEndpointDB(db).Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (d *failoverDB) RevsDiff(ctx context.Context, revMap interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.RevsDiff(ctx, revMap)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) BulkGet(ctx context.Context, docs []driver.BulkGetReference, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.BulkGet(ctx, docs, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) PartitionStats(ctx context.Context, name string) (stats *driver.PartitionStats, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		stats, err = db.PartitionStats(ctx, name)
+		return err
+	})
+	return stats, err
+}
+
+func (d *failoverDB) Search(ctx context.Context, ddoc, index, query string, options map[string]interface{}) (rows driver.Rows, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		rows, err = db.Search(ctx, ddoc, index, query, options)
+		return err
+	})
+	return rows, err
+}
+
+func (d *failoverDB) SearchInfo(ctx context.Context, ddoc, index string) (info *driver.SearchInfo, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		info, err = db.SearchInfo(ctx, ddoc, index)
+		return err
+	})
+	return info, err
+}
+
+func (d *failoverDB) SearchAnalyze(ctx context.Context, text string) (tokens []string, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		tokens, err = db.SearchAnalyze(ctx, text)
+		return err
+	})
+	return tokens, err
+}
+
+// BulkDocsStream streams the documents to a single endpoint, since docs
+// cannot be read twice.
+func (d *failoverDB) BulkDocsStream(ctx context.Context, docs driver.DocSource, options map[string]interface{}) (results []driver.BulkResult, err error) {
+	err = d.client.do(ctx, false, func(i int) (err error) {
+		results, err = newEndpointDB(d.dbs[i]).BulkDocsStream(ctx, docs, options)
+		return err
+	})
+	return results, err
+}
+
+func (d *failoverDB) Count(ctx context.Context, selector interface{}, options map[string]interface{}) (count int64, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		count, err = db.Count(ctx, selector, options)
+		return err
+	})
+	return count, err
+}
+
+func (d *failoverDB) GetMeta(ctx context.Context, docID string, options map[string]interface{}) (meta *driver.DocMeta, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		meta, err = db.GetMeta(ctx, docID, options)
+		return err
+	})
+	return meta, err
+}
+
+func (d *failoverDB) Shards(ctx context.Context) (shards map[string][]string, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		shards, err = db.Shards(ctx)
+		return err
+	})
+	return shards, err
+}
+
+func (d *failoverDB) DocShard(ctx context.Context, docID string) (shard *driver.DocShard, err error) {
+	err = d.read(ctx, func(db endpointDB) (err error) {
+		shard, err = db.DocShard(ctx, docID)
+		return err
+	})
+	return shard, err
+}
@@ -0,0 +1,436 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "not found", err: &Error{Status: http.StatusNotFound}, want: false},
+		{name: "internal server error", err: &Error{Status: http.StatusInternalServerError}, want: true},
+		{name: "service unavailable", err: &Error{Status: http.StatusServiceUnavailable}, want: true},
+		{name: "not implemented", err: &Error{Status: http.StatusNotImplemented}, want: false},
+		{name: "client closed", err: ErrClientClosed, want: false},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "context canceled", err: context.Canceled, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isUnavailable(test.err); got != test.want {
+				t.Errorf("Unexpected result: %v", got)
+			}
+		})
+	}
+}
+
+// fakeNode is a mock server which fails with err, if set, and counts calls.
+type fakeNode struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (n *fakeNode) client() *mock.Client {
+	return &mock.Client{
+		ID: n.name,
+		VersionFunc: func(context.Context) (*driver.Version, error) {
+			n.calls++
+			if n.err != nil {
+				return nil, n.err
+			}
+			return &driver.Version{Vendor: n.name}, nil
+		},
+		CreateDBFunc: func(context.Context, string, map[string]interface{}) error {
+			n.calls++
+			return n.err
+		},
+		DBFunc: func(string, map[string]interface{}) (driver.DB, error) {
+			return &mock.DB{
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					n.calls++
+					if n.err != nil {
+						return nil, n.err
+					}
+					return &driver.Document{Body: body(`{"_id":"foo","_rev":"1-` + n.name + `"}`)}, nil
+				},
+			}, nil
+		},
+	}
+}
+
+func newFailoverTest(policy FailoverPolicy, nodes ...*fakeNode) (*Client, *time.Time) {
+	now := time.Now()
+	f := &failoverClient{
+		policy: &policy,
+		now:    func() time.Time { return now },
+	}
+	for _, n := range nodes {
+		f.endpoints = append(f.endpoints, &endpoint{dsn: n.name, client: n.client()})
+	}
+	return &Client{driverClient: f}, &now
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}
+
+	t.Run("reads fail over", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b"}
+		c, _ := newFailoverTest(FailoverPolicy{}, a, b)
+		v, err := c.Version(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Vendor != "b" {
+			t.Errorf("Unexpected vendor: %s", v.Vendor)
+		}
+		rev, err := c.DB("foo").GetRev(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-b" {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+		if a.calls != 1 {
+			t.Errorf("Failed endpoint should be avoided, but was called %d times", a.calls)
+		}
+	})
+	t.Run("permanent errors do not fail over", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: &Error{Status: http.StatusNotFound, Message: "missing"}}, &fakeNode{name: "b"}
+		c, _ := newFailoverTest(FailoverPolicy{}, a, b)
+		_, err := c.Version(ctx)
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+		if b.calls != 0 {
+			t.Errorf("Unexpected calls to second endpoint: %d", b.calls)
+		}
+	})
+	t.Run("all endpoints down", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b", err: unavailable}
+		c, _ := newFailoverTest(FailoverPolicy{}, a, b)
+		_, err := c.Version(ctx)
+		testy.StatusError(t, "unavailable", http.StatusServiceUnavailable, err)
+		if a.calls != 1 || b.calls != 1 {
+			t.Errorf("Unexpected calls: a=%d b=%d", a.calls, b.calls)
+		}
+	})
+	t.Run("writes do not fail over by default", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b"}
+		c, _ := newFailoverTest(FailoverPolicy{}, a, b)
+		err := c.CreateDB(ctx, "foo")
+		testy.StatusError(t, "unavailable", http.StatusServiceUnavailable, err)
+		if b.calls != 0 {
+			t.Errorf("Unexpected calls to second endpoint: %d", b.calls)
+		}
+		// The failed endpoint is now avoided, for writes too.
+		if err := c.CreateDB(ctx, "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if b.calls != 1 {
+			t.Errorf("Expected write to go to second endpoint")
+		}
+	})
+	t.Run("writes fail over when enabled", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b"}
+		c, _ := newFailoverTest(FailoverPolicy{Writes: true}, a, b)
+		if err := c.CreateDB(ctx, "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if a.calls != 1 || b.calls != 1 {
+			t.Errorf("Unexpected calls: a=%d b=%d", a.calls, b.calls)
+		}
+	})
+	t.Run("recovery", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b"}
+		c, now := newFailoverTest(FailoverPolicy{RecoverAfter: time.Minute}, a, b)
+		if _, err := c.Version(ctx); err != nil {
+			t.Fatal(err)
+		}
+		status := c.Endpoints()
+		if status[0].Healthy || status[0].LastError != unavailable || !status[1].Healthy {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		a.err = nil
+		*now = now.Add(30 * time.Second)
+		// Discard the cached version, so that each call reaches an endpoint.
+		c.ResetVersion()
+		if v, _ := c.Version(ctx); v.Vendor != "b" {
+			t.Errorf("Endpoint recovered too soon")
+		}
+		*now = now.Add(30 * time.Second)
+		c.ResetVersion()
+		if v, _ := c.Version(ctx); v.Vendor != "a" {
+			t.Errorf("Endpoint did not recover")
+		}
+		if status := c.Endpoints(); !status[0].Healthy || status[0].LastError != nil {
+			t.Errorf("Unexpected status: %+v", status[0])
+		}
+	})
+	t.Run("custom failover test", func(t *testing.T) {
+		a, b := &fakeNode{name: "a", err: &Error{Status: http.StatusUnauthorized}}, &fakeNode{name: "b"}
+		c, _ := newFailoverTest(FailoverPolicy{
+			Failover: func(err error) bool { return HTTPStatus(err) == http.StatusUnauthorized },
+		}, a, b)
+		if v, err := c.Version(ctx); err != nil || v.Vendor != "b" {
+			t.Errorf("Unexpected result: %v, %v", v, err)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a"})
+		err := c.DB("foo").CreateIndex(ctx, "", "", `{}`)
+		testy.StatusError(t, "kivik: driver does not support Find interface", http.StatusNotImplemented, err)
+	})
+}
+
+func TestWithFailover(t *testing.T) {
+	d := &mock.Driver{
+		NewClientFunc: func(dsn string, options map[string]interface{}) (driver.Client, error) {
+			if options["foo"] != "bar" {
+				t.Errorf("Unexpected driver options: %v", options)
+			}
+			if dsn == "bad" {
+				return nil, errors.New("bad DSN")
+			}
+			return (&fakeNode{name: dsn}).client(), nil
+		},
+	}
+	c, err := NewClientFromDriver(d, "a", WithFailover(FailoverPolicy{DSNs: []string{"b", "c"}}), Options{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dsns []string
+	for _, status := range c.Endpoints() {
+		dsns = append(dsns, status.DSN)
+	}
+	if d := testy.DiffInterface([]string{"a", "b", "c"}, dsns); d != nil {
+		t.Error(d)
+	}
+	if c.DSN() != "a" {
+		t.Errorf("Unexpected DSN: %s", c.DSN())
+	}
+	_, err = NewClientFromDriver(d, "a", WithFailover(FailoverPolicy{DSNs: []string{"bad"}}), Options{"foo": "bar"})
+	if err == nil || err.Error() != "bad DSN" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if (&Client{driverClient: &mock.Client{}}).Endpoints() != nil {
+		t.Error("Expected no endpoints for a plain client")
+	}
+}
+
+// optionalInterfaces are the optional interfaces of the driver package,
+// which failoverClient and failoverDB must pass through.
+var optionalInterfaces = struct {
+	client, db map[string]reflect.Type
+}{
+	client: map[string]reflect.Type{
+		"DBsStatser":       reflect.TypeOf((*driver.DBsStatser)(nil)).Elem(),
+		"ClientReplicator": reflect.TypeOf((*driver.ClientReplicator)(nil)).Elem(),
+		"Authenticator":    reflect.TypeOf((*driver.Authenticator)(nil)).Elem(),
+		"Pinger":           reflect.TypeOf((*driver.Pinger)(nil)).Elem(),
+		"UUIDer":           reflect.TypeOf((*driver.UUIDer)(nil)).Elem(),
+		"ActiveTasker":     reflect.TypeOf((*driver.ActiveTasker)(nil)).Elem(),
+		"PoolStatser":      reflect.TypeOf((*driver.PoolStatser)(nil)).Elem(),
+		"Cluster":          reflect.TypeOf((*driver.Cluster)(nil)).Elem(),
+		"ClientCloser":     reflect.TypeOf((*driver.ClientCloser)(nil)).Elem(),
+		"Sessioner":        reflect.TypeOf((*driver.Sessioner)(nil)).Elem(),
+		"DBUpdater":        reflect.TypeOf((*driver.DBUpdater)(nil)).Elem(),
+		"Configer":         reflect.TypeOf((*driver.Configer)(nil)).Elem(),
+		"NodeInspector":    reflect.TypeOf((*driver.NodeInspector)(nil)).Elem(),
+	},
+	db: map[string]reflect.Type{
+		"Purger":               reflect.TypeOf((*driver.Purger)(nil)).Elem(),
+		"BulkDocer":            reflect.TypeOf((*driver.BulkDocer)(nil)).Elem(),
+		"BulkDocsStreamer":     reflect.TypeOf((*driver.BulkDocsStreamer)(nil)).Elem(),
+		"Counter":              reflect.TypeOf((*driver.Counter)(nil)).Elem(),
+		"Finder":               reflect.TypeOf((*driver.Finder)(nil)).Elem(),
+		"AttachmentMetaGetter": reflect.TypeOf((*driver.AttachmentMetaGetter)(nil)).Elem(),
+		"RevGetter":            reflect.TypeOf((*driver.RevGetter)(nil)).Elem(),
+		"MetaGetter":           reflect.TypeOf((*driver.MetaGetter)(nil)).Elem(),
+		"Flusher":              reflect.TypeOf((*driver.Flusher)(nil)).Elem(),
+		"Copier":               reflect.TypeOf((*driver.Copier)(nil)).Elem(),
+		"DesignDocer":          reflect.TypeOf((*driver.DesignDocer)(nil)).Elem(),
+		"LocalDocer":           reflect.TypeOf((*driver.LocalDocer)(nil)).Elem(),
+		"DBCloser":             reflect.TypeOf((*driver.DBCloser)(nil)).Elem(),
+		"RevsDiffer":           reflect.TypeOf((*driver.RevsDiffer)(nil)).Elem(),
+		"BulkGetter":           reflect.TypeOf((*driver.BulkGetter)(nil)).Elem(),
+		"PartitionedDB":        reflect.TypeOf((*driver.PartitionedDB)(nil)).Elem(),
+		"Searcher":             reflect.TypeOf((*driver.Searcher)(nil)).Elem(),
+		"Sharder":              reflect.TypeOf((*driver.Sharder)(nil)).Elem(),
+	},
+}
+
+func TestFailoverOptionalInterfaces(t *testing.T) {
+	// Interfaces of the driver package which are not optional features of a
+	// Client or DB.
+	other := map[string]bool{
+		"Driver": true, "Client": true, "DB": true, "Rows": true,
+		"RowsWarner": true, "Bookmarker": true, "Changes": true,
+		"Attachments": true, "Replication": true, "DBUpdates": true,
+		"LastSeqer": true, "DocSource": true,
+	}
+	pkgs, err := parser.ParseDir(token.NewFileSet(), "driver", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkgs["driver"].Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if _, ok := ts.Type.(*ast.InterfaceType); !ok || other[ts.Name.Name] {
+					continue
+				}
+				if optionalInterfaces.client[ts.Name.Name] == nil && optionalInterfaces.db[ts.Name.Name] == nil {
+					t.Errorf("driver.%s is not passed through by failover", ts.Name.Name)
+				}
+			}
+		}
+	}
+
+	c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a"})
+	for name, typ := range optionalInterfaces.client {
+		if !reflect.TypeOf(c.driverClient).Implements(typ) {
+			t.Errorf("failover client does not implement driver.%s", name)
+		}
+	}
+	db := c.DB("foo")
+	for name, typ := range optionalInterfaces.db {
+		if !reflect.TypeOf(db.driverDB).Implements(typ) {
+			t.Errorf("failover db does not implement driver.%s", name)
+		}
+	}
+}
+
+func TestFailoverOptionalFeatures(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}
+
+	t.Run("search fails over", func(t *testing.T) {
+		searcher := func(name string, err error) driver.DB {
+			return &mock.Searcher{
+				DB: &mock.DB{ID: name},
+				SearchFunc: func(context.Context, string, string, string, map[string]interface{}) (driver.Rows, error) {
+					if err != nil {
+						return nil, err
+					}
+					return &mock.Rows{ID: name}, nil
+				},
+			}
+		}
+		f := &failoverClient{policy: &FailoverPolicy{}, now: time.Now}
+		f.endpoints = []*endpoint{{dsn: "a"}, {dsn: "b"}}
+		fdb := &failoverDB{client: f, dbs: []driver.DB{searcher("a", unavailable), searcher("b", nil)}}
+		rows, err := fdb.Search(ctx, "ddoc", "index", "q", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := rows.(*mock.Rows).ID; id != "b" {
+			t.Errorf("Unexpected endpoint: %s", id)
+		}
+	})
+	t.Run("uuids fall back to local generation", func(t *testing.T) {
+		c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a"})
+		uuids, err := c.UUIDs(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uuids) != 2 {
+			t.Errorf("Unexpected UUIDs: %v", uuids)
+		}
+	})
+	t.Run("meta falls back to get", func(t *testing.T) {
+		c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a", err: unavailable}, &fakeNode{name: "b"})
+		meta, err := c.DB("foo").GetMeta(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Rev != "1-b" {
+			t.Errorf("Unexpected rev: %s", meta.Rev)
+		}
+	})
+	t.Run("pool stats are combined", func(t *testing.T) {
+		pool := func(inUse int) driver.Client {
+			return &mock.PoolStatser{
+				Client: &mock.Client{},
+				PoolStatsFunc: func() *driver.PoolStats {
+					return &driver.PoolStats{MaxOpenConnections: 10, InUse: inUse}
+				},
+			}
+		}
+		f := &failoverClient{policy: &FailoverPolicy{}, now: time.Now}
+		f.endpoints = []*endpoint{{dsn: "a", client: pool(1)}, {dsn: "b", client: pool(2)}}
+		want := &driver.PoolStats{MaxOpenConnections: 20, InUse: 3}
+		if d := testy.DiffInterface(want, f.PoolStats()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("only the endpoints' interfaces are used", func(t *testing.T) {
+		c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a"})
+		if optional(c.driverClient, (*driver.Pinger)(nil)) != nil {
+			t.Error("Pinger should not be used, as the endpoints do not implement it")
+		}
+		if optional(c.DB("foo").driverDB, (*driver.RevGetter)(nil)) != nil {
+			t.Error("RevGetter should not be used, as the endpoints do not implement it")
+		}
+		f := &failoverClient{policy: &FailoverPolicy{}, now: time.Now}
+		f.endpoints = []*endpoint{{dsn: "a"}}
+		fdb := &failoverDB{client: f, dbs: []driver.DB{&mock.RevGetter{
+			GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+				return "1-native", nil
+			},
+		}}}
+		rev, err := (&DB{client: &Client{}, driverDB: fdb}).GetRev(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-native" {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+	t.Run("shards not supported", func(t *testing.T) {
+		c, _ := newFailoverTest(FailoverPolicy{}, &fakeNode{name: "a"})
+		_, err := c.DB("foo").Shards(ctx)
+		testy.StatusError(t, "kivik: driver does not support shard inspection", http.StatusNotImplemented, err)
+	})
+}
@@ -28,16 +28,24 @@ func (db *DB) Find(ctx context.Context, query interface{}, options ...Options) R
 	if db.err != nil {
 		return &errRS{err: db.err}
 	}
-	if finder, ok := db.driverDB.(driver.Finder); ok {
+	if finder, ok := optional(db.driverDB, (*driver.Finder)(nil)).(driver.Finder); ok {
 		if err := db.startQuery(); err != nil {
 			return &errRS{err: err}
 		}
-		rowsi, err := finder.Find(ctx, query, mergeOptions(options...))
+		var rowsi driver.Rows
+		opts := mergeOptions(options...)
+		op := &Operation{Method: "Find", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+		err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+			rowsi, err = finder.Find(ctx, query, opts)
+			return err
+		})
 		if err != nil {
 			db.endQuery()
 			return &errRS{err: err}
 		}
-		return newRows(ctx, db.endQuery, rowsi)
+		it := newRows(ctx, db.endQuery, rowsi)
+		db.trackIterator(op, it.iter)
+		return it
 	}
 	return &errRS{err: findNotImplemented}
 }
@@ -54,8 +62,11 @@ func (db *DB) CreateIndex(ctx context.Context, ddoc, name string, index interfac
 		return err
 	}
 	defer db.endQuery()
-	if finder, ok := db.driverDB.(driver.Finder); ok {
-		return finder.CreateIndex(ctx, ddoc, name, index, mergeOptions(options...))
+	if finder, ok := optional(db.driverDB, (*driver.Finder)(nil)).(driver.Finder); ok {
+		opts := mergeOptions(options...)
+		return db.client.invoke(ctx, &Operation{Method: "CreateIndex", DB: db.name, Options: opts}, func(ctx context.Context) error {
+			return finder.CreateIndex(ctx, ddoc, name, index, opts)
+		})
 	}
 	return findNotImplemented
 }
@@ -69,8 +80,11 @@ func (db *DB) DeleteIndex(ctx context.Context, ddoc, name string, options ...Opt
 		return err
 	}
 	defer db.endQuery()
-	if finder, ok := db.driverDB.(driver.Finder); ok {
-		return finder.DeleteIndex(ctx, ddoc, name, mergeOptions(options...))
+	if finder, ok := optional(db.driverDB, (*driver.Finder)(nil)).(driver.Finder); ok {
+		opts := mergeOptions(options...)
+		return db.client.invoke(ctx, &Operation{Method: "DeleteIndex", DB: db.name, Options: opts}, func(ctx context.Context) error {
+			return finder.DeleteIndex(ctx, ddoc, name, opts)
+		})
 	}
 	return findNotImplemented
 }
@@ -92,8 +106,13 @@ func (db *DB) GetIndexes(ctx context.Context, options ...Options) ([]Index, erro
 		return nil, err
 	}
 	defer db.endQuery()
-	if finder, ok := db.driverDB.(driver.Finder); ok {
-		dIndexes, err := finder.GetIndexes(ctx, mergeOptions(options...))
+	if finder, ok := optional(db.driverDB, (*driver.Finder)(nil)).(driver.Finder); ok {
+		var dIndexes []driver.Index
+		opts := mergeOptions(options...)
+		err := db.client.invoke(ctx, &Operation{Method: "GetIndexes", DB: db.name, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			dIndexes, err = finder.GetIndexes(ctx, opts)
+			return err
+		})
 		indexes := make([]Index, len(dIndexes))
 		for i, index := range dIndexes {
 			indexes[i] = Index(index)
@@ -129,8 +148,13 @@ func (db *DB) Explain(ctx context.Context, query interface{}, options ...Options
 		return nil, err
 	}
 	defer db.endQuery()
-	if explainer, ok := db.driverDB.(driver.Finder); ok {
-		plan, err := explainer.Explain(ctx, query, mergeOptions(options...))
+	if explainer, ok := optional(db.driverDB, (*driver.Finder)(nil)).(driver.Finder); ok {
+		var plan *driver.QueryPlan
+		opts := mergeOptions(options...)
+		err := db.client.invoke(ctx, &Operation{Method: "Explain", DB: db.name, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			plan, err = explainer.Explain(ctx, query, opts)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -139,3 +163,40 @@ func (db *DB) Explain(ctx context.Context, query interface{}, options ...Options
 	}
 	return nil, findNotImplemented
 }
+
+// Count returns the number of documents matching the Mango selector, which
+// may be any value accepted as the selector of a [DB.Find] query, such as a
+// map or a JSON string. Design documents are not counted.
+//
+// If the driver implements [driver.Counter], the documents are counted by the
+// backend. Otherwise, a Find query is made, which returns only the ID of each
+// matching document, and the results are counted.
+func (db *DB) Count(ctx context.Context, selector interface{}, options ...Options) (int64, error) {
+	if db.err != nil {
+		return 0, db.err
+	}
+	query, err := selectorQuery(selector, "_id")
+	if err != nil {
+		return 0, err
+	}
+	opts := mergeOptions(options...)
+	if counter, ok := optional(db.driverDB, (*driver.Counter)(nil)).(driver.Counter); ok {
+		if err := db.startQuery(); err != nil {
+			return 0, err
+		}
+		defer db.endQuery()
+		var count int64
+		err := db.client.invoke(ctx, &Operation{Method: "Count", DB: db.name, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+			count, err = counter.Count(ctx, query["selector"], opts)
+			return err
+		})
+		return count, err
+	}
+	rs := db.Find(ctx, query, opts)
+	defer rs.Close() // nolint:errcheck
+	var count int64
+	for rs.Next() {
+		count++
+	}
+	return count, rs.Err()
+}
@@ -464,3 +464,64 @@ func TestExplain(t *testing.T) {
 		}
 	})
 }
+
+func TestCount(t *testing.T) {
+	t.Run("counter", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.Counter{
+				CountFunc: func(_ context.Context, selector interface{}, _ map[string]interface{}) (int64, error) {
+					if d := testy.DiffAsJSON([]byte(`{"type":"cow"}`), selector); d != nil {
+						t.Error(d)
+					}
+					return 42, nil
+				},
+			},
+		}
+		count, err := db.Count(context.Background(), `{"type":"cow"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 42 {
+			t.Errorf("Unexpected count: %d", count)
+		}
+	})
+	t.Run("find fallback", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.Finder{
+				FindFunc: func(_ context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+					want := `{"selector":{"type":"cow"},"fields":["_id"],"limit":2147483647}`
+					if d := testy.DiffAsJSON([]byte(want), query); d != nil {
+						t.Error(d)
+					}
+					return mock.NewRowsFeed(mock.Row{ID: "a"}, mock.Row{ID: "b"}).Rows(), nil
+				},
+			},
+		}
+		count, err := db.Count(context.Background(), map[string]interface{}{"type": "cow"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("Unexpected count: %d", count)
+		}
+	})
+	t.Run("counter error", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.Counter{
+				CountFunc: func(context.Context, interface{}, map[string]interface{}) (int64, error) {
+					return 0, &Error{Status: http.StatusBadRequest, Message: "bad selector"}
+				},
+			},
+		}
+		_, err := db.Count(context.Background(), `{}`)
+		testy.StatusError(t, "bad selector", http.StatusBadRequest, err)
+	})
+	t.Run("not supported", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.DB{}}
+		_, err := db.Count(context.Background(), `{}`)
+		testy.StatusError(t, "kivik: driver does not support Find interface", http.StatusNotImplemented, err)
+	})
+}
@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// RawDocument is the unparsed body of a document, as returned by
+// [DB.GetRaw].
+type RawDocument struct {
+	// Body is the document body, exactly as returned by the driver. It must
+	// be closed by the caller.
+	Body io.ReadCloser
+	// Rev is the revision of the document.
+	Rev string
+	// Header holds the response headers, for drivers which report them with
+	// [driver.RecordResponse], or else is nil.
+	Header http.Header
+}
+
+// GetRaw fetches the requested document, as for [DB.Get], but returns the
+// body without parsing it, for callers which pass documents on unchanged,
+// such as to an HTTP client. GetRaw accepts the same options as [DB.Get].
+//
+// The body is not decoded by any [Codec] configured with [WithCodec]. When the
+// attachments option is used, attachments returned separately from the body by
+// the driver are discarded; use [DB.Get] to read them.
+func (db *DB) GetRaw(ctx context.Context, docID string, options ...Options) (*RawDocument, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	ctx = driver.WithResponseRecorder(ctx)
+	recorder := driver.ResponseRecorderFrom(ctx)
+	prev := recorder.Last()
+	doc, err := db.get(ctx, "GetRaw", docID, mergeOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	if doc.Attachments != nil {
+		_ = doc.Attachments.Close()
+	}
+	raw := &RawDocument{
+		Body: doc.Body,
+		Rev:  doc.Rev,
+	}
+	if md := recorder.Last(); md != nil && md != prev {
+		raw.Header = md.Header
+	}
+	return raw, nil
+}
@@ -0,0 +1,137 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestGetRaw(t *testing.T) {
+	type tt struct {
+		db         *DB
+		docID      string
+		options    Options
+		wantBody   string
+		wantRev    string
+		wantHeader http.Header
+		status     int
+		err        string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db: &DB{
+			client: &Client{},
+			err:    errors.New("db error"),
+		},
+		docID:  "foo",
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("missing doc ID", tt{
+		db: &DB{
+			client: &Client{},
+		},
+		status: http.StatusBadRequest,
+		err:    "kivik: docID required",
+	})
+	tests.Add("driver error", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+				},
+			},
+		},
+		docID:  "foo",
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("success", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+					if docID != "foo" {
+						return nil, errors.New("unexpected doc ID")
+					}
+					if d := testy.DiffInterface(map[string]interface{}{"revs": true}, opts); d != nil {
+						return nil, errors.New(d.String())
+					}
+					return &driver.Document{
+						Rev:  "1-xxx",
+						Body: io.NopCloser(strings.NewReader(`{"_id":"foo","_rev":"1-xxx","n":1.50}`)),
+					}, nil
+				},
+			},
+		},
+		docID:    "foo",
+		options:  Options{"revs": true},
+		wantBody: `{"_id":"foo","_rev":"1-xxx","n":1.50}`,
+		wantRev:  "1-xxx",
+	})
+	tests.Add("with headers", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				GetFunc: func(ctx context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+					driver.RecordResponse(ctx, driver.NewResponseMetadata(http.StatusOK, http.Header{
+						"Content-Type": {"application/json"},
+						"Etag":         {`"1-xxx"`},
+					}))
+					return &driver.Document{
+						Rev:  "1-xxx",
+						Body: io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		},
+		docID:    "foo",
+		wantBody: `{}`,
+		wantRev:  "1-xxx",
+		wantHeader: http.Header{
+			"Content-Type": {"application/json"},
+			"Etag":         {`"1-xxx"`},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		raw, err := tt.db.GetRaw(context.Background(), tt.docID, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		defer raw.Body.Close() // nolint:errcheck
+		body, err := io.ReadAll(raw.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tt.wantBody {
+			t.Errorf("Unexpected body: %s", body)
+		}
+		if raw.Rev != tt.wantRev {
+			t.Errorf("Unexpected rev: %s", raw.Rev)
+		}
+		if d := testy.DiffInterface(tt.wantHeader, raw.Header); d != nil {
+			t.Error(d)
+		}
+	})
+}
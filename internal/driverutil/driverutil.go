@@ -0,0 +1,160 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package driverutil provides helpers shared by the drivers bundled with
+// Kivik which store documents themselves: memorydb, fsdb and sqlite.
+package driverutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+// validDBName matches valid CouchDB database names.
+var validDBName = regexp.MustCompile(`^[a-z][a-z0-9_$()+/-]*$`)
+
+// ValidDBName reports whether name is a valid CouchDB database name.
+func ValidDBName(name string) bool {
+	return validDBName.MatchString(name)
+}
+
+// NewDocID returns a random document ID, in the format of CouchDB's default
+// UUIDs: 32 hex characters.
+func NewDocID() string {
+	return strings.Replace(kivik.UUIDv4(), "-", "", -1)
+}
+
+// StringOption returns the named option if it is a string, or "".
+func StringOption(options map[string]interface{}, name string) string {
+	s, _ := options[name].(string)
+	return s
+}
+
+// BoolOption returns the named option as a bool. The value may be a bool, or
+// a string accepted by [strconv.ParseBool].
+func BoolOption(options map[string]interface{}, name string) bool {
+	switch t := options[name].(type) {
+	case bool:
+		return t
+	case string:
+		b, _ := strconv.ParseBool(t)
+		return b
+	}
+	return false
+}
+
+// IntOption returns the named option as an int64, and whether it was set to
+// a number, or a string holding one.
+func IntOption(options map[string]interface{}, name string) (int64, bool) {
+	switch t := options[name].(type) {
+	case int:
+		return int64(t), true
+	case int64:
+		return t, true
+	case float64:
+		return int64(t), true
+	case string:
+		i, err := strconv.ParseInt(t, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// KeyOption returns the first of the named options which is set, as a
+// document ID. The value may be a string, or a JSON-encoded string.
+func KeyOption(options map[string]interface{}, names ...string) (string, bool) {
+	for _, name := range names {
+		switch t := options[name].(type) {
+		case string:
+			return t, true
+		case json.RawMessage:
+			var s string
+			if json.Unmarshal(t, &s) == nil {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ConvertOption converts the option value v, which may be JSON, to dest.
+func ConvertOption(v, dest interface{}) error {
+	var raw []byte
+	switch t := v.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return nil
+}
+
+// ChangesFilter returns a function which reports whether the document docID
+// should be included in a changes feed, according to the built-in filter
+// requested in options. The _selector filter calls doc to read the body of
+// the document, which is nil if it has been deleted. Filter functions and the
+// _view filter are not supported, and result in a 501 error naming the
+// driver, driverName.
+func ChangesFilter(options map[string]interface{}, driverName string) (func(docID string, doc func() (map[string]interface{}, error)) (bool, error), error) {
+	switch filter := StringOption(options, "filter"); filter {
+	case "":
+		return func(string, func() (map[string]interface{}, error)) (bool, error) {
+			return true, nil
+		}, nil
+	case "_design":
+		return func(docID string, _ func() (map[string]interface{}, error)) (bool, error) {
+			return strings.HasPrefix(docID, "_design/"), nil
+		}, nil
+	case "_doc_ids":
+		var ids []string
+		if err := ConvertOption(options["doc_ids"], &ids); err != nil {
+			return nil, err
+		}
+		want := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			want[id] = true
+		}
+		return func(docID string, _ func() (map[string]interface{}, error)) (bool, error) {
+			return want[docID], nil
+		}, nil
+	case "_selector":
+		var selector map[string]interface{}
+		if err := ConvertOption(options["selector"], &selector); err != nil {
+			return nil, err
+		}
+		return func(_ string, doc func() (map[string]interface{}, error)) (bool, error) {
+			body, err := doc()
+			if err != nil || body == nil {
+				return false, err
+			}
+			return mango.Match(selector, body)
+		}, nil
+	default:
+		return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: " + filter + " filter not supported by the " + driverName + " driver"}
+	}
+}
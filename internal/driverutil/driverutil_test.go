@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driverutil
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func TestValidDBName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"foo":       true,
+		"foo/bar_1": true,
+		"a$()+-":    true,
+		"":          false,
+		"Foo":       false,
+		"_users":    false,
+		"1foo":      false,
+	} {
+		if got := ValidDBName(name); got != want {
+			t.Errorf("ValidDBName(%q) = %v", name, got)
+		}
+	}
+}
+
+func TestNewDocID(t *testing.T) {
+	id := NewDocID()
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) {
+		t.Errorf("Unexpected ID: %s", id)
+	}
+	if NewDocID() == id {
+		t.Error("Expected unique IDs")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	options := map[string]interface{}{
+		"int":      2,
+		"float":    3.0,
+		"numeric":  "4",
+		"bad":      "x",
+		"key":      "foo",
+		"json_key": json.RawMessage(`"bar"`),
+		"bool":     "true",
+	}
+	for name, want := range map[string]int64{"int": 2, "float": 3, "numeric": 4} {
+		if got, ok := IntOption(options, name); !ok || got != want {
+			t.Errorf("IntOption(%q) = %d, %v", name, got, ok)
+		}
+	}
+	if _, ok := IntOption(options, "bad"); ok {
+		t.Error("Expected non-numeric string to be ignored")
+	}
+	if got, ok := KeyOption(options, "missing", "json_key", "key"); !ok || got != "bar" {
+		t.Errorf("KeyOption = %q, %v", got, ok)
+	}
+	if !BoolOption(options, "bool") || BoolOption(options, "missing") {
+		t.Error("Unexpected BoolOption result")
+	}
+	if got := StringOption(options, "key"); got != "foo" {
+		t.Errorf("StringOption = %q", got)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	rows := NewRows([]*driver.Row{{ID: "a"}, {ID: "b"}}, 1, 5, "7")
+	var row driver.Row
+	var ids []string
+	for rows.Next(&row) == nil {
+		ids = append(ids, row.ID)
+	}
+	if len(ids) != 2 || ids[1] != "b" || rows.Offset() != 1 || rows.TotalRows() != 5 || rows.UpdateSeq() != "7" {
+		t.Errorf("Unexpected rows: %v", ids)
+	}
+
+	changes := NewChanges([]*driver.Change{{ID: "a", Seq: "1"}}, "1")
+	if changes.Pending() != 1 {
+		t.Errorf("Unexpected pending: %d", changes.Pending())
+	}
+	var change driver.Change
+	if err := changes.Next(&change); err != nil || change.ID != "a" {
+		t.Errorf("Unexpected change: %v, %v", change, err)
+	}
+	if err := changes.Next(&change); err != io.EOF || changes.LastSeq() != "1" {
+		t.Errorf("Unexpected end of feed: %v, %s", err, changes.LastSeq())
+	}
+}
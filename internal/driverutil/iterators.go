@@ -0,0 +1,106 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driverutil
+
+import (
+	"io"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Rows is a [driver.Rows] over a pre-computed result set.
+type Rows struct {
+	rows      []*driver.Row
+	offset    int64
+	totalRows int64
+	updateSeq string
+}
+
+var _ driver.Rows = &Rows{}
+
+// NewRows returns a [driver.Rows] which returns rows.
+func NewRows(rows []*driver.Row, offset, totalRows int64, updateSeq string) *Rows {
+	return &Rows{
+		rows:      rows,
+		offset:    offset,
+		totalRows: totalRows,
+		updateSeq: updateSeq,
+	}
+}
+
+// Next implements [driver.Rows].
+func (r *Rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// Close implements [driver.Rows].
+func (r *Rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+// UpdateSeq implements [driver.Rows].
+func (r *Rows) UpdateSeq() string { return r.updateSeq }
+
+// Offset implements [driver.Rows].
+func (r *Rows) Offset() int64 { return r.offset }
+
+// TotalRows implements [driver.Rows].
+func (r *Rows) TotalRows() int64 { return r.totalRows }
+
+// Changes is a [driver.Changes] over a pre-computed, normal changes feed.
+type Changes struct {
+	changes []*driver.Change
+	lastSeq string
+}
+
+var _ driver.Changes = &Changes{}
+
+// NewChanges returns a [driver.Changes] which returns changes, followed by
+// lastSeq.
+func NewChanges(changes []*driver.Change, lastSeq string) *Changes {
+	return &Changes{
+		changes: changes,
+		lastSeq: lastSeq,
+	}
+}
+
+// Next implements [driver.Changes].
+func (c *Changes) Next(change *driver.Change) error {
+	if len(c.changes) == 0 {
+		return io.EOF
+	}
+	*change = *c.changes[0]
+	c.changes = c.changes[1:]
+	return nil
+}
+
+// Close implements [driver.Changes].
+func (c *Changes) Close() error {
+	c.changes = nil
+	return nil
+}
+
+// LastSeq implements [driver.Changes].
+func (c *Changes) LastSeq() string { return c.lastSeq }
+
+// Pending implements [driver.Changes].
+func (c *Changes) Pending() int64 { return int64(len(c.changes)) }
+
+// ETag implements [driver.Changes].
+func (c *Changes) ETag() string { return "" }
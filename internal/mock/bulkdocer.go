@@ -30,3 +30,16 @@ var _ driver.BulkDocer = &BulkDocer{}
 func (db *BulkDocer) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) ([]driver.BulkResult, error) {
 	return db.BulkDocsFunc(ctx, docs, options)
 }
+
+// BulkDocsStreamer mocks a driver.DB and driver.BulkDocsStreamer
+type BulkDocsStreamer struct {
+	*DB
+	BulkDocsStreamFunc func(ctx context.Context, docs driver.DocSource, options map[string]interface{}) ([]driver.BulkResult, error)
+}
+
+var _ driver.BulkDocsStreamer = &BulkDocsStreamer{}
+
+// BulkDocsStream calls db.BulkDocsStreamFunc
+func (db *BulkDocsStreamer) BulkDocsStream(ctx context.Context, docs driver.DocSource, options map[string]interface{}) ([]driver.BulkResult, error) {
+	return db.BulkDocsStreamFunc(ctx, docs, options)
+}
@@ -14,6 +14,7 @@ package mock
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -133,6 +134,19 @@ func (c *Pinger) Ping(ctx context.Context) (bool, error) {
 	return c.PingFunc(ctx)
 }
 
+// UUIDer mocks driver.Client and driver.UUIDer
+type UUIDer struct {
+	*Client
+	UUIDsFunc func(context.Context, int) ([]string, error)
+}
+
+var _ driver.UUIDer = &UUIDer{}
+
+// UUIDs calls c.UUIDsFunc
+func (c *UUIDer) UUIDs(ctx context.Context, count int) ([]string, error) {
+	return c.UUIDsFunc(ctx, count)
+}
+
 // Cluster mocks driver.Client and driver.Cluster
 type Cluster struct {
 	*Client
@@ -207,3 +221,48 @@ func (c *Configer) SetConfigValue(ctx context.Context, node, section, key, value
 func (c *Configer) DeleteConfigKey(ctx context.Context, node, section, key string) (string, error) {
 	return c.DeleteConfigKeyFunc(ctx, node, section, key)
 }
+
+// NodeInspector mocks driver.Client and driver.NodeInspector
+type NodeInspector struct {
+	*Client
+	NodeStatsFunc  func(context.Context, string) (json.RawMessage, error)
+	NodeSystemFunc func(context.Context, string) (json.RawMessage, error)
+}
+
+var _ driver.NodeInspector = &NodeInspector{}
+
+// NodeStats calls c.NodeStatsFunc
+func (c *NodeInspector) NodeStats(ctx context.Context, node string) (json.RawMessage, error) {
+	return c.NodeStatsFunc(ctx, node)
+}
+
+// NodeSystem calls c.NodeSystemFunc
+func (c *NodeInspector) NodeSystem(ctx context.Context, node string) (json.RawMessage, error) {
+	return c.NodeSystemFunc(ctx, node)
+}
+
+// ActiveTasker mocks driver.Client and driver.ActiveTasker
+type ActiveTasker struct {
+	*Client
+	ActiveTasksFunc func(context.Context) (json.RawMessage, error)
+}
+
+var _ driver.ActiveTasker = &ActiveTasker{}
+
+// ActiveTasks calls c.ActiveTasksFunc
+func (c *ActiveTasker) ActiveTasks(ctx context.Context) (json.RawMessage, error) {
+	return c.ActiveTasksFunc(ctx)
+}
+
+// PoolStatser mocks driver.Client and driver.PoolStatser
+type PoolStatser struct {
+	*Client
+	PoolStatsFunc func() *driver.PoolStats
+}
+
+var _ driver.PoolStatser = &PoolStatser{}
+
+// PoolStats calls c.PoolStatsFunc
+func (c *PoolStatser) PoolStats() *driver.PoolStats {
+	return c.PoolStatsFunc()
+}
@@ -185,6 +185,19 @@ func (db *RevGetter) GetRev(ctx context.Context, docID string, opts map[string]i
 	return db.GetRevFunc(ctx, docID, opts)
 }
 
+// MetaGetter mocks a driver.DB and driver.MetaGetter
+type MetaGetter struct {
+	*DB
+	GetMetaFunc func(context.Context, string, map[string]interface{}) (*driver.DocMeta, error)
+}
+
+var _ driver.MetaGetter = &MetaGetter{}
+
+// GetMeta calls db.GetMetaFunc
+func (db *MetaGetter) GetMeta(ctx context.Context, docID string, opts map[string]interface{}) (*driver.DocMeta, error) {
+	return db.GetMetaFunc(ctx, docID, opts)
+}
+
 // Copier mocks a driver.DB and driver.Copier.
 type Copier struct {
 	*DB
@@ -302,3 +315,60 @@ type PartitionedDB struct {
 func (db *PartitionedDB) PartitionStats(ctx context.Context, name string) (*driver.PartitionStats, error) {
 	return db.PartitionStatsFunc(ctx, name)
 }
+
+// Counter mocks a driver.DB and driver.Counter
+type Counter struct {
+	*DB
+	CountFunc func(context.Context, interface{}, map[string]interface{}) (int64, error)
+}
+
+var _ driver.Counter = &Counter{}
+
+// Count calls db.CountFunc
+func (db *Counter) Count(ctx context.Context, selector interface{}, opts map[string]interface{}) (int64, error) {
+	return db.CountFunc(ctx, selector, opts)
+}
+
+// Searcher mocks a driver.DB and driver.Searcher
+type Searcher struct {
+	*DB
+	SearchFunc        func(context.Context, string, string, string, map[string]interface{}) (driver.Rows, error)
+	SearchInfoFunc    func(context.Context, string, string) (*driver.SearchInfo, error)
+	SearchAnalyzeFunc func(context.Context, string) ([]string, error)
+}
+
+var _ driver.Searcher = &Searcher{}
+
+// Search calls db.SearchFunc
+func (db *Searcher) Search(ctx context.Context, ddoc, index, query string, opts map[string]interface{}) (driver.Rows, error) {
+	return db.SearchFunc(ctx, ddoc, index, query, opts)
+}
+
+// SearchInfo calls db.SearchInfoFunc
+func (db *Searcher) SearchInfo(ctx context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+	return db.SearchInfoFunc(ctx, ddoc, index)
+}
+
+// SearchAnalyze calls db.SearchAnalyzeFunc
+func (db *Searcher) SearchAnalyze(ctx context.Context, text string) ([]string, error) {
+	return db.SearchAnalyzeFunc(ctx, text)
+}
+
+// Sharder mocks a driver.DB and driver.Sharder
+type Sharder struct {
+	*DB
+	ShardsFunc   func(context.Context) (map[string][]string, error)
+	DocShardFunc func(context.Context, string) (*driver.DocShard, error)
+}
+
+var _ driver.Sharder = &Sharder{}
+
+// Shards calls db.ShardsFunc
+func (db *Sharder) Shards(ctx context.Context) (map[string][]string, error) {
+	return db.ShardsFunc(ctx)
+}
+
+// DocShard calls db.DocShardFunc
+func (db *Sharder) DocShard(ctx context.Context, docID string) (*driver.DocShard, error) {
+	return db.DocShardFunc(ctx, docID)
+}
@@ -36,3 +36,16 @@ func (u *DBUpdates) Close() error {
 	}
 	return nil
 }
+
+// LastSeqer wraps driver.LastSeqer
+type LastSeqer struct {
+	*DBUpdates
+	LastSeqFunc func() (string, error)
+}
+
+var _ driver.LastSeqer = &LastSeqer{}
+
+// LastSeq calls u.LastSeqFunc
+func (u *LastSeqer) LastSeq() (string, error) {
+	return u.LastSeqFunc()
+}
@@ -0,0 +1,449 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Row is a fixture for a single row of a [RowsFeed]. Key, Value and Doc are
+// marshaled to JSON, unless they are already a string, []byte or
+// json.RawMessage, in which case they are used as raw JSON.
+type Row struct {
+	ID    string
+	Key   interface{}
+	Value interface{}
+	Doc   interface{}
+	// Error is the per-row error, such as for a missing key in a query with
+	// the keys option.
+	Error error
+}
+
+// RowsFeed builds a [driver.Rows] iterator from fixtures. Rows, errors and
+// query boundaries are returned in the order in which they were added.
+type RowsFeed struct {
+	steps     []interface{} // *Row or error
+	offset    int64
+	totalRows int64
+	updateSeq string
+	warning   string
+	bookmark  string
+}
+
+// NewRowsFeed returns a new feed containing rows.
+func NewRowsFeed(rows ...Row) *RowsFeed {
+	f := &RowsFeed{}
+	return f.AddRows(rows...)
+}
+
+// RowsFeedFromJSON builds a feed from a CouchDB view response, such as:
+//
+//	{"total_rows":2,"offset":0,"rows":[{"id":"a","key":"a","value":{"rev":"1-a"}}]}
+//
+// A multi-query response, with a "results" array of such objects, produces
+// a feed with an end-of-query boundary after each result set. Rows with an
+// "error" field are given a per-row error. The "update_seq", "warning" and
+// "bookmark" fields are honored.
+func RowsFeedFromJSON(data string) (*RowsFeed, error) {
+	type viewResult struct {
+		TotalRows int64             `json:"total_rows"`
+		Offset    int64             `json:"offset"`
+		UpdateSeq json.RawMessage   `json:"update_seq"`
+		Warning   string            `json:"warning"`
+		Bookmark  string            `json:"bookmark"`
+		Rows      []json.RawMessage `json:"rows"`
+		Docs      []json.RawMessage `json:"docs"`
+		Results   []json.RawMessage `json:"results"`
+	}
+	var result viewResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, err
+	}
+	f := &RowsFeed{
+		totalRows: result.TotalRows,
+		offset:    result.Offset,
+		updateSeq: seqString(result.UpdateSeq),
+		warning:   result.Warning,
+		bookmark:  result.Bookmark,
+	}
+	if result.Results == nil {
+		return f, f.addJSONRows(result.Rows, result.Docs)
+	}
+	for i, raw := range result.Results {
+		var sub viewResult
+		if err := json.Unmarshal(raw, &sub); err != nil {
+			return nil, err
+		}
+		if err := f.addJSONRows(sub.Rows, sub.Docs); err != nil {
+			return nil, err
+		}
+		if i < len(result.Results)-1 {
+			f.EOQ()
+		}
+	}
+	return f, nil
+}
+
+func (f *RowsFeed) addJSONRows(rows, docs []json.RawMessage) error {
+	for _, raw := range rows {
+		var row struct {
+			ID    string          `json:"id"`
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+			Doc   json.RawMessage `json:"doc"`
+			Error string          `json:"error"`
+		}
+		if err := json.Unmarshal(raw, &row); err != nil {
+			return err
+		}
+		r := Row{ID: row.ID}
+		if row.Key != nil {
+			r.Key = row.Key
+		}
+		if row.Value != nil {
+			r.Value = row.Value
+		}
+		if row.Doc != nil {
+			r.Doc = row.Doc
+		}
+		if row.Error != "" {
+			r.Error = errors.New(row.Error)
+		}
+		f.AddRows(r)
+	}
+	// The docs of a _find response are returned as rows with only a doc.
+	for _, doc := range docs {
+		f.AddRows(Row{Doc: doc})
+	}
+	return nil
+}
+
+// AddRows appends rows to the feed.
+func (f *RowsFeed) AddRows(rows ...Row) *RowsFeed {
+	for i := range rows {
+		f.steps = append(f.steps, &rows[i])
+	}
+	return f
+}
+
+// AddError appends an error to the feed, which is returned by Next in place
+// of a row.
+func (f *RowsFeed) AddError(err error) *RowsFeed {
+	f.steps = append(f.steps, err)
+	return f
+}
+
+// EOQ appends an end-of-query boundary to the feed, as returned between the
+// result sets of a multi-query.
+func (f *RowsFeed) EOQ() *RowsFeed {
+	return f.AddError(driver.EOQ)
+}
+
+// TotalRows sets the total_rows value reported by the feed.
+func (f *RowsFeed) TotalRows(n int64) *RowsFeed {
+	f.totalRows = n
+	return f
+}
+
+// Offset sets the offset reported by the feed.
+func (f *RowsFeed) Offset(n int64) *RowsFeed {
+	f.offset = n
+	return f
+}
+
+// UpdateSeq sets the update sequence reported by the feed.
+func (f *RowsFeed) UpdateSeq(seq string) *RowsFeed {
+	f.updateSeq = seq
+	return f
+}
+
+// Warning sets the warning reported by the feed, which then implements
+// [driver.RowsWarner].
+func (f *RowsFeed) Warning(warning string) *RowsFeed {
+	f.warning = warning
+	return f
+}
+
+// Bookmark sets the bookmark reported by the feed, which then implements
+// [driver.Bookmarker].
+func (f *RowsFeed) Bookmark(bookmark string) *RowsFeed {
+	f.bookmark = bookmark
+	return f
+}
+
+// Rows returns a new iterator over the feed. Each call returns an independent
+// iterator, starting at the beginning of the feed.
+func (f *RowsFeed) Rows() driver.Rows {
+	steps := f.steps
+	rows := &Rows{
+		NextFunc: func(row *driver.Row) error {
+			if len(steps) == 0 {
+				return io.EOF
+			}
+			step := steps[0]
+			steps = steps[1:]
+			switch t := step.(type) {
+			case error:
+				return t
+			case *Row:
+				return t.toDriver(row)
+			}
+			return nil
+		},
+		OffsetFunc:    func() int64 { return f.offset },
+		TotalRowsFunc: func() int64 { return f.totalRows },
+		UpdateSeqFunc: func() string { return f.updateSeq },
+	}
+	switch {
+	case f.warning != "" && f.bookmark != "":
+		return &rowsWarnerBookmarker{
+			Rows:     rows,
+			warning:  f.warning,
+			bookmark: f.bookmark,
+		}
+	case f.warning != "":
+		return &RowsWarner{
+			Rows:        rows,
+			WarningFunc: func() string { return f.warning },
+		}
+	case f.bookmark != "":
+		return &Bookmarker{
+			Rows:         rows,
+			BookmarkFunc: func() string { return f.bookmark },
+		}
+	}
+	return rows
+}
+
+type rowsWarnerBookmarker struct {
+	*Rows
+	warning  string
+	bookmark string
+}
+
+var (
+	_ driver.RowsWarner = &rowsWarnerBookmarker{}
+	_ driver.Bookmarker = &rowsWarnerBookmarker{}
+)
+
+func (r *rowsWarnerBookmarker) Warning() string  { return r.warning }
+func (r *rowsWarnerBookmarker) Bookmark() string { return r.bookmark }
+
+func (r *Row) toDriver(row *driver.Row) error {
+	key, err := rawJSON(r.Key)
+	if err != nil {
+		return err
+	}
+	value, err := rawJSON(r.Value)
+	if err != nil {
+		return err
+	}
+	doc, err := rawJSON(r.Doc)
+	if err != nil {
+		return err
+	}
+	*row = driver.Row{
+		ID:    r.ID,
+		Key:   key,
+		Error: r.Error,
+	}
+	if value != nil {
+		row.Value = bytes.NewReader(value)
+	}
+	if doc != nil {
+		row.Doc = bytes.NewReader(doc)
+	}
+	return nil
+}
+
+// rawJSON returns v as raw JSON, or nil if v is nil.
+func rawJSON(v interface{}) (json.RawMessage, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return t, nil
+	case []byte:
+		return t, nil
+	case string:
+		return json.RawMessage(t), nil
+	}
+	return json.Marshal(v)
+}
+
+// seqString returns a JSON update sequence, which may be a string or a
+// number, as a string.
+func seqString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// ChangesFeed builds a [driver.Changes] iterator from fixtures.
+type ChangesFeed struct {
+	steps   []interface{} // *driver.Change or error
+	lastSeq string
+	pending int64
+	etag    string
+}
+
+// NewChangesFeed returns a new feed containing changes.
+func NewChangesFeed(changes ...driver.Change) *ChangesFeed {
+	f := &ChangesFeed{}
+	return f.AddChanges(changes...)
+}
+
+// ChangesFeedFromJSON builds a feed from a CouchDB changes response, such as:
+//
+//	{"results":[{"seq":"1-x","id":"a","changes":[{"rev":"1-a"}]}],"last_seq":"1-x","pending":0}
+func ChangesFeedFromJSON(data string) (*ChangesFeed, error) {
+	var result struct {
+		Results []driver.Change `json:"results"`
+		LastSeq json.RawMessage `json:"last_seq"`
+		Pending int64           `json:"pending"`
+	}
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, err
+	}
+	f := NewChangesFeed(result.Results...)
+	f.lastSeq = seqString(result.LastSeq)
+	f.pending = result.Pending
+	return f, nil
+}
+
+// AddChanges appends changes to the feed.
+func (f *ChangesFeed) AddChanges(changes ...driver.Change) *ChangesFeed {
+	for i := range changes {
+		f.steps = append(f.steps, &changes[i])
+	}
+	return f
+}
+
+// AddError appends an error to the feed, which is returned by Next in place
+// of a change.
+func (f *ChangesFeed) AddError(err error) *ChangesFeed {
+	f.steps = append(f.steps, err)
+	return f
+}
+
+// LastSeq sets the last sequence reported by the feed.
+func (f *ChangesFeed) LastSeq(seq string) *ChangesFeed {
+	f.lastSeq = seq
+	return f
+}
+
+// Pending sets the number of pending changes reported by the feed.
+func (f *ChangesFeed) Pending(n int64) *ChangesFeed {
+	f.pending = n
+	return f
+}
+
+// ETag sets the ETag reported by the feed.
+func (f *ChangesFeed) ETag(etag string) *ChangesFeed {
+	f.etag = etag
+	return f
+}
+
+// Changes returns a new iterator over the feed. Each call returns an
+// independent iterator, starting at the beginning of the feed.
+func (f *ChangesFeed) Changes() *Changes {
+	steps := f.steps
+	return &Changes{
+		NextFunc: func(change *driver.Change) error {
+			if len(steps) == 0 {
+				return io.EOF
+			}
+			step := steps[0]
+			steps = steps[1:]
+			switch t := step.(type) {
+			case error:
+				return t
+			case *driver.Change:
+				*change = *t
+			}
+			return nil
+		},
+		LastSeqFunc: func() string { return f.lastSeq },
+		PendingFunc: func() int64 { return f.pending },
+		ETagFunc:    func() string { return f.etag },
+	}
+}
+
+// DBUpdatesFeed builds a [driver.DBUpdates] iterator from fixtures.
+type DBUpdatesFeed struct {
+	steps []interface{} // *driver.DBUpdate or error
+}
+
+// NewDBUpdatesFeed returns a new feed containing updates.
+func NewDBUpdatesFeed(updates ...driver.DBUpdate) *DBUpdatesFeed {
+	f := &DBUpdatesFeed{}
+	return f.AddUpdates(updates...)
+}
+
+// DBUpdatesFeedFromJSON builds a feed from a CouchDB _db_updates response,
+// such as:
+//
+//	{"results":[{"db_name":"foo","type":"created","seq":"1-x"}],"last_seq":"1-x"}
+func DBUpdatesFeedFromJSON(data string) (*DBUpdatesFeed, error) {
+	var result struct {
+		Results []driver.DBUpdate `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, err
+	}
+	return NewDBUpdatesFeed(result.Results...), nil
+}
+
+// AddUpdates appends updates to the feed.
+func (f *DBUpdatesFeed) AddUpdates(updates ...driver.DBUpdate) *DBUpdatesFeed {
+	for i := range updates {
+		f.steps = append(f.steps, &updates[i])
+	}
+	return f
+}
+
+// AddError appends an error to the feed, which is returned by Next in place
+// of an update.
+func (f *DBUpdatesFeed) AddError(err error) *DBUpdatesFeed {
+	f.steps = append(f.steps, err)
+	return f
+}
+
+// DBUpdates returns a new iterator over the feed. Each call returns an
+// independent iterator, starting at the beginning of the feed.
+func (f *DBUpdatesFeed) DBUpdates() *DBUpdates {
+	steps := f.steps
+	return &DBUpdates{
+		NextFunc: func(update *driver.DBUpdate) error {
+			if len(steps) == 0 {
+				return io.EOF
+			}
+			step := steps[0]
+			steps = steps[1:]
+			switch t := step.(type) {
+			case error:
+				return t
+			case *driver.DBUpdate:
+				*update = *t
+			}
+			return nil
+		},
+	}
+}
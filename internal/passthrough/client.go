@@ -0,0 +1,223 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package passthrough
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// ClientFeatures implements each of the optional client interfaces of the
+// driver package. Calls are passed to Base, if it implements the interface.
+// Otherwise they fail as Kivik would fail them, usually with status 501 (Not
+// Implemented), which also causes Kivik to fall back to its own emulation,
+// where it has one.
+//
+// ClientFeatures is meant to be embedded in a wrapper, alongside the wrapped
+// driver.Client. Methods of the wrapper take precedence over those of
+// ClientFeatures.
+type ClientFeatures struct {
+	// Base is the wrapped client.
+	Base driver.Client
+}
+
+var (
+	_ driver.DBsStatser       = &ClientFeatures{}
+	_ driver.ClientReplicator = &ClientFeatures{}
+	_ driver.Authenticator    = &ClientFeatures{}
+	_ driver.Pinger           = &ClientFeatures{}
+	_ driver.UUIDer           = &ClientFeatures{}
+	_ driver.ActiveTasker     = &ClientFeatures{}
+	_ driver.PoolStatser      = &ClientFeatures{}
+	_ driver.Cluster          = &ClientFeatures{}
+	_ driver.ClientCloser     = &ClientFeatures{}
+	_ driver.Sessioner        = &ClientFeatures{}
+	_ driver.DBUpdater        = &ClientFeatures{}
+	_ driver.Configer         = &ClientFeatures{}
+	_ driver.NodeInspector    = &ClientFeatures{}
+)
+
+// DBsStats calls the underlying driver's DBsStats method. Kivik falls back
+// to fetching the statistics of each database in turn if it is not
+// implemented.
+func (f *ClientFeatures) DBsStats(ctx context.Context, dbNames []string) ([]*driver.DBStats, error) {
+	if statser, ok := f.Base.(driver.DBsStatser); ok {
+		return statser.DBsStats(ctx, dbNames)
+	}
+	return nil, notImplemented("DBsStats")
+}
+
+// Replicate calls the underlying driver's Replicate method.
+func (f *ClientFeatures) Replicate(ctx context.Context, targetDSN, sourceDSN string, options map[string]interface{}) (driver.Replication, error) {
+	if replicator, ok := f.Base.(driver.ClientReplicator); ok {
+		return replicator.Replicate(ctx, targetDSN, sourceDSN, options)
+	}
+	return nil, notImplemented("Replicate")
+}
+
+// GetReplications calls the underlying driver's GetReplications method.
+func (f *ClientFeatures) GetReplications(ctx context.Context, options map[string]interface{}) ([]driver.Replication, error) {
+	if replicator, ok := f.Base.(driver.ClientReplicator); ok {
+		return replicator.GetReplications(ctx, options)
+	}
+	return nil, notImplemented("GetReplications")
+}
+
+// Authenticate calls the underlying driver's Authenticate method.
+func (f *ClientFeatures) Authenticate(ctx context.Context, authenticator interface{}) error {
+	if auth, ok := f.Base.(driver.Authenticator); ok {
+		return auth.Authenticate(ctx, authenticator)
+	}
+	return &statusError{status: http.StatusNotImplemented, msg: "kivik: driver does not support authentication"}
+}
+
+// Ping calls the underlying driver's Ping method, or falls back to Version.
+func (f *ClientFeatures) Ping(ctx context.Context) (bool, error) {
+	if pinger, ok := f.Base.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := f.Base.Version(ctx)
+	return err == nil, err
+}
+
+// UUIDs calls the underlying driver's UUIDs method. Kivik generates UUIDs
+// locally if it is not implemented.
+func (f *ClientFeatures) UUIDs(ctx context.Context, count int) ([]string, error) {
+	if uuider, ok := f.Base.(driver.UUIDer); ok {
+		return uuider.UUIDs(ctx, count)
+	}
+	return nil, notImplemented("UUIDs")
+}
+
+// ActiveTasks calls the underlying driver's ActiveTasks method.
+func (f *ClientFeatures) ActiveTasks(ctx context.Context) (json.RawMessage, error) {
+	if tasker, ok := f.Base.(driver.ActiveTasker); ok {
+		return tasker.ActiveTasks(ctx)
+	}
+	return nil, notImplemented("ActiveTasks")
+}
+
+// PoolStats calls the underlying driver's PoolStats method, or returns nil.
+func (f *ClientFeatures) PoolStats() *driver.PoolStats {
+	if statser, ok := f.Base.(driver.PoolStatser); ok {
+		return statser.PoolStats()
+	}
+	return nil
+}
+
+// ClusterStatus calls the underlying driver's ClusterStatus method.
+func (f *ClientFeatures) ClusterStatus(ctx context.Context, options map[string]interface{}) (string, error) {
+	if cluster, ok := f.Base.(driver.Cluster); ok {
+		return cluster.ClusterStatus(ctx, options)
+	}
+	return "", notImplemented("ClusterStatus")
+}
+
+// ClusterSetup calls the underlying driver's ClusterSetup method.
+func (f *ClientFeatures) ClusterSetup(ctx context.Context, action interface{}) error {
+	if cluster, ok := f.Base.(driver.Cluster); ok {
+		return cluster.ClusterSetup(ctx, action)
+	}
+	return notImplemented("ClusterSetup")
+}
+
+// Membership calls the underlying driver's Membership method.
+func (f *ClientFeatures) Membership(ctx context.Context) (*driver.ClusterMembership, error) {
+	if cluster, ok := f.Base.(driver.Cluster); ok {
+		return cluster.Membership(ctx)
+	}
+	return nil, notImplemented("Membership")
+}
+
+// Close calls the underlying driver's Close method, if any.
+func (f *ClientFeatures) Close() error {
+	if closer, ok := f.Base.(driver.ClientCloser); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Session calls the underlying driver's Session method.
+func (f *ClientFeatures) Session(ctx context.Context) (*driver.Session, error) {
+	if sessioner, ok := f.Base.(driver.Sessioner); ok {
+		return sessioner.Session(ctx)
+	}
+	return nil, notImplemented("Session")
+}
+
+// DBUpdates calls the underlying driver's DBUpdates method.
+func (f *ClientFeatures) DBUpdates(ctx context.Context, options map[string]interface{}) (driver.DBUpdates, error) {
+	if updater, ok := f.Base.(driver.DBUpdater); ok {
+		return updater.DBUpdates(ctx, options)
+	}
+	return nil, notImplemented("DBUpdates")
+}
+
+// Config calls the underlying driver's Config method.
+func (f *ClientFeatures) Config(ctx context.Context, node string) (driver.Config, error) {
+	if configer, ok := f.Base.(driver.Configer); ok {
+		return configer.Config(ctx, node)
+	}
+	return nil, notImplemented("Config")
+}
+
+// ConfigSection calls the underlying driver's ConfigSection method.
+func (f *ClientFeatures) ConfigSection(ctx context.Context, node, section string) (driver.ConfigSection, error) {
+	if configer, ok := f.Base.(driver.Configer); ok {
+		return configer.ConfigSection(ctx, node, section)
+	}
+	return nil, notImplemented("ConfigSection")
+}
+
+// ConfigValue calls the underlying driver's ConfigValue method.
+func (f *ClientFeatures) ConfigValue(ctx context.Context, node, section, key string) (string, error) {
+	if configer, ok := f.Base.(driver.Configer); ok {
+		return configer.ConfigValue(ctx, node, section, key)
+	}
+	return "", notImplemented("ConfigValue")
+}
+
+// SetConfigValue calls the underlying driver's SetConfigValue method.
+func (f *ClientFeatures) SetConfigValue(ctx context.Context, node, section, key, value string) (string, error) {
+	if configer, ok := f.Base.(driver.Configer); ok {
+		return configer.SetConfigValue(ctx, node, section, key, value)
+	}
+	return "", notImplemented("SetConfigValue")
+}
+
+// DeleteConfigKey calls the underlying driver's DeleteConfigKey method.
+func (f *ClientFeatures) DeleteConfigKey(ctx context.Context, node, section, key string) (string, error) {
+	if configer, ok := f.Base.(driver.Configer); ok {
+		return configer.DeleteConfigKey(ctx, node, section, key)
+	}
+	return "", notImplemented("DeleteConfigKey")
+}
+
+// NodeStats calls the underlying driver's NodeStats method.
+func (f *ClientFeatures) NodeStats(ctx context.Context, node string) (json.RawMessage, error) {
+	if inspector, ok := f.Base.(driver.NodeInspector); ok {
+		return inspector.NodeStats(ctx, node)
+	}
+	return nil, notImplemented("NodeStats")
+}
+
+// NodeSystem calls the underlying driver's NodeSystem method.
+func (f *ClientFeatures) NodeSystem(ctx context.Context, node string) (json.RawMessage, error) {
+	if inspector, ok := f.Base.(driver.NodeInspector); ok {
+		return inspector.NodeSystem(ctx, node)
+	}
+	return nil, notImplemented("NodeSystem")
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package passthrough
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestClientFeatures(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("passes through", func(t *testing.T) {
+		var authenticated interface{}
+		f := &ClientFeatures{Base: &mock.Authenticator{
+			Client: &mock.Client{},
+			AuthenticateFunc: func(_ context.Context, a interface{}) error {
+				authenticated = a
+				return nil
+			},
+		}}
+		if err := f.Authenticate(ctx, "creds"); err != nil {
+			t.Fatal(err)
+		}
+		if authenticated != "creds" {
+			t.Errorf("Unexpected authenticator: %v", authenticated)
+		}
+	})
+	t.Run("not implemented", func(t *testing.T) {
+		f := &ClientFeatures{Base: &mock.Client{}}
+		err := f.Authenticate(ctx, "creds")
+		testy.StatusError(t, "kivik: driver does not support authentication", http.StatusNotImplemented, err)
+		_, err = f.UUIDs(ctx, 1)
+		testy.StatusError(t, "kivik: UUIDs not supported by driver", http.StatusNotImplemented, err)
+		if stats := f.PoolStats(); stats != nil {
+			t.Errorf("Unexpected pool stats: %v", stats)
+		}
+	})
+	t.Run("Ping falls back to Version", func(t *testing.T) {
+		f := &ClientFeatures{Base: &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				return nil, errors.New("down")
+			},
+		}}
+		up, err := f.Ping(ctx)
+		if up || err == nil {
+			t.Errorf("Unexpected result: %v, %v", up, err)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		closed := false
+		f := &ClientFeatures{Base: &mock.ClientCloser{
+			Client: &mock.Client{},
+			CloseFunc: func() error {
+				closed = true
+				return nil
+			},
+		}}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !closed {
+			t.Error("Underlying client was not closed")
+		}
+		if err := (&ClientFeatures{Base: &mock.Client{}}).Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
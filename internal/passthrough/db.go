@@ -0,0 +1,415 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package passthrough implements the optional interfaces of the driver
+// package for types which wrap a driver, such as those of the x/views and
+// x/cache packages, so that wrapping a client or database does not hide the
+// features of the underlying driver.
+package passthrough
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// BulkBatchSize is the number of documents stored by each call to BulkDocs,
+// when BulkDocsStream is emulated. It matches kivik.DefaultBulkBatchSize,
+// which cannot be referenced here, as the kivik package imports this one.
+const BulkBatchSize = 1000
+
+// statusError is an error with an HTTP status, as reported by
+// kivik.HTTPStatus.
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e *statusError) Error() string   { return e.msg }
+func (e *statusError) HTTPStatus() int { return e.status }
+
+func notImplemented(what string) error {
+	return &statusError{status: http.StatusNotImplemented, msg: "kivik: " + what + " not supported by driver"}
+}
+
+// DBFeatures implements each of the optional database interfaces of the
+// driver package. Calls are passed to Base, if it implements the interface.
+// Otherwise they are emulated as Kivik would emulate them, by way of the
+// methods of Self, or fail with status 501 (Not Implemented).
+//
+// DBFeatures is meant to be embedded in a wrapper, alongside the wrapped
+// driver.DB. Methods of the wrapper take precedence over those of
+// DBFeatures.
+type DBFeatures struct {
+	// Base is the wrapped database.
+	Base driver.DB
+	// Self is the wrapper, through which emulated calls are made, so that
+	// they are subject to the wrapper's own behavior. If nil, Base is used.
+	Self driver.DB
+}
+
+var (
+	_ driver.RevGetter            = &DBFeatures{}
+	_ driver.BulkDocer            = &DBFeatures{}
+	_ driver.BulkDocsStreamer     = &DBFeatures{}
+	_ driver.Counter              = &DBFeatures{}
+	_ driver.Copier               = &DBFeatures{}
+	_ driver.AttachmentMetaGetter = &DBFeatures{}
+	_ driver.MetaGetter           = &DBFeatures{}
+	_ driver.DBCloser             = &DBFeatures{}
+	_ driver.Finder               = &DBFeatures{}
+	_ driver.Flusher              = &DBFeatures{}
+	_ driver.DesignDocer          = &DBFeatures{}
+	_ driver.LocalDocer           = &DBFeatures{}
+	_ driver.Purger               = &DBFeatures{}
+	_ driver.BulkGetter           = &DBFeatures{}
+	_ driver.RevsDiffer           = &DBFeatures{}
+	_ driver.PartitionedDB        = &DBFeatures{}
+	_ driver.Searcher             = &DBFeatures{}
+	_ driver.Sharder              = &DBFeatures{}
+)
+
+func (f *DBFeatures) self() driver.DB {
+	if f.Self != nil {
+		return f.Self
+	}
+	return f.Base
+}
+
+// GetRev calls the underlying driver's GetRev method, or falls back to Get.
+func (f *DBFeatures) GetRev(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	if revGetter, ok := f.Base.(driver.RevGetter); ok {
+		return revGetter.GetRev(ctx, docID, options)
+	}
+	doc, err := f.self().Get(ctx, docID, options)
+	if err != nil {
+		return "", err
+	}
+	_ = doc.Body.Close()
+	return doc.Rev, nil
+}
+
+// BulkDocs calls the underlying driver's BulkDocs method, or falls back to
+// calling Put or CreateDoc for each document.
+func (f *DBFeatures) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) ([]driver.BulkResult, error) {
+	if bulkDocer, ok := f.Base.(driver.BulkDocer); ok {
+		return bulkDocer.BulkDocs(ctx, docs, options)
+	}
+	self := f.self()
+	results := make([]driver.BulkResult, 0, len(docs))
+	for _, doc := range docs {
+		var meta struct {
+			ID string `json:"_id"`
+		}
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, err
+		}
+		result := driver.BulkResult{ID: meta.ID}
+		if meta.ID != "" {
+			result.Rev, result.Error = self.Put(ctx, meta.ID, doc, options)
+		} else {
+			result.ID, result.Rev, result.Error = self.CreateDoc(ctx, doc, options)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// BulkDocsStream calls the underlying driver's BulkDocsStream method, or
+// falls back to storing the documents with BulkDocs, in batches of
+// [BulkBatchSize].
+func (f *DBFeatures) BulkDocsStream(ctx context.Context, docs driver.DocSource, options map[string]interface{}) ([]driver.BulkResult, error) {
+	if streamer, ok := f.Base.(driver.BulkDocsStreamer); ok {
+		return streamer.BulkDocsStream(ctx, docs, options)
+	}
+	bulkDocer, ok := f.self().(driver.BulkDocer)
+	if !ok {
+		bulkDocer = f
+	}
+	var results []driver.BulkResult
+	for {
+		batch := make([]interface{}, 0, BulkBatchSize)
+		var srcErr error
+		for len(batch) < BulkBatchSize {
+			doc, err := docs.Next()
+			if err != nil {
+				srcErr = err
+				break
+			}
+			batch = append(batch, doc)
+		}
+		if len(batch) > 0 {
+			batchResults, err := bulkDocer.BulkDocs(ctx, batch, options)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, batchResults...)
+		}
+		if srcErr == io.EOF {
+			return results, nil
+		}
+		if srcErr != nil {
+			return results, srcErr
+		}
+	}
+}
+
+// Count calls the underlying driver's Count method, or falls back to
+// counting the results of a Find query.
+func (f *DBFeatures) Count(ctx context.Context, selector interface{}, options map[string]interface{}) (int64, error) {
+	if counter, ok := f.Base.(driver.Counter); ok {
+		return counter.Count(ctx, selector, options)
+	}
+	finder, ok := f.self().(driver.Finder)
+	if !ok {
+		finder = f
+	}
+	rows, err := finder.Find(ctx, map[string]interface{}{"selector": selector, "fields": []string{"_id"}}, options)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close() // nolint:errcheck
+	var count int64
+	var row driver.Row
+	for {
+		if err := rows.Next(&row); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return 0, err
+		}
+		count++
+	}
+}
+
+// Copy calls the underlying driver's Copy method, or falls back to Get and
+// Put.
+func (f *DBFeatures) Copy(ctx context.Context, targetID, sourceID string, options map[string]interface{}) (string, error) {
+	if copier, ok := f.Base.(driver.Copier); ok {
+		return copier.Copy(ctx, targetID, sourceID, options)
+	}
+	self := f.self()
+	source, err := self.Get(ctx, sourceID, options)
+	if err != nil {
+		return "", err
+	}
+	defer source.Body.Close() // nolint:errcheck
+	var doc map[string]interface{}
+	if err := json.NewDecoder(source.Body).Decode(&doc); err != nil {
+		return "", err
+	}
+	delete(doc, "_rev")
+	doc["_id"] = targetID
+	putOpts := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		if k != "rev" {
+			putOpts[k] = v
+		}
+	}
+	return self.Put(ctx, targetID, doc, putOpts)
+}
+
+// GetAttachmentMeta calls the underlying driver's GetAttachmentMeta method,
+// or falls back to GetAttachment.
+func (f *DBFeatures) GetAttachmentMeta(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	if metaer, ok := f.Base.(driver.AttachmentMetaGetter); ok {
+		return metaer.GetAttachmentMeta(ctx, docID, filename, options)
+	}
+	att, err := f.self().GetAttachment(ctx, docID, filename, options)
+	if err != nil {
+		return nil, err
+	}
+	_ = att.Content.Close()
+	att.Content = nil
+	return att, nil
+}
+
+// GetMeta calls the underlying driver's GetMeta method, or falls back to Get.
+func (f *DBFeatures) GetMeta(ctx context.Context, docID string, options map[string]interface{}) (*driver.DocMeta, error) {
+	if metaGetter, ok := f.Base.(driver.MetaGetter); ok {
+		return metaGetter.GetMeta(ctx, docID, options)
+	}
+	doc, err := f.self().Get(ctx, docID, options)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Body.Close() // nolint:errcheck
+	raw, err := io.ReadAll(doc.Body)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Rev      string           `json:"_rev"`
+		Deleted  bool             `json:"_deleted"`
+		RevsInfo []driver.RevInfo `json:"_revs_info"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	return &driver.DocMeta{
+		Rev:      body.Rev,
+		Deleted:  body.Deleted,
+		Size:     int64(len(raw)),
+		RevsInfo: body.RevsInfo,
+	}, nil
+}
+
+// Close calls the underlying driver's Close method, if any.
+func (f *DBFeatures) Close() error {
+	if closer, ok := f.Base.(driver.DBCloser); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Find calls the underlying driver's Find method.
+func (f *DBFeatures) Find(ctx context.Context, query interface{}, options map[string]interface{}) (driver.Rows, error) {
+	if finder, ok := f.Base.(driver.Finder); ok {
+		return finder.Find(ctx, query, options)
+	}
+	return nil, notImplemented("Find")
+}
+
+// CreateIndex calls the underlying driver's CreateIndex method.
+func (f *DBFeatures) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, options map[string]interface{}) error {
+	if finder, ok := f.Base.(driver.Finder); ok {
+		return finder.CreateIndex(ctx, ddoc, name, index, options)
+	}
+	return notImplemented("CreateIndex")
+}
+
+// GetIndexes calls the underlying driver's GetIndexes method.
+func (f *DBFeatures) GetIndexes(ctx context.Context, options map[string]interface{}) ([]driver.Index, error) {
+	if finder, ok := f.Base.(driver.Finder); ok {
+		return finder.GetIndexes(ctx, options)
+	}
+	return nil, notImplemented("GetIndexes")
+}
+
+// DeleteIndex calls the underlying driver's DeleteIndex method.
+func (f *DBFeatures) DeleteIndex(ctx context.Context, ddoc, name string, options map[string]interface{}) error {
+	if finder, ok := f.Base.(driver.Finder); ok {
+		return finder.DeleteIndex(ctx, ddoc, name, options)
+	}
+	return notImplemented("DeleteIndex")
+}
+
+// Explain calls the underlying driver's Explain method.
+func (f *DBFeatures) Explain(ctx context.Context, query interface{}, options map[string]interface{}) (*driver.QueryPlan, error) {
+	if finder, ok := f.Base.(driver.Finder); ok {
+		return finder.Explain(ctx, query, options)
+	}
+	return nil, notImplemented("Explain")
+}
+
+// Flush calls the underlying driver's Flush method.
+func (f *DBFeatures) Flush(ctx context.Context) error {
+	if flusher, ok := f.Base.(driver.Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return notImplemented("Flush")
+}
+
+// DesignDocs calls the underlying driver's DesignDocs method.
+func (f *DBFeatures) DesignDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	if ddocer, ok := f.Base.(driver.DesignDocer); ok {
+		return ddocer.DesignDocs(ctx, options)
+	}
+	return nil, notImplemented("DesignDocs")
+}
+
+// LocalDocs calls the underlying driver's LocalDocs method.
+func (f *DBFeatures) LocalDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	if ldocer, ok := f.Base.(driver.LocalDocer); ok {
+		return ldocer.LocalDocs(ctx, options)
+	}
+	return nil, notImplemented("LocalDocs")
+}
+
+// Purge calls the underlying driver's Purge method.
+func (f *DBFeatures) Purge(ctx context.Context, docRevMap map[string][]string) (*driver.PurgeResult, error) {
+	if purger, ok := f.Base.(driver.Purger); ok {
+		return purger.Purge(ctx, docRevMap)
+	}
+	return nil, notImplemented("Purge")
+}
+
+// BulkGet calls the underlying driver's BulkGet method.
+func (f *DBFeatures) BulkGet(ctx context.Context, docs []driver.BulkGetReference, options map[string]interface{}) (driver.Rows, error) {
+	if bulkGetter, ok := f.Base.(driver.BulkGetter); ok {
+		return bulkGetter.BulkGet(ctx, docs, options)
+	}
+	return nil, notImplemented("BulkGet")
+}
+
+// RevsDiff calls the underlying driver's RevsDiff method.
+func (f *DBFeatures) RevsDiff(ctx context.Context, revMap interface{}) (driver.Rows, error) {
+	if rd, ok := f.Base.(driver.RevsDiffer); ok {
+		return rd.RevsDiff(ctx, revMap)
+	}
+	return nil, notImplemented("RevsDiff")
+}
+
+// PartitionStats calls the underlying driver's PartitionStats method.
+func (f *DBFeatures) PartitionStats(ctx context.Context, name string) (*driver.PartitionStats, error) {
+	if pdb, ok := f.Base.(driver.PartitionedDB); ok {
+		return pdb.PartitionStats(ctx, name)
+	}
+	return nil, notImplemented("PartitionStats")
+}
+
+// Search calls the underlying driver's Search method.
+func (f *DBFeatures) Search(ctx context.Context, ddoc, index, query string, options map[string]interface{}) (driver.Rows, error) {
+	if searcher, ok := f.Base.(driver.Searcher); ok {
+		return searcher.Search(ctx, ddoc, index, query, options)
+	}
+	return nil, notImplemented("Search")
+}
+
+// SearchInfo calls the underlying driver's SearchInfo method.
+func (f *DBFeatures) SearchInfo(ctx context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+	if searcher, ok := f.Base.(driver.Searcher); ok {
+		return searcher.SearchInfo(ctx, ddoc, index)
+	}
+	return nil, notImplemented("SearchInfo")
+}
+
+// SearchAnalyze calls the underlying driver's SearchAnalyze method.
+func (f *DBFeatures) SearchAnalyze(ctx context.Context, text string) ([]string, error) {
+	if searcher, ok := f.Base.(driver.Searcher); ok {
+		return searcher.SearchAnalyze(ctx, text)
+	}
+	return nil, notImplemented("SearchAnalyze")
+}
+
+// Shards calls the underlying driver's Shards method.
+func (f *DBFeatures) Shards(ctx context.Context) (map[string][]string, error) {
+	if sharder, ok := f.Base.(driver.Sharder); ok {
+		return sharder.Shards(ctx)
+	}
+	return nil, notImplemented("Shards")
+}
+
+// DocShard calls the underlying driver's DocShard method.
+func (f *DBFeatures) DocShard(ctx context.Context, docID string) (*driver.DocShard, error) {
+	if sharder, ok := f.Base.(driver.Sharder); ok {
+		return sharder.DocShard(ctx, docID)
+	}
+	return nil, notImplemented("DocShard")
+}
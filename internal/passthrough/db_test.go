@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package passthrough
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// wrapper is a minimal wrapper, which records the documents written through
+// it.
+type wrapper struct {
+	driver.DB
+	DBFeatures
+	puts []string
+}
+
+func wrap(db driver.DB) *wrapper {
+	w := &wrapper{DB: db}
+	w.DBFeatures = DBFeatures{Base: db, Self: w}
+	return w
+}
+
+func (w *wrapper) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	w.puts = append(w.puts, docID)
+	return w.DB.Put(ctx, docID, doc, options)
+}
+
+type docSource []interface{}
+
+func (s *docSource) Next() (interface{}, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	doc := (*s)[0]
+	*s = (*s)[1:]
+	return doc, nil
+}
+
+func TestDBFeatures(t *testing.T) {
+	ctx := context.Background()
+	getter := &mock.DB{
+		GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+			return &driver.Document{
+				Rev:  "2-abc",
+				Body: io.NopCloser(strings.NewReader(`{"_id":"foo","_rev":"2-abc","_deleted":true}`)),
+			}, nil
+		},
+		PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+			return "1-xyz", nil
+		},
+	}
+
+	t.Run("passes through", func(t *testing.T) {
+		w := wrap(&mock.Sharder{
+			DB: &mock.DB{},
+			ShardsFunc: func(context.Context) (map[string][]string, error) {
+				return map[string][]string{"00000000-ffffffff": {"node1"}}, nil
+			},
+		})
+		shards, err := w.Shards(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shards) != 1 {
+			t.Errorf("Unexpected shards: %v", shards)
+		}
+	})
+	t.Run("not implemented", func(t *testing.T) {
+		_, err := wrap(&mock.DB{}).Search(ctx, "ddoc", "index", "q", nil)
+		testy.StatusError(t, "kivik: Search not supported by driver", http.StatusNotImplemented, err)
+	})
+	t.Run("GetRev falls back to Get", func(t *testing.T) {
+		rev, err := wrap(getter).GetRev(ctx, "foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "2-abc" {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+	t.Run("GetMeta falls back to Get", func(t *testing.T) {
+		meta, err := wrap(getter).GetMeta(ctx, "foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &driver.DocMeta{Rev: "2-abc", Deleted: true, Size: 44}
+		if d := testy.DiffInterface(want, meta); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("Count falls back to Find", func(t *testing.T) {
+		rows := 3
+		w := wrap(&mock.Finder{
+			DB: &mock.DB{},
+			FindFunc: func(_ context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+				if sel := query.(map[string]interface{})["selector"]; sel != "sel" {
+					t.Errorf("Unexpected selector: %v", sel)
+				}
+				return &mock.Rows{
+					NextFunc: func(*driver.Row) error {
+						if rows == 0 {
+							return io.EOF
+						}
+						rows--
+						return nil
+					},
+				}, nil
+			},
+		})
+		count, err := w.Count(ctx, "sel", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 3 {
+			t.Errorf("Unexpected count: %d", count)
+		}
+	})
+	t.Run("emulated writes go through the wrapper", func(t *testing.T) {
+		w := wrap(getter)
+		docs := docSource{
+			map[string]interface{}{"_id": "a"},
+			map[string]interface{}{"_id": "b"},
+		}
+		results, err := w.BulkDocsStream(ctx, &docs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Unexpected results: %v", results)
+		}
+		if _, err := w.Copy(ctx, "c", "foo", nil); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"a", "b", "c"}, w.puts); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("close without closer", func(t *testing.T) {
+		if err := wrap(&mock.DB{}).Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
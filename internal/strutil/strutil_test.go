@@ -0,0 +1,34 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package strutil
+
+import "testing"
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		s, sep        string
+		before, after string
+		found         bool
+	}{
+		{s: "ddoc/view", sep: "/", before: "ddoc", after: "view", found: true},
+		{s: "a/b/c", sep: "/", before: "a", after: "b/c", found: true},
+		{s: "ddoc", sep: "/", before: "ddoc"},
+		{s: "", sep: "/"},
+	}
+	for _, test := range tests {
+		before, after, found := Cut(test.s, test.sep)
+		if before != test.before || after != test.after || found != test.found {
+			t.Errorf("Cut(%q, %q) = %q, %q, %v", test.s, test.sep, before, after, found)
+		}
+	}
+}
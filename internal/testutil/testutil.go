@@ -0,0 +1,29 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package testutil provides helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// CheckError is like testy.StatusError, but does not end the test when err is
+// non-nil.
+func CheckError(t *testing.T, want string, status int, err error) {
+	t.Helper()
+	if err == nil || err.Error() != want || kivik.HTTPStatus(err) != status {
+		t.Errorf("Unexpected error: %v (status %d), expected %s (status %d)", err, kivik.HTTPStatus(err), want, status)
+	}
+}
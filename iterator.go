@@ -17,6 +17,7 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -58,6 +59,12 @@ type iter struct {
 	cancel func() // cancel function to exit context goroutine when iterator is closed
 
 	curVal interface{}
+
+	rows   int64                       // number of rows successfully read, accessed atomically
+	onDone func(rows int64, err error) // called once, after the iterator is closed
+
+	useNumber bool  // decode numbers as json.Number when scanning
+	codec     Codec // decodes scanned documents, if set
 }
 
 func (i *iter) rlock() (unlock func(), err error) {
@@ -168,6 +175,7 @@ func (i *iter) next() (doClose, ok bool) {
 	if i.err != nil {
 		return true, false
 	}
+	atomic.AddInt64(&i.rows, 1)
 	return false, true
 }
 
@@ -201,6 +209,13 @@ func (i *iter) close(err error) error {
 	if i.onClose != nil {
 		i.onClose()
 	}
+	if i.onDone != nil {
+		iterErr := i.err
+		if iterErr == io.EOF {
+			iterErr = nil
+		}
+		i.onDone(atomic.LoadInt64(&i.rows), iterErr)
+	}
 
 	return err
 }
@@ -215,3 +230,134 @@ func (i *iter) Err() error {
 	}
 	return i.err
 }
+
+// iterators is the set of iterators opened by a [Client] or [DB], which are
+// still open, so that they may be closed along with it.
+type iterators struct {
+	mu   sync.Mutex
+	open map[*iter]struct{}
+	err  error // non-nil once closeAll has been called
+}
+
+// add adds it to the set, or returns the error passed to closeAll, if the set
+// has already been closed.
+func (s *iterators) add(it *iter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.open == nil {
+		s.open = make(map[*iter]struct{})
+	}
+	s.open[it] = struct{}{}
+	return nil
+}
+
+func (s *iterators) remove(it *iter) {
+	s.mu.Lock()
+	delete(s.open, it)
+	s.mu.Unlock()
+}
+
+// closeAll closes all open iterators, such that their Err method returns err,
+// and causes any iterators subsequently added to be closed immediately.
+func (s *iterators) closeAll(err error) {
+	s.mu.Lock()
+	s.err = err
+	open := make([]*iter, 0, len(s.open))
+	for it := range s.open {
+		open = append(open, it)
+	}
+	s.mu.Unlock()
+	for _, it := range open {
+		_ = it.close(err)
+	}
+}
+
+// trackIterator registers it with the client, so that it is closed by
+// [Client.Close], and with any additional sets. It also reports the opening
+// of it to the client's metrics, if any, and arranges for its closing to be
+// reported as well.
+func (c *Client) trackIterator(op *Operation, it *iter, sets ...*iterators) {
+	if op.deadline != nil {
+		// Close the iterator when its default timeout expires, and release
+		// the timeout when it is closed.
+		deadline, cancel := op.deadline, op.cancelDeadline
+		go func() {
+			<-deadline.Done()
+			_ = it.close(deadline.Err())
+		}()
+		it.mu.Lock()
+		onClose := it.onClose
+		it.onClose = func() {
+			cancel()
+			if onClose != nil {
+				onClose()
+			}
+		}
+		if it.state == stateClosed {
+			cancel()
+		}
+		it.mu.Unlock()
+	}
+	sets = append([]*iterators{&c.iters}, sets...)
+	var done []func(rows int64, err error)
+	if m := c.metrics; m != nil {
+		m.IteratorOpened(op)
+		done = append(done, func(rows int64, err error) {
+			m.IteratorClosed(op, rows, err)
+		})
+	}
+	if l := c.slowQueries; l != nil && !op.start.IsZero() {
+		done = append(done, func(rows int64, err error) {
+			l.observe(op, op.start, rows, err)
+		})
+	}
+	it.mu.Lock()
+	if it.state == stateClosed {
+		// The context was cancelled before we got here.
+		err := it.err
+		it.mu.Unlock()
+		for _, fn := range done {
+			fn(0, err)
+		}
+		return
+	}
+	if len(done) > 0 {
+		it.onDone = func(rows int64, err error) {
+			for _, fn := range done {
+				fn(rows, err)
+			}
+		}
+	}
+	onClose := it.onClose
+	it.onClose = func() {
+		for _, s := range sets {
+			s.remove(it)
+		}
+		if onClose != nil {
+			onClose()
+		}
+	}
+	var closeErr error
+	for _, s := range sets {
+		if closeErr = s.add(it); closeErr != nil {
+			break
+		}
+	}
+	it.mu.Unlock()
+	if closeErr != nil {
+		// The client or database was closed before we got here.
+		_ = it.close(closeErr)
+	}
+}
+
+// trackIterator registers it with the database and its client, so that it is
+// closed by [DB.Close] or [Client.Close], and applies the database's decoding
+// options to it.
+func (db *DB) trackIterator(op *Operation, it *iter) {
+	it.useNumber = db.useNumber
+	it.codec = db.codec
+	db.client.trackIterator(op, it, &db.iters)
+}
@@ -0,0 +1,54 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// optionUseNumber is the option key used to pass [WithUseNumber] to [New] or
+// [Client.DB].
+const optionUseNumber = "kivik:useNumber"
+
+// WithUseNumber returns an option which, when passed to [New] or [Client.DB],
+// causes numbers scanned into interface{} values, such as the values of a
+// map[string]interface{}, to be decoded as [encoding/json.Number] rather than
+// float64. This preserves the precision of large integers, such as 64-bit
+// IDs, and of decimal values, which float64 cannot represent exactly. It
+// applies to the Scan methods of [ResultSet] and [Changes], to the result of
+// [DB.Get], and to documents which Kivik itself decodes and re-encodes, as
+// done by [DB.Update] and [DB.Upsert].
+func WithUseNumber() Options {
+	return Options{optionUseNumber: true}
+}
+
+// decodeJSON decodes the JSON value read from r into dest. If useNumber is
+// true, numbers are decoded into interface{} values as json.Number.
+func decodeJSON(r io.Reader, dest interface{}, useNumber bool) error {
+	dec := json.NewDecoder(r)
+	if useNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(dest)
+}
+
+// unmarshalJSON works like [encoding/json.Unmarshal], but decodes numbers
+// into interface{} values as json.Number if useNumber is true.
+func unmarshalJSON(data []byte, dest interface{}, useNumber bool) error {
+	if !useNumber {
+		return json.Unmarshal(data, dest)
+	}
+	return decodeJSON(bytes.NewReader(data), dest, true)
+}
@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+const bigDoc = `{"_id":"foo","_rev":"1-xxx","id":12345678901234567891,"price":0.1}`
+
+func TestWithUseNumber(t *testing.T) {
+	newDB := func(t *testing.T, clientOpts, dbOpts Options) *DB {
+		t.Helper()
+		client, err := NewClientFromDriverClient(&mock.Client{
+			DBFunc: func(_ string, opts map[string]interface{}) (driver.DB, error) {
+				if _, ok := opts[optionUseNumber]; ok {
+					return nil, errors.New("option passed to driver")
+				}
+				return &mock.DB{
+					GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+						return &driver.Document{Rev: "1-xxx", Body: body(bigDoc)}, nil
+					},
+					AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+						var done bool
+						return &mock.Rows{
+							NextFunc: func(row *driver.Row) error {
+								if done {
+									return io.EOF
+								}
+								done = true
+								row.ID = "foo"
+								row.Key = json.RawMessage(`12345678901234567891`)
+								row.Doc = strings.NewReader(bigDoc)
+								return nil
+							},
+						}, nil
+					},
+				}, nil
+			},
+		}, clientOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := client.DB("foo", dbOpts)
+		if err := db.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	want := map[string]interface{}{
+		"_id":   "foo",
+		"_rev":  "1-xxx",
+		"id":    json.Number("12345678901234567891"),
+		"price": json.Number("0.1"),
+	}
+
+	t.Run("default", func(t *testing.T) {
+		db := newDB(t, nil, nil)
+		var doc map[string]interface{}
+		if err := db.Get(context.Background(), "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := doc["id"].(float64); !ok {
+			t.Errorf("Expected float64, got %T", doc["id"])
+		}
+	})
+	t.Run("client option", func(t *testing.T) {
+		db := newDB(t, WithUseNumber(), nil)
+		var doc map[string]interface{}
+		if err := db.Get(context.Background(), "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(want, doc); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("db option", func(t *testing.T) {
+		db := newDB(t, nil, WithUseNumber())
+		rows := db.AllDocs(context.Background())
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(want, doc); d != nil {
+			t.Error(d)
+		}
+		var key interface{}
+		if err := rows.ScanKey(&key); err != nil {
+			t.Fatal(err)
+		}
+		if key != json.Number("12345678901234567891") {
+			t.Errorf("Unexpected key: %v (%T)", key, key)
+		}
+		_ = rows.Close()
+	})
+}
+
+func TestUpdatePreservesNumbers(t *testing.T) {
+	var stored []byte
+	db := &DB{
+		client:    &Client{},
+		useNumber: true,
+		driverDB: &mock.DB{
+			GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				return &driver.Document{Rev: "1-xxx", Body: body(bigDoc)}, nil
+			},
+			PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+				var err error
+				stored, err = json.Marshal(doc)
+				return "2-xxx", err
+			},
+		},
+	}
+	_, err := db.Update(context.Background(), "foo", func(doc json.RawMessage) (interface{}, error) {
+		return doc, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffAsJSON([]byte(bigDoc), stored); d != nil {
+		t.Error(d)
+	}
+	if !strings.Contains(string(stored), `"id":12345678901234567891`) {
+		t.Errorf("Number not preserved: %s", stored)
+	}
+}
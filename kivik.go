@@ -15,6 +15,7 @@ package kivik
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -29,11 +30,36 @@ type Client struct {
 	dsn          string
 	driverName   string
 	driverClient driver.Client
+	retryPolicy  *RetryPolicy
+	rateLimiter  RateLimiter
+	middleware   []Middleware
+	metrics      Metrics
+	slowQueries  *slowQueryLog
+	failover     *FailoverPolicy
+	useNumber    bool
+	codec        Codec
+	timeouts     *Timeouts
+	idGenerator  IDFunc
+
+	// uuidOptions are the defaults for UUIDs, and sequentialUUIDs its state
+	// for the sequential algorithm.
+	uuidOptions     Options
+	sequentialUUIDs sequentialUUIDs
+
+	// version caches the result of Version, and versionCall is the request
+	// for it in progress, if any, which concurrent callers wait for.
+	versionMu   sync.Mutex
+	version     *Version
+	versionCall *versionCall
+
+	// inFlight is the number of queries in progress, for Stats.
+	inFlight int64
 
 	// closed will be non-0 when the client has been closed
 	closed int32
 	mu     sync.Mutex
 	wg     sync.WaitGroup
+	iters  iterators
 }
 
 // Options is a collection of options. The keys and values are backend specific.
@@ -71,15 +97,124 @@ func New(driverName, dataSourceName string, options ...Options) (*Client, error)
 	if driveri == nil {
 		return nil, &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: unknown driver %q (forgotten import?)", driverName)}
 	}
-	client, err := driveri.NewClient(dataSourceName, mergeOptions(options...))
+	return newClient(driverName, driveri, dataSourceName, options)
+}
+
+// NewClientFromDriver creates a new client object using the driver d directly,
+// rather than one made available by [Register]. This allows several
+// differently-configured instances of the same driver to be used at once,
+// and drivers to be provided by dependency injection. [Client.Driver] returns
+// an empty string for clients created this way.
+func NewClientFromDriver(d driver.Driver, dataSourceName string, options ...Options) (*Client, error) {
+	if d == nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: driver is nil"}
+	}
+	return newClient("", d, dataSourceName, options)
+}
+
+// NewClientFromDriverClient creates a new client object which wraps the
+// already-connected driver client dc. [Client.Driver] and [Client.DSN] return
+// empty strings for clients created this way. Only options which configure
+// the Client itself, such as [WithRetry], are meaningful; any others are
+// ignored. As there is no driver with which to connect to other servers, the
+// [WithFailover] option is not supported.
+func NewClientFromDriverClient(dc driver.Client, options ...Options) (*Client, error) {
+	if dc == nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: driver client is nil"}
+	}
+	c := &Client{}
+	_ = c.applyOptions(mergeOptions(options...))
+	if c.failover != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: failover requires a driver"}
+	}
+	c.driverClient = dc
+	return c, nil
+}
+
+func newClient(driverName string, driveri driver.Driver, dataSourceName string, options []Options) (*Client, error) {
+	c := &Client{
+		dsn:        dataSourceName,
+		driverName: driverName,
+	}
+	opts := c.applyOptions(mergeOptions(options...))
+	client, err := driveri.NewClient(dataSourceName, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
-		dsn:          dataSourceName,
-		driverName:   driverName,
-		driverClient: client,
-	}, nil
+	if c.failover != nil {
+		client, err = newFailoverClient(driveri, dataSourceName, client, c.failover, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.driverClient = client
+	return c, nil
+}
+
+// applyOptions consumes the options which configure the Client itself, such
+// as [WithRetry], [WithRateLimiter], [WithMetrics], [WithSlowQueryLog],
+// [WithFailover] and [WithUUIDAlgorithm], and returns the remaining options,
+// which are meant for the driver.
+func (c *Client) applyOptions(opts Options) Options {
+	if policy, ok := opts[optionRetry].(*RetryPolicy); ok {
+		c.retryPolicy = policy
+	}
+	if limiter, ok := opts[optionRateLimiter].(RateLimiter); ok {
+		c.rateLimiter = limiter
+	}
+	if m, ok := opts[optionMetrics].(Metrics); ok {
+		c.metrics = m
+		c.middleware = append(c.middleware, metricsMiddleware(m))
+	}
+	if mw, ok := opts[optionLogger].(Middleware); ok {
+		c.middleware = append(c.middleware, mw)
+	}
+	if d, ok := opts[optionDebugger].(*Debugger); ok {
+		c.middleware = append(c.middleware, debugMiddleware(d))
+	}
+	if l, ok := opts[optionSlowQueryLog].(*slowQueryLog); ok {
+		c.slowQueries = l
+		c.middleware = append(c.middleware, l.middleware())
+	}
+	if policy, ok := opts[optionFailover].(*FailoverPolicy); ok {
+		c.failover = policy
+	}
+	if useNumber, _ := opts[optionUseNumber].(bool); useNumber {
+		c.useNumber = true
+	}
+	if codec, ok := opts[optionCodec].(Codec); ok {
+		c.codec = codec
+	}
+	if t, ok := opts[optionTimeouts].(Timeouts); ok {
+		c.timeouts = &t
+	}
+	if fn, ok := opts[optionIDGenerator].(IDFunc); ok {
+		c.idGenerator = fn
+	}
+	for _, key := range []string{optionUUIDAlgorithm, optionUTCIDSuffix, optionServerUUIDs} {
+		if v, ok := opts[key]; ok {
+			if c.uuidOptions == nil {
+				c.uuidOptions = Options{}
+			}
+			c.uuidOptions[key] = v
+			delete(opts, key)
+		}
+	}
+	delete(opts, optionRetry)
+	delete(opts, optionRateLimiter)
+	delete(opts, optionMetrics)
+	delete(opts, optionLogger)
+	delete(opts, optionDebugger)
+	delete(opts, optionSlowQueryLog)
+	delete(opts, optionFailover)
+	delete(opts, optionUseNumber)
+	delete(opts, optionCodec)
+	delete(opts, optionTimeouts)
+	delete(opts, optionIDGenerator)
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
 }
 
 // Driver returns the name of the driver string used to connect this client.
@@ -87,7 +222,8 @@ func (c *Client) Driver() string {
 	return c.driverName
 }
 
-// DSN returns the data source name used to connect this client.
+// DSN returns the data source name used to connect this client. It may
+// contain credentials; use [Client.RedactedDSN] for logging.
 func (c *Client) DSN() string {
 	return c.dsn
 }
@@ -116,40 +252,122 @@ func (c *Client) startQuery() error {
 		return ErrClientClosed
 	}
 	c.wg.Add(1)
+	atomic.AddInt64(&c.inFlight, 1)
 	return nil
 }
 
 func (c *Client) endQuery() {
 	c.mu.Lock()
+	atomic.AddInt64(&c.inFlight, -1)
 	c.wg.Done()
 	c.mu.Unlock()
 }
 
-// Version returns version and vendor info about the backend.
+// Version returns version and vendor info about the backend. The result is
+// cached, so only the first call, or the first after [Client.ResetVersion],
+// requests it from the server, and concurrent first calls share a single
+// request. The cache is discarded when the client is closed.
 func (c *Client) Version(ctx context.Context) (*Version, error) {
 	if err := c.startQuery(); err != nil {
 		return nil, err
 	}
 	defer c.endQuery()
-	ver, err := c.driverClient.Version(ctx)
-	if err != nil {
-		return nil, err
+	for {
+		c.versionMu.Lock()
+		if c.version != nil {
+			v := c.version.copy()
+			c.versionMu.Unlock()
+			return v, nil
+		}
+		call := c.versionCall
+		if call == nil {
+			call = &versionCall{done: make(chan struct{})}
+			c.versionCall = call
+			c.versionMu.Unlock()
+			return c.fetchVersion(ctx, call)
+		}
+		c.versionMu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		if call.err == nil {
+			return call.version.copy(), nil
+		}
+		// If the request failed only because its caller gave up, try again
+		// with this caller's context.
+		if !errors.Is(call.err, context.Canceled) && !errors.Is(call.err, context.DeadlineExceeded) {
+			return nil, call.err
+		}
+	}
+}
+
+// versionCall is a request for the server version, shared by concurrent
+// callers of [Client.Version].
+type versionCall struct {
+	done    chan struct{}
+	version *Version
+	err     error
+}
+
+// fetchVersion requests the server version on behalf of call, and caches it,
+// unless the cache was reset in the meantime.
+func (c *Client) fetchVersion(ctx context.Context, call *versionCall) (*Version, error) {
+	var ver *driver.Version
+	call.err = c.invoke(ctx, &Operation{Method: "Version", ReadOnly: true}, func(ctx context.Context) (err error) {
+		ver, err = c.driverClient.Version(ctx)
+		return err
+	})
+	if call.err == nil {
+		v := Version(*ver)
+		call.version = &v
+	}
+	c.versionMu.Lock()
+	if c.versionCall == call {
+		c.versionCall = nil
+		if call.err == nil {
+			c.version = call.version
+		}
 	}
-	v := &Version{}
-	*v = Version(*ver)
-	return v, nil
+	c.versionMu.Unlock()
+	close(call.done)
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.version.copy(), nil
+}
+
+// ResetVersion discards the version cached by [Client.Version], so that the
+// next call to Client.Version or [Client.HasFeature] fetches it again, for
+// instance after the server has been upgraded.
+func (c *Client) ResetVersion() {
+	c.versionMu.Lock()
+	c.version = nil
+	c.versionCall = nil
+	c.versionMu.Unlock()
 }
 
 // DB returns a handle to the requested database. Any options parameters
 // passed are merged, with later values taking precidence. If any errors occur
 // at this stage, they are deferred, or may be checked directly with [DB.Err].
 func (c *Client) DB(dbName string, options ...Options) *DB {
-	db, err := c.driverClient.DB(dbName, mergeOptions(options...))
+	opts := mergeOptions(options...)
+	useNumber, _ := opts[optionUseNumber].(bool)
+	codec := c.codec
+	if v, ok := opts[optionCodec]; ok {
+		codec, _ = v.(Codec)
+	}
+	delete(opts, optionUseNumber)
+	delete(opts, optionCodec)
+	db, err := c.driverClient.DB(dbName, opts)
 	return &DB{
-		client:   c,
-		name:     dbName,
-		driverDB: db,
-		err:      err,
+		client:    c,
+		name:      dbName,
+		driverDB:  db,
+		err:       err,
+		useNumber: c.useNumber || useNumber,
+		codec:     codec,
 	}
 }
 
@@ -159,7 +377,13 @@ func (c *Client) AllDBs(ctx context.Context, options ...Options) ([]string, erro
 		return nil, err
 	}
 	defer c.endQuery()
-	return c.driverClient.AllDBs(ctx, mergeOptions(options...))
+	var dbs []string
+	opts := mergeOptions(options...)
+	err := c.invoke(ctx, &Operation{Method: "AllDBs", Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		dbs, err = c.driverClient.AllDBs(ctx, opts)
+		return err
+	})
+	return dbs, err
 }
 
 // DBExists returns true if the specified database exists.
@@ -168,16 +392,33 @@ func (c *Client) DBExists(ctx context.Context, dbName string, options ...Options
 		return false, err
 	}
 	defer c.endQuery()
-	return c.driverClient.DBExists(ctx, dbName, mergeOptions(options...))
+	var exists bool
+	opts := mergeOptions(options...)
+	err := c.invoke(ctx, &Operation{Method: "DBExists", DB: dbName, Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		exists, err = c.driverClient.DBExists(ctx, dbName, opts)
+		return err
+	})
+	return exists, err
 }
 
 // CreateDB creates a DB of the requested name.
+//
+// The number of shards and replicas, and whether the database is
+// partitioned, may be set with the [Shards], [Replicas] and [Partitioned]
+// options, or with [CreateDBOptions]. These are validated before being passed
+// to the driver, and an invalid value results in a 400 Bad Request error.
 func (c *Client) CreateDB(ctx context.Context, dbName string, options ...Options) error {
+	opts := mergeOptions(options...)
+	if err := normalizeCreateDBOptions(opts); err != nil {
+		return err
+	}
 	if err := c.startQuery(); err != nil {
 		return err
 	}
 	defer c.endQuery()
-	return c.driverClient.CreateDB(ctx, dbName, mergeOptions(options...))
+	return c.invoke(ctx, &Operation{Method: "CreateDB", DB: dbName, Options: opts}, func(ctx context.Context) error {
+		return c.driverClient.CreateDB(ctx, dbName, opts)
+	})
 }
 
 // DestroyDB deletes the requested DB.
@@ -186,7 +427,10 @@ func (c *Client) DestroyDB(ctx context.Context, dbName string, options ...Option
 		return err
 	}
 	defer c.endQuery()
-	return c.driverClient.DestroyDB(ctx, dbName, mergeOptions(options...))
+	opts := mergeOptions(options...)
+	return c.invoke(ctx, &Operation{Method: "DestroyDB", DB: dbName, Options: opts}, func(ctx context.Context) error {
+		return c.driverClient.DestroyDB(ctx, dbName, opts)
+	})
 }
 
 // Authenticate authenticates the client with the passed authenticator, which
@@ -197,8 +441,10 @@ func (c *Client) Authenticate(ctx context.Context, a interface{}) error {
 		return err
 	}
 	defer c.endQuery()
-	if auth, ok := c.driverClient.(driver.Authenticator); ok {
-		return auth.Authenticate(ctx, a)
+	if auth, ok := optional(c.driverClient, (*driver.Authenticator)(nil)).(driver.Authenticator); ok {
+		return c.invoke(ctx, &Operation{Method: "Authenticate"}, func(ctx context.Context) error {
+			return auth.Authenticate(ctx, a)
+		})
 	}
 	return &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support authentication"}
 }
@@ -234,11 +480,15 @@ func (c *Client) fallbackDBsStats(ctx context.Context, dbnames []string) ([]*DBS
 }
 
 func (c *Client) nativeDBsStats(ctx context.Context, dbnames []string) ([]*DBStats, error) {
-	statser, ok := c.driverClient.(driver.DBsStatser)
+	statser, ok := optional(c.driverClient, (*driver.DBsStatser)(nil)).(driver.DBsStatser)
 	if !ok {
 		return nil, &Error{Status: http.StatusNotImplemented, Message: "kivik: not supported by driver"}
 	}
-	stats, err := statser.DBsStats(ctx, dbnames)
+	var stats []*driver.DBStats
+	err := c.invoke(ctx, &Operation{Method: "DBsStats", ReadOnly: true}, func(ctx context.Context) (err error) {
+		stats, err = statser.DBsStats(ctx, dbnames)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -258,23 +508,36 @@ func (c *Client) Ping(ctx context.Context) (bool, error) {
 		return false, err
 	}
 	defer c.endQuery()
-	if pinger, ok := c.driverClient.(driver.Pinger); ok {
-		return pinger.Ping(ctx)
+	if pinger, ok := optional(c.driverClient, (*driver.Pinger)(nil)).(driver.Pinger); ok {
+		var up bool
+		err := c.invoke(ctx, &Operation{Method: "Ping", ReadOnly: true}, func(ctx context.Context) (err error) {
+			up, err = pinger.Ping(ctx)
+			return err
+		})
+		return up, err
 	}
-	_, err := c.driverClient.Version(ctx)
+	err := c.invoke(ctx, &Operation{Method: "Ping", ReadOnly: true}, func(ctx context.Context) error {
+		_, err := c.driverClient.Version(ctx)
+		return err
+	})
 	return err == nil, err
 }
 
 // Close cleans up any resources used by Client. Close is safe to call
 // concurrently with other operations and will block until all other operations
-// finish. After calling Close, any other client operations will return
-// ErrClientClosed.
+// finish. Any open iterators, such as [ResultSet] or [Changes], are closed,
+// and their Err method will return ErrClientClosed. After calling Close, any
+// other client operations will return ErrClientClosed. If the driver client
+// implements [driver.ClientCloser], its Close method is called last, to
+// release any resources held by the driver.
 func (c *Client) Close() error {
 	c.mu.Lock()
 	atomic.StoreInt32(&c.closed, 1)
 	c.mu.Unlock()
+	c.iters.closeAll(ErrClientClosed)
 	c.wg.Wait()
-	if closer, ok := c.driverClient.(driver.ClientCloser); ok {
+	c.ResetVersion()
+	if closer, ok := optional(c.driverClient, (*driver.ClientCloser)(nil)).(driver.ClientCloser); ok {
 		return closer.Close()
 	}
 	return nil
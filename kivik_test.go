@@ -88,6 +88,85 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewClientFromDriver(t *testing.T) {
+	t.Run("nil driver", func(t *testing.T) {
+		_, err := NewClientFromDriver(nil, "oink")
+		testy.StatusError(t, "kivik: driver is nil", http.StatusBadRequest, err)
+	})
+	t.Run("connection error", func(t *testing.T) {
+		_, err := NewClientFromDriver(&mock.Driver{
+			NewClientFunc: func(string, map[string]interface{}) (driver.Client, error) {
+				return nil, errors.New("connection error")
+			},
+		}, "oink")
+		testy.StatusError(t, "connection error", http.StatusInternalServerError, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		var gotOpts map[string]interface{}
+		d := &mock.Driver{
+			NewClientFunc: func(dsn string, opts map[string]interface{}) (driver.Client, error) {
+				gotOpts = opts
+				return &mock.Client{ID: dsn}, nil
+			},
+		}
+		result, err := NewClientFromDriver(d, "oink", WithRetry(RetryPolicy{MaxAttempts: 2}), Param("foo", "bar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &Client{
+			dsn:          "oink",
+			driverClient: &mock.Client{ID: "oink"},
+			retryPolicy:  &RetryPolicy{MaxAttempts: 2},
+		}
+		if d := testy.DiffInterface(expected, result); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"foo": "bar"}, gotOpts); d != nil {
+			t.Errorf("Unexpected driver options:\n%s", d)
+		}
+		// A second instance of the same driver is independent of the first.
+		other, err := NewClientFromDriver(d, "moo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := other.driverClient.(*mock.Client).ID; id != "moo" {
+			t.Errorf("Unexpected client: %s", id)
+		}
+	})
+}
+
+func TestNewClientFromDriverClient(t *testing.T) {
+	t.Run("nil client", func(t *testing.T) {
+		_, err := NewClientFromDriverClient(nil)
+		testy.StatusError(t, "kivik: driver client is nil", http.StatusBadRequest, err)
+	})
+	t.Run("failover", func(t *testing.T) {
+		_, err := NewClientFromDriverClient(&mock.Client{}, WithFailover(FailoverPolicy{DSNs: []string{"moo"}}))
+		testy.StatusError(t, "kivik: failover requires a driver", http.StatusBadRequest, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		dc := &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				return &driver.Version{Version: "1.2.3"}, nil
+			},
+		}
+		c, err := NewClientFromDriverClient(dc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Driver() != "" || c.DSN() != "" {
+			t.Errorf("Unexpected driver name or DSN: %q, %q", c.Driver(), c.DSN())
+		}
+		version, err := c.Version(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.Version != "1.2.3" {
+			t.Errorf("Unexpected version: %s", version.Version)
+		}
+	})
+}
+
 func TestClientGetters(t *testing.T) {
 	driverName := "foo"
 	dsn := "bar"
@@ -398,6 +477,46 @@ func TestCreateDB(t *testing.T) {
 			status: http.StatusServiceUnavailable,
 			err:    errClientClosed,
 		},
+		{
+			name: "cluster options",
+			client: &Client{
+				driverClient: &mock.Client{
+					CreateDBFunc: func(_ context.Context, _ string, opts map[string]interface{}) error {
+						expectedOpts := map[string]interface{}{"q": 8, "n": 3, "partitioned": true}
+						if d := testy.DiffInterface(expectedOpts, opts); d != nil {
+							return fmt.Errorf("Unexpected opts:\n%s", d)
+						}
+						return nil
+					},
+				},
+			},
+			dbName: "foo",
+			opts:   mergeOptions(Shards(8), Param("n", "3"), Param("partitioned", "true")),
+		},
+		{
+			name:   "invalid shards",
+			client: &Client{},
+			dbName: "foo",
+			opts:   Shards(0),
+			status: http.StatusBadRequest,
+			err:    "kivik: invalid q option 0: must be a positive integer",
+		},
+		{
+			name:   "invalid replicas",
+			client: &Client{},
+			dbName: "foo",
+			opts:   Param("n", 1.5),
+			status: http.StatusBadRequest,
+			err:    "kivik: invalid n option 1.5: must be a positive integer",
+		},
+		{
+			name:   "invalid partitioned",
+			client: &Client{},
+			dbName: "foo",
+			opts:   Param("partitioned", "yes"),
+			status: http.StatusBadRequest,
+			err:    `kivik: invalid partitioned option "yes"`,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -1174,4 +1293,52 @@ func TestClientClose(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("closes open iterators", func(t *testing.T) {
+		t.Parallel()
+
+		var feedClosed bool
+		c := &Client{
+			driverClient: &mock.Client{
+				DBFunc: func(string, map[string]interface{}) (driver.DB, error) {
+					return &mock.DB{
+						AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+							return &mock.Rows{
+								NextFunc: func(*driver.Row) error { return nil },
+								CloseFunc: func() error {
+									feedClosed = true
+									return nil
+								},
+							}, nil
+						},
+					}, nil
+				},
+			},
+		}
+		rs := c.DB("foo").AllDocs(context.Background())
+		if !rs.Next() {
+			t.Fatalf("Next() returned false: %v", rs.Err())
+		}
+
+		done := make(chan struct{})
+		go func() {
+			_ = c.Close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("client.Close() blocked on an open iterator")
+		}
+
+		if !feedClosed {
+			t.Error("driver rows were not closed")
+		}
+		if rs.Next() {
+			t.Error("Next() should return false after the client is closed")
+		}
+		if err := rs.Err(); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
 }
@@ -0,0 +1,98 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+)
+
+// MaintenanceMode is the value of a node's couchdb/maintenance_mode config
+// setting.
+//
+// See https://docs.couchdb.org/en/stable/config/couchdb.html#couchdb/maintenance_mode
+type MaintenanceMode string
+
+// Maintenance modes understood by CouchDB.
+const (
+	// MaintenanceOff is normal operation.
+	MaintenanceOff MaintenanceMode = "false"
+	// MaintenanceOn stops the node from responding to clustered requests from
+	// other nodes, and makes its /_up endpoint return 404.
+	MaintenanceOn MaintenanceMode = "true"
+	// MaintenanceNoLB makes only the /_up endpoint return 404, so that load
+	// balancers stop routing to the node, while it continues to take part in
+	// the cluster.
+	MaintenanceNoLB MaintenanceMode = "nolb"
+)
+
+const (
+	maintenanceSection = "couchdb"
+	maintenanceKey     = "maintenance_mode"
+)
+
+// MaintenanceMode returns the maintenance mode of the named node. An unset
+// value is reported as [MaintenanceOff]. If node is empty, the node which
+// handles the request is queried.
+func (c *Client) MaintenanceMode(ctx context.Context, node string) (MaintenanceMode, error) {
+	if node == "" {
+		node = localNode
+	}
+	value, err := c.ConfigValue(ctx, node, maintenanceSection, maintenanceKey)
+	if HTTPStatus(err) == http.StatusNotFound {
+		return MaintenanceOff, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return MaintenanceOff, nil
+	}
+	return MaintenanceMode(value), nil
+}
+
+// SetMaintenanceMode sets the maintenance mode of the named node, and returns
+// the previous mode. If node is empty, the node which handles the request is
+// updated.
+func (c *Client) SetMaintenanceMode(ctx context.Context, node string, mode MaintenanceMode) (MaintenanceMode, error) {
+	switch mode {
+	case MaintenanceOff, MaintenanceOn, MaintenanceNoLB:
+	default:
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: invalid maintenance mode: " + string(mode)}
+	}
+	if node == "" {
+		node = localNode
+	}
+	old, err := c.SetConfigValue(ctx, node, maintenanceSection, maintenanceKey, string(mode))
+	if err != nil {
+		return "", err
+	}
+	if old == "" {
+		return MaintenanceOff, nil
+	}
+	return MaintenanceMode(old), nil
+}
+
+// SafeToRestart reports whether the named node may be restarted without
+// interrupting internal replication, that is, whether it has no pending
+// internal replication jobs. Callers performing a rolling restart will
+// typically put the node into maintenance mode first, then poll SafeToRestart
+// until it returns true. If node is empty, the node which handles the request
+// is checked.
+func (c *Client) SafeToRestart(ctx context.Context, node string) (bool, error) {
+	system, err := c.NodeSystem(ctx, node)
+	if err != nil {
+		return false, err
+	}
+	return system.InternalReplicationJobs == 0, nil
+}
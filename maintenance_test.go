@@ -0,0 +1,154 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	type tst struct {
+		client   *Client
+		node     string
+		expected MaintenanceMode
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("not supported", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support Config interface",
+	})
+	tests.Add("unset", tst{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigValueFunc: func(_ context.Context, node, section, key string) (string, error) {
+				if node != "_local" || section != "couchdb" || key != "maintenance_mode" {
+					t.Errorf("Unexpected request: %s/%s/%s", node, section, key)
+				}
+				return "", &Error{Status: http.StatusNotFound, Message: "unknown_config_value"}
+			},
+		}},
+		expected: MaintenanceOff,
+	})
+	tests.Add("nolb", tst{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigValueFunc: func(context.Context, string, string, string) (string, error) {
+				return "nolb", nil
+			},
+		}},
+		node:     "node1@127.0.0.1",
+		expected: MaintenanceNoLB,
+	})
+	tests.Add("error", tst{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigValueFunc: func(context.Context, string, string, string) (string, error) {
+				return "", &Error{Status: http.StatusUnauthorized, Message: "unauthorized"}
+			},
+		}},
+		status: http.StatusUnauthorized,
+		err:    "unauthorized",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		mode, err := test.client.MaintenanceMode(context.Background(), test.node)
+		if mode != test.expected {
+			t.Errorf("Unexpected mode: %s", mode)
+		}
+		testy.StatusError(t, test.err, test.status, err)
+	})
+}
+
+func TestSetMaintenanceMode(t *testing.T) {
+	type tst struct {
+		client   *Client
+		mode     MaintenanceMode
+		expected MaintenanceMode
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("invalid mode", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		mode:   "maybe",
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid maintenance mode: maybe",
+	})
+	tests.Add("previously unset", tst{
+		client: &Client{driverClient: &mock.Configer{
+			SetConfigValueFunc: func(_ context.Context, node, section, key, value string) (string, error) {
+				if node != "_local" || section != "couchdb" || key != "maintenance_mode" || value != "true" {
+					t.Errorf("Unexpected request: %s/%s/%s = %s", node, section, key, value)
+				}
+				return "", nil
+			},
+		}},
+		mode:     MaintenanceOn,
+		expected: MaintenanceOff,
+	})
+	tests.Add("previously on", tst{
+		client: &Client{driverClient: &mock.Configer{
+			SetConfigValueFunc: func(context.Context, string, string, string, string) (string, error) {
+				return "true", nil
+			},
+		}},
+		mode:     MaintenanceOff,
+		expected: MaintenanceOn,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		old, err := test.client.SetMaintenanceMode(context.Background(), "", test.mode)
+		if old != test.expected {
+			t.Errorf("Unexpected previous mode: %s", old)
+		}
+		testy.StatusError(t, test.err, test.status, err)
+	})
+}
+
+func TestSafeToRestart(t *testing.T) {
+	type tst struct {
+		system   string
+		expected bool
+	}
+	tests := testy.NewTable()
+	tests.Add("idle", tst{
+		system:   `{"internal_replication_jobs":0}`,
+		expected: true,
+	})
+	tests.Add("replicating", tst{
+		system:   `{"internal_replication_jobs":3}`,
+		expected: false,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		c := &Client{driverClient: &mock.NodeInspector{
+			NodeSystemFunc: func(context.Context, string) (json.RawMessage, error) {
+				return json.RawMessage(test.system), nil
+			},
+		}}
+		safe, err := c.SafeToRestart(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if safe != test.expected {
+			t.Errorf("Unexpected result: %t", safe)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives measurements about the operations performed by a [Client].
+// Implementations must be safe for concurrent use.
+//
+// Kivik does not depend on any particular metrics library. A Prometheus
+// adapter, for example, might look like:
+//
+//	type promMetrics struct {
+//	    ops      *prometheus.CounterVec   // labels: method, status
+//	    duration *prometheus.HistogramVec // labels: method
+//	    rows     *prometheus.CounterVec   // labels: method
+//	    open     prometheus.Gauge
+//	}
+//
+//	func (m *promMetrics) ObserveOperation(op *kivik.Operation, err error, d time.Duration) {
+//	    m.ops.WithLabelValues(op.Method, strconv.Itoa(kivik.HTTPStatus(err))).Inc()
+//	    m.duration.WithLabelValues(op.Method).Observe(d.Seconds())
+//	}
+//
+//	func (m *promMetrics) IteratorOpened(*kivik.Operation) { m.open.Inc() }
+//
+//	func (m *promMetrics) IteratorClosed(op *kivik.Operation, rows int64, _ error) {
+//	    m.open.Dec()
+//	    m.rows.WithLabelValues(op.Method).Add(float64(rows))
+//	}
+type Metrics interface {
+	// ObserveOperation is called once each request to the driver completes,
+	// including any retries. err is the error returned to the caller, if any;
+	// [HTTPStatus] may be used to derive a status label.
+	ObserveOperation(op *Operation, err error, duration time.Duration)
+	// IteratorOpened is called when an operation returns an open iterator,
+	// such as the result of [DB.AllDocs] or [DB.Changes].
+	IteratorOpened(op *Operation)
+	// IteratorClosed is called exactly once for each call to IteratorOpened,
+	// once the iterator has been closed, with the number of rows read and the
+	// iteration error, if any.
+	IteratorClosed(op *Operation, rows int64, err error)
+}
+
+// optionMetrics is the option key used to pass [Metrics] to [New].
+const optionMetrics = "kivik:metrics"
+
+// WithMetrics returns an option which, when passed to [New], causes the client
+// to report metrics about each operation to m.
+func WithMetrics(m Metrics) Options {
+	return Options{optionMetrics: m}
+}
+
+// metricsMiddleware returns middleware which reports the duration and
+// outcome of each operation to m.
+func metricsMiddleware(m Metrics) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			m.ObserveOperation(op, err, time.Since(start))
+			return err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type recordedOp struct {
+	Method string
+	Status int
+}
+
+type recordedIter struct {
+	Method string
+	Rows   int64
+	Err    string
+}
+
+type testMetrics struct {
+	mu     sync.Mutex
+	ops    []recordedOp
+	open   int
+	closed []recordedIter
+}
+
+var _ Metrics = &testMetrics{}
+
+func (m *testMetrics) ObserveOperation(op *Operation, err error, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = append(m.ops, recordedOp{Method: op.Method, Status: HTTPStatus(err)})
+}
+
+func (m *testMetrics) IteratorOpened(*Operation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open++
+}
+
+func (m *testMetrics) IteratorClosed(op *Operation, rows int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open--
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	m.closed = append(m.closed, recordedIter{Method: op.Method, Rows: rows, Err: msg})
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		m := &testMetrics{}
+		c := &Client{}
+		opts := c.applyOptions(mergeOptions(WithMetrics(m)))
+		if opts != nil {
+			t.Errorf("Expected no driver options, got %v", opts)
+		}
+		if c.metrics != m {
+			t.Errorf("Metrics not set")
+		}
+		if len(c.middleware) != 1 {
+			t.Errorf("Expected metrics middleware to be installed")
+		}
+	})
+	t.Run("operations", func(t *testing.T) {
+		m := &testMetrics{}
+		client := &Client{
+			driverClient: &mock.Client{
+				CreateDBFunc: func(context.Context, string, map[string]interface{}) error {
+					return nil
+				},
+				AllDBsFunc: func(context.Context, map[string]interface{}) ([]string, error) {
+					return nil, &Error{Status: http.StatusServiceUnavailable, Message: "down"}
+				},
+			},
+		}
+		client.applyOptions(WithMetrics(m))
+		_ = client.CreateDB(context.Background(), "foo")
+		_, _ = client.AllDBs(context.Background())
+		want := []recordedOp{
+			{Method: "CreateDB", Status: 0},
+			{Method: "AllDBs", Status: http.StatusServiceUnavailable},
+		}
+		if d := testy.DiffInterface(want, m.ops); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("iterators", func(t *testing.T) {
+		m := &testMetrics{}
+		remaining := 3
+		db := &DB{
+			client: &Client{},
+			name:   "foo",
+			driverDB: &mock.DB{
+				AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+					return &mock.Rows{
+						NextFunc: func(*driver.Row) error {
+							if remaining == 0 {
+								return io.EOF
+							}
+							remaining--
+							return nil
+						},
+					}, nil
+				},
+				ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+					return &mock.Changes{
+						NextFunc: func(*driver.Change) error {
+							return errors.New("feed failure")
+						},
+					}, nil
+				},
+			},
+		}
+		db.client.applyOptions(WithMetrics(m))
+
+		rows := db.AllDocs(context.Background())
+		if m.open != 1 {
+			t.Errorf("Expected 1 open iterator, got %d", m.open)
+		}
+		for rows.Next() { //nolint:revive // intentional empty block
+		}
+		changes := db.Changes(context.Background())
+		for changes.Next() { //nolint:revive // intentional empty block
+		}
+		_ = changes.Close()
+
+		if m.open != 0 {
+			t.Errorf("Expected no open iterators, got %d", m.open)
+		}
+		want := []recordedIter{
+			{Method: "AllDocs", Rows: 3},
+			{Method: "Changes", Err: "feed failure"},
+		}
+		if d := testy.DiffInterface(want, m.closed); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"time"
+)
+
+// Operation describes a single request made by a [Client] to its driver.
+type Operation struct {
+	// Method is the name of the Kivik method which initiated the request,
+	// such as "Get" or "AllDBs".
+	Method string
+	// DB is the name of the database, or empty for server-level operations.
+	DB string
+	// DocID is the document ID, for operations on a single document.
+	DocID string
+	// Options are the options passed to the driver. Middleware must not
+	// modify them.
+	Options Options
+	// ReadOnly is true for operations which do not modify data on the server,
+	// and are therefore safe to retry.
+	ReadOnly bool
+
+	// iterator is true for operations which return an iterator.
+	iterator bool
+	// feed is true for iterator operations which always return a long-lived
+	// feed, whatever their feed option.
+	feed bool
+	// deadline is the context carrying the default timeout of an iterator
+	// operation, which is released by cancelDeadline when the iterator is
+	// closed.
+	deadline       context.Context
+	cancelDeadline context.CancelFunc
+	// start is the time at which an iterator operation began, recorded for
+	// the slow query log.
+	start time.Time
+}
+
+// Handler performs the request described by op. ctx must be passed on to the
+// driver unaltered, or derived from the original context.
+type Handler func(ctx context.Context, op *Operation) error
+
+// Middleware wraps a [Handler], to run custom code before and after each
+// request made to the driver. This makes it possible to add tracing, metrics,
+// auditing or fault injection to a client, regardless of the driver in use.
+//
+// A typical middleware looks like:
+//
+//	func timing(next kivik.Handler) kivik.Handler {
+//	    return func(ctx context.Context, op *kivik.Operation) error {
+//	        start := time.Now()
+//	        err := next(ctx, op)
+//	        log.Printf("%s %s: %s (err: %v)", op.Method, op.DB, time.Since(start), err)
+//	        return err
+//	    }
+//	}
+//
+// For operations which return an iterator, such as [DB.AllDocs], the handler
+// covers only the initial request, not the iteration.
+type Middleware func(next Handler) Handler
+
+// optionLogger is the option key used by WithLogger, which requires Go 1.21
+// or later. Its value is the logging [Middleware].
+const optionLogger = "kivik:logger"
+
+// Use adds middleware to the client. Middleware is called in the order it is
+// added, with the first middleware added being the outermost. All middleware
+// wraps the entire operation, including any retries enabled by [WithRetry].
+//
+// Use is safe to call concurrently with other operations, but affects only
+// operations started after it returns.
+func (c *Client) Use(mw ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	middleware := make([]Middleware, 0, len(c.middleware)+len(mw))
+	middleware = append(middleware, c.middleware...)
+	c.middleware = append(middleware, mw...)
+}
+
+// invoke performs the request described by op by calling fn, via any
+// configured middleware, and subject to the client's retry policy, rate
+// limiter and default timeouts.
+func (c *Client) invoke(ctx context.Context, op *Operation, fn func(context.Context) error) error {
+	c.mu.Lock()
+	middleware := c.middleware
+	c.mu.Unlock()
+	h := func(ctx context.Context, op *Operation) error {
+		if op.ReadOnly {
+			return c.retry(ctx, fn)
+		}
+		return c.call(ctx, fn)
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	ctx, cancel := c.withTimeout(ctx, op)
+	if cancel == nil {
+		return h(ctx, op)
+	}
+	if op.iterator {
+		op.deadline, op.cancelDeadline = ctx, cancel
+	}
+	err := h(ctx, op)
+	if err != nil || !op.iterator {
+		cancel()
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type ctxKey string
+
+func TestMiddleware(t *testing.T) {
+	t.Run("order and metadata", func(t *testing.T) {
+		var trace []string
+		var ops []Operation
+		record := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(ctx context.Context, op *Operation) error {
+					trace = append(trace, name+" before")
+					err := next(ctx, op)
+					trace = append(trace, name+" after")
+					ops = append(ops, *op)
+					return err
+				}
+			}
+		}
+		client := &Client{}
+		client.Use(record("outer"), record("inner"))
+		db := &DB{
+			client: client,
+			name:   "animals",
+			driverDB: &mock.DB{
+				PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+					trace = append(trace, "driver")
+					return "1-xxx", nil
+				},
+			},
+		}
+		if _, err := db.Put(context.Background(), "cow", map[string]string{}, Options{"batch": "ok"}); err != nil {
+			t.Fatal(err)
+		}
+		wantTrace := []string{"outer before", "inner before", "driver", "inner after", "outer after"}
+		if d := testy.DiffInterface(wantTrace, trace); d != nil {
+			t.Error(d)
+		}
+		wantOp := Operation{Method: "Put", DB: "animals", DocID: "cow", Options: Options{"batch": "ok"}}
+		for _, op := range ops {
+			if d := testy.DiffInterface(wantOp, op); d != nil {
+				t.Error(d)
+			}
+		}
+	})
+	t.Run("context is passed to driver", func(t *testing.T) {
+		client := &Client{
+			driverClient: &mock.Client{
+				AllDBsFunc: func(ctx context.Context, _ map[string]interface{}) ([]string, error) {
+					if v, _ := ctx.Value(ctxKey("foo")).(string); v != "bar" {
+						return nil, errors.New("context value missing")
+					}
+					return []string{"a"}, nil
+				},
+			},
+		}
+		client.Use(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				return next(context.WithValue(ctx, ctxKey("foo"), "bar"), op)
+			}
+		})
+		dbs, err := client.AllDBs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dbs) != 1 {
+			t.Errorf("Unexpected result: %v", dbs)
+		}
+	})
+	t.Run("fault injection", func(t *testing.T) {
+		client := &Client{
+			driverClient: &mock.Client{
+				VersionFunc: func(context.Context) (*driver.Version, error) {
+					t.Fatal("driver should not be called")
+					return nil, nil
+				},
+			},
+		}
+		client.Use(func(Handler) Handler {
+			return func(context.Context, *Operation) error {
+				return &Error{Status: http.StatusServiceUnavailable, Message: "injected"}
+			}
+		})
+		_, err := client.Version(context.Background())
+		testy.StatusError(t, "injected", http.StatusServiceUnavailable, err)
+	})
+	t.Run("wraps retries", func(t *testing.T) {
+		var calls, attempts int
+		client := &Client{
+			retryPolicy: &RetryPolicy{MinBackoff: time.Microsecond},
+			driverClient: &mock.Client{
+				VersionFunc: func(context.Context) (*driver.Version, error) {
+					attempts++
+					if attempts == 1 {
+						return nil, &Error{Status: http.StatusServiceUnavailable}
+					}
+					return &driver.Version{}, nil
+				},
+			},
+		}
+		client.Use(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				calls++
+				if !op.ReadOnly {
+					t.Errorf("Version should be read-only")
+				}
+				return next(ctx, op)
+			}
+		})
+		if _, err := client.Version(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if calls != 1 || attempts != 2 {
+			t.Errorf("Unexpected calls=%d attempts=%d", calls, attempts)
+		}
+	})
+}
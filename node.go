@@ -0,0 +1,285 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var nodeNotImplemented = &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support node statistics"}
+
+// localNode is the name by which a CouchDB node refers to itself.
+const localNode = "_local"
+
+// NodeStats are the statistics of a CouchDB node, as returned by
+// [Client.NodeStats].
+type NodeStats struct {
+	// Metrics are keyed by their path, with the names of nested groups
+	// separated by dots, such as "couchdb.request_time" or
+	// "couchdb.httpd_status_codes.500".
+	Metrics map[string]*Metric
+	// RawResponse is the raw response body returned by the server.
+	RawResponse json.RawMessage
+}
+
+// Value returns the value of the named counter or gauge, or the mean of the
+// named histogram, and whether the metric exists.
+func (s *NodeStats) Value(name string) (float64, bool) {
+	m, ok := s.Metrics[name]
+	if !ok {
+		return 0, false
+	}
+	if m.Histogram != nil {
+		return m.Histogram.ArithmeticMean, true
+	}
+	return m.Value, true
+}
+
+// Metric is a single statistic of a node.
+type Metric struct {
+	// Type is "counter", "gauge" or "histogram".
+	Type string
+	// Desc describes the metric.
+	Desc string
+	// Value is the value of a counter or gauge.
+	Value float64
+	// Histogram holds the value of a histogram, or is nil for other types.
+	Histogram *Histogram
+}
+
+// Histogram summarizes the values of a histogram metric, such as request
+// times in milliseconds, over the node's statistics interval.
+type Histogram struct {
+	N                 int64
+	Min               float64
+	Max               float64
+	ArithmeticMean    float64
+	GeometricMean     float64
+	HarmonicMean      float64
+	Median            float64
+	Variance          float64
+	StandardDeviation float64
+	Skewness          float64
+	Kurtosis          float64
+	// Percentiles are keyed by percentile, such as 50, 99 or 99.9.
+	Percentiles map[float64]float64
+	// Buckets are the counts of values in each bucket of the histogram.
+	Buckets []HistogramBucket
+}
+
+// HistogramBucket is a single bucket of a [Histogram].
+type HistogramBucket struct {
+	Value float64
+	Count int64
+}
+
+// histogramJSON is the JSON representation of a histogram's value.
+type histogramJSON struct {
+	N                 int64        `json:"n"`
+	Min               float64      `json:"min"`
+	Max               float64      `json:"max"`
+	ArithmeticMean    float64      `json:"arithmetic_mean"`
+	GeometricMean     float64      `json:"geometric_mean"`
+	HarmonicMean      float64      `json:"harmonic_mean"`
+	Median            float64      `json:"median"`
+	Variance          float64      `json:"variance"`
+	StandardDeviation float64      `json:"standard_deviation"`
+	Skewness          float64      `json:"skewness"`
+	Kurtosis          float64      `json:"kurtosis"`
+	Percentile        [][2]float64 `json:"percentile"`
+	Histogram         [][2]float64 `json:"histogram"`
+}
+
+// parseNodeStats decodes a _stats response.
+func parseNodeStats(raw json.RawMessage) (*NodeStats, error) {
+	stats := &NodeStats{Metrics: map[string]*Metric{}, RawResponse: raw}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Err: err}
+	}
+	if err := stats.parseGroup(nil, root); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Err: err}
+	}
+	return stats, nil
+}
+
+func (s *NodeStats) parseGroup(path []string, group map[string]json.RawMessage) error {
+	for name, raw := range group {
+		p := append(append([]string(nil), path...), name)
+		var entry struct {
+			Type  *string         `json:"type"`
+			Desc  string          `json:"desc"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			// Not an object, so not a metric or a group; ignore it.
+			continue
+		}
+		if entry.Type == nil || entry.Value == nil {
+			var sub map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &sub); err != nil {
+				return err
+			}
+			if err := s.parseGroup(p, sub); err != nil {
+				return err
+			}
+			continue
+		}
+		m := &Metric{Type: *entry.Type, Desc: entry.Desc}
+		if m.Type == "histogram" {
+			var h histogramJSON
+			if err := json.Unmarshal(entry.Value, &h); err != nil {
+				return fmt.Errorf("%s: %w", strings.Join(p, "."), err)
+			}
+			m.Histogram = h.histogram()
+		} else if err := json.Unmarshal(entry.Value, &m.Value); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(p, "."), err)
+		}
+		s.Metrics[strings.Join(p, ".")] = m
+	}
+	return nil
+}
+
+func (h *histogramJSON) histogram() *Histogram {
+	result := &Histogram{
+		N:                 h.N,
+		Min:               h.Min,
+		Max:               h.Max,
+		ArithmeticMean:    h.ArithmeticMean,
+		GeometricMean:     h.GeometricMean,
+		HarmonicMean:      h.HarmonicMean,
+		Median:            h.Median,
+		Variance:          h.Variance,
+		StandardDeviation: h.StandardDeviation,
+		Skewness:          h.Skewness,
+		Kurtosis:          h.Kurtosis,
+		Percentiles:       make(map[float64]float64, len(h.Percentile)),
+		Buckets:           make([]HistogramBucket, len(h.Histogram)),
+	}
+	for _, p := range h.Percentile {
+		key := p[0]
+		if key == 999 {
+			// CouchDB reports the 99.9th percentile as 999.
+			key = 99.9
+		}
+		result.Percentiles[key] = p[1]
+	}
+	for i, b := range h.Histogram {
+		result.Buckets[i] = HistogramBucket{Value: b[0], Count: int64(b[1])}
+	}
+	return result
+}
+
+// NodeSystem are the system-level metrics of a CouchDB node, as returned by
+// [Client.NodeSystem]. Memory sizes are in bytes.
+type NodeSystem struct {
+	Uptime                  int64                   `json:"uptime"`
+	Memory                  map[string]int64        `json:"memory"`
+	RunQueue                int64                   `json:"run_queue"`
+	ETSTableCount           int64                   `json:"ets_table_count"`
+	ContextSwitches         int64                   `json:"context_switches"`
+	Reductions              int64                   `json:"reductions"`
+	GarbageCollectionCount  int64                   `json:"garbage_collection_count"`
+	WordsReclaimed          int64                   `json:"words_reclaimed"`
+	IOInput                 int64                   `json:"io_input"`
+	IOOutput                int64                   `json:"io_output"`
+	OSProcCount             int64                   `json:"os_proc_count"`
+	StaleProcCount          int64                   `json:"stale_proc_count"`
+	ProcessCount            int64                   `json:"process_count"`
+	ProcessLimit            int64                   `json:"process_limit"`
+	InternalReplicationJobs int64                   `json:"internal_replication_jobs"`
+	MessageQueues           map[string]MessageQueue `json:"message_queues"`
+	// RawResponse is the raw response body returned by the server.
+	RawResponse json.RawMessage `json:"-"`
+}
+
+// MessageQueue is the length of an Erlang process's message queue. For
+// groups of processes, such as couch_file, it summarizes the queue lengths of
+// the processes in the group; for single processes, only Count is set.
+type MessageQueue struct {
+	Count int64 `json:"count"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	P50   int64 `json:"50"`
+	P90   int64 `json:"90"`
+	P99   int64 `json:"99"`
+}
+
+// UnmarshalJSON decodes a message queue, which CouchDB reports as a number
+// for single processes, and as an object for groups.
+func (q *MessageQueue) UnmarshalJSON(data []byte) error {
+	var count int64
+	if err := json.Unmarshal(data, &count); err == nil {
+		*q = MessageQueue{Count: count}
+		return nil
+	}
+	type alias MessageQueue
+	return json.Unmarshal(data, (*alias)(q))
+}
+
+// NodeStats returns the statistics of the named node, such as request counts
+// and times, from the /_node/{node-name}/_stats endpoint. If node is empty,
+// the statistics of the node which handles the request are returned.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#node-node-name-stats
+func (c *Client) NodeStats(ctx context.Context, node string) (*NodeStats, error) {
+	raw, err := c.nodeInspect(ctx, "NodeStats", node, driver.NodeInspector.NodeStats)
+	if err != nil {
+		return nil, err
+	}
+	return parseNodeStats(raw)
+}
+
+// NodeSystem returns the system-level metrics of the named node, such as
+// memory use and message queue lengths, from the /_node/{node-name}/_system
+// endpoint. If node is empty, the metrics of the node which handles the
+// request are returned.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#node-node-name-system
+func (c *Client) NodeSystem(ctx context.Context, node string) (*NodeSystem, error) {
+	raw, err := c.nodeInspect(ctx, "NodeSystem", node, driver.NodeInspector.NodeSystem)
+	if err != nil {
+		return nil, err
+	}
+	system := &NodeSystem{RawResponse: raw}
+	if err := json.Unmarshal(raw, system); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Err: err}
+	}
+	return system, nil
+}
+
+func (c *Client) nodeInspect(ctx context.Context, method, node string, fn func(driver.NodeInspector, context.Context, string) (json.RawMessage, error)) (json.RawMessage, error) {
+	if err := c.startQuery(); err != nil {
+		return nil, err
+	}
+	defer c.endQuery()
+	inspector, ok := optional(c.driverClient, (*driver.NodeInspector)(nil)).(driver.NodeInspector)
+	if !ok {
+		return nil, nodeNotImplemented
+	}
+	if node == "" {
+		node = localNode
+	}
+	var raw json.RawMessage
+	err := c.invoke(ctx, &Operation{Method: method, ReadOnly: true}, func(ctx context.Context) (err error) {
+		raw, err = fn(inspector, ctx, node)
+		return err
+	})
+	return raw, err
+}
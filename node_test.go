@@ -0,0 +1,157 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+const testNodeStats = `{
+	"couchdb": {
+		"request_time": {
+			"value": {
+				"min": 1, "max": 9, "arithmetic_mean": 4.5, "geometric_mean": 3, "harmonic_mean": 2,
+				"median": 4, "variance": 1.5, "standard_deviation": 1.2, "skewness": 0.1, "kurtosis": 0.2,
+				"percentile": [[50, 4], [99, 9], [999, 9]],
+				"histogram": [[1, 3], [5, 2]],
+				"n": 5
+			},
+			"type": "histogram",
+			"desc": "length of a request inside CouchDB without MochiWeb"
+		},
+		"httpd_status_codes": {
+			"500": {"value": 3, "type": "counter", "desc": "number of HTTP 500 Internal Server Error responses"}
+		},
+		"open_databases": {"value": 12, "type": "counter", "desc": "number of open databases"}
+	},
+	"mem3": {
+		"shard_cache": {"eviction": {"value": 0, "type": "counter", "desc": "number of shard cache evictions"}}
+	}
+}`
+
+func TestNodeStats(t *testing.T) {
+	t.Run("not supported", func(t *testing.T) {
+		c := &Client{driverClient: &mock.Client{}}
+		_, err := c.NodeStats(context.Background(), "")
+		testy.StatusError(t, "kivik: driver does not support node statistics", http.StatusNotImplemented, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		var gotNode string
+		c := &Client{driverClient: &mock.NodeInspector{
+			NodeStatsFunc: func(_ context.Context, node string) (json.RawMessage, error) {
+				gotNode = node
+				return json.RawMessage(testNodeStats), nil
+			},
+		}}
+		stats, err := c.NodeStats(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotNode != "_local" {
+			t.Errorf("Unexpected node: %s", gotNode)
+		}
+		if len(stats.Metrics) != 4 {
+			t.Errorf("Unexpected metrics: %v", stats.Metrics)
+		}
+		if v, ok := stats.Value("couchdb.httpd_status_codes.500"); !ok || v != 3 {
+			t.Errorf("Unexpected 500 count: %v, %t", v, ok)
+		}
+		if v, ok := stats.Value("couchdb.request_time"); !ok || v != 4.5 {
+			t.Errorf("Unexpected request time: %v, %t", v, ok)
+		}
+		if _, ok := stats.Value("couchdb.nothing"); ok {
+			t.Error("Unexpected metric")
+		}
+		want := &Metric{
+			Type: "histogram",
+			Desc: "length of a request inside CouchDB without MochiWeb",
+			Histogram: &Histogram{
+				N: 5, Min: 1, Max: 9, ArithmeticMean: 4.5, GeometricMean: 3, HarmonicMean: 2,
+				Median: 4, Variance: 1.5, StandardDeviation: 1.2, Skewness: 0.1, Kurtosis: 0.2,
+				Percentiles: map[float64]float64{50: 4, 99: 9, 99.9: 9},
+				Buckets:     []HistogramBucket{{Value: 1, Count: 3}, {Value: 5, Count: 2}},
+			},
+		}
+		if d := testy.DiffInterface(want, stats.Metrics["couchdb.request_time"]); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("invalid response", func(t *testing.T) {
+		c := &Client{driverClient: &mock.NodeInspector{
+			NodeStatsFunc: func(context.Context, string) (json.RawMessage, error) {
+				return json.RawMessage(`{"couchdb":{"x":{"type":"counter","value":"nope"}}}`), nil
+			},
+		}}
+		_, err := c.NodeStats(context.Background(), "node1@127.0.0.1")
+		testy.StatusError(t, "couchdb.x: json: cannot unmarshal string into Go value of type float64", http.StatusBadGateway, err)
+	})
+}
+
+func TestNodeSystem(t *testing.T) {
+	t.Run("not supported", func(t *testing.T) {
+		c := &Client{driverClient: &mock.Client{}}
+		_, err := c.NodeSystem(context.Background(), "")
+		testy.StatusError(t, "kivik: driver does not support node statistics", http.StatusNotImplemented, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		raw := json.RawMessage(`{
+			"uptime": 259,
+			"memory": {"other": 12345, "processes": 678},
+			"run_queue": 1,
+			"process_count": 320,
+			"process_limit": 262144,
+			"internal_replication_jobs": 2,
+			"message_queues": {
+				"couch_file": {"count": 4, "min": 0, "max": 7, "50": 0, "90": 1, "99": 7},
+				"couch_server": 5
+			}
+		}`)
+		var gotNode string
+		c := &Client{driverClient: &mock.NodeInspector{
+			NodeSystemFunc: func(_ context.Context, node string) (json.RawMessage, error) {
+				gotNode = node
+				return raw, nil
+			},
+		}}
+		system, err := c.NodeSystem(context.Background(), "node1@127.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotNode != "node1@127.0.0.1" {
+			t.Errorf("Unexpected node: %s", gotNode)
+		}
+		want := &NodeSystem{
+			Uptime:                  259,
+			Memory:                  map[string]int64{"other": 12345, "processes": 678},
+			RunQueue:                1,
+			ProcessCount:            320,
+			ProcessLimit:            262144,
+			InternalReplicationJobs: 2,
+			MessageQueues: map[string]MessageQueue{
+				"couch_file":   {Count: 4, Max: 7, P90: 1, P99: 7},
+				"couch_server": {Count: 5},
+			},
+			RawResponse: raw,
+		}
+		if d := testy.DiffInterface(want, system); d != nil {
+			t.Error(d)
+		}
+	})
+}
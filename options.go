@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// jsonQueryOptions are the options whose values CouchDB expects as JSON in
+// the query string, even when they are strings.
+var jsonQueryOptions = map[string]bool{
+	"key":       true,
+	"keys":      true,
+	"startkey":  true,
+	"start_key": true,
+	"endkey":    true,
+	"end_key":   true,
+}
+
+// Apply sets the options on target, which must be a map[string]interface{},
+// to which each option is copied, or a *[net/url.Values], to which each option
+// is added as a query parameter, as CouchDB expects it. Other targets are
+// ignored. Apply allows Options, and the typed options such as [GetOptions],
+// to be passed to drivers which accept typed options.
+func (o Options) Apply(target interface{}) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		for k, v := range o {
+			t[k] = v
+		}
+	case *url.Values:
+		if *t == nil {
+			*t = url.Values{}
+		}
+		for k, v := range o {
+			t.Set(k, queryValue(k, v))
+		}
+	}
+}
+
+// queryValue returns v encoded as the query parameter key.
+func queryValue(key string, v interface{}) string {
+	if !jsonQueryOptions[key] {
+		switch t := v.(type) {
+		case string:
+			return t
+		case bool:
+			return strconv.FormatBool(t)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+			return fmt.Sprint(t)
+		}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o GetOptions) Apply(target interface{}) { o.Options().Apply(target) }
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o PutOptions) Apply(target interface{}) { o.Options().Apply(target) }
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o QueryOptions) Apply(target interface{}) { o.Options().Apply(target) }
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o ChangesOptions) Apply(target interface{}) { o.Options().Apply(target) }
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o DBUpdatesOptions) Apply(target interface{}) { o.Options().Apply(target) }
+
+// Apply sets the options on target, as described for [Options.Apply].
+func (o CreateDBOptions) Apply(target interface{}) { o.Options().Apply(target) }
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"net/url"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestOptionsApply(t *testing.T) {
+	opts := Options{
+		"include_docs": true,
+		"limit":        10,
+		"startkey":     "a",
+		"keys":         []string{"a", "b"},
+		"since":        "now",
+		"doc_ids":      []string{"x"},
+	}
+	t.Run("map", func(t *testing.T) {
+		target := map[string]interface{}{"skip": 1}
+		opts.Apply(target)
+		want := map[string]interface{}{
+			"skip":         1,
+			"include_docs": true,
+			"limit":        10,
+			"startkey":     "a",
+			"keys":         []string{"a", "b"},
+			"since":        "now",
+			"doc_ids":      []string{"x"},
+		}
+		if d := testy.DiffInterface(want, target); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("query", func(t *testing.T) {
+		var query url.Values
+		opts.Apply(&query)
+		want := url.Values{
+			"include_docs": {"true"},
+			"limit":        {"10"},
+			"startkey":     {`"a"`},
+			"keys":         {`["a","b"]`},
+			"since":        {"now"},
+			"doc_ids":      {`["x"]`},
+		}
+		if d := testy.DiffInterface(want, query); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("typed", func(t *testing.T) {
+		var query url.Values
+		QueryOptions{Key: "cow", Limit: 2}.Apply(&query)
+		if got := query.Encode(); got != "key=%22cow%22&limit=2" {
+			t.Errorf("Unexpected query: %s", got)
+		}
+	})
+	t.Run("unsupported target", func(t *testing.T) {
+		opts.Apply(struct{}{}) // Should not panic
+	})
+}
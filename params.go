@@ -0,0 +1,156 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+// The functions in this file construct single options, to be passed to any
+// method which accepts [Options]. Options passed to a method are merged, so
+// they may be combined freely with each other, with typed options such as
+// [GetOptions], and with Options literals:
+//
+//	rows := db.AllDocs(ctx, kivik.IncludeDocs(), kivik.Limit(10))
+
+// Param returns an option which sets the parameter key to value. It may be
+// used for driver-specific options without a dedicated constructor.
+func Param(key string, value interface{}) Options {
+	return Options{key: value}
+}
+
+// Params returns an option which sets each of the parameters in params.
+func Params(params map[string]interface{}) Options {
+	opts := make(Options, len(params))
+	for k, v := range params {
+		opts[k] = v
+	}
+	return opts
+}
+
+// Rev returns an option which requests a specific document revision.
+func Rev(rev string) Options {
+	return Options{"rev": rev}
+}
+
+// Revs returns an option which includes the revision history of documents.
+func Revs() Options {
+	return Options{"revs": true}
+}
+
+// Conflicts returns an option which includes the conflicting revisions of
+// documents.
+func Conflicts() Options {
+	return Options{"conflicts": true}
+}
+
+// IncludeAttachments returns an option which includes the content of
+// attachments, rather than stubs.
+func IncludeAttachments() Options {
+	return Options{"attachments": true}
+}
+
+// IncludeDocs returns an option which includes the full document with each
+// row of a view, or each change of a changes feed.
+func IncludeDocs() Options {
+	return Options{"include_docs": true}
+}
+
+// Limit returns an option which limits the number of results returned.
+func Limit(n int) Options {
+	return Options{"limit": n}
+}
+
+// Skip returns an option which skips the first n results.
+func Skip(n int) Options {
+	return Options{"skip": n}
+}
+
+// Descending returns an option which returns results in reverse order.
+func Descending() Options {
+	return Options{"descending": true}
+}
+
+// Key returns an option which returns only view rows matching key.
+func Key(key interface{}) Options {
+	return Options{"key": key}
+}
+
+// Keys returns an option which returns only view rows matching one of keys.
+func Keys(keys ...interface{}) Options {
+	return Options{"keys": keys}
+}
+
+// StartKey returns an option which returns view rows starting with key.
+func StartKey(key interface{}) Options {
+	return Options{"startkey": key}
+}
+
+// EndKey returns an option which stops returning view rows after key.
+func EndKey(key interface{}) Options {
+	return Options{"endkey": key}
+}
+
+// Since returns an option which returns only changes after the sequence seq.
+func Since(seq string) Options {
+	return Options{"since": seq}
+}
+
+// Shards returns an option which sets the number of shards (q) of a database
+// created with [Client.CreateDB].
+func Shards(q int) Options {
+	return Options{"q": q}
+}
+
+// Replicas returns an option which sets the number of replicas (n) of each
+// shard of a database created with [Client.CreateDB].
+func Replicas(n int) Options {
+	return Options{"n": n}
+}
+
+// ReadQuorum returns an option which sets the number of replicas (r) which
+// must respond to a read of a document, such as [DB.Get], before the result is
+// returned. It must not exceed the number of replicas of the database.
+func ReadQuorum(r int) Options {
+	return Options{"r": r}
+}
+
+// WriteQuorum returns an option which sets the number of replicas (w) which
+// must acknowledge a write of a document, such as [DB.Put], before it is
+// reported as successful. It must not exceed the number of replicas of the
+// database.
+func WriteQuorum(w int) Options {
+	return Options{"w": w}
+}
+
+// Partitioned returns an option which creates a partitioned database with
+// [Client.CreateDB].
+func Partitioned() Options {
+	return Options{"partitioned": true}
+}
+
+// DocIDsFilter returns an option which limits the results of [DB.Changes] to
+// the documents with the given IDs, using the built-in _doc_ids filter.
+func DocIDsFilter(docIDs ...string) Options {
+	return Options{"filter": "_doc_ids", "doc_ids": docIDs}
+}
+
+// SelectorFilter returns an option which limits the results of [DB.Changes]
+// to documents matching the Mango selector, using the built-in _selector
+// filter. selector may be a map, a struct, a JSON string or a
+// [encoding/json.RawMessage].
+func SelectorFilter(selector interface{}) Options {
+	return Options{"filter": "_selector", "selector": selector}
+}
+
+// DesignFilter returns an option which limits the results of [DB.Changes] to
+// design documents, using the built-in _design filter.
+func DesignFilter() Options {
+	return Options{"filter": "_design"}
+}
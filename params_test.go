@@ -0,0 +1,75 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestParams(t *testing.T) {
+	got := mergeOptions(
+		Rev("1-xxx"),
+		Revs(),
+		Conflicts(),
+		IncludeAttachments(),
+		IncludeDocs(),
+		Limit(10),
+		Skip(5),
+		Descending(),
+		Keys("a", 1),
+		StartKey([]interface{}{"a"}),
+		EndKey([]interface{}{"b"}),
+		Since("now"),
+		Param("custom", 1.5),
+		Params(map[string]interface{}{"foo": "bar"}),
+		GetOptions{Latest: true}.Options(),
+	)
+	want := Options{
+		"rev":          "1-xxx",
+		"revs":         true,
+		"conflicts":    true,
+		"attachments":  true,
+		"include_docs": true,
+		"limit":        10,
+		"skip":         5,
+		"descending":   true,
+		"keys":         []interface{}{"a", 1},
+		"startkey":     []interface{}{"a"},
+		"endkey":       []interface{}{"b"},
+		"since":        "now",
+		"custom":       1.5,
+		"foo":          "bar",
+		"latest":       true,
+	}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(Options{"key": "a"}, Key("a")); d != nil {
+		t.Error(d)
+	}
+	cluster := mergeOptions(Shards(8), Replicas(3), Partitioned())
+	if d := testy.DiffInterface(Options{"q": 8, "n": 3, "partitioned": true}, cluster); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(Options{"filter": "_doc_ids", "doc_ids": []string{"a", "b"}}, DocIDsFilter("a", "b")); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(Options{"filter": "_selector", "selector": `{"a":1}`}, SelectorFilter(`{"a":1}`)); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(Options{"filter": "_design"}, DesignFilter()); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,61 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// checkQuorum validates the read (r) and write (w) quorum options in opts,
+// which must be positive integers no greater than the number of replicas of
+// the database. The number of replicas is read once per DB handle, from
+// [DB.Stats], and only if a quorum option is used. If the driver does not
+// report it, only the sign of the options is checked.
+func (db *DB) checkQuorum(ctx context.Context, opts Options) error {
+	for _, key := range []string{"r", "w"} {
+		v, ok := opts[key]
+		if !ok {
+			continue
+		}
+		q, ok := toInt(v)
+		if !ok || q < 1 {
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid %s %v: must be a positive integer", key, v)}
+		}
+		if n := db.replicaCount(ctx); n > 0 && q > n {
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: %s %d exceeds the number of replicas (%d)", key, q, n)}
+		}
+	}
+	return nil
+}
+
+// replicaCount returns the number of replicas (n) of the database, or 0 if
+// unknown. A failure to read it is not cached. The lock is held while reading
+// it, so that concurrent callers share a single request.
+func (db *DB) replicaCount(ctx context.Context) int {
+	db.replicasMu.Lock()
+	defer db.replicasMu.Unlock()
+	if db.replicasKnown {
+		return db.replicas
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		return 0
+	}
+	if stats.Cluster != nil {
+		db.replicas = stats.Cluster.Replicas
+	}
+	db.replicasKnown = true
+	return db.replicas
+}
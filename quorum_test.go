@@ -0,0 +1,232 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestQuorum(t *testing.T) {
+	type tst struct {
+		stats   func(context.Context) (*driver.DBStats, error)
+		call    func(*DB) error
+		wantOpt Options
+		status  int
+		err     string
+	}
+	clustered := func(context.Context) (*driver.DBStats, error) {
+		return &driver.DBStats{Cluster: &driver.ClusterStats{Replicas: 3}}, nil
+	}
+	get := func(options ...Options) func(*DB) error {
+		return func(db *DB) error {
+			return db.Get(context.Background(), "foo", options...).Err()
+		}
+	}
+	put := func(options ...Options) func(*DB) error {
+		return func(db *DB) error {
+			_, err := db.Put(context.Background(), "foo", map[string]string{}, options...)
+			return err
+		}
+	}
+	tests := testy.NewTable()
+	tests.Add("read quorum", tst{
+		stats:   clustered,
+		call:    get(ReadQuorum(2)),
+		wantOpt: Options{"r": 2},
+	})
+	tests.Add("typed read quorum", tst{
+		stats:   clustered,
+		call:    get(GetOptions{R: 3}.Options()),
+		wantOpt: Options{"r": 3},
+	})
+	tests.Add("read quorum too large", tst{
+		stats:  clustered,
+		call:   get(ReadQuorum(4)),
+		status: http.StatusBadRequest,
+		err:    "kivik: r 4 exceeds the number of replicas (3)",
+	})
+	tests.Add("write quorum", tst{
+		stats:   clustered,
+		call:    put(WriteQuorum(3)),
+		wantOpt: Options{"w": 3},
+	})
+	tests.Add("write quorum too large", tst{
+		stats:  clustered,
+		call:   put(PutOptions{W: 5}.Options()),
+		status: http.StatusBadRequest,
+		err:    "kivik: w 5 exceeds the number of replicas (3)",
+	})
+	tests.Add("zero", tst{
+		stats:  clustered,
+		call:   put(WriteQuorum(0)),
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid w 0: must be a positive integer",
+	})
+	tests.Add("not an integer", tst{
+		stats:  clustered,
+		call:   get(Param("r", "two")),
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid r two: must be a positive integer",
+	})
+	tests.Add("string write quorum", tst{
+		stats:   clustered,
+		call:    put(Options{"w": "2"}),
+		wantOpt: Options{"w": "2"},
+	})
+	tests.Add("string read quorum too large", tst{
+		stats:  clustered,
+		call:   get(Options{"r": "4"}),
+		status: http.StatusBadRequest,
+		err:    "kivik: r 4 exceeds the number of replicas (3)",
+	})
+	tests.Add("not clustered", tst{
+		stats: func(context.Context) (*driver.DBStats, error) {
+			return &driver.DBStats{}, nil
+		},
+		call:    get(ReadQuorum(5)),
+		wantOpt: Options{"r": 5},
+	})
+	tests.Add("stats failure", tst{
+		stats: func(context.Context) (*driver.DBStats, error) {
+			return nil, errors.New("stats failed")
+		},
+		call:    put(WriteQuorum(5)),
+		wantOpt: Options{"w": 5},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var gotOpts map[string]interface{}
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				StatsFunc: tt.stats,
+				GetFunc: func(_ context.Context, _ string, options map[string]interface{}) (*driver.Document, error) {
+					gotOpts = options
+					return &driver.Document{Body: io.NopCloser(strings.NewReader("{}"))}, nil
+				},
+				PutFunc: func(_ context.Context, _ string, _ interface{}, options map[string]interface{}) (string, error) {
+					gotOpts = options
+					return "1-a", nil
+				},
+			},
+		}
+		err := tt.call(db)
+		if tt.wantOpt != nil {
+			if d := testy.DiffInterface(map[string]interface{}(tt.wantOpt), gotOpts); d != nil {
+				t.Error(d)
+			}
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestQuorumReplicasCached(t *testing.T) {
+	var calls int
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			StatsFunc: func(context.Context) (*driver.DBStats, error) {
+				calls++
+				return &driver.DBStats{Cluster: &driver.ClusterStats{Replicas: 3}}, nil
+			},
+			PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+				return "1-a", nil
+			},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := db.Put(context.Background(), "foo", map[string]string{}, WriteQuorum(2), ReadQuorum(2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Put(context.Background(), "foo", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to Stats, got %d", calls)
+	}
+}
+
+func TestQuorumReplicasShared(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			StatsFunc: func(context.Context) (*driver.DBStats, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return &driver.DBStats{Cluster: &driver.ClusterStats{Replicas: 3}}, nil
+			},
+			PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+				return "1-a", nil
+			},
+		},
+	}
+	errs := make(chan error)
+	for _, id := range []string{"foo", "bar"} {
+		go func(id string) {
+			_, err := db.Put(context.Background(), id, map[string]string{}, WriteQuorum(2))
+			errs <- err
+		}(id)
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected concurrent callers to share 1 call to Stats, got %d", calls)
+	}
+}
+
+func TestQuorumClosedDB(t *testing.T) {
+	var calls int
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.RevGetter{
+			DB: &mock.DB{
+				StatsFunc: func(context.Context) (*driver.DBStats, error) {
+					calls++
+					return &driver.DBStats{Cluster: &driver.ClusterStats{Replicas: 3}}, nil
+				},
+			},
+		},
+		closed: 1,
+	}
+	_, err := db.GetRev(context.Background(), "foo", ReadQuorum(2))
+	if !errors.Is(err, ErrDatabaseClosed) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no call to Stats on a closed DB, got %d", calls)
+	}
+}
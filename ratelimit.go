@@ -0,0 +1,135 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of requests made by a [Client]. Wait should
+// block until the next request is permitted, or return an error if ctx is
+// cancelled first.
+//
+// A *rate.Limiter from the golang.org/x/time/rate package satisfies this
+// interface.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// optionRateLimiter is the option key used to pass a [RateLimiter] to [New].
+const optionRateLimiter = "kivik:rateLimiter"
+
+// WithRateLimiter returns an option which, when passed to [New], causes every
+// request the client makes to the driver to first wait on limiter. This
+// includes each retry attempt made when [WithRetry] is in use.
+func WithRateLimiter(limiter RateLimiter) Options {
+	return Options{optionRateLimiter: limiter}
+}
+
+// WithRateLimit is a convenience wrapper around [WithRateLimiter], using a
+// simple token bucket which permits perSecond requests per second on average,
+// with bursts of up to burst requests. A burst value less than 1 is treated
+// as 1. A perSecond value of 0 or less disables rate limiting.
+func WithRateLimit(perSecond float64, burst int) Options {
+	return WithRateLimiter(newTokenBucket(perSecond, burst))
+}
+
+// tokenBucket is a minimal token bucket implementation of [RateLimiter].
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+var _ RateLimiter = &tokenBucket{}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// reserve takes a token from the bucket, and returns the duration the caller
+// must wait before the token may be used.
+func (b *tokenBucket) reserve() time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns an unused token to the bucket, which never holds more than
+// burst tokens.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mu.Unlock()
+}
+
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delay := b.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// call performs a single request to the driver by calling fn, after waiting
+// for the client's rate limiter, if any.
+func (c *Client) call(ctx context.Context, fn func(context.Context) error) error {
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return fn(ctx)
+}
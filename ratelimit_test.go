@@ -0,0 +1,136 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type countingLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *countingLimiter) Wait(context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if got := b.reserve(); got != want {
+			t.Errorf("reservation %d: got %s, want %s", i, got, want)
+		}
+	}
+	now = now.Add(time.Second)
+	if got := b.reserve(); got != 0 {
+		t.Errorf("Expected refilled bucket, got delay of %s", got)
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+
+	// Cancelling a reservation on a full bucket must not exceed the burst.
+	b.cancel()
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond} {
+		if got := b.reserve(); got != want {
+			t.Errorf("reservation %d: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		b := newTokenBucket(0, 1)
+		for i := 0; i < 100; i++ {
+			if err := b.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		b := newTokenBucket(0.001, 1)
+		_ = b.Wait(context.Background())
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		err := b.Wait(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if b.tokens < -0.5 {
+			t.Errorf("Expected cancelled reservation to be returned, have %f tokens", b.tokens)
+		}
+	})
+}
+
+func TestClientRateLimiter(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		limiter := &countingLimiter{}
+		c := &Client{}
+		opts := c.applyOptions(mergeOptions(WithRateLimiter(limiter)))
+		if opts != nil {
+			t.Errorf("Expected no driver options, got %v", opts)
+		}
+		if c.rateLimiter != limiter {
+			t.Errorf("Rate limiter not set")
+		}
+	})
+	t.Run("every call waits", func(t *testing.T) {
+		limiter := &countingLimiter{}
+		client := &Client{
+			rateLimiter: limiter,
+			driverClient: &mock.Client{
+				CreateDBFunc: func(context.Context, string, map[string]interface{}) error { return nil },
+				AllDBsFunc: func(context.Context, map[string]interface{}) ([]string, error) {
+					return nil, nil
+				},
+			},
+		}
+		if err := client.CreateDB(context.Background(), "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.AllDBs(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if limiter.calls != 2 {
+			t.Errorf("Unexpected number of waits: %d", limiter.calls)
+		}
+	})
+	t.Run("limiter error", func(t *testing.T) {
+		client := &Client{
+			rateLimiter: &countingLimiter{err: errors.New("limited")},
+			driverClient: &mock.Client{
+				CreateDBFunc: func(context.Context, string, map[string]interface{}) error {
+					t.Fatal("driver should not be called")
+					return nil
+				},
+			},
+		}
+		err := client.CreateDB(context.Background(), "foo")
+		testy.Error(t, "limited", err)
+	})
+}
@@ -175,11 +175,16 @@ func (c *Client) GetReplications(ctx context.Context, options ...Options) ([]*Re
 		return nil, err
 	}
 	defer c.endQuery()
-	replicator, ok := c.driverClient.(driver.ClientReplicator)
+	replicator, ok := optional(c.driverClient, (*driver.ClientReplicator)(nil)).(driver.ClientReplicator)
 	if !ok {
 		return nil, replicationNotImplemented
 	}
-	reps, err := replicator.GetReplications(ctx, mergeOptions(options...))
+	var reps []driver.Replication
+	opts := mergeOptions(options...)
+	err := c.invoke(ctx, &Operation{Method: "GetReplications", Options: opts, ReadOnly: true}, func(ctx context.Context) (err error) {
+		reps, err = replicator.GetReplications(ctx, opts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -199,11 +204,16 @@ func (c *Client) Replicate(ctx context.Context, targetDSN, sourceDSN string, opt
 		return nil, err
 	}
 	defer c.endQuery()
-	replicator, ok := c.driverClient.(driver.ClientReplicator)
+	replicator, ok := optional(c.driverClient, (*driver.ClientReplicator)(nil)).(driver.ClientReplicator)
 	if !ok {
 		return nil, replicationNotImplemented
 	}
-	rep, err := replicator.Replicate(ctx, targetDSN, sourceDSN, mergeOptions(options...))
+	var rep driver.Replication
+	opts := mergeOptions(options...)
+	err := c.invoke(ctx, &Operation{Method: "Replicate", Options: opts}, func(ctx context.Context) (err error) {
+		rep, err = replicator.Replicate(ctx, targetDSN, sourceDSN, opts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// ResponseInfo describes the HTTP response to a request made by a driver.
+type ResponseInfo struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// ETag is the value of the ETag header, with quotes removed.
+	ETag string
+	// RequestID is the value of the X-Couch-Request-ID header, which
+	// identifies the request in the CouchDB server's logs.
+	RequestID string
+	// BodyTime is the value of the X-CouchDB-Body-Time header.
+	BodyTime time.Duration
+	// Header holds all of the response headers.
+	Header http.Header
+}
+
+// WithResponseMetadata returns a copy of ctx which records the metadata of
+// the responses to requests made with it, for retrieval with
+// [ResponseMetadata]:
+//
+//	ctx = kivik.WithResponseMetadata(ctx)
+//	err := db.Get(ctx, "foo").ScanDoc(&doc)
+//	if err != nil {
+//	    if md := kivik.ResponseMetadata(ctx); md != nil {
+//	        log.Printf("request %s failed: %s", md.RequestID, err)
+//	    }
+//	}
+//
+// Response metadata is only available from drivers which report it with
+// [driver.RecordResponse]; drivers which do not use HTTP do not.
+func WithResponseMetadata(ctx context.Context) context.Context {
+	return driver.WithResponseRecorder(ctx)
+}
+
+// ResponseMetadata returns the metadata of the most recent response to a
+// request made with ctx, which must be derived from the result of
+// [WithResponseMetadata]. It returns nil if no response has been recorded.
+// For operations which return an iterator, the metadata is that of the
+// response to the initial request.
+func ResponseMetadata(ctx context.Context) *ResponseInfo {
+	r := driver.ResponseRecorderFrom(ctx)
+	if r == nil {
+		return nil
+	}
+	md := r.Last()
+	if md == nil {
+		return nil
+	}
+	info := ResponseInfo(*md)
+	return &info
+}
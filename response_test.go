@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestResponseMetadata(t *testing.T) {
+	header := http.Header{
+		"Etag":                {`"1-xxx"`},
+		"X-Couch-Request-Id":  {"abc123"},
+		"X-Couchdb-Body-Time": {"12"},
+		"Content-Type":        {"application/json"},
+	}
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			GetFunc: func(ctx context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+				driver.RecordResponse(ctx, driver.NewResponseMetadata(http.StatusNotFound, header))
+				return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+			},
+		},
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		ctx := context.Background()
+		_ = db.Get(ctx, "foo").Err()
+		if md := ResponseMetadata(ctx); md != nil {
+			t.Errorf("Unexpected metadata: %v", md)
+		}
+	})
+	t.Run("no response", func(t *testing.T) {
+		if md := ResponseMetadata(WithResponseMetadata(context.Background())); md != nil {
+			t.Errorf("Unexpected metadata: %v", md)
+		}
+	})
+	t.Run("recorded", func(t *testing.T) {
+		ctx := WithResponseMetadata(context.Background())
+		_ = db.Get(ctx, "foo").Err()
+		want := &ResponseInfo{
+			Status:    http.StatusNotFound,
+			ETag:      "1-xxx",
+			RequestID: "abc123",
+			BodyTime:  12 * time.Millisecond,
+			Header:    header,
+		}
+		if d := testy.DiffInterface(want, ResponseMetadata(ctx)); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -14,7 +14,6 @@ package kivik
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -228,7 +227,7 @@ func (r *rows) ScanValue(dest interface{}) (err error) {
 		return row.Error
 	}
 	if row.Value != nil {
-		return json.NewDecoder(row.Value).Decode(dest)
+		return decodeJSON(row.Value, dest, r.useNumber)
 	}
 	return nil
 }
@@ -244,11 +243,14 @@ func (r *rows) ScanDoc(dest interface{}) (err error) {
 		return err
 	}
 	if row.Doc != nil {
-		return json.NewDecoder(row.Doc).Decode(dest)
+		return decodeDoc(row.Doc, dest, r.useNumber, r.codec)
 	}
-	return &Error{Status: http.StatusBadRequest, Message: "kivik: doc is nil; does the query include docs?"}
+	return errNoDoc
 }
 
+// errNoDoc is returned by ScanDoc for rows which do not include a document.
+var errNoDoc = &Error{Status: http.StatusBadRequest, Message: "kivik: doc is nil; does the query include docs?"}
+
 // ScanAllDocs loops through remaining documents in the resultset, and scans
 // them into dest. Dest is expected to be a pointer to a slice or an array, any
 // other type will return an error. If dest is an array, scanning will stop
@@ -325,7 +327,7 @@ func (r *rows) ScanKey(dest interface{}) (err error) {
 	}
 	defer runlock()
 	row := r.curVal.(*driver.Row)
-	if err := json.Unmarshal(row.Key, dest); err != nil {
+	if err := unmarshalJSON(row.Key, dest, r.useNumber); err != nil {
 		return err
 	}
 	return row.Error
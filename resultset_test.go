@@ -795,6 +795,75 @@ func TestNextResultSet(t *testing.T) {
 	})
 }
 
+func TestRowsFromFixture(t *testing.T) {
+	feed, err := mock.RowsFeedFromJSON(`{
+		"warning": "no matching index found",
+		"bookmark": "abc",
+		"results": [
+			{"total_rows": 3, "rows": [{"id": "a", "key": "a", "value": {"rev": "1-a"}}, {"key": "b", "error": "not_found"}]},
+			{"total_rows": 3, "rows": [{"id": "c", "key": "c", "value": {"rev": "1-c"}}]}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.TotalRows(3)
+	r := newRows(context.Background(), nil, feed.Rows())
+
+	type result struct {
+		Set int
+		ID  string
+		Err string
+	}
+	var got []result
+	for set := 0; r.NextResultSet(); set++ {
+		for r.Next() {
+			var value map[string]string
+			res := result{Set: set}
+			res.ID, _ = r.ID()
+			if err := r.ScanValue(&value); err != nil {
+				res.Err = err.Error()
+			}
+			got = append(got, res)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{Set: 0, ID: "a"},
+		{Set: 0, Err: "not_found"},
+		{Set: 1, ID: "c"},
+	}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	meta, err := r.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMeta := &ResultMetadata{TotalRows: 3, Warning: "no matching index found", Bookmark: "abc"}
+	if d := testy.DiffInterface(wantMeta, meta); d != nil {
+		t.Error(d)
+	}
+
+	t.Run("error mid-stream", func(t *testing.T) {
+		feed := mock.NewRowsFeed(mock.Row{ID: "a"}).
+			AddError(errors.New("connection reset")).
+			AddRows(mock.Row{ID: "b"})
+		r := newRows(context.Background(), nil, feed.Rows())
+		var ids []string
+		for r.Next() {
+			id, _ := r.ID()
+			ids = append(ids, id)
+		}
+		if d := testy.DiffInterface([]string{"a"}, ids); d != nil {
+			t.Error(d)
+		}
+		testy.Error(t, "connection reset", r.Err())
+	})
+}
+
 func multiResultSet() ResultSet {
 	rows := []interface{}{
 		&driver.Row{ID: "1", Doc: strings.NewReader(`{"foo":"bar"}`)},
@@ -0,0 +1,249 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// ResumableChanges is an iterator over a database changes feed which
+// transparently reconnects when the underlying feed fails with a transient
+// error, resuming from the last sequence seen. The changes from each
+// connection are presented as a single stream.
+type ResumableChanges struct {
+	db         *DB
+	ctx        context.Context
+	cancel     context.CancelFunc
+	opts       Options
+	policy     *RetryPolicy
+	continuous bool
+
+	mu       sync.Mutex
+	cur      *Changes
+	lastSeq  string
+	err      error
+	closed   bool
+	failures int
+	// received is true once the current connection has delivered a change.
+	received bool
+}
+
+// ResumableChanges returns an iterator over the changes feed of db, like
+// [DB.Changes], which reconnects whenever the feed fails with an error
+// considered transient by policy, after a jittered exponential backoff. Each
+// new connection requests only changes after the last sequence seen, so
+// changes are not skipped, although a change may be delivered twice if the
+// feed fails before its sequence is acknowledged by the server.
+//
+// policy.MaxAttempts is the number of consecutive failed connections after
+// which the feed gives up and [ResumableChanges.Err] reports the last error.
+// The count is reset each time a change is received. In addition to the
+// errors retried by [WithRetry], feeds interrupted by [io.ErrUnexpectedEOF]
+// are resumed.
+//
+// When the feed option is "continuous", a feed closed cleanly by the server,
+// for instance due to a timeout, is reopened as well. Otherwise, iteration
+// ends when a connection reaches the end of its feed.
+func (db *DB) ResumableChanges(ctx context.Context, policy RetryPolicy, options ...Options) *ResumableChanges {
+	opts := mergeOptions(options...)
+	ctx, cancel := context.WithCancel(ctx)
+	r := &ResumableChanges{
+		db:     db,
+		ctx:    ctx,
+		cancel: cancel,
+		opts:   opts,
+		policy: &policy,
+	}
+	if feed, _ := opts["feed"].(string); feed == "continuous" {
+		r.continuous = true
+	}
+	if since, ok := opts["since"]; ok {
+		r.lastSeq = fmt.Sprint(since)
+	}
+	return r
+}
+
+func (r *ResumableChanges) retryable(err error) bool {
+	if r.policy.Retryable != nil {
+		return r.policy.Retryable(err)
+	}
+	return isTransient(err) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// connect opens a new changes feed, starting after the last sequence seen.
+func (r *ResumableChanges) connect() *Changes {
+	opts := make(Options, len(r.opts)+1)
+	for k, v := range r.opts {
+		opts[k] = v
+	}
+	if r.lastSeq != "" {
+		opts["since"] = r.lastSeq
+	}
+	return r.db.Changes(r.ctx, opts)
+}
+
+// Next prepares the next change for reading, reconnecting as necessary. It
+// returns true on success, or false if the feed has ended, a permanent error
+// was encountered, or the iterator was closed.
+func (r *ResumableChanges) Next() bool {
+	for {
+		r.mu.Lock()
+		if r.closed || r.err != nil {
+			r.mu.Unlock()
+			return false
+		}
+		if r.cur == nil {
+			r.cur = r.connect()
+			r.received = false
+		}
+		cur := r.cur
+		r.mu.Unlock()
+
+		if cur.Next() {
+			r.mu.Lock()
+			r.failures = 0
+			r.received = true
+			if seq := cur.Seq(); seq != "" {
+				r.lastSeq = seq
+			}
+			r.mu.Unlock()
+			return true
+		}
+		err := cur.Err()
+		var meta *ChangesMetadata
+		if err == nil {
+			meta, _ = cur.Metadata()
+		}
+		_ = cur.Close()
+
+		r.mu.Lock()
+		r.cur = nil
+		if r.closed {
+			r.mu.Unlock()
+			return false
+		}
+		if err == nil {
+			if meta != nil && meta.LastSeq != "" {
+				r.lastSeq = meta.LastSeq
+			}
+			if !r.continuous {
+				r.closed = true
+				r.mu.Unlock()
+				r.cancel()
+				return false
+			}
+			// Don't hammer a server which closes the feed immediately.
+			received := r.received
+			r.mu.Unlock()
+			if !received {
+				r.wait(r.policy.minBackoff())
+			}
+			continue
+		}
+		r.failures++
+		if r.failures >= r.policy.maxAttempts() || !r.retryable(err) {
+			r.err = err
+			r.mu.Unlock()
+			r.cancel()
+			return false
+		}
+		delay := r.policy.backoff(r.failures, err)
+		r.mu.Unlock()
+		r.wait(delay)
+	}
+}
+
+// wait blocks for d, or until the iterator is closed.
+func (r *ResumableChanges) wait(d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.ctx.Done():
+	case <-t.C:
+	}
+}
+
+// Err returns the error, if any, which ended iteration. A feed which is
+// closed, or ends normally, reports no error.
+func (r *ResumableChanges) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the iterator and the underlying feed. It is safe to call
+// Close concurrently with Next, to stop a blocked continuous feed.
+func (r *ResumableChanges) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	cur := r.cur
+	r.mu.Unlock()
+	r.cancel()
+	if cur != nil {
+		return cur.Close()
+	}
+	return nil
+}
+
+// LastSeq returns the sequence of the last change read, or the last sequence
+// reported by the server at the end of a feed. It may be passed as the since
+// option to a later call to resume from this point.
+func (r *ResumableChanges) LastSeq() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastSeq
+}
+
+// current returns the feed which produced the current change, or an empty
+// one if there is none.
+func (r *ResumableChanges) current() *Changes {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cur == nil {
+		return &Changes{iter: &iter{curVal: &driver.Change{}}}
+	}
+	return r.cur
+}
+
+// ID returns the ID of the current change.
+func (r *ResumableChanges) ID() string {
+	return r.current().ID()
+}
+
+// Seq returns the sequence of the current change.
+func (r *ResumableChanges) Seq() string {
+	return r.current().Seq()
+}
+
+// Changes returns the list of changed revs of the current change.
+func (r *ResumableChanges) Changes() []string {
+	return r.current().Changes()
+}
+
+// Deleted returns true if the current change relates to a deleted document.
+func (r *ResumableChanges) Deleted() bool {
+	return r.current().Deleted()
+}
+
+// ScanDoc unmarshals the document of the current change into dest. It is
+// only valid when documents are included in the feed.
+func (r *ResumableChanges) ScanDoc(dest interface{}) error {
+	return r.current().ScanDoc(dest)
+}
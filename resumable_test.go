@@ -0,0 +1,208 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// changesFeed returns a mock changes feed which returns changes with the
+// given sequences, followed by err.
+func changesFeed(err error, seqs ...string) driver.Changes {
+	return &mock.Changes{
+		NextFunc: func(c *driver.Change) error {
+			if len(seqs) == 0 {
+				return err
+			}
+			*c = driver.Change{ID: "doc" + seqs[0], Seq: seqs[0]}
+			seqs = seqs[1:]
+			return nil
+		},
+	}
+}
+
+func TestResumableChanges(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: time.Nanosecond, MaxBackoff: time.Nanosecond}
+	type feed struct {
+		since interface{}
+		feed  driver.Changes
+		err   error
+	}
+	tests := []struct {
+		name    string
+		opts    Options
+		policy  RetryPolicy
+		feeds   []feed
+		want    []string
+		lastSeq string
+		status  int
+		err     string
+	}{
+		{
+			name:    "no errors",
+			feeds:   []feed{{feed: changesFeed(io.EOF, "1", "2")}},
+			want:    []string{"1", "2"},
+			lastSeq: "2",
+		},
+		{
+			name: "resume after transient error",
+			opts: Param("since", "0"),
+			feeds: []feed{
+				{since: "0", feed: changesFeed(&Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}, "1")},
+				{since: "1", feed: changesFeed(io.ErrUnexpectedEOF, "2")},
+				{since: "2", feed: changesFeed(io.EOF, "3")},
+			},
+			want:    []string{"1", "2", "3"},
+			lastSeq: "3",
+		},
+		{
+			name: "reconnect after failed connection",
+			feeds: []feed{
+				{err: &Error{Status: http.StatusBadGateway, Message: "bad gateway"}},
+				{feed: changesFeed(io.EOF, "1")},
+			},
+			want:    []string{"1"},
+			lastSeq: "1",
+		},
+		{
+			name: "permanent error",
+			feeds: []feed{
+				{feed: changesFeed(&Error{Status: http.StatusUnauthorized, Message: "unauthorized"}, "1")},
+			},
+			want:    []string{"1"},
+			lastSeq: "1",
+			status:  http.StatusUnauthorized,
+			err:     "unauthorized",
+		},
+		{
+			name:   "attempts exhausted",
+			policy: RetryPolicy{MaxAttempts: 2},
+			feeds: []feed{
+				{err: &Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}},
+				{err: &Error{Status: http.StatusServiceUnavailable, Message: "still unavailable"}},
+			},
+			status: http.StatusServiceUnavailable,
+			err:    "still unavailable",
+		},
+		{
+			name:   "custom retryable",
+			policy: RetryPolicy{Retryable: func(error) bool { return true }},
+			feeds: []feed{
+				{feed: changesFeed(errors.New("anything"), "1")},
+				{since: "1", feed: changesFeed(io.EOF, "2")},
+			},
+			want:    []string{"1", "2"},
+			lastSeq: "2",
+		},
+		{
+			name: "continuous feed reopened",
+			opts: Param("feed", "continuous"),
+			feeds: []feed{
+				{feed: changesFeed(io.EOF, "1")},
+				{since: "1", feed: &mock.Changes{LastSeqFunc: func() string { return "4" }}},
+				{since: "4", feed: changesFeed(&Error{Status: http.StatusNotFound, Message: "gone"}, "5")},
+			},
+			want:    []string{"1", "5"},
+			lastSeq: "5",
+			status:  http.StatusNotFound,
+			err:     "gone",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeds := tt.feeds
+			db := &DB{
+				client: &Client{},
+				driverDB: &mock.DB{
+					ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+						if len(feeds) == 0 {
+							t.Fatal("unexpected connection")
+						}
+						f := feeds[0]
+						feeds = feeds[1:]
+						if since := opts["since"]; since != f.since {
+							t.Errorf("Unexpected since: %v, want %v", since, f.since)
+						}
+						return f.feed, f.err
+					},
+				},
+			}
+			p := tt.policy
+			p.MinBackoff, p.MaxBackoff = policy.MinBackoff, policy.MaxBackoff
+			r := db.ResumableChanges(context.Background(), p, tt.opts)
+			var got []string
+			for r.Next() {
+				if r.ID() != "doc"+r.Seq() {
+					t.Errorf("Unexpected ID %s for seq %s", r.ID(), r.Seq())
+				}
+				got = append(got, r.Seq())
+			}
+			if d := testy.DiffInterface(tt.want, got); d != nil {
+				t.Error(d)
+			}
+			if len(feeds) != 0 {
+				t.Errorf("%d feeds not consumed", len(feeds))
+			}
+			if seq := r.LastSeq(); seq != tt.lastSeq {
+				t.Errorf("Unexpected last seq: %s, want %s", seq, tt.lastSeq)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			testy.StatusError(t, tt.err, tt.status, r.Err())
+		})
+	}
+	t.Run("close while blocked", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				ChangesFunc: func(ctx context.Context, _ map[string]interface{}) (driver.Changes, error) {
+					return &mock.Changes{
+						NextFunc: func(*driver.Change) error {
+							<-ctx.Done()
+							return ctx.Err()
+						},
+					}, nil
+				},
+			},
+		}
+		r := db.ResumableChanges(context.Background(), RetryPolicy{}, Param("feed", "continuous"))
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r.Next() {
+				t.Error("Next should return false")
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if err := r.Err(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
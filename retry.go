@@ -0,0 +1,172 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default values used for unset [RetryPolicy] fields.
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMinBackoff  = 100 * time.Millisecond
+	DefaultRetryMaxBackoff  = 10 * time.Second
+)
+
+// RetryPolicy controls how a [Client] retries failed idempotent operations.
+// The zero value is a valid policy, which uses the Default* values for each
+// field.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times an operation is attempted,
+	// including the initial attempt.
+	MaxAttempts int
+
+	// MinBackoff is the upper bound of the delay before the first retry. Each
+	// subsequent retry doubles the bound, up to MaxBackoff. The actual delay is
+	// chosen randomly between 0 and the bound (full jitter).
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between two attempts. It also caps any
+	// delay requested by the server with a Retry-After header.
+	MaxBackoff time.Duration
+
+	// Retryable, if set, replaces the default test for whether an error is
+	// transient. By default, errors with status 429, 502, 503 or 504, and
+	// network errors, are retried.
+	Retryable func(error) bool
+}
+
+// optionRetry is the option key used to pass a [RetryPolicy] to [New].
+const optionRetry = "kivik:retry"
+
+// WithRetry returns an option which, when passed to [New], enables retries of
+// idempotent (read-only) operations which fail with a transient error. Retries
+// use jittered exponential backoff, honor a Retry-After delay reported by the
+// driver, and stop as soon as the operation's context is cancelled.
+//
+// Only the initial request of operations which return an iterator is retried;
+// errors encountered while iterating are returned to the caller as usual.
+func WithRetry(policy RetryPolicy) Options {
+	return Options{optionRetry: &policy}
+}
+
+// retryAfterer is an optional interface which may be implemented by errors
+// returned by a driver, to report the delay requested by the server (usually
+// via the Retry-After header) before the request may be retried.
+//
+// This interface is not exported, but is considered part of the stable public
+// API, in the same way as the statusCoder interface.
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return DefaultRetryMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) minBackoff() time.Duration {
+	if p.MinBackoff <= 0 {
+		return DefaultRetryMinBackoff
+	}
+	return p.MinBackoff
+}
+
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return DefaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return isTransient(err)
+}
+
+// backoff returns the delay before attempt number attempt+1, where attempt
+// counts from 1.
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	max := p.maxBackoff()
+	var ra retryAfterer
+	if errors.As(err, &ra) {
+		if d := ra.RetryAfter(); d > 0 {
+			if d > max {
+				return max
+			}
+			return d
+		}
+	}
+	bound := p.minBackoff()
+	for i := 1; i < attempt && bound < max; i++ {
+		bound *= 2
+	}
+	if bound > max {
+		bound = max
+	}
+	return time.Duration(rand.Int63n(int64(bound) + 1)) // nolint:gosec
+}
+
+// isTransient returns true if err is likely to succeed on a later attempt.
+func isTransient(e error) bool {
+	if e == nil {
+		return false
+	}
+	if errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded) {
+		return false
+	}
+	var kerr err
+	if errors.As(e, &kerr) {
+		// ErrClientClosed and friends are permanent, despite their status.
+		return false
+	}
+	switch HTTPStatus(e) {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	var netErr net.Error
+	return errors.As(e, &netErr)
+}
+
+// retry calls fn, by way of [Client.call], until it succeeds, returns a
+// permanent error, the retry policy is exhausted, or ctx is cancelled. If the
+// client has no retry policy, fn is called exactly once.
+func (c *Client) retry(ctx context.Context, fn func(context.Context) error) error {
+	if c.retryPolicy == nil {
+		return c.call(ctx, fn)
+	}
+	p := c.retryPolicy
+	for attempt := 1; ; attempt++ {
+		err := c.call(ctx, fn)
+		if err == nil || attempt >= p.maxAttempts() || !p.retryable(err) {
+			return err
+		}
+		t := time.NewTimer(p.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
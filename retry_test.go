@@ -0,0 +1,210 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type retryAfterErr struct {
+	status int
+	after  time.Duration
+}
+
+func (e *retryAfterErr) Error() string             { return "retry later" }
+func (e *retryAfterErr) HTTPStatus() int           { return e.status }
+func (e *retryAfterErr) RetryAfter() time.Duration { return e.after }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "not found", err: &Error{Status: http.StatusNotFound}, want: false},
+		{name: "too many requests", err: &Error{Status: http.StatusTooManyRequests}, want: true},
+		{name: "service unavailable", err: &Error{Status: http.StatusServiceUnavailable}, want: true},
+		{name: "bad gateway", err: &Error{Status: http.StatusBadGateway}, want: true},
+		{name: "client closed", err: ErrClientClosed, want: false},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "context canceled", err: context.Canceled, want: false},
+		{name: "plain error", err: errors.New("foo"), want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTransient(test.err); got != test.want {
+				t.Errorf("Unexpected result: %v", got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	for attempt := 1; attempt < 10; attempt++ {
+		if d := p.backoff(attempt, errors.New("x")); d < 0 || d > 5*time.Millisecond {
+			t.Errorf("attempt %d: backoff %s out of range", attempt, d)
+		}
+	}
+	t.Run("Retry-After", func(t *testing.T) {
+		d := p.backoff(1, &retryAfterErr{status: http.StatusTooManyRequests, after: 2 * time.Millisecond})
+		if d != 2*time.Millisecond {
+			t.Errorf("Unexpected backoff: %s", d)
+		}
+	})
+	t.Run("Retry-After capped", func(t *testing.T) {
+		d := p.backoff(1, &retryAfterErr{status: http.StatusTooManyRequests, after: time.Hour})
+		if d != 5*time.Millisecond {
+			t.Errorf("Unexpected backoff: %s", d)
+		}
+	})
+}
+
+func TestClientRetry(t *testing.T) {
+	type tst struct {
+		policy   *RetryPolicy
+		errs     []error
+		ctx      context.Context
+		err      string
+		attempts int
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := testy.NewTable()
+	tests.Add("no policy", tst{
+		errs:     []error{&Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}},
+		err:      "unavailable",
+		attempts: 1,
+	})
+	tests.Add("success after retries", tst{
+		policy: &RetryPolicy{MinBackoff: time.Microsecond},
+		errs: []error{
+			&Error{Status: http.StatusServiceUnavailable},
+			&Error{Status: http.StatusServiceUnavailable},
+			nil,
+		},
+		attempts: 3,
+	})
+	tests.Add("permanent error", tst{
+		policy:   &RetryPolicy{MinBackoff: time.Microsecond},
+		errs:     []error{&Error{Status: http.StatusNotFound, Message: "missing"}},
+		err:      "missing",
+		attempts: 1,
+	})
+	tests.Add("attempts exhausted", tst{
+		policy: &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Microsecond},
+		errs: []error{
+			&Error{Status: http.StatusTooManyRequests},
+			&Error{Status: http.StatusTooManyRequests, Message: "slow down"},
+			nil,
+		},
+		err:      "slow down",
+		attempts: 2,
+	})
+	tests.Add("context cancelled", tst{
+		policy:   &RetryPolicy{MinBackoff: time.Hour},
+		ctx:      canceled,
+		errs:     []error{&Error{Status: http.StatusBadGateway, Message: "bad gateway"}, nil},
+		err:      "bad gateway",
+		attempts: 1,
+	})
+	tests.Add("custom retryable", tst{
+		policy: &RetryPolicy{
+			MinBackoff: time.Microsecond,
+			Retryable:  func(err error) bool { return HTTPStatus(err) == http.StatusNotFound },
+		},
+		errs:     []error{&Error{Status: http.StatusNotFound}, nil},
+		attempts: 2,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		ctx := test.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		c := &Client{retryPolicy: test.policy}
+		var attempts int
+		err := c.retry(ctx, func(context.Context) error {
+			err := test.errs[attempts]
+			attempts++
+			return err
+		})
+		testy.Error(t, test.err, err)
+		if attempts != test.attempts {
+			t.Errorf("Unexpected attempts: %d", attempts)
+		}
+	})
+}
+
+func TestWithRetry(t *testing.T) {
+	c := &Client{}
+	opts := c.applyOptions(mergeOptions(WithRetry(RetryPolicy{MaxAttempts: 5}), Options{"foo": "bar"}))
+	if d := testy.DiffInterface(Options{"foo": "bar"}, opts); d != nil {
+		t.Errorf("Unexpected driver options:\n%s", d)
+	}
+	if c.retryPolicy == nil || c.retryPolicy.MaxAttempts != 5 {
+		t.Errorf("Unexpected retry policy: %v", c.retryPolicy)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		var attempts int
+		db := &DB{
+			client: &Client{retryPolicy: &RetryPolicy{MinBackoff: time.Microsecond}},
+			driverDB: &mock.DB{
+				GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+					attempts++
+					if attempts < 2 {
+						return nil, &Error{Status: http.StatusServiceUnavailable}
+					}
+					return &driver.Document{Rev: "1-xxx", Body: body(`{}`)}, nil
+				},
+			},
+		}
+		rev, err := db.Get(context.Background(), "foo").Rev()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-xxx" || attempts != 2 {
+			t.Errorf("Unexpected result: rev=%s attempts=%d", rev, attempts)
+		}
+	})
+	t.Run("Put is not retried", func(t *testing.T) {
+		var attempts int
+		db := &DB{
+			client: &Client{retryPolicy: &RetryPolicy{MinBackoff: time.Microsecond}},
+			driverDB: &mock.DB{
+				PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+					attempts++
+					return "", &Error{Status: http.StatusServiceUnavailable, Message: "unavailable"}
+				},
+			},
+		}
+		_, err := db.Put(context.Background(), "foo", map[string]string{})
+		testy.Error(t, "unavailable", err)
+		if attempts != 1 {
+			t.Errorf("Unexpected attempts: %d", attempts)
+		}
+	})
+}
@@ -13,7 +13,6 @@
 package kivik
 
 import (
-	"encoding/json"
 	"io"
 	"sync/atomic"
 )
@@ -23,7 +22,7 @@ import (
 // multipart/related responses. When done, the underlying reader is closed.
 func (r *row) ScanDoc(dest interface{}) error {
 	defer r.body.Close() // nolint:errcheck
-	return json.NewDecoder(r.body).Decode(dest)
+	return decodeDoc(r.body, dest, r.useNumber, r.codec)
 }
 
 type row struct {
@@ -32,6 +31,9 @@ type row struct {
 	body io.ReadCloser
 	atts *AttachmentsIterator
 
+	useNumber bool
+	codec     Codec
+
 	// prepared is set to true by the first call to Next()
 	prepared int32
 	errRS
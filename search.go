@@ -0,0 +1,120 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var searchNotImplemented = &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support Search interface"}
+
+// SearchInfo is the result of a [DB.SearchInfo] request.
+type SearchInfo struct {
+	Name        string
+	SearchIndex SearchIndex
+	// RawResponse is the raw JSON response returned by the server.
+	RawResponse json.RawMessage
+}
+
+// SearchIndex contains statistics about a full-text search index.
+type SearchIndex struct {
+	PendingSeq   int64
+	DocDelCount  int64
+	DocCount     int64
+	DiskSize     int64
+	CommittedSeq int64
+}
+
+// Search performs a full-text search against the specified ddoc and index,
+// with the specified Lucene query, as supported by Cloudant and CouchDB 3.0.0
+// or later with the search plugin. Each row's ID is the ID of a matching
+// document, and its Value holds the stored fields of the match. Options such
+// as limit, bookmark and include_docs are passed to the driver.
+// See https://docs.couchdb.org/en/stable/ddocs/search.html
+func (db *DB) Search(ctx context.Context, ddoc, index, query string, options ...Options) ResultSet {
+	if db.err != nil {
+		return &errRS{err: db.err}
+	}
+	if searcher, ok := optional(db.driverDB, (*driver.Searcher)(nil)).(driver.Searcher); ok {
+		if err := db.startQuery(); err != nil {
+			return &errRS{err: err}
+		}
+		var rowsi driver.Rows
+		opts := mergeOptions(options...)
+		op := &Operation{Method: "Search", DB: db.name, Options: opts, ReadOnly: true, iterator: true}
+		err := db.client.invoke(ctx, op, func(ctx context.Context) (err error) {
+			rowsi, err = searcher.Search(ctx, ddoc, index, query, opts)
+			return err
+		})
+		if err != nil {
+			db.endQuery()
+			return &errRS{err: err}
+		}
+		it := newRows(ctx, db.endQuery, rowsi)
+		db.trackIterator(op, it.iter)
+		return it
+	}
+	return &errRS{err: searchNotImplemented}
+}
+
+// SearchInfo returns statistics about the specified search index.
+func (db *DB) SearchInfo(ctx context.Context, ddoc, index string) (*SearchInfo, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if err := db.startQuery(); err != nil {
+		return nil, err
+	}
+	defer db.endQuery()
+	if searcher, ok := optional(db.driverDB, (*driver.Searcher)(nil)).(driver.Searcher); ok {
+		var info *driver.SearchInfo
+		err := db.client.invoke(ctx, &Operation{Method: "SearchInfo", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+			info, err = searcher.SearchInfo(ctx, ddoc, index)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &SearchInfo{
+			Name:        info.Name,
+			SearchIndex: SearchIndex(info.SearchIndex),
+			RawResponse: info.RawResponse,
+		}, nil
+	}
+	return nil, searchNotImplemented
+}
+
+// SearchAnalyze returns the tokens produced by the search analyzer for text,
+// which is useful to understand how a query or document field is indexed.
+func (db *DB) SearchAnalyze(ctx context.Context, text string) ([]string, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if err := db.startQuery(); err != nil {
+		return nil, err
+	}
+	defer db.endQuery()
+	if searcher, ok := optional(db.driverDB, (*driver.Searcher)(nil)).(driver.Searcher); ok {
+		var tokens []string
+		err := db.client.invoke(ctx, &Operation{Method: "SearchAnalyze", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+			tokens, err = searcher.SearchAnalyze(ctx, text)
+			return err
+		})
+		return tokens, err
+	}
+	return nil, searchNotImplemented
+}
@@ -0,0 +1,202 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSearch(t *testing.T) {
+	type tt struct {
+		db       *DB
+		expected []string
+		status   int
+		err      string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("non-searcher", tt{
+		db: &DB{
+			client:   &Client{},
+			driverDB: &mock.DB{},
+		},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support Search interface",
+	})
+	tests.Add("search error", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.Searcher{
+				SearchFunc: func(context.Context, string, string, string, map[string]interface{}) (driver.Rows, error) {
+					return nil, errors.New("search error")
+				},
+			},
+		},
+		status: http.StatusInternalServerError,
+		err:    "search error",
+	})
+	tests.Add("success", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.Searcher{
+				SearchFunc: func(_ context.Context, ddoc, index, query string, opts map[string]interface{}) (driver.Rows, error) {
+					if ddoc != "animals" || index != "names" || query != "name:bessie" {
+						return nil, errors.New("unexpected arguments")
+					}
+					if opts["limit"] != 10 {
+						return nil, errors.New("unexpected options")
+					}
+					var n int
+					return &mock.Rows{
+						NextFunc: func(row *driver.Row) error {
+							if n == 2 {
+								return io.EOF
+							}
+							n++
+							row.ID = []string{"cow", "sheep"}[n-1]
+							return nil
+						},
+					}, nil
+				},
+			},
+		},
+		expected: []string{"cow", "sheep"},
+	})
+	tests.Add(errClientClosed, tt{
+		db: &DB{
+			client: &Client{
+				closed: 1,
+			},
+			driverDB: &mock.Searcher{},
+		},
+		status: http.StatusServiceUnavailable,
+		err:    errClientClosed,
+	})
+	tests.Add("db error", tt{
+		db: &DB{
+			err: errors.New("db error"),
+		},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		rs := tt.db.Search(context.Background(), "animals", "names", "name:bessie", Param("limit", 10))
+		var ids []string
+		for rs.Next() {
+			id, _ := rs.ID()
+			ids = append(ids, id)
+		}
+		testy.StatusError(t, tt.err, tt.status, rs.Err())
+		if d := testy.DiffInterface(tt.expected, ids); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSearchInfo(t *testing.T) {
+	type tt struct {
+		db       *DB
+		expected *SearchInfo
+		status   int
+		err      string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("non-searcher", tt{
+		db: &DB{
+			client:   &Client{},
+			driverDB: &mock.DB{},
+		},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support Search interface",
+	})
+	tests.Add("error", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.Searcher{
+				SearchInfoFunc: func(context.Context, string, string) (*driver.SearchInfo, error) {
+					return nil, &Error{Status: http.StatusNotFound, Message: "not found"}
+				},
+			},
+		},
+		status: http.StatusNotFound,
+		err:    "not found",
+	})
+	tests.Add("success", tt{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.Searcher{
+				SearchInfoFunc: func(_ context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+					return &driver.SearchInfo{
+						Name:        ddoc + "/" + index,
+						SearchIndex: driver.SearchIndex{DocCount: 3, PendingSeq: 5},
+					}, nil
+				},
+			},
+		},
+		expected: &SearchInfo{
+			Name:        "animals/names",
+			SearchIndex: SearchIndex{DocCount: 3, PendingSeq: 5},
+		},
+	})
+	tests.Add("db error", tt{
+		db: &DB{
+			err: errors.New("db error"),
+		},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		info, err := tt.db.SearchInfo(context.Background(), "animals", "names")
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, info); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSearchAnalyze(t *testing.T) {
+	t.Run("non-searcher", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.DB{}}
+		_, err := db.SearchAnalyze(context.Background(), "foo")
+		testy.StatusError(t, "kivik: driver does not support Search interface", http.StatusNotImplemented, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.Searcher{
+				SearchAnalyzeFunc: func(_ context.Context, text string) ([]string, error) {
+					return []string{text}, nil
+				},
+			},
+		}
+		tokens, err := db.SearchAnalyze(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"foo"}, tokens); d != nil {
+			t.Error(d)
+		}
+	})
+}
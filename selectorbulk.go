@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+)
+
+// selectorQuery returns a Find query for all documents matching selector,
+// which may be a JSON string, []byte, [encoding/json.RawMessage], or any
+// value which marshals to a JSON selector object.
+func selectorQuery(selector interface{}, fields ...string) (map[string]interface{}, error) {
+	switch t := selector.(type) {
+	case nil:
+		return nil, missingArg("selector")
+	case string:
+		selector = json.RawMessage(t)
+	case []byte:
+		selector = json.RawMessage(t)
+	}
+	query := map[string]interface{}{
+		"selector": selector,
+		"limit":    math.MaxInt32,
+	}
+	if len(fields) > 0 {
+		query["fields"] = fields
+	}
+	return query, nil
+}
+
+// bulkBatches calls fn for each document returned by rs, and stores the
+// documents it returns with BulkDocs, in batches of up to size. It returns the
+// results of all batches stored.
+func (db *DB) bulkBatches(ctx context.Context, rs ResultSet, size int, fn func(ResultSet) (interface{}, error)) ([]BulkResult, error) {
+	defer rs.Close() // nolint:errcheck
+	var results []BulkResult
+	batch := make([]interface{}, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		batchResults, err := db.BulkDocs(ctx, batch)
+		if err != nil {
+			return err
+		}
+		results = append(results, batchResults...)
+		// The driver may retain the batch, so it is not reused.
+		batch = make([]interface{}, 0, size)
+		return nil
+	}
+	for rs.Next() {
+		doc, err := fn(rs)
+		if err != nil {
+			return results, err
+		}
+		if doc == nil {
+			continue
+		}
+		batch = append(batch, doc)
+		if len(batch) == size {
+			if err := flush(); err != nil {
+				return results, err
+			}
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return results, err
+	}
+	if err := flush(); err != nil {
+		return results, err
+	}
+	return results, NewBulkError(results)
+}
+
+// DeleteBySelector deletes every document matching the Mango selector, which
+// may be any value accepted as the selector of a [DB.Find] query, such as a
+// map or a JSON string. Matching documents are deleted with [DB.BulkDocs], in
+// batches of up to [WithBatchSize] documents. Other options are passed to
+// Find.
+//
+// The results report the outcome for each document deleted. If any document
+// could not be deleted, for instance because it was modified concurrently,
+// the error is a [*BulkError]. If the query or a batch fails as a whole,
+// DeleteBySelector stops, and returns the results so far along with the
+// error.
+func (db *DB) DeleteBySelector(ctx context.Context, selector interface{}, options ...Options) ([]BulkResult, error) {
+	query, err := selectorQuery(selector, "_id", "_rev")
+	if err != nil {
+		return nil, err
+	}
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size, err := bulkPutOption(opts, optionBatchSize, "batch size", DefaultBulkBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	rs := db.Find(ctx, query, opts)
+	return db.bulkBatches(ctx, rs, size, func(rs ResultSet) (interface{}, error) {
+		var doc struct {
+			ID  string `json:"_id"`
+			Rev string `json:"_rev"`
+		}
+		if err := rs.ScanDoc(&doc); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"_id": doc.ID, "_rev": doc.Rev, "_deleted": true}, nil
+	})
+}
+
+// UpdateBySelector calls fn with every document matching the Mango selector,
+// as for [DB.DeleteBySelector], and saves the new versions returned by fn with
+// [DB.BulkDocs], in batches of up to [WithBatchSize] documents. As with
+// [DB.Update], fn returns nil to leave a document unchanged, and any _id or
+// _rev field in the returned document is ignored. Other options are passed to
+// Find.
+//
+// The results report the outcome for each document saved. If any document
+// could not be saved, for instance because it was modified concurrently, the
+// error is a [*BulkError]; unlike [DB.Update], conflicts are not retried. If fn
+// returns an error, or the query or a batch fails as a whole,
+// UpdateBySelector stops, and returns the results of the batches already
+// saved along with the error.
+func (db *DB) UpdateBySelector(ctx context.Context, selector interface{}, fn UpdateFunc, options ...Options) ([]BulkResult, error) {
+	if fn == nil {
+		return nil, missingArg("fn")
+	}
+	query, err := selectorQuery(selector)
+	if err != nil {
+		return nil, err
+	}
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size, err := bulkPutOption(opts, optionBatchSize, "batch size", DefaultBulkBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	rs := db.Find(ctx, query, opts)
+	return db.bulkBatches(ctx, rs, size, func(rs ResultSet) (interface{}, error) {
+		var current json.RawMessage
+		if err := rs.ScanDoc(&current); err != nil {
+			return nil, err
+		}
+		var meta struct {
+			ID  string `json:"_id"`
+			Rev string `json:"_rev"`
+		}
+		_ = json.Unmarshal(current, &meta)
+		updated, err := fn(current)
+		if err != nil || updated == nil {
+			return nil, err
+		}
+		doc, err := toDocMap(updated, db.useNumber)
+		if err != nil {
+			return nil, err
+		}
+		doc["_id"] = meta.ID
+		doc["_rev"] = meta.Rev
+		return doc, nil
+	})
+}
@@ -0,0 +1,154 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// findBulkDocer is a driver.DB which implements both Finder and BulkDocer.
+type findBulkDocer struct {
+	*mock.Finder
+	BulkDocsFunc func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error)
+}
+
+func (db *findBulkDocer) BulkDocs(ctx context.Context, docs []interface{}, opts map[string]interface{}) ([]driver.BulkResult, error) {
+	return db.BulkDocsFunc(ctx, docs, opts)
+}
+
+func TestSelectorBulk(t *testing.T) {
+	matches := func() []mock.Row {
+		return []mock.Row{
+			{Doc: `{"_id":"a","_rev":"1-a","n":1}`},
+			{Doc: `{"_id":"b","_rev":"1-b","n":2}`},
+			{Doc: `{"_id":"c","_rev":"1-c","n":3}`},
+		}
+	}
+	newDB := func(queries *[]map[string]interface{}, batches *[][]interface{}) *DB {
+		return &DB{
+			client: &Client{},
+			driverDB: &findBulkDocer{
+				Finder: &mock.Finder{
+					FindFunc: func(_ context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+						raw, _ := json.Marshal(query)
+						var q map[string]interface{}
+						_ = json.Unmarshal(raw, &q)
+						*queries = append(*queries, q)
+						return mock.NewRowsFeed(matches()...).Rows(), nil
+					},
+				},
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+					*batches = append(*batches, docs)
+					results := make([]driver.BulkResult, len(docs))
+					for i, doc := range docs {
+						id := doc.(map[string]interface{})["_id"].(string)
+						results[i] = driver.BulkResult{ID: id, Rev: "2-" + id}
+						if id == "b" {
+							results[i] = driver.BulkResult{ID: id, Error: &Error{Status: http.StatusConflict, Message: "conflict"}}
+						}
+					}
+					return results, nil
+				},
+			},
+		}
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		var queries []map[string]interface{}
+		var batches [][]interface{}
+		db := newDB(&queries, &batches)
+		results, err := db.DeleteBySelector(context.Background(), `{"n":{"$gt":0}}`, WithBatchSize(2))
+		wantQuery := []map[string]interface{}{{
+			"selector": map[string]interface{}{"n": map[string]interface{}{"$gt": float64(0)}},
+			"fields":   []interface{}{"_id", "_rev"},
+			"limit":    float64(2147483647),
+		}}
+		if d := testy.DiffInterface(wantQuery, queries); d != nil {
+			t.Error(d)
+		}
+		wantBatches := [][]interface{}{
+			{
+				map[string]interface{}{"_id": "a", "_rev": "1-a", "_deleted": true},
+				map[string]interface{}{"_id": "b", "_rev": "1-b", "_deleted": true},
+			},
+			{
+				map[string]interface{}{"_id": "c", "_rev": "1-c", "_deleted": true},
+			},
+		}
+		if d := testy.DiffInterface(wantBatches, batches); d != nil {
+			t.Error(d)
+		}
+		if len(results) != 3 {
+			t.Errorf("Unexpected results: %v", results)
+		}
+		var bulkErr *BulkError
+		if !errors.As(err, &bulkErr) || len(bulkErr.Errors) != 1 || bulkErr.Errors[0].ID != "b" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		var queries []map[string]interface{}
+		var batches [][]interface{}
+		db := newDB(&queries, &batches)
+		results, err := db.UpdateBySelector(context.Background(), map[string]interface{}{"n": 1}, func(doc json.RawMessage) (interface{}, error) {
+			var d map[string]interface{}
+			if err := json.Unmarshal(doc, &d); err != nil {
+				return nil, err
+			}
+			if d["_id"] == "b" {
+				return nil, nil
+			}
+			d["n"] = d["n"].(float64) * 10
+			d["_rev"] = "bogus"
+			return d, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantBatches := [][]interface{}{{
+			map[string]interface{}{"_id": "a", "_rev": "1-a", "n": float64(10)},
+			map[string]interface{}{"_id": "c", "_rev": "1-c", "n": float64(30)},
+		}}
+		if d := testy.DiffInterface(wantBatches, batches); d != nil {
+			t.Error(d)
+		}
+		if len(results) != 2 || results[1].Rev != "2-c" {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("update error", func(t *testing.T) {
+		var queries []map[string]interface{}
+		var batches [][]interface{}
+		db := newDB(&queries, &batches)
+		_, err := db.UpdateBySelector(context.Background(), map[string]interface{}{}, func(json.RawMessage) (interface{}, error) {
+			return nil, errors.New("nope")
+		})
+		if len(batches) != 0 {
+			t.Errorf("Nothing should be stored, got %v", batches)
+		}
+		testy.Error(t, "nope", err)
+	})
+	t.Run("no selector", func(t *testing.T) {
+		_, err := (&DB{}).DeleteBySelector(context.Background(), nil)
+		testy.StatusError(t, "kivik: selector required", http.StatusBadRequest, err)
+	})
+}
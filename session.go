@@ -46,8 +46,12 @@ func (c *Client) Session(ctx context.Context) (*Session, error) {
 		return nil, err
 	}
 	defer c.endQuery()
-	if sessioner, ok := c.driverClient.(driver.Sessioner); ok {
-		session, err := sessioner.Session(ctx)
+	if sessioner, ok := optional(c.driverClient, (*driver.Sessioner)(nil)).(driver.Sessioner); ok {
+		var session *driver.Session
+		err := c.invoke(ctx, &Operation{Method: "Session", ReadOnly: true}, func(ctx context.Context) (err error) {
+			session, err = sessioner.Session(ctx)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var shardsNotImplemented = &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support shard inspection"}
+
+// ShardMap is the shard map of a database.
+type ShardMap struct {
+	// Ranges are the nodes which hold a copy of each shard, keyed by the
+	// shard's range of document ID hashes, such as "00000000-1fffffff".
+	Ranges map[string][]string
+}
+
+// ByNode returns the ranges of the shards held by each node, in order.
+// A node which holds more shards than its peers may be a hot spot.
+func (s *ShardMap) ByNode() map[string][]string {
+	nodes := map[string][]string{}
+	for r, holders := range s.Ranges {
+		for _, node := range holders {
+			nodes[node] = append(nodes[node], r)
+		}
+	}
+	for _, ranges := range nodes {
+		sort.Strings(ranges)
+	}
+	return nodes
+}
+
+// DocShard is the shard which holds a document.
+type DocShard struct {
+	// Range is the shard's range of document ID hashes.
+	Range string
+	// Nodes are the nodes which hold a copy of the shard.
+	Nodes []string
+}
+
+// Shards returns the database's shard map, which lists the nodes holding a
+// copy of each shard.
+//
+// See https://docs.couchdb.org/en/stable/api/database/shard.html#get--db-_shards
+func (db *DB) Shards(ctx context.Context) (*ShardMap, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if err := db.startQuery(); err != nil {
+		return nil, err
+	}
+	defer db.endQuery()
+	sharder, ok := optional(db.driverDB, (*driver.Sharder)(nil)).(driver.Sharder)
+	if !ok {
+		return nil, shardsNotImplemented
+	}
+	var ranges map[string][]string
+	err := db.client.invoke(ctx, &Operation{Method: "Shards", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+		ranges, err = sharder.Shards(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ShardMap{Ranges: ranges}, nil
+}
+
+// DocShard returns the shard which holds, or would hold, the document with the
+// given ID, and the nodes which hold a copy of that shard.
+//
+// See https://docs.couchdb.org/en/stable/api/database/shard.html#get--db-_shards-docid
+func (db *DB) DocShard(ctx context.Context, docID string) (*DocShard, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	if err := db.startQuery(); err != nil {
+		return nil, err
+	}
+	defer db.endQuery()
+	sharder, ok := optional(db.driverDB, (*driver.Sharder)(nil)).(driver.Sharder)
+	if !ok {
+		return nil, shardsNotImplemented
+	}
+	var shard *driver.DocShard
+	err := db.client.invoke(ctx, &Operation{Method: "DocShard", DB: db.name, ReadOnly: true}, func(ctx context.Context) (err error) {
+		shard, err = sharder.DocShard(ctx, docID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := DocShard(*shard)
+	return &s, nil
+}
@@ -0,0 +1,134 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestShards(t *testing.T) {
+	type tt struct {
+		db     *DB
+		want   *ShardMap
+		status int
+		err    string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("not supported", tt{
+		db:     &DB{client: &Client{}, driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support shard inspection",
+	})
+	tests.Add("error", tt{
+		db: &DB{client: &Client{}, driverDB: &mock.Sharder{
+			ShardsFunc: func(context.Context) (map[string][]string, error) {
+				return nil, &Error{Status: http.StatusNotFound, Message: "not found"}
+			},
+		}},
+		status: http.StatusNotFound,
+		err:    "not found",
+	})
+	tests.Add("success", tt{
+		db: &DB{client: &Client{}, driverDB: &mock.Sharder{
+			ShardsFunc: func(context.Context) (map[string][]string, error) {
+				return map[string][]string{
+					"00000000-7fffffff": {"node1@127.0.0.1", "node2@127.0.0.1"},
+					"80000000-ffffffff": {"node2@127.0.0.1"},
+				}, nil
+			},
+		}},
+		want: &ShardMap{Ranges: map[string][]string{
+			"00000000-7fffffff": {"node1@127.0.0.1", "node2@127.0.0.1"},
+			"80000000-ffffffff": {"node2@127.0.0.1"},
+		}},
+	})
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		shards, err := tt.db.Shards(context.Background())
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.want, shards); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestShardMapByNode(t *testing.T) {
+	shards := &ShardMap{Ranges: map[string][]string{
+		"80000000-ffffffff": {"node1", "node2"},
+		"00000000-7fffffff": {"node1"},
+	}}
+	want := map[string][]string{
+		"node1": {"00000000-7fffffff", "80000000-ffffffff"},
+		"node2": {"80000000-ffffffff"},
+	}
+	if d := testy.DiffInterface(want, shards.ByNode()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestDocShard(t *testing.T) {
+	type tt struct {
+		db     *DB
+		docID  string
+		want   *DocShard
+		status int
+		err    string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("not supported", tt{
+		db:     &DB{client: &Client{}, driverDB: &mock.DB{}},
+		docID:  "foo",
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support shard inspection",
+	})
+	tests.Add("missing doc ID", tt{
+		db:     &DB{client: &Client{}, driverDB: &mock.Sharder{}},
+		status: http.StatusBadRequest,
+		err:    "kivik: docID required",
+	})
+	tests.Add("success", tt{
+		db: &DB{client: &Client{}, driverDB: &mock.Sharder{
+			DocShardFunc: func(_ context.Context, docID string) (*driver.DocShard, error) {
+				if docID != "foo" {
+					return nil, errors.New("unexpected doc ID")
+				}
+				return &driver.DocShard{Range: "e0000000-ffffffff", Nodes: []string{"node1"}}, nil
+			},
+		}},
+		docID: "foo",
+		want:  &DocShard{Range: "e0000000-ffffffff", Nodes: []string{"node1"}},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		shard, err := tt.db.DocShard(context.Background(), tt.docID)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.want, shard); d != nil {
+			t.Error(d)
+		}
+	})
+}
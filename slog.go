@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+//go:build go1.21
+
+package kivik
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LogConfig configures the logging enabled by [WithLogger].
+type LogConfig struct {
+	// Level is the level at which successful operations are logged. If nil,
+	// [slog.LevelDebug] is used.
+	Level slog.Leveler
+	// ErrorLevel is the level at which failed operations are logged. If nil,
+	// [slog.LevelError] is used.
+	ErrorLevel slog.Leveler
+	// SlowThreshold, if positive, causes operations which take at least this
+	// long to be logged at no lower than [slog.LevelWarn]. The duration is
+	// measured until the operation returns, so for operations which return an
+	// iterator, it excludes reading the results. To report the time until the
+	// iterator is closed, with the number of rows read, use
+	// [WithSlowQueryLog] with [SlowQueryLogger].
+	SlowThreshold time.Duration
+}
+
+func (c LogConfig) level(err error, duration time.Duration) slog.Level {
+	var level slog.Level
+	switch {
+	case err != nil && c.ErrorLevel != nil:
+		level = c.ErrorLevel.Level()
+	case err != nil:
+		level = slog.LevelError
+	case c.Level != nil:
+		level = c.Level.Level()
+	default:
+		level = slog.LevelDebug
+	}
+	if c.SlowThreshold > 0 && duration >= c.SlowThreshold && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	return level
+}
+
+// WithLogger returns an option which, when passed to [New], causes the client
+// to log each operation to logger, with the attributes op, db, docid and
+// duration, and for failed operations, status and error. An optional config
+// may be passed to control the levels used.
+func WithLogger(logger *slog.Logger, config ...LogConfig) Options {
+	var cfg LogConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	return Options{optionLogger: logMiddleware(logger, cfg)}
+}
+
+func logMiddleware(logger *slog.Logger, cfg LogConfig) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			duration := time.Since(start)
+			level := cfg.level(err, duration)
+			if !logger.Enabled(ctx, level) {
+				return err
+			}
+			msg := "kivik operation"
+			if cfg.SlowThreshold > 0 && duration >= cfg.SlowThreshold {
+				msg = "kivik slow operation"
+			}
+			logger.LogAttrs(ctx, level, msg, logAttrs(op.Method, op.DB, op.DocID, duration, err)...)
+			return err
+		}
+	}
+}
+
+// logAttrs returns the attributes logged for an operation.
+func logAttrs(method, db, docID string, duration time.Duration, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("op", method),
+		slog.String("db", db),
+		slog.String("docid", docID),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs,
+			slog.Int("status", HTTPStatus(err)),
+			slog.String("error", err.Error()),
+		)
+	}
+	return attrs
+}
+
+// SlowQueryLogger returns a function, for use with [WithSlowQueryLog], which
+// logs each slow query to logger at [slog.LevelWarn], with the same
+// attributes as [WithLogger], and for operations which return an iterator,
+// rows. This allows slow iterators to be reported with the rest of the
+// client's logs.
+func SlowQueryLogger(logger *slog.Logger) func(SlowQuery) {
+	return func(q SlowQuery) {
+		attrs := logAttrs(q.Method, q.DB, q.DocID, q.Duration, q.Err)
+		if q.Iterator {
+			attrs = append(attrs, slog.Int64("rows", q.Rows))
+		}
+		if len(q.Options) > 0 {
+			attrs = append(attrs, slog.Any("options", q.Options))
+		}
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "kivik slow query", attrs...)
+	}
+}
@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+//go:build go1.21
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestLogConfigLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      LogConfig
+		err      error
+		duration time.Duration
+		want     slog.Level
+	}{
+		{name: "success", want: slog.LevelDebug},
+		{name: "error", err: errors.New("x"), want: slog.LevelError},
+		{name: "custom levels", cfg: LogConfig{Level: slog.LevelInfo}, want: slog.LevelInfo},
+		{name: "custom error level", cfg: LogConfig{ErrorLevel: slog.LevelWarn}, err: errors.New("x"), want: slog.LevelWarn},
+		{name: "slow", cfg: LogConfig{SlowThreshold: time.Second}, duration: 2 * time.Second, want: slog.LevelWarn},
+		{name: "fast", cfg: LogConfig{SlowThreshold: time.Second}, duration: time.Millisecond, want: slog.LevelDebug},
+		{name: "slow error", cfg: LogConfig{SlowThreshold: time.Second}, err: errors.New("x"), duration: 2 * time.Second, want: slog.LevelError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.cfg.level(test.err, test.duration); got != test.want {
+				t.Errorf("Unexpected level: %s", got)
+			}
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	client := &Client{
+		driverClient: &mock.Client{
+			CreateDBFunc: func(context.Context, string, map[string]interface{}) error {
+				return &Error{Status: http.StatusPreconditionFailed, Message: "exists"}
+			},
+		},
+	}
+	opts := client.applyOptions(mergeOptions(WithLogger(logger), Options{"foo": "bar"}))
+	if d := testy.DiffInterface(Options{"foo": "bar"}, opts); d != nil {
+		t.Errorf("Unexpected driver options:\n%s", d)
+	}
+	db := &DB{
+		client: client,
+		name:   "animals",
+		driverDB: &mock.DB{
+			DeleteFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+				return "2-xxx", nil
+			},
+		},
+	}
+	_, _ = db.Delete(context.Background(), "cow", "1-xxx")
+	_ = client.CreateDB(context.Background(), "animals")
+
+	want := `level=DEBUG msg="kivik operation" op=Delete db=animals docid=cow
+level=ERROR msg="kivik operation" op=CreateDB db=animals docid="" status=412 error=exists
+`
+	if d := testy.DiffText(want, buf.String()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSlowQueryLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	SlowQueryLogger(logger)(SlowQuery{
+		Method:   "Query",
+		DB:       "animals",
+		Duration: 1500 * time.Millisecond,
+		Options:  map[string]interface{}{"limit": 10},
+		Iterator: true,
+		Rows:     42,
+	})
+	want := `level=WARN msg="kivik slow query" op=Query db=animals docid="" duration=1.5s rows=42 options=map[limit:10]
+`
+	if d := testy.DiffText(want, buf.String()); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,138 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// SlowQuery describes an operation which took longer than the threshold set
+// with [WithSlowQueryLog].
+type SlowQuery struct {
+	// Time is the time at which the operation started.
+	Time time.Time
+	// Duration is the time taken by the operation, including any retries.
+	// For operations which return an iterator, such as [DB.AllDocs] or
+	// [DB.Query], it runs until the iterator is closed, so it includes the
+	// time taken by the caller to consume the results.
+	Duration time.Duration
+	// Method, DB and DocID are copied from the [Operation].
+	Method string
+	DB     string
+	DocID  string
+	// Options are the options passed to the driver, sanitized as for
+	// [DebugRecord].
+	Options map[string]interface{}
+	// Iterator is true for operations which return an iterator, and Rows is
+	// the number of rows read from it.
+	Iterator bool
+	Rows     int64
+	// Err is the error returned by the operation, or by the iterator, if
+	// any.
+	Err error
+}
+
+// String returns a one-line description of the slow query, suitable for
+// logging.
+func (q SlowQuery) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "kivik: slow query: %s", q.Method)
+	if q.DB != "" {
+		fmt.Fprintf(&b, " db=%s", q.DB)
+	}
+	if q.DocID != "" {
+		fmt.Fprintf(&b, " docid=%s", q.DocID)
+	}
+	fmt.Fprintf(&b, " duration=%s", q.Duration)
+	if q.Iterator {
+		fmt.Fprintf(&b, " rows=%d", q.Rows)
+	}
+	if len(q.Options) > 0 {
+		if opts, err := json.Marshal(q.Options); err == nil {
+			fmt.Fprintf(&b, " options=%s", opts)
+		}
+	}
+	if q.Err != nil {
+		fmt.Fprintf(&b, " error=%q", q.Err)
+	}
+	return b.String()
+}
+
+// optionSlowQueryLog is the option key used by [WithSlowQueryLog].
+const optionSlowQueryLog = "kivik:slowQueryLog"
+
+type slowQueryLog struct {
+	threshold time.Duration
+	fn        func(SlowQuery)
+}
+
+// WithSlowQueryLog returns an option which, when passed to [New], causes fn
+// to be called for each operation which takes threshold or longer. If fn is
+// nil, slow operations are logged with the standard library's default
+// logger. fn is called synchronously, from the goroutine which completed the
+// operation or closed the iterator, so it should not block. To log slow
+// queries with [log/slog], pass [SlowQueryLogger] as fn.
+//
+// This differs from [LogConfig.SlowThreshold], which raises the level of the
+// entry logged by [WithLogger] for each operation: the duration of an
+// operation which returns an iterator runs until the iterator is closed, and
+// is reported with the number of rows read, so that slow views are caught
+// even when the initial request is fast. It is also available without
+// log/slog, which requires Go 1.21.
+func WithSlowQueryLog(threshold time.Duration, fn func(SlowQuery)) Options {
+	if fn == nil {
+		fn = func(q SlowQuery) { log.Print(q) }
+	}
+	return Options{optionSlowQueryLog: &slowQueryLog{threshold: threshold, fn: fn}}
+}
+
+// middleware returns middleware which reports slow operations. Operations
+// which return an iterator are reported by the client once the iterator is
+// closed, with the number of rows read.
+func (l *slowQueryLog) middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			if op.iterator && err == nil {
+				op.start = start
+				return nil
+			}
+			l.observe(op, start, 0, err)
+			return err
+		}
+	}
+}
+
+func (l *slowQueryLog) observe(op *Operation, start time.Time, rows int64, err error) {
+	d := time.Since(start)
+	if d < l.threshold {
+		return
+	}
+	l.fn(SlowQuery{
+		Time:     start,
+		Duration: d,
+		Method:   op.Method,
+		DB:       op.DB,
+		DocID:    op.DocID,
+		Options:  sanitizeOptions(op.Options),
+		Iterator: op.iterator,
+		Rows:     rows,
+		Err:      err,
+	})
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		c := &Client{}
+		opts := c.applyOptions(mergeOptions(WithSlowQueryLog(time.Second, func(SlowQuery) {})))
+		if opts != nil {
+			t.Errorf("Expected no driver options, got %v", opts)
+		}
+		if c.slowQueries == nil || len(c.middleware) != 1 {
+			t.Errorf("Slow query log not installed")
+		}
+	})
+	t.Run("operations", func(t *testing.T) {
+		var slow []SlowQuery
+		client := &Client{
+			driverClient: &mock.Client{
+				CreateDBFunc: func(context.Context, string, map[string]interface{}) error {
+					return nil
+				},
+				DBExistsFunc: func(context.Context, string, map[string]interface{}) (bool, error) {
+					time.Sleep(20 * time.Millisecond)
+					return false, &Error{Status: http.StatusServiceUnavailable, Message: "down"}
+				},
+			},
+		}
+		client.applyOptions(WithSlowQueryLog(10*time.Millisecond, func(q SlowQuery) {
+			slow = append(slow, q)
+		}))
+		_ = client.CreateDB(context.Background(), "fast")
+		_, _ = client.DBExists(context.Background(), "slow", Params(map[string]interface{}{"password": "hunter2", "limit": 5}))
+		if len(slow) != 1 {
+			t.Fatalf("Expected 1 slow query, got %d", len(slow))
+		}
+		q := slow[0]
+		if q.Method != "DBExists" || q.DB != "slow" || q.Duration < 10*time.Millisecond || q.Iterator {
+			t.Errorf("Unexpected slow query: %+v", q)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"password": redacted, "limit": 5}, q.Options); d != nil {
+			t.Errorf("Unexpected options:\n%s", d)
+		}
+		testy.StatusError(t, "down", http.StatusServiceUnavailable, q.Err)
+	})
+	t.Run("iterators", func(t *testing.T) {
+		var slow []SlowQuery
+		remaining := 3
+		db := &DB{
+			client: &Client{},
+			name:   "foo",
+			driverDB: &mock.DB{
+				AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+					return &mock.Rows{
+						NextFunc: func(*driver.Row) error {
+							if remaining == 0 {
+								return io.EOF
+							}
+							remaining--
+							time.Sleep(5 * time.Millisecond)
+							return nil
+						},
+					}, nil
+				},
+				ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+					return &mock.Changes{
+						NextFunc: func(*driver.Change) error {
+							return errors.New("feed failure")
+						},
+					}, nil
+				},
+			},
+		}
+		db.client.applyOptions(WithSlowQueryLog(10*time.Millisecond, func(q SlowQuery) {
+			slow = append(slow, q)
+		}))
+
+		rows := db.AllDocs(context.Background(), IncludeDocs())
+		if len(slow) != 0 {
+			t.Errorf("Iterator reported before it was closed")
+		}
+		for rows.Next() { //nolint:revive // intentional empty block
+		}
+		changes := db.Changes(context.Background())
+		for changes.Next() { //nolint:revive // intentional empty block
+		}
+		_ = changes.Close()
+
+		if len(slow) != 1 {
+			t.Fatalf("Expected 1 slow query, got %d", len(slow))
+		}
+		q := slow[0]
+		if q.Method != "AllDocs" || q.DB != "foo" || !q.Iterator || q.Rows != 3 || q.Err != nil {
+			t.Errorf("Unexpected slow query: %+v", q)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"include_docs": true}, q.Options); d != nil {
+			t.Errorf("Unexpected options:\n%s", d)
+		}
+	})
+	t.Run("default logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(&buf)
+		c := &Client{driverClient: &mock.Client{
+			AllDBsFunc: func(context.Context, map[string]interface{}) ([]string, error) {
+				return nil, nil
+			},
+		}}
+		c.applyOptions(WithSlowQueryLog(0, nil))
+		_, _ = c.AllDBs(context.Background())
+		if !strings.Contains(buf.String(), "kivik: slow query: AllDBs duration=") {
+			t.Errorf("Unexpected log output: %s", buf.String())
+		}
+	})
+}
+
+func TestSlowQueryString(t *testing.T) {
+	q := SlowQuery{
+		Method:   "Query",
+		DB:       "animals",
+		Duration: 1500 * time.Millisecond,
+		Options:  map[string]interface{}{"limit": 10},
+		Iterator: true,
+		Rows:     42,
+		Err:      errors.New("timeout"),
+	}
+	want := `kivik: slow query: Query db=animals duration=1.5s rows=42 options={"limit":10} error="timeout"`
+	if got := q.String(); got != want {
+		t.Errorf("Unexpected string:\n got: %s\nwant: %s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// SQLColumn describes a column of [SQLRows].
+type SQLColumn struct {
+	// Name is the column name. If empty, Field is used.
+	Name string
+	// Field is the path of the value within each row's JSON object, as for
+	// [CSVColumn].
+	Field string
+}
+
+// defaultSQLColumns are the columns of an [SQLRows] created without columns.
+var defaultSQLColumns = []SQLColumn{{Field: "id"}, {Field: "key"}, {Field: "value"}}
+
+// SQLRows adapts a [ResultSet] to the Columns, Next, Scan, Err and Close
+// methods of [database/sql.Rows], for code written to consume SQL query
+// results. Each row is the row's JSON object, as written by [ExportNDJSON],
+// and each column is the value of a field within it.
+//
+// Values are passed to Scan as the types used by [database/sql/driver]:
+// strings as string, integers as int64, other numbers as float64, booleans as
+// bool, objects and arrays as JSON-encoded []byte, and missing fields and
+// null as nil. Scan converts them to the destination's type, much as
+// [database/sql.Rows.Scan] does, and supports destinations implementing
+// [database/sql.Scanner], such as [database/sql.NullString], as well as
+// [encoding/json.RawMessage], which receives the value as JSON.
+type SQLRows struct {
+	rs      ResultSet
+	columns []SQLColumn
+	values  []interface{}
+	err     error
+}
+
+// NewSQLRows returns an adapter reading the rows of rs, with the given
+// columns. If no columns are given, the columns are the id, key and value of
+// each row, as returned by views.
+func NewSQLRows(rs ResultSet, columns ...SQLColumn) *SQLRows {
+	if len(columns) == 0 {
+		columns = defaultSQLColumns
+	}
+	return &SQLRows{rs: rs, columns: columns}
+}
+
+// Columns returns the column names.
+func (r *SQLRows) Columns() ([]string, error) {
+	if err := r.rs.Err(); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		names[i] = col.Name
+		if names[i] == "" {
+			names[i] = col.Field
+		}
+	}
+	return names, nil
+}
+
+// Next prepares the next row for reading with Scan. It returns false when
+// there are no more rows, or an error occurs, which is then returned by Err.
+func (r *SQLRows) Next() bool {
+	r.values = nil
+	if r.err != nil || !r.rs.Next() {
+		return false
+	}
+	obj, err := exportObject(r.rs)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	var v interface{}
+	if err := unmarshalJSON(obj, &v, true); err != nil {
+		r.err = err
+		return false
+	}
+	r.values = make([]interface{}, len(r.columns))
+	for i, col := range r.columns {
+		if r.values[i], err = sqlValue(lookupField(v, col.Field)); err != nil {
+			r.err = err
+			return false
+		}
+	}
+	return true
+}
+
+// sqlValue converts a value decoded from JSON to a database/sql/driver value.
+func sqlValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil, string, bool:
+		return t, nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	}
+	return json.Marshal(v)
+}
+
+// Scan copies the columns of the current row into dest, which must have one
+// pointer for each column.
+func (r *SQLRows) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.values == nil {
+		return errors.New("kivik: Scan called without calling Next")
+	}
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("kivik: expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+	names, _ := r.Columns()
+	for i, value := range r.values {
+		if err := convertAssign(dest[i], value); err != nil {
+			return fmt.Errorf("kivik: Scan error on column index %d, name %q: %w", i, names[i], err)
+		}
+	}
+	return nil
+}
+
+// Err returns the error, if any, encountered while iterating.
+func (r *SQLRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rs.Err()
+}
+
+// Close closes the underlying result set.
+func (r *SQLRows) Close() error {
+	r.values = nil
+	return r.rs.Close()
+}
+
+// convertAssign stores src, a database/sql/driver value, in dest.
+func convertAssign(dest, src interface{}) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(src)
+	case *interface{}:
+		*d = src
+		return nil
+	case *json.RawMessage:
+		if b, ok := src.([]byte); ok {
+			*d = append((*d)[:0], b...)
+			return nil
+		}
+		raw, err := json.Marshal(src)
+		*d = raw
+		return err
+	case *[]byte:
+		switch s := src.(type) {
+		case nil:
+			*d = nil
+		case []byte:
+			*d = append([]byte(nil), s...)
+		default:
+			*d = []byte(asString(s))
+		}
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.New("destination not a pointer")
+	}
+	dv = dv.Elem()
+	if dv.Kind() == reflect.Ptr {
+		if src == nil {
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		dv.Set(reflect.New(dv.Type().Elem()))
+		return convertAssign(dv.Interface(), src)
+	}
+	if src == nil {
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Kind())
+	}
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(asString(src))
+		return nil
+	case reflect.Bool:
+		switch s := src.(type) {
+		case bool:
+			dv.SetBool(s)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("converting %q to bool: %w", s, err)
+			}
+			dv.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(asString(src), 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %v to %s: %w", src, dv.Kind(), err)
+		}
+		dv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(asString(src), 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %v to %s: %w", src, dv.Kind(), err)
+		}
+		dv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(asString(src), dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %v to %s: %w", src, dv.Kind(), err)
+		}
+		dv.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported Scan, storing %T into type %T", src, dest)
+}
+
+// asString formats a database/sql/driver value as a string.
+func asString(src interface{}) string {
+	switch s := src.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case int64:
+		return strconv.FormatInt(s, 10)
+	case float64:
+		return strconv.FormatFloat(s, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	return fmt.Sprint(src)
+}
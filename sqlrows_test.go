@@ -0,0 +1,125 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func TestSQLRows(t *testing.T) {
+	t.Run("docs", func(t *testing.T) {
+		rows := NewSQLRows(exportRows(testExportRows()...),
+			SQLColumn{Name: "id", Field: "_id"},
+			SQLColumn{Field: "name"},
+			SQLColumn{Field: "legs"},
+			SQLColumn{Field: "weight"},
+			SQLColumn{Name: "city", Field: "address.city"},
+			SQLColumn{Field: "tags"},
+		)
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"id", "name", "legs", "weight", "city", "tags"}, columns); d != nil {
+			t.Error(d)
+		}
+		type result struct {
+			ID     string
+			Name   string
+			Legs   int
+			Weight sql.NullFloat64
+			City   *string
+			Tags   json.RawMessage
+		}
+		var got []result
+		for rows.Next() {
+			var r result
+			if err := rows.Scan(&r.ID, &r.Name, &r.Legs, &r.Weight, &r.City, &r.Tags); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, r)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		city := "Farmville"
+		want := []result{
+			{ID: "cow", Name: "Bessie", Legs: 4, City: &city, Tags: json.RawMessage(`["moo","milk"]`)},
+			{ID: "pig", Name: `Wilbur, "some pig"`, Legs: 4, Weight: sql.NullFloat64{Float64: 120.5, Valid: true}, Tags: json.RawMessage(`null`)},
+		}
+		if d := testy.DiffInterface(want, got); d != nil {
+			t.Error(d)
+		}
+		if err := rows.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("default columns", func(t *testing.T) {
+		rows := NewSQLRows(exportRows(
+			driver.Row{ID: "cow", Key: []byte(`["cow",1]`), Value: strings.NewReader(`4`)},
+		))
+		columns, _ := rows.Columns()
+		if d := testy.DiffInterface([]string{"id", "key", "value"}, columns); d != nil {
+			t.Error(d)
+		}
+		if !rows.Next() {
+			t.Fatal("expected a row")
+		}
+		var id, key string
+		var value interface{}
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			t.Fatal(err)
+		}
+		if id != "cow" || key != `["cow",1]` || value != int64(4) {
+			t.Errorf("Unexpected row: %s, %s, %v", id, key, value)
+		}
+		if rows.Next() {
+			t.Error("expected no more rows")
+		}
+	})
+	t.Run("scan errors", func(t *testing.T) {
+		rows := NewSQLRows(exportRows(testExportRows()...), SQLColumn{Field: "name"}, SQLColumn{Field: "weight"})
+		var name string
+		var weight float64
+		if err := rows.Scan(&name, &weight); err == nil || err.Error() != "kivik: Scan called without calling Next" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		rows.Next()
+		if err := rows.Scan(&name); err == nil || err.Error() != "kivik: expected 2 destination arguments in Scan, not 1" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := rows.Scan(&weight, &weight); err == nil || err.Error() != `kivik: Scan error on column index 0, name "name": converting Bessie to float64: strconv.ParseFloat: parsing "Bessie": invalid syntax` {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		err := rows.Scan(&name, &weight)
+		testy.Error(t, `kivik: Scan error on column index 1, name "weight": converting NULL to float64 is unsupported`, err)
+	})
+	t.Run("result set error", func(t *testing.T) {
+		rows := NewSQLRows(&errRS{err: errors.New("query failed")})
+		if _, err := rows.Columns(); err == nil {
+			t.Error("expected an error from Columns")
+		}
+		if rows.Next() {
+			t.Error("expected no rows")
+		}
+		testy.Error(t, "query failed", rows.Err())
+	})
+}
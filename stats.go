@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// ClientStats are the statistics of a [Client], as returned by
+// [Client.Stats].
+type ClientStats struct {
+	// InFlight is the number of operations in progress, including iterators
+	// which have not yet been closed.
+	InFlight int
+	// Pool holds the statistics of the driver's connection pool, or is nil if
+	// the driver does not report them.
+	Pool *PoolStats
+}
+
+// PoolStats are the statistics of a driver's connection pool.
+type PoolStats struct {
+	// MaxOpenConnections is the maximum number of open connections, or 0 if
+	// unlimited.
+	MaxOpenConnections int
+	// OpenConnections is the number of established connections, both in use
+	// and idle.
+	OpenConnections int
+	// InUse is the number of connections currently in use.
+	InUse int
+	// Idle is the number of idle connections.
+	Idle int
+	// WaitCount is the total number of requests which waited for a
+	// connection.
+	WaitCount int64
+	// WaitDuration is the total time spent waiting for a connection.
+	WaitDuration time.Duration
+}
+
+// Stats returns the current statistics of the client. Connection pool
+// statistics are included if the driver reports them. Stats makes no
+// requests to the server, and may be called on a closed client.
+func (c *Client) Stats() ClientStats {
+	stats := ClientStats{
+		InFlight: int(atomic.LoadInt64(&c.inFlight)),
+	}
+	if statser, ok := optional(c.driverClient, (*driver.PoolStatser)(nil)).(driver.PoolStatser); ok {
+		if pool := statser.PoolStats(); pool != nil {
+			stats.Pool = &PoolStats{
+				MaxOpenConnections: pool.MaxOpenConnections,
+				OpenConnections:    pool.OpenConnections,
+				InUse:              pool.InUse,
+				Idle:               pool.Idle,
+				WaitCount:          pool.WaitCount,
+				WaitDuration:       pool.WaitDuration,
+			}
+		}
+	}
+	return stats
+}
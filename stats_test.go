@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestClientStats(t *testing.T) {
+	t.Run("no pool stats", func(t *testing.T) {
+		c := &Client{driverClient: &mock.Client{}}
+		if d := testy.DiffInterface(ClientStats{}, c.Stats()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("pool stats", func(t *testing.T) {
+		c := &Client{driverClient: &mock.PoolStatser{
+			PoolStatsFunc: func() *driver.PoolStats {
+				return &driver.PoolStats{
+					MaxOpenConnections: 10,
+					OpenConnections:    4,
+					InUse:              3,
+					Idle:               1,
+					WaitCount:          2,
+					WaitDuration:       time.Second,
+				}
+			},
+		}}
+		want := ClientStats{Pool: &PoolStats{
+			MaxOpenConnections: 10,
+			OpenConnections:    4,
+			InUse:              3,
+			Idle:               1,
+			WaitCount:          2,
+			WaitDuration:       time.Second,
+		}}
+		if d := testy.DiffInterface(want, c.Stats()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("in flight", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		c := &Client{driverClient: &mock.Client{
+			AllDBsFunc: func(context.Context, map[string]interface{}) ([]string, error) {
+				close(started)
+				<-release
+				return nil, nil
+			},
+		}}
+		done := make(chan struct{})
+		go func() {
+			_, _ = c.AllDBs(context.Background())
+			close(done)
+		}()
+		<-started
+		if n := c.Stats().InFlight; n != 1 {
+			t.Errorf("Expected 1 operation in flight, got %d", n)
+		}
+		close(release)
+		<-done
+		if n := c.Stats().InFlight; n != 0 {
+			t.Errorf("Expected no operations in flight, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"time"
+)
+
+// optionTimeouts is the option key used to pass [Timeouts] to [New].
+const optionTimeouts = "kivik:timeouts"
+
+// Timeouts configures the default timeouts applied by a [Client] to
+// operations whose context has no deadline, so that a request made without
+// [context.WithTimeout] cannot block forever. A zero duration means no
+// timeout. A deadline set on the caller's context always takes precedence.
+//
+// For operations which return an iterator, such as [DB.AllDocs] and
+// [DB.Changes], the timeout covers the iteration as well as the initial
+// request; once it expires, the iterator is closed with
+// [context.DeadlineExceeded].
+type Timeouts struct {
+	// Read applies to read-only operations which return a single result,
+	// such as [DB.Get] and [Client.AllDBs].
+	Read time.Duration
+	// Write applies to operations which may modify data, such as [DB.Put],
+	// [DB.BulkDocs] and [DB.Compact].
+	Write time.Duration
+	// Query applies to operations which return an iterator, such as
+	// [DB.AllDocs], [DB.Query], [DB.Find], and normal changes feeds.
+	Query time.Duration
+	// Feed applies to [Client.DBUpdates], and to continuous, longpoll and
+	// eventsource feeds returned by [DB.Changes]. It is usually left at zero,
+	// as such feeds are expected to stay open.
+	Feed time.Duration
+	// Methods overrides the above for individual operations, by the Method
+	// of the [Operation], such as "Query" or "BulkDocs". A zero duration
+	// disables the timeout for that method.
+	Methods map[string]time.Duration
+}
+
+// WithTimeouts returns an option which, when passed to [New], sets the
+// default timeouts applied to each operation.
+func WithTimeouts(t Timeouts) Options {
+	return Options{optionTimeouts: t}
+}
+
+// timeout returns the default timeout for op.
+func (t *Timeouts) timeout(op *Operation) time.Duration {
+	if d, ok := t.Methods[op.Method]; ok {
+		return d
+	}
+	switch {
+	case op.feed:
+		return t.Feed
+	case op.iterator:
+		switch feed, _ := op.Options["feed"].(string); feed {
+		case "continuous", "longpoll", "eventsource":
+			return t.Feed
+		}
+		return t.Query
+	case op.ReadOnly:
+		return t.Read
+	}
+	return t.Write
+}
+
+// withTimeout returns ctx with the default timeout for op applied, if ctx has
+// no deadline of its own. The returned cancel function is nil if no timeout
+// was applied.
+func (c *Client) withTimeout(ctx context.Context, op *Operation) (context.Context, context.CancelFunc) {
+	if c.timeouts == nil {
+		return ctx, nil
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, nil
+	}
+	d := c.timeouts.timeout(op)
+	if d <= 0 {
+		return ctx, nil
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,197 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestTimeoutsTimeout(t *testing.T) {
+	timeouts := &Timeouts{
+		Read:    1 * time.Second,
+		Write:   2 * time.Second,
+		Query:   3 * time.Second,
+		Methods: map[string]time.Duration{"Find": 4 * time.Second, "Compact": 0},
+	}
+	tests := []struct {
+		name string
+		op   *Operation
+		want time.Duration
+	}{
+		{
+			name: "read",
+			op:   &Operation{Method: "Get", ReadOnly: true},
+			want: 1 * time.Second,
+		},
+		{
+			name: "write",
+			op:   &Operation{Method: "Put"},
+			want: 2 * time.Second,
+		},
+		{
+			name: "query",
+			op:   &Operation{Method: "Query", ReadOnly: true, iterator: true},
+			want: 3 * time.Second,
+		},
+		{
+			name: "normal feed",
+			op:   &Operation{Method: "Changes", ReadOnly: true, iterator: true, Options: Options{"feed": "normal"}},
+			want: 3 * time.Second,
+		},
+		{
+			name: "continuous feed",
+			op:   &Operation{Method: "Changes", ReadOnly: true, iterator: true, Options: Options{"feed": "continuous"}},
+			want: 0,
+		},
+		{
+			name: "db updates",
+			op:   &Operation{Method: "DBUpdates", ReadOnly: true, iterator: true, feed: true},
+			want: 0,
+		},
+		{
+			name: "method override",
+			op:   &Operation{Method: "Find", ReadOnly: true, iterator: true},
+			want: 4 * time.Second,
+		},
+		{
+			name: "method disabled",
+			op:   &Operation{Method: "Compact"},
+			want: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := timeouts.timeout(test.op); got != test.want {
+				t.Errorf("Unexpected timeout: %v", got)
+			}
+		})
+	}
+}
+
+func TestWithTimeouts(t *testing.T) {
+	newDB := func(t *testing.T, d driver.DB) *DB {
+		t.Helper()
+		client, err := NewClientFromDriverClient(&mock.Client{
+			DBFunc: func(string, map[string]interface{}) (driver.DB, error) {
+				return d, nil
+			},
+		}, WithTimeouts(Timeouts{Read: time.Minute, Query: 50 * time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.DB("foo")
+	}
+
+	t.Run("single result", func(t *testing.T) {
+		var driverCtx context.Context
+		db := newDB(t, &mock.DB{
+			GetFunc: func(ctx context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+				driverCtx = ctx
+				deadline, ok := ctx.Deadline()
+				if !ok || time.Until(deadline) > time.Minute {
+					return nil, errors.New("unexpected deadline")
+				}
+				return &driver.Document{Body: body(`{}`)}, nil
+			},
+		})
+		if err := db.Get(context.Background(), "foo").Err(); err != nil {
+			t.Fatal(err)
+		}
+		if driverCtx.Err() != context.Canceled {
+			t.Errorf("Timeout not released: %v", driverCtx.Err())
+		}
+	})
+	t.Run("caller deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		want, _ := ctx.Deadline()
+		db := newDB(t, &mock.DB{
+			GetFunc: func(ctx context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+				if got, _ := ctx.Deadline(); !got.Equal(want) {
+					return nil, errors.New("caller's deadline overridden")
+				}
+				return &driver.Document{Body: body(`{}`)}, nil
+			},
+		})
+		if err := db.Get(ctx, "foo").Err(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("iterator", func(t *testing.T) {
+		var driverCtx context.Context
+		db := newDB(t, &mock.DB{
+			AllDocsFunc: func(ctx context.Context, _ map[string]interface{}) (driver.Rows, error) {
+				driverCtx = ctx
+				return &mock.Rows{
+					NextFunc: func(row *driver.Row) error {
+						row.ID = "foo"
+						return nil
+					},
+				}, nil
+			},
+		})
+		rows := db.AllDocs(context.Background())
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		if err := driverCtx.Err(); err != nil {
+			t.Fatalf("Timeout released before iteration: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if rows.Next() {
+			t.Error("Expected the iterator to be closed")
+		}
+		if err := rows.Err(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("db updates", func(t *testing.T) {
+		client, err := NewClientFromDriverClient(&mock.DBUpdater{
+			DBUpdatesFunc: func(ctx context.Context, _ map[string]interface{}) (driver.DBUpdates, error) {
+				if _, ok := ctx.Deadline(); ok {
+					return nil, errors.New("query timeout applied to the feed")
+				}
+				return &mock.DBUpdates{}, nil
+			},
+		}, WithTimeouts(Timeouts{Query: 50 * time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates := client.DBUpdates(context.Background())
+		if err := updates.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = updates.Close()
+	})
+	t.Run("iterator closed", func(t *testing.T) {
+		var driverCtx context.Context
+		db := newDB(t, &mock.DB{
+			AllDocsFunc: func(ctx context.Context, _ map[string]interface{}) (driver.Rows, error) {
+				driverCtx = ctx
+				return &mock.Rows{}, nil
+			},
+		})
+		if err := db.AllDocs(context.Background()).Close(); err != nil {
+			t.Fatal(err)
+		}
+		if driverCtx.Err() != context.Canceled {
+			t.Errorf("Timeout not released: %v", driverCtx.Err())
+		}
+	})
+}
@@ -0,0 +1,486 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types in this file provide typed alternatives to [Options] for the most
+// common operations, so that a misspelled option is a compile-time error,
+// rather than being silently ignored by the server. Each type's Options
+// method returns the equivalent Options, including only the fields which have
+// been set, to be passed to the corresponding method:
+//
+//	row := db.Get(ctx, "foo", kivik.GetOptions{Rev: "1-xxx", Conflicts: true}.Options())
+
+// GetOptions are the options accepted by [DB.Get].
+type GetOptions struct {
+	// Rev fetches a specific revision, rather than the current one.
+	Rev string
+	// Revs includes the revision history in the _revisions field.
+	Revs bool
+	// RevsInfo includes the status of past revisions in the _revs_info field.
+	RevsInfo bool
+	// Conflicts includes conflicting revisions in the _conflicts field.
+	Conflicts bool
+	// Attachments includes attachment content, rather than stubs.
+	Attachments bool
+	// Latest fetches the latest leaf revision descended from Rev or OpenRevs.
+	Latest bool
+	// OpenRevs fetches the listed leaf revisions. The single value "all"
+	// fetches all leaves.
+	OpenRevs []string
+	// R is the read quorum; see [ReadQuorum].
+	R int
+}
+
+// Options returns o as Options.
+func (o GetOptions) Options() Options {
+	opts := Options{}
+	setString(opts, "rev", o.Rev)
+	setBool(opts, "revs", o.Revs)
+	setBool(opts, "revs_info", o.RevsInfo)
+	setBool(opts, "conflicts", o.Conflicts)
+	setBool(opts, "attachments", o.Attachments)
+	setBool(opts, "latest", o.Latest)
+	if len(o.OpenRevs) > 0 {
+		opts["open_revs"] = o.OpenRevs
+	}
+	setInt(opts, "r", o.R)
+	return opts
+}
+
+// Validate returns a 400 error if R is negative. [DB.Get] performs the same
+// check, and also that R does not exceed the number of replicas.
+func (o GetOptions) Validate() error {
+	return validateOptions(o.Options(), "r")
+}
+
+// PutOptions are the options accepted by [DB.Put] and [DB.CreateDoc].
+type PutOptions struct {
+	// Batch stores the document in batch mode, so that it is written to disk
+	// at a later time. The write may be lost in the event of a crash.
+	Batch bool
+	// NewEdits, if set to false, stores the document with the revision given
+	// in its _rev field, as done by replication, instead of generating a new
+	// revision.
+	NewEdits *bool
+	// W is the write quorum; see [WriteQuorum].
+	W int
+}
+
+// Options returns o as Options.
+func (o PutOptions) Options() Options {
+	opts := Options{}
+	if o.Batch {
+		opts["batch"] = "ok"
+	}
+	if o.NewEdits != nil {
+		opts["new_edits"] = *o.NewEdits
+	}
+	setInt(opts, "w", o.W)
+	return opts
+}
+
+// QueryOptions are the options accepted by [DB.Query], [DB.AllDocs],
+// [DB.DesignDocs] and [DB.LocalDocs]. Keys are encoded to JSON by the driver.
+type QueryOptions struct {
+	// Key returns only rows matching this key.
+	Key interface{}
+	// Keys returns only rows matching one of these keys.
+	Keys []interface{}
+	// StartKey returns rows starting with this key.
+	StartKey interface{}
+	// EndKey stops returning rows when this key is reached.
+	EndKey interface{}
+	// StartKeyDocID and EndKeyDocID break ties between rows with the same
+	// StartKey or EndKey, respectively.
+	StartKeyDocID string
+	EndKeyDocID   string
+	// InclusiveEnd, if set to false, excludes rows matching EndKey.
+	InclusiveEnd *bool
+	// Limit is the maximum number of rows returned. Zero means no limit.
+	Limit int
+	// Skip is the number of rows to skip.
+	Skip int
+	// Descending returns rows in reverse order.
+	Descending bool
+	// IncludeDocs includes the document with each row.
+	IncludeDocs bool
+	// Conflicts includes conflict information in included documents.
+	Conflicts bool
+	// Attachments includes attachment content in included documents.
+	Attachments bool
+	// Reduce, if set, controls whether the view's reduce function is used.
+	Reduce *bool
+	// Group groups reduced results by key.
+	Group bool
+	// GroupLevel groups reduced results by the first GroupLevel elements of
+	// array keys.
+	GroupLevel int
+	// Update controls whether the view is updated before returning results:
+	// "true", "false" or "lazy".
+	Update string
+	// Stable returns results from a stable set of shards.
+	Stable bool
+	// UpdateSeq includes the view's update sequence in the results.
+	UpdateSeq bool
+}
+
+// Options returns o as Options.
+func (o QueryOptions) Options() Options {
+	opts := Options{}
+	setValue(opts, "key", o.Key)
+	if o.Keys != nil {
+		opts["keys"] = o.Keys
+	}
+	setValue(opts, "startkey", o.StartKey)
+	setValue(opts, "endkey", o.EndKey)
+	setString(opts, "startkey_docid", o.StartKeyDocID)
+	setString(opts, "endkey_docid", o.EndKeyDocID)
+	if o.InclusiveEnd != nil {
+		opts["inclusive_end"] = *o.InclusiveEnd
+	}
+	setInt(opts, "limit", o.Limit)
+	setInt(opts, "skip", o.Skip)
+	setBool(opts, "descending", o.Descending)
+	setBool(opts, "include_docs", o.IncludeDocs)
+	setBool(opts, "conflicts", o.Conflicts)
+	setBool(opts, "attachments", o.Attachments)
+	if o.Reduce != nil {
+		opts["reduce"] = *o.Reduce
+	}
+	setBool(opts, "group", o.Group)
+	setInt(opts, "group_level", o.GroupLevel)
+	setString(opts, "update", o.Update)
+	setBool(opts, "stable", o.Stable)
+	setBool(opts, "update_seq", o.UpdateSeq)
+	return opts
+}
+
+// Validate returns a 400 error if Limit, Skip or GroupLevel is negative, or
+// Update is not one of the accepted values. [DB.Query], [DB.AllDocs],
+// [DB.DesignDocs] and [DB.LocalDocs] perform the same checks.
+func (o QueryOptions) Validate() error {
+	return validateQueryOptions(o.Options())
+}
+
+// ChangesOptions are the options accepted by [DB.Changes].
+type ChangesOptions struct {
+	// Since returns only changes after this sequence. "now" starts from the
+	// current sequence.
+	Since string
+	// Limit is the maximum number of changes returned. Zero means no limit.
+	Limit int
+	// Descending returns changes in reverse order.
+	Descending bool
+	// IncludeDocs includes the document with each change.
+	IncludeDocs bool
+	// Conflicts includes conflict information in included documents.
+	Conflicts bool
+	// Attachments includes attachment content in included documents.
+	Attachments bool
+	// Feed is the type of feed: "normal", "longpoll", "continuous" or
+	// "eventsource".
+	Feed string
+	// Heartbeat is the interval at which the server sends empty lines to
+	// keep the connection alive. It is sent in milliseconds.
+	Heartbeat time.Duration
+	// Timeout is how long the server waits for changes before closing the
+	// connection. It is sent in milliseconds.
+	Timeout time.Duration
+	// Filter is the name of the filter function, or one of the built-in
+	// filters "_doc_ids", "_selector", "_design" or "_view". It defaults to
+	// "_doc_ids" if DocIDs is set, or "_selector" if Selector is set.
+	Filter string
+	// DocIDs are the document IDs used by the _doc_ids filter.
+	DocIDs []string
+	// Selector is the Mango selector used by the _selector filter.
+	Selector interface{}
+	// View is the view used by the _view filter.
+	View string
+	// Style is "main_only" or "all_docs".
+	Style string
+	// SeqInterval causes the sequence to be calculated only every
+	// SeqInterval changes.
+	SeqInterval int
+}
+
+// Options returns o as Options.
+func (o ChangesOptions) Options() Options {
+	opts := Options{}
+	setString(opts, "since", o.Since)
+	setInt(opts, "limit", o.Limit)
+	setBool(opts, "descending", o.Descending)
+	setBool(opts, "include_docs", o.IncludeDocs)
+	setBool(opts, "conflicts", o.Conflicts)
+	setBool(opts, "attachments", o.Attachments)
+	setString(opts, "feed", o.Feed)
+	setInt(opts, "heartbeat", int(o.Heartbeat/time.Millisecond))
+	setInt(opts, "timeout", int(o.Timeout/time.Millisecond))
+	setString(opts, "filter", o.Filter)
+	if len(o.DocIDs) > 0 {
+		opts["doc_ids"] = o.DocIDs
+	}
+	setValue(opts, "selector", o.Selector)
+	setString(opts, "view", o.View)
+	setString(opts, "style", o.Style)
+	setInt(opts, "seq_interval", o.SeqInterval)
+	return opts
+}
+
+// Validate returns a 400 error if Limit, Heartbeat, Timeout or SeqInterval
+// is negative, or Feed or Style is not one of the accepted values.
+// [DB.Changes] performs the same checks.
+func (o ChangesOptions) Validate() error {
+	return validateChangesOptions(o.Options())
+}
+
+// DBUpdatesOptions are the options accepted by [Client.DBUpdates].
+type DBUpdatesOptions struct {
+	// Since returns only updates after this sequence. "now" starts from the
+	// current sequence.
+	Since string
+	// Feed is the type of feed: "normal", "longpoll", "continuous" or
+	// "eventsource".
+	Feed string
+	// Heartbeat is the interval at which the server sends empty lines to
+	// keep the connection alive. It is sent in milliseconds.
+	Heartbeat time.Duration
+	// Timeout is how long the server waits for updates before closing the
+	// connection. It is sent in milliseconds.
+	Timeout time.Duration
+}
+
+// Options returns o as Options.
+func (o DBUpdatesOptions) Options() Options {
+	opts := Options{}
+	setString(opts, "since", o.Since)
+	setString(opts, "feed", o.Feed)
+	setInt(opts, "heartbeat", int(o.Heartbeat/time.Millisecond))
+	setInt(opts, "timeout", int(o.Timeout/time.Millisecond))
+	return opts
+}
+
+// Validate returns a 400 error if Heartbeat or Timeout is negative, or Feed
+// is not one of the accepted values. [Client.DBUpdates] performs the same
+// checks.
+func (o DBUpdatesOptions) Validate() error {
+	return validateDBUpdatesOptions(o.Options())
+}
+
+// CreateDBOptions are the options accepted by [Client.CreateDB].
+type CreateDBOptions struct {
+	// Q is the number of shards.
+	Q int
+	// N is the number of replicas of each shard.
+	N int
+	// Partitioned creates a partitioned database.
+	Partitioned bool
+}
+
+// Options returns o as Options.
+func (o CreateDBOptions) Options() Options {
+	opts := Options{}
+	setInt(opts, "q", o.Q)
+	setInt(opts, "n", o.N)
+	setBool(opts, "partitioned", o.Partitioned)
+	return opts
+}
+
+// normalizeCreateDBOptions validates the q, n and partitioned options of
+// [Client.CreateDB], if present, and converts them to an int and a bool
+// respectively, so that drivers need not handle the alternative forms
+// accepted from callers.
+func normalizeCreateDBOptions(opts Options) error {
+	for _, key := range []string{"q", "n"} {
+		v, ok := opts[key]
+		if !ok {
+			continue
+		}
+		n, ok := toInt(v)
+		if !ok || n < 1 {
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid %s option %v: must be a positive integer", key, v)}
+		}
+		opts[key] = n
+	}
+	if v, ok := opts["partitioned"]; ok {
+		var partitioned bool
+		switch t := v.(type) {
+		case bool:
+			partitioned = t
+		case string:
+			var err error
+			if partitioned, err = strconv.ParseBool(t); err != nil {
+				return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid partitioned option %q", t)}
+			}
+		default:
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid partitioned option %v", v)}
+		}
+		opts["partitioned"] = partitioned
+	}
+	return nil
+}
+
+// normalizeChangesOptions sets the filter option implied by the doc_ids and
+// selector options, which require the request body supported by the built-in
+// _doc_ids and _selector filters, and checks that those filters are given
+// their arguments.
+func normalizeChangesOptions(opts Options) error {
+	filter, _ := opts["filter"].(string)
+	_, hasDocIDs := opts["doc_ids"]
+	_, hasSelector := opts["selector"]
+	if filter == "" {
+		switch {
+		case hasDocIDs && hasSelector:
+			return &Error{Status: http.StatusBadRequest, Message: "kivik: doc_ids and selector options are mutually exclusive"}
+		case hasDocIDs:
+			filter = "_doc_ids"
+		case hasSelector:
+			filter = "_selector"
+		default:
+			return nil
+		}
+		opts["filter"] = filter
+	}
+	switch filter {
+	case "_doc_ids":
+		if !hasDocIDs {
+			return &Error{Status: http.StatusBadRequest, Message: "kivik: _doc_ids filter requires the doc_ids option"}
+		}
+	case "_selector":
+		if !hasSelector {
+			return &Error{Status: http.StatusBadRequest, Message: "kivik: _selector filter requires the selector option"}
+		}
+	}
+	return nil
+}
+
+var (
+	feedValues   = []string{"normal", "longpoll", "continuous", "eventsource"}
+	styleValues  = []string{"main_only", "all_docs"}
+	updateValues = []string{"true", "false", "lazy"}
+)
+
+// validateQueryOptions checks the options of the view and _all_docs queries.
+func validateQueryOptions(opts Options) error {
+	if err := validateOptions(opts, "limit", "skip", "group_level"); err != nil {
+		return err
+	}
+	if _, ok := opts["update"].(bool); ok {
+		return nil
+	}
+	return validateEnumOption(opts, "update", updateValues)
+}
+
+// validateChangesOptions checks the options of [DB.Changes].
+func validateChangesOptions(opts Options) error {
+	if err := validateOptions(opts, "limit", "heartbeat", "timeout", "seq_interval"); err != nil {
+		return err
+	}
+	if err := validateEnumOption(opts, "feed", feedValues); err != nil {
+		return err
+	}
+	return validateEnumOption(opts, "style", styleValues)
+}
+
+// validateDBUpdatesOptions checks the options of [Client.DBUpdates].
+func validateDBUpdatesOptions(opts Options) error {
+	if err := validateOptions(opts, "heartbeat", "timeout"); err != nil {
+		return err
+	}
+	return validateEnumOption(opts, "feed", feedValues)
+}
+
+// validateOptions checks that each of keys present in opts is a
+// non-negative integer.
+func validateOptions(opts Options, keys ...string) error {
+	for _, key := range keys {
+		v, ok := opts[key]
+		if !ok {
+			continue
+		}
+		if n, ok := toInt(v); !ok || n < 0 {
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid %s option %v: must be a non-negative integer", key, v)}
+		}
+	}
+	return nil
+}
+
+// validateEnumOption checks that the key option, if present, is one of
+// values.
+func validateEnumOption(opts Options, key string, values []string) error {
+	v, ok := opts[key]
+	if !ok {
+		return nil
+	}
+	if s, ok := v.(string); ok {
+		for _, value := range values {
+			if s == value {
+				return nil
+			}
+		}
+	}
+	return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid %s option %v: must be one of %s", key, v, strings.Join(values, ", "))}
+}
+
+// toInt converts v, which may be any integer type, a whole float or a
+// numeric string, to an int.
+func toInt(v interface{}) (int, bool) {
+	switch t := v.(type) {
+	case int:
+		return t, true
+	case int32:
+		return int(t), true
+	case int64:
+		return int(t), true
+	case float64:
+		if t != float64(int(t)) {
+			return 0, false
+		}
+		return int(t), true
+	case string:
+		n, err := strconv.Atoi(t)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func setString(opts Options, key, value string) {
+	if value != "" {
+		opts[key] = value
+	}
+}
+
+func setBool(opts Options, key string, value bool) {
+	if value {
+		opts[key] = true
+	}
+}
+
+func setInt(opts Options, key string, value int) {
+	if value != 0 {
+		opts[key] = value
+	}
+}
+
+func setValue(opts Options, key string, value interface{}) {
+	if value != nil {
+		opts[key] = value
+	}
+}
@@ -0,0 +1,267 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestTypedOptions(t *testing.T) {
+	no := false
+	tests := []struct {
+		name string
+		opts interface{ Options() Options }
+		want Options
+	}{
+		{
+			name: "empty get",
+			opts: GetOptions{},
+			want: Options{},
+		},
+		{
+			name: "get",
+			opts: GetOptions{Rev: "1-xxx", Revs: true, Conflicts: true, OpenRevs: []string{"all"}, R: 2},
+			want: Options{"rev": "1-xxx", "revs": true, "conflicts": true, "open_revs": []string{"all"}, "r": 2},
+		},
+		{
+			name: "put",
+			opts: PutOptions{Batch: true, NewEdits: &no, W: 3},
+			want: Options{"batch": "ok", "new_edits": false, "w": 3},
+		},
+		{
+			name: "query",
+			opts: QueryOptions{
+				StartKey:     []interface{}{"a"},
+				EndKey:       []interface{}{"a", map[string]interface{}{}},
+				InclusiveEnd: &no,
+				Limit:        10,
+				IncludeDocs:  true,
+				Reduce:       &no,
+			},
+			want: Options{
+				"startkey":      []interface{}{"a"},
+				"endkey":        []interface{}{"a", map[string]interface{}{}},
+				"inclusive_end": false,
+				"limit":         10,
+				"include_docs":  true,
+				"reduce":        false,
+			},
+		},
+		{
+			name: "changes",
+			opts: ChangesOptions{
+				Since:     "now",
+				Feed:      "longpoll",
+				Heartbeat: 5 * time.Second,
+				Filter:    "_doc_ids",
+				DocIDs:    []string{"foo"},
+			},
+			want: Options{
+				"since":     "now",
+				"feed":      "longpoll",
+				"heartbeat": 5000,
+				"filter":    "_doc_ids",
+				"doc_ids":   []string{"foo"},
+			},
+		},
+		{
+			name: "create db",
+			opts: CreateDBOptions{Q: 8, Partitioned: true},
+			want: Options{"q": 8, "partitioned": true},
+		},
+		{
+			name: "changes selector",
+			opts: ChangesOptions{Selector: map[string]interface{}{"type": "cow"}},
+			want: Options{"selector": map[string]interface{}{"type": "cow"}},
+		},
+		{
+			name: "db updates",
+			opts: DBUpdatesOptions{Since: "now", Feed: "longpoll", Heartbeat: 10 * time.Second},
+			want: Options{"since": "now", "feed": "longpoll", "heartbeat": 10000},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if d := testy.DiffInterface(test.want, test.opts.Options()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestTypedOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts interface{ Validate() error }
+		err  string
+	}{
+		{
+			name: "empty query",
+			opts: QueryOptions{},
+		},
+		{
+			name: "valid query",
+			opts: QueryOptions{Limit: 10, Skip: 5, GroupLevel: 2, Update: "lazy"},
+		},
+		{
+			name: "negative limit",
+			opts: QueryOptions{Limit: -1},
+			err:  "kivik: invalid limit option -1: must be a non-negative integer",
+		},
+		{
+			name: "negative skip",
+			opts: QueryOptions{Skip: -5},
+			err:  "kivik: invalid skip option -5: must be a non-negative integer",
+		},
+		{
+			name: "negative group level",
+			opts: QueryOptions{GroupLevel: -1},
+			err:  "kivik: invalid group_level option -1: must be a non-negative integer",
+		},
+		{
+			name: "invalid update",
+			opts: QueryOptions{Update: "later"},
+			err:  "kivik: invalid update option later: must be one of true, false, lazy",
+		},
+		{
+			name: "negative read quorum",
+			opts: GetOptions{R: -1},
+			err:  "kivik: invalid r option -1: must be a non-negative integer",
+		},
+		{
+			name: "valid changes",
+			opts: ChangesOptions{Feed: "continuous", Style: "all_docs", Heartbeat: time.Second},
+		},
+		{
+			name: "negative changes limit",
+			opts: ChangesOptions{Limit: -1},
+			err:  "kivik: invalid limit option -1: must be a non-negative integer",
+		},
+		{
+			name: "negative seq interval",
+			opts: ChangesOptions{SeqInterval: -10},
+			err:  "kivik: invalid seq_interval option -10: must be a non-negative integer",
+		},
+		{
+			name: "invalid changes feed",
+			opts: ChangesOptions{Feed: "websocket"},
+			err:  "kivik: invalid feed option websocket: must be one of normal, longpoll, continuous, eventsource",
+		},
+		{
+			name: "invalid style",
+			opts: ChangesOptions{Style: "all"},
+			err:  "kivik: invalid style option all: must be one of main_only, all_docs",
+		},
+		{
+			name: "invalid db updates feed",
+			opts: DBUpdatesOptions{Feed: "poll"},
+			err:  "kivik: invalid feed option poll: must be one of normal, longpoll, continuous, eventsource",
+		},
+		{
+			name: "negative db updates timeout",
+			opts: DBUpdatesOptions{Timeout: -time.Second},
+			err:  "kivik: invalid timeout option -1000: must be a non-negative integer",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := 0
+			if test.err != "" {
+				status = http.StatusBadRequest
+			}
+			testy.StatusError(t, test.err, status, test.opts.Validate())
+		})
+	}
+}
+
+func TestValidateOptionsBeforeDriver(t *testing.T) {
+	called := false
+	rows := func(context.Context, map[string]interface{}) (driver.Rows, error) {
+		called = true
+		return &mock.Rows{}, nil
+	}
+	db := &DB{
+		client: &Client{},
+		driverDB: &mock.DesignDocer{
+			DB: &mock.DB{
+				AllDocsFunc: rows,
+				QueryFunc: func(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+					called = true
+					return &mock.Rows{}, nil
+				},
+				ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+					called = true
+					return &mock.Changes{}, nil
+				},
+			},
+			DesignDocsFunc: rows,
+		},
+	}
+	client := &Client{
+		driverClient: &mock.DBUpdater{
+			DBUpdatesFunc: func(context.Context, map[string]interface{}) (driver.DBUpdates, error) {
+				called = true
+				return &mock.DBUpdates{}, nil
+			},
+		},
+	}
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() error
+		err  string
+	}{
+		{
+			name: "all docs",
+			call: func() error { return db.AllDocs(ctx, Param("limit", -1)).Err() },
+			err:  "kivik: invalid limit option -1: must be a non-negative integer",
+		},
+		{
+			name: "design docs",
+			call: func() error { return db.DesignDocs(ctx, Param("skip", "-2")).Err() },
+			err:  "kivik: invalid skip option -2: must be a non-negative integer",
+		},
+		{
+			name: "query",
+			call: func() error { return db.Query(ctx, "foo", "bar", Param("update", "maybe")).Err() },
+			err:  "kivik: invalid update option maybe: must be one of true, false, lazy",
+		},
+		{
+			name: "changes",
+			call: func() error { return db.Changes(ctx, Param("style", "latest")).Err() },
+			err:  "kivik: invalid style option latest: must be one of main_only, all_docs",
+		},
+		{
+			name: "db updates",
+			call: func() error { return client.DBUpdates(ctx, Param("feed", "stream")).Err() },
+			err:  "kivik: invalid feed option stream: must be one of normal, longpoll, continuous, eventsource",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			called = false
+			testy.StatusError(t, test.err, http.StatusBadRequest, test.call())
+			if called {
+				t.Error("driver should not be called with invalid options")
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultUpdateAttempts is the number of times [DB.Update] and [DB.Upsert]
+// attempt to store a document, unless overridden with [WithUpdateAttempts].
+const DefaultUpdateAttempts = 10
+
+// optionUpdateAttempts is the option key used to pass the attempt limit to
+// [DB.Update] and [DB.Upsert].
+const optionUpdateAttempts = "kivik:updateAttempts"
+
+// WithUpdateAttempts returns an option which, when passed to [DB.Update] or
+// [DB.Upsert], limits the number of times the document is written before
+// giving up on conflicts.
+func WithUpdateAttempts(n int) Options {
+	return Options{optionUpdateAttempts: n}
+}
+
+// updateAttempts consumes the attempt limit from opts.
+func updateAttempts(opts Options) int {
+	n, ok := opts[optionUpdateAttempts].(int)
+	delete(opts, optionUpdateAttempts)
+	if !ok || n < 1 {
+		return DefaultUpdateAttempts
+	}
+	return n
+}
+
+// UpdateFunc is called by [DB.Update] with the current version of a
+// document, or nil if it does not exist. It returns the new version to be
+// stored, or nil to leave the document unchanged. It may be called several
+// times, so it should not have side effects.
+type UpdateFunc func(doc json.RawMessage) (interface{}, error)
+
+// Update performs an optimistic read-modify-write of the document docID. The
+// current version is fetched and passed to fn, and the result is stored with
+// the fetched revision. If the write fails with a conflict, because the
+// document was modified concurrently, the process is repeated, up to
+// [DefaultUpdateAttempts] times, or the number given with
+// [WithUpdateAttempts]. Other options are passed to [DB.Put].
+//
+// Any _id or _rev field in the document returned by fn is ignored. The new
+// revision is returned, or the current revision if fn returns nil.
+func (db *DB) Update(ctx context.Context, docID string, fn UpdateFunc, options ...Options) (rev string, err error) {
+	if docID == "" {
+		return "", missingArg("docID")
+	}
+	opts := mergeOptions(options...)
+	attempts := updateAttempts(opts)
+	for attempt := 1; ; attempt++ {
+		rev, err = db.update(ctx, docID, fn, opts)
+		if err == nil || HTTPStatus(err) != http.StatusConflict || attempt >= attempts {
+			return rev, err
+		}
+	}
+}
+
+func (db *DB) update(ctx context.Context, docID string, fn UpdateFunc, opts Options) (string, error) {
+	var current json.RawMessage
+	var rev string
+	row := db.Get(ctx, docID)
+	switch err := row.ScanDoc(&current); {
+	case HTTPStatus(err) == http.StatusNotFound:
+		current = nil
+	case err != nil:
+		return "", err
+	default:
+		var meta struct {
+			Rev string `json:"_rev"`
+		}
+		_ = json.Unmarshal(current, &meta)
+		rev = meta.Rev
+	}
+	updated, err := fn(current)
+	if err != nil {
+		return "", err
+	}
+	if updated == nil {
+		return rev, nil
+	}
+	doc, err := toDocMap(updated, db.useNumber)
+	if err != nil {
+		return "", err
+	}
+	doc["_id"] = docID
+	delete(doc, "_rev")
+	if rev != "" {
+		doc["_rev"] = rev
+	}
+	return db.Put(ctx, docID, doc, opts)
+}
+
+// toDocMap converts doc to a map, by way of JSON. If useNumber is true,
+// numbers are decoded as json.Number, so that they are re-encoded exactly.
+func toDocMap(doc interface{}, useNumber bool) (map[string]interface{}, error) {
+	doc, err := normalizeFromJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var m map[string]interface{}
+	if err := unmarshalJSON(raw, &m, useNumber); err != nil || m == nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: document must be a JSON object"}
+	}
+	return m, nil
+}
+
+// Upsert stores doc as docID, creating the document if it does not exist, or
+// overwriting the current revision if it does. The current revision is
+// fetched with [DB.GetRev], which is usually a cheap HEAD request. If the
+// document is modified concurrently, causing a conflict, the write is
+// retried as for [DB.Update]. Other options are passed to [DB.Put].
+//
+// Any _id or _rev field in doc is ignored. The new revision is returned.
+func (db *DB) Upsert(ctx context.Context, docID string, doc interface{}, options ...Options) (rev string, err error) {
+	if docID == "" {
+		return "", missingArg("docID")
+	}
+	body, err := toDocMap(doc, db.useNumber)
+	if err != nil {
+		return "", err
+	}
+	body["_id"] = docID
+	opts := mergeOptions(options...)
+	attempts := updateAttempts(opts)
+	for attempt := 1; ; attempt++ {
+		current, err := db.GetRev(ctx, docID)
+		switch {
+		case HTTPStatus(err) == http.StatusNotFound:
+			delete(body, "_rev")
+		case err != nil:
+			return "", err
+		default:
+			body["_rev"] = current
+		}
+		rev, err = db.Put(ctx, docID, body, opts)
+		if err == nil || HTTPStatus(err) != http.StatusConflict || attempt >= attempts {
+			return rev, err
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// counterDB returns a DB holding a single document with a numeric field n,
+// which fails the first conflicts writes with a conflict.
+func counterDB(exists bool, conflicts int, puts *int) *DB {
+	return &DB{
+		client: &Client{},
+		driverDB: &mock.DB{
+			GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				if !exists {
+					return nil, &Error{Status: http.StatusNotFound, Message: "missing"}
+				}
+				return &driver.Document{Body: body(fmt.Sprintf(`{"_id":"foo","_rev":"%d-x","n":%d}`, *puts+1, *puts))}, nil
+			},
+			PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+				*puts++
+				if *puts <= conflicts {
+					return "", &Error{Status: http.StatusConflict, Message: "document update conflict"}
+				}
+				m := doc.(map[string]interface{})
+				want := fmt.Sprintf("%d-x", *puts)
+				if !exists {
+					want = ""
+				}
+				if rev, _ := m["_rev"].(string); rev != want {
+					return "", fmt.Errorf("Unexpected _rev: %v", m["_rev"])
+				}
+				return "new-rev", nil
+			},
+		},
+	}
+}
+
+func increment(doc json.RawMessage) (interface{}, error) {
+	var counter struct {
+		N int `json:"n"`
+	}
+	if doc != nil {
+		if err := json.Unmarshal(doc, &counter); err != nil {
+			return nil, err
+		}
+	}
+	counter.N++
+	return counter, nil
+}
+
+func TestUpdate(t *testing.T) {
+	type tst struct {
+		exists    bool
+		conflicts int
+		fn        UpdateFunc
+		options   Options
+		rev       string
+		puts      int
+		status    int
+		err       string
+	}
+	tests := testy.NewTable()
+	tests.Add("create", tst{
+		fn:   increment,
+		rev:  "new-rev",
+		puts: 1,
+	})
+	tests.Add("update", tst{
+		exists: true,
+		fn:     increment,
+		rev:    "new-rev",
+		puts:   1,
+	})
+	tests.Add("retry on conflict", tst{
+		exists:    true,
+		conflicts: 2,
+		fn:        increment,
+		rev:       "new-rev",
+		puts:      3,
+	})
+	tests.Add("attempts exhausted", tst{
+		exists:    true,
+		conflicts: 5,
+		fn:        increment,
+		options:   WithUpdateAttempts(3),
+		puts:      3,
+		status:    http.StatusConflict,
+		err:       "document update conflict",
+	})
+	tests.Add("no change", tst{
+		exists: true,
+		fn:     func(json.RawMessage) (interface{}, error) { return nil, nil },
+		rev:    "1-x",
+	})
+	tests.Add("update func error", tst{
+		exists: true,
+		fn:     func(json.RawMessage) (interface{}, error) { return nil, errors.New("nope") },
+		status: http.StatusInternalServerError,
+		err:    "nope",
+	})
+	tests.Add("not an object", tst{
+		fn:     func(json.RawMessage) (interface{}, error) { return []int{1}, nil },
+		status: http.StatusBadRequest,
+		err:    "kivik: document must be a JSON object",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		var puts int
+		db := counterDB(test.exists, test.conflicts, &puts)
+		rev, err := db.Update(context.Background(), "foo", test.fn, test.options)
+		if puts != test.puts {
+			t.Errorf("Unexpected number of writes: %d", puts)
+		}
+		testy.StatusError(t, test.err, test.status, err)
+		if rev != test.rev {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	type tst struct {
+		db     *DB
+		doc    interface{}
+		rev    string
+		status int
+		err    string
+	}
+	missing := &Error{Status: http.StatusNotFound, Message: "missing"}
+	tests := testy.NewTable()
+	tests.Add("create", tst{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.RevGetter{
+				DB: &mock.DB{
+					PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+						want := map[string]interface{}{"_id": "foo", "n": 1.0}
+						if d := testy.DiffInterface(want, doc); d != nil {
+							return "", fmt.Errorf("Unexpected doc:\n%s", d)
+						}
+						return "1-x", nil
+					},
+				},
+				GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+					return "", missing
+				},
+			},
+		},
+		doc: map[string]interface{}{"_rev": "bogus", "n": 1},
+		rev: "1-x",
+	})
+	tests.Add("overwrite after conflict", func() interface{} {
+		var revs int
+		return tst{
+			db: &DB{
+				client: &Client{},
+				driverDB: &mock.RevGetter{
+					DB: &mock.DB{
+						PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+							if revs < 2 {
+								return "", &Error{Status: http.StatusConflict, Message: "conflict"}
+							}
+							if rev := doc.(map[string]interface{})["_rev"]; rev != "2-x" {
+								return "", fmt.Errorf("Unexpected rev: %v", rev)
+							}
+							return "3-x", nil
+						},
+					},
+					GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+						revs++
+						return fmt.Sprintf("%d-x", revs), nil
+					},
+				},
+			},
+			doc: map[string]interface{}{"n": 1},
+			rev: "3-x",
+		}
+	})
+	tests.Add("get rev error", tst{
+		db: &DB{
+			client: &Client{},
+			driverDB: &mock.RevGetter{
+				DB: &mock.DB{},
+				GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+					return "", &Error{Status: http.StatusBadGateway, Message: "bad gateway"}
+				},
+			},
+		},
+		doc:    map[string]interface{}{},
+		status: http.StatusBadGateway,
+		err:    "bad gateway",
+	})
+	tests.Add("invalid doc", tst{
+		db:     &DB{client: &Client{}},
+		doc:    "foo",
+		status: http.StatusBadRequest,
+		err:    "kivik: document must be a JSON object",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		rev, err := test.db.Upsert(context.Background(), "foo", test.doc)
+		testy.StatusError(t, test.err, test.status, err)
+		if rev != test.rev {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+}
@@ -14,6 +14,7 @@ package kivik
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/go-kivik/kivik/v4/driver"
@@ -24,18 +25,51 @@ type DBUpdates struct {
 	*iter
 }
 
-type updatesIterator struct{ driver.DBUpdates }
+type updatesIterator struct {
+	driver.DBUpdates
+	lastSeq    string
+	lastSeqErr error
+}
 
 var _ iterator = &updatesIterator{}
 
-func (r *updatesIterator) Next(i interface{}) error { return r.DBUpdates.Next(i.(*driver.DBUpdate)) }
+func (r *updatesIterator) Next(i interface{}) error {
+	update := i.(*driver.DBUpdate)
+	err := r.DBUpdates.Next(update)
+	switch {
+	case err == nil:
+		r.lastSeq = update.Seq
+	case err == io.EOF:
+		if seqer, ok := r.DBUpdates.(driver.LastSeqer); ok {
+			r.lastSeq, r.lastSeqErr = seqer.LastSeq()
+		}
+	}
+	return err
+}
 
 func newDBUpdates(ctx context.Context, onClose func(), updatesi driver.DBUpdates) *DBUpdates {
 	return &DBUpdates{
-		iter: newIterator(ctx, onClose, &updatesIterator{updatesi}, &driver.DBUpdate{}),
+		iter: newIterator(ctx, onClose, &updatesIterator{DBUpdates: updatesi}, &driver.DBUpdate{}),
 	}
 }
 
+// LastSeq returns the update sequence at the end of the feed, which may be
+// passed as the since option to a subsequent call to [Client.DBUpdates], to
+// resume from where this feed stopped. It must be called after [DBUpdates.Next]
+// returns false. Otherwise it will return an error.
+func (f *DBUpdates) LastSeq() (string, error) {
+	if f.iter == nil || f.feed == nil {
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: LastSeq called on an uninitialized feed"}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.state != stateClosed {
+		return "", &Error{Status: http.StatusBadRequest, Message: "kivik: LastSeq must not be called until the feed is complete"}
+	}
+	it := f.feed.(*updatesIterator)
+	return it.lastSeq, it.lastSeqErr
+}
+
 // DBName returns the database name for the current update.
 func (f *DBUpdates) DBName() string {
 	runlock, err := f.rlock()
@@ -66,21 +100,34 @@ func (f *DBUpdates) Seq() string {
 	return f.curVal.(*driver.DBUpdate).Seq
 }
 
-// DBUpdates begins polling for database updates.
+// DBUpdates begins polling for database updates. The feed may be configured
+// with [DBUpdatesOptions], and resumed later from the sequence reported by
+// [DBUpdates.LastSeq].
 func (c *Client) DBUpdates(ctx context.Context, options ...Options) *DBUpdates {
-	updater, ok := c.driverClient.(driver.DBUpdater)
+	updater, ok := optional(c.driverClient, (*driver.DBUpdater)(nil)).(driver.DBUpdater)
 	if !ok {
 		return &DBUpdates{errIterator(&Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not implement DBUpdater"})}
 	}
 
+	opts := mergeOptions(options...)
+	if err := validateDBUpdatesOptions(opts); err != nil {
+		return &DBUpdates{errIterator(err)}
+	}
 	if err := c.startQuery(); err != nil {
 		return &DBUpdates{errIterator(err)}
 	}
 
-	updatesi, err := updater.DBUpdates(ctx, mergeOptions(options...))
+	var updatesi driver.DBUpdates
+	op := &Operation{Method: "DBUpdates", Options: opts, ReadOnly: true, iterator: true, feed: true}
+	err := c.invoke(ctx, op, func(ctx context.Context) (err error) {
+		updatesi, err = updater.DBUpdates(ctx, opts)
+		return err
+	})
 	if err != nil {
 		c.endQuery()
 		return &DBUpdates{errIterator(err)}
 	}
-	return newDBUpdates(context.Background(), c.endQuery, updatesi)
+	it := newDBUpdates(context.Background(), c.endQuery, updatesi)
+	c.trackIterator(op, it.iter)
+	return it
 }
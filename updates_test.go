@@ -263,3 +263,96 @@ func TestDBUpdates(t *testing.T) {
 		})
 	})
 }
+
+func TestDBUpdatesFromFixture(t *testing.T) {
+	feed, err := mock.DBUpdatesFeedFromJSON(`{
+		"results": [
+			{"db_name": "foo", "type": "created", "seq": "1-x"},
+			{"db_name": "foo", "type": "updated", "seq": "2-x"}
+		],
+		"last_seq": "2-x"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.AddError(errors.New("connection reset"))
+	u := newDBUpdates(context.Background(), nil, feed.DBUpdates())
+	var got []string
+	for u.Next() {
+		got = append(got, u.DBName()+" "+u.Type()+" "+u.Seq())
+	}
+	want := []string{"foo created 1-x", "foo updated 2-x"}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	testy.Error(t, "connection reset", u.Err())
+}
+
+func TestDBUpdatesLastSeq(t *testing.T) {
+	updates := func() *mock.DBUpdates {
+		return mock.NewDBUpdatesFeed(
+			driver.DBUpdate{DBName: "a", Type: "created", Seq: "1-x"},
+			driver.DBUpdate{DBName: "b", Type: "created", Seq: "2-x"},
+		).DBUpdates()
+	}
+	drain := func(t *testing.T, u *DBUpdates) {
+		t.Helper()
+		for u.Next() { //nolint:revive // intentional empty block
+		}
+		if err := u.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("from driver", func(t *testing.T) {
+		u := newDBUpdates(context.Background(), nil, &mock.LastSeqer{
+			DBUpdates: updates(),
+			LastSeqFunc: func() (string, error) {
+				return "5-x", nil
+			},
+		})
+		drain(t, u)
+		seq, err := u.LastSeq()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != "5-x" {
+			t.Errorf("Unexpected last seq: %s", seq)
+		}
+	})
+	t.Run("driver error", func(t *testing.T) {
+		u := newDBUpdates(context.Background(), nil, &mock.LastSeqer{
+			DBUpdates: updates(),
+			LastSeqFunc: func() (string, error) {
+				return "", errors.New("no last_seq")
+			},
+		})
+		drain(t, u)
+		_, err := u.LastSeq()
+		testy.Error(t, "no last_seq", err)
+	})
+	t.Run("last update", func(t *testing.T) {
+		u := newDBUpdates(context.Background(), nil, updates())
+		drain(t, u)
+		seq, err := u.LastSeq()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != "2-x" {
+			t.Errorf("Unexpected last seq: %s", seq)
+		}
+	})
+	t.Run("before the end", func(t *testing.T) {
+		u := newDBUpdates(context.Background(), nil, updates())
+		if !u.Next() {
+			t.Fatal(u.Err())
+		}
+		_, err := u.LastSeq()
+		testy.StatusError(t, "kivik: LastSeq must not be called until the feed is complete", http.StatusBadRequest, err)
+	})
+	t.Run("error iterator", func(t *testing.T) {
+		u := (&Client{driverClient: &mock.Client{}}).DBUpdates(context.Background())
+		_, err := u.LastSeq()
+		testy.StatusError(t, "kivik: LastSeq called on an uninitialized feed", http.StatusBadRequest, err)
+	})
+}
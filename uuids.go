@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// UUIDAlgorithm is an algorithm used by [Client.UUIDs] to generate UUIDs
+// locally. The algorithms are those of CouchDB's uuids/algorithm setting.
+type UUIDAlgorithm string
+
+// The UUID algorithms supported by [Client.UUIDs].
+const (
+	// UUIDRandom generates 128 random bits, as 32 hex characters.
+	UUIDRandom UUIDAlgorithm = "random"
+	// UUIDSequential generates UUIDs which share a random 26 character hex
+	// prefix, followed by a six character hex suffix, which increases by a
+	// random amount with each UUID. When the suffix overflows, a new prefix
+	// is chosen. Sequential UUIDs keep inserts into CouchDB's B-trees
+	// cheap.
+	UUIDSequential UUIDAlgorithm = "sequential"
+	// UUIDUTCRandom generates the time since the Unix epoch, in microseconds,
+	// as 14 hex characters, followed by 18 random hex characters.
+	UUIDUTCRandom UUIDAlgorithm = "utc_random"
+	// UUIDUTCID generates the time since the Unix epoch, in microseconds, as
+	// 14 hex characters, followed by the suffix set with [WithUTCIDSuffix].
+	UUIDUTCID UUIDAlgorithm = "utc_id"
+)
+
+const (
+	optionUUIDAlgorithm = "kivik:uuidAlgorithm"
+	optionUTCIDSuffix   = "kivik:utcIDSuffix"
+	optionServerUUIDs   = "kivik:serverUUIDs"
+)
+
+// WithUUIDAlgorithm returns an option which causes [Client.UUIDs] to generate
+// UUIDs locally with alg, even if the server could generate them. It may be
+// passed to [New], to set the client's default, or to Client.UUIDs.
+func WithUUIDAlgorithm(alg UUIDAlgorithm) Options {
+	return Options{optionUUIDAlgorithm: alg}
+}
+
+// WithUTCIDSuffix returns an option which sets the suffix of UUIDs generated
+// with [UUIDUTCID]. It may be passed to [New] or to [Client.UUIDs].
+func WithUTCIDSuffix(suffix string) Options {
+	return Options{optionUTCIDSuffix: suffix}
+}
+
+// WithServerUUIDs returns an option which causes [Client.UUIDs] to fail with
+// status 501 (Not Implemented), rather than generating UUIDs locally, if the
+// server cannot generate them. It may be passed to [New] or to Client.UUIDs.
+func WithServerUUIDs() Options {
+	return Options{optionServerUUIDs: true}
+}
+
+// UUIDs returns count UUIDs. If the driver implements [driver.UUIDer], as
+// drivers for CouchDB do with the /_uuids endpoint, the UUIDs are generated
+// by the server, unless an algorithm is chosen with [WithUUIDAlgorithm].
+// Otherwise, they are generated locally with the chosen algorithm, or
+// [UUIDRandom], unless [WithServerUUIDs] is given. A driver which returns
+// status 501 (Not Implemented) from UUIDs, as wrappers of other drivers may,
+// is treated as one which cannot generate UUIDs.
+func (c *Client) UUIDs(ctx context.Context, count int, options ...Options) ([]string, error) {
+	if err := c.startQuery(); err != nil {
+		return nil, err
+	}
+	defer c.endQuery()
+	if count < 1 {
+		return nil, &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid UUID count %d", count)}
+	}
+	opts := mergeOptions(append([]Options{c.uuidOptions}, options...)...)
+	alg, local := opts[optionUUIDAlgorithm].(UUIDAlgorithm)
+	serverOnly, _ := opts[optionServerUUIDs].(bool)
+	if uuider, ok := optional(c.driverClient, (*driver.UUIDer)(nil)).(driver.UUIDer); ok && (serverOnly || !local) {
+		var uuids []string
+		err := c.invoke(ctx, &Operation{Method: "UUIDs", ReadOnly: true}, func(ctx context.Context) (err error) {
+			uuids, err = uuider.UUIDs(ctx, count)
+			return err
+		})
+		if serverOnly || HTTPStatus(err) != http.StatusNotImplemented {
+			return uuids, err
+		}
+	}
+	if serverOnly {
+		return nil, &Error{Status: http.StatusNotImplemented, Message: "kivik: driver does not support server-side UUIDs"}
+	}
+	var next func() string
+	switch alg {
+	case "", UUIDRandom:
+		next = randomUUID
+	case UUIDSequential:
+		next = c.sequentialUUIDs.next
+	case UUIDUTCRandom:
+		next = utcRandomUUID
+	case UUIDUTCID:
+		suffix, _ := opts[optionUTCIDSuffix].(string)
+		next = func() string { return utcUUID(time.Now()) + suffix }
+	default:
+		return nil, &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("kivik: unknown UUID algorithm %q", alg)}
+	}
+	uuids := make([]string, count)
+	for i := range uuids {
+		uuids[i] = next()
+	}
+	return uuids, nil
+}
@@ -0,0 +1,188 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestUUIDs(t *testing.T) {
+	server := &mock.UUIDer{
+		UUIDsFunc: func(_ context.Context, count int) ([]string, error) {
+			uuids := make([]string, count)
+			for i := range uuids {
+				uuids[i] = "server"
+			}
+			return uuids, nil
+		},
+	}
+	type tt struct {
+		client  *Client
+		count   int
+		options Options
+		want    *regexp.Regexp
+		status  int
+		err     string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("server", tt{
+		client: &Client{driverClient: server},
+		count:  2,
+		want:   regexp.MustCompile(`^server$`),
+	})
+	tests.Add("random fallback", tt{
+		client: &Client{driverClient: &mock.Client{}},
+		count:  3,
+		want:   regexp.MustCompile(`^[0-9a-f]{32}$`),
+	})
+	tests.Add("local algorithm overrides server", tt{
+		client:  &Client{driverClient: server},
+		count:   2,
+		options: WithUUIDAlgorithm(UUIDUTCRandom),
+		want:    regexp.MustCompile(`^[0-9a-f]{32}$`),
+	})
+	tests.Add("utc_id", tt{
+		client:  &Client{driverClient: &mock.Client{}},
+		count:   1,
+		options: mergeOptions(WithUUIDAlgorithm(UUIDUTCID), WithUTCIDSuffix("-node1")),
+		want:    regexp.MustCompile(`^[0-9a-f]{14}-node1$`),
+	})
+	tests.Add("client default", tt{
+		client: &Client{driverClient: &mock.Client{}, uuidOptions: WithUUIDAlgorithm(UUIDSequential)},
+		count:  1,
+		want:   regexp.MustCompile(`^[0-9a-f]{32}$`),
+	})
+	tests.Add("server forced", tt{
+		client:  &Client{driverClient: server, uuidOptions: WithUUIDAlgorithm(UUIDRandom)},
+		count:   1,
+		options: WithServerUUIDs(),
+		want:    regexp.MustCompile(`^server$`),
+	})
+	tests.Add("server forced, not supported", tt{
+		client:  &Client{driverClient: &mock.Client{}},
+		count:   1,
+		options: WithServerUUIDs(),
+		status:  http.StatusNotImplemented,
+		err:     "kivik: driver does not support server-side UUIDs",
+	})
+	unsupported := &mock.UUIDer{
+		UUIDsFunc: func(context.Context, int) ([]string, error) {
+			return nil, &Error{Status: http.StatusNotImplemented, Message: "not supported"}
+		},
+	}
+	tests.Add("server not supported, fallback", tt{
+		client: &Client{driverClient: unsupported},
+		count:  2,
+		want:   regexp.MustCompile(`^[0-9a-f]{32}$`),
+	})
+	tests.Add("server forced, driver returns not implemented", tt{
+		client:  &Client{driverClient: unsupported},
+		count:   1,
+		options: WithServerUUIDs(),
+		status:  http.StatusNotImplemented,
+		err:     "not supported",
+	})
+	tests.Add("invalid count", tt{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid UUID count 0",
+	})
+	tests.Add("unknown algorithm", tt{
+		client:  &Client{driverClient: &mock.Client{}},
+		count:   1,
+		options: WithUUIDAlgorithm("v9"),
+		status:  http.StatusBadRequest,
+		err:     `kivik: unknown UUID algorithm "v9"`,
+	})
+	tests.Add(errClientClosed, tt{
+		client: &Client{closed: 1},
+		count:  1,
+		status: http.StatusServiceUnavailable,
+		err:    errClientClosed,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		uuids, err := tt.client.UUIDs(context.Background(), tt.count, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if len(uuids) != tt.count {
+			t.Fatalf("Expected %d UUIDs, got %d", tt.count, len(uuids))
+		}
+		for _, uuid := range uuids {
+			if !tt.want.MatchString(uuid) {
+				t.Errorf("Unexpected UUID: %s", uuid)
+			}
+		}
+	})
+}
+
+func TestUUIDsOrdering(t *testing.T) {
+	ctx := context.Background()
+	t.Run("sequential", func(t *testing.T) {
+		c := &Client{driverClient: &mock.Client{}}
+		uuids, err := c.UUIDs(ctx, 100, WithUUIDAlgorithm(UUIDSequential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		more, err := c.UUIDs(ctx, 100, WithUUIDAlgorithm(UUIDSequential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, more...)
+		for i := 1; i < len(uuids); i++ {
+			if uuids[i][:26] != uuids[0][:26] {
+				t.Fatalf("Prefix changed: %s, %s", uuids[0], uuids[i])
+			}
+			if uuids[i] <= uuids[i-1] {
+				t.Fatalf("UUIDs not increasing: %s, %s", uuids[i-1], uuids[i])
+			}
+		}
+	})
+	t.Run("sequential overflow", func(t *testing.T) {
+		s := &sequentialUUIDs{prefix: "00000000000000000000000000", seq: sequentialOverflow}
+		if uuid := s.next(); uuid[:26] == "00000000000000000000000000" {
+			t.Errorf("Prefix not changed on overflow: %s", uuid)
+		}
+	})
+	t.Run("utc_random", func(t *testing.T) {
+		c := &Client{driverClient: &mock.Client{}}
+		uuids, err := c.UUIDs(ctx, 2, WithUUIDAlgorithm(UUIDUTCRandom))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uuids[0][:14] > uuids[1][:14] {
+			t.Errorf("Timestamps not ordered: %s, %s", uuids[0], uuids[1])
+		}
+	})
+}
+
+func TestUUIDClientOptions(t *testing.T) {
+	c, err := NewClientFromDriverClient(&mock.Client{}, WithUUIDAlgorithm(UUIDUTCID), WithUTCIDSuffix("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids, err := c.UUIDs(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{14}x$`).MatchString(uuids[0]) {
+		t.Errorf("Unexpected UUID: %s", uuids[0])
+	}
+}
@@ -0,0 +1,75 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ValidateFunc is a client-side validation function, as registered with
+// [DB.AddValidator]. It should return an error if doc may not be written.
+type ValidateFunc func(doc interface{}) error
+
+// AddValidator registers fn to be called with each document before it is
+// written by [DB.Put], [DB.CreateDoc], [DB.BulkDocs] or any of the helpers
+// built on them, such as [DB.Save], [DB.Update] and [BulkPut]. Documents
+// passed as an [io.Reader] are passed to fn as [encoding/json.RawMessage];
+// other values are passed unaltered.
+// Deletions made with [DB.Delete] are not validated, but documents with the
+// _deleted field set are.
+//
+// Validators are run in the order registered, and the first error aborts
+// the write. An error without an HTTP status is returned with status 400
+// (Bad Request). For BulkDocs, each invalid document is reported in the
+// results, and only the valid documents are sent to the database.
+//
+// Validators apply only to this DB handle, and not to other handles returned
+// by [Client.DB] for the same database. AddValidator is safe to call
+// concurrently with writes.
+func (db *DB) AddValidator(fn ValidateFunc) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.validators = append(db.validators, fn)
+}
+
+func (db *DB) hasValidators() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.validators) > 0
+}
+
+// validate normalizes doc and runs the registered validators on it. doc is
+// returned unaltered if there are no validators.
+func (db *DB) validate(doc interface{}) (interface{}, error) {
+	db.mu.Lock()
+	validators := db.validators
+	db.mu.Unlock()
+	if len(validators) == 0 {
+		return doc, nil
+	}
+	doc, err := normalizeFromJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range validators {
+		if err := fn(doc); err != nil {
+			var status statusCoder
+			if errors.As(err, &status) {
+				return nil, err
+			}
+			return nil, &Error{Status: http.StatusBadRequest, Message: "kivik: document failed validation", Err: err}
+		}
+	}
+	return doc, nil
+}
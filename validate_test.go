@@ -0,0 +1,183 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// requireName is a validator which rejects documents without a name field.
+func requireName(doc interface{}) error {
+	var fields struct {
+		Name string `json:"name"`
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	if fields.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func TestAddValidator(t *testing.T) {
+	t.Run("put", func(t *testing.T) {
+		var stored interface{}
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				PutFunc: func(_ context.Context, _ string, doc interface{}, _ map[string]interface{}) (string, error) {
+					stored = doc
+					return "1-xxx", nil
+				},
+			},
+		}
+		var seen []interface{}
+		db.AddValidator(func(doc interface{}) error {
+			seen = append(seen, doc)
+			return nil
+		})
+		db.AddValidator(requireName)
+
+		if _, err := db.Put(context.Background(), "cow", strings.NewReader(`{"name":"Bessie"}`)); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]interface{}{json.RawMessage(`{"name":"Bessie"}`)}, seen); d != nil {
+			t.Error(d)
+		}
+		stored = nil
+		_, err := db.Put(context.Background(), "cow", strings.NewReader(`{}`))
+		if stored != nil {
+			t.Errorf("Invalid document was stored: %v", stored)
+		}
+		testy.StatusError(t, "kivik: document failed validation: name required", http.StatusBadRequest, err)
+	})
+	t.Run("status preserved", func(t *testing.T) {
+		db := &DB{client: &Client{}, driverDB: &mock.DB{}}
+		db.AddValidator(func(interface{}) error {
+			return &Error{Status: http.StatusForbidden, Message: "read only"}
+		})
+		_, _, err := db.CreateDoc(context.Background(), map[string]string{"name": "Bessie"})
+		testy.StatusError(t, "read only", http.StatusForbidden, err)
+	})
+	t.Run("create doc", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				CreateDocFunc: func(_ context.Context, doc interface{}, _ map[string]interface{}) (string, string, error) {
+					if d := testy.DiffAsJSON([]byte(`{"name":"Bessie"}`), doc); d != nil {
+						return "", "", fmt.Errorf("Unexpected doc:\n%s", d)
+					}
+					return "cow", "1-xxx", nil
+				},
+			},
+		}
+		db.AddValidator(requireName)
+		id, _, err := db.CreateDoc(context.Background(), strings.NewReader(`{"name":"Bessie"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "cow" {
+			t.Errorf("Unexpected id: %s", id)
+		}
+		_, _, err = db.CreateDoc(context.Background(), map[string]string{})
+		testy.StatusError(t, "kivik: document failed validation: name required", http.StatusBadRequest, err)
+	})
+	t.Run("bulk docs", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) ([]driver.BulkResult, error) {
+					results := make([]driver.BulkResult, len(docs))
+					for i, doc := range docs {
+						id, _ := extractDocID(doc)
+						results[i] = driver.BulkResult{ID: id, Rev: "1-xxx"}
+					}
+					return results, nil
+				},
+			},
+		}
+		db.AddValidator(requireName)
+		results, err := db.BulkDocs(context.Background(), []interface{}{
+			map[string]string{"_id": "a", "name": "Bessie"},
+			map[string]string{"_id": "b"},
+			map[string]string{"_id": "c", "name": "Daisy"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 || results[0].Rev != "1-xxx" || results[2].ID != "c" || results[2].Rev != "1-xxx" {
+			t.Fatalf("Unexpected results: %v", results)
+		}
+		if results[1].ID != "b" || HTTPStatus(results[1].Error) != http.StatusBadRequest {
+			t.Errorf("Unexpected result for invalid doc: %v", results[1])
+		}
+	})
+	t.Run("bulk docs all invalid", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocer{
+				BulkDocsFunc: func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error) {
+					return nil, errors.New("unexpected call")
+				},
+			},
+		}
+		db.AddValidator(requireName)
+		results, err := db.BulkDocs(context.Background(), []interface{}{map[string]string{"_id": "a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ID != "a" || results[0].Error == nil {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+	t.Run("bulk docs from", func(t *testing.T) {
+		db := &DB{
+			client: &Client{},
+			driverDB: &mock.BulkDocsStreamer{
+				DB: &mock.DB{
+					PutFunc: func(_ context.Context, docID string, _ interface{}, _ map[string]interface{}) (string, error) {
+						return "1-" + docID, nil
+					},
+				},
+				BulkDocsStreamFunc: func(context.Context, driver.DocSource, map[string]interface{}) ([]driver.BulkResult, error) {
+					return nil, errors.New("validated documents should not be streamed")
+				},
+			},
+		}
+		db.AddValidator(requireName)
+		results, err := db.BulkDocsFrom(context.Background(), NDJSONSource(strings.NewReader(`{"_id":"a","name":"Bessie"}
+{"_id":"b"}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Rev != "1-a" || results[1].Error == nil {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultSampleSize is the number of documents checked by
+// [VerifyReplication] and [ReplicationLag], when [WithSampleSize] is not
+// used.
+const defaultSampleSize = 100
+
+// optionSampleSize is the option key used by [WithSampleSize].
+const optionSampleSize = "kivik:sampleSize"
+
+// WithSampleSize returns an option which sets the number of documents checked
+// by [VerifyReplication], or the number of recent changes checked by
+// [ReplicationLag]. The default is 100.
+func WithSampleSize(n int) Options {
+	return Options{optionSampleSize: n}
+}
+
+// ReplicationReport is the result of [VerifyReplication].
+type ReplicationReport struct {
+	SourceDocCount     int64
+	TargetDocCount     int64
+	SourceDeletedCount int64
+	TargetDeletedCount int64
+	// SourceUpdateSeq and TargetUpdateSeq are reported for information only;
+	// update sequences are not comparable between databases.
+	SourceUpdateSeq string
+	TargetUpdateSeq string
+	// Sampled is the number of source documents whose revisions were checked
+	// in the target.
+	Sampled int
+	// Missing lists the sampled documents not found in the target.
+	Missing []string
+	// RevMismatches lists the sampled documents found in the target with a
+	// different current revision.
+	RevMismatches []string
+}
+
+// OK returns true if the source and target have the same document counts,
+// and every sampled document has the same revision in both.
+func (r *ReplicationReport) OK() bool {
+	return r.SourceDocCount == r.TargetDocCount &&
+		r.SourceDeletedCount == r.TargetDeletedCount &&
+		len(r.Missing) == 0 &&
+		len(r.RevMismatches) == 0
+}
+
+// VerifyReplication checks that target is a complete copy of source, such as
+// after a one-shot replication, by comparing their document counts, and by
+// checking that a random sample of source documents have the same current
+// revision in the target. The sample is drawn in a single pass over the
+// source's [DB.AllDocs], and its size is set with [WithSampleSize]; other
+// options are passed to AllDocs. To compare every document, see [Diff].
+func VerifyReplication(ctx context.Context, source, target *DB, options ...Options) (*ReplicationReport, error) {
+	opts := mergeOptions(options...)
+	size := sampleSize(opts)
+	delete(opts, optionSampleSize)
+	sourceStats, err := source.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetStats, err := target.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReplicationReport{
+		SourceDocCount:     sourceStats.DocCount,
+		TargetDocCount:     targetStats.DocCount,
+		SourceDeletedCount: sourceStats.DeletedCount,
+		TargetDeletedCount: targetStats.DeletedCount,
+		SourceUpdateSeq:    sourceStats.UpdateSeq,
+		TargetUpdateSeq:    targetStats.UpdateSeq,
+	}
+	sample, err := sampleRevs(ctx, source, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.Sampled = len(sample)
+	for _, doc := range sample {
+		rev, err := target.GetRev(ctx, doc.id)
+		switch {
+		case HTTPStatus(err) == http.StatusNotFound:
+			report.Missing = append(report.Missing, doc.id)
+		case err != nil:
+			return nil, err
+		case rev != doc.rev:
+			report.RevMismatches = append(report.RevMismatches, doc.id)
+		}
+	}
+	return report, nil
+}
+
+func sampleSize(opts Options) int {
+	if n, ok := opts[optionSampleSize].(int); ok && n > 0 {
+		return n
+	}
+	return defaultSampleSize
+}
+
+type sampledRev struct {
+	id, rev string
+}
+
+// sampleRevs returns up to size randomly chosen documents from db, in order of
+// document ID.
+func sampleRevs(ctx context.Context, db *DB, size int, opts Options) ([]sampledRev, error) {
+	rs := db.AllDocs(ctx, opts)
+	defer rs.Close() // nolint:errcheck
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	sample := make([]sampledRev, 0, size)
+	for seen := 0; rs.Next(); seen++ {
+		id, err := rs.ID()
+		if err != nil {
+			return nil, err
+		}
+		var value struct {
+			Rev string `json:"rev"`
+		}
+		if err := rs.ScanValue(&value); err != nil {
+			return nil, err
+		}
+		// Reservoir sampling, so that every document has an equal chance of
+		// being chosen, without knowing the number of documents in advance.
+		if seen < size {
+			sample = append(sample, sampledRev{id: id, rev: value.Rev})
+		} else if i := rnd.Intn(seen + 1); i < size {
+			sample[i] = sampledRev{id: id, rev: value.Rev}
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(sample, func(i, j int) bool { return sample[i].id < sample[j].id })
+	return sample, nil
+}
+
+// Lag describes how far a target database is behind its source, as reported
+// by [ReplicationLag].
+type Lag struct {
+	// Pending is the number of recent changes to the source not yet present
+	// in the target.
+	Pending int64
+	// Truncated is true if every change checked was pending, in which case
+	// the true lag may be greater than Pending.
+	Truncated bool
+	// SourceSeq is the update sequence of the most recent change to the
+	// source.
+	SourceSeq string
+	// ReplicatedSeq is the update sequence of the most recent change to the
+	// source which is present in the target, or empty if none was found.
+	ReplicatedSeq string
+}
+
+// ReplicationLag reports how far target is behind source, such as during a
+// continuous replication. It reads the most recent changes to source, newest
+// first, and counts those whose revision is not yet present in target,
+// stopping at the first change which has been replicated. A deletion is
+// considered replicated if the document is not found in target. At most the
+// number of changes set by [WithSampleSize] are checked. Other options are
+// passed to [DB.Changes]; the source driver must support the descending
+// option.
+func ReplicationLag(ctx context.Context, source, target *DB, options ...Options) (*Lag, error) {
+	opts := mergeOptions(options...)
+	if opts == nil {
+		opts = Options{}
+	}
+	size := sampleSize(opts)
+	delete(opts, optionSampleSize)
+	opts["descending"] = true
+	opts["limit"] = size
+	changes := source.Changes(ctx, opts)
+	defer changes.Close() // nolint:errcheck
+	lag := &Lag{}
+	for changes.Next() {
+		if lag.SourceSeq == "" {
+			lag.SourceSeq = changes.Seq()
+		}
+		replicated, err := hasChange(ctx, target, changes)
+		if err != nil {
+			return nil, err
+		}
+		if replicated {
+			lag.ReplicatedSeq = changes.Seq()
+			return lag, nil
+		}
+		lag.Pending++
+	}
+	if err := changes.Err(); err != nil {
+		return nil, err
+	}
+	lag.Truncated = lag.Pending == int64(size)
+	return lag, nil
+}
+
+// hasChange returns true if the current change has been applied to target.
+func hasChange(ctx context.Context, target *DB, change *Changes) (bool, error) {
+	rev, err := target.GetRev(ctx, change.ID())
+	if HTTPStatus(err) == http.StatusNotFound {
+		return change.Deleted(), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, r := range change.Changes() {
+		if r == rev {
+			return true, nil
+		}
+	}
+	return false, nil
+}
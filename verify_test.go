@@ -0,0 +1,198 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// verifyDB returns a database containing the documents in revs, listed by
+// AllDocs in the order of ids, and whose changes feed, newest first, is
+// changes.
+func verifyDB(ids []string, revs map[string]string, deleted int64, changes ...driver.Change) *DB {
+	db := diffDB(ids, revs, nil)
+	mdb := db.driverDB.(*mock.DB)
+	mdb.StatsFunc = func(context.Context) (*driver.DBStats, error) {
+		return &driver.DBStats{DocCount: int64(len(ids)), DeletedCount: deleted, UpdateSeq: fmt.Sprintf("%d-xxx", len(ids))}, nil
+	}
+	mdb.ChangesFunc = func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+		if opts["descending"] != true {
+			return nil, errors.New("descending expected")
+		}
+		limit := opts["limit"].(int)
+		if limit < len(changes) {
+			changes = changes[:limit]
+		}
+		return &mock.Changes{
+			NextFunc: func(ch *driver.Change) error {
+				if len(changes) == 0 {
+					return io.EOF
+				}
+				*ch = changes[0]
+				changes = changes[1:]
+				return nil
+			},
+		}, nil
+	}
+	db.driverDB = &mock.RevGetter{
+		DB: mdb,
+		GetRevFunc: func(_ context.Context, docID string, _ map[string]interface{}) (string, error) {
+			if rev, ok := revs[docID]; ok {
+				return rev, nil
+			}
+			return "", &Error{Status: http.StatusNotFound, Message: "missing"}
+		},
+	}
+	return db
+}
+
+func TestVerifyReplication(t *testing.T) {
+	type tt struct {
+		source, target *DB
+		options        Options
+		want           *ReplicationReport
+		wantOK         bool
+		status         int
+		err            string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("complete", tt{
+		source: verifyDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "1-b"}, 1),
+		target: verifyDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "1-b"}, 1),
+		want: &ReplicationReport{
+			SourceDocCount: 2, TargetDocCount: 2,
+			SourceDeletedCount: 1, TargetDeletedCount: 1,
+			SourceUpdateSeq: "2-xxx", TargetUpdateSeq: "2-xxx",
+			Sampled: 2,
+		},
+		wantOK: true,
+	})
+	tests.Add("incomplete", tt{
+		source: verifyDB([]string{"a", "b", "c"}, map[string]string{"a": "1-a", "b": "2-b", "c": "1-c"}, 0),
+		target: verifyDB([]string{"a", "b"}, map[string]string{"a": "1-a", "b": "1-b"}, 0),
+		want: &ReplicationReport{
+			SourceDocCount: 3, TargetDocCount: 2,
+			SourceUpdateSeq: "3-xxx", TargetUpdateSeq: "2-xxx",
+			Sampled:       3,
+			Missing:       []string{"c"},
+			RevMismatches: []string{"b"},
+		},
+	})
+	tests.Add("sample size", tt{
+		source:  verifyDB([]string{"a", "b", "c"}, map[string]string{"a": "1-a", "b": "1-b", "c": "1-c"}, 0),
+		target:  verifyDB([]string{"a", "b", "c"}, map[string]string{"a": "1-a", "b": "1-b", "c": "1-c"}, 0),
+		options: WithSampleSize(2),
+		want: &ReplicationReport{
+			SourceDocCount: 3, TargetDocCount: 3,
+			SourceUpdateSeq: "3-xxx", TargetUpdateSeq: "3-xxx",
+			Sampled: 2,
+		},
+		wantOK: true,
+	})
+	tests.Add("stats error", tt{
+		source: &DB{
+			client: &Client{},
+			driverDB: &mock.DB{
+				StatsFunc: func(context.Context) (*driver.DBStats, error) {
+					return nil, &Error{Status: http.StatusUnauthorized, Message: "unauthorized"}
+				},
+			},
+		},
+		target: verifyDB(nil, nil, 0),
+		status: http.StatusUnauthorized,
+		err:    "unauthorized",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		report, err := VerifyReplication(context.Background(), tt.source, tt.target, tt.options)
+		if d := testy.DiffInterface(tt.want, report); d != nil {
+			t.Error(d)
+		}
+		if report != nil && report.OK() != tt.wantOK {
+			t.Errorf("Unexpected OK: %t", report.OK())
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestReplicationLag(t *testing.T) {
+	type tt struct {
+		source, target *DB
+		options        Options
+		want           *Lag
+		status         int
+		err            string
+	}
+
+	changes := []driver.Change{
+		{ID: "c", Seq: "4-xxx", Changes: []string{"1-c"}},
+		{ID: "b", Seq: "3-xxx", Changes: []string{"2-b"}},
+		{ID: "x", Seq: "2-xxx", Changes: []string{"2-x"}, Deleted: true},
+		{ID: "a", Seq: "1-xxx", Changes: []string{"1-a"}},
+	}
+	tests := testy.NewTable()
+	tests.Add("caught up", tt{
+		source: verifyDB(nil, nil, 0, changes...),
+		target: verifyDB(nil, map[string]string{"a": "1-a", "b": "2-b", "c": "1-c"}, 0),
+		want:   &Lag{SourceSeq: "4-xxx", ReplicatedSeq: "4-xxx"},
+	})
+	tests.Add("behind", tt{
+		source: verifyDB(nil, nil, 0, changes...),
+		target: verifyDB(nil, map[string]string{"a": "1-a", "b": "1-b"}, 0),
+		want:   &Lag{Pending: 2, SourceSeq: "4-xxx", ReplicatedSeq: "2-xxx"},
+	})
+	tests.Add("truncated", tt{
+		source:  verifyDB(nil, nil, 0, changes...),
+		target:  verifyDB(nil, map[string]string{"a": "1-a"}, 0),
+		options: WithSampleSize(2),
+		want:    &Lag{Pending: 2, Truncated: true, SourceSeq: "4-xxx"},
+	})
+	tests.Add("empty", tt{
+		source: verifyDB(nil, nil, 0),
+		target: verifyDB(nil, nil, 0),
+		want:   &Lag{},
+	})
+	tests.Add("target error", tt{
+		source: verifyDB(nil, nil, 0, changes...),
+		target: &DB{
+			client: &Client{},
+			driverDB: &mock.RevGetter{
+				DB: &mock.DB{},
+				GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+					return "", &Error{Status: http.StatusBadGateway, Message: "bad gateway"}
+				},
+			},
+		},
+		status: http.StatusBadGateway,
+		err:    "bad gateway",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		lag, err := ReplicationLag(context.Background(), tt.source, tt.target, tt.options)
+		if d := testy.DiffInterface(tt.want, lag); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
@@ -0,0 +1,212 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Compare compares the server's version with version, as described for
+// [CompareVersions].
+func (v *Version) Compare(version string) int {
+	return CompareVersions(v.Version, version)
+}
+
+// AtLeast returns true if the server's version is version or later, as
+// compared by [CompareVersions]. For example, v.AtLeast("3.0") reports
+// whether the server is CouchDB 3.0.0 or later.
+func (v *Version) AtLeast(version string) bool {
+	return v.Compare(version) >= 0
+}
+
+// CompareVersions compares two version strings, such as "3.3.2" and "2.1",
+// according to the precedence rules of Semantic Versioning 2.0.0, and returns
+// -1 if a precedes b, 1 if b precedes a, and 0 if they are equal. Missing
+// minor and patch versions are treated as zero, a pre-release, such as
+// "3.0.0-RC1", precedes the corresponding release, and build metadata,
+// following a plus sign, is ignored. Non-numeric version components are
+// compared as strings, after numeric ones.
+func CompareVersions(a, b string) int {
+	aRelease, aPre := splitVersion(a)
+	bRelease, bPre := splitVersion(b)
+	if c := compareIdentifiers(aRelease, bRelease, "0"); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == nil && bPre == nil:
+		return 0
+	case aPre == nil:
+		return 1
+	case bPre == nil:
+		return -1
+	}
+	return compareIdentifiers(aPre, bPre, "")
+}
+
+// splitVersion returns the dot-separated release and pre-release identifiers
+// of version, without build metadata. pre is nil if version is not a
+// pre-release.
+func splitVersion(version string) (release, pre []string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(version, '+'); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.IndexByte(version, '-'); i >= 0 {
+		pre = strings.Split(version[i+1:], ".")
+		version = version[:i]
+	}
+	return strings.Split(version, "."), pre
+}
+
+// compareIdentifiers compares two lists of version identifiers, in order.
+// If one list is shorter, it is padded with pad, or, if pad is empty,
+// precedes the longer list.
+func compareIdentifiers(a, b []string, pad string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y string
+		switch {
+		case i < len(a) && i < len(b):
+			x, y = a[i], b[i]
+		case pad == "" && i >= len(a):
+			return -1
+		case pad == "" && i >= len(b):
+			return 1
+		case i < len(a):
+			x, y = a[i], pad
+		default:
+			x, y = pad, b[i]
+		}
+		if c := compareIdentifier(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareIdentifier compares two version identifiers, numerically if both
+// are numeric. Numeric identifiers precede non-numeric ones.
+func compareIdentifier(a, b string) int {
+	x, xErr := strconv.ParseUint(a, 10, 64)
+	y, yErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case xErr == nil && yErr == nil:
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case xErr == nil:
+		return -1
+	case yErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// copy returns a copy of v, so that a cached version is not modified by
+// callers.
+func (v *Version) copy() *Version {
+	c := *v
+	c.Features = append([]string(nil), v.Features...)
+	c.RawResponse = append(json.RawMessage(nil), v.RawResponse...)
+	return &c
+}
+
+// Features which may be passed to [Version.HasFeature] and
+// [Client.HasFeature], and which are inferred from the version of CouchDB
+// servers. Any other name is looked up in the features reported by the
+// server, such as "partitioned", "pluggable-storage-engines" or "search".
+const (
+	// FeatureFind is Mango queries, with [DB.Find].
+	FeatureFind = "find"
+	// FeatureBulkGet is the _bulk_get endpoint, used by [DB.BulkGet].
+	FeatureBulkGet = "bulk_get"
+	// FeatureDBsInfo is the _dbs_info endpoint, used by [Client.DBsStats].
+	FeatureDBsInfo = "dbs_info"
+	// FeaturePurge is clustered purge, with [DB.Purge].
+	FeaturePurge = "purge"
+	// FeatureSelectors is the _selector filter of the changes feed.
+	FeatureSelectors = "selector_filter"
+	// FeatureDBUpdates is the _db_updates feed.
+	FeatureDBUpdates = "db_updates"
+	// FeatureClustering is the _cluster_setup and _membership endpoints.
+	FeatureClustering = "clustering"
+)
+
+// inferredFeatures are features which CouchDB servers do not list in the
+// features array, but which are supported by all versions since the given
+// one.
+var inferredFeatures = map[string]string{
+	FeatureFind:       "2.0",
+	FeatureBulkGet:    "2.0",
+	FeatureDBsInfo:    "2.2",
+	FeaturePurge:      "2.3",
+	FeatureSelectors:  "2.0",
+	FeatureDBUpdates:  "1.4",
+	FeatureClustering: "2.0",
+}
+
+// HasFeature returns true if the server lists the named feature in its
+// features, or if name is one of the features inferred from the version of
+// CouchDB servers, such as [FeatureBulkGet], and the server is a version of
+// CouchDB which supports it. A server is recognized as CouchDB, or a
+// compatible server such as Cloudant, by the "couchdb" field of its welcome
+// message, in RawResponse.
+func (v *Version) HasFeature(name string) bool {
+	for _, f := range v.Features {
+		if f == name {
+			return true
+		}
+	}
+	since, ok := inferredFeatures[name]
+	if !ok || !v.isCouchDB() {
+		return false
+	}
+	return v.AtLeast(since)
+}
+
+// isCouchDB returns true if the version was reported by CouchDB, or a server
+// compatible with it.
+func (v *Version) isCouchDB() bool {
+	var welcome struct {
+		CouchDB *string `json:"couchdb"`
+	}
+	if err := json.Unmarshal(v.RawResponse, &welcome); err != nil {
+		return false
+	}
+	return welcome.CouchDB != nil
+}
+
+// HasFeature returns true if the server supports the named feature, as
+// described for [Version.HasFeature]. The server's version is fetched with
+// [Client.Version] only if it has not been already, so HasFeature may be
+// called before each operation which has alternative implementations, to
+// choose between them:
+//
+//	if ok, _ := client.HasFeature(ctx, kivik.FeatureBulkGet); ok {
+//	    // Fetch documents with DB.BulkGet
+//	} else {
+//	    // Fetch documents one at a time with DB.Get
+//	}
+func (c *Client) HasFeature(ctx context.Context, name string) (bool, error) {
+	v, err := c.Version(ctx)
+	if err != nil {
+		return false, err
+	}
+	return v.HasFeature(name), nil
+}
@@ -0,0 +1,271 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.3.2", "3.3.2", 0},
+		{"3.0", "3.0.0", 0},
+		{"v2.1.0", "2.1", 0},
+		{"2.3.1", "3.0.0", -1},
+		{"3.10.0", "3.9.9", 1},
+		{"1.7.1", "2", -1},
+		{"3.0.0-RC1", "3.0.0", -1},
+		{"3.0.0", "3.0.0-RC1", 1},
+		{"3.0.0-RC1", "3.0.0-RC2", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.beta", "1.0.0-alpha.1", 1},
+		{"1.0.0-2", "1.0.0-10", -1},
+		{"2.3.0+abc", "2.3.0+def", 0},
+		{"3.0.0", "3.x", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	v := &Version{Version: "3.2.1"}
+	if !v.AtLeast("3.0") {
+		t.Error("3.2.1 should be at least 3.0")
+	}
+	if !v.AtLeast("3.2.1") {
+		t.Error("3.2.1 should be at least 3.2.1")
+	}
+	if v.AtLeast("3.3") {
+		t.Error("3.2.1 should not be at least 3.3")
+	}
+	if c := v.Compare("2.3.1"); c != 1 {
+		t.Errorf("Unexpected comparison: %d", c)
+	}
+}
+
+func TestVersionHasFeature(t *testing.T) {
+	couch := func(version string, features ...string) *Version {
+		return &Version{
+			Version:     version,
+			Features:    features,
+			RawResponse: []byte(`{"couchdb":"Welcome","version":"` + version + `"}`),
+		}
+	}
+	tests := []struct {
+		name    string
+		version *Version
+		feature string
+		want    bool
+	}{
+		{"listed", couch("3.3.2", "partitioned"), "partitioned", true},
+		{"not listed", couch("3.3.2"), "partitioned", false},
+		{"inferred", couch("2.3.1"), FeatureBulkGet, true},
+		{"too old", couch("1.7.2"), FeatureBulkGet, false},
+		{"purge", couch("2.2.0"), FeaturePurge, false},
+		{"not couchdb", &Version{Version: "4.0.0", RawResponse: []byte(`{}`)}, FeatureBulkGet, false},
+		{"listed by other server", &Version{Version: "1.0", Features: []string{FeatureFind}}, FeatureFind, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.HasFeature(tt.feature); got != tt.want {
+				t.Errorf("HasFeature(%q) = %t, want %t", tt.feature, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientVersionCache(t *testing.T) {
+	var calls int
+	c := &Client{
+		driverClient: &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				calls++
+				if calls == 1 {
+					return nil, errors.New("unavailable")
+				}
+				return &driver.Version{Version: "2.3.1", RawResponse: []byte(`{"couchdb":"Welcome"}`)}, nil
+			},
+		},
+	}
+	ctx := context.Background()
+	if _, err := c.HasFeature(ctx, FeatureBulkGet); err == nil {
+		t.Fatal("expected an error")
+	}
+	ok, err := c.HasFeature(ctx, FeatureBulkGet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected bulk_get to be supported")
+	}
+	if _, err := c.HasFeature(ctx, FeaturePurge); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls to the driver, got %d", calls)
+	}
+	v, err := c.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected Version to use the cache, got %d calls", calls)
+	}
+	v.Version = "modified"
+	if ok, _ := c.HasFeature(ctx, FeatureBulkGet); !ok {
+		t.Error("Cached version was modified")
+	}
+	c.ResetVersion()
+	if _, err := c.HasFeature(ctx, FeatureBulkGet); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls to the driver after reset, got %d", calls)
+	}
+}
+
+func TestClientVersionCalls(t *testing.T) {
+	var calls int32
+	c := &Client{
+		driverClient: &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				atomic.AddInt32(&calls, 1)
+				return &driver.Version{Version: "3.3.2"}, nil
+			},
+		},
+	}
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Version(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := c.HasFeature(ctx, FeatureFind); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to the driver, got %d", calls)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c.version != nil {
+		t.Error("Expected the cached version to be discarded on Close")
+	}
+	if _, err := c.Version(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Unexpected error after Close: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no driver call after Close, got %d calls", calls)
+	}
+}
+
+func TestClientVersionWaitCancelled(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	c := &Client{
+		driverClient: &mock.Client{
+			VersionFunc: func(ctx context.Context) (*driver.Version, error) {
+				atomic.AddInt32(&calls, 1)
+				select {
+				case <-release:
+					return &driver.Version{Version: "3.3.2"}, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			},
+		},
+	}
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Version(context.Background())
+		first <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+
+	// A second caller waits for the first request, but gives up when its own
+	// context is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Version(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to the driver, got %d", calls)
+	}
+}
+
+func TestClientVersionLeaderCancelled(t *testing.T) {
+	var calls int32
+	c := &Client{
+		driverClient: &mock.Client{
+			VersionFunc: func(ctx context.Context) (*driver.Version, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return &driver.Version{Version: "3.3.2"}, nil
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Version(ctx)
+		first <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.Version(context.Background())
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// The second caller retries with its own context.
+	if err := <-second; err != nil {
+		t.Error(err)
+	}
+}
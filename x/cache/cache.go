@@ -0,0 +1,148 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package cache provides a caching wrapper around any [driver.DB].
+//
+// Documents returned by Get are cached by document ID and revision. Since a
+// given revision of a document never changes, a cached document is always
+// valid for the revision it was stored with. When Get is called without an
+// explicit revision, the current revision is first fetched with the
+// underlying driver's GetRev method, typically a cheap HEAD request, and the
+// document body is only fetched if that revision is not already cached.
+//
+// Only plain Get requests, optionally with the "rev" option, are cached.
+// Requests with any other options, such as attachments=true or revs=true,
+// are passed directly to the underlying driver. If the underlying driver does
+// not implement [driver.RevGetter], only requests for an explicit revision are
+// cached.
+//
+// The results of view and Mango queries may also be cached, with
+// [WithQueryCache]. Unlike documents, query results are invalidated by
+// writes, so they are only cached while [DB.Watch] follows the database's
+// changes feed.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// Store stores cached document bodies. Implementations must be safe for
+// concurrent use. Stored values must not be modified.
+type Store interface {
+	// Get returns the value stored under key, and whether it was found.
+	Get(key string) (value []byte, ok bool)
+	// Set stores value under key.
+	Set(key string, value []byte)
+}
+
+// DB is a caching [driver.DB]. In addition to the methods of driver.DB, it
+// implements all of the optional database interfaces used by Kivik, passing
+// calls through to the underlying driver.
+type DB struct {
+	driver.DB
+	passthrough.DBFeatures
+	store       Store
+	queries     *queryCache
+	resultLimit resultLimit
+}
+
+var _ driver.DB = &DB{}
+
+// Option configures a [DB].
+type Option func(*DB)
+
+// New returns db wrapped with a cache backed by store. If store is nil, an
+// in-memory LRU store holding [DefaultCapacity] documents is used.
+func New(db driver.DB, store Store, options ...Option) *DB {
+	if store == nil {
+		store = NewLRU(DefaultCapacity)
+	}
+	cdb := &DB{
+		DB:          db,
+		store:       store,
+		resultLimit: resultLimit{rows: DefaultQueryResultRows, bytes: DefaultQueryResultBytes},
+	}
+	cdb.DBFeatures = passthrough.DBFeatures{Base: db, Self: cdb}
+	for _, opt := range options {
+		opt(cdb)
+	}
+	return cdb
+}
+
+func cacheKey(docID, rev string) string {
+	return rev + "/" + docID
+}
+
+// cacheableRev returns the revision requested by options, and whether the
+// request may be served from the cache.
+func cacheableRev(options map[string]interface{}) (rev string, ok bool) {
+	switch len(options) {
+	case 0:
+		return "", true
+	case 1:
+		rev, ok = options["rev"].(string)
+		return rev, ok
+	}
+	return "", false
+}
+
+// Get returns the requested document, from the cache if possible.
+func (db *DB) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	rev, ok := cacheableRev(options)
+	if !ok {
+		return db.DB.Get(ctx, docID, options)
+	}
+	if rev == "" {
+		revGetter, ok := db.DB.(driver.RevGetter)
+		if !ok {
+			return db.DB.Get(ctx, docID, options)
+		}
+		var err error
+		if rev, err = revGetter.GetRev(ctx, docID, nil); err != nil {
+			return nil, err
+		}
+	}
+	if body, ok := db.store.Get(cacheKey(docID, rev)); ok {
+		return &driver.Document{
+			Rev:  rev,
+			Body: io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+	doc, err := db.DB.Get(ctx, docID, options)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Attachments != nil || doc.Rev == "" {
+		return doc, nil
+	}
+	body, err := io.ReadAll(doc.Body)
+	_ = doc.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	db.store.Set(cacheKey(docID, doc.Rev), body)
+	doc.Body = io.NopCloser(bytes.NewReader(body))
+	return doc, nil
+}
+
+// Find calls the underlying driver's Find method, or returns cached results,
+// if enabled with [WithQueryCache].
+func (db *DB) Find(ctx context.Context, query interface{}, options map[string]interface{}) (driver.Rows, error) {
+	return db.queries.rows(findKey(query, options), options, db.resultLimit, func() (driver.Rows, error) {
+		return db.DBFeatures.Find(ctx, query, options)
+	})
+}
@@ -0,0 +1,189 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func readBody(t *testing.T, doc *driver.Document) string {
+	t.Helper()
+	defer doc.Body.Close() // nolint:errcheck
+	body, err := io.ReadAll(doc.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestGet(t *testing.T) {
+	type step struct {
+		current string // if set, the document's current revision before this step
+		options map[string]interface{}
+		rev     string
+	}
+	type tst struct {
+		db    func(current *string, gets *int) driver.DB
+		steps []step
+		gets  int
+	}
+
+	getFunc := func(current *string, gets *int) func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+		return func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+			*gets++
+			rev := *current
+			if r, ok := opts["rev"].(string); ok {
+				rev = r
+			}
+			return &driver.Document{
+				Rev:  rev,
+				Body: io.NopCloser(strings.NewReader(`{"rev":"` + rev + `"}`)),
+			}, nil
+		}
+	}
+	withRevGetter := func(current *string, gets *int) driver.DB {
+		return &mock.RevGetter{
+			DB: &mock.DB{GetFunc: getFunc(current, gets)},
+			GetRevFunc: func(context.Context, string, map[string]interface{}) (string, error) {
+				return *current, nil
+			},
+		}
+	}
+
+	tests := testy.NewTable()
+	tests.Add("cached after first fetch", tst{
+		db:    withRevGetter,
+		steps: []step{{rev: "1-a"}, {rev: "1-a"}},
+		gets:  1,
+	})
+	tests.Add("new revision refetched", tst{
+		db:    withRevGetter,
+		steps: []step{{rev: "1-a"}, {current: "2-b", rev: "2-b"}, {rev: "2-b"}},
+		gets:  2,
+	})
+	tests.Add("explicit rev without RevGetter", tst{
+		db: func(current *string, gets *int) driver.DB {
+			return &mock.DB{GetFunc: getFunc(current, gets)}
+		},
+		steps: []step{
+			{options: map[string]interface{}{"rev": "1-x"}, rev: "1-x"},
+			{options: map[string]interface{}{"rev": "1-x"}, rev: "1-x"},
+			{rev: "1-a"},
+			{rev: "1-a"},
+		},
+		gets: 3,
+	})
+	tests.Add("other options bypass cache", tst{
+		db: withRevGetter,
+		steps: []step{
+			{options: map[string]interface{}{"revs": true}, rev: "1-a"},
+			{options: map[string]interface{}{"revs": true}, rev: "1-a"},
+		},
+		gets: 2,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		current := "1-a"
+		var gets int
+		db := New(test.db(&current, &gets), nil)
+		for i, step := range test.steps {
+			if step.current != "" {
+				current = step.current
+			}
+			doc, err := db.Get(context.Background(), "foo", step.options)
+			if err != nil {
+				t.Fatalf("step %d: %s", i, err)
+			}
+			if doc.Rev != step.rev {
+				t.Errorf("step %d: unexpected rev %s", i, doc.Rev)
+			}
+			if body, want := readBody(t, doc), `{"rev":"`+step.rev+`"}`; body != want {
+				t.Errorf("step %d: unexpected body %s", i, body)
+			}
+		}
+		if gets != test.gets {
+			t.Errorf("Unexpected number of fetches: %d", gets)
+		}
+	})
+}
+
+func TestPassthrough(t *testing.T) {
+	t.Run("implemented", func(t *testing.T) {
+		db := New(&mock.Flusher{
+			DB:        &mock.DB{},
+			FlushFunc: func(context.Context) error { return nil },
+		}, nil)
+		if err := db.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("counter", func(t *testing.T) {
+		db := New(&mock.Counter{
+			DB: &mock.DB{},
+			CountFunc: func(context.Context, interface{}, map[string]interface{}) (int64, error) {
+				return 3, nil
+			},
+		}, nil)
+		count, err := db.Count(context.Background(), map[string]interface{}{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 3 {
+			t.Errorf("Unexpected count: %d", count)
+		}
+	})
+	t.Run("not implemented", func(t *testing.T) {
+		db := New(&mock.DB{}, nil)
+		_, err := db.Find(context.Background(), nil, nil)
+		testy.StatusError(t, "kivik: Find not supported by driver", http.StatusNotImplemented, err)
+	})
+	t.Run("BulkDocs fallback", func(t *testing.T) {
+		var puts, creates int
+		db := New(&mock.DB{
+			PutFunc: func(_ context.Context, docID string, _ interface{}, _ map[string]interface{}) (string, error) {
+				puts++
+				return "1-" + docID, nil
+			},
+			CreateDocFunc: func(context.Context, interface{}, map[string]interface{}) (string, string, error) {
+				creates++
+				return "new", "1-new", nil
+			},
+		}, nil)
+		results, err := db.BulkDocs(context.Background(), []interface{}{
+			map[string]string{"_id": "foo"},
+			map[string]string{"name": "bar"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []driver.BulkResult{
+			{ID: "foo", Rev: "1-foo"},
+			{ID: "new", Rev: "1-new"},
+		}
+		if d := testy.DiffInterface(want, results); d != nil {
+			t.Error(d)
+		}
+		if puts != 1 || creates != 1 {
+			t.Errorf("Unexpected puts=%d creates=%d", puts, creates)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	store := NewLRU(2)
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+	if _, ok := store.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	store.Set("c", []byte("3")) // evicts b, the least recently used
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := store.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	store.Set("a", []byte("4"))
+	if v, _ := store.Get("a"); string(v) != "4" {
+		t.Errorf("Unexpected value: %s", v)
+	}
+}
@@ -0,0 +1,455 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// DefaultQueryCapacity is the number of query results held when
+// [WithQueryCache] is called with a capacity less than 1.
+const DefaultQueryCapacity = 100
+
+// DefaultQueryResultRows and DefaultQueryResultBytes are the limits on the
+// size of a cached query result used when [WithQueryResultLimit] is not
+// given, or is given a limit less than 1.
+const (
+	DefaultQueryResultRows  = 1000
+	DefaultQueryResultBytes = 1 << 20
+)
+
+// WithQueryCache returns an option which enables caching of the results of
+// Query and Find, holding at most capacity results, and evicting the least
+// recently used when full. Results are held in memory in full, so this is
+// best suited to small, frequently repeated queries, such as those behind
+// dashboards. Results larger than the limit set by [WithQueryResultLimit]
+// are streamed from the underlying driver, and not cached.
+//
+// Cached results are discarded when the changes feed reports a write to the
+// database, so results are only cached while [DB.Watch] is running. A query
+// with a "partition" option is only invalidated by writes to documents in
+// that partition, that is, whose IDs begin with the partition name followed
+// by a colon.
+func WithQueryCache(capacity int) Option {
+	if capacity < 1 {
+		capacity = DefaultQueryCapacity
+	}
+	return func(db *DB) {
+		db.queries = &queryCache{
+			capacity: capacity,
+			ll:       list.New(),
+			items:    make(map[string]*list.Element),
+		}
+	}
+}
+
+// WithQueryResultLimit returns an option which limits each result cached by
+// [WithQueryCache] to maxRows rows, and to maxBytes bytes of document IDs,
+// keys, values and documents. Once a result exceeds either limit, the rows
+// already read are returned, followed by the rest of the result, directly
+// from the underlying driver. A limit less than 1 means the default.
+func WithQueryResultLimit(maxRows, maxBytes int) Option {
+	if maxRows < 1 {
+		maxRows = DefaultQueryResultRows
+	}
+	if maxBytes < 1 {
+		maxBytes = DefaultQueryResultBytes
+	}
+	return func(db *DB) {
+		db.resultLimit = resultLimit{rows: maxRows, bytes: maxBytes}
+	}
+}
+
+// resultLimit is the largest query result which may be cached.
+type resultLimit struct {
+	rows, bytes int
+}
+
+// QueryStats are the cumulative counters of the query cache.
+type QueryStats struct {
+	// Hits is the number of queries answered from the cache.
+	Hits int64
+	// Misses is the number of queries passed to the underlying driver while
+	// the cache was active.
+	Misses int64
+	// Invalidations is the number of cached results discarded due to writes.
+	Invalidations int64
+	// Oversized is the number of results not cached because they exceeded
+	// the limit set by [WithQueryResultLimit].
+	Oversized int64
+	// Entries is the number of results currently cached.
+	Entries int
+}
+
+// QueryStats returns the query cache's counters. It returns zero stats if
+// query caching is not enabled.
+func (db *DB) QueryStats() QueryStats {
+	return db.queries.stats()
+}
+
+// Watch follows the database's continuous changes feed, from the current
+// update sequence, and discards cached query results affected by each write,
+// until ctx is cancelled or the feed fails. Query results are only cached
+// while Watch is running; when it returns, the query cache is emptied. Watch
+// returns nil if ctx was cancelled, and an error if query caching is not
+// enabled.
+// Watch should be restarted, after a delay, if it fails.
+func (db *DB) Watch(ctx context.Context) error {
+	if db.queries == nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: query cache not enabled"}
+	}
+	changes, err := db.DB.Changes(ctx, map[string]interface{}{
+		"feed":  "continuous",
+		"since": "now",
+	})
+	if err != nil {
+		return err
+	}
+	defer changes.Close() // nolint:errcheck
+	db.queries.activate()
+	defer db.queries.deactivate()
+	var change driver.Change
+	for {
+		err := changes.Next(&change)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == io.EOF {
+			return errors.New("kivik: changes feed closed")
+		}
+		if err != nil {
+			return err
+		}
+		db.queries.invalidate(change.ID)
+	}
+}
+
+// Query calls the underlying driver's Query method, or returns cached
+// results, if enabled with [WithQueryCache].
+func (db *DB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	return db.queries.rows(queryKey(ddoc, view, options), options, db.resultLimit, func() (driver.Rows, error) {
+		return db.DB.Query(ctx, ddoc, view, options)
+	})
+}
+
+func queryKey(ddoc, view string, options map[string]interface{}) string {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return ""
+	}
+	return "query\x00" + ddoc + "\x00" + view + "\x00" + string(opts)
+}
+
+func findKey(query interface{}, options map[string]interface{}) string {
+	q, err := json.Marshal(query)
+	if err != nil {
+		return ""
+	}
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return ""
+	}
+	return "find\x00" + string(q) + "\x00" + string(opts)
+}
+
+// queryResult is a cached query result.
+type queryResult struct {
+	key       string
+	partition string
+	rows      []cachedRow
+	updateSeq string
+	offset    int64
+	totalRows int64
+	warning   string
+	bookmark  string
+}
+
+type cachedRow struct {
+	id    string
+	key   json.RawMessage
+	value []byte
+	doc   []byte
+	err   error
+}
+
+// queryCache is a least-recently-used cache of query results. A nil
+// *queryCache caches nothing.
+type queryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	// active is true while the changes feed is being watched.
+	active bool
+	// generation is incremented by each invalidation, so that results
+	// fetched before a write are not cached after it.
+	generation uint64
+
+	hits, misses, invalidations, oversized int64
+}
+
+func (c *queryCache) stats() QueryStats {
+	if c == nil {
+		return QueryStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return QueryStats{
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+		Oversized:     c.oversized,
+		Entries:       c.ll.Len(),
+	}
+}
+
+func (c *queryCache) activate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = true
+}
+
+func (c *queryCache) deactivate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = false
+	c.generation++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// invalidate discards the results which may be affected by a write to docID.
+func (c *queryCache) invalidate(docID string) {
+	partition := ""
+	if i := strings.Index(docID, ":"); i > 0 {
+		partition = docID[:i]
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		result := el.Value.(*queryResult)
+		if result.partition == "" || result.partition == partition {
+			c.ll.Remove(el)
+			delete(c.items, result.key)
+			c.invalidations++
+		}
+		el = next
+	}
+}
+
+// rows returns the cached result for key, or calls fetch, and caches its
+// result if possible and no larger than limit.
+func (c *queryCache) rows(key string, options map[string]interface{}, limit resultLimit, fetch func() (driver.Rows, error)) (driver.Rows, error) {
+	if c == nil || key == "" {
+		return fetch()
+	}
+	c.mu.Lock()
+	if !c.active {
+		c.mu.Unlock()
+		return fetch()
+	}
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		result := el.Value.(*queryResult)
+		c.mu.Unlock()
+		return &replayRows{queryResult: result, remaining: result.rows}, nil
+	}
+	c.misses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	rows, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	result, stream, err := readResult(rows, limit)
+	if err != nil {
+		return nil, err
+	}
+	if stream != nil {
+		c.mu.Lock()
+		c.oversized++
+		c.mu.Unlock()
+		return stream, nil
+	}
+	result.key = key
+	result.partition, _ = options["partition"].(string)
+	c.store(result, generation)
+	return &replayRows{queryResult: result, remaining: result.rows}, nil
+}
+
+// store caches result, unless the cache has been invalidated since
+// generation.
+func (c *queryCache) store(result *queryResult, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active || c.generation != generation {
+		return
+	}
+	if el, ok := c.items[result.key]; ok {
+		el.Value = result
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[result.key] = c.ll.PushFront(result)
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*queryResult).key)
+	}
+}
+
+// readResult reads and closes rows. If the result exceeds limit, reading
+// stops, and instead of a result, rows are returned which stream the rest of
+// the result from the driver, after the rows already read.
+func readResult(rows driver.Rows, limit resultLimit) (result *queryResult, stream driver.Rows, err error) {
+	defer func() {
+		if stream == nil {
+			_ = rows.Close()
+		}
+	}()
+	result = &queryResult{}
+	var size int
+	var row driver.Row
+	for {
+		row = driver.Row{}
+		err := rows.Next(&row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		cached := cachedRow{id: row.ID, key: append(json.RawMessage(nil), row.Key...), err: row.Error}
+		if row.Value != nil {
+			if cached.value, err = io.ReadAll(row.Value); err != nil {
+				return nil, nil, err
+			}
+		}
+		if row.Doc != nil {
+			if cached.doc, err = io.ReadAll(row.Doc); err != nil {
+				return nil, nil, err
+			}
+		}
+		result.rows = append(result.rows, cached)
+		size += len(cached.id) + len(cached.key) + len(cached.value) + len(cached.doc)
+		if len(result.rows) > limit.rows || size > limit.bytes {
+			return nil, &streamRows{Rows: rows, buffered: result.rows}, nil
+		}
+	}
+	// Metadata is only available once the rows have been read.
+	result.updateSeq = rows.UpdateSeq()
+	result.offset = rows.Offset()
+	result.totalRows = rows.TotalRows()
+	if w, ok := rows.(driver.RowsWarner); ok {
+		result.warning = w.Warning()
+	}
+	if b, ok := rows.(driver.Bookmarker); ok {
+		result.bookmark = b.Bookmark()
+	}
+	return result, nil, nil
+}
+
+// row returns the cached row as a driver.Row.
+func (c cachedRow) row() driver.Row {
+	row := driver.Row{ID: c.id, Key: c.key, Error: c.err}
+	if c.value != nil {
+		row.Value = bytes.NewReader(c.value)
+	}
+	if c.doc != nil {
+		row.Doc = bytes.NewReader(c.doc)
+	}
+	return row
+}
+
+// streamRows returns the rows already read from a result too large to cache,
+// followed by the rest of the underlying rows.
+type streamRows struct {
+	driver.Rows
+	buffered []cachedRow
+}
+
+var (
+	_ driver.Rows       = &streamRows{}
+	_ driver.RowsWarner = &streamRows{}
+	_ driver.Bookmarker = &streamRows{}
+)
+
+func (r *streamRows) Next(row *driver.Row) error {
+	if len(r.buffered) == 0 {
+		return r.Rows.Next(row)
+	}
+	*row = r.buffered[0].row()
+	r.buffered = r.buffered[1:]
+	return nil
+}
+
+func (r *streamRows) Warning() string {
+	if w, ok := r.Rows.(driver.RowsWarner); ok {
+		return w.Warning()
+	}
+	return ""
+}
+
+func (r *streamRows) Bookmark() string {
+	if b, ok := r.Rows.(driver.Bookmarker); ok {
+		return b.Bookmark()
+	}
+	return ""
+}
+
+// replayRows returns the rows of a cached result.
+type replayRows struct {
+	*queryResult
+	remaining []cachedRow
+}
+
+var (
+	_ driver.Rows       = &replayRows{}
+	_ driver.RowsWarner = &replayRows{}
+	_ driver.Bookmarker = &replayRows{}
+)
+
+func (r *replayRows) Next(row *driver.Row) error {
+	if len(r.remaining) == 0 {
+		return io.EOF
+	}
+	*row = r.remaining[0].row()
+	r.remaining = r.remaining[1:]
+	return nil
+}
+
+func (r *replayRows) Close() error {
+	r.remaining = nil
+	return nil
+}
+
+func (r *replayRows) UpdateSeq() string { return r.updateSeq }
+func (r *replayRows) Offset() int64     { return r.offset }
+func (r *replayRows) TotalRows() int64  { return r.totalRows }
+func (r *replayRows) Warning() string   { return r.warning }
+func (r *replayRows) Bookmark() string  { return r.bookmark }
@@ -0,0 +1,281 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// queryDB returns a database whose views and Find queries each return a
+// single row, counting the calls made, and whose continuous changes feed
+// reports the document IDs sent to changes.
+func queryDB(queries *int, changes <-chan string) driver.DB {
+	rows := func() driver.Rows {
+		*queries++
+		done := false
+		return &mock.Rows{
+			NextFunc: func(r *driver.Row) error {
+				if done {
+					return io.EOF
+				}
+				*r = driver.Row{
+					ID:    "cow",
+					Key:   []byte(`"cow"`),
+					Value: strings.NewReader(`"value"`),
+					Doc:   strings.NewReader(`{"_id":"cow"}`),
+				}
+				done = true
+				return nil
+			},
+			TotalRowsFunc: func() int64 { return 42 },
+		}
+	}
+	return &mock.Finder{
+		DB: &mock.DB{
+			QueryFunc: func(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+				return rows(), nil
+			},
+			ChangesFunc: func(ctx context.Context, opts map[string]interface{}) (driver.Changes, error) {
+				if opts["feed"] != "continuous" || opts["since"] != "now" {
+					return nil, errors.New("unexpected options")
+				}
+				return &mock.Changes{
+					NextFunc: func(ch *driver.Change) error {
+						select {
+						case <-ctx.Done():
+							return ctx.Err()
+						case id, ok := <-changes:
+							if !ok {
+								return io.EOF
+							}
+							*ch = driver.Change{ID: id}
+							return nil
+						}
+					},
+				}, nil
+			},
+		},
+		FindFunc: func(context.Context, interface{}, map[string]interface{}) (driver.Rows, error) {
+			return rows(), nil
+		},
+	}
+}
+
+func readRows(t *testing.T, rows driver.Rows) []string {
+	t.Helper()
+	defer rows.Close() // nolint:errcheck
+	var result []string
+	var row driver.Row
+	for rows.Next(&row) == nil {
+		value, _ := io.ReadAll(row.Value)
+		doc, _ := io.ReadAll(row.Doc)
+		result = append(result, row.ID+" "+string(row.Key)+" "+string(value)+" "+string(doc))
+	}
+	if rows.TotalRows() != 42 {
+		t.Errorf("Unexpected total rows: %d", rows.TotalRows())
+	}
+	return result
+}
+
+// waitStats waits for the stats of db to satisfy ok.
+func waitStats(t *testing.T, db *DB, ok func(QueryStats) bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for !ok(db.QueryStats()) {
+		select {
+		case <-timeout:
+			t.Fatalf("timed out; stats: %+v", db.QueryStats())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	var queries int
+	changes := make(chan string)
+	db := New(queryDB(&queries, changes), nil, WithQueryCache(0))
+	ctx := context.Background()
+	want := []string{`cow "cow" "value" {"_id":"cow"}`}
+
+	query := func(options map[string]interface{}) {
+		t.Helper()
+		rows, err := db.Query(ctx, "foo", "bar", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(want, readRows(t, rows)); d != nil {
+			t.Error(d)
+		}
+	}
+
+	// Nothing is cached until the changes feed is watched.
+	query(nil)
+	query(nil)
+	if queries != 2 {
+		t.Errorf("Expected 2 queries before watching, got %d", queries)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- db.Watch(watchCtx) }()
+	waitStats(t, db, func(QueryStats) bool {
+		db.queries.mu.Lock()
+		defer db.queries.mu.Unlock()
+		return db.queries.active
+	})
+
+	query(nil)
+	query(nil)
+	query(map[string]interface{}{"partition": "pig"})
+	query(map[string]interface{}{"partition": "pig"})
+	rows, err := db.Find(ctx, map[string]interface{}{"selector": map[string]interface{}{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readRows(t, rows)
+	if queries != 5 {
+		t.Errorf("Expected 5 queries, got %d", queries)
+	}
+	if d := testy.DiffInterface(QueryStats{Hits: 2, Misses: 3, Entries: 3}, db.QueryStats()); d != nil {
+		t.Error(d)
+	}
+
+	// A write to another partition leaves the partitioned result cached.
+	changes <- "cow:bessie"
+	waitStats(t, db, func(s QueryStats) bool { return s.Invalidations == 2 })
+	query(map[string]interface{}{"partition": "pig"})
+	if queries != 5 {
+		t.Errorf("Expected the partitioned query to be cached")
+	}
+	changes <- "pig:wilbur"
+	waitStats(t, db, func(s QueryStats) bool { return s.Invalidations == 3 })
+	query(map[string]interface{}{"partition": "pig"})
+	if queries != 6 {
+		t.Errorf("Expected the partitioned query to be refetched")
+	}
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Fatal(err)
+	}
+	if entries := db.QueryStats().Entries; entries != 0 {
+		t.Errorf("Expected the cache to be emptied, have %d entries", entries)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("not enabled", func(t *testing.T) {
+		err := New(&mock.DB{}, nil).Watch(context.Background())
+		testy.StatusError(t, "kivik: query cache not enabled", http.StatusBadRequest, err)
+	})
+	t.Run("feed closed", func(t *testing.T) {
+		var queries int
+		changes := make(chan string)
+		close(changes)
+		err := New(queryDB(&queries, changes), nil, WithQueryCache(1)).Watch(context.Background())
+		testy.Error(t, "kivik: changes feed closed", err)
+	})
+	t.Run("feed error", func(t *testing.T) {
+		db := New(&mock.DB{
+			ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+				return nil, errors.New("no continuous feed")
+			},
+		}, nil, WithQueryCache(1))
+		testy.Error(t, "no continuous feed", db.Watch(context.Background()))
+	})
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	c := New(&mock.DB{}, nil, WithQueryCache(2)).queries
+	c.active = true
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, err := c.rows(key, nil, resultLimit{rows: 1, bytes: 1}, func() (driver.Rows, error) { return &mock.Rows{}, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.items["b"]; ok {
+		t.Error("Expected b to be evicted")
+	}
+	if d := testy.DiffInterface(QueryStats{Hits: 1, Misses: 3, Entries: 2}, c.stats()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestQueryCacheResultLimit(t *testing.T) {
+	var queries int
+	db := New(queryDB(&queries, nil), nil, WithQueryCache(1), WithQueryResultLimit(1, 10))
+	db.queries.active = true
+	want := []string{`cow "cow" "value" {"_id":"cow"}`}
+	for i := 0; i < 2; i++ {
+		rows, err := db.Query(context.Background(), "foo", "bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(want, readRows(t, rows)); d != nil {
+			t.Error(d)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("Expected the oversized result to be refetched, got %d queries", queries)
+	}
+	if d := testy.DiffInterface(QueryStats{Misses: 2, Oversized: 2}, db.QueryStats()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestQueryCacheKeyCopied(t *testing.T) {
+	c := New(&mock.DB{}, nil, WithQueryCache(1)).queries
+	c.active = true
+	key := []byte(`"cow"`)
+	fetch := func() (driver.Rows, error) {
+		done := false
+		return &mock.Rows{
+			NextFunc: func(r *driver.Row) error {
+				if done {
+					// The driver reuses its buffer for the next row.
+					copy(key, `"pig"`)
+					return io.EOF
+				}
+				done = true
+				*r = driver.Row{ID: "cow", Key: key}
+				return nil
+			},
+		}, nil
+	}
+	limit := resultLimit{rows: DefaultQueryResultRows, bytes: DefaultQueryResultBytes}
+	if _, err := c.rows("a", nil, limit, fetch); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := c.rows("a", nil, limit, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var row driver.Row
+	if err := rows.Next(&row); err != nil {
+		t.Fatal(err)
+	}
+	if string(row.Key) != `"cow"` {
+		t.Errorf("Unexpected cached key: %s", row.Key)
+	}
+}
@@ -0,0 +1,470 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package compaction triggers database and view compaction on a schedule.
+//
+// CouchDB compacts databases automatically, when configured to, but many
+// installations prefer to compact at quiet times of day. A [Scheduler]
+// compacts a set of databases, and optionally their views, within one or more
+// daily time windows, with a bounded number of compactions running at once:
+//
+//	s := compaction.New(client, compaction.Config{
+//	    DBs:         []string{"orders", "invoices"},
+//	    Windows:     []compaction.Window{{Start: 2 * time.Hour, End: 5 * time.Hour}},
+//	    Concurrency: 2,
+//	    Views:       true,
+//	    Cleanup:     true,
+//	    OnProgress: func(p compaction.Progress) {
+//	        log.Printf("%s %s %s: %d%%", p.Action, p.DB, p.DDoc, p.Progress)
+//	    },
+//	})
+//	go s.Run(ctx)
+//
+// CouchDB compacts in the background, so after triggering each compaction the
+// scheduler polls [kivik.Client.ActiveTasks] to report progress, and to wait
+// for the compaction to finish before starting the next one. Drivers which do
+// not support active tasks are polled with [kivik.DB.Stats] instead, which
+// reports only whether a database compaction is running.
+//
+// Databases not yet started when a window closes are skipped until the next
+// window. Compactions already running are left to finish.
+package compaction
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultInterval is the time between passes, when Config.Windows and
+// Config.Interval are unset.
+const DefaultInterval = 24 * time.Hour
+
+// DefaultPollInterval is the time between checks for the completion of a
+// compaction, when Config.PollInterval is unset.
+const DefaultPollInterval = 10 * time.Second
+
+const day = 24 * time.Hour
+
+// Window is a daily time window, given as offsets from midnight. A window
+// whose End is not after its Start extends past midnight, so that
+// {Start: 22 * time.Hour, End: 2 * time.Hour} runs from 22:00 to 02:00.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses a window in the form "HH:MM-HH:MM", such as
+// "22:30-02:00".
+func ParseWindow(s string) (Window, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return Window{}, fmt.Errorf("compaction: invalid window %q", s)
+	}
+	var w Window
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return Window{}, fmt.Errorf("compaction: invalid window %q: %w", s, err)
+		}
+		offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.Start = offset
+		} else {
+			w.End = offset
+		}
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("compaction: empty window %q", s)
+	}
+	return w, nil
+}
+
+// bounds returns the start and end of the window which opens on the day
+// beginning at midnight.
+func (w Window) bounds(midnight time.Time) (time.Time, time.Time) {
+	start := midnight.Add(w.Start)
+	end := midnight.Add(w.End)
+	if !end.After(start) {
+		end = end.Add(day)
+	}
+	return start, end
+}
+
+// Action is a maintenance operation performed on a database.
+type Action string
+
+// The actions performed by a [Scheduler].
+const (
+	ActionCompact     Action = "compact"
+	ActionCompactView Action = "compact_view"
+	ActionViewCleanup Action = "view_cleanup"
+)
+
+// Progress reports the progress of a single action.
+type Progress struct {
+	DB string
+	// DDoc is the name of the design document whose views are being
+	// compacted, without the _design/ prefix, for ActionCompactView.
+	DDoc   string
+	Action Action
+	// Progress is the estimated completion, as a percentage, as reported by
+	// the server's active tasks. It is 0 if the server does not report
+	// progress.
+	Progress int
+	// Done is true once the action has completed, or failed.
+	Done bool
+	// Err is the error which caused the action to fail.
+	Err error
+}
+
+// Result reports the outcome of a single pass over the databases.
+type Result struct {
+	// Completed lists the databases which were compacted.
+	Completed []string
+	// Skipped lists the databases which were not started, because the window
+	// closed, or the context was cancelled.
+	Skipped []string
+	// Failed holds the error for each database which failed.
+	Failed map[string]error
+}
+
+// Config configures a [Scheduler].
+type Config struct {
+	// DBs are the databases to compact. If empty, all databases are
+	// compacted.
+	DBs []string
+	// Windows are the daily time windows within which compactions may be
+	// started. If empty, compactions may start at any time.
+	Windows []Window
+	// Location is the time zone of Windows. It defaults to [time.Local].
+	Location *time.Location
+	// Concurrency is the maximum number of databases compacted at once. It
+	// defaults to 1.
+	Concurrency int
+	// Views causes the views of each design document to be compacted, after
+	// the database.
+	Views bool
+	// Cleanup causes stale view index files to be removed, after compaction.
+	Cleanup bool
+	// Interval is the time between passes made by [Scheduler.Run], when
+	// Windows is empty. With windows, one pass is made per window.
+	Interval time.Duration
+	// PollInterval is the time between checks for the completion of a
+	// compaction.
+	PollInterval time.Duration
+	// OnProgress, if set, is called as each action starts, progresses and
+	// completes. It may be called concurrently when Concurrency is greater
+	// than 1.
+	OnProgress func(Progress)
+	// OnRun, if set, is called after each pass made by [Scheduler.Run], with
+	// its result, or error.
+	OnRun func(*Result, error)
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+}
+
+// Scheduler compacts databases within configured time windows.
+type Scheduler struct {
+	client *kivik.Client
+	config Config
+}
+
+// New returns a scheduler which compacts databases on client.
+func New(client *kivik.Client, config Config) *Scheduler {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Scheduler{client: client, config: config}
+}
+
+// midnight returns the start of the day containing t, offset by days.
+func (s *Scheduler) midnight(t time.Time, days int) time.Time {
+	t = t.In(s.config.Location)
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, s.config.Location)
+}
+
+// window returns the end of the window containing t, and whether there is
+// one.
+func (s *Scheduler) window(t time.Time) (time.Time, bool) {
+	var end time.Time
+	var found bool
+	for _, w := range s.config.Windows {
+		for days := -1; days <= 0; days++ {
+			start, e := w.bounds(s.midnight(t, days))
+			if !t.Before(start) && t.Before(e) && e.After(end) {
+				end, found = e, true
+			}
+		}
+	}
+	return end, found
+}
+
+// InWindow returns true if compactions may be started at t.
+func (s *Scheduler) InWindow(t time.Time) bool {
+	if len(s.config.Windows) == 0 {
+		return true
+	}
+	_, ok := s.window(t)
+	return ok
+}
+
+// NextWindow returns t if it is within a window, or otherwise the time at
+// which the next window opens.
+func (s *Scheduler) NextWindow(t time.Time) time.Time {
+	if s.InWindow(t) {
+		return t
+	}
+	var next time.Time
+	for _, w := range s.config.Windows {
+		for days := 0; days <= 1; days++ {
+			start, _ := w.bounds(s.midnight(t, days))
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// nextPass returns the time of the pass following one which finished at t.
+func (s *Scheduler) nextPass(t time.Time) time.Time {
+	if len(s.config.Windows) == 0 {
+		return t.Add(s.config.Interval)
+	}
+	if end, ok := s.window(t); ok {
+		t = end
+	}
+	return s.NextWindow(t)
+}
+
+// Run makes a pass over the databases in each window, or every
+// Config.Interval if no windows are configured, until ctx is cancelled.
+// Errors from individual passes are reported to Config.OnRun, and do not stop
+// Run.
+func (s *Scheduler) Run(ctx context.Context) {
+	next := s.NextWindow(s.config.Now())
+	for {
+		if !s.sleepUntil(ctx, next) {
+			return
+		}
+		result, err := s.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if s.config.OnRun != nil {
+			s.config.OnRun(result, err)
+		}
+		next = s.nextPass(s.config.Now())
+	}
+}
+
+// sleepUntil waits until t, and returns false if ctx is cancelled first.
+func (s *Scheduler) sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(t.Sub(s.config.Now()))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// RunOnce compacts each database once, starting no new compactions once the
+// current window has closed. An error is returned only if the databases
+// could not be listed, or ctx is cancelled; failures of individual databases
+// are reported in the result.
+func (s *Scheduler) RunOnce(ctx context.Context) (*Result, error) {
+	dbs := s.config.DBs
+	if len(dbs) == 0 {
+		var err error
+		if dbs, err = s.client.AllDBs(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result := &Result{Failed: map[string]error{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				if ctx.Err() != nil || !s.InWindow(s.config.Now()) {
+					mu.Lock()
+					result.Skipped = append(result.Skipped, name)
+					mu.Unlock()
+					continue
+				}
+				err := s.compact(ctx, name)
+				mu.Lock()
+				if err != nil {
+					result.Failed[name] = err
+				} else {
+					result.Completed = append(result.Completed, name)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range dbs {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	sort.Strings(result.Completed)
+	sort.Strings(result.Skipped)
+	return result, ctx.Err()
+}
+
+func (s *Scheduler) report(p Progress) {
+	if s.config.OnProgress != nil {
+		s.config.OnProgress(p)
+	}
+}
+
+// compact performs all configured actions on the named database.
+func (s *Scheduler) compact(ctx context.Context, name string) error {
+	db := s.client.DB(name)
+	defer db.Close() // nolint:errcheck
+	err := s.do(ctx, db, Progress{DB: name, Action: ActionCompact}, func() error {
+		return db.Compact(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if s.config.Views {
+		ddocs, err := designDocs(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, ddoc := range ddocs {
+			ddoc := ddoc
+			err := s.do(ctx, db, Progress{DB: name, DDoc: ddoc, Action: ActionCompactView}, func() error {
+				return db.CompactView(ctx, ddoc)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.Cleanup {
+		return s.do(ctx, db, Progress{DB: name, Action: ActionViewCleanup}, func() error {
+			return db.ViewCleanup(ctx)
+		})
+	}
+	return nil
+}
+
+// do starts an action with fn, then waits for it to complete, reporting its
+// progress.
+func (s *Scheduler) do(ctx context.Context, db *kivik.DB, p Progress, fn func() error) error {
+	s.report(p)
+	err := fn()
+	if err == nil && p.Action != ActionViewCleanup {
+		err = s.wait(ctx, db, p)
+	}
+	p.Done, p.Err = true, err
+	if err == nil {
+		p.Progress = 100
+	}
+	s.report(p)
+	return err
+}
+
+// wait polls until the compaction described by p is no longer running.
+func (s *Scheduler) wait(ctx context.Context, db *kivik.DB, p Progress) error {
+	for {
+		running, progress, err := s.running(ctx, db, p)
+		if err != nil || !running {
+			return err
+		}
+		p.Progress = progress
+		s.report(p)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.config.PollInterval):
+		}
+	}
+}
+
+// running reports whether the compaction described by p is running, and its
+// average progress across shards.
+func (s *Scheduler) running(ctx context.Context, db *kivik.DB, p Progress) (bool, int, error) {
+	taskType, ddoc := "database_compaction", ""
+	if p.Action == ActionCompactView {
+		taskType, ddoc = "view_compaction", "_design/"+p.DDoc
+	}
+	tasks, err := s.client.ActiveTasks(ctx)
+	if err != nil && kivik.HTTPStatus(err) != http.StatusNotImplemented {
+		return false, 0, err
+	}
+	var count, total int
+	for _, task := range tasks {
+		if task.Type == taskType && task.DBName() == p.DB && task.DesignDocument == ddoc {
+			count++
+			total += task.Progress
+		}
+	}
+	if count > 0 {
+		return true, total / count, nil
+	}
+	if p.Action != ActionCompact {
+		return false, 0, nil
+	}
+	// The task may not be listed yet, or active tasks may be unsupported.
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	return stats.CompactRunning, 0, nil
+}
+
+// designDocs returns the names of the design documents in db, without the
+// _design/ prefix.
+func designDocs(ctx context.Context, db *kivik.DB) ([]string, error) {
+	rs := db.AllDocs(ctx, kivik.Params(map[string]interface{}{
+		"startkey": "_design/",
+		"endkey":   "_design0",
+	}))
+	defer rs.Close() // nolint:errcheck
+	var ddocs []string
+	for rs.Next() {
+		id, err := rs.ID()
+		if err != nil {
+			return nil, err
+		}
+		ddocs = append(ddocs, strings.TrimPrefix(id, "_design/"))
+	}
+	return ddocs, rs.Err()
+}
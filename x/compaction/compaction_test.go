@@ -0,0 +1,345 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package compaction
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+)
+
+// testClient adds active tasks to the memory driver, returning each of tasks
+// in turn, and then none, and records the maintenance actions performed.
+type testClient struct {
+	driver.Client
+
+	mu      sync.Mutex
+	tasks   []string
+	actions []string
+}
+
+func (c *testClient) ActiveTasks(context.Context) (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.tasks) == 0 {
+		return json.RawMessage(`[]`), nil
+	}
+	tasks := c.tasks[0]
+	c.tasks = c.tasks[1:]
+	return json.RawMessage(tasks), nil
+}
+
+func (c *testClient) record(action string) {
+	c.mu.Lock()
+	c.actions = append(c.actions, action)
+	c.mu.Unlock()
+}
+
+func (c *testClient) DB(name string, options map[string]interface{}) (driver.DB, error) {
+	db, err := c.Client.DB(name, options)
+	if err != nil {
+		return nil, err
+	}
+	return &testDB{DB: db, client: c, name: name}, nil
+}
+
+type testDB struct {
+	driver.DB
+	client *testClient
+	name   string
+}
+
+func (d *testDB) Compact(ctx context.Context) error {
+	d.client.record("compact " + d.name)
+	return d.DB.Compact(ctx)
+}
+
+func (d *testDB) CompactView(ctx context.Context, ddoc string) error {
+	d.client.record("compact_view " + d.name + " " + ddoc)
+	return d.DB.CompactView(ctx, ddoc)
+}
+
+func (d *testDB) ViewCleanup(ctx context.Context) error {
+	d.client.record("view_cleanup " + d.name)
+	return d.DB.ViewCleanup(ctx)
+}
+
+var now = time.Date(2023, 11, 14, 23, 0, 0, 0, time.UTC)
+
+func newClient(t *testing.T, tasks ...string) (*kivik.Client, *testClient) {
+	t.Helper()
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"orders", "invoices", "users"} {
+		if err := memory.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := memory.DB("orders").Put(ctx, "_design/reports", map[string]interface{}{"views": map[string]interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	tc := &testClient{Client: proxydb.NewClient(memory), tasks: tasks}
+	client, err := kivik.NewClientFromDriverClient(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, tc
+}
+
+func TestParseWindow(t *testing.T) {
+	type tt struct {
+		input string
+		want  Window
+		err   string
+	}
+	tests := testy.NewTable()
+	tests.Add("daytime", tt{
+		input: "12:00-13:30",
+		want:  Window{Start: 12 * time.Hour, End: 13*time.Hour + 30*time.Minute},
+	})
+	tests.Add("overnight", tt{
+		input: "22:00 - 02:00",
+		want:  Window{Start: 22 * time.Hour, End: 2 * time.Hour},
+	})
+	tests.Add("empty", tt{
+		input: "02:00-02:00",
+		err:   `compaction: empty window "02:00-02:00"`,
+	})
+	tests.Add("no end", tt{
+		input: "02:00",
+		err:   `compaction: invalid window "02:00"`,
+	})
+	tests.Add("invalid time", tt{
+		input: "2am-4am",
+		err:   `compaction: invalid window "2am-4am": parsing time "2am" as "15:04": cannot parse "am" as ":"`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		got, err := ParseWindow(tt.input)
+		if got != tt.want {
+			t.Errorf("Unexpected window: %v", got)
+		}
+		testy.Error(t, tt.err, err)
+	})
+}
+
+func TestWindows(t *testing.T) {
+	s := New(nil, Config{
+		Windows: []Window{
+			{Start: 22 * time.Hour, End: 2 * time.Hour},
+			{Start: 12 * time.Hour, End: 13 * time.Hour},
+		},
+		Location: time.UTC,
+	})
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 11, 14, hour, minute, 0, 0, time.UTC)
+	}
+	type tt struct {
+		t        time.Time
+		in       bool
+		next     time.Time
+		nextPass time.Time
+	}
+	tests := testy.NewTable()
+	tests.Add("after midnight", tt{
+		t:        at(1, 30),
+		in:       true,
+		next:     at(1, 30),
+		nextPass: at(12, 0),
+	})
+	tests.Add("morning", tt{
+		t:        at(2, 0),
+		next:     at(12, 0),
+		nextPass: at(12, 0),
+	})
+	tests.Add("midday", tt{
+		t:        at(12, 15),
+		in:       true,
+		next:     at(12, 15),
+		nextPass: at(22, 0),
+	})
+	tests.Add("evening", tt{
+		t:        at(23, 0),
+		in:       true,
+		next:     at(23, 0),
+		nextPass: at(36, 0),
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		if in := s.InWindow(tt.t); in != tt.in {
+			t.Errorf("Unexpected InWindow: %t", in)
+		}
+		if next := s.NextWindow(tt.t); !next.Equal(tt.next) {
+			t.Errorf("Unexpected NextWindow: %v", next)
+		}
+		if next := s.nextPass(tt.t); !next.Equal(tt.nextPass) {
+			t.Errorf("Unexpected next pass: %v", next)
+		}
+	})
+}
+
+func TestRunOnce(t *testing.T) {
+	const compacting = `[
+		{"type":"database_compaction","database":"shards/00000000-7fffffff/orders.1700000000","progress":40},
+		{"type":"database_compaction","database":"shards/80000000-ffffffff/orders.1700000000","progress":60},
+		{"type":"database_compaction","database":"shards/00000000-7fffffff/users.1700000000","progress":10}
+	]`
+	const compactingView = `[
+		{"type":"view_compaction","database":"shards/00000000-7fffffff/orders.1700000000","design_document":"_design/reports","progress":75}
+	]`
+	client, tc := newClient(t, `[]`, compacting, `[]`, compactingView)
+	var progress []Progress
+	s := New(client, Config{
+		DBs:          []string{"invoices", "orders"},
+		Windows:      []Window{{Start: 22 * time.Hour, End: 2 * time.Hour}},
+		Location:     time.UTC,
+		Views:        true,
+		Cleanup:      true,
+		PollInterval: time.Millisecond,
+		OnProgress:   func(p Progress) { progress = append(progress, p) },
+		Now:          func() time.Time { return now },
+	})
+	result, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Result{Completed: []string{"invoices", "orders"}, Failed: map[string]error{}}
+	if d := testy.DiffInterface(want, result); d != nil {
+		t.Error(d)
+	}
+	wantActions := []string{
+		"compact invoices",
+		"view_cleanup invoices",
+		"compact orders",
+		"compact_view orders reports",
+		"view_cleanup orders",
+	}
+	if d := testy.DiffInterface(wantActions, tc.actions); d != nil {
+		t.Errorf("Unexpected actions:\n%s", d)
+	}
+	wantProgress := []Progress{
+		{DB: "invoices", Action: ActionCompact},
+		{DB: "invoices", Action: ActionCompact, Progress: 100, Done: true},
+		{DB: "invoices", Action: ActionViewCleanup},
+		{DB: "invoices", Action: ActionViewCleanup, Progress: 100, Done: true},
+		{DB: "orders", Action: ActionCompact},
+		{DB: "orders", Action: ActionCompact, Progress: 50},
+		{DB: "orders", Action: ActionCompact, Progress: 100, Done: true},
+		{DB: "orders", DDoc: "reports", Action: ActionCompactView},
+		{DB: "orders", DDoc: "reports", Action: ActionCompactView, Progress: 75},
+		{DB: "orders", DDoc: "reports", Action: ActionCompactView, Progress: 100, Done: true},
+		{DB: "orders", Action: ActionViewCleanup},
+		{DB: "orders", Action: ActionViewCleanup, Progress: 100, Done: true},
+	}
+	if d := testy.DiffInterface(wantProgress, progress); d != nil {
+		t.Errorf("Unexpected progress:\n%s", d)
+	}
+}
+
+func TestRunOnceAllDBs(t *testing.T) {
+	client, tc := newClient(t)
+	s := New(client, Config{Concurrency: 2, PollInterval: time.Millisecond})
+	result, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Result{Completed: []string{"invoices", "orders", "users"}, Failed: map[string]error{}}
+	if d := testy.DiffInterface(want, result); d != nil {
+		t.Error(d)
+	}
+	sort.Strings(tc.actions)
+	wantActions := []string{"compact invoices", "compact orders", "compact users"}
+	if d := testy.DiffInterface(wantActions, tc.actions); d != nil {
+		t.Errorf("Unexpected actions:\n%s", d)
+	}
+}
+
+func TestRunOnceOutsideWindow(t *testing.T) {
+	client, tc := newClient(t)
+	s := New(client, Config{
+		Windows:  []Window{{Start: 2 * time.Hour, End: 5 * time.Hour}},
+		Location: time.UTC,
+		Now:      func() time.Time { return now },
+	})
+	result, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Result{Skipped: []string{"invoices", "orders", "users"}, Failed: map[string]error{}}
+	if d := testy.DiffInterface(want, result); d != nil {
+		t.Error(d)
+	}
+	if len(tc.actions) != 0 {
+		t.Errorf("Unexpected actions: %v", tc.actions)
+	}
+}
+
+func TestRunOnceFailure(t *testing.T) {
+	client, _ := newClient(t)
+	s := New(client, Config{DBs: []string{"orders", "missing"}, PollInterval: time.Millisecond})
+	result, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"orders"}, result.Completed); d != nil {
+		t.Error(d)
+	}
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, result.Failed["missing"])
+}
+
+func TestRun(t *testing.T) {
+	client, _ := newClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var runs int
+	s := New(client, Config{
+		DBs:          []string{"orders"},
+		Interval:     time.Millisecond,
+		PollInterval: time.Millisecond,
+		OnRun: func(result *Result, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			if runs++; runs == 3 {
+				cancel()
+			}
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if runs != 3 {
+		t.Errorf("Unexpected number of runs: %d", runs)
+	}
+}
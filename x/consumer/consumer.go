@@ -0,0 +1,320 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package consumer delivers the changes feed of a database over a Go channel,
+// and periodically records how far processing has progressed in a local
+// document, so that a consumer which is restarted resumes where it left off.
+//
+//	c := consumer.New(db, consumer.Config{ID: "indexer"})
+//	go func() {
+//	    for change := range c.Changes() {
+//	        index(change)
+//	        change.Ack()
+//	    }
+//	}()
+//	err := c.Run(ctx)
+//
+// Each change must be acknowledged with [Change.Ack] once it has been
+// processed. The checkpoint only advances past a change after it, and every
+// change before it, has been acknowledged, so a change is delivered again
+// after a restart unless it was acknowledged and checkpointed beforehand.
+// Processing is therefore at-least-once, and should be idempotent.
+//
+// To forward changes to a message broker or event bus, implement
+// [Publisher], and use [Pipe], which publishes changes in batches and
+// acknowledges them once published.
+//
+// To share the processing of a feed between several workers, use a [Group].
+//
+// The checkpoint of a consumer with ID "indexer" is stored in the local
+// document "_local/kivik-consumer:indexer" of the database being consumed.
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Default values used for unset [Config] fields.
+const (
+	DefaultCheckpointInterval = 10 * time.Second
+	DefaultPollInterval       = time.Second
+)
+
+const checkpointPrefix = "_local/kivik-consumer:"
+
+// Config configures a [Consumer].
+type Config struct {
+	// ID identifies the consumer. Consumers of the same database with
+	// different IDs keep independent checkpoints. It is required.
+	ID string
+
+	// CheckpointInterval is how often the checkpoint is saved, if it has
+	// advanced. It is also saved when Run returns.
+	CheckpointInterval time.Duration
+
+	// Buffer is the capacity of the channel returned by Changes.
+	Buffer int
+
+	// Options are passed to [kivik.DB.Changes], for instance to include
+	// documents, or to set a filter. Unless set here, the feed option defaults
+	// to "continuous". The since option is managed by the consumer.
+	Options kivik.Options
+
+	// Retry controls how the changes feed is resumed after a transient error.
+	// See [kivik.DB.ResumableChanges].
+	Retry kivik.RetryPolicy
+
+	// PollInterval is the delay before the changes feed is reopened, when a
+	// normal or longpoll feed reaches its end.
+	PollInterval time.Duration
+
+	// OnError, if set, is called when a checkpoint cannot be saved. The
+	// consumer keeps running, and tries again at the next interval.
+	OnError func(error)
+}
+
+// Change is a single change delivered by a [Consumer].
+type Change struct {
+	ID      string
+	Seq     string
+	Changes []string
+	Deleted bool
+	// Doc is the document, if documents are included in the feed.
+	Doc json.RawMessage
+
+	c     *Consumer
+	acked bool
+}
+
+// Ack acknowledges that the change has been processed, allowing the
+// checkpoint to advance past it. Ack may be called from any goroutine, and in
+// any order, and more than once.
+func (ch *Change) Ack() {
+	ch.c.ack(ch)
+}
+
+// checkpoint is the local document in which progress is stored.
+type checkpoint struct {
+	Rev string `json:"_rev,omitempty"`
+	Seq string `json:"seq"`
+}
+
+// Consumer delivers the changes feed of a database over a channel.
+type Consumer struct {
+	db     *kivik.DB
+	config Config
+	ch     chan *Change
+
+	mu sync.Mutex
+	// pending holds the changes delivered but not yet covered by seq, in
+	// feed order.
+	pending []*Change
+	// seq is the sequence up to which all changes have been acknowledged.
+	seq     string
+	running bool
+
+	// saveMu serializes checkpoint writes, and protects saved and rev.
+	saveMu sync.Mutex
+	saved  string
+	rev    string
+}
+
+// New returns a consumer of the changes feed of db. Call [Consumer.Run] to
+// start it.
+func New(db *kivik.DB, config Config) *Consumer {
+	if config.CheckpointInterval <= 0 {
+		config.CheckpointInterval = DefaultCheckpointInterval
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.Buffer < 0 {
+		config.Buffer = 0
+	}
+	return &Consumer{
+		db:     db,
+		config: config,
+		ch:     make(chan *Change, config.Buffer),
+	}
+}
+
+// Changes returns the channel over which changes are delivered. It is closed
+// when Run returns.
+func (c *Consumer) Changes() <-chan *Change {
+	return c.ch
+}
+
+func (c *Consumer) checkpointID() string {
+	return checkpointPrefix + c.config.ID
+}
+
+// Run reads the last checkpoint, and follows the changes feed from there,
+// delivering each change to the channel returned by Changes, until ctx is
+// cancelled or the feed fails with a permanent error. It returns nil if ctx
+// was cancelled. The checkpoint is saved a final time before Run returns.
+//
+// Run may only be called once.
+func (c *Consumer) Run(ctx context.Context) error {
+	if c.config.ID == "" {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: consumer ID required"}
+	}
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: consumer already started"}
+	}
+	c.running = true
+	c.mu.Unlock()
+	defer close(c.ch)
+
+	cp := &checkpoint{}
+	err := c.db.Get(ctx, c.checkpointID()).ScanDoc(cp)
+	if err != nil && kivik.HTTPStatus(err) != http.StatusNotFound {
+		return err
+	}
+	c.seq, c.saved, c.rev = cp.Seq, cp.Seq, cp.Rev
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.config.CheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.save(ctx)
+			}
+		}
+	}()
+
+	err = c.follow(ctx, cp.Seq)
+	close(done)
+	wg.Wait()
+	// ctx may already be cancelled, but the progress made so far should not
+	// be lost.
+	c.save(context.Background())
+	return err
+}
+
+// follow delivers changes since seq until ctx is cancelled, or a permanent
+// error occurs.
+func (c *Consumer) follow(ctx context.Context, since string) error {
+	for {
+		opts := kivik.Options{"feed": "continuous"}
+		for k, v := range c.config.Options {
+			opts[k] = v
+		}
+		delete(opts, "since")
+		if since != "" {
+			opts["since"] = since
+		}
+		feed := c.db.ResumableChanges(ctx, c.config.Retry, opts)
+		for feed.Next() {
+			if strings.HasPrefix(feed.ID(), "_local/") {
+				continue
+			}
+			change := &Change{
+				ID:      feed.ID(),
+				Seq:     feed.Seq(),
+				Changes: feed.Changes(),
+				Deleted: feed.Deleted(),
+				c:       c,
+			}
+			_ = feed.ScanDoc(&change.Doc)
+			c.mu.Lock()
+			c.pending = append(c.pending, change)
+			c.mu.Unlock()
+			select {
+			case c.ch <- change:
+			case <-ctx.Done():
+				_ = feed.Close()
+				return nil
+			}
+		}
+		err := feed.Err()
+		_ = feed.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		since = feed.LastSeq()
+		c.mu.Lock()
+		if len(c.pending) == 0 && since != "" {
+			// Everything delivered has been processed, so skip past any
+			// filtered or local changes at the end of the feed.
+			c.seq = since
+		}
+		c.mu.Unlock()
+		t := time.NewTimer(c.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Consumer) ack(ch *Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch.acked = true
+	for len(c.pending) > 0 && c.pending[0].acked {
+		c.seq = c.pending[0].Seq
+		c.pending = c.pending[1:]
+	}
+}
+
+// Seq returns the sequence up to which all changes have been acknowledged.
+func (c *Consumer) Seq() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// Checkpoint saves the checkpoint immediately, if it has advanced since it
+// was last saved.
+func (c *Consumer) Checkpoint(ctx context.Context) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	seq := c.Seq()
+	if seq == c.saved {
+		return nil
+	}
+	rev, err := c.db.Put(ctx, c.checkpointID(), &checkpoint{Rev: c.rev, Seq: seq})
+	if err != nil {
+		return err
+	}
+	c.saved, c.rev = seq, rev
+	return nil
+}
+
+// save calls Checkpoint, reporting any error to the OnError callback.
+func (c *Consumer) save(ctx context.Context) {
+	if err := c.Checkpoint(ctx); err != nil && c.config.OnError != nil && !errors.Is(err, context.Canceled) {
+		c.config.OnError(err)
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+// newDB returns a new, empty in-memory database.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "events"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("events")
+}
+
+func put(t *testing.T, db *kivik.DB, docID string) {
+	t.Helper()
+	if _, err := db.Put(context.Background(), docID, map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// start runs c in the background, returning a function which stops it and
+// returns the result of Run.
+func start(t *testing.T, c *Consumer) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(ctx)
+	}()
+	return func() error {
+		cancel()
+		return <-errc
+	}
+}
+
+// receive returns the IDs of the next n changes delivered by c.
+func receive(t *testing.T, c *Consumer, n int, ack bool) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	timeout := time.After(5 * time.Second)
+	for len(ids) < n {
+		select {
+		case change, ok := <-c.Changes():
+			if !ok {
+				t.Fatal("channel closed")
+			}
+			ids = append(ids, change.ID)
+			if ack {
+				change.Ack()
+			}
+		case <-timeout:
+			t.Fatalf("timed out after receiving %v", ids)
+		}
+	}
+	return ids
+}
+
+func savedSeq(t *testing.T, db *kivik.DB, id string) string {
+	t.Helper()
+	var cp checkpoint
+	if err := db.Get(context.Background(), checkpointPrefix+id).ScanDoc(&cp); err != nil {
+		t.Fatal(err)
+	}
+	return cp.Seq
+}
+
+func config() Config {
+	return Config{
+		ID:                 "test",
+		Options:            kivik.Options{"feed": "normal", "include_docs": true},
+		PollInterval:       time.Millisecond,
+		CheckpointInterval: time.Hour,
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		db := newDB(t)
+		put(t, db, "a")
+		put(t, db, "b")
+
+		c := New(db, config())
+		stop := start(t, c)
+		if d := testy.DiffInterface([]string{"a", "b"}, receive(t, c, 2, true)); d != nil {
+			t.Error(d)
+		}
+		put(t, db, "c")
+		if d := testy.DiffInterface([]string{"c"}, receive(t, c, 1, true)); d != nil {
+			t.Error(d)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := <-c.Changes(); ok {
+			t.Error("channel should be closed")
+		}
+		if seq := savedSeq(t, db, "test"); seq != "3" {
+			t.Errorf("Unexpected checkpoint: %s", seq)
+		}
+
+		put(t, db, "d")
+		c = New(db, config())
+		stop = start(t, c)
+		if d := testy.DiffInterface([]string{"d"}, receive(t, c, 1, true)); d != nil {
+			t.Error(d)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("unacknowledged changes are redelivered", func(t *testing.T) {
+		db := newDB(t)
+		put(t, db, "a")
+		put(t, db, "b")
+		put(t, db, "c")
+
+		c := New(db, config())
+		stop := start(t, c)
+		var changes []*Change
+		for i := 0; i < 3; i++ {
+			changes = append(changes, <-c.Changes())
+		}
+		changes[0].Ack()
+		changes[2].Ack()
+		if seq := c.Seq(); seq != "1" {
+			t.Errorf("Unexpected seq: %s", seq)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		c = New(db, config())
+		stop = start(t, c)
+		if d := testy.DiffInterface([]string{"b", "c"}, receive(t, c, 2, false)); d != nil {
+			t.Error(d)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("documents", func(t *testing.T) {
+		db := newDB(t)
+		put(t, db, "a")
+		c := New(db, config())
+		stop := start(t, c)
+		change := <-c.Changes()
+		var doc map[string]interface{}
+		if err := json.Unmarshal(change.Doc, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["foo"] != "bar" {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("periodic checkpoint", func(t *testing.T) {
+		db := newDB(t)
+		put(t, db, "a")
+		cfg := config()
+		cfg.CheckpointInterval = time.Millisecond
+		c := New(db, cfg)
+		stop := start(t, c)
+		receive(t, c, 1, true)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var cp checkpoint
+			err := db.Get(context.Background(), checkpointPrefix+"test").ScanDoc(&cp)
+			if err == nil && cp.Seq == "1" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("checkpoint not saved: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("feed error", func(t *testing.T) {
+		db := newDB(t)
+		cfg := config()
+		cfg.Options = nil // The memory driver has no continuous feed
+		err := New(db, cfg).Run(context.Background())
+		if status := kivik.HTTPStatus(err); status != http.StatusNotImplemented {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("missing ID", func(t *testing.T) {
+		err := New(newDB(t), Config{}).Run(context.Background())
+		if status := kivik.HTTPStatus(err); status != http.StatusBadRequest {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
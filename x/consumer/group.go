@@ -0,0 +1,335 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Default values used for unset [GroupConfig] fields.
+const (
+	DefaultPartitions        = 16
+	DefaultHeartbeatInterval = 5 * time.Second
+)
+
+const groupPrefix = "_local/kivik-group:"
+
+// GroupConfig configures a [Group].
+type GroupConfig struct {
+	// Group identifies the consumer group. Workers with the same Group share
+	// the changes feed between them. It is required.
+	Group string
+
+	// Member identifies this worker within the group, and must be unique to
+	// it. It is required.
+	Member string
+
+	// Partitions is the number of partitions the changes feed is divided
+	// into, by a hash of the document ID. It must be the same for all
+	// members, and should be no smaller than the largest expected number of
+	// members.
+	Partitions int
+
+	// HeartbeatInterval is how often a member records that it is alive, and
+	// checks for members joining or leaving.
+	HeartbeatInterval time.Duration
+
+	// SessionTimeout is how long after its last heartbeat a member is
+	// considered to have left the group. It defaults to three heartbeat
+	// intervals. As heartbeats are timestamped by each member, clocks must
+	// be synchronized to well within this period.
+	SessionTimeout time.Duration
+
+	// Consumer configures the consumer of each partition. Its ID is ignored.
+	// OnError is also called when a heartbeat cannot be recorded.
+	Consumer Config
+}
+
+// membership is the local document in which the members of a group record
+// their heartbeats.
+type membership struct {
+	Rev string `json:"_rev,omitempty"`
+	// Members maps each member to the time of its last heartbeat, in
+	// nanoseconds since the Unix epoch.
+	Members map[string]int64 `json:"members"`
+}
+
+// Group is a member of a consumer group, which divides the changes feed of
+// a database between several workers, typically in separate processes.
+//
+// The feed is divided into a fixed number of partitions, by a hash of the
+// document ID, as reported by [Partition]. The partitions are shared out
+// between the live members of the group, and reassigned whenever a member
+// joins or leaves. Each partition is consumed as by a [Consumer], with its
+// own checkpoint, so that a member which takes over a partition resumes where
+// its previous owner left off. Membership is recorded in the local document
+// "_local/kivik-group:" followed by the group name.
+//
+// As for a Consumer, each change must be acknowledged with [Change.Ack] once
+// it has been processed. Changes may be delivered more than once, in
+// particular while partitions are being reassigned.
+type Group struct {
+	db     *kivik.DB
+	config GroupConfig
+	ch     chan *Change
+
+	mu         sync.Mutex
+	partitions map[int]*partition
+	running    bool
+}
+
+// partition is a partition consumed by this member.
+type partition struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewGroup returns a member of a consumer group of the changes feed of db.
+// Call [Group.Run] to join the group.
+func NewGroup(db *kivik.DB, config GroupConfig) *Group {
+	if config.Partitions <= 0 {
+		config.Partitions = DefaultPartitions
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	if config.SessionTimeout <= 0 {
+		config.SessionTimeout = 3 * config.HeartbeatInterval
+	}
+	if config.Consumer.Buffer < 0 {
+		config.Consumer.Buffer = 0
+	}
+	return &Group{
+		db:         db,
+		config:     config,
+		ch:         make(chan *Change, config.Consumer.Buffer),
+		partitions: map[int]*partition{},
+	}
+}
+
+// Partition returns the partition, between 0 and n-1, to which changes to
+// docID belong.
+func Partition(docID string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(docID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Changes returns the channel over which the changes of the partitions
+// assigned to this member are delivered. It is closed when Run returns.
+func (g *Group) Changes() <-chan *Change {
+	return g.ch
+}
+
+// Partitions returns the partitions currently assigned to this member, in
+// ascending order.
+func (g *Group) Partitions() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	parts := make([]int, 0, len(g.partitions))
+	for p := range g.partitions {
+		parts = append(parts, p)
+	}
+	sort.Ints(parts)
+	return parts
+}
+
+func (g *Group) membershipID() string {
+	return groupPrefix + g.config.Group
+}
+
+// Run joins the group, and consumes the partitions assigned to this member
+// until ctx is cancelled, or the feed fails with a permanent error. Before
+// returning, Run saves the checkpoint of each partition, and leaves the
+// group, so that its partitions are promptly taken over by the remaining
+// members. It returns nil if ctx was cancelled.
+//
+// Run may only be called once.
+func (g *Group) Run(ctx context.Context) error {
+	if g.config.Group == "" {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: consumer group required"}
+	}
+	if g.config.Member == "" {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: consumer group member required"}
+	}
+	g.mu.Lock()
+	if g.running {
+		g.mu.Unlock()
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: consumer group already started"}
+	}
+	g.running = true
+	g.mu.Unlock()
+	defer close(g.ch)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, g.config.Partitions)
+	ticker := time.NewTicker(g.config.HeartbeatInterval)
+	defer ticker.Stop()
+	var err error
+	for {
+		members, hbErr := g.heartbeat(ctx, false)
+		if hbErr != nil && ctx.Err() == nil && g.config.Consumer.OnError != nil {
+			g.config.Consumer.OnError(hbErr)
+		}
+		if hbErr == nil {
+			g.rebalance(ctx, g.assigned(members), errc)
+		}
+		select {
+		case <-ctx.Done():
+		case err = <-errc:
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+	cancel()
+	g.rebalance(ctx, nil, errc)
+	// ctx has been cancelled, but the group should still be told that this
+	// member is leaving.
+	_, _ = g.heartbeat(context.Background(), true)
+	return err
+}
+
+// heartbeat records that this member is alive, or, if leaving is true, that
+// it has left, and returns the live members of the group, sorted.
+func (g *Group) heartbeat(ctx context.Context, leaving bool) ([]string, error) {
+	for {
+		m := &membership{}
+		err := g.db.Get(ctx, g.membershipID()).ScanDoc(m)
+		if err != nil && kivik.HTTPStatus(err) != http.StatusNotFound {
+			return nil, err
+		}
+		now := time.Now()
+		if m.Members == nil {
+			m.Members = map[string]int64{}
+		}
+		for member, last := range m.Members {
+			if now.Sub(time.Unix(0, last)) > g.config.SessionTimeout {
+				delete(m.Members, member)
+			}
+		}
+		if leaving {
+			delete(m.Members, g.config.Member)
+		} else {
+			m.Members[g.config.Member] = now.UnixNano()
+		}
+		_, err = g.db.Put(ctx, g.membershipID(), m)
+		if kivik.HTTPStatus(err) == http.StatusConflict {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		members := make([]string, 0, len(m.Members))
+		for member := range m.Members {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		return members, nil
+	}
+}
+
+// assigned returns the partitions assigned to this member, given the sorted
+// list of live members. Partitions are dealt out to members in turn.
+func (g *Group) assigned(members []string) map[int]bool {
+	i := sort.SearchStrings(members, g.config.Member)
+	if i == len(members) || members[i] != g.config.Member {
+		return nil
+	}
+	parts := map[int]bool{}
+	for p := i; p < g.config.Partitions; p += len(members) {
+		parts[p] = true
+	}
+	return parts
+}
+
+// rebalance stops consuming the partitions not in want, waiting for their
+// checkpoints to be saved, then starts consuming those newly assigned.
+func (g *Group) rebalance(ctx context.Context, want map[int]bool, errc chan<- error) {
+	g.mu.Lock()
+	stopped := map[int]*partition{}
+	for p, part := range g.partitions {
+		if !want[p] {
+			part.cancel()
+			stopped[p] = part
+		}
+	}
+	g.mu.Unlock()
+	for _, part := range stopped {
+		<-part.done
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// Stopped partitions are only forgotten once they have finished, so that
+	// Partitions never omits a partition which may still deliver changes.
+	for p := range stopped {
+		delete(g.partitions, p)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	for p := range want {
+		if _, ok := g.partitions[p]; !ok {
+			g.partitions[p] = g.consume(ctx, p, errc)
+		}
+	}
+}
+
+// consume starts consuming partition p.
+func (g *Group) consume(ctx context.Context, p int, errc chan<- error) *partition {
+	ctx, cancel := context.WithCancel(ctx)
+	part := &partition{cancel: cancel, done: make(chan struct{})}
+	config := g.config.Consumer
+	config.ID = g.config.Group + ":" + strconv.Itoa(p)
+	c := New(g.db, config)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for change := range c.Changes() {
+			if Partition(change.ID, g.config.Partitions) != p {
+				change.Ack()
+				continue
+			}
+			if ctx.Err() != nil {
+				// Keep draining, so the consumer can shut down.
+				continue
+			}
+			select {
+			case g.ch <- change:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	go func() {
+		defer close(part.done)
+		err := c.Run(ctx)
+		wg.Wait()
+		if err != nil {
+			select {
+			case errc <- err:
+			default:
+			}
+		}
+	}()
+	return part
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func groupConfig(member string) GroupConfig {
+	return GroupConfig{
+		Group:             "workers",
+		Member:            member,
+		Partitions:        4,
+		HeartbeatInterval: 5 * time.Millisecond,
+		SessionTimeout:    time.Second,
+		Consumer:          config(),
+	}
+}
+
+// startGroup runs g in the background, acknowledging and recording the IDs
+// of the changes it delivers. It returns a function which stops it and
+// returns the result of Run.
+func startGroup(t *testing.T, g *Group, mu *sync.Mutex, seen map[string][]string) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.Run(ctx)
+	}()
+	go func() {
+		for change := range g.Changes() {
+			mu.Lock()
+			seen[change.ID] = append(seen[change.ID], g.config.Member)
+			mu.Unlock()
+			change.Ack()
+		}
+	}()
+	return func() error {
+		cancel()
+		return <-errc
+	}
+}
+
+func waitPartitions(t *testing.T, g *Group, want []int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		if d := testy.DiffInterface(want, g.Partitions()); d == nil {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for partitions %v, have %v", want, g.Partitions())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("doc%d", i)
+		p := Partition(id, 4)
+		if p < 0 || p >= 4 {
+			t.Fatalf("Partition out of range: %d", p)
+		}
+		if Partition(id, 4) != p {
+			t.Fatalf("Partition not stable for %s", id)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	t.Run("missing group", func(t *testing.T) {
+		err := NewGroup(newDB(t), GroupConfig{Member: "a"}).Run(context.Background())
+		testy.StatusError(t, "kivik: consumer group required", http.StatusBadRequest, err)
+	})
+	t.Run("missing member", func(t *testing.T) {
+		err := NewGroup(newDB(t), GroupConfig{Group: "workers"}).Run(context.Background())
+		testy.StatusError(t, "kivik: consumer group member required", http.StatusBadRequest, err)
+	})
+	t.Run("rebalance", func(t *testing.T) {
+		db := newDB(t)
+		var mu sync.Mutex
+		seen := map[string][]string{}
+
+		a := NewGroup(db, groupConfig("a"))
+		stopA := startGroup(t, a, &mu, seen)
+		waitPartitions(t, a, []int{0, 1, 2, 3})
+
+		b := NewGroup(db, groupConfig("b"))
+		stopB := startGroup(t, b, &mu, seen)
+		waitPartitions(t, a, []int{0, 2})
+		waitPartitions(t, b, []int{1, 3})
+
+		ids := make([]string, 20)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("doc%02d", i)
+			put(t, db, ids[i])
+		}
+		timeout := time.After(5 * time.Second)
+		for {
+			mu.Lock()
+			n := len(seen)
+			mu.Unlock()
+			if n == len(ids) {
+				break
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("timed out; received %d of %d", n, len(ids))
+			case <-time.After(time.Millisecond):
+			}
+		}
+		mu.Lock()
+		for _, id := range ids {
+			want := "a"
+			if Partition(id, 4)%2 == 1 {
+				want = "b"
+			}
+			if d := testy.DiffInterface([]string{want}, seen[id]); d != nil {
+				t.Errorf("%s: %s", id, d)
+			}
+		}
+		mu.Unlock()
+
+		if err := stopB(); err != nil {
+			t.Fatal(err)
+		}
+		waitPartitions(t, a, []int{0, 1, 2, 3})
+		if err := stopA(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Each partition has its own checkpoint.
+		for p := 0; p < 4; p++ {
+			if seq := savedSeq(t, db, fmt.Sprintf("workers:%d", p)); seq == "" {
+				t.Errorf("Expected a checkpoint for partition %d", p)
+			}
+		}
+	})
+}
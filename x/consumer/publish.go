@@ -0,0 +1,144 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package consumer
+
+import (
+	"context"
+	"time"
+)
+
+// Publisher publishes changes to an external system, such as a message
+// broker or an in-process event bus. It is the contract between [Pipe] and
+// such integrations.
+type Publisher interface {
+	// Publish publishes a batch of changes, in feed order. It should return
+	// only once the changes have been durably accepted, as they are
+	// acknowledged, and may be checkpointed, as soon as it returns nil.
+	Publish(ctx context.Context, changes []*Change) error
+}
+
+// PublisherFunc adapts an ordinary function to the [Publisher] interface.
+type PublisherFunc func(ctx context.Context, changes []*Change) error
+
+var _ Publisher = PublisherFunc(nil)
+
+// Publish calls f(ctx, changes).
+func (f PublisherFunc) Publish(ctx context.Context, changes []*Change) error {
+	return f(ctx, changes)
+}
+
+// Default values used for unset [BatchConfig] fields.
+const (
+	DefaultBatchSize = 100
+	DefaultBatchWait = time.Second
+)
+
+// BatchConfig controls how [Pipe] groups changes into batches.
+type BatchConfig struct {
+	// Size is the maximum number of changes in a batch.
+	Size int
+	// Wait is the longest a change waits for its batch to fill, before the
+	// batch is published anyway.
+	Wait time.Duration
+}
+
+// Pipe runs c, as for [Consumer.Run], publishing the changes it delivers to
+// p in batches, and acknowledging each batch once it has been published. A
+// batch is published once it holds config.Size changes, or config.Wait after
+// its first change arrived, whichever comes first.
+//
+// Pipe returns when ctx is cancelled, in which case it returns nil, when the
+// changes feed fails, or when p returns an error, in which case Pipe stops
+// the consumer and returns that error. Changes which were not published are
+// delivered again when the consumer is restarted, so p may see the same
+// change more than once.
+//
+// The channel returned by [Consumer.Changes] must not be read by anything
+// else while Pipe is running.
+func Pipe(ctx context.Context, c *Consumer, p Publisher, config BatchConfig) error {
+	if config.Size <= 0 {
+		config.Size = DefaultBatchSize
+	}
+	if config.Wait <= 0 {
+		config.Wait = DefaultBatchWait
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(ctx)
+	}()
+
+	var pubErr error
+	batch := make([]*Change, 0, config.Size)
+	timer := time.NewTimer(config.Wait)
+	timer.Stop()
+	var timeout <-chan time.Time
+	publish := func() {
+		if timeout != nil && !timer.Stop() {
+			<-timer.C
+		}
+		timeout = nil
+		if len(batch) == 0 || pubErr != nil {
+			batch = batch[:0]
+			return
+		}
+		if err := p.Publish(ctx, batch); err != nil {
+			if ctx.Err() == nil {
+				pubErr = err
+			}
+			cancel()
+		} else {
+			for _, change := range batch {
+				change.Ack()
+			}
+		}
+		batch = make([]*Change, 0, config.Size)
+	}
+	changes := c.Changes()
+	for changes != nil {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			if pubErr != nil {
+				// Drain the channel, so the consumer can shut down.
+				continue
+			}
+			batch = append(batch, change)
+			if len(batch) == 1 {
+				timer.Reset(config.Wait)
+				timeout = timer.C
+			}
+			if len(batch) >= config.Size {
+				publish()
+			}
+		case <-timeout:
+			timeout = nil
+			publish()
+		}
+	}
+	timer.Stop()
+	err := <-errc
+	// Changes acknowledged after the consumer's final checkpoint would
+	// otherwise be delivered again.
+	if cpErr := c.Checkpoint(context.Background()); err == nil {
+		err = cpErr
+	}
+	if pubErr != nil {
+		return pubErr
+	}
+	return err
+}
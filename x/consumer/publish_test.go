@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+)
+
+// batchRecorder is a Publisher which records the IDs in each batch.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *batchRecorder) Publish(_ context.Context, changes []*Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	ids := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = change.ID
+	}
+	r.batches = append(r.batches, ids)
+	return nil
+}
+
+func (r *batchRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, b := range r.batches {
+		n += len(b)
+	}
+	return n
+}
+
+// waitFor waits until cond returns true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			t.Fatal("timed out")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	t.Run("batches and checkpoints", func(t *testing.T) {
+		db := newDB(t)
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			put(t, db, id)
+		}
+		c := New(db, config())
+		rec := &batchRecorder{}
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- Pipe(ctx, c, rec, BatchConfig{Size: 2, Wait: 10 * time.Millisecond})
+		}()
+		waitFor(t, func() bool { return rec.count() == 5 })
+		cancel()
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+		if d := testy.DiffInterface(want, rec.batches); d != nil {
+			t.Error(d)
+		}
+		if seq := savedSeq(t, db, "test"); seq == "" {
+			t.Error("Expected a checkpoint to be saved")
+		}
+
+		// A restarted pipe publishes only new changes.
+		put(t, db, "f")
+		rec = &batchRecorder{}
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() {
+			errc <- Pipe(ctx, New(db, config()), rec, BatchConfig{Wait: time.Millisecond})
+		}()
+		waitFor(t, func() bool { return rec.count() == 1 })
+		cancel()
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([][]string{{"f"}}, rec.batches); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("publish error", func(t *testing.T) {
+		db := newDB(t)
+		put(t, db, "a")
+		rec := &batchRecorder{err: errors.New("broker unavailable")}
+		err := Pipe(context.Background(), New(db, config()), rec, BatchConfig{Wait: time.Millisecond})
+		if err == nil || err.Error() != "broker unavailable" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if _, err := db.Get(context.Background(), checkpointPrefix+"test").Rev(); err == nil {
+			t.Error("Expected no checkpoint to be saved")
+		}
+	})
+}
@@ -0,0 +1,352 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package encrypt provides a [kivik.Codec] which encrypts documents, or
+// selected fields of documents, with AES-GCM before they are stored, so that
+// sensitive data is encrypted at rest in the database.
+//
+//	codec, err := encrypt.New("2024-01", key, "ssn", "address")
+//	if err != nil {
+//	    return err
+//	}
+//	client, err := kivik.New("couch", dsn, kivik.WithCodec(codec))
+//
+// Each encrypted value is replaced by an object with a single field,
+// "$encrypted", holding an envelope which records the ID of the key used:
+//
+//	{"_id": "bob", "name": "Bob", "ssn": {"$encrypted": {"kid": "2024-01", "alg": "AES-GCM", "data": "..."}}}
+//
+// When no fields are given, all fields except the special, underscore-prefixed
+// ones are encrypted together into a single top-level "$encrypted" field.
+// Special fields are never encrypted, so that the database can interpret
+// them, and nor are design or local documents, which kivik does not pass to
+// the codec.
+//
+// To rotate keys, create a codec with the new key, and register the old key
+// with [AESGCM.AddKey], so that documents written before the rotation can
+// still be read. Each ciphertext is bound to the _id of the document and the
+// name of the field it was stored in, so encrypted values cannot be moved
+// between fields or documents undetected. Documents must therefore have an
+// _id when they are encoded; to use [kivik.DB.CreateDoc], create the client
+// with [kivik.WithIDGenerator].
+//
+// By default, values stored before encryption was introduced are read as
+// they are. Once all documents have been encrypted, call [AESGCM.SetStrict]
+// to reject plaintext where ciphertext is expected.
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Field is the name of the field which holds an encrypted value.
+const Field = "$encrypted"
+
+// Algorithm is the algorithm identifier recorded in each envelope.
+const Algorithm = "AES-GCM"
+
+// envelope is the stored form of an encrypted value.
+type envelope struct {
+	KeyID string `json:"kid"`
+	Alg   string `json:"alg"`
+	// Data is the nonce, followed by the ciphertext.
+	Data []byte `json:"data"`
+}
+
+// AESGCM is a [kivik.Codec] which encrypts with AES-GCM. It is safe for
+// concurrent use.
+type AESGCM struct {
+	current string
+	fields  []string
+
+	mu     sync.RWMutex
+	aeads  map[string]cipher.AEAD
+	strict bool
+}
+
+var _ kivik.Codec = &AESGCM{}
+
+// New returns a codec which encrypts the named top-level fields of each
+// document, or the whole document if no fields are given, with key, which
+// must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+// keyID identifies the key in the stored envelopes, and must not be empty.
+func New(keyID string, key []byte, fields ...string) (*AESGCM, error) {
+	for _, field := range fields {
+		if field == "" || strings.HasPrefix(field, "_") {
+			return nil, fmt.Errorf("encrypt: invalid field name %q", field)
+		}
+	}
+	c := &AESGCM{
+		current: keyID,
+		fields:  fields,
+		aeads:   map[string]cipher.AEAD{},
+	}
+	if err := c.AddKey(keyID, key); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AddKey registers an additional key, which is used only to decrypt values
+// encrypted with it, as after a key rotation.
+func (c *AESGCM) AddKey(keyID string, key []byte) error {
+	if keyID == "" {
+		return errors.New("encrypt: key ID required")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aeads[keyID] = aead
+	return nil
+}
+
+// SetStrict sets whether [AESGCM.Decode] rejects documents with plaintext
+// values in the fields the codec is configured to encrypt, or, if it encrypts
+// whole documents, with unencrypted fields other than the special ones.
+// Design and local documents are never rejected.
+func (c *AESGCM) SetStrict(strict bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strict = strict
+}
+
+func (c *AESGCM) isStrict() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.strict
+}
+
+func (c *AESGCM) aead(keyID string) (cipher.AEAD, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	aead, ok := c.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encrypt: unknown key ID %q", keyID)
+	}
+	return aead, nil
+}
+
+// additionalData binds a ciphertext to the key ID, document ID and field
+// name.
+func additionalData(keyID, docID, field string) []byte {
+	return []byte(keyID + "\x00" + docID + "\x00" + field)
+}
+
+// seal encrypts value, stored in field of the document docID, with the
+// current key.
+func (c *AESGCM) seal(docID, field string, value []byte) (*envelope, error) {
+	aead, err := c.aead(c.current)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &envelope{
+		KeyID: c.current,
+		Alg:   Algorithm,
+		Data:  aead.Seal(nonce, nonce, value, additionalData(c.current, docID, field)),
+	}, nil
+}
+
+// open returns the plaintext of env, stored in field of the document docID.
+func (c *AESGCM) open(docID, field string, env *envelope) ([]byte, error) {
+	if env.Alg != Algorithm {
+		return nil, fmt.Errorf("encrypt: unsupported algorithm %q", env.Alg)
+	}
+	aead, err := c.aead(env.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Data) < aead.NonceSize() {
+		return nil, errors.New("encrypt: ciphertext too short")
+	}
+	nonce, ciphertext := env.Data[:aead.NonceSize()], env.Data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, additionalData(env.KeyID, docID, field))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: field %q: %w", field, err)
+	}
+	return plain, nil
+}
+
+// parseEnvelope returns the envelope held in value, if value is an object
+// whose only field is [Field].
+func parseEnvelope(value json.RawMessage) (*envelope, bool) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || value[0] != '{' {
+		return nil, false
+	}
+	var wrapper map[string]*envelope
+	if err := json.Unmarshal(value, &wrapper); err != nil || len(wrapper) != 1 || wrapper[Field] == nil {
+		return nil, false
+	}
+	return wrapper[Field], true
+}
+
+// docID returns the _id of the document with the given fields.
+func docID(fields map[string]json.RawMessage) string {
+	var id string
+	_ = json.Unmarshal(fields["_id"], &id)
+	return id
+}
+
+// Encode encrypts the configured fields of doc, which must have an _id.
+func (c *AESGCM) Encode(doc json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	id := docID(fields)
+	if id == "" {
+		return nil, errors.New("encrypt: document _id required")
+	}
+	if len(c.fields) == 0 {
+		if _, ok := fields[Field]; ok {
+			// Already encrypted.
+			return doc, nil
+		}
+		body := map[string]json.RawMessage{}
+		for name, value := range fields {
+			if !strings.HasPrefix(name, "_") {
+				body[name] = value
+				delete(fields, name)
+			}
+		}
+		plain, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		env, err := c.seal(id, "", plain)
+		if err != nil {
+			return nil, err
+		}
+		if fields[Field], err = json.Marshal(env); err != nil {
+			return nil, err
+		}
+		return json.Marshal(fields)
+	}
+	for _, name := range c.fields {
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if _, encrypted := parseEnvelope(value); encrypted {
+			continue
+		}
+		env, err := c.seal(id, name, value)
+		if err != nil {
+			return nil, err
+		}
+		if fields[name], err = json.Marshal(map[string]*envelope{Field: env}); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Decode decrypts any encrypted values in doc, regardless of the fields the
+// codec is configured to encrypt. Documents without encrypted values are
+// returned unaltered, unless the codec is strict; see [AESGCM.SetStrict].
+func (c *AESGCM) Decode(doc json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		// Not an object, so nothing to decrypt.
+		return doc, nil
+	}
+	id := docID(fields)
+	if c.isStrict() {
+		if err := c.checkEncrypted(id, fields); err != nil {
+			return nil, err
+		}
+	}
+	changed := false
+	if whole, ok := fields[Field]; ok {
+		env := &envelope{}
+		if err := json.Unmarshal(whole, env); err != nil {
+			return nil, fmt.Errorf("encrypt: invalid envelope: %w", err)
+		}
+		plain, err := c.open(id, "", env)
+		if err != nil {
+			return nil, err
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(plain, &body); err != nil {
+			return nil, fmt.Errorf("encrypt: %w", err)
+		}
+		delete(fields, Field)
+		for name, value := range body {
+			fields[name] = value
+		}
+		changed = true
+	}
+	for name, value := range fields {
+		env, ok := parseEnvelope(value)
+		if !ok {
+			continue
+		}
+		plain, err := c.open(id, name, env)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = plain
+		changed = true
+	}
+	if !changed {
+		return doc, nil
+	}
+	return json.Marshal(fields)
+}
+
+// checkEncrypted returns an error if any value which the codec would have
+// encrypted is stored in plaintext in fields, the fields of the document id.
+func (c *AESGCM) checkEncrypted(id string, fields map[string]json.RawMessage) error {
+	if strings.HasPrefix(id, "_design/") || strings.HasPrefix(id, "_local/") {
+		return nil
+	}
+	if len(c.fields) > 0 {
+		for _, name := range c.fields {
+			if value, ok := fields[name]; ok {
+				if _, encrypted := parseEnvelope(value); !encrypted {
+					return fmt.Errorf("encrypt: field %q is not encrypted", name)
+				}
+			}
+		}
+		return nil
+	}
+	if _, ok := fields[Field]; ok {
+		return nil
+	}
+	for name := range fields {
+		if !strings.HasPrefix(name, "_") {
+			return fmt.Errorf("encrypt: document %q is not encrypted", id)
+		}
+	}
+	return nil
+}
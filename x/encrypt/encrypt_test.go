@@ -0,0 +1,309 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New("k1", []byte("short")); err == nil || err.Error() != "encrypt: crypto/aes: invalid key size 5" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := New("", key1); err == nil || err.Error() != "encrypt: key ID required" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := New("k1", key1, "_id"); err == nil || err.Error() != `encrypt: invalid field name "_id"` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	const doc = `{"_id":"bob","_rev":"1-xxx","name":"Bob","ssn":"123-45-6789","address":{"city":"Springfield"}}`
+	tests := []struct {
+		name   string
+		fields []string
+		check  func(t *testing.T, stored map[string]interface{})
+	}{
+		{
+			name:   "fields",
+			fields: []string{"ssn", "address", "missing"},
+			check: func(t *testing.T, stored map[string]interface{}) {
+				if stored["name"] != "Bob" {
+					t.Errorf("Unencrypted field altered: %v", stored["name"])
+				}
+				for _, field := range []string{"ssn", "address"} {
+					env, _ := stored[field].(map[string]interface{})[Field].(map[string]interface{})
+					if env["kid"] != "k1" || env["alg"] != Algorithm {
+						t.Errorf("Unexpected envelope for %s: %v", field, stored[field])
+					}
+				}
+				if _, ok := stored["missing"]; ok {
+					t.Error("Missing field was added")
+				}
+			},
+		},
+		{
+			name: "whole document",
+			check: func(t *testing.T, stored map[string]interface{}) {
+				if len(stored) != 3 || stored["_id"] != "bob" || stored["_rev"] != "1-xxx" || stored[Field] == nil {
+					t.Errorf("Unexpected stored document: %v", stored)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := New("k1", key1, test.fields...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := c.Encode(json.RawMessage(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(encoded), "6789") {
+				t.Errorf("Plaintext leaked: %s", encoded)
+			}
+			var stored map[string]interface{}
+			if err := json.Unmarshal(encoded, &stored); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, stored)
+
+			again, err := c.Encode(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := testy.DiffAsJSON(encoded, again); d != nil {
+				t.Errorf("Encrypted values were re-encrypted:\n%s", d)
+			}
+
+			decoded, err := c.Decode(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := testy.DiffAsJSON([]byte(doc), decoded); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	old, _ := New("k1", key1, "ssn")
+	encoded, err := old.Encode(json.RawMessage(`{"_id":"bob","ssn":"123","pin":"456"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("plain document", func(t *testing.T) {
+		doc := json.RawMessage(`{"name": "Bob"}`)
+		decoded, err := old.Decode(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decoded) != string(doc) {
+			t.Errorf("Unexpected result: %s", decoded)
+		}
+	})
+	t.Run("rotated key", func(t *testing.T) {
+		c, _ := New("k2", key2, "ssn")
+		if _, err := c.Decode(encoded); err == nil || err.Error() != `encrypt: unknown key ID "k1"` {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := c.AddKey("k1", key1); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := c.Decode(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffAsJSON([]byte(`{"_id":"bob","ssn":"123","pin":"456"}`), decoded); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("moved field", func(t *testing.T) {
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(encoded, &fields)
+		fields["pin"] = fields["ssn"]
+		moved, _ := json.Marshal(fields)
+		if _, err := old.Decode(moved); err == nil || err.Error() != `encrypt: field "pin": cipher: message authentication failed` {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("moved document", func(t *testing.T) {
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(encoded, &fields)
+		fields["_id"] = json.RawMessage(`"alice"`)
+		moved, _ := json.Marshal(fields)
+		if _, err := old.Decode(moved); err == nil || err.Error() != `encrypt: field "ssn": cipher: message authentication failed` {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("no id", func(t *testing.T) {
+		if _, err := old.Encode(json.RawMessage(`{"ssn":"123"}`)); err == nil || err.Error() != "encrypt: document _id required" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestDecodeStrict(t *testing.T) {
+	fields, _ := New("k1", key1, "ssn")
+	fields.SetStrict(true)
+	whole, _ := New("k1", key1)
+	whole.SetStrict(true)
+	tests := []struct {
+		name  string
+		codec *AESGCM
+		doc   string
+		err   string
+	}{
+		{
+			name:  "plaintext field",
+			codec: fields,
+			doc:   `{"_id":"bob","ssn":"123"}`,
+			err:   `encrypt: field "ssn" is not encrypted`,
+		},
+		{
+			name:  "other fields",
+			codec: fields,
+			doc:   `{"_id":"bob","name":"Bob"}`,
+		},
+		{
+			name:  "plaintext document",
+			codec: whole,
+			doc:   `{"_id":"bob","name":"Bob"}`,
+			err:   `encrypt: document "bob" is not encrypted`,
+		},
+		{
+			name:  "deleted document",
+			codec: whole,
+			doc:   `{"_id":"bob","_rev":"2-xxx","_deleted":true}`,
+		},
+		{
+			name:  "design document",
+			codec: whole,
+			doc:   `{"_id":"_design/foo","views":{}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.codec.Decode(json.RawMessage(test.doc))
+			if !testy.ErrorMatches(test.err, err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+	t.Run("encrypted", func(t *testing.T) {
+		encoded, err := whole.Encode(json.RawMessage(`{"_id":"bob","name":"Bob"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := whole.Decode(encoded); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestCodec(t *testing.T) {
+	ctx := context.Background()
+	c, err := New("k1", key1, "ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.New("memory", "", kivik.WithCodec(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "people"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("people")
+	if _, err := db.Put(ctx, "bob", map[string]string{"name": "Bob", "ssn": "123-45-6789"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "bob").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["ssn"] != "123-45-6789" {
+		t.Errorf("Unexpected decoded document: %v", doc)
+	}
+
+	raw := client.DB("people", kivik.WithCodec(nil))
+	rows := raw.AllDocs(ctx, kivik.Options{"include_docs": true})
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	if err := rows.ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if _, ok := doc["ssn"].(map[string]interface{}); !ok {
+		t.Errorf("Expected encrypted field, got: %v", doc["ssn"])
+	}
+}
+
+func TestCodecDesignDoc(t *testing.T) {
+	ctx := context.Background()
+	c, err := New("k1", key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.New("memory", "", kivik.WithCodec(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "people"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("people")
+	const ddoc = `{"language":"javascript","views":{"by_name":{"map":"function(doc) { emit(doc.name) }"}}}`
+	if _, err := db.Put(ctx, "_design/people", json.RawMessage(ddoc)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.BulkDocs(ctx, []interface{}{map[string]interface{}{"_id": "_design/bulk", "language": "javascript"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := client.DB("people", kivik.WithCodec(nil))
+	var doc map[string]interface{}
+	if err := raw.Get(ctx, "_design/people").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc[Field]; ok || doc["views"] == nil {
+		t.Errorf("Expected unencrypted design doc, got: %v", doc)
+	}
+	if err := raw.Get(ctx, "_design/bulk").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["language"] != "javascript" {
+		t.Errorf("Expected unencrypted design doc, got: %v", doc)
+	}
+}
@@ -0,0 +1,352 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package expiry deletes documents once they expire.
+//
+// CouchDB has no native support for expiring documents. With this package,
+// documents opt in to expiry by carrying an expiry field, by default
+// "expires_at", holding the time after which the document should be removed,
+// in seconds since the Unix epoch:
+//
+//	{"_id": "session:abc", "user": "bob", "expires_at": 1735689600}
+//
+// A [Janitor] periodically finds expired documents, with a Mango query, or a
+// view, and deletes them, or purges them, if configured:
+//
+//	j := expiry.New(db, expiry.Config{Interval: time.Minute})
+//	if err := j.EnsureIndex(ctx); err != nil {
+//	    return err
+//	}
+//	go j.Run(ctx)
+//
+// Documents are removed at the next sweep after they expire, so they remain
+// readable for up to Config.Interval after their expiry time. Readers which
+// must not see expired documents should check the expiry field themselves,
+// for instance with [Expired].
+//
+// A document which is updated between being found and being deleted is left
+// in place, and reconsidered at the next sweep, so that extending a
+// document's expiry is never lost.
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/strutil"
+)
+
+// DefaultField is the name of the expiry field, unless configured otherwise.
+const DefaultField = "expires_at"
+
+// DefaultInterval is the time between sweeps when Config.Interval is unset.
+const DefaultInterval = time.Minute
+
+// DefaultBatchSize is the number of documents removed per request when
+// Config.BatchSize is unset.
+const DefaultBatchSize = 100
+
+// IndexName is the name of the Mango index created by [Janitor.EnsureIndex].
+const IndexName = "kivik-expiry"
+
+// Config configures a [Janitor].
+type Config struct {
+	// Field is the name of the top-level field holding each document's
+	// expiry time, in seconds since the Unix epoch.
+	Field string
+	// View, if set, is the name of a view, in the form "ddoc/view", used to
+	// find expired documents in place of a Mango query. Its keys must be
+	// expiry times, and its values the documents' revisions, as emitted by
+	// the map function returned by [MapFunc].
+	View string
+	// Interval is the time between sweeps made by [Janitor.Run].
+	Interval time.Duration
+	// BatchSize is the maximum number of documents removed per request.
+	BatchSize int
+	// Purge causes expired documents to be purged, rather than deleted, so
+	// that no tombstone remains. Purges are not replicated.
+	Purge bool
+	// DryRun causes sweeps to report the expired documents found, without
+	// removing them.
+	DryRun bool
+	// OnSweep, if set, is called after each sweep made by [Janitor.Run],
+	// with its result, or error.
+	OnSweep func(*Result, error)
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+}
+
+// Result reports the outcome of a single sweep.
+type Result struct {
+	// Expired lists the IDs of the expired documents found.
+	Expired []string
+	// Removed is the number of documents deleted or purged. It is always 0
+	// for a dry run.
+	Removed int
+	// Skipped is the number of expired documents which could not be removed,
+	// usually because they were updated concurrently.
+	Skipped int
+}
+
+// Stats are the cumulative counters of a [Janitor].
+type Stats struct {
+	Sweeps  int64
+	Errors  int64
+	Expired int64
+	Removed int64
+	Skipped int64
+	// LastSweep is the time at which the last sweep started.
+	LastSweep time.Time
+	// LastError is the error from the last sweep, if it failed.
+	LastError error
+}
+
+// Janitor removes expired documents from a database.
+type Janitor struct {
+	db     *kivik.DB
+	config Config
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a janitor for db.
+func New(db *kivik.DB, config Config) *Janitor {
+	if config.Field == "" {
+		config.Field = DefaultField
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Janitor{db: db, config: config}
+}
+
+// MapFunc returns the JavaScript source of a map function suitable for
+// Config.View, emitting the expiry time and revision of each document with
+// the expiry field field.
+func MapFunc(field string) string {
+	f, _ := json.Marshal(field)
+	return fmt.Sprintf("function(doc) { var t = doc[%s]; if (typeof t === 'number') { emit(t, doc._rev); } }", f)
+}
+
+// Expired returns true if doc, which may be any value which marshals to a
+// JSON object, has an expiry time in field which is not after now.
+func Expired(doc interface{}, field string, now time.Time) bool {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	var t float64
+	if err := json.Unmarshal(fields[field], &t); err != nil {
+		return false
+	}
+	return t <= float64(now.Unix())
+}
+
+// EnsureIndex creates the Mango index used to find expired documents. It has
+// no effect when Config.View is set.
+func (j *Janitor) EnsureIndex(ctx context.Context) error {
+	if j.config.View != "" {
+		return nil
+	}
+	return j.db.CreateIndex(ctx, "", IndexName, map[string]interface{}{
+		"fields": []string{j.config.Field},
+	})
+}
+
+// Stats returns a snapshot of the janitor's counters.
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+// Run sweeps the database immediately, and then every Config.Interval, until
+// ctx is cancelled. Errors from individual sweeps are reported to
+// Config.OnSweep, and in the stats, and do not stop Run.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+	for {
+		result, err := j.Sweep(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if j.config.OnSweep != nil {
+			j.config.OnSweep(result, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep finds the documents which have expired, and removes them, unless
+// Config.DryRun is set. If removing a batch fails as a whole, Sweep stops,
+// and returns the result so far along with the error.
+func (j *Janitor) Sweep(ctx context.Context) (*Result, error) {
+	start := j.config.Now()
+	result, err := j.sweep(ctx, start)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stats.Sweeps++
+	j.stats.LastSweep = start
+	j.stats.LastError = err
+	if err != nil {
+		j.stats.Errors++
+	}
+	j.stats.Expired += int64(len(result.Expired))
+	j.stats.Removed += int64(result.Removed)
+	j.stats.Skipped += int64(result.Skipped)
+	return result, err
+}
+
+type expiredDoc struct {
+	ID  string
+	Rev string
+}
+
+func (j *Janitor) sweep(ctx context.Context, now time.Time) (*Result, error) {
+	result := &Result{}
+	rs, err := j.query(ctx, now)
+	if err != nil {
+		return result, err
+	}
+	defer rs.Close() // nolint:errcheck
+	batch := make([]expiredDoc, 0, j.config.BatchSize)
+	for rs.Next() {
+		doc, err := j.scan(rs)
+		if err != nil {
+			return result, err
+		}
+		result.Expired = append(result.Expired, doc.ID)
+		if j.config.DryRun {
+			continue
+		}
+		batch = append(batch, doc)
+		if len(batch) == j.config.BatchSize {
+			if err := j.remove(ctx, batch, result); err != nil {
+				return result, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return result, err
+	}
+	return result, j.remove(ctx, batch, result)
+}
+
+func (j *Janitor) query(ctx context.Context, now time.Time) (kivik.ResultSet, error) {
+	cutoff := now.Unix()
+	if j.config.View != "" {
+		ddoc, view, ok := strutil.Cut(strings.TrimPrefix(j.config.View, "_design/"), "/")
+		if !ok || ddoc == "" || view == "" {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "expiry: invalid view name " + j.config.View}
+		}
+		return j.db.Query(ctx, ddoc, view, kivik.EndKey(cutoff)), nil
+	}
+	return j.db.Find(ctx, map[string]interface{}{
+		"selector": map[string]interface{}{
+			j.config.Field: map[string]interface{}{"$lte": cutoff},
+		},
+		"fields": []string{"_id", "_rev"},
+		"limit":  math.MaxInt32,
+	}), nil
+}
+
+func (j *Janitor) scan(rs kivik.ResultSet) (expiredDoc, error) {
+	var doc expiredDoc
+	if j.config.View != "" {
+		var err error
+		if doc.ID, err = rs.ID(); err != nil {
+			return doc, err
+		}
+		err = rs.ScanValue(&doc.Rev)
+		return doc, err
+	}
+	var meta struct {
+		ID  string `json:"_id"`
+		Rev string `json:"_rev"`
+	}
+	err := rs.ScanDoc(&meta)
+	return expiredDoc{ID: meta.ID, Rev: meta.Rev}, err
+}
+
+// remove deletes or purges batch, and records the outcome in result.
+func (j *Janitor) remove(ctx context.Context, batch []expiredDoc, result *Result) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if j.config.Purge {
+		revs := make(map[string][]string, len(batch))
+		for _, doc := range batch {
+			revs[doc.ID] = append(revs[doc.ID], doc.Rev)
+		}
+		purged, err := j.db.Purge(ctx, revs)
+		if err != nil {
+			return err
+		}
+		for _, doc := range batch {
+			if contains(purged.Purged[doc.ID], doc.Rev) {
+				result.Removed++
+			} else {
+				result.Skipped++
+			}
+		}
+		return nil
+	}
+	docs := make([]interface{}, len(batch))
+	for i, doc := range batch {
+		docs[i] = map[string]interface{}{"_id": doc.ID, "_rev": doc.Rev, "_deleted": true}
+	}
+	results, err := j.db.BulkDocs(ctx, docs)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			result.Skipped++
+			continue
+		}
+		result.Removed++
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+	"github.com/go-kivik/kivik/v4/x/views"
+)
+
+// byTime is the view expiry/by_time, as defined by MapFunc(DefaultField).
+var byTime = views.DesignDoc{
+	ID: "_design/expiry",
+	Views: map[string]views.View{
+		"by_time": {
+			Map: func(doc map[string]interface{}, emit views.Emitter) {
+				if t, ok := doc[DefaultField].(float64); ok {
+					emit(t, doc["_rev"])
+				}
+			},
+		},
+	},
+}
+
+var now = time.Unix(1700000000, 0)
+
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDB(ctx, "sessions"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.NewClientFromDriverClient(views.NewClient(proxydb.NewClient(memory), byTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("sessions")
+	for id, doc := range map[string]interface{}{
+		"a": map[string]interface{}{"expires_at": now.Unix() - 60},
+		"b": map[string]interface{}{"expires_at": now.Unix()},
+		"c": map[string]interface{}{"expires_at": now.Unix() + 60},
+		"d": map[string]interface{}{"user": "bob"},
+		"e": map[string]interface{}{"expires_at": now.Unix() - 3600},
+	} {
+		if _, err := db.Put(ctx, id, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func remaining(t *testing.T, db *kivik.DB) []string {
+	t.Helper()
+	rs := db.AllDocs(context.Background())
+	defer rs.Close() // nolint:errcheck
+	var ids []string
+	for rs.Next() {
+		id, _ := rs.ID()
+		ids = append(ids, id)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestSweep(t *testing.T) {
+	type tt struct {
+		config        Config
+		want          *Result
+		wantRemaining []string
+		status        int
+		err           string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("mango", tt{
+		config:        Config{BatchSize: 2},
+		want:          &Result{Expired: []string{"a", "b", "e"}, Removed: 3},
+		wantRemaining: []string{"c", "d"},
+	})
+	tests.Add("view", tt{
+		config: Config{View: "_design/expiry/by_time"},
+		// The view is ordered by expiry time.
+		want:          &Result{Expired: []string{"e", "a", "b"}, Removed: 3},
+		wantRemaining: []string{"c", "d"},
+	})
+	tests.Add("dry run", tt{
+		config:        Config{DryRun: true},
+		want:          &Result{Expired: []string{"a", "b", "e"}},
+		wantRemaining: []string{"a", "b", "c", "d", "e"},
+	})
+	tests.Add("invalid view", tt{
+		config:        Config{View: "expiry"},
+		want:          &Result{},
+		wantRemaining: []string{"a", "b", "c", "d", "e"},
+		status:        http.StatusBadRequest,
+		err:           "expiry: invalid view name expiry",
+	})
+	tests.Add("purge not supported", tt{
+		config:        Config{Purge: true},
+		want:          &Result{Expired: []string{"a", "b", "e"}},
+		wantRemaining: []string{"a", "b", "c", "d", "e"},
+		status:        http.StatusNotImplemented,
+		err:           "kivik: purge not supported by driver",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		db := newDB(t)
+		tt.config.Now = func() time.Time { return now }
+		j := New(db, tt.config)
+		got, err := j.Sweep(context.Background())
+		if d := testy.DiffInterface(tt.want, got); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(tt.wantRemaining, remaining(t, db)); d != nil {
+			t.Errorf("Unexpected remaining documents:\n%s", d)
+		}
+		stats := j.Stats()
+		if stats.Sweeps != 1 || stats.Expired != int64(len(tt.want.Expired)) || stats.Removed != int64(tt.want.Removed) || !stats.LastSweep.Equal(now) {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if (stats.Errors == 1) != (tt.err != "") {
+			t.Errorf("Unexpected error count: %d", stats.Errors)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestSweepSkipsUpdated(t *testing.T) {
+	db := newDB(t)
+	j := New(db, Config{Now: func() time.Time { return now }})
+	// A stale revision stands in for a document updated between being found
+	// and being deleted.
+	result := &Result{}
+	if err := j.remove(context.Background(), []expiredDoc{{ID: "a", Rev: "1-stale"}}, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Skipped != 1 || result.Removed != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if d := testy.DiffInterface([]string{"a", "b", "c", "d", "e"}, remaining(t, db)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestRun(t *testing.T) {
+	db := newDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sweeps := make(chan *Result, 10)
+	j := New(db, Config{
+		Interval: time.Millisecond,
+		Now:      func() time.Time { return now },
+		OnSweep: func(result *Result, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			sweeps <- result
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		j.Run(ctx)
+		close(done)
+	}()
+	if first := <-sweeps; first.Removed != 3 {
+		t.Errorf("Expected 3 documents removed by the first sweep, got %d", first.Removed)
+	}
+	if second := <-sweeps; second.Removed != 0 {
+		t.Errorf("Expected no documents removed by the second sweep, got %d", second.Removed)
+	}
+	cancel()
+	<-done
+	if stats := j.Stats(); stats.Removed != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	tests := []struct {
+		doc  interface{}
+		want bool
+	}{
+		{doc: map[string]interface{}{"expires_at": now.Unix()}, want: true},
+		{doc: map[string]interface{}{"expires_at": now.Unix() + 1}, want: false},
+		{doc: json.RawMessage(`{"expires_at":1}`), want: true},
+		{doc: map[string]interface{}{"expires_at": "soon"}, want: false},
+		{doc: map[string]interface{}{}, want: false},
+	}
+	for _, test := range tests {
+		if got := Expired(test.doc, DefaultField, now); got != test.want {
+			t.Errorf("Expired(%v) = %t", test.doc, got)
+		}
+	}
+}
+
+func TestEnsureIndex(t *testing.T) {
+	db := newDB(t)
+	j := New(db, Config{})
+	if err := j.EnsureIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(db, Config{View: "expiry/by_time"}).EnsureIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapFunc(t *testing.T) {
+	want := `function(doc) { var t = doc["expires_at"]; if (typeof t === 'number') { emit(t, doc._rev); } }`
+	if got := MapFunc(DefaultField); got != want {
+		t.Errorf("Unexpected map function: %s", got)
+	}
+}
@@ -0,0 +1,268 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package fixtures loads documents from a directory into a database, for use
+// in integration tests:
+//
+//	func TestSomething(t *testing.T) {
+//	    db := client.DB("test")
+//	    fixtures.LoadT(t, db, "testdata/animals", fixtures.Config{
+//	        Vars: map[string]interface{}{"Owner": "bob"},
+//	    })
+//	    // The fixtures are removed when the test ends.
+//	}
+//
+// The directory uses the same layout as the file system driver,
+// [github.com/go-kivik/kivik/v4/x/fsdb]: each document is stored in a file
+// named after its ID, with the extension .json, and its attachments as files
+// in a directory named after the ID, with the extension .attachments. IDs are
+// escaped as for URL paths, so "_design/foo" is stored as
+// "_design%2Ffoo.json". An _id field in a document overrides its file name.
+// Other formats may be loaded by registering a decoder for their extension in
+// [Config].Decoders. Decoders for YAML are provided by the separate module
+// [github.com/go-kivik/kivik/v4/x/fixtures/yaml]. Any _rev field is ignored.
+//
+// Document files, but not attachments, are expanded as [text/template]
+// templates with the variables in [Config].Vars, if any, before being
+// decoded.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Config configures the loading of fixtures.
+type Config struct {
+	// Vars, if not nil, are the variables available to document templates.
+	// Templates which refer to missing variables fail to load.
+	Vars map[string]interface{}
+
+	// Decoders maps file extensions, including the leading dot, to functions
+	// which decode documents in that format, such as those returned by
+	// [github.com/go-kivik/kivik/v4/x/fixtures/yaml.Decoders]. Files
+	// with the extension .json are decoded as JSON, unless overridden.
+	Decoders map[string]func(data []byte, v interface{}) error
+
+	// Replace causes existing documents to be overwritten. Otherwise, loading
+	// a document which already exists fails with a conflict.
+	Replace bool
+}
+
+// attachmentsExt is the extension of attachment directories.
+const attachmentsExt = ".attachments"
+
+// Set is a set of fixtures loaded into a database.
+type Set struct {
+	db  *kivik.DB
+	ids []string
+}
+
+// IDs returns the IDs of the loaded documents, in the order in which they
+// were loaded.
+func (s *Set) IDs() []string {
+	return append([]string(nil), s.ids...)
+}
+
+// Teardown deletes the loaded documents, in reverse order. Documents which no
+// longer exist are skipped.
+func (s *Set) Teardown(ctx context.Context) error {
+	for i := len(s.ids) - 1; i >= 0; i-- {
+		id := s.ids[i]
+		rev, err := s.db.GetRev(ctx, id)
+		if kivik.HTTPStatus(err) == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Delete(ctx, id, rev); err != nil && kivik.HTTPStatus(err) != http.StatusNotFound {
+			return err
+		}
+	}
+	s.ids = nil
+	return nil
+}
+
+// LoadT loads the fixtures in dir into db, as for [Load], failing the test
+// immediately on error. The fixtures are torn down when the test and its
+// subtests complete.
+func LoadT(t testing.TB, db *kivik.DB, dir string, config Config) *Set {
+	t.Helper()
+	set, err := Load(context.Background(), db, dir, config)
+	if set != nil {
+		t.Cleanup(func() {
+			if err := set.Teardown(context.Background()); err != nil {
+				t.Errorf("failed to tear down fixtures: %s", err)
+			}
+		})
+	}
+	if err != nil {
+		t.Fatalf("failed to load fixtures: %s", err)
+	}
+	return set
+}
+
+// Load loads the fixtures in dir into db. Documents are loaded in order of
+// file name. If loading fails part way, the returned Set holds the documents
+// loaded so far, so that they may be torn down.
+func Load(ctx context.Context, db *kivik.DB, dir string, config Config) (*Set, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	set := &Set{db: db}
+	for _, name := range names {
+		ext := filepath.Ext(name)
+		decode := decoder(ext, config)
+		if decode == nil {
+			continue
+		}
+		base := strings.TrimSuffix(name, ext)
+		id, err := url.PathUnescape(base)
+		if err != nil {
+			return set, &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: invalid fixture file name " + name, Err: err}
+		}
+		doc, err := readDoc(filepath.Join(dir, name), decode, config.Vars)
+		if err != nil {
+			return set, err
+		}
+		if docID, ok := doc["_id"].(string); ok && docID != "" {
+			id = docID
+		}
+		delete(doc, "_rev")
+		if err := set.put(ctx, id, doc, config.Replace); err != nil {
+			return set, err
+		}
+		if err := set.putAttachments(ctx, id, filepath.Join(dir, base+attachmentsExt)); err != nil {
+			return set, err
+		}
+	}
+	return set, nil
+}
+
+// decoder returns the decoder for files with the extension ext, or nil.
+func decoder(ext string, config Config) func([]byte, interface{}) error {
+	if decode, ok := config.Decoders[ext]; ok {
+		return decode
+	}
+	if ext == ".json" {
+		return json.Unmarshal
+	}
+	return nil
+}
+
+func readDoc(path string, decode func([]byte, interface{}) error, vars map[string]interface{}) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if vars != nil {
+		tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, vars); err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+		data = buf.Bytes()
+	}
+	var doc map[string]interface{}
+	if err := decode(data, &doc); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: invalid fixture " + path, Err: err}
+	}
+	if doc == nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: fixture " + path + " is not an object"}
+	}
+	return doc, nil
+}
+
+func (s *Set) put(ctx context.Context, id string, doc map[string]interface{}, replace bool) error {
+	_, err := s.db.Put(ctx, id, doc)
+	if replace && kivik.HTTPStatus(err) == http.StatusConflict {
+		var rev string
+		if rev, err = s.db.GetRev(ctx, id); err != nil {
+			return err
+		}
+		doc["_rev"] = rev
+		_, err = s.db.Put(ctx, id, doc)
+	}
+	if err != nil {
+		return err
+	}
+	s.ids = append(s.ids, id)
+	return nil
+}
+
+// putAttachments stores each file in dir, if it exists, as an attachment to
+// the document id.
+func (s *Set) putAttachments(ctx context.Context, id, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filename, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: invalid attachment file name " + entry.Name(), Err: err}
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(filename))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		rev, err := s.db.GetRev(ctx, id)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		_, err = s.db.PutAttachment(ctx, id, &kivik.Attachment{
+			Filename:    filename,
+			ContentType: contentType,
+			Content:     f,
+		}, kivik.Rev(rev))
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "animals"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("animals")
+}
+
+func config() Config {
+	return Config{
+		Vars:     map[string]interface{}{"Owner": "bob"},
+		Decoders: map[string]func([]byte, interface{}) error{".alt": json.Unmarshal},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	set, err := Load(ctx, db, "testdata/animals", config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"_design/zoo", "cow", "wilbur"}, set.IDs()); d != nil {
+		t.Error(d)
+	}
+
+	var cow map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&cow); err != nil {
+		t.Fatal(err)
+	}
+	if cow["owner"] != "bob" || cow["name"] != "Bessie" {
+		t.Errorf("Unexpected document: %v", cow)
+	}
+	att, err := db.GetAttachment(ctx, "cow", "sound.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if string(content) != "moo\n" || !strings.HasPrefix(att.ContentType, "text/plain") {
+		t.Errorf("Unexpected attachment: %s %q", att.ContentType, content)
+	}
+
+	if _, err := Load(ctx, db, "testdata/animals", config()); kivik.HTTPStatus(err) != http.StatusConflict {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+
+	replace := config()
+	replace.Replace = true
+	if _, err := Load(ctx, db, "testdata/animals", replace); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := set.Teardown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"_design/zoo", "cow", "wilbur"} {
+		if _, err := db.GetRev(ctx, id); kivik.HTTPStatus(err) != http.StatusNotFound {
+			t.Errorf("Expected %s to be deleted, got %v", id, err)
+		}
+	}
+}
+
+func TestLoadMissingVar(t *testing.T) {
+	_, err := Load(context.Background(), newDB(t), "testdata/animals", Config{Vars: map[string]interface{}{}})
+	testy.StatusErrorRE(t, `map has no entry for key "Owner"`, http.StatusBadRequest, err)
+}
+
+func TestLoadT(t *testing.T) {
+	db := newDB(t)
+	t.Run("load", func(t *testing.T) {
+		LoadT(t, db, "testdata/animals", config())
+		if _, err := db.GetRev(context.Background(), "cow"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := db.GetRev(context.Background(), "cow"); kivik.HTTPStatus(err) != http.StatusNotFound {
+		t.Errorf("Expected fixtures to be torn down, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/go-kivik/kivik/v4/x/fixtures/yaml
+
+go 1.17
+
+replace github.com/go-kivik/kivik/v4 => ../../../
+
+require (
+	github.com/go-kivik/kivik/v4 v4.0.0
+	gitlab.com/flimzy/testy v0.12.4
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/Masterminds/semver/v3 v3.1.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/otiai10/copy v1.7.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
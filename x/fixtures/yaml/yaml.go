@@ -0,0 +1,82 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package yaml decodes YAML fixtures for
+// [github.com/go-kivik/kivik/v4/x/fixtures]. It is a separate module, so that
+// users of fixtures who do not need YAML do not depend on a YAML parser:
+//
+//	fixtures.LoadT(t, db, "testdata/animals", fixtures.Config{
+//	    Decoders: yaml.Decoders(),
+//	})
+//
+// Documents in files with the extension .yaml or .yml are then loaded
+// alongside those in .json files.
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Decoders returns decoders for the extensions .yaml and .yml, for use as
+// [fixtures.Config].Decoders. Further decoders may be added to the returned
+// map.
+func Decoders() map[string]func(data []byte, v interface{}) error {
+	return map[string]func([]byte, interface{}) error{
+		".yaml": Unmarshal,
+		".yml":  Unmarshal,
+	}
+}
+
+// Unmarshal decodes the YAML document data into v, as if it had been
+// converted to JSON and decoded with [encoding/json.Unmarshal], so that
+// fixtures decode the same way whichever format they are written in. Keys of
+// mappings which are not strings, such as numbers, are formatted as strings,
+// and timestamps are formatted as RFC 3339 strings. Only the first document
+// in data is decoded.
+func Unmarshal(data []byte, v interface{}) error {
+	var doc interface{}
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	body, err := json.Marshal(normalize(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// normalize converts mappings with non-string keys, which cannot be
+// represented in JSON, to mappings with string keys.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = normalize(val)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalize(val)
+		}
+		return t
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package yaml
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/fixtures"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func TestUnmarshal(t *testing.T) {
+	var doc map[string]interface{}
+	err := Unmarshal([]byte("a: 1\nb: [x, {2: y}]\nc: 2020-04-01T00:00:00Z\n"), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": float64(1),
+		"b": []interface{}{"x", map[string]interface{}{"2": "y"}},
+		"c": "2020-04-01T00:00:00Z",
+	}
+	if d := testy.DiffInterface(want, doc); d != nil {
+		t.Error(d)
+	}
+	if err := Unmarshal([]byte("a: [b"), &doc); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	set := fixtures.LoadT(t, db, "testdata/animals", fixtures.Config{
+		Vars:     map[string]interface{}{"Owner": "bob"},
+		Decoders: Decoders(),
+	})
+	if d := testy.DiffInterface([]string{"_design/zoo", "cow"}, set.IDs()); d != nil {
+		t.Error(d)
+	}
+	var cow map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&cow); err != nil {
+		t.Fatal(err)
+	}
+	delete(cow, "_rev")
+	want := map[string]interface{}{
+		"_id":     "cow",
+		"name":    "Bessie",
+		"owner":   "bob",
+		"born":    "2020-04-01T00:00:00Z",
+		"legs":    float64(4),
+		"sounds":  []interface{}{"moo", "low"},
+		"ratings": map[string]interface{}{"1": "docile", "2": "curious"},
+	}
+	if d := testy.DiffInterface(want, cow); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+// AllDocs supports the include_docs, descending, startkey, endkey, key,
+// inclusive_end, skip and limit options.
+func (d *db) AllDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	ids, err := d.docIDs()
+	if err != nil {
+		return nil, err
+	}
+	descending := driverutil.BoolOption(options, "descending")
+	sort.Slice(ids, func(i, j int) bool {
+		return (ids[i] < ids[j]) != descending
+	})
+	startKey, hasStart := driverutil.KeyOption(options, "startkey", "start_key")
+	endKey, hasEnd := driverutil.KeyOption(options, "endkey", "end_key")
+	inclusiveEnd := true
+	if _, ok := options["inclusive_end"]; ok {
+		inclusiveEnd = driverutil.BoolOption(options, "inclusive_end")
+	}
+	if key, ok := driverutil.KeyOption(options, "key"); ok {
+		startKey, endKey = key, key
+		hasStart, hasEnd, inclusiveEnd = true, true, true
+	}
+	before := func(a, b string) bool {
+		if descending {
+			return a > b
+		}
+		return a < b
+	}
+	skip, _ := driverutil.IntOption(options, "skip")
+	limit, hasLimit := driverutil.IntOption(options, "limit")
+	includeDocs := driverutil.BoolOption(options, "include_docs")
+
+	var offset int64
+	var result []*driver.Row
+	for _, id := range ids {
+		if hasStart && before(id, startKey) {
+			offset++
+			continue
+		}
+		if hasEnd && (before(endKey, id) || (!inclusiveEnd && id == endKey)) {
+			break
+		}
+		if skip > 0 {
+			skip--
+			offset++
+			continue
+		}
+		if hasLimit && int64(len(result)) >= limit {
+			break
+		}
+		doc, err := d.readDoc(id)
+		if err == errMissing {
+			// Deleted since the directory was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(id)
+		value, _ := json.Marshal(map[string]string{"rev": doc.rev})
+		row := &driver.Row{
+			ID:    id,
+			Key:   key,
+			Value: bytes.NewReader(value),
+		}
+		if includeDocs {
+			body, err := d.toJSON(doc, false)
+			if err != nil {
+				return nil, err
+			}
+			row.Doc = bytes.NewReader(body)
+		}
+		result = append(result, row)
+	}
+	return driverutil.NewRows(result, offset, int64(len(ids)), ""), nil
+}
@@ -0,0 +1,93 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAllDocs(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newDB(t)
+	for _, id := range []string{"d", "b", "a", "c"} {
+		if _, err := db.Put(ctx, id, map[string]string{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type tst struct {
+		options kivik.Options
+		ids     []string
+		offset  int64
+	}
+	tests := testy.NewTable()
+	tests.Add("default", tst{ids: []string{"a", "b", "c", "d"}})
+	tests.Add("range", tst{
+		options: kivik.Options{"startkey": "b", "endkey": "c"},
+		ids:     []string{"b", "c"},
+		offset:  1,
+	})
+	tests.Add("descending with limit", tst{
+		options: kivik.Options{"descending": true, "limit": 2},
+		ids:     []string{"d", "c"},
+	})
+	tests.Add("skip", tst{
+		options: kivik.Options{"skip": 3},
+		ids:     []string{"d"},
+		offset:  3,
+	})
+	tests.Add("key", tst{
+		options: kivik.Options{"key": "c"},
+		ids:     []string{"c"},
+		offset:  2,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		rows := db.AllDocs(ctx, test.options)
+		var ids []string
+		for rows.Next() {
+			id, _ := rows.ID()
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(test.ids, ids); d != nil {
+			t.Error(d)
+		}
+		meta, _ := rows.Metadata()
+		if meta.TotalRows != 4 || meta.Offset != test.offset {
+			t.Errorf("Unexpected metadata: %+v", meta)
+		}
+	})
+
+	t.Run("include_docs", func(t *testing.T) {
+		rows := db.AllDocs(ctx, kivik.Options{"include_docs": true, "limit": 1})
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["id"] != "a" {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		_ = rows.Close()
+	})
+}
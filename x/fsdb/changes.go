@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+// Changes returns a normal changes feed, with one entry for each existing
+// document. The update sequence of each document is its file's modification
+// time, in nanoseconds since the Unix epoch, so the since option may be used to
+// fetch only documents modified since a previous call. Deletions are not
+// reported. The built-in _doc_ids, _selector and _design filters are
+// supported.
+func (d *db) Changes(_ context.Context, options map[string]interface{}) (driver.Changes, error) {
+	switch feed, _ := options["feed"].(string); feed {
+	case "", "normal":
+	default:
+		return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: " + feed + " changes feed not supported by the file driver"}
+	}
+	filter, err := driverutil.ChangesFilter(options, "file")
+	if err != nil {
+		return nil, err
+	}
+	ids, err := d.docIDs()
+	if err != nil {
+		return nil, err
+	}
+	since, _ := driverutil.IntOption(options, "since")
+	type entry struct {
+		id  string
+		seq int64
+	}
+	entries := make([]entry, 0, len(ids))
+	for _, id := range ids {
+		info, err := os.Stat(d.docPath(id))
+		if err != nil {
+			continue
+		}
+		seq := info.ModTime().UnixNano()
+		if seq <= since {
+			continue
+		}
+		ok, err := filter(id, d.docBody(id))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			entries = append(entries, entry{id: id, seq: seq})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].seq == entries[j].seq {
+			return entries[i].id < entries[j].id
+		}
+		return entries[i].seq < entries[j].seq
+	})
+	if limit, ok := driverutil.IntOption(options, "limit"); ok && limit > 0 && limit < int64(len(entries)) {
+		entries = entries[:limit]
+	}
+	includeDocs := driverutil.BoolOption(options, "include_docs")
+	result := make([]*driver.Change, 0, len(entries))
+	lastSeq := strconv.FormatInt(since, 10)
+	for _, e := range entries {
+		doc, err := d.readDoc(e.id)
+		if err == errMissing {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		change := &driver.Change{
+			ID:      e.id,
+			Seq:     strconv.FormatInt(e.seq, 10),
+			Changes: driver.ChangedRevs{doc.rev},
+		}
+		if includeDocs {
+			if change.Doc, err = d.toJSON(doc, false); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, change)
+		lastSeq = change.Seq
+	}
+	return driverutil.NewChanges(result, lastSeq), nil
+}
+
+// docBody returns a function which returns the body of the document docID,
+// or nil if it has been deleted.
+func (d *db) docBody(docID string) func() (map[string]interface{}, error) {
+	return func() (map[string]interface{}, error) {
+		doc, err := d.readDoc(docID)
+		if err == errMissing {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.toJSON(doc, false)
+		if err != nil {
+			return nil, err
+		}
+		var body map[string]interface{}
+		err = json.Unmarshal(raw, &body)
+		return body, err
+	}
+}
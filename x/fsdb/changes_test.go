@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	db, dir := newDB(t)
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"b", "a", "c"} {
+		if _, err := db.Put(ctx, id, map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, id+".json"), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collect := func(options kivik.Options) ([]string, string) {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var ids []string
+		for feed.Next() {
+			ids = append(ids, feed.ID())
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := feed.Metadata()
+		return ids, meta.LastSeq
+	}
+	ids, lastSeq := collect(nil)
+	if d := testy.DiffInterface([]string{"b", "a", "c"}, ids); d != nil {
+		t.Error(d)
+	}
+	ids, _ = collect(kivik.Options{"since": base.Add(30 * time.Second).UnixNano()})
+	if d := testy.DiffInterface([]string{"a", "c"}, ids); d != nil {
+		t.Error(d)
+	}
+	if ids, _ = collect(kivik.Options{"since": lastSeq}); len(ids) != 0 {
+		t.Errorf("Expected no further changes, got %v", ids)
+	}
+}
+
+func TestChangesFilters(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newDB(t)
+	_, _ = db.Put(ctx, "a", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "b", map[string]string{"type": "horse"})
+	_, _ = db.Put(ctx, "c", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "_design/foo", map[string]string{})
+
+	ids := func(t *testing.T, options kivik.Options) []string {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var ids []string
+		for feed.Next() {
+			ids = append(ids, feed.ID())
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	tests := []struct {
+		name    string
+		options kivik.Options
+		want    []string
+	}{
+		{
+			name:    "doc ids",
+			options: kivik.DocIDsFilter("c", "a", "z"),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "selector",
+			options: kivik.SelectorFilter(map[string]interface{}{"type": "cow"}),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "design",
+			options: kivik.DesignFilter(),
+			want:    []string{"_design/foo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := testy.DiffInterface(tt.want, ids(t, tt.options)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+	t.Run("unsupported", func(t *testing.T) {
+		err := db.Changes(ctx, kivik.Param("filter", "foo/bar")).Err()
+		testutil.CheckError(t, "kivik: foo/bar filter not supported by the file driver", http.StatusNotImplemented, err)
+	})
+}
@@ -0,0 +1,573 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+var (
+	errDatabaseNotFound = &kivik.Error{Status: http.StatusNotFound, Message: "database does not exist"}
+	errConflict         = &kivik.Error{Status: http.StatusConflict, Message: "document update conflict"}
+	errMissing          = &kivik.Error{Status: http.StatusNotFound, Message: "missing"}
+)
+
+const (
+	docExt         = ".json"
+	attachmentsExt = ".attachments"
+	securityFile   = ".security.json"
+)
+
+type db struct {
+	client *client
+	name   string
+	path   string
+}
+
+var (
+	_ driver.DB        = &db{}
+	_ driver.RevGetter = &db{}
+)
+
+func (d *db) docPath(docID string) string {
+	return filepath.Join(d.path, escape(docID)+docExt)
+}
+
+func (d *db) attachmentPath(docID, filename string) string {
+	return filepath.Join(d.attachmentsDir(docID), escape(filename))
+}
+
+func (d *db) attachmentsDir(docID string) string {
+	return filepath.Join(d.path, escape(docID)+attachmentsExt)
+}
+
+// checkDB returns an error if the database does not exist.
+func (d *db) checkDB() error {
+	info, err := os.Stat(d.path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return errDatabaseNotFound
+	}
+	return err
+}
+
+// storedDoc is a document as read from disk.
+type storedDoc struct {
+	id          string
+	rev         string
+	body        map[string]interface{}
+	attachments map[string]*attachmentMeta
+}
+
+type attachmentMeta struct {
+	ContentType string `json:"content_type"`
+	Digest      string `json:"digest"`
+	Length      int64  `json:"length"`
+	RevPos      int64  `json:"revpos"`
+}
+
+func (s *storedDoc) generation() int64 {
+	gen, _ := strconv.ParseInt(strings.SplitN(s.rev, "-", 2)[0], 10, 64)
+	return gen
+}
+
+// readDoc reads the current revision of docID from disk.
+func (d *db) readDoc(docID string) (*storedDoc, error) {
+	if err := d.checkDB(); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(d.docPath(docID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStored(docID, raw)
+}
+
+func parseStored(docID string, raw []byte) (*storedDoc, error) {
+	var doc struct {
+		Rev         string                     `json:"_rev"`
+		Attachments map[string]*attachmentMeta `json:"_attachments"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, &kivik.Error{Status: http.StatusInternalServerError, Message: "corrupt document " + docID, Err: err}
+	}
+	var body map[string]interface{}
+	_ = json.Unmarshal(raw, &body)
+	for k := range body {
+		if strings.HasPrefix(k, "_") {
+			delete(body, k)
+		}
+	}
+	rev := doc.Rev
+	if rev == "" {
+		// A hand-written document; derive a stable revision from its content.
+		sum := md5.Sum(raw)
+		rev = "1-" + hex.EncodeToString(sum[:])
+	}
+	return &storedDoc{
+		id:          docID,
+		rev:         rev,
+		body:        body,
+		attachments: doc.Attachments,
+	}, nil
+}
+
+// toJSON renders the stored document. If withAttachments is true, attachment
+// content is read from disk and included inline.
+func (d *db) toJSON(doc *storedDoc, withAttachments bool) ([]byte, error) {
+	out := make(map[string]interface{}, len(doc.body)+3)
+	for k, v := range doc.body {
+		out[k] = v
+	}
+	out["_id"] = doc.id
+	out["_rev"] = doc.rev
+	if len(doc.attachments) > 0 {
+		atts := make(map[string]interface{}, len(doc.attachments))
+		for name, meta := range doc.attachments {
+			att := map[string]interface{}{
+				"content_type": meta.ContentType,
+				"digest":       meta.Digest,
+				"length":       meta.Length,
+				"revpos":       meta.RevPos,
+			}
+			if withAttachments {
+				data, err := os.ReadFile(d.attachmentPath(doc.id, name))
+				if err != nil {
+					return nil, err
+				}
+				att["data"] = data
+			} else {
+				att["stub"] = true
+			}
+			atts[name] = att
+		}
+		out["_attachments"] = atts
+	}
+	return json.Marshal(out)
+}
+
+// writeDoc stores doc as the next revision after prev, which is nil for a new
+// document, and returns the new revision.
+func (d *db) writeDoc(doc *storedDoc, prev *storedDoc) (string, error) {
+	var gen int64
+	var prevRev string
+	if prev != nil {
+		gen, prevRev = prev.generation(), prev.rev
+	}
+	gen++
+	h := md5.New()
+	_ = json.NewEncoder(h).Encode([]interface{}{prevRev, doc.body, doc.attachments})
+	doc.rev = fmt.Sprintf("%d-%x", gen, h.Sum(nil))
+	for _, meta := range doc.attachments {
+		if meta.RevPos == 0 {
+			meta.RevPos = gen
+		}
+	}
+
+	out := make(map[string]interface{}, len(doc.body)+3)
+	for k, v := range doc.body {
+		out[k] = v
+	}
+	out["_id"] = doc.id
+	out["_rev"] = doc.rev
+	if len(doc.attachments) > 0 {
+		out["_attachments"] = doc.attachments
+	}
+	raw, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if err := writeFile(d.docPath(doc.id), append(raw, '\n')); err != nil {
+		return "", err
+	}
+	return doc.rev, nil
+}
+
+// writeFile writes data to path atomically, by way of a temporary file.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// current returns the current revision of docID, checking that rev matches
+// it. If the document does not exist, nil is returned.
+func (d *db) current(docID, rev string) (*storedDoc, error) {
+	prev, err := d.readDoc(docID)
+	switch {
+	case err == errMissing:
+		if rev != "" {
+			return nil, errConflict
+		}
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case prev.rev != rev:
+		return nil, errConflict
+	}
+	return prev, nil
+}
+
+func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "document must be a JSON object"}
+	}
+	var meta struct {
+		ID          string `json:"_id"`
+		Rev         string `json:"_rev"`
+		Deleted     bool   `json:"_deleted"`
+		Attachments map[string]struct {
+			ContentType string `json:"content_type"`
+			Data        []byte `json:"data"`
+			Stub        bool   `json:"stub"`
+		} `json:"_attachments"`
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if meta.ID != "" && meta.ID != docID {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "document ID does not match"}
+	}
+	for k := range body {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		switch k {
+		case "_id", "_rev", "_deleted", "_attachments", "_revisions", "_conflicts":
+			delete(body, k)
+		default:
+			return "", &kivik.Error{Status: http.StatusBadRequest, Message: "bad special document member: " + k}
+		}
+	}
+	rev := meta.Rev
+	if r := driverutil.StringOption(options, "rev"); r != "" {
+		rev = r
+	}
+	if meta.Deleted {
+		return d.Delete(ctx, docID, map[string]interface{}{"rev": rev})
+	}
+
+	d.client.mu.Lock()
+	defer d.client.mu.Unlock()
+	prev, err := d.current(docID, rev)
+	if err != nil {
+		return "", err
+	}
+	newDoc := &storedDoc{
+		id:          docID,
+		body:        body,
+		attachments: make(map[string]*attachmentMeta, len(meta.Attachments)),
+	}
+	for name, att := range meta.Attachments {
+		if att.Stub {
+			if prev == nil || prev.attachments[name] == nil {
+				return "", &kivik.Error{Status: http.StatusPreconditionFailed, Message: "invalid attachment stub for " + name}
+			}
+			newDoc.attachments[name] = prev.attachments[name]
+			continue
+		}
+		if err := d.writeAttachment(docID, name, att.Data); err != nil {
+			return "", err
+		}
+		newDoc.attachments[name] = newAttachmentMeta(att.ContentType, att.Data)
+	}
+	if prev != nil {
+		for name := range prev.attachments {
+			if _, ok := newDoc.attachments[name]; !ok {
+				_ = os.Remove(d.attachmentPath(docID, name))
+			}
+		}
+	}
+	return d.writeDoc(newDoc, prev)
+}
+
+func newAttachmentMeta(contentType string, data []byte) *attachmentMeta {
+	sum := md5.Sum(data)
+	return &attachmentMeta{
+		ContentType: contentType,
+		Digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		Length:      int64(len(data)),
+	}
+}
+
+func (d *db) writeAttachment(docID, filename string, data []byte) error {
+	path := d.attachmentPath(docID, filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return "", "", &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var meta struct {
+		ID string `json:"_id"`
+	}
+	_ = json.Unmarshal(raw, &meta)
+	docID := meta.ID
+	if docID == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", "", err
+		}
+		docID = hex.EncodeToString(b)
+	}
+	rev, err := d.Put(ctx, docID, json.RawMessage(raw), options)
+	return docID, rev, err
+}
+
+func (d *db) Get(_ context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	doc, err := d.readDoc(docID)
+	if err != nil {
+		return nil, err
+	}
+	if rev := driverutil.StringOption(options, "rev"); rev != "" && rev != doc.rev {
+		return nil, errMissing
+	}
+	body, err := d.toJSON(doc, driverutil.BoolOption(options, "attachments"))
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		Rev:  doc.rev,
+		Body: io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (d *db) GetRev(_ context.Context, docID string, options map[string]interface{}) (string, error) {
+	doc, err := d.readDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	if rev := driverutil.StringOption(options, "rev"); rev != "" && rev != doc.rev {
+		return "", errMissing
+	}
+	return doc.rev, nil
+}
+
+// Delete removes the document and its attachments from disk. The returned
+// revision is that of the deletion, which is not stored.
+func (d *db) Delete(_ context.Context, docID string, options map[string]interface{}) (string, error) {
+	d.client.mu.Lock()
+	defer d.client.mu.Unlock()
+	rev := driverutil.StringOption(options, "rev")
+	if rev == "" {
+		return "", errConflict
+	}
+	prev, err := d.readDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	if prev.rev != rev {
+		return "", errConflict
+	}
+	if err := os.Remove(d.docPath(docID)); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(d.attachmentsDir(docID)); err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(prev.rev))
+	return fmt.Sprintf("%d-%x", prev.generation()+1, sum), nil
+}
+
+// docIDs returns the IDs of all documents in the database, unsorted.
+func (d *db) docIDs() ([]string, error) {
+	if err := d.checkDB(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, docExt) {
+			continue
+		}
+		id, err := unescape(strings.TrimSuffix(name, docExt))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (d *db) Stats(context.Context) (*driver.DBStats, error) {
+	ids, err := d.docIDs()
+	if err != nil {
+		return nil, err
+	}
+	return &driver.DBStats{
+		Name:     d.name,
+		DocCount: int64(len(ids)),
+	}, nil
+}
+
+func (d *db) Compact(context.Context) error             { return d.checkDB() }
+func (d *db) CompactView(context.Context, string) error { return d.checkDB() }
+func (d *db) ViewCleanup(context.Context) error         { return d.checkDB() }
+
+func (d *db) Security(context.Context) (*driver.Security, error) {
+	if err := d.checkDB(); err != nil {
+		return nil, err
+	}
+	sec := &driver.Security{}
+	raw, err := os.ReadFile(filepath.Join(d.path, securityFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return sec, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, sec); err != nil {
+		return nil, err
+	}
+	return sec, nil
+}
+
+func (d *db) SetSecurity(_ context.Context, security *driver.Security) error {
+	if err := d.checkDB(); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(security, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(d.path, securityFile), append(raw, '\n'))
+}
+
+func (d *db) Query(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+	return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: views are not supported by the file driver"}
+}
+
+func (d *db) PutAttachment(_ context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	data, err := io.ReadAll(att.Content)
+	if err != nil {
+		return "", err
+	}
+	d.client.mu.Lock()
+	defer d.client.mu.Unlock()
+	prev, err := d.current(docID, driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return "", err
+	}
+	doc := &storedDoc{
+		id:          docID,
+		body:        map[string]interface{}{},
+		attachments: map[string]*attachmentMeta{},
+	}
+	if prev != nil {
+		doc.body = prev.body
+		for name, meta := range prev.attachments {
+			doc.attachments[name] = meta
+		}
+	}
+	if err := d.writeAttachment(docID, att.Filename, data); err != nil {
+		return "", err
+	}
+	doc.attachments[att.Filename] = newAttachmentMeta(att.ContentType, data)
+	return d.writeDoc(doc, prev)
+}
+
+func (d *db) GetAttachment(_ context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	doc, err := d.readDoc(docID)
+	if err != nil {
+		return nil, err
+	}
+	if rev := driverutil.StringOption(options, "rev"); rev != "" && rev != doc.rev {
+		return nil, errMissing
+	}
+	meta, ok := doc.attachments[filename]
+	if !ok {
+		return nil, errMissing
+	}
+	f, err := os.Open(d.attachmentPath(docID, filename))
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: meta.ContentType,
+		Content:     f,
+		Size:        meta.Length,
+		Digest:      meta.Digest,
+		RevPos:      meta.RevPos,
+	}, nil
+}
+
+func (d *db) DeleteAttachment(_ context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	d.client.mu.Lock()
+	defer d.client.mu.Unlock()
+	prev, err := d.current(docID, driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return "", err
+	}
+	if prev == nil || prev.attachments[filename] == nil {
+		return "", errMissing
+	}
+	doc := &storedDoc{
+		id:          docID,
+		body:        prev.body,
+		attachments: map[string]*attachmentMeta{},
+	}
+	for name, meta := range prev.attachments {
+		if name != filename {
+			doc.attachments[name] = meta
+		}
+	}
+	if err := os.Remove(d.attachmentPath(docID, filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return d.writeDoc(doc, prev)
+}
@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestFixtures(t *testing.T) {
+	client, err := kivik.New("file", "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	var doc map[string]interface{}
+	row := db.Get(context.Background(), "cow")
+	if err := row.ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	rev, _ := row.Rev()
+	if !strings.HasPrefix(rev, "1-") || doc["_rev"] != rev || doc["_id"] != "cow" || doc["name"] != "Bessie" {
+		t.Errorf("Unexpected document: %v", doc)
+	}
+	if err := db.Get(context.Background(), "_design/farm").Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDocumentLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db, dir := newDB(t)
+
+	rev1, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Put(ctx, "cow", map[string]interface{}{"name": "Daisy"})
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+
+	rev2, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Daisy"}, kivik.Options{"rev": rev1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev2, "2-") {
+		t.Errorf("Unexpected rev: %s", rev2)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "cow.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"_id\": \"cow\",\n  \"_rev\": \"" + rev2 + "\",\n  \"name\": \"Daisy\"\n}\n"
+	if d := testy.DiffText(want, string(raw)); d != nil {
+		t.Error(d)
+	}
+	err = db.Get(ctx, "cow", kivik.Options{"rev": rev1}).Err()
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+
+	if _, err := db.Delete(ctx, "cow", rev1); kivik.HTTPStatus(err) != http.StatusConflict {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	if _, err := db.Delete(ctx, "cow", rev2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cow.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected file to be removed, got %v", err)
+	}
+	err = db.Get(ctx, "cow").Err()
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db, dir := newDB(t)
+	rev, err := db.PutAttachment(ctx, "cow", &kivik.Attachment{
+		Filename:    "moo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("moo")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "cow.attachments", "moo.txt"))
+	if err != nil || string(raw) != "moo" {
+		t.Errorf("Unexpected attachment file: %s, %v", raw, err)
+	}
+	att, err := db.GetAttachment(ctx, "cow", "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if string(content) != "moo" || att.ContentType != "text/plain" || att.RevPos != 1 {
+		t.Errorf("Unexpected attachment: %+v (%s)", att, content)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow", kivik.Options{"attachments": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	atts, _ := doc["_attachments"].(map[string]interface{})
+	if moo, _ := atts["moo.txt"].(map[string]interface{}); moo["data"] != "bW9v" {
+		t.Errorf("Unexpected inline attachment: %v", atts)
+	}
+
+	if _, err := db.DeleteAttachment(ctx, "cow", rev, "moo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "cow", "moo.txt")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+}
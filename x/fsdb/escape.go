@@ -0,0 +1,34 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// escape converts a document ID, database name or attachment filename into a
+// safe filename. A leading dot is escaped, so that the result is never a
+// hidden file, or one of the special names "." and "..".
+func escape(name string) string {
+	escaped := url.PathEscape(name)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+	return escaped
+}
+
+// unescape reverses escape.
+func unescape(filename string) (string, error) {
+	return url.PathUnescape(filename)
+}
@@ -0,0 +1,165 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package fsdb provides a Kivik driver which stores data as plain files on the
+// local filesystem, suitable for local development, fixtures and test data
+// tracked in version control.
+//
+// The driver is registered under the name "file". The data source name is the
+// path to the root directory:
+//
+//	import (
+//	    kivik "github.com/go-kivik/kivik/v4"
+//	    _ "github.com/go-kivik/kivik/v4/x/fsdb"
+//	)
+//
+//	client, err := kivik.New("file", "/path/to/data")
+//
+// Each database is a directory below the root. Each document is stored as a
+// JSON file named after the document ID, with the extension .json, and its
+// attachments as files in a directory named after the document ID, with the
+// extension .attachments. Document IDs and database names are escaped as for
+// URL paths, so that "_design/foo" is stored as "_design%2Ffoo.json".
+//
+// Documents without a _rev field, such as hand-written fixtures, are given a
+// revision derived from the file's content. Only the current revision of each
+// document is kept, and deleted documents are removed from disk. The changes
+// feed reports the current revision of each document, ordered by file
+// modification time, and does not include deletions.
+//
+// Writes are atomic, but there is no locking between processes sharing the
+// same directory.
+package fsdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+func init() {
+	kivik.Register("file", &fsDriver{})
+}
+
+type fsDriver struct{}
+
+var _ driver.Driver = &fsDriver{}
+
+// NewClient returns a client rooted at the directory dir, which must exist.
+func (d *fsDriver) NewClient(dir string, _ map[string]interface{}) (driver.Client, error) {
+	if dir == "" {
+		dir = "."
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if !info.IsDir() {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: " + dir + " is not a directory"}
+	}
+	return &client{root: dir}, nil
+}
+
+type client struct {
+	root string
+	// mu serializes writes made by this client.
+	mu sync.Mutex
+}
+
+var _ driver.Client = &client{}
+
+// Version is the version reported by the filesystem driver.
+const Version = kivik.KivikVersion
+
+// Vendor is the vendor string reported by the filesystem driver.
+const Vendor = "Kivik File System Adaptor"
+
+func (c *client) Version(context.Context) (*driver.Version, error) {
+	return &driver.Version{
+		Version: Version,
+		Vendor:  Vendor,
+	}, nil
+}
+
+func (c *client) AllDBs(context.Context, map[string]interface{}) ([]string, error) {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return nil, err
+	}
+	dbs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name, err := unescape(entry.Name())
+		if err != nil || !driverutil.ValidDBName(name) {
+			continue
+		}
+		dbs = append(dbs, name)
+	}
+	sort.Strings(dbs)
+	return dbs, nil
+}
+
+func (c *client) dbPath(dbName string) string {
+	return filepath.Join(c.root, escape(dbName))
+}
+
+func (c *client) DBExists(_ context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	info, err := os.Stat(c.dbPath(dbName))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (c *client) CreateDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	if !driverutil.ValidDBName(dbName) {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "illegal database name"}
+	}
+	err := os.Mkdir(c.dbPath(dbName), 0o777)
+	if errors.Is(err, os.ErrExist) {
+		return &kivik.Error{Status: http.StatusPreconditionFailed, Message: "database exists"}
+	}
+	return err
+}
+
+func (c *client) DestroyDB(ctx context.Context, dbName string, _ map[string]interface{}) error {
+	exists, err := c.DBExists(ctx, dbName, nil)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errDatabaseNotFound
+	}
+	return os.RemoveAll(c.dbPath(dbName))
+}
+
+func (c *client) DB(dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{
+		client: c,
+		name:   dbName,
+		path:   c.dbPath(dbName),
+	}, nil
+}
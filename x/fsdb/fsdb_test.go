@@ -0,0 +1,91 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+// newDB returns a new, empty database in a temporary directory.
+func newDB(t *testing.T) (*kivik.DB, string) {
+	t.Helper()
+	dir := t.TempDir()
+	client, err := kivik.New("file", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "animals"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("animals"), filepath.Join(dir, "animals")
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	_, err := kivik.New("file", filepath.Join(t.TempDir(), "missing"))
+	if !strings.Contains(fmt.Sprint(err), "no such file or directory") || kivik.HTTPStatus(err) != http.StatusBadRequest {
+		t.Errorf("Unexpected error for missing directory: %v", err)
+	}
+
+	dir := t.TempDir()
+	client, err := kivik.New("file", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar/baz"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bar%2Fbaz")); err != nil {
+		t.Errorf("Expected escaped directory: %s", err)
+	}
+	err = client.CreateDB(ctx, "foo")
+	testutil.CheckError(t, "database exists", http.StatusPreconditionFailed, err)
+
+	dbs, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bar/baz", "foo"}, dbs); d != nil {
+		t.Error(d)
+	}
+	if err := client.DestroyDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.DestroyDB(ctx, "foo")
+	testutil.CheckError(t, "database does not exist", http.StatusNotFound, err)
+}
+
+func TestEscape(t *testing.T) {
+	for _, name := range []string{"foo", "_design/foo", ".hidden", "..", "a b?c"} {
+		escaped := escape(name)
+		if filepath.Base(escaped) != escaped || escaped[0] == '.' {
+			t.Errorf("%q escaped to unsafe filename %q", name, escaped)
+		}
+		if got, _ := unescape(escaped); got != name {
+			t.Errorf("%q did not round-trip, got %q", name, got)
+		}
+	}
+}
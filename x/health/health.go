@@ -0,0 +1,264 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package health checks the availability of a CouchDB server, for liveness
+// and readiness probes.
+//
+// A [Checker] periodically pings the server, and reads a designated database,
+// and keeps the result, so that probes are answered without making a request
+// of their own:
+//
+//	checker := health.New(client, health.Config{DB: "orders"})
+//	go checker.Run(ctx)
+//	checker.Register(mux) // serves /healthz and /readyz
+//
+// The two endpoints have the usual Kubernetes semantics:
+//
+//   - /healthz reports liveness. It fails only if the checker itself has
+//     stalled, so that an outage of the database does not cause the
+//     application to be restarted.
+//   - /readyz reports readiness. It fails until a check has succeeded, and
+//     whenever the last check failed, so that traffic is routed elsewhere
+//     while the database is unavailable.
+//
+// Both endpoints respond with the current [Status], encoded as JSON.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultInterval is the time between checks, when Config.Interval is unset.
+const DefaultInterval = 10 * time.Second
+
+// DefaultTimeout is the time allowed for each check, when Config.Timeout is
+// unset.
+const DefaultTimeout = 5 * time.Second
+
+// The paths registered by [Checker.Register].
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// errNotChecked is reported until the first check completes.
+var errNotChecked = errors.New("health: not yet checked")
+
+// errDown is reported when the server responds, but is not available.
+var errDown = errors.New("health: server is not available")
+
+// Config configures a [Checker].
+type Config struct {
+	// DB, if set, is the name of a database which must respond to a read,
+	// in addition to the server responding to a ping.
+	DB string
+	// Interval is the time between checks made by [Checker.Run].
+	Interval time.Duration
+	// Timeout is the time allowed for each check.
+	Timeout time.Duration
+	// StaleAfter is the time after the last completed check at which the
+	// checker is considered stalled, and liveness fails. It defaults to three
+	// times Interval plus Timeout.
+	StaleAfter time.Duration
+	// OnCheck, if set, is called with the status after each check.
+	OnCheck func(Status)
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+}
+
+// Status is the result of the last check.
+type Status struct {
+	// Ready is true if the last check succeeded.
+	Ready bool `json:"ready"`
+	// CheckedAt is the time at which the last check completed. It is zero
+	// before the first check.
+	CheckedAt time.Time `json:"checked_at"`
+	// Latency is the duration of the last check.
+	Latency time.Duration `json:"-"`
+	// Err is the error from the last check, if it failed.
+	Err error `json:"-"`
+	// ConsecutiveFailures is the number of checks which have failed since
+	// the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// MarshalJSON encodes the status, with Latency in milliseconds, and Err as
+// its message.
+func (s Status) MarshalJSON() ([]byte, error) {
+	type alias Status
+	var errMsg string
+	if s.Err != nil {
+		errMsg = s.Err.Error()
+	}
+	return json.Marshal(struct {
+		alias
+		Latency float64 `json:"latency_ms"`
+		Error   string  `json:"error,omitempty"`
+	}{
+		alias:   alias(s),
+		Latency: float64(s.Latency) / float64(time.Millisecond),
+		Error:   errMsg,
+	})
+}
+
+// Checker checks the health of a server.
+type Checker struct {
+	client *kivik.Client
+	config Config
+
+	mu      sync.Mutex
+	status  Status
+	started time.Time
+}
+
+// New returns a checker for client.
+func New(client *kivik.Client, config Config) *Checker {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = 3*config.Interval + config.Timeout
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Checker{
+		client:  client,
+		config:  config,
+		status:  Status{Err: errNotChecked},
+		started: config.Now(),
+	}
+}
+
+// Run checks the server immediately, and then every Config.Interval, until
+// ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		c.Check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks the server once, records the result, and returns it.
+func (c *Checker) Check(ctx context.Context) Status {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	start := c.config.Now()
+	err := c.check(ctx)
+	now := c.config.Now()
+
+	c.mu.Lock()
+	failures := 0
+	if err != nil {
+		failures = c.status.ConsecutiveFailures + 1
+	}
+	c.status = Status{
+		Ready:               err == nil,
+		CheckedAt:           now,
+		Latency:             now.Sub(start),
+		Err:                 err,
+		ConsecutiveFailures: failures,
+	}
+	status := c.status
+	c.mu.Unlock()
+
+	if c.config.OnCheck != nil {
+		c.config.OnCheck(status)
+	}
+	return status
+}
+
+func (c *Checker) check(ctx context.Context) error {
+	up, err := c.client.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if !up {
+		return errDown
+	}
+	if c.config.DB == "" {
+		return nil
+	}
+	_, err = c.client.DB(c.config.DB).Stats(ctx)
+	return err
+}
+
+// Healthy returns the result of the last check.
+func (c *Checker) Healthy() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Live returns false if no check has completed within Config.StaleAfter.
+func (c *Checker) Live() bool {
+	c.mu.Lock()
+	last := c.status.CheckedAt
+	if last.IsZero() {
+		last = c.started
+	}
+	c.mu.Unlock()
+	return c.config.Now().Sub(last) <= c.config.StaleAfter
+}
+
+// LivenessHandler returns a handler which responds with status 200 while the
+// checker is live, and 503 otherwise.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		c.respond(w, c.Live())
+	})
+}
+
+// ReadinessHandler returns a handler which responds with status 200 if the
+// last check succeeded, and 503 otherwise.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		c.respond(w, c.Healthy().Ready)
+	})
+}
+
+// Register registers the liveness and readiness handlers on mux, at
+// [LivenessPath] and [ReadinessPath].
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.Handle(LivenessPath, c.LivenessHandler())
+	mux.Handle(ReadinessPath, c.ReadinessHandler())
+}
+
+func (c *Checker) respond(w http.ResponseWriter, ok bool) {
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(c.Healthy())
+}
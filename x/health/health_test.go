@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newClient(t *testing.T) *kivik.Client {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "orders"); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func get(t *testing.T, mux *http.ServeMux, path string) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func TestCheck(t *testing.T) {
+	client := newClient(t)
+	t.Run("ready", func(t *testing.T) {
+		c := New(client, Config{DB: "orders"})
+		status := c.Check(context.Background())
+		if !status.Ready || status.Err != nil || status.CheckedAt.IsZero() {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+	t.Run("missing database", func(t *testing.T) {
+		var checks []Status
+		c := New(client, Config{DB: "missing", OnCheck: func(s Status) { checks = append(checks, s) }})
+		c.Check(context.Background())
+		status := c.Check(context.Background())
+		if status.Ready || kivik.HTTPStatus(status.Err) != http.StatusNotFound || status.ConsecutiveFailures != 2 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		if len(checks) != 2 {
+			t.Errorf("Expected 2 calls to OnCheck, got %d", len(checks))
+		}
+		if h := c.Healthy(); h.ConsecutiveFailures != 2 {
+			t.Errorf("Unexpected snapshot: %+v", h)
+		}
+	})
+	t.Run("recovery resets failures", func(t *testing.T) {
+		c := New(client, Config{DB: "later"})
+		c.Check(context.Background())
+		if err := client.CreateDB(context.Background(), "later"); err != nil {
+			t.Fatal(err)
+		}
+		status := c.Check(context.Background())
+		if !status.Ready || status.ConsecutiveFailures != 0 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+}
+
+func TestHandlers(t *testing.T) {
+	now := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	c := New(newClient(t), Config{
+		DB:       "orders",
+		Interval: time.Minute,
+		Timeout:  time.Second,
+		Now:      func() time.Time { return now },
+	})
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	code, body := get(t, mux, ReadinessPath)
+	if code != http.StatusServiceUnavailable || body["error"] != "health: not yet checked" {
+		t.Errorf("Unexpected readiness before first check: %d %v", code, body)
+	}
+	if code, _ := get(t, mux, LivenessPath); code != http.StatusOK {
+		t.Errorf("Unexpected liveness before first check: %d", code)
+	}
+
+	c.Check(context.Background())
+	code, body = get(t, mux, ReadinessPath)
+	if code != http.StatusOK || body["ready"] != true {
+		t.Errorf("Unexpected readiness: %d %v", code, body)
+	}
+
+	now = now.Add(3*time.Minute + time.Second)
+	if code, _ := get(t, mux, LivenessPath); code != http.StatusOK {
+		t.Errorf("Unexpected liveness: %d", code)
+	}
+	now = now.Add(time.Second)
+	if code, _ := get(t, mux, LivenessPath); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected liveness after stalling: %d", code)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var checks int
+	c := New(newClient(t), Config{
+		Interval: time.Millisecond,
+		OnCheck: func(Status) {
+			if checks++; checks == 3 {
+				cancel()
+			}
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if checks != 3 {
+		t.Errorf("Unexpected number of checks: %d", checks)
+	}
+}
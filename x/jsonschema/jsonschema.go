@@ -0,0 +1,349 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package jsonschema validates documents against a JSON Schema on the client,
+// before they are written, for databases whose server-side validation
+// functions are not available or not practical to maintain.
+//
+//	schema, err := jsonschema.Compile(`{
+//	    "type": "object",
+//	    "required": ["name"],
+//	    "properties": {
+//	        "name": {"type": "string", "minLength": 1},
+//	        "age":  {"type": "integer", "minimum": 0}
+//	    }
+//	}`)
+//	if err != nil {
+//	    return err
+//	}
+//	db.AddValidator(jsonschema.Validator(schema))
+//
+// Use [ByField] to select a schema according to a type field in each
+// document.
+//
+// The supported keywords are type, enum, const, properties, required,
+// additionalProperties, minProperties, maxProperties, items, minItems,
+// maxItems, uniqueItems, minimum, maximum, exclusiveMinimum (as a number),
+// exclusiveMaximum (as a number), multipleOf, minLength, maxLength, pattern,
+// allOf, anyOf, oneOf and not. Boolean schemas are also supported. Annotations
+// such as title, description and format are ignored. Any other keyword,
+// including $ref, is rejected by [Compile], rather than silently ignored.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	// never is set for the boolean schema false.
+	never bool
+
+	types    []string
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	properties    map[string]*Schema
+	required      []string
+	additional    *Schema
+	minProperties *int
+	maxProperties *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minimum          *big.Rat
+	maximum          *big.Rat
+	exclusiveMinimum *big.Rat
+	exclusiveMaximum *big.Rat
+	multipleOf       *big.Rat
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+// annotations are keywords which do not affect validation.
+var annotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"format":      true,
+	"readOnly":    true,
+	"writeOnly":   true,
+	"deprecated":  true,
+}
+
+var validTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"number":  true,
+	"integer": true,
+	"string":  true,
+}
+
+// Compile compiles schema, which may be a string, []byte or
+// [encoding/json.RawMessage] containing JSON, or any value which marshals to
+// a JSON object or boolean.
+func Compile(schema interface{}) (*Schema, error) {
+	v, err := decode(schema)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "jsonschema: invalid schema", Err: err}
+	}
+	return compile(v, "#")
+}
+
+// MustCompile is like [Compile], but panics on error.
+func MustCompile(schema interface{}) *Schema {
+	s, err := Compile(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// decode converts v to its generic JSON representation, with numbers
+// decoded as [encoding/json.Number].
+func decode(v interface{}) (interface{}, error) {
+	var raw []byte
+	switch t := v.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var result interface{}
+	if err := dec.Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("jsonschema: %s: "+format, append([]interface{}{path}, args...)...)}
+}
+
+func compile(v interface{}, path string) (*Schema, error) {
+	switch t := v.(type) {
+	case bool:
+		return &Schema{never: !t}, nil
+	case map[string]interface{}:
+		s := &Schema{}
+		for _, key := range sortedKeys(t) {
+			if err := s.compileKeyword(key, t[key], path+"/"+key); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	return nil, schemaError(path, "schema must be an object or boolean")
+}
+
+// compileKeyword sets the field of s corresponding to key.
+func (s *Schema) compileKeyword(key string, v interface{}, path string) error { // nolint:gocyclo
+	var err error
+	switch key {
+	case "type":
+		s.types, err = compileTypes(v, path)
+	case "enum":
+		list, ok := v.([]interface{})
+		if !ok {
+			return schemaError(path, "must be an array")
+		}
+		s.enum = list
+	case "const":
+		s.constant, s.hasConst = v, true
+	case "properties":
+		props, ok := v.(map[string]interface{})
+		if !ok {
+			return schemaError(path, "must be an object")
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = compile(prop, path+"/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	case "required":
+		s.required, err = stringList(v, path)
+	case "additionalProperties":
+		s.additional, err = compile(v, path)
+	case "minProperties":
+		s.minProperties, err = nonNegative(v, path)
+	case "maxProperties":
+		s.maxProperties, err = nonNegative(v, path)
+	case "items":
+		s.items, err = compile(v, path)
+	case "minItems":
+		s.minItems, err = nonNegative(v, path)
+	case "maxItems":
+		s.maxItems, err = nonNegative(v, path)
+	case "uniqueItems":
+		unique, ok := v.(bool)
+		if !ok {
+			return schemaError(path, "must be a boolean")
+		}
+		s.uniqueItems = unique
+	case "minimum":
+		s.minimum, err = number(v, path)
+	case "maximum":
+		s.maximum, err = number(v, path)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(v, path)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(v, path)
+	case "multipleOf":
+		if s.multipleOf, err = number(v, path); err == nil && s.multipleOf.Sign() <= 0 {
+			return schemaError(path, "must be greater than 0")
+		}
+	case "minLength":
+		s.minLength, err = nonNegative(v, path)
+	case "maxLength":
+		s.maxLength, err = nonNegative(v, path)
+	case "pattern":
+		pattern, ok := v.(string)
+		if !ok {
+			return schemaError(path, "must be a string")
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return schemaError(path, "%s", err)
+		}
+	case "allOf":
+		s.allOf, err = schemaList(v, path)
+	case "anyOf":
+		s.anyOf, err = schemaList(v, path)
+	case "oneOf":
+		s.oneOf, err = schemaList(v, path)
+	case "not":
+		s.not, err = compile(v, path)
+	default:
+		if !annotations[key] {
+			return schemaError(path, "unsupported keyword")
+		}
+	}
+	return err
+}
+
+func compileTypes(v interface{}, path string) ([]string, error) {
+	types, ok := []string(nil), false
+	if name, isString := v.(string); isString {
+		types, ok = []string{name}, true
+	} else {
+		var err error
+		if types, err = stringList(v, path); err == nil {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, schemaError(path, "must be a string or array of strings")
+	}
+	for _, name := range types {
+		if !validTypes[name] {
+			return nil, schemaError(path, "unknown type %q", name)
+		}
+	}
+	return types, nil
+}
+
+func stringList(v interface{}, path string) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, schemaError(path, "must be an array of strings")
+	}
+	result := make([]string, len(list))
+	for i, item := range list {
+		if result[i], ok = item.(string); !ok {
+			return nil, schemaError(path, "must be an array of strings")
+		}
+	}
+	return result, nil
+}
+
+func schemaList(v interface{}, path string) ([]*Schema, error) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, schemaError(path, "must be a non-empty array")
+	}
+	result := make([]*Schema, len(list))
+	for i, item := range list {
+		var err error
+		if result[i], err = compile(item, path+"/"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func number(v interface{}, path string) (*big.Rat, error) {
+	if n, ok := v.(json.Number); ok {
+		if r, ok := new(big.Rat).SetString(n.String()); ok {
+			return r, nil
+		}
+	}
+	return nil, schemaError(path, "must be a number")
+}
+
+func nonNegative(v interface{}, path string) (*int, error) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := strconv.Atoi(n.String()); err == nil && i >= 0 {
+			return &i, nil
+		}
+	}
+	return nil, schemaError(path, "must be a non-negative integer")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escape escapes name for use as a JSON Pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
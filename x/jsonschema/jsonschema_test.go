@@ -0,0 +1,231 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package jsonschema
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema interface{}
+		status int
+		err    string
+	}{
+		{
+			name:   "invalid JSON",
+			schema: `{`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: invalid schema: unexpected EOF",
+		},
+		{
+			name:   "not an object",
+			schema: `"string"`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #: schema must be an object or boolean",
+		},
+		{
+			name:   "unsupported keyword",
+			schema: `{"properties":{"a":{"$ref":"#/definitions/a"}}}`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #/properties/a/$ref: unsupported keyword",
+		},
+		{
+			name:   "unknown type",
+			schema: `{"type":["string","float"]}`,
+			status: http.StatusBadRequest,
+			err:    `jsonschema: #/type: unknown type "float"`,
+		},
+		{
+			name:   "invalid pattern",
+			schema: `{"pattern":"("}`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #/pattern: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:   "zero multipleOf",
+			schema: `{"multipleOf":0}`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #/multipleOf: must be greater than 0",
+		},
+		{
+			name:   "negative minLength",
+			schema: `{"minLength":-1}`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #/minLength: must be a non-negative integer",
+		},
+		{
+			name:   "empty anyOf",
+			schema: `{"anyOf":[]}`,
+			status: http.StatusBadRequest,
+			err:    "jsonschema: #/anyOf: must be a non-empty array",
+		},
+		{
+			name:   "annotations",
+			schema: map[string]interface{}{"title": "Cow", "format": "date", "type": "object"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Compile(test.schema)
+			testy.StatusError(t, test.err, test.status, err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := MustCompile(`{
+		"type": "object",
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 50},
+			"weight": {"type": "number", "multipleOf": 0.5},
+			"kind": {"enum": ["cow", "bull"]},
+			"farm": {"const": 1},
+			"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+			"address": {
+				"type": "object",
+				"properties": {"a/b": {"type": "string"}},
+				"minProperties": 1
+			},
+			"id": {"oneOf": [{"type": "string"}, {"type": "integer"}]},
+			"color": {"anyOf": [{"const": "black"}, {"const": "white"}]},
+			"horns": {"not": {"type": "null"}}
+		}
+	}`)
+	tests := []struct {
+		name string
+		doc  interface{}
+		want []FieldError
+	}{
+		{
+			name: "valid",
+			doc: map[string]interface{}{
+				"name":   "Bessie",
+				"age":    3.0,
+				"weight": 512.5,
+				"kind":   "cow",
+				"farm":   1.0,
+				"tags":   []string{"brown", "spotted"},
+				"id":     7,
+				"color":  "black",
+				"horns":  true,
+			},
+		},
+		{
+			name: "missing required fields",
+			doc:  `{"weight": 1}`,
+			want: []FieldError{
+				{Path: "/name", Message: "is required"},
+				{Path: "/age", Message: "is required"},
+			},
+		},
+		{
+			name: "type mismatch",
+			doc:  `[]`,
+			want: []FieldError{{Message: "expected object, got array"}},
+		},
+		{
+			name: "nested failures",
+			doc: `{
+				"name": "bessie",
+				"age": 50,
+				"weight": 1.2,
+				"kind": "calf",
+				"farm": 2,
+				"tags": ["a", 1, "a", "b"],
+				"address": {"a/b": 1},
+				"id": 1.5,
+				"color": "brown",
+				"horns": null,
+				"extra": true
+			}`,
+			want: []FieldError{
+				{Path: "/address/a~1b", Message: "expected string, got integer"},
+				{Path: "/age", Message: "must be < 50"},
+				{Path: "/color", Message: "must match at least one schema in anyOf"},
+				{Path: "/extra", Message: "is not allowed"},
+				{Path: "/farm", Message: "must equal the constant value"},
+				{Path: "/horns", Message: "must not match the schema in not"},
+				{Path: "/id", Message: "must match exactly one schema in oneOf, matched 0"},
+				{Path: "/kind", Message: "must be one of the enumerated values"},
+				{Path: "/name", Message: `must match pattern "^[A-Z]"`},
+				{Path: "/tags", Message: "must have at most 3 items"},
+				{Path: "/tags", Message: "items 0 and 2 are equal"},
+				{Path: "/tags/1", Message: "expected string, got integer"},
+				{Path: "/weight", Message: "must be a multiple of 1/2"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := schema.Validate(test.doc)
+			var got []FieldError
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				got = verr.Errors
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if d := testy.DiffInterface(test.want, got); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	db.AddValidator(ByField("type", map[string]*Schema{
+		"cow": MustCompile(`{"required":["name"],"additionalProperties":false,"properties":{"name":{"type":"string"},"type":{}}}`),
+	}))
+
+	rev, err := db.Put(ctx, "bessie", map[string]string{"type": "cow", "name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(ctx, "bessie", rev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "_design/cows", map[string]string{"_id": "_design/cows", "type": "cow", "language": "javascript"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "rex", map[string]string{"type": "dog"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Put(ctx, "daisy", map[string]string{"type": "cow", "_id": "daisy"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testy.StatusError(t, "kivik: document failed validation: document does not match schema: /name: is required", http.StatusBadRequest, err)
+}
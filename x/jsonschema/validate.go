@@ -0,0 +1,358 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// FieldError describes a single way in which a document fails to match a
+// schema.
+type FieldError struct {
+	// Path is the JSON Pointer to the offending value, such as
+	// "/address/zip", or "" for the document itself.
+	Path string
+	// Message describes the failure.
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Path == "" {
+		return "(root): " + e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError is returned when a document does not match a schema. It
+// lists every failure found, in document order.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "document does not match schema: " + strings.Join(msgs, "; ")
+}
+
+// Validate validates doc, which may be any value accepted by [kivik.DB.Put],
+// against s. If doc does not match, the returned error is a
+// [*ValidationError].
+func (s *Schema) Validate(doc interface{}) error {
+	v, err := decode(doc)
+	if err != nil {
+		return err
+	}
+	return s.validateValue(v)
+}
+
+func (s *Schema) validateValue(v interface{}) error {
+	var errs []FieldError
+	s.validate(v, "", &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Validator returns a [kivik.ValidateFunc], for use with
+// [kivik.DB.AddValidator], which validates documents against s. Top-level
+// fields beginning with an underscore, such as _id and _rev, are removed
+// before validation. Deletions, and design and local documents identified by
+// their _id field, are not validated.
+func Validator(s *Schema) kivik.ValidateFunc {
+	return ByField("", map[string]*Schema{"": s})
+}
+
+// ByField returns a [kivik.ValidateFunc] which selects the schema for each
+// document by the string value of its top-level field, such as "type".
+// Documents whose field is missing, or has no entry in schemas, are not
+// validated. Special fields, design documents, local documents and deletions
+// are handled as for [Validator].
+func ByField(field string, schemas map[string]*Schema) kivik.ValidateFunc {
+	return func(doc interface{}) error {
+		v, err := decode(doc)
+		if err != nil {
+			return err
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return &ValidationError{Errors: []FieldError{{Message: "document must be an object"}}}
+		}
+		if skip(obj) {
+			return nil
+		}
+		var key string
+		if field != "" {
+			if key, ok = obj[field].(string); !ok {
+				return nil
+			}
+		}
+		s, ok := schemas[key]
+		if !ok {
+			return nil
+		}
+		body := make(map[string]interface{}, len(obj))
+		for k, v := range obj {
+			if !strings.HasPrefix(k, "_") {
+				body[k] = v
+			}
+		}
+		return s.validateValue(body)
+	}
+}
+
+// skip reports whether doc is exempt from validation.
+func skip(doc map[string]interface{}) bool {
+	if deleted, _ := doc["_deleted"].(bool); deleted {
+		return true
+	}
+	id, _ := doc["_id"].(string)
+	return strings.HasPrefix(id, "_design/") || strings.HasPrefix(id, "_local/")
+}
+
+func (s *Schema) validate(v interface{}, path string, errs *[]FieldError) { // nolint:gocyclo
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		fail("no value is allowed")
+		return
+	}
+	if len(s.types) > 0 && !s.matchesType(v) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+		return
+	}
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of the enumerated values")
+		}
+	}
+	if s.hasConst && !equal(v, s.constant) {
+		fail("must equal the constant value")
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(t, path, errs)
+	case []interface{}:
+		s.validateArray(t, path, errs)
+	case json.Number:
+		n, _ := new(big.Rat).SetString(t.String())
+		if s.minimum != nil && n.Cmp(s.minimum) < 0 {
+			fail("must be >= %s", s.minimum.RatString())
+		}
+		if s.maximum != nil && n.Cmp(s.maximum) > 0 {
+			fail("must be <= %s", s.maximum.RatString())
+		}
+		if s.exclusiveMinimum != nil && n.Cmp(s.exclusiveMinimum) <= 0 {
+			fail("must be > %s", s.exclusiveMinimum.RatString())
+		}
+		if s.exclusiveMaximum != nil && n.Cmp(s.exclusiveMaximum) >= 0 {
+			fail("must be < %s", s.exclusiveMaximum.RatString())
+		}
+		if s.multipleOf != nil && !new(big.Rat).Quo(n, s.multipleOf).IsInt() {
+			fail("must be a multiple of %s", s.multipleOf.RatString())
+		}
+	case string:
+		length := utf8.RuneCountInString(t)
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			fail("must match pattern %q", s.pattern.String())
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, errs)
+	}
+	if s.anyOf != nil {
+		matched := 0
+		for _, sub := range s.anyOf {
+			if sub.matches(v) {
+				matched++
+				break
+			}
+		}
+		if matched == 0 {
+			fail("must match at least one schema in anyOf")
+		}
+	}
+	if s.oneOf != nil {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if sub.matches(v) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", matched)
+		}
+	}
+	if s.not != nil && s.not.matches(v) {
+		fail("must not match the schema in not")
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, path string, errs *[]FieldError) {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, FieldError{Path: path + "/" + escape(name), Message: "is required"})
+		}
+	}
+	if s.minProperties != nil && len(obj) < *s.minProperties {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("must have at least %d properties", *s.minProperties)})
+	}
+	if s.maxProperties != nil && len(obj) > *s.maxProperties {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("must have at most %d properties", *s.maxProperties)})
+	}
+	for _, name := range sortedKeys(obj) {
+		propPath := path + "/" + escape(name)
+		if prop, ok := s.properties[name]; ok {
+			prop.validate(obj[name], propPath, errs)
+			continue
+		}
+		if s.additional == nil {
+			continue
+		}
+		if s.additional.never {
+			*errs = append(*errs, FieldError{Path: propPath, Message: "is not allowed"})
+			continue
+		}
+		s.additional.validate(obj[name], propPath, errs)
+	}
+}
+
+func (s *Schema) validateArray(list []interface{}, path string, errs *[]FieldError) {
+	if s.minItems != nil && len(list) < *s.minItems {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.minItems)})
+	}
+	if s.maxItems != nil && len(list) > *s.maxItems {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.maxItems)})
+	}
+	if s.uniqueItems {
+	outer:
+		for i := 1; i < len(list); i++ {
+			for j := 0; j < i; j++ {
+				if equal(list[i], list[j]) {
+					*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("items %d and %d are equal", j, i)})
+					break outer
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i, item := range list {
+			s.items.validate(item, path+"/"+strconv.Itoa(i), errs)
+		}
+	}
+}
+
+// matches reports whether v matches s.
+func (s *Schema) matches(v interface{}) bool {
+	var errs []FieldError
+	s.validate(v, "", &errs)
+	return len(errs) == 0
+}
+
+func (s *Schema) matchesType(v interface{}) bool {
+	actual := typeOf(v)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of v. Numbers with no fractional part
+// are reported as integers.
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if n, ok := new(big.Rat).SetString(t.String()); ok && n.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal reports whether a and b are equal JSON values. Numbers are compared
+// by value, so that 1 and 1.0 are equal.
+func equal(a, b interface{}) bool {
+	switch at := a.(type) {
+	case json.Number:
+		bt, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		an, _ := new(big.Rat).SetString(at.String())
+		bn, _ := new(big.Rat).SetString(bt.String())
+		return an != nil && bn != nil && an.Cmp(bn) == 0
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !equal(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, v := range at {
+			bv, ok := bt[k]
+			if !ok || !equal(v, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,298 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+type driverClient struct {
+	mock *Mock
+}
+
+var (
+	_ driver.Client       = &driverClient{}
+	_ driver.ClientCloser = &driverClient{}
+)
+
+func (c *driverClient) Version(ctx context.Context) (*driver.Version, error) {
+	e, err := c.mock.call(ctx, &call{method: "Version"})
+	if err != nil {
+		return nil, err
+	}
+	version, _ := e.ret.(string)
+	return &driver.Version{Version: version, Vendor: "Kivik Mock"}, nil
+}
+
+func (c *driverClient) AllDBs(ctx context.Context, options map[string]interface{}) ([]string, error) {
+	e, err := c.mock.call(ctx, &call{method: "AllDBs", options: options})
+	if err != nil {
+		return nil, err
+	}
+	dbs, _ := e.ret.([]string)
+	return dbs, nil
+}
+
+func (c *driverClient) DBExists(ctx context.Context, dbName string, options map[string]interface{}) (bool, error) {
+	e, err := c.mock.call(ctx, &call{method: "DBExists", db: dbName, options: options})
+	if err != nil {
+		return false, err
+	}
+	exists, _ := e.ret.(bool)
+	return exists, nil
+}
+
+func (c *driverClient) CreateDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	_, err := c.mock.call(ctx, &call{method: "CreateDB", db: dbName, options: options})
+	return err
+}
+
+func (c *driverClient) DestroyDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	_, err := c.mock.call(ctx, &call{method: "DestroyDB", db: dbName, options: options})
+	return err
+}
+
+func (c *driverClient) DB(dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{mock: c.mock, name: dbName}, nil
+}
+
+func (c *driverClient) Close() error {
+	_, err := c.mock.call(context.Background(), &call{method: "Close"})
+	return err
+}
+
+type db struct {
+	mock *Mock
+	name string
+}
+
+var (
+	_ driver.DB     = &db{}
+	_ driver.Finder = &db{}
+)
+
+func (d *db) call(ctx context.Context, c *call) (*Expectation, error) {
+	c.db = d.name
+	return d.mock.call(ctx, c)
+}
+
+func (d *db) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	e, err := d.call(ctx, &call{method: "Get", docID: docID, options: options})
+	if err != nil {
+		return nil, err
+	}
+	body := []byte("{}")
+	if e.ret != nil {
+		if body, err = json.Marshal(e.ret); err != nil {
+			return nil, err
+		}
+	}
+	var meta struct {
+		Rev string `json:"_rev"`
+	}
+	_ = json.Unmarshal(body, &meta)
+	return &driver.Document{
+		Rev:  meta.Rev,
+		Body: io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	e, err := d.call(ctx, &call{method: "Put", docID: docID, doc: doc, options: options})
+	if err != nil {
+		return "", err
+	}
+	rev, _ := e.ret.(string)
+	return rev, nil
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	e, err := d.call(ctx, &call{method: "CreateDoc", doc: doc, options: options})
+	if err != nil {
+		return "", "", err
+	}
+	rev, _ := e.ret.(string)
+	return e.docID, rev, nil
+}
+
+func (d *db) Delete(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	e, err := d.call(ctx, &call{method: "Delete", docID: docID, options: options})
+	if err != nil {
+		return "", err
+	}
+	rev, _ := e.ret.(string)
+	return rev, nil
+}
+
+func (d *db) AllDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	e, err := d.call(ctx, &call{method: "AllDocs", options: options})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(e.rows)
+}
+
+func (d *db) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	e, err := d.call(ctx, &call{method: "Query", ddoc: ddoc, view: view, options: options})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(e.rows)
+}
+
+func (d *db) Find(ctx context.Context, query interface{}, options map[string]interface{}) (driver.Rows, error) {
+	e, err := d.call(ctx, &call{method: "Find", doc: query, options: options})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(e.rows)
+}
+
+func (d *db) Compact(ctx context.Context) error {
+	_, err := d.call(ctx, &call{method: "Compact"})
+	return err
+}
+
+// The remaining methods may not be expected, and so always fail.
+
+func (d *db) unexpected(ctx context.Context, method string) error {
+	_, err := d.call(ctx, &call{method: method})
+	return err
+}
+
+func (d *db) Stats(ctx context.Context) (*driver.DBStats, error) {
+	return nil, d.unexpected(ctx, "Stats")
+}
+
+func (d *db) CompactView(ctx context.Context, _ string) error {
+	return d.unexpected(ctx, "CompactView")
+}
+
+func (d *db) ViewCleanup(ctx context.Context) error {
+	return d.unexpected(ctx, "ViewCleanup")
+}
+
+func (d *db) Security(ctx context.Context) (*driver.Security, error) {
+	return nil, d.unexpected(ctx, "Security")
+}
+
+func (d *db) SetSecurity(ctx context.Context, _ *driver.Security) error {
+	return d.unexpected(ctx, "SetSecurity")
+}
+
+func (d *db) Changes(ctx context.Context, _ map[string]interface{}) (driver.Changes, error) {
+	return nil, d.unexpected(ctx, "Changes")
+}
+
+func (d *db) PutAttachment(ctx context.Context, _ string, _ *driver.Attachment, _ map[string]interface{}) (string, error) {
+	return "", d.unexpected(ctx, "PutAttachment")
+}
+
+func (d *db) GetAttachment(ctx context.Context, _, _ string, _ map[string]interface{}) (*driver.Attachment, error) {
+	return nil, d.unexpected(ctx, "GetAttachment")
+}
+
+func (d *db) DeleteAttachment(ctx context.Context, _, _ string, _ map[string]interface{}) (string, error) {
+	return "", d.unexpected(ctx, "DeleteAttachment")
+}
+
+func (d *db) CreateIndex(ctx context.Context, _, _ string, _ interface{}, _ map[string]interface{}) error {
+	return d.unexpected(ctx, "CreateIndex")
+}
+
+func (d *db) GetIndexes(ctx context.Context, _ map[string]interface{}) ([]driver.Index, error) {
+	return nil, d.unexpected(ctx, "GetIndexes")
+}
+
+func (d *db) DeleteIndex(ctx context.Context, _, _ string, _ map[string]interface{}) error {
+	return d.unexpected(ctx, "DeleteIndex")
+}
+
+func (d *db) Explain(ctx context.Context, _ interface{}, _ map[string]interface{}) (*driver.QueryPlan, error) {
+	return nil, d.unexpected(ctx, "Explain")
+}
+
+// rows is a [driver.Rows] over the rows set by [Expectation.WillReturnRows].
+type rows struct {
+	rows      []*driver.Row
+	docs      [][]byte
+	totalRows int64
+}
+
+var _ driver.Rows = &rows{}
+
+func newRows(in []Row) (driver.Rows, error) {
+	r := &rows{
+		rows:      make([]*driver.Row, 0, len(in)),
+		docs:      make([][]byte, 0, len(in)),
+		totalRows: int64(len(in)),
+	}
+	for _, row := range in {
+		key, err := marshalOrNull(row.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalOrNull(row.Value)
+		if err != nil {
+			return nil, err
+		}
+		var doc []byte
+		if row.Doc != nil {
+			if doc, err = json.Marshal(row.Doc); err != nil {
+				return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+			}
+		}
+		r.rows = append(r.rows, &driver.Row{ID: row.ID, Key: key, Value: bytes.NewReader(value)})
+		r.docs = append(r.docs, doc)
+	}
+	return r, nil
+}
+
+func marshalOrNull(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return json.RawMessage("null"), nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return raw, nil
+}
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	if r.docs[0] != nil {
+		row.Doc = bytes.NewReader(r.docs[0])
+	}
+	r.rows, r.docs = r.rows[1:], r.docs[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows, r.docs = nil, nil
+	return nil
+}
+
+func (r *rows) UpdateSeq() string { return "" }
+func (r *rows) Offset() int64     { return 0 }
+func (r *rows) TotalRows() int64  { return r.totalRows }
@@ -0,0 +1,266 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Expectation is an expected call to the mock client. It is returned by the
+// Expect methods of [Mock], and configured by chaining the With and Will
+// methods:
+//
+//	mock.ExpectPut("animals").WithDocID("cow").WillReturn("2-xxx")
+//
+// The meaning of [Expectation.WillReturn] depends on the method expected, and
+// is described by each Expect method.
+type Expectation struct {
+	method string
+	db     string
+	docID  string
+	ddoc   string
+	view   string
+
+	doc     interface{}
+	hasDoc  bool
+	options map[string]interface{}
+
+	ret   interface{}
+	rows  []Row
+	err   error
+	delay time.Duration
+
+	triggered bool
+}
+
+// Row is a row returned by an iterator, such as the result of
+// [github.com/go-kivik/kivik/v4.DB.AllDocs]. Key, Value and Doc are
+// marshaled to JSON.
+type Row struct {
+	ID    string
+	Key   interface{}
+	Value interface{}
+	Doc   interface{}
+}
+
+// String returns a description of the expected call.
+func (e *Expectation) String() string {
+	return describe(e.method, e.db, e.docID, e.ddoc, e.view)
+}
+
+// WithDocID sets the document ID the call is expected to be made with. For
+// CreateDoc, which takes no document ID, it instead sets the ID returned.
+func (e *Expectation) WithDocID(docID string) *Expectation {
+	e.docID = docID
+	return e
+}
+
+// WithDoc sets the document, or for Find, the query, the call is expected to
+// be made with. Documents are compared by their JSON representation.
+func (e *Expectation) WithDoc(doc interface{}) *Expectation {
+	e.doc = doc
+	e.hasDoc = true
+	return e
+}
+
+// WithOptions sets options which the call is expected to include. The call
+// may include additional options.
+func (e *Expectation) WithOptions(options map[string]interface{}) *Expectation {
+	if e.options == nil {
+		e.options = make(map[string]interface{}, len(options))
+	}
+	for k, v := range options {
+		e.options[k] = v
+	}
+	return e
+}
+
+// WillReturn sets the value returned by the call.
+func (e *Expectation) WillReturn(value interface{}) *Expectation {
+	e.ret = value
+	return e
+}
+
+// WillReturnRows sets the rows returned by a call which returns an iterator.
+func (e *Expectation) WillReturnRows(rows ...Row) *Expectation {
+	e.rows = append(e.rows, rows...)
+	return e
+}
+
+// WillReturnError causes the call to return err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelay causes the call to block for delay, or until its context is
+// cancelled, before returning.
+func (e *Expectation) WillDelay(delay time.Duration) *Expectation {
+	e.delay = delay
+	return e
+}
+
+// match returns an error describing why c does not match e, or nil if it
+// does.
+func (e *Expectation) match(c *call) error {
+	if e.method != c.method {
+		return fmt.Errorf("expected %s", e.method)
+	}
+	if e.db != c.db {
+		return fmt.Errorf("expected database %q, got %q", e.db, c.db)
+	}
+	if e.method != "CreateDoc" && e.docID != "" && e.docID != c.docID {
+		return fmt.Errorf("expected document ID %q, got %q", e.docID, c.docID)
+	}
+	if e.ddoc != "" && (e.ddoc != c.ddoc || e.view != c.view) {
+		return fmt.Errorf("expected view %s/%s, got %s/%s", e.ddoc, e.view, c.ddoc, c.view)
+	}
+	if e.hasDoc && !jsonEqual(e.doc, c.doc) {
+		return fmt.Errorf("document does not match")
+	}
+	for k, v := range e.options {
+		got, ok := c.options[k]
+		if !ok {
+			return fmt.Errorf("missing option %q", k)
+		}
+		if !reflect.DeepEqual(v, got) && !jsonEqual(v, got) {
+			return fmt.Errorf("option %q: expected %v, got %v", k, v, got)
+		}
+	}
+	return nil
+}
+
+// jsonEqual reports whether a and b have equivalent JSON representations.
+func jsonEqual(a, b interface{}) bool {
+	var x, y interface{}
+	if err := unmarshalJSON(a, &x); err != nil {
+		return false
+	}
+	if err := unmarshalJSON(b, &y); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// unmarshalJSON unmarshals the JSON representation of v into dest. Strings,
+// byte slices and json.RawMessage values are treated as raw JSON.
+func unmarshalJSON(v, dest interface{}) error {
+	var raw []byte
+	switch t := v.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+func (m *Mock) newExpectation(method, db string) *Expectation {
+	return m.expect(&Expectation{method: method, db: db})
+}
+
+// ExpectVersion expects a call to Version. WillReturn sets the version
+// string returned.
+func (m *Mock) ExpectVersion() *Expectation {
+	return m.newExpectation("Version", "")
+}
+
+// ExpectAllDBs expects a call to AllDBs. WillReturn sets the []string of
+// database names returned.
+func (m *Mock) ExpectAllDBs() *Expectation {
+	return m.newExpectation("AllDBs", "")
+}
+
+// ExpectDBExists expects a call to DBExists for the database db. WillReturn
+// sets the bool returned.
+func (m *Mock) ExpectDBExists(db string) *Expectation {
+	return m.newExpectation("DBExists", db)
+}
+
+// ExpectCreateDB expects a call to CreateDB for the database db.
+func (m *Mock) ExpectCreateDB(db string) *Expectation {
+	return m.newExpectation("CreateDB", db)
+}
+
+// ExpectDestroyDB expects a call to DestroyDB for the database db.
+func (m *Mock) ExpectDestroyDB(db string) *Expectation {
+	return m.newExpectation("DestroyDB", db)
+}
+
+// ExpectGet expects a call to Get on the database db. WillReturn sets the
+// document returned, which is marshaled to JSON. Its revision is taken from
+// the _rev field.
+func (m *Mock) ExpectGet(db string) *Expectation {
+	return m.newExpectation("Get", db)
+}
+
+// ExpectPut expects a call to Put on the database db. WillReturn sets the
+// revision returned.
+func (m *Mock) ExpectPut(db string) *Expectation {
+	return m.newExpectation("Put", db)
+}
+
+// ExpectCreateDoc expects a call to CreateDoc on the database db. WithDocID
+// sets the document ID returned, and WillReturn the revision.
+func (m *Mock) ExpectCreateDoc(db string) *Expectation {
+	return m.newExpectation("CreateDoc", db)
+}
+
+// ExpectDelete expects a call to Delete on the database db. WillReturn sets
+// the revision returned.
+func (m *Mock) ExpectDelete(db string) *Expectation {
+	return m.newExpectation("Delete", db)
+}
+
+// ExpectAllDocs expects a call to AllDocs on the database db. WillReturnRows
+// sets the rows returned.
+func (m *Mock) ExpectAllDocs(db string) *Expectation {
+	return m.newExpectation("AllDocs", db)
+}
+
+// ExpectFind expects a call to Find on the database db. WithDoc sets the
+// expected query, and WillReturnRows the rows returned.
+func (m *Mock) ExpectFind(db string) *Expectation {
+	return m.newExpectation("Find", db)
+}
+
+// ExpectQuery expects a call to Query of the view ddoc/view on the database
+// db. The _design/ and _view/ prefixes are optional. WillReturnRows sets the
+// rows returned.
+func (m *Mock) ExpectQuery(db, ddoc, view string) *Expectation {
+	e := m.newExpectation("Query", db)
+	e.ddoc = strings.TrimPrefix(ddoc, "_design/")
+	e.view = strings.TrimPrefix(view, "_view/")
+	return e
+}
+
+// ExpectCompact expects a call to Compact on the database db.
+func (m *Mock) ExpectCompact(db string) *Expectation {
+	return m.newExpectation("Compact", db)
+}
+
+// ExpectClose expects the client to be closed.
+func (m *Mock) ExpectClose() *Expectation {
+	return m.newExpectation("Close", "")
+}
@@ -0,0 +1,165 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kivikmock provides a Kivik client backed by a mock driver, which
+// verifies the calls made against a list of expectations, in the spirit of
+// sqlmock.
+//
+//	client, mock, err := kivikmock.New()
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	mock.ExpectGet("animals").WithDocID("cow").WillReturn(map[string]interface{}{
+//	    "_id":  "cow",
+//	    "_rev": "1-xxx",
+//	    "says": "moo",
+//	})
+//
+//	// ... call the code under test with client ...
+//
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//	    t.Error(err)
+//	}
+//
+// Each call made through the client is matched against the expectations
+// which have not yet been met. By default, expectations must be met in the
+// order in which they were declared; see [Mock.MatchExpectationsInOrder]. A
+// call which matches no expectation returns an error describing the mismatch.
+package kivikmock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Mock holds the expectations for a mock client.
+type Mock struct {
+	mu        sync.Mutex
+	unordered bool
+	expected  []*Expectation
+}
+
+// New returns a new client, and the Mock which controls it.
+func New() (*kivik.Client, *Mock, error) {
+	m := &Mock{}
+	client, err := kivik.NewClientFromDriverClient(&driverClient{mock: m})
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, m, nil
+}
+
+// MatchExpectationsInOrder sets whether expectations must be met in the order
+// in which they were declared, which is the default. When false, each call is
+// matched against the first pending expectation which it satisfies.
+func (m *Mock) MatchExpectationsInOrder(inOrder bool) {
+	m.mu.Lock()
+	m.unordered = !inOrder
+	m.mu.Unlock()
+}
+
+// ExpectationsWereMet returns an error describing the expectations which have
+// not been met, if any.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []string
+	for _, e := range m.expected {
+		if !e.triggered {
+			pending = append(pending, e.String())
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return fmt.Errorf("kivikmock: there are unmet expectations: %s", strings.Join(pending, ", "))
+}
+
+func (m *Mock) expect(e *Expectation) *Expectation {
+	m.mu.Lock()
+	m.expected = append(m.expected, e)
+	m.mu.Unlock()
+	return e
+}
+
+// call matches c against the pending expectations, marking the matching
+// expectation as met, and returning it along with the error it is set to
+// return, if any.
+func (m *Mock) call(ctx context.Context, c *call) (*Expectation, error) {
+	m.mu.Lock()
+	var found *Expectation
+	for _, e := range m.expected {
+		if e.triggered {
+			continue
+		}
+		err := e.match(c)
+		if err == nil {
+			found = e
+			break
+		}
+		if !m.unordered {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("kivikmock: call to %s was not expected, next expectation is %s: %w", c, e, err)
+		}
+	}
+	if found == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("kivikmock: call to %s was not expected", c)
+	}
+	found.triggered = true
+	m.mu.Unlock()
+
+	if found.delay > 0 {
+		timer := time.NewTimer(found.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return found, found.err
+}
+
+// call describes a call made to the mock driver.
+type call struct {
+	method  string
+	db      string
+	docID   string
+	ddoc    string
+	view    string
+	doc     interface{}
+	options map[string]interface{}
+}
+
+func (c *call) String() string {
+	return describe(c.method, c.db, c.docID, c.ddoc, c.view)
+}
+
+func describe(method, db, docID, ddoc, view string) string {
+	var args []string
+	if db != "" {
+		args = append(args, "db="+db)
+	}
+	if docID != "" {
+		args = append(args, "docID="+docID)
+	}
+	if ddoc != "" {
+		args = append(args, "ddoc="+ddoc, "view="+view)
+	}
+	return method + "(" + strings.Join(args, ", ") + ")"
+}
@@ -0,0 +1,236 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func newMock(t *testing.T) (*kivik.Client, *Mock) {
+	t.Helper()
+	client, mock, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, mock
+}
+
+// checkError fails the test unless err contains want, or is nil when want is
+// empty.
+func checkError(t *testing.T, want string, err error) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("Unexpected error: %s", err)
+	case want != "" && err == nil:
+		t.Errorf("Expected error containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("Expected error containing %q, got %q", want, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client, mock := newMock(t)
+	mock.ExpectVersion().WillReturn("3.3.3")
+	mock.ExpectAllDBs().WillReturn([]string{"a", "b"})
+	mock.ExpectDBExists("a").WillReturn(true)
+	mock.ExpectCreateDB("c")
+	mock.ExpectDestroyDB("c").WillReturnError(&kivik.Error{Status: http.StatusNotFound, Message: "missing"})
+	mock.ExpectClose()
+
+	version, err := client.Version(ctx)
+	checkError(t, "", err)
+	if version.Version != "3.3.3" {
+		t.Errorf("Unexpected version: %s", version.Version)
+	}
+	dbs, err := client.AllDBs(ctx)
+	checkError(t, "", err)
+	if len(dbs) != 2 {
+		t.Errorf("Unexpected databases: %v", dbs)
+	}
+	exists, err := client.DBExists(ctx, "a")
+	checkError(t, "", err)
+	if !exists {
+		t.Error("Expected database to exist")
+	}
+	checkError(t, "", client.CreateDB(ctx, "c"))
+	err = client.DestroyDB(ctx, "c")
+	if kivik.HTTPStatus(err) != http.StatusNotFound {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	checkError(t, "", client.Close())
+	checkError(t, "", mock.ExpectationsWereMet())
+}
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	client, mock := newMock(t)
+	db := client.DB("animals")
+	mock.ExpectGet("animals").WithDocID("cow").WillReturn(map[string]interface{}{
+		"_id":  "cow",
+		"_rev": "1-xxx",
+		"says": "moo",
+	})
+	mock.ExpectPut("animals").WithDocID("cow").
+		WithDoc(map[string]interface{}{"_rev": "1-xxx", "says": "MOO"}).
+		WillReturn("2-xxx")
+	mock.ExpectCreateDoc("animals").WithDocID("random").WillReturn("1-yyy")
+	mock.ExpectDelete("animals").WithDocID("cow").WithOptions(kivik.Rev("2-xxx")).WillReturn("3-xxx")
+	mock.ExpectCompact("animals")
+
+	row := db.Get(ctx, "cow")
+	var doc map[string]interface{}
+	checkError(t, "", row.ScanDoc(&doc))
+	if doc["says"] != "moo" {
+		t.Errorf("Unexpected document: %v", doc)
+	}
+	rev, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": "1-xxx", "says": "MOO"})
+	checkError(t, "", err)
+	if rev != "2-xxx" {
+		t.Errorf("Unexpected rev: %s", rev)
+	}
+	docID, rev, err := db.CreateDoc(ctx, map[string]interface{}{"says": "baa"})
+	checkError(t, "", err)
+	if docID != "random" || rev != "1-yyy" {
+		t.Errorf("Unexpected ID or rev: %s, %s", docID, rev)
+	}
+	rev, err = db.Delete(ctx, "cow", "2-xxx")
+	checkError(t, "", err)
+	if rev != "3-xxx" {
+		t.Errorf("Unexpected rev: %s", rev)
+	}
+	checkError(t, "", db.Compact(ctx))
+	checkError(t, "", mock.ExpectationsWereMet())
+}
+
+func TestIterators(t *testing.T) {
+	ctx := context.Background()
+	client, mock := newMock(t)
+	db := client.DB("animals")
+	mock.ExpectAllDocs("animals").WithOptions(kivik.IncludeDocs()).WillReturnRows(
+		Row{ID: "cow", Key: "cow", Value: map[string]string{"rev": "1-xxx"}, Doc: map[string]string{"says": "moo"}},
+		Row{ID: "pig", Key: "pig", Value: map[string]string{"rev": "1-yyy"}, Doc: map[string]string{"says": "oink"}},
+	)
+	mock.ExpectQuery("animals", "_design/sounds", "_view/by_sound").WillReturnRows(
+		Row{ID: "cow", Key: "moo"},
+	)
+	mock.ExpectFind("animals").WithDoc(`{"selector":{"says":"moo"}}`).WillReturnRows(
+		Row{ID: "cow", Doc: map[string]string{"says": "moo"}},
+	)
+
+	rs := db.AllDocs(ctx, kivik.IncludeDocs())
+	var sounds []string
+	for rs.Next() {
+		var doc struct {
+			Says string `json:"says"`
+		}
+		checkError(t, "", rs.ScanDoc(&doc))
+		sounds = append(sounds, doc.Says)
+	}
+	checkError(t, "", rs.Err())
+	if strings.Join(sounds, ",") != "moo,oink" {
+		t.Errorf("Unexpected results: %v", sounds)
+	}
+
+	rs = db.Query(ctx, "sounds", "by_sound")
+	if !rs.Next() {
+		t.Fatalf("Query: %v", rs.Err())
+	}
+	var key string
+	checkError(t, "", rs.ScanKey(&key))
+	if key != "moo" {
+		t.Errorf("Unexpected key: %s", key)
+	}
+	_ = rs.Close()
+
+	rs = db.Find(ctx, map[string]interface{}{
+		"selector": map[string]interface{}{"says": "moo"},
+	})
+	if !rs.Next() {
+		t.Fatalf("Find: %v", rs.Err())
+	}
+	_ = rs.Close()
+	checkError(t, "", mock.ExpectationsWereMet())
+}
+
+func TestMatching(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("out of order", func(t *testing.T) {
+		client, mock := newMock(t)
+		mock.ExpectCreateDB("a")
+		mock.ExpectCreateDB("b")
+		err := client.CreateDB(ctx, "b")
+		checkError(t, `call to CreateDB(db=b) was not expected, next expectation is CreateDB(db=a): expected database "a", got "b"`, err)
+		checkError(t, "there are unmet expectations: CreateDB(db=a), CreateDB(db=b)", mock.ExpectationsWereMet())
+	})
+	t.Run("unordered", func(t *testing.T) {
+		client, mock := newMock(t)
+		mock.MatchExpectationsInOrder(false)
+		mock.ExpectCreateDB("a")
+		mock.ExpectCreateDB("b")
+		checkError(t, "", client.CreateDB(ctx, "b"))
+		checkError(t, "", client.CreateDB(ctx, "a"))
+		checkError(t, "", mock.ExpectationsWereMet())
+	})
+	t.Run("unexpected", func(t *testing.T) {
+		client, mock := newMock(t)
+		_, err := client.DB("animals").Stats(ctx)
+		checkError(t, "call to Stats(db=animals) was not expected", err)
+		checkError(t, "", mock.ExpectationsWereMet())
+	})
+	t.Run("called twice", func(t *testing.T) {
+		client, mock := newMock(t)
+		mock.ExpectCreateDB("a")
+		checkError(t, "", client.CreateDB(ctx, "a"))
+		checkError(t, "call to CreateDB(db=a) was not expected", client.CreateDB(ctx, "a"))
+		checkError(t, "", mock.ExpectationsWereMet())
+	})
+	t.Run("options", func(t *testing.T) {
+		client, mock := newMock(t)
+		mock.ExpectGet("animals").WithOptions(kivik.Rev("1-xxx"))
+		err := client.DB("animals").Get(ctx, "cow", kivik.Rev("2-xxx")).Err()
+		checkError(t, `option "rev": expected 1-xxx, got 2-xxx`, err)
+		err = client.DB("animals").Get(ctx, "cow").Err()
+		checkError(t, `missing option "rev"`, err)
+	})
+	t.Run("document", func(t *testing.T) {
+		client, mock := newMock(t)
+		mock.ExpectPut("animals").WithDoc(map[string]string{"says": "moo"})
+		_, err := client.DB("animals").Put(ctx, "cow", map[string]string{"says": "oink"})
+		checkError(t, "document does not match", err)
+	})
+	t.Run("close", func(t *testing.T) {
+		client, _ := newMock(t)
+		checkError(t, "call to Close() was not expected", client.Close())
+	})
+}
+
+func TestWillDelay(t *testing.T) {
+	client, mock := newMock(t)
+	mock.ExpectCreateDB("a").WillDelay(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.CreateDB(ctx, "a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
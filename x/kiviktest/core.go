@@ -0,0 +1,225 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func testServer(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+
+	if _, err := client.Version(ctx); err != nil {
+		t.Errorf("Version: %s", err)
+	}
+
+	const name = "kiviktest_server"
+	if err := client.CreateDB(ctx, name); err != nil {
+		t.Fatalf("CreateDB: %s", err)
+	}
+	exists, err := client.DBExists(ctx, name)
+	if err != nil {
+		t.Fatalf("DBExists: %s", err)
+	}
+	if !exists {
+		t.Errorf("DBExists: expected true after CreateDB")
+	}
+	all, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatalf("AllDBs: %s", err)
+	}
+	if !contains(all, name) {
+		t.Errorf("AllDBs: %q missing from %v", name, all)
+	}
+	checkStatus(t, "CreateDB of an existing database", client.CreateDB(ctx, name), http.StatusPreconditionFailed)
+
+	if err := client.DestroyDB(ctx, name); err != nil {
+		t.Fatalf("DestroyDB: %s", err)
+	}
+	exists, err = client.DBExists(ctx, name)
+	if err != nil {
+		t.Fatalf("DBExists: %s", err)
+	}
+	if exists {
+		t.Errorf("DBExists: expected false after DestroyDB")
+	}
+	checkStatus(t, "DestroyDB of a missing database", client.DestroyDB(ctx, name), http.StatusNotFound)
+	checkStatus(t, "CreateDB with an invalid name", client.CreateDB(ctx, "Invalid Name!"), http.StatusBadRequest)
+}
+
+func testDocuments(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+
+	t.Run("CreateDoc", func(t *testing.T) {
+		docID, rev, err := db.CreateDoc(ctx, map[string]interface{}{"name": "Bob"})
+		if err != nil {
+			t.Fatalf("CreateDoc: %s", err)
+		}
+		if docID == "" || !strings.HasPrefix(rev, "1-") {
+			t.Errorf("CreateDoc: unexpected ID %q or rev %q", docID, rev)
+		}
+		var doc map[string]interface{}
+		if err := db.Get(ctx, docID).ScanDoc(&doc); err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		checkField(t, "Get", doc, "_id", docID)
+		checkField(t, "Get", doc, "_rev", rev)
+		checkField(t, "Get", doc, "name", "Bob")
+	})
+
+	t.Run("Put", func(t *testing.T) {
+		rev := put(t, db, "put", map[string]interface{}{"count": 1})
+		if !strings.HasPrefix(rev, "1-") {
+			t.Errorf("Put: unexpected rev %q for a new document", rev)
+		}
+		_, err := db.Put(ctx, "put", map[string]interface{}{"count": 2})
+		checkStatus(t, "Put without a rev", err, http.StatusConflict)
+
+		rev2 := put(t, db, "put", map[string]interface{}{"_rev": rev, "count": 2})
+		if !strings.HasPrefix(rev2, "2-") {
+			t.Errorf("Put: unexpected rev %q for an update", rev2)
+		}
+		_, err = db.Put(ctx, "put", map[string]interface{}{"_rev": rev, "count": 3})
+		checkStatus(t, "Put with a stale rev", err, http.StatusConflict)
+
+		var doc map[string]interface{}
+		if err := db.Get(ctx, "put").ScanDoc(&doc); err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		checkField(t, "Get", doc, "_rev", rev2)
+		checkField(t, "Get", doc, "count", 2)
+
+		got, err := db.GetRev(ctx, "put")
+		if err != nil {
+			t.Fatalf("GetRev: %s", err)
+		}
+		if got != rev2 {
+			t.Errorf("GetRev: expected %q, got %q", rev2, got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		rev := put(t, db, "delete", map[string]interface{}{})
+		_, err := db.Delete(ctx, "delete", "1-00000000000000000000000000000000")
+		checkStatus(t, "Delete with a stale rev", err, http.StatusConflict)
+
+		newRev, err := db.Delete(ctx, "delete", rev)
+		if err != nil {
+			t.Fatalf("Delete: %s", err)
+		}
+		if !strings.HasPrefix(newRev, "2-") {
+			t.Errorf("Delete: unexpected rev %q", newRev)
+		}
+		checkStatus(t, "Get of a deleted document", db.Get(ctx, "delete").Err(), http.StatusNotFound)
+
+		// A deleted document may be recreated without a rev.
+		put(t, db, "delete", map[string]interface{}{})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		checkStatus(t, "Get of a missing document", db.Get(ctx, "missing").Err(), http.StatusNotFound)
+		_, err := db.GetRev(ctx, "missing")
+		checkStatus(t, "GetRev of a missing document", err, http.StatusNotFound)
+		_, err = db.Delete(ctx, "missing", "1-00000000000000000000000000000000")
+		checkStatus(t, "Delete of a missing document", err, http.StatusNotFound)
+
+		missing := client.DB("kiviktest_missing")
+		checkStatus(t, "Get from a missing database", missing.Get(ctx, "foo").Err(), http.StatusNotFound)
+		_, err = missing.Put(ctx, "foo", map[string]interface{}{})
+		checkStatus(t, "Put to a missing database", err, http.StatusNotFound)
+	})
+}
+
+func testIterators(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	for _, id := range []string{"c", "a", "b"} {
+		put(t, db, id, map[string]interface{}{"name": id})
+	}
+
+	t.Run("AllDocs", func(t *testing.T) {
+		rs := db.AllDocs(ctx)
+		checkIDs(t, "AllDocs", ids(t, rs), []string{"a", "b", "c"})
+		if rs.Next() {
+			t.Errorf("Next: expected false after the last row")
+		}
+		if err := rs.Close(); err != nil {
+			t.Errorf("Close: %s", err)
+		}
+		if err := rs.Close(); err != nil {
+			t.Errorf("Close: second call failed: %s", err)
+		}
+	})
+
+	t.Run("include_docs", func(t *testing.T) {
+		rs := db.AllDocs(ctx, kivik.IncludeDocs())
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			id, _ := rs.ID()
+			var doc map[string]interface{}
+			if err := rs.ScanDoc(&doc); err != nil {
+				t.Fatalf("ScanDoc: %s", err)
+			}
+			checkField(t, "ScanDoc", doc, "_id", id)
+			checkField(t, "ScanDoc", doc, "name", id)
+		}
+		if err := rs.Err(); err != nil {
+			t.Fatalf("iteration failed: %s", err)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		checkIDs(t, "AllDocs with limit", ids(t, db.AllDocs(ctx, kivik.Limit(2))), []string{"a", "b"})
+		checkIDs(t, "AllDocs with descending", ids(t, db.AllDocs(ctx, kivik.Descending())), []string{"c", "b", "a"})
+		checkIDs(t, "AllDocs with start and end keys", ids(t, db.AllDocs(ctx, kivik.StartKey("b"), kivik.EndKey("c"))), []string{"b", "c"})
+	})
+
+	t.Run("early close", func(t *testing.T) {
+		rs := db.AllDocs(ctx)
+		if !rs.Next() {
+			t.Fatalf("Next: %s", rs.Err())
+		}
+		if err := rs.Close(); err != nil {
+			t.Errorf("Close: %s", err)
+		}
+		if rs.Next() {
+			t.Errorf("Next: expected false after Close")
+		}
+		if err := rs.Err(); err != nil {
+			t.Errorf("Err: expected nil after Close, got %s", err)
+		}
+	})
+
+	t.Run("missing database", func(t *testing.T) {
+		rs := client.DB("kiviktest_missing").AllDocs(ctx)
+		if rs.Next() {
+			t.Errorf("Next: expected false")
+		}
+		checkStatus(t, "AllDocs of a missing database", rs.Err(), http.StatusNotFound)
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// AssertIndexUsed fails the test if the query plan for query, as reported by
+// [kivik.DB.Explain], falls back to a full scan of _all_docs, or, if index is
+// not empty, does not use the named index. index is the name of the index,
+// optionally preceded by its design document and a slash, as in
+// "_design/foo/by_type" or "foo/by_type". The query plan is returned, or nil
+// if it could not be obtained.
+//
+// It is meant to be called from the tests of applications, to guard against
+// queries which would scan the whole database in production:
+//
+//	kiviktest.AssertIndexUsed(t, db, map[string]interface{}{
+//	    "selector": map[string]interface{}{"type": "user"},
+//	}, "by_type")
+func AssertIndexUsed(t testing.TB, db *kivik.DB, query interface{}, index string, options ...kivik.Options) *kivik.QueryPlan {
+	t.Helper()
+	plan, err := db.Explain(context.Background(), query, options...)
+	if err != nil {
+		t.Errorf("Explain failed: %s", err)
+		return nil
+	}
+	name, _ := plan.Index["name"].(string)
+	ddoc, _ := plan.Index["ddoc"].(string)
+	if typ, _ := plan.Index["type"].(string); typ == "special" || name == "_all_docs" {
+		t.Errorf("Query falls back to a full scan of _all_docs; selector: %v", plan.Selector)
+		return plan
+	}
+	if index == "" {
+		return plan
+	}
+	wantName, wantDDoc := index, ""
+	if i := strings.LastIndex(index, "/"); i >= 0 {
+		wantDDoc, wantName = index[:i], index[i+1:]
+	}
+	if name != wantName || (wantDDoc != "" && strings.TrimPrefix(ddoc, "_design/") != strings.TrimPrefix(wantDDoc, "_design/")) {
+		t.Errorf("Query uses index %s/%s, want %s", ddoc, name, index)
+	}
+	return plan
+}
+
+// AssertNoFullScan fails the test if the query plan for query falls back to
+// a full scan of _all_docs. It is equivalent to [AssertIndexUsed] with an
+// empty index name.
+func AssertNoFullScan(t testing.TB, db *kivik.DB, query interface{}, options ...kivik.Options) *kivik.QueryPlan {
+	t.Helper()
+	return AssertIndexUsed(t, db, query, "", options...)
+}
@@ -0,0 +1,84 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// recorder is a testing.TB which records failures, instead of failing the
+// test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func explainDB(t *testing.T, index map[string]interface{}, err error) *kivik.DB {
+	t.Helper()
+	client, cerr := kivik.NewClientFromDriverClient(&mock.Client{
+		DBFunc: func(string, map[string]interface{}) (driver.DB, error) {
+			return &mock.Finder{
+				ExplainFunc: func(context.Context, interface{}, map[string]interface{}) (*driver.QueryPlan, error) {
+					if err != nil {
+						return nil, err
+					}
+					return &driver.QueryPlan{Index: index}, nil
+				},
+			}, nil
+		},
+	})
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+	return client.DB("db")
+}
+
+func TestAssertIndexUsed(t *testing.T) {
+	byType := map[string]interface{}{"ddoc": "_design/foo", "name": "by_type", "type": "json"}
+	allDocs := map[string]interface{}{"ddoc": nil, "name": "_all_docs", "type": "special"}
+	tests := []struct {
+		name  string
+		index map[string]interface{}
+		err   error
+		want  string
+		fails bool
+	}{
+		{name: "any index", index: byType},
+		{name: "named index", index: byType, want: "by_type"},
+		{name: "with ddoc", index: byType, want: "foo/by_type"},
+		{name: "with full ddoc", index: byType, want: "_design/foo/by_type"},
+		{name: "wrong index", index: byType, want: "by_name", fails: true},
+		{name: "wrong ddoc", index: byType, want: "bar/by_type", fails: true},
+		{name: "full scan", index: allDocs, fails: true},
+		{name: "explain error", err: &kivik.Error{Status: 500, Message: "oops"}, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertIndexUsed(r, explainDB(t, tt.index, tt.err), map[string]interface{}{"selector": map[string]interface{}{}}, tt.want)
+			if failed := len(r.errors) > 0; failed != tt.fails {
+				t.Errorf("Unexpected result; failures: %v", r.errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kiviktest provides a conformance suite for Kivik drivers.
+//
+// Driver authors run the suite from their own tests, against a client
+// connected to a fresh, empty server:
+//
+//	func TestConformance(t *testing.T) {
+//	    kiviktest.Run(t, kiviktest.Suite{
+//	        NewClient: func(t *testing.T) *kivik.Client {
+//	            client, err := kivik.New("mydriver", t.TempDir())
+//	            if err != nil {
+//	                t.Fatal(err)
+//	            }
+//	            return client
+//	        },
+//	        Capabilities: kiviktest.Capabilities{
+//	            Changes:     true,
+//	            Attachments: true,
+//	        },
+//	    })
+//	}
+//
+// The suite exercises the client through the public kivik API, and so checks
+// the behavior that the kivik package, and its users, expect of a driver:
+// document CRUD and revisions, iterators, the HTTP status of common errors,
+// and, depending on the [Capabilities] declared, optional areas such as the
+// changes feed, attachments, Mango queries and context cancellation. Tests
+// for areas which are not declared as supported are skipped.
+//
+// The package also provides assertions for the tests of applications which use
+// Kivik, such as [AssertIndexUsed], which fails a test if a Mango query would
+// scan the whole database.
+package kiviktest
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Capabilities declares the optional areas of functionality supported by a
+// driver. Tests for areas which are not supported are skipped.
+type Capabilities struct {
+	// Changes indicates support for normal changes feeds.
+	Changes bool
+	// Attachments indicates support for storing, retrieving and deleting
+	// attachments.
+	Attachments bool
+	// Security indicates that security objects are stored.
+	Security bool
+	// LocalDocs indicates support for local documents, which are not included
+	// in the results of AllDocs, and for listing them with LocalDocs.
+	LocalDocs bool
+	// DesignDocs indicates support for listing design documents with
+	// DesignDocs.
+	DesignDocs bool
+	// Find indicates support for Mango queries.
+	Find bool
+	// Views indicates support for JavaScript map functions, queried with
+	// Query.
+	Views bool
+	// Cancellation indicates that operations fail once their context has been
+	// cancelled.
+	Cancellation bool
+}
+
+// Suite configures a conformance test run.
+type Suite struct {
+	// NewClient must return a client connected to a server without any
+	// databases. It is called once for each test, and the client is closed
+	// when the test completes.
+	NewClient func(t *testing.T) *kivik.Client
+
+	// Capabilities declares the optional functionality supported by the
+	// driver.
+	Capabilities Capabilities
+}
+
+// Run runs the conformance suite s as subtests of t.
+func Run(t *testing.T, s Suite) {
+	t.Helper()
+	if s.NewClient == nil {
+		t.Fatal("kiviktest: Suite.NewClient must not be nil")
+	}
+	caps := s.Capabilities
+	tests := []struct {
+		name      string
+		supported bool
+		fn        func(*testing.T, *kivik.Client)
+	}{
+		{"Server", true, testServer},
+		{"Documents", true, testDocuments},
+		{"Iterators", true, testIterators},
+		{"Changes", caps.Changes, testChanges},
+		{"Attachments", caps.Attachments, testAttachments},
+		{"Security", caps.Security, testSecurity},
+		{"LocalDocs", caps.LocalDocs, testLocalDocs},
+		{"DesignDocs", caps.DesignDocs, testDesignDocs},
+		{"Find", caps.Find, testFind},
+		{"Views", caps.Views, testViews},
+		{"Cancellation", caps.Cancellation, testCancellation},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if !test.supported {
+				t.Skip("not supported by the driver")
+			}
+			client := s.NewClient(t)
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			test.fn(t, client)
+		})
+	}
+}
+
+var dbCounter int64
+
+// newDB creates a new, uniquely-named database, which is destroyed when t
+// completes.
+func newDB(t *testing.T, client *kivik.Client) *kivik.DB {
+	t.Helper()
+	name := fmt.Sprintf("kiviktest_%d", atomic.AddInt64(&dbCounter, 1))
+	if err := client.CreateDB(context.Background(), name); err != nil {
+		t.Fatalf("CreateDB(%q): %s", name, err)
+	}
+	t.Cleanup(func() {
+		_ = client.DestroyDB(context.Background(), name)
+	})
+	return client.DB(name)
+}
+
+// put stores doc under docID, failing the test on error, and returns the new
+// revision.
+func put(t *testing.T, db *kivik.DB, docID string, doc interface{}) string {
+	t.Helper()
+	rev, err := db.Put(context.Background(), docID, doc)
+	if err != nil {
+		t.Fatalf("Put(%q): %s", docID, err)
+	}
+	return rev
+}
+
+// checkStatus fails the test unless err has the HTTP status want.
+func checkStatus(t *testing.T, what string, err error, want int) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("%s: expected status %d, got no error", what, want)
+		return
+	}
+	if got := kivik.HTTPStatus(err); got != want {
+		t.Errorf("%s: expected status %d, got %d (%s)", what, want, got, err)
+	}
+}
+
+// ids reads the remaining rows from rs, and returns their IDs.
+func ids(t *testing.T, rs kivik.ResultSet) []string {
+	t.Helper()
+	result := []string{}
+	for rs.Next() {
+		id, err := rs.ID()
+		if err != nil {
+			t.Fatalf("ID: %s", err)
+		}
+		result = append(result, id)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatalf("iteration failed: %s", err)
+	}
+	return result
+}
+
+func checkIDs(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: expected %v, got %v", what, want, got)
+	}
+}
+
+func checkField(t *testing.T, what string, doc map[string]interface{}, key string, want interface{}) {
+	t.Helper()
+	if got := doc[key]; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: expected %s=%v, got %v", what, key, want, got)
+	}
+}
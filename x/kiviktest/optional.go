@@ -0,0 +1,237 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func testChanges(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	revs := map[string]string{
+		"a": put(t, db, "a", map[string]interface{}{"name": "a"}),
+		"b": put(t, db, "b", map[string]interface{}{"name": "b"}),
+	}
+
+	changes := db.Changes(ctx, kivik.IncludeDocs())
+	got := []string{}
+	for changes.Next() {
+		id := changes.ID()
+		got = append(got, id)
+		if rev, ok := revs[id]; ok && !contains(changes.Changes(), rev) {
+			t.Errorf("Changes: %q missing from the revs of %q: %v", rev, id, changes.Changes())
+		}
+		var doc map[string]interface{}
+		if err := changes.ScanDoc(&doc); err != nil {
+			t.Fatalf("ScanDoc: %s", err)
+		}
+		checkField(t, "ScanDoc", doc, "_id", id)
+	}
+	if err := changes.Err(); err != nil {
+		t.Fatalf("iteration failed: %s", err)
+	}
+	sort.Strings(got)
+	checkIDs(t, "Changes", got, []string{"a", "b"})
+	meta, err := changes.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata: %s", err)
+	}
+	if meta.LastSeq == "" {
+		t.Errorf("Metadata: empty last_seq")
+	}
+}
+
+func testAttachments(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+
+	rev, err := db.PutAttachment(ctx, "doc", &kivik.Attachment{
+		Filename:    "foo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("Hello, World!")),
+	})
+	if err != nil {
+		t.Fatalf("PutAttachment: %s", err)
+	}
+	att, err := db.GetAttachment(ctx, "doc", "foo.txt")
+	if err != nil {
+		t.Fatalf("GetAttachment: %s", err)
+	}
+	content, err := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if err != nil {
+		t.Fatalf("GetAttachment: %s", err)
+	}
+	if string(content) != "Hello, World!" {
+		t.Errorf("GetAttachment: unexpected content %q", content)
+	}
+	if !strings.HasPrefix(att.ContentType, "text/plain") {
+		t.Errorf("GetAttachment: unexpected content type %q", att.ContentType)
+	}
+
+	_, err = db.GetAttachment(ctx, "doc", "missing.txt")
+	checkStatus(t, "GetAttachment of a missing attachment", err, http.StatusNotFound)
+
+	// Updating the document with a stub keeps the attachment.
+	rev = put(t, db, "doc", map[string]interface{}{
+		"_rev": rev,
+		"_attachments": map[string]interface{}{
+			"foo.txt": map[string]interface{}{"stub": true},
+		},
+	})
+	if _, err := db.GetAttachment(ctx, "doc", "foo.txt"); err != nil {
+		t.Fatalf("GetAttachment after an update: %s", err)
+	}
+
+	if _, err := db.DeleteAttachment(ctx, "doc", rev, "foo.txt"); err != nil {
+		t.Fatalf("DeleteAttachment: %s", err)
+	}
+	_, err = db.GetAttachment(ctx, "doc", "foo.txt")
+	checkStatus(t, "GetAttachment of a deleted attachment", err, http.StatusNotFound)
+}
+
+func testSecurity(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	sec := &kivik.Security{
+		Admins:  kivik.Members{Names: []string{"bob"}},
+		Members: kivik.Members{Roles: []string{"users"}},
+	}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatalf("SetSecurity: %s", err)
+	}
+	got, err := db.Security(ctx)
+	if err != nil {
+		t.Fatalf("Security: %s", err)
+	}
+	checkIDs(t, "Security admin names", got.Admins.Names, sec.Admins.Names)
+	checkIDs(t, "Security member roles", got.Members.Roles, sec.Members.Roles)
+}
+
+func testLocalDocs(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	put(t, db, "doc", map[string]interface{}{})
+	put(t, db, "_local/foo", map[string]interface{}{"seq": 1})
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "_local/foo").ScanDoc(&doc); err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	checkField(t, "Get", doc, "seq", 1)
+	checkIDs(t, "AllDocs", ids(t, db.AllDocs(ctx)), []string{"doc"})
+	checkIDs(t, "LocalDocs", ids(t, db.LocalDocs(ctx)), []string{"_local/foo"})
+}
+
+func testDesignDocs(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	put(t, db, "doc", map[string]interface{}{})
+	put(t, db, "_design/foo", map[string]interface{}{"language": "javascript"})
+
+	checkIDs(t, "DesignDocs", ids(t, db.DesignDocs(ctx)), []string{"_design/foo"})
+}
+
+func testFind(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	put(t, db, "a", map[string]interface{}{"type": "fruit", "name": "apple"})
+	put(t, db, "b", map[string]interface{}{"type": "vegetable", "name": "bean"})
+	put(t, db, "c", map[string]interface{}{"type": "fruit", "name": "cherry"})
+
+	rs := db.Find(ctx, map[string]interface{}{
+		"selector": map[string]interface{}{"type": "fruit"},
+	})
+	got := []string{}
+	for rs.Next() {
+		var doc map[string]interface{}
+		if err := rs.ScanDoc(&doc); err != nil {
+			t.Fatalf("ScanDoc: %s", err)
+		}
+		got = append(got, doc["name"].(string))
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	sort.Strings(got)
+	checkIDs(t, "Find", got, []string{"apple", "cherry"})
+
+	rs = db.Find(ctx, map[string]interface{}{"selector": "invalid"})
+	if rs.Next() {
+		t.Errorf("Find with an invalid selector: expected no results")
+	}
+	checkStatus(t, "Find with an invalid selector", rs.Err(), http.StatusBadRequest)
+}
+
+func testViews(t *testing.T, client *kivik.Client) {
+	ctx := context.Background()
+	db := newDB(t, client)
+	put(t, db, "_design/test", map[string]interface{}{
+		"views": map[string]interface{}{
+			"names": map[string]interface{}{
+				"map": "function(doc) { if (doc.name) { emit(doc.name, null); } }",
+			},
+		},
+	})
+	put(t, db, "a", map[string]interface{}{"name": "zed"})
+	put(t, db, "b", map[string]interface{}{"name": "amy"})
+
+	rs := db.Query(ctx, "_design/test", "_view/names")
+	keys := []string{}
+	for rs.Next() {
+		var key string
+		if err := rs.ScanKey(&key); err != nil {
+			t.Fatalf("ScanKey: %s", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatalf("Query: %s", err)
+	}
+	checkIDs(t, "Query", keys, []string{"amy", "zed"})
+
+	rs = db.Query(ctx, "_design/missing", "_view/names")
+	if rs.Next() {
+		t.Errorf("Query of a missing design document: expected no results")
+	}
+	checkStatus(t, "Query of a missing design document", rs.Err(), http.StatusNotFound)
+}
+
+func testCancellation(t *testing.T, client *kivik.Client) {
+	db := newDB(t, client)
+	put(t, db, "doc", map[string]interface{}{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.Get(ctx, "doc").Err(); err == nil {
+		t.Errorf("Get: expected an error with a cancelled context")
+	}
+	if _, err := db.Put(ctx, "new", map[string]interface{}{}); err == nil {
+		t.Errorf("Put: expected an error with a cancelled context")
+	}
+	rs := db.AllDocs(ctx)
+	for rs.Next() { // nolint:revive // intentional empty block
+	}
+	if rs.Err() == nil {
+		t.Errorf("AllDocs: expected an error with a cancelled context")
+	}
+}
@@ -0,0 +1,253 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Selector is a Mango selector, built with [Field] and the logical
+// combinators [And], [Or], [Nor] and [Not]:
+//
+//	sel := mango.Field("age").Gt(21).And(mango.Field("type").Eq("user"))
+//
+// A Selector marshals to the JSON selector object, so it may be used as the
+// selector of a query passed to [kivik.DB.Find], with [Selector.Query], or
+// with [kivik.SelectorFilter]. Mistakes, such as an unknown operator, are
+// reported by [Selector.Err], and when the selector is marshaled.
+//
+// The zero value is the empty selector, which matches every document.
+type Selector struct {
+	sel map[string]interface{}
+	err error
+}
+
+// FieldExpr is a field of a document, on which a condition is built. Field
+// names may use dots to refer to nested fields.
+type FieldExpr struct {
+	name string
+}
+
+// Field returns an expression for the named field, on which a condition is
+// built to make a [Selector].
+func Field(name string) FieldExpr {
+	return FieldExpr{name: name}
+}
+
+// fieldOperators are the operators accepted by [FieldExpr.Op].
+var fieldOperators = map[string]bool{
+	"$eq": true, "$ne": true, "$lt": true, "$lte": true, "$gt": true,
+	"$gte": true, "$exists": true, "$type": true, "$in": true, "$nin": true,
+	"$size": true, "$mod": true, "$regex": true, "$all": true,
+	"$elemMatch": true, "$allMatch": true,
+}
+
+// jsonTypes are the type names accepted by the $type operator.
+var jsonTypes = map[string]bool{
+	"null": true, "boolean": true, "number": true, "string": true,
+	"array": true, "object": true,
+}
+
+func invalidSelector(format string, args ...interface{}) Selector {
+	return Selector{err: badSelector(format, args...)}
+}
+
+// Op returns a selector which applies the condition operator op, such as
+// "$gt", to the field. An unknown operator results in an invalid selector.
+// The methods named after each operator should usually be preferred.
+func (f FieldExpr) Op(op string, arg interface{}) Selector {
+	if !fieldOperators[op] {
+		return invalidSelector("invalid operator %s", op)
+	}
+	if f.name == "" {
+		return invalidSelector("field name required")
+	}
+	var err error
+	if s, ok := arg.(Selector); ok {
+		arg, err = s.selector(), s.err
+	}
+	return Selector{
+		sel: map[string]interface{}{f.name: map[string]interface{}{op: arg}},
+		err: err,
+	}
+}
+
+// Eq matches documents whose field equals value.
+func (f FieldExpr) Eq(value interface{}) Selector { return f.Op("$eq", value) }
+
+// Ne matches documents whose field does not equal value.
+func (f FieldExpr) Ne(value interface{}) Selector { return f.Op("$ne", value) }
+
+// Lt matches documents whose field is less than value.
+func (f FieldExpr) Lt(value interface{}) Selector { return f.Op("$lt", value) }
+
+// Lte matches documents whose field is less than or equal to value.
+func (f FieldExpr) Lte(value interface{}) Selector { return f.Op("$lte", value) }
+
+// Gt matches documents whose field is greater than value.
+func (f FieldExpr) Gt(value interface{}) Selector { return f.Op("$gt", value) }
+
+// Gte matches documents whose field is greater than or equal to value.
+func (f FieldExpr) Gte(value interface{}) Selector { return f.Op("$gte", value) }
+
+// Exists matches documents which have the field, if exists is true, or which
+// don't, if it is false.
+func (f FieldExpr) Exists(exists bool) Selector { return f.Op("$exists", exists) }
+
+// Type matches documents whose field is of the JSON type typ: "null",
+// "boolean", "number", "string", "array" or "object".
+func (f FieldExpr) Type(typ string) Selector {
+	if !jsonTypes[typ] {
+		return invalidSelector("invalid $type: %s", typ)
+	}
+	return f.Op("$type", typ)
+}
+
+// In matches documents whose field equals one of values.
+func (f FieldExpr) In(values ...interface{}) Selector { return f.Op("$in", nonNil(values)) }
+
+// Nin matches documents whose field equals none of values.
+func (f FieldExpr) Nin(values ...interface{}) Selector { return f.Op("$nin", nonNil(values)) }
+
+// Size matches documents whose field is an array of length n.
+func (f FieldExpr) Size(n int) Selector { return f.Op("$size", n) }
+
+// Mod matches documents whose field is an integer, which divided by divisor
+// leaves remainder.
+func (f FieldExpr) Mod(divisor, remainder int) Selector {
+	if divisor == 0 {
+		return invalidSelector("$mod requires a non-zero divisor")
+	}
+	return f.Op("$mod", []int{divisor, remainder})
+}
+
+// Regex matches documents whose field is a string matching pattern. The
+// pattern is checked with the [regexp] package, whose syntax is close to,
+// but not the same as, the PCRE syntax used by CouchDB.
+func (f FieldExpr) Regex(pattern string) Selector {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return invalidSelector("invalid $regex: %s", err)
+	}
+	return f.Op("$regex", pattern)
+}
+
+// All matches documents whose field is an array containing all of values.
+func (f FieldExpr) All(values ...interface{}) Selector { return f.Op("$all", nonNil(values)) }
+
+// ElemMatch matches documents whose field is an array with at least one
+// element matching sel.
+func (f FieldExpr) ElemMatch(sel Selector) Selector { return f.Op("$elemMatch", sel) }
+
+// AllMatch matches documents whose field is a non-empty array of which every
+// element matches sel.
+func (f FieldExpr) AllMatch(sel Selector) Selector { return f.Op("$allMatch", sel) }
+
+// nonNil returns values, or an empty slice if it is nil, so that it marshals
+// to an empty JSON array.
+func nonNil(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+	return values
+}
+
+// combine returns a selector applying the combination operator op to sels.
+// Nested combinations with the same operator are flattened.
+func combine(op string, sels []Selector) Selector {
+	list := make([]interface{}, 0, len(sels))
+	for _, s := range sels {
+		if s.err != nil {
+			return Selector{err: s.err}
+		}
+		if sub, ok := s.sel[op].([]interface{}); ok && len(s.sel) == 1 {
+			list = append(list, sub...)
+			continue
+		}
+		list = append(list, s.selector())
+	}
+	return Selector{sel: map[string]interface{}{op: list}}
+}
+
+// And matches documents which match all of sels.
+func And(sels ...Selector) Selector { return combine("$and", sels) }
+
+// Or matches documents which match at least one of sels.
+func Or(sels ...Selector) Selector { return combine("$or", sels) }
+
+// Nor matches documents which match none of sels.
+func Nor(sels ...Selector) Selector { return combine("$nor", sels) }
+
+// Not matches documents which do not match sel.
+func Not(sel Selector) Selector {
+	if sel.err != nil {
+		return sel
+	}
+	return Selector{sel: map[string]interface{}{"$not": sel.selector()}}
+}
+
+// And matches documents which match s and all of others.
+func (s Selector) And(others ...Selector) Selector {
+	return And(append([]Selector{s}, others...)...)
+}
+
+// Or matches documents which match s or any of others.
+func (s Selector) Or(others ...Selector) Selector {
+	return Or(append([]Selector{s}, others...)...)
+}
+
+// selector returns the selector object, which is never nil.
+func (s Selector) selector() map[string]interface{} {
+	if s.sel == nil {
+		return map[string]interface{}{}
+	}
+	return s.sel
+}
+
+// Err returns the first mistake made while building the selector, if any.
+func (s Selector) Err() error {
+	return s.err
+}
+
+// MarshalJSON satisfies the [encoding/json.Marshaler] interface. It returns
+// [Selector.Err], if it is not nil.
+func (s Selector) MarshalJSON() ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return json.Marshal(s.selector())
+}
+
+// Map returns the selector as it would be decoded from JSON, suitable for
+// [Match].
+func (s Selector) Map() (map[string]interface{}, error) {
+	raw, err := s.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return m, nil
+}
+
+// Query returns a query with the selector s, which may be passed to
+// [kivik.DB.Find] or [kivik.DB.Explain]. Other query fields, such as sort or
+// limit, may be added to the result.
+func (s Selector) Query() map[string]interface{} {
+	return map[string]interface{}{"selector": s}
+}
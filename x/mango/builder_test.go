@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestSelectorBuilder(t *testing.T) {
+	tests := []struct {
+		name   string
+		sel    Selector
+		want   string
+		status int
+		err    string
+	}{
+		{
+			name: "empty",
+			want: `{}`,
+		},
+		{
+			name: "and",
+			sel:  Field("age").Gt(21).And(Field("type").Eq("user")),
+			want: `{"$and":[{"age":{"$gt":21}},{"type":{"$eq":"user"}}]}`,
+		},
+		{
+			name: "flattened",
+			sel:  Field("a").Eq(1).And(Field("b").Eq(2)).And(Field("c").Eq(3)),
+			want: `{"$and":[{"a":{"$eq":1}},{"b":{"$eq":2}},{"c":{"$eq":3}}]}`,
+		},
+		{
+			name: "or, nor and not",
+			sel:  Or(Nor(Field("a").Exists(true)), Not(Field("b").In("x", "y"))),
+			want: `{"$or":[{"$nor":[{"a":{"$exists":true}}]},{"$not":{"b":{"$in":["x","y"]}}}]}`,
+		},
+		{
+			name: "array operators",
+			sel: And(
+				Field("tags").All(),
+				Field("tags").Size(2),
+				Field("scores").ElemMatch(Field("value").Gte(10)),
+				Field("owner.name").Regex("^Old"),
+				Field("n").Mod(4, 1),
+				Field("t").Type("string"),
+			),
+			want: `{"$and":[
+				{"tags":{"$all":[]}},
+				{"tags":{"$size":2}},
+				{"scores":{"$elemMatch":{"value":{"$gte":10}}}},
+				{"owner.name":{"$regex":"^Old"}},
+				{"n":{"$mod":[4,1]}},
+				{"t":{"$type":"string"}}
+			]}`,
+		},
+		{
+			name:   "unknown operator",
+			sel:    Field("age").Op("$gtt", 21).And(Field("type").Eq("user")),
+			status: http.StatusBadRequest,
+			err:    "invalid operator $gtt",
+		},
+		{
+			name:   "invalid type",
+			sel:    Not(Field("age").Type("integer")),
+			status: http.StatusBadRequest,
+			err:    "invalid $type: integer",
+		},
+		{
+			name:   "zero divisor",
+			sel:    Field("n").Mod(0, 1),
+			status: http.StatusBadRequest,
+			err:    "$mod requires a non-zero divisor",
+		},
+		{
+			name:   "invalid nested selector",
+			sel:    Field("a").ElemMatch(Field("").Eq(1)),
+			status: http.StatusBadRequest,
+			err:    "field name required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.sel)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d := testy.DiffAsJSON([]byte(tt.want), got); d != nil {
+					t.Error(d)
+				}
+			} else if err == nil {
+				t.Error("Expected a marshaling error")
+			}
+			testy.StatusError(t, tt.err, tt.status, tt.sel.Err())
+		})
+	}
+}
+
+func TestSelectorMatch(t *testing.T) {
+	sel := Field("age").Gt(21).And(Field("type").Eq("user"))
+	m, err := sel.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		doc  map[string]interface{}
+		want bool
+	}{
+		{doc: map[string]interface{}{"age": 30.0, "type": "user"}, want: true},
+		{doc: map[string]interface{}{"age": 20.0, "type": "user"}, want: false},
+	} {
+		got, err := Match(m, tt.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Match(%v) = %v, want %v", tt.doc, got, tt.want)
+		}
+	}
+
+	q, err := ParseQuery(sel.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(m, q.Selector); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Type ranks, in CouchDB collation order.
+const (
+	rankNull = iota
+	rankFalse
+	rankTrue
+	rankNumber
+	rankString
+	rankArray
+	rankObject
+)
+
+func rank(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return rankNull
+	case bool:
+		if t {
+			return rankTrue
+		}
+		return rankFalse
+	case float64, json.Number, int, int64:
+		return rankNumber
+	case string:
+		return rankString
+	case []interface{}:
+		return rankArray
+	default:
+		return rankObject
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	}
+	return 0
+}
+
+// Compare compares two decoded JSON values according to CouchDB's collation
+// rules, returning -1, 0 or +1. Values of different types sort in the order
+// null, false, true, numbers, strings, arrays, objects. Strings are compared
+// by their raw byte values, rather than with the Unicode Collation Algorithm
+// used by CouchDB.
+func Compare(a, b interface{}) int {
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return sign(ra - rb)
+	}
+	switch ra {
+	case rankNumber:
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case rankString:
+		return strings.Compare(a.(string), b.(string))
+	case rankArray:
+		aa, ab := a.([]interface{}), b.([]interface{})
+		for i := 0; i < len(aa) && i < len(ab); i++ {
+			if c := Compare(aa[i], ab[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(aa) - len(ab))
+	case rankObject:
+		oa, _ := a.(map[string]interface{})
+		ob, _ := b.(map[string]interface{})
+		ka, kb := sortedKeys(oa), sortedKeys(ob)
+		for i := 0; i < len(ka) && i < len(kb); i++ {
+			if c := strings.Compare(ka[i], kb[i]); c != 0 {
+				return c
+			}
+			if c := Compare(oa[ka[i]], ob[kb[i]]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(ka) - len(kb))
+	}
+	return 0
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
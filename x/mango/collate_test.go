@@ -0,0 +1,45 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		float64(-1),
+		float64(1),
+		float64(2),
+		"a",
+		"b",
+		"ba",
+		[]interface{}{},
+		[]interface{}{"a"},
+		[]interface{}{"a", "b"},
+		[]interface{}{"b"},
+		map[string]interface{}{},
+		map[string]interface{}{"a": float64(1)},
+		map[string]interface{}{"a": float64(2)},
+		map[string]interface{}{"b": float64(1)},
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := sign(i - j)
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%v, %v) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package mango provides an in-process implementation of CouchDB's Mango
+// query language, for use by drivers which do not have a server to evaluate
+// queries for them.
+//
+// Documents and selectors are expected in the form produced by
+// [encoding/json] when decoding into an interface{}: maps, slices, strings,
+// float64s, bools and nil.
+package mango
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func badSelector(format string, args ...interface{}) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// Match reports whether doc matches selector. An error is returned if the
+// selector is invalid.
+func Match(selector map[string]interface{}, doc interface{}) (bool, error) {
+	for key, cond := range selector {
+		ok, err := matchKey(key, cond, doc)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchKey(key string, cond, doc interface{}) (bool, error) {
+	switch key {
+	case "$and", "$or", "$nor":
+		subs, ok := cond.([]interface{})
+		if !ok {
+			return false, badSelector("%s requires an array", key)
+		}
+		for _, sub := range subs {
+			subSel, ok := sub.(map[string]interface{})
+			if !ok {
+				return false, badSelector("%s requires an array of selectors", key)
+			}
+			ok, err := Match(subSel, doc)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case key == "$and" && !ok:
+				return false, nil
+			case key == "$or" && ok:
+				return true, nil
+			case key == "$nor" && ok:
+				return false, nil
+			}
+		}
+		return key != "$or", nil
+	case "$not":
+		subSel, ok := cond.(map[string]interface{})
+		if !ok {
+			return false, badSelector("$not requires a selector")
+		}
+		ok, err := Match(subSel, doc)
+		return !ok && err == nil, err
+	}
+	if strings.HasPrefix(key, "$") {
+		return false, badSelector("invalid operator %s", key)
+	}
+	value, exists := lookup(doc, splitField(key))
+	return matchCond(cond, value, exists)
+}
+
+// splitField splits a dotted field name into its components. A literal dot
+// may be escaped with a backslash.
+func splitField(field string) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == '\\' && i+1 < len(field) && field[i+1] == '.':
+			cur.WriteByte('.')
+			i++
+		case c == '.':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	for _, part := range path {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// isOperatorObject returns true if cond is an object whose keys are all
+// operators.
+func isOperatorObject(cond interface{}) (map[string]interface{}, bool) {
+	obj, ok := cond.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return nil, false
+	}
+	for k := range obj {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+func matchCond(cond, value interface{}, exists bool) (bool, error) {
+	ops, ok := isOperatorObject(cond)
+	if !ok {
+		if sub, ok := cond.(map[string]interface{}); ok && len(sub) > 0 {
+			// An implicit nested selector, e.g. {"a": {"b": 1}}
+			return Match(sub, value)
+		}
+		return exists && Compare(value, cond) == 0, nil
+	}
+	for op, arg := range ops {
+		ok, err := matchOp(op, arg, value, exists)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchOp(op string, arg, value interface{}, exists bool) (bool, error) {
+	switch op {
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			return false, badSelector("$exists requires a boolean")
+		}
+		return exists == want, nil
+	case "$not":
+		ok, err := matchCond(arg, value, exists)
+		return !ok && err == nil, err
+	case "$and", "$or", "$nor":
+		conds, ok := arg.([]interface{})
+		if !ok {
+			return false, badSelector("%s requires an array", op)
+		}
+		for _, c := range conds {
+			ok, err := matchCond(c, value, exists)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case op == "$and" && !ok:
+				return false, nil
+			case op == "$or" && ok:
+				return true, nil
+			case op == "$nor" && ok:
+				return false, nil
+			}
+		}
+		return op != "$or", nil
+	}
+	if !exists {
+		return false, nil
+	}
+	switch op {
+	case "$eq":
+		return Compare(value, arg) == 0, nil
+	case "$ne":
+		return Compare(value, arg) != 0, nil
+	case "$lt":
+		return Compare(value, arg) < 0, nil
+	case "$lte":
+		return Compare(value, arg) <= 0, nil
+	case "$gt":
+		return Compare(value, arg) > 0, nil
+	case "$gte":
+		return Compare(value, arg) >= 0, nil
+	case "$in", "$nin":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return false, badSelector("%s requires an array", op)
+		}
+		found := false
+		for _, v := range list {
+			if Compare(value, v) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (op == "$in"), nil
+	case "$type":
+		want, ok := arg.(string)
+		if !ok {
+			return false, badSelector("$type requires a string")
+		}
+		return typeName(value) == want, nil
+	case "$size":
+		want, ok := arg.(float64)
+		if !ok {
+			return false, badSelector("$size requires a number")
+		}
+		list, ok := value.([]interface{})
+		return ok && float64(len(list)) == want, nil
+	case "$mod":
+		args, ok := arg.([]interface{})
+		if !ok || len(args) != 2 {
+			return false, badSelector("$mod requires an array of [Divisor, Remainder]")
+		}
+		divisor, ok1 := args[0].(float64)
+		remainder, ok2 := args[1].(float64)
+		if !ok1 || !ok2 || divisor == 0 {
+			return false, badSelector("$mod requires a non-zero divisor and a remainder")
+		}
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return false, nil
+		}
+		return math.Mod(n, divisor) == remainder, nil
+	case "$regex":
+		pattern, ok := arg.(string)
+		if !ok {
+			return false, badSelector("$regex requires a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, badSelector("invalid $regex: %s", err)
+		}
+		s, ok := value.(string)
+		return ok && re.MatchString(s), nil
+	case "$all":
+		want, ok := arg.([]interface{})
+		if !ok {
+			return false, badSelector("$all requires an array")
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+	outer:
+		for _, w := range want {
+			for _, v := range list {
+				if Compare(v, w) == 0 {
+					continue outer
+				}
+			}
+			return false, nil
+		}
+		return true, nil
+	case "$elemMatch", "$allMatch":
+		list, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		if op == "$allMatch" && len(list) == 0 {
+			return false, nil
+		}
+		for _, v := range list {
+			ok, err := matchCond(arg, v, true)
+			if err != nil {
+				return false, err
+			}
+			if op == "$elemMatch" && ok {
+				return true, nil
+			}
+			if op == "$allMatch" && !ok {
+				return false, nil
+			}
+		}
+		return op == "$allMatch", nil
+	}
+	return false, badSelector("invalid operator %s", op)
+}
+
+func typeName(v interface{}) string {
+	switch rank(v) {
+	case rankNull:
+		return "null"
+	case rankFalse, rankTrue:
+		return "boolean"
+	case rankNumber:
+		return "number"
+	case rankString:
+		return "string"
+	case rankArray:
+		return "array"
+	}
+	return "object"
+}
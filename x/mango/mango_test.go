@@ -0,0 +1,90 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestMatch(t *testing.T) {
+	type tst struct {
+		selector string
+		want     bool
+		status   int
+		err      string
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"_id": "cow",
+		"name": "Bessie",
+		"age": 7,
+		"tags": ["farm", "dairy"],
+		"owner": {"name": "Old MacDonald", "address": {"city": "Springfield"}},
+		"dotted.key": true,
+		"scores": [3, 8, 12]
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := testy.NewTable()
+	tests.Add("empty", tst{selector: `{}`, want: true})
+	tests.Add("implicit eq", tst{selector: `{"name": "Bessie"}`, want: true})
+	tests.Add("implicit eq mismatch", tst{selector: `{"name": "Daisy"}`, want: false})
+	tests.Add("nested dotted", tst{selector: `{"owner.address.city": "Springfield"}`, want: true})
+	tests.Add("nested object", tst{selector: `{"owner": {"address": {"city": "Springfield"}}}`, want: true})
+	tests.Add("escaped dot", tst{selector: `{"dotted\\.key": true}`, want: true})
+	tests.Add("gt", tst{selector: `{"age": {"$gt": 5}}`, want: true})
+	tests.Add("range", tst{selector: `{"age": {"$gte": 7, "$lt": 7}}`, want: false})
+	tests.Add("ne missing field", tst{selector: `{"color": {"$ne": "brown"}}`, want: false})
+	tests.Add("exists false", tst{selector: `{"color": {"$exists": false}}`, want: true})
+	tests.Add("in", tst{selector: `{"name": {"$in": ["Daisy", "Bessie"]}}`, want: true})
+	tests.Add("nin", tst{selector: `{"name": {"$nin": ["Daisy", "Bessie"]}}`, want: false})
+	tests.Add("type", tst{selector: `{"tags": {"$type": "array"}}`, want: true})
+	tests.Add("size", tst{selector: `{"tags": {"$size": 2}}`, want: true})
+	tests.Add("mod", tst{selector: `{"age": {"$mod": [4, 3]}}`, want: true})
+	tests.Add("regex", tst{selector: `{"name": {"$regex": "^Bes"}}`, want: true})
+	tests.Add("all", tst{selector: `{"tags": {"$all": ["dairy", "farm"]}}`, want: true})
+	tests.Add("elemMatch", tst{selector: `{"scores": {"$elemMatch": {"$gt": 10}}}`, want: true})
+	tests.Add("allMatch", tst{selector: `{"scores": {"$allMatch": {"$gt": 10}}}`, want: false})
+	tests.Add("and", tst{selector: `{"$and": [{"name": "Bessie"}, {"age": 7}]}`, want: true})
+	tests.Add("or", tst{selector: `{"$or": [{"name": "Daisy"}, {"age": 7}]}`, want: true})
+	tests.Add("nor", tst{selector: `{"$nor": [{"name": "Daisy"}, {"age": 7}]}`, want: false})
+	tests.Add("not", tst{selector: `{"$not": {"name": "Daisy"}}`, want: true})
+	tests.Add("field not", tst{selector: `{"age": {"$not": {"$lt": 5}}}`, want: true})
+	tests.Add("invalid operator", tst{
+		selector: `{"age": {"$foo": 5}}`,
+		status:   http.StatusBadRequest,
+		err:      "invalid operator $foo",
+	})
+	tests.Add("invalid regex", tst{
+		selector: `{"name": {"$regex": "("}}`,
+		status:   http.StatusBadRequest,
+		err:      "invalid $regex: error parsing regexp: missing closing ): `(`",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		var selector map[string]interface{}
+		if err := json.Unmarshal([]byte(test.selector), &selector); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Match(selector, doc)
+		testy.StatusError(t, test.err, test.status, err)
+		if got != test.want {
+			t.Errorf("Unexpected result: %v", got)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultLimit is the maximum number of results returned by a query which
+// does not specify a limit, as in CouchDB.
+const DefaultLimit = 25
+
+// SortField is a single field of a query's sort specification.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Query is a parsed Mango query, as passed to [kivik.DB.Find].
+type Query struct {
+	Selector map[string]interface{}
+	Fields   []string
+	Sort     []SortField
+	Limit    int64
+	Skip     int64
+}
+
+// ParseQuery parses a query, which may be any value accepted by
+// [kivik.DB.Find]: a JSON string, []byte, json.RawMessage, io.Reader, or any
+// value which marshals to a JSON query object.
+func ParseQuery(query interface{}) (*Query, error) {
+	var raw []byte
+	switch t := query.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	case io.Reader:
+		var err error
+		if raw, err = io.ReadAll(t); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if raw, err = json.Marshal(query); err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+		Fields   []string               `json:"fields"`
+		Sort     []interface{}          `json:"sort"`
+		Limit    *int64                 `json:"limit"`
+		Skip     int64                  `json:"skip"`
+	}
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if q.Selector == nil {
+		return nil, badSelector("query must contain a selector")
+	}
+	result := &Query{
+		Selector: q.Selector,
+		Fields:   q.Fields,
+		Limit:    DefaultLimit,
+		Skip:     q.Skip,
+	}
+	if q.Limit != nil {
+		result.Limit = *q.Limit
+	}
+	for _, s := range q.Sort {
+		switch t := s.(type) {
+		case string:
+			result.Sort = append(result.Sort, SortField{Field: t})
+		case map[string]interface{}:
+			if len(t) != 1 {
+				return nil, badSelector("invalid sort field: %v", t)
+			}
+			for field, dir := range t {
+				switch dir {
+				case "asc":
+					result.Sort = append(result.Sort, SortField{Field: field})
+				case "desc":
+					result.Sort = append(result.Sort, SortField{Field: field, Descending: true})
+				default:
+					return nil, badSelector("invalid sort direction: %v", dir)
+				}
+			}
+		default:
+			return nil, badSelector("invalid sort field: %v", t)
+		}
+	}
+	return result, nil
+}
+
+// Apply returns the documents from docs which match the query's selector,
+// sorted, paginated and projected as requested by the query. docs is not
+// modified.
+func (q *Query) Apply(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+	matched := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		ok, err := Match(q.Selector, doc)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, doc)
+		}
+	}
+	q.sort(matched)
+	if q.Skip >= int64(len(matched)) {
+		return nil, nil
+	}
+	matched = matched[q.Skip:]
+	if q.Limit >= 0 && q.Limit < int64(len(matched)) {
+		matched = matched[:q.Limit]
+	}
+	if len(q.Fields) > 0 {
+		for i, doc := range matched {
+			matched[i] = Project(doc, q.Fields)
+		}
+	}
+	return matched, nil
+}
+
+func (q *Query) sort(docs []map[string]interface{}) {
+	if len(q.Sort) == 0 {
+		return
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, s := range q.Sort {
+			path := splitField(s.Field)
+			a, _ := lookup(docs[i], path)
+			b, _ := lookup(docs[j], path)
+			if c := Compare(a, b); c != 0 {
+				return (c < 0) != s.Descending
+			}
+		}
+		return false
+	})
+}
+
+// Project returns a copy of doc containing only the requested fields, which
+// may be dotted paths to nested fields.
+func Project(doc map[string]interface{}, fields []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		path := splitField(field)
+		value, ok := lookup(doc, path)
+		if !ok {
+			continue
+		}
+		target := result
+		for _, part := range path[:len(path)-1] {
+			next, ok := target[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestParseQuery(t *testing.T) {
+	type tst struct {
+		query  interface{}
+		want   *Query
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("string", tst{
+		query: `{"selector":{"a":1},"sort":["a",{"b":"desc"}],"limit":5,"skip":1,"fields":["a"]}`,
+		want: &Query{
+			Selector: map[string]interface{}{"a": float64(1)},
+			Fields:   []string{"a"},
+			Sort:     []SortField{{Field: "a"}, {Field: "b", Descending: true}},
+			Limit:    5,
+			Skip:     1,
+		},
+	})
+	tests.Add("map, default limit", tst{
+		query: map[string]interface{}{"selector": map[string]interface{}{}},
+		want: &Query{
+			Selector: map[string]interface{}{},
+			Limit:    DefaultLimit,
+		},
+	})
+	tests.Add("missing selector", tst{
+		query:  `{}`,
+		status: http.StatusBadRequest,
+		err:    "query must contain a selector",
+	})
+	tests.Add("invalid sort", tst{
+		query:  `{"selector":{},"sort":[{"a":"up"}]}`,
+		status: http.StatusBadRequest,
+		err:    "invalid sort direction: up",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		got, err := ParseQuery(test.query)
+		testy.StatusError(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.want, got); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestQueryApply(t *testing.T) {
+	docs := []map[string]interface{}{
+		{"_id": "a", "type": "cow", "age": float64(3), "name": map[string]interface{}{"first": "Bessie"}},
+		{"_id": "b", "type": "pig", "age": float64(2)},
+		{"_id": "c", "type": "cow", "age": float64(5)},
+		{"_id": "d", "type": "cow", "age": float64(1)},
+	}
+	q, err := ParseQuery(`{"selector":{"type":"cow"},"sort":[{"age":"desc"}],"skip":1,"limit":2,"fields":["_id","name.first"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.Apply(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"_id": "a", "name": map[string]interface{}{"first": "Bessie"}},
+		{"_id": "d"},
+	}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+}
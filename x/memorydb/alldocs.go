@@ -0,0 +1,120 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+func (d *db) AllDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(options, func(docID string) bool {
+		return !isLocal(docID)
+	})
+}
+
+func (d *db) DesignDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(options, func(docID string) bool {
+		return strings.HasPrefix(docID, "_design/")
+	})
+}
+
+func (d *db) LocalDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(options, isLocal)
+}
+
+// allDocs implements the _all_docs view, and its variants, over the
+// documents for which include returns true.
+func (d *db) allDocs(options map[string]interface{}, include func(string) bool) (driver.Rows, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	docs := data.liveDocs(include)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].id < docs[j].id })
+	descending := driverutil.BoolOption(options, "descending")
+	if descending {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+	total := int64(len(docs))
+
+	startKey, hasStart := driverutil.KeyOption(options, "startkey", "start_key")
+	endKey, hasEnd := driverutil.KeyOption(options, "endkey", "end_key")
+	inclusiveEnd := true
+	if _, ok := options["inclusive_end"]; ok {
+		inclusiveEnd = driverutil.BoolOption(options, "inclusive_end")
+	}
+	if key, ok := driverutil.KeyOption(options, "key"); ok {
+		startKey, endKey = key, key
+		hasStart, hasEnd, inclusiveEnd = true, true, true
+	}
+	before := func(a, b string) bool {
+		if descending {
+			return a > b
+		}
+		return a < b
+	}
+
+	var offset int64
+	result := make([]*driver.Row, 0, len(docs))
+	for _, doc := range docs {
+		if hasStart && before(doc.id, startKey) {
+			offset++
+			continue
+		}
+		if hasEnd && (before(endKey, doc.id) || (!inclusiveEnd && doc.id == endKey)) {
+			break
+		}
+		result = append(result, allDocsRow(doc, driverutil.BoolOption(options, "include_docs")))
+	}
+	if skip, ok := driverutil.IntOption(options, "skip"); ok && skip > 0 {
+		if skip > int64(len(result)) {
+			skip = int64(len(result))
+		}
+		result = result[skip:]
+		offset += skip
+	}
+	if limit, ok := driverutil.IntOption(options, "limit"); ok && limit >= 0 && limit < int64(len(result)) {
+		result = result[:limit]
+	}
+	var updateSeq string
+	if driverutil.BoolOption(options, "update_seq") {
+		updateSeq = strconv.FormatInt(data.seq, 10)
+	}
+	return driverutil.NewRows(result, offset, total, updateSeq), nil
+}
+
+func allDocsRow(doc *document, includeDocs bool) *driver.Row {
+	key, _ := json.Marshal(doc.id)
+	value, _ := json.Marshal(map[string]string{"rev": doc.leaf().rev})
+	row := &driver.Row{
+		ID:    doc.id,
+		Key:   key,
+		Value: bytes.NewReader(value),
+	}
+	if includeDocs {
+		row.Doc = bytes.NewReader(doc.leaf().toJSON(doc.id, false))
+	}
+	return row
+}
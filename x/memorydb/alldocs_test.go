@@ -0,0 +1,102 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAllDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"d", "b", "a", "c", "_design/foo", "_local/bar"} {
+		if _, err := db.Put(ctx, id, map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type tst struct {
+		options kivik.Options
+		ids     []string
+		offset  int64
+	}
+	tests := testy.NewTable()
+	tests.Add("default", tst{ids: []string{"_design/foo", "a", "b", "c", "d"}})
+	tests.Add("range", tst{
+		options: kivik.Options{"startkey": "b", "endkey": "c"},
+		ids:     []string{"b", "c"},
+		offset:  2,
+	})
+	tests.Add("exclusive end", tst{
+		options: kivik.Options{"startkey": "b", "endkey": "d", "inclusive_end": false},
+		ids:     []string{"b", "c"},
+		offset:  2,
+	})
+	tests.Add("descending", tst{
+		options: kivik.Options{"descending": true, "startkey": "c", "limit": 2},
+		ids:     []string{"c", "b"},
+		offset:  1,
+	})
+	tests.Add("key", tst{
+		options: kivik.Options{"key": "a"},
+		ids:     []string{"a"},
+		offset:  1,
+	})
+	tests.Add("skip and limit", tst{
+		options: kivik.Options{"skip": 1, "limit": 2},
+		ids:     []string{"a", "b"},
+		offset:  1,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		rows := db.AllDocs(ctx, test.options)
+		var ids []string
+		for rows.Next() {
+			id, _ := rows.ID()
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(test.ids, ids); d != nil {
+			t.Error(d)
+		}
+		meta, err := rows.Metadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.TotalRows != 5 || meta.Offset != test.offset {
+			t.Errorf("Unexpected metadata: %+v", meta)
+		}
+	})
+
+	t.Run("local docs", func(t *testing.T) {
+		rows := db.LocalDocs(ctx, kivik.Options{"include_docs": true})
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["_id"] != "_local/bar" {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		_ = rows.Close()
+	})
+}
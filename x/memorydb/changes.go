@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+// Changes returns the normal changes feed. Continuous and longpoll feeds are
+// not supported. The built-in _doc_ids, _selector and _design filters are
+// supported.
+func (d *db) Changes(_ context.Context, options map[string]interface{}) (driver.Changes, error) {
+	switch feed := driverutil.StringOption(options, "feed"); feed {
+	case "", "normal":
+	default:
+		return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: " + feed + " changes feed not supported by the memory driver"}
+	}
+	filter, err := driverutil.ChangesFilter(options, "memory")
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	var since int64
+	switch s := options["since"].(type) {
+	case string:
+		if s == "now" {
+			since = data.seq
+		} else {
+			since, _ = strconv.ParseInt(s, 10, 64)
+		}
+	default:
+		since, _ = driverutil.IntOption(options, "since")
+	}
+
+	docs := make([]*document, 0, len(data.docs))
+	for id, doc := range data.docs {
+		if isLocal(id) || doc.seq <= since {
+			continue
+		}
+		ok, err := filter(id, docBody(doc))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, doc)
+		}
+	}
+	descending := driverutil.BoolOption(options, "descending")
+	sort.Slice(docs, func(i, j int) bool {
+		return (docs[i].seq < docs[j].seq) != descending
+	})
+	if limit, ok := driverutil.IntOption(options, "limit"); ok && limit > 0 && limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	includeDocs := driverutil.BoolOption(options, "include_docs")
+	result := make([]*driver.Change, 0, len(docs))
+	lastSeq := strconv.FormatInt(since, 10)
+	for _, doc := range docs {
+		leaf := doc.leaf()
+		change := &driver.Change{
+			ID:      doc.id,
+			Seq:     strconv.FormatInt(doc.seq, 10),
+			Deleted: leaf.deleted,
+			Changes: driver.ChangedRevs{leaf.rev},
+		}
+		if includeDocs {
+			change.Doc = leaf.toJSON(doc.id, false)
+		}
+		result = append(result, change)
+		lastSeq = change.Seq
+	}
+	return driverutil.NewChanges(result, lastSeq), nil
+}
+
+// docBody returns a function which returns the body of the current revision
+// of doc, or nil if it is deleted.
+func docBody(doc *document) func() (map[string]interface{}, error) {
+	return func() (map[string]interface{}, error) {
+		leaf := doc.leaf()
+		if leaf.deleted {
+			return nil, nil
+		}
+		var body map[string]interface{}
+		err := json.Unmarshal(leaf.toJSON(doc.id, false), &body)
+		return body, err
+	}
+}
@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	revA, _ := db.Put(ctx, "a", map[string]string{})
+	_, _ = db.Put(ctx, "b", map[string]string{})
+	_, _ = db.Delete(ctx, "a", revA)
+
+	type change struct {
+		ID      string
+		Seq     string
+		Deleted bool
+	}
+	collect := func(options kivik.Options) ([]change, string) {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var changes []change
+		for feed.Next() {
+			changes = append(changes, change{ID: feed.ID(), Seq: feed.Seq(), Deleted: feed.Deleted()})
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := feed.Metadata()
+		return changes, meta.LastSeq
+	}
+
+	changes, lastSeq := collect(nil)
+	want := []change{{ID: "b", Seq: "2"}, {ID: "a", Seq: "3", Deleted: true}}
+	if d := testy.DiffInterface(want, changes); d != nil {
+		t.Error(d)
+	}
+	if lastSeq != "3" {
+		t.Errorf("Unexpected last seq: %s", lastSeq)
+	}
+	changes, _ = collect(kivik.Options{"since": "2"})
+	if d := testy.DiffInterface(want[1:], changes); d != nil {
+		t.Error(d)
+	}
+
+	err := db.Changes(ctx, kivik.Options{"feed": "continuous"}).Err()
+	testutil.CheckError(t, "kivik: continuous changes feed not supported by the memory driver", http.StatusNotImplemented, err)
+}
+
+func TestChangesFilters(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	_, _ = db.Put(ctx, "a", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "b", map[string]string{"type": "horse"})
+	_, _ = db.Put(ctx, "c", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "_design/foo", map[string]string{})
+
+	ids := func(t *testing.T, options kivik.Options) []string {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var ids []string
+		for feed.Next() {
+			ids = append(ids, feed.ID())
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	tests := []struct {
+		name    string
+		options kivik.Options
+		want    []string
+	}{
+		{
+			name:    "doc ids",
+			options: kivik.DocIDsFilter("c", "a", "z"),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "selector",
+			options: kivik.SelectorFilter(map[string]interface{}{"type": "cow"}),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "JSON selector",
+			options: kivik.SelectorFilter(`{"type":{"$ne":"cow"}}`),
+			want:    []string{"b"},
+		},
+		{
+			name:    "design",
+			options: kivik.DesignFilter(),
+			want:    []string{"_design/foo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := testy.DiffInterface(tt.want, ids(t, tt.options)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+	t.Run("unsupported", func(t *testing.T) {
+		err := db.Changes(ctx, kivik.Param("filter", "foo/bar")).Err()
+		testutil.CheckError(t, "kivik: foo/bar filter not supported by the memory driver", http.StatusNotImplemented, err)
+	})
+	t.Run("invalid selector", func(t *testing.T) {
+		err := db.Changes(ctx, kivik.SelectorFilter("[")).Err()
+		testutil.CheckError(t, "unexpected end of JSON input", http.StatusBadRequest, err)
+	})
+}
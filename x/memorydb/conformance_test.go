@@ -0,0 +1,40 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"testing"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/kiviktest"
+)
+
+func TestConformance(t *testing.T) {
+	kiviktest.Run(t, kiviktest.Suite{
+		NewClient: func(t *testing.T) *kivik.Client {
+			client, err := kivik.New("memory", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return client
+		},
+		Capabilities: kiviktest.Capabilities{
+			Changes:     true,
+			Attachments: true,
+			Security:    true,
+			LocalDocs:   true,
+			DesignDocs:  true,
+			Find:        true,
+		},
+	})
+}
@@ -0,0 +1,208 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var (
+	errDatabaseNotFound = &kivik.Error{Status: http.StatusNotFound, Message: "database does not exist"}
+	errConflict         = &kivik.Error{Status: http.StatusConflict, Message: "document update conflict"}
+	errMissing          = &kivik.Error{Status: http.StatusNotFound, Message: "missing"}
+	errDeleted          = &kivik.Error{Status: http.StatusNotFound, Message: "deleted"}
+)
+
+// database holds the contents of a single database.
+type database struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	seq      int64
+	security *driver.Security
+	indexes  []driver.Index
+}
+
+func newDatabase() *database {
+	return &database{
+		docs:     make(map[string]*document),
+		security: &driver.Security{},
+	}
+}
+
+// document is a document and its revision history, oldest first.
+type document struct {
+	id   string
+	revs []*revision
+	seq  int64
+}
+
+func (d *document) leaf() *revision {
+	return d.revs[len(d.revs)-1]
+}
+
+// revision returns the requested revision, or the leaf revision if rev is
+// empty.
+func (d *document) revision(rev string) (*revision, error) {
+	if rev == "" {
+		if d.leaf().deleted {
+			return nil, errDeleted
+		}
+		return d.leaf(), nil
+	}
+	for _, r := range d.revs {
+		if r.rev == rev {
+			if r.body == nil && !r.deleted {
+				// Compacted away
+				return nil, errMissing
+			}
+			return r, nil
+		}
+	}
+	return nil, errMissing
+}
+
+type revision struct {
+	rev         string
+	body        map[string]interface{}
+	deleted     bool
+	attachments map[string]*attachment
+}
+
+func (r *revision) generation() int64 {
+	gen, _ := strconv.ParseInt(strings.SplitN(r.rev, "-", 2)[0], 10, 64)
+	return gen
+}
+
+type attachment struct {
+	contentType string
+	data        []byte
+	digest      string
+	revpos      int64
+}
+
+func newAttachment(contentType string, data []byte, revpos int64) *attachment {
+	sum := md5.Sum(data)
+	return &attachment{
+		contentType: contentType,
+		data:        data,
+		digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		revpos:      revpos,
+	}
+}
+
+func (a *attachment) stub() map[string]interface{} {
+	return map[string]interface{}{
+		"content_type": a.contentType,
+		"digest":       a.digest,
+		"length":       len(a.data),
+		"revpos":       a.revpos,
+		"stub":         true,
+	}
+}
+
+// toJSON renders the revision as a CouchDB document.
+func (r *revision) toJSON(docID string, withAttachments bool) json.RawMessage {
+	doc := make(map[string]interface{}, len(r.body)+3)
+	for k, v := range r.body {
+		doc[k] = v
+	}
+	doc["_id"] = docID
+	doc["_rev"] = r.rev
+	if r.deleted {
+		doc["_deleted"] = true
+	}
+	if len(r.attachments) > 0 {
+		atts := make(map[string]interface{}, len(r.attachments))
+		for name, att := range r.attachments {
+			stub := att.stub()
+			if withAttachments {
+				delete(stub, "stub")
+				stub["data"] = att.data
+			}
+			atts[name] = stub
+		}
+		doc["_attachments"] = atts
+	}
+	body, _ := json.Marshal(doc)
+	return body
+}
+
+func newRevID(gen int64, prev string, body map[string]interface{}, deleted bool) string {
+	h := md5.New()
+	_ = json.NewEncoder(h).Encode([]interface{}{prev, body, deleted})
+	return fmt.Sprintf("%d-%x", gen, h.Sum(nil))
+}
+
+func isLocal(docID string) bool {
+	return strings.HasPrefix(docID, "_local/")
+}
+
+// update stores a new revision of docID, after checking that rev is the
+// current revision. The caller must hold the write lock.
+func (d *database) update(docID, rev string, newRev *revision) (string, error) {
+	doc, exists := d.docs[docID]
+	var gen int64
+	var prev string
+	switch {
+	case exists && (!doc.leaf().deleted || rev != ""):
+		if rev != doc.leaf().rev {
+			return "", errConflict
+		}
+		gen = doc.leaf().generation()
+		prev = doc.leaf().rev
+	case rev != "":
+		return "", errConflict
+	case exists:
+		gen = doc.leaf().generation()
+		prev = doc.leaf().rev
+	}
+	if !exists {
+		doc = &document{id: docID}
+		d.docs[docID] = doc
+	}
+	gen++
+	for _, att := range newRev.attachments {
+		if att.revpos == 0 {
+			att.revpos = gen
+		}
+	}
+	newRev.rev = newRevID(gen, prev, newRev.body, newRev.deleted)
+	doc.revs = append(doc.revs, newRev)
+	if !isLocal(docID) {
+		d.seq++
+		doc.seq = d.seq
+	}
+	return newRev.rev, nil
+}
+
+// liveDocs returns the non-deleted documents whose IDs satisfy include.
+// The caller must hold the read lock.
+func (d *database) liveDocs(include func(docID string) bool) []*document {
+	docs := make([]*document, 0, len(d.docs))
+	for id, doc := range d.docs {
+		if !doc.leaf().deleted && include(id) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
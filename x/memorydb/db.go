@@ -0,0 +1,421 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+type db struct {
+	client *client
+	name   string
+}
+
+var (
+	_ driver.DB          = &db{}
+	_ driver.RevGetter   = &db{}
+	_ driver.MetaGetter  = &db{}
+	_ driver.DesignDocer = &db{}
+	_ driver.LocalDocer  = &db{}
+)
+
+func (d *db) database() (*database, error) {
+	return d.client.database(d.name)
+}
+
+// docMeta holds the special, underscore-prefixed fields of a document.
+type docMeta struct {
+	ID          string                     `json:"_id"`
+	Rev         string                     `json:"_rev"`
+	Deleted     bool                       `json:"_deleted"`
+	Attachments map[string]json.RawMessage `json:"_attachments"`
+}
+
+// parseDoc splits doc into its body and special fields.
+func parseDoc(doc interface{}) (map[string]interface{}, *docMeta, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Message: "document must be a JSON object"}
+	}
+	meta := &docMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	for k := range body {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		switch k {
+		case "_id", "_rev", "_deleted", "_attachments", "_revisions", "_conflicts":
+			delete(body, k)
+		default:
+			return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Message: "bad special document member: " + k}
+		}
+	}
+	return body, meta, nil
+}
+
+// attachments builds the attachments for a new revision from the
+// _attachments field of a document, copying stubs from prev.
+func attachments(atts map[string]json.RawMessage, prev *revision) (map[string]*attachment, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*attachment, len(atts))
+	for name, raw := range atts {
+		var att struct {
+			ContentType string `json:"content_type"`
+			Data        []byte `json:"data"`
+			Stub        bool   `json:"stub"`
+		}
+		if err := json.Unmarshal(raw, &att); err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+		if att.Stub {
+			if prev == nil || prev.attachments[name] == nil {
+				return nil, &kivik.Error{Status: http.StatusPreconditionFailed, Message: "invalid attachment stub for " + name}
+			}
+			result[name] = prev.attachments[name]
+			continue
+		}
+		result[name] = newAttachment(att.ContentType, att.Data, 0)
+	}
+	return result, nil
+}
+
+func (d *db) Put(_ context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	body, meta, err := parseDoc(doc)
+	if err != nil {
+		return "", err
+	}
+	if meta.ID != "" && meta.ID != docID {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "document ID does not match"}
+	}
+	rev := meta.Rev
+	if r := driverutil.StringOption(options, "rev"); r != "" {
+		rev = r
+	}
+	data, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	var prev *revision
+	if existing, ok := data.docs[docID]; ok && !existing.leaf().deleted {
+		prev = existing.leaf()
+	}
+	atts, err := attachments(meta.Attachments, prev)
+	if err != nil {
+		return "", err
+	}
+	if meta.Deleted {
+		body = map[string]interface{}{}
+		atts = nil
+	}
+	return data.update(docID, rev, &revision{
+		body:        body,
+		deleted:     meta.Deleted,
+		attachments: atts,
+	})
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	_, meta, err := parseDoc(doc)
+	if err != nil {
+		return "", "", err
+	}
+	docID := meta.ID
+	if docID == "" {
+		docID = driverutil.NewDocID()
+	}
+	rev, err := d.Put(ctx, docID, doc, options)
+	return docID, rev, err
+}
+
+func (d *db) Get(_ context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	doc, ok := data.docs[docID]
+	if !ok {
+		return nil, errMissing
+	}
+	rev, err := doc.revision(driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		Rev:  rev.rev,
+		Body: io.NopCloser(bytes.NewReader(rev.toJSON(docID, driverutil.BoolOption(options, "attachments")))),
+	}, nil
+}
+
+func (d *db) GetRev(_ context.Context, docID string, options map[string]interface{}) (string, error) {
+	data, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	doc, ok := data.docs[docID]
+	if !ok {
+		return "", errMissing
+	}
+	rev, err := doc.revision(driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return "", err
+	}
+	return rev.rev, nil
+}
+
+// GetMeta reports the metadata of a document without encoding its body for
+// the caller. Unlike Get, the tombstone of a deleted document is reported,
+// rather than returning an error.
+func (d *db) GetMeta(_ context.Context, docID string, options map[string]interface{}) (*driver.DocMeta, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	doc, ok := data.docs[docID]
+	if !ok {
+		return nil, errMissing
+	}
+	var rev *revision
+	if r := driverutil.StringOption(options, "rev"); r != "" {
+		if rev, err = doc.revision(r); err != nil {
+			return nil, err
+		}
+	} else {
+		rev = doc.leaf()
+	}
+	meta := &driver.DocMeta{
+		Rev:     rev.rev,
+		Deleted: rev.deleted,
+		Size:    int64(len(rev.toJSON(docID, false))),
+	}
+	if driverutil.BoolOption(options, "revs_info") {
+		for i := len(doc.revs) - 1; i >= 0; i-- {
+			r := doc.revs[i]
+			if len(meta.RevsInfo) == 0 && r != rev {
+				continue
+			}
+			status := "available"
+			switch {
+			case r.deleted:
+				status = "deleted"
+			case r.body == nil:
+				status = "missing"
+			}
+			meta.RevsInfo = append(meta.RevsInfo, driver.RevInfo{Rev: r.rev, Status: status})
+		}
+	}
+	return meta, nil
+}
+
+func (d *db) Delete(_ context.Context, docID string, options map[string]interface{}) (string, error) {
+	rev := driverutil.StringOption(options, "rev")
+	if rev == "" {
+		return "", errConflict
+	}
+	data, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	doc, ok := data.docs[docID]
+	if !ok {
+		return "", errMissing
+	}
+	if doc.leaf().deleted {
+		return "", errDeleted
+	}
+	return data.update(docID, rev, &revision{
+		body:    map[string]interface{}{},
+		deleted: true,
+	})
+}
+
+func (d *db) Stats(context.Context) (*driver.DBStats, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	stats := &driver.DBStats{
+		Name:      d.name,
+		UpdateSeq: strconv.FormatInt(data.seq, 10),
+	}
+	for id, doc := range data.docs {
+		switch {
+		case isLocal(id):
+		case doc.leaf().deleted:
+			stats.DeletedCount++
+		default:
+			stats.DocCount++
+		}
+	}
+	return stats, nil
+}
+
+// Compact discards the bodies of all non-leaf revisions.
+func (d *db) Compact(context.Context) error {
+	data, err := d.database()
+	if err != nil {
+		return err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	for _, doc := range data.docs {
+		for _, rev := range doc.revs[:len(doc.revs)-1] {
+			rev.body = nil
+			rev.attachments = nil
+		}
+	}
+	return nil
+}
+
+func (d *db) CompactView(context.Context, string) error {
+	_, err := d.database()
+	return err
+}
+
+func (d *db) ViewCleanup(context.Context) error {
+	_, err := d.database()
+	return err
+}
+
+func (d *db) Security(context.Context) (*driver.Security, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	sec := *data.security
+	return &sec, nil
+}
+
+func (d *db) SetSecurity(_ context.Context, security *driver.Security) error {
+	data, err := d.database()
+	if err != nil {
+		return err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	sec := *security
+	data.security = &sec
+	return nil
+}
+
+func (d *db) Query(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+	return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: views are not supported by the memory driver"}
+}
+
+// updateAttachments stores a new revision of docID, with its attachments
+// modified by fn. If fn returns an error, no update is made.
+func (d *db) updateAttachments(docID, rev string, fn func(map[string]*attachment) error) (string, error) {
+	data, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	body := map[string]interface{}{}
+	atts := map[string]*attachment{}
+	if doc, ok := data.docs[docID]; ok && !doc.leaf().deleted {
+		leaf := doc.leaf()
+		body = leaf.body
+		for name, att := range leaf.attachments {
+			atts[name] = att
+		}
+	}
+	if err := fn(atts); err != nil {
+		return "", err
+	}
+	return data.update(docID, rev, &revision{
+		body:        body,
+		attachments: atts,
+	})
+}
+
+func (d *db) PutAttachment(_ context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	content, err := io.ReadAll(att.Content)
+	if err != nil {
+		return "", err
+	}
+	return d.updateAttachments(docID, driverutil.StringOption(options, "rev"), func(atts map[string]*attachment) error {
+		atts[att.Filename] = newAttachment(att.ContentType, content, 0)
+		return nil
+	})
+}
+
+func (d *db) DeleteAttachment(_ context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	return d.updateAttachments(docID, driverutil.StringOption(options, "rev"), func(atts map[string]*attachment) error {
+		if _, ok := atts[filename]; !ok {
+			return errMissing
+		}
+		delete(atts, filename)
+		return nil
+	})
+}
+
+func (d *db) GetAttachment(_ context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	doc, ok := data.docs[docID]
+	if !ok {
+		return nil, errMissing
+	}
+	rev, err := doc.revision(driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return nil, err
+	}
+	att, ok := rev.attachments[filename]
+	if !ok {
+		return nil, errMissing
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: att.contentType,
+		Content:     io.NopCloser(bytes.NewReader(att.data)),
+		Size:        int64(len(att.data)),
+		Digest:      att.digest,
+		RevPos:      att.revpos,
+	}, nil
+}
@@ -0,0 +1,205 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestDocumentLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	rev1, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev1, "1-") {
+		t.Errorf("Unexpected rev: %s", rev1)
+	}
+	_, err = db.Put(ctx, "cow", map[string]interface{}{"name": "Daisy"})
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+
+	rev2, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": rev1, "name": "Daisy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev2, "2-") {
+		t.Errorf("Unexpected rev: %s", rev2)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"_id": "cow", "_rev": rev2, "name": "Daisy"}
+	if d := testy.DiffInterface(want, doc); d != nil {
+		t.Error(d)
+	}
+	if err := db.Get(ctx, "cow", kivik.Options{"rev": rev1}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "Bessie" {
+		t.Errorf("Unexpected old revision: %v", doc)
+	}
+
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "cow", kivik.Options{"rev": rev1}).Err()
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+
+	rev3, err := db.Delete(ctx, "cow", rev2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "cow").Err()
+	testutil.CheckError(t, "deleted", http.StatusNotFound, err)
+
+	rev4, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Clarabelle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev4 <= rev3 {
+		t.Errorf("Expected recreated doc to have a later rev than %s, got %s", rev3, rev4)
+	}
+
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DocCount != 1 || stats.UpdateSeq != "4" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCreateDoc(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	id, rev, err := db.CreateDoc(ctx, map[string]string{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 32 || rev == "" {
+		t.Errorf("Unexpected id/rev: %s/%s", id, rev)
+	}
+	_, err = db.Put(ctx, "cow", map[string]string{"_foo": "bar"})
+	testutil.CheckError(t, "bad special document member: _foo", http.StatusBadRequest, err)
+}
+
+func TestGetMeta(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	rev1, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev2, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": rev1, "name": "Daisy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := db.GetMeta(ctx, "cow", kivik.GetOptions{RevsInfo: true}.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &kivik.DocMeta{
+		ID:       "cow",
+		Rev:      rev2,
+		Size:     int64(len(`{"_id":"cow","_rev":"` + rev2 + `","name":"Daisy"}`)),
+		RevCount: 2,
+		RevsInfo: []kivik.RevInfo{
+			{Rev: rev2, Status: "available"},
+			{Rev: rev1, Status: "missing"},
+		},
+	}
+	if d := testy.DiffInterface(want, meta); d != nil {
+		t.Error(d)
+	}
+
+	rev3, err := db.Delete(ctx, "cow", rev2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err = db.GetMeta(ctx, "cow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Rev != rev3 || !meta.Deleted || meta.RevsInfo != nil {
+		t.Errorf("Unexpected tombstone meta: %+v", meta)
+	}
+
+	_, err = db.GetMeta(ctx, "cow", kivik.Options{"rev": rev1})
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+	_, err = db.GetMeta(ctx, "horse")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.Put(ctx, "cow", map[string]string{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev, err = db.PutAttachment(ctx, "cow", &kivik.Attachment{
+		Filename:    "moo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("moo")),
+	}, kivik.Options{"rev": rev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := db.GetAttachment(ctx, "cow", "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	if string(content) != "moo" || att.ContentType != "text/plain" || att.RevPos != 2 {
+		t.Errorf("Unexpected attachment: %+v (%s)", att, content)
+	}
+
+	// Attachment stubs survive an update
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["name"] = "Daisy"
+	if rev, err = db.Put(ctx, "cow", doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAttachment(ctx, "cow", "moo.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.DeleteAttachment(ctx, "cow", rev, "oink.txt")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+	if _, err = db.DeleteAttachment(ctx, "cow", rev, "moo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "cow", "moo.txt")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+}
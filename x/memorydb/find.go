@@ -0,0 +1,212 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+var (
+	_ driver.Finder  = &db{}
+	_ driver.Counter = &db{}
+)
+
+// allDocsIndex is the special index reported for every database.
+var allDocsIndex = driver.Index{
+	Name: "_all_docs",
+	Type: "special",
+	Definition: map[string]interface{}{
+		"fields": []interface{}{map[string]interface{}{"_id": "asc"}},
+	},
+}
+
+// Find evaluates query against every non-design document in the database.
+// Indexes are recorded, but never used.
+func (d *db) Find(_ context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+	q, err := mango.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	docs := data.liveDocs(func(docID string) bool {
+		return !isLocal(docID) && !strings.HasPrefix(docID, "_design/")
+	})
+	sort.Slice(docs, func(i, j int) bool { return docs[i].id < docs[j].id })
+	bodies := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		var body map[string]interface{}
+		_ = json.Unmarshal(doc.leaf().toJSON(doc.id, false), &body)
+		bodies[i] = body
+	}
+	data.mu.RUnlock()
+
+	results, err := q.Apply(bodies)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*driver.Row, len(results))
+	for i, result := range results {
+		doc, _ := json.Marshal(result)
+		id, _ := result["_id"].(string)
+		rows[i] = &driver.Row{
+			ID:  id,
+			Doc: bytes.NewReader(doc),
+		}
+	}
+	return driverutil.NewRows(rows, 0, 0, ""), nil
+}
+
+// Count returns the number of non-design documents matching selector.
+func (d *db) Count(_ context.Context, selector interface{}, _ map[string]interface{}) (int64, error) {
+	var sel map[string]interface{}
+	if err := driverutil.ConvertOption(selector, &sel); err != nil {
+		return 0, err
+	}
+	if sel == nil {
+		return 0, &kivik.Error{Status: http.StatusBadRequest, Message: "selector must be a JSON object"}
+	}
+	data, err := d.database()
+	if err != nil {
+		return 0, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	docs := data.liveDocs(func(docID string) bool {
+		return !isLocal(docID) && !strings.HasPrefix(docID, "_design/")
+	})
+	var count int64
+	for _, doc := range docs {
+		var body map[string]interface{}
+		_ = json.Unmarshal(doc.leaf().toJSON(doc.id, false), &body)
+		ok, err := mango.Match(sel, body)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *db) CreateIndex(_ context.Context, ddoc, name string, index interface{}, _ map[string]interface{}) error {
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if _, ok := def["fields"].([]interface{}); !ok {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "index definition must contain fields"}
+	}
+	if name == "" {
+		name = driverutil.NewDocID()
+	}
+	if ddoc == "" {
+		ddoc = name
+	}
+	if !strings.HasPrefix(ddoc, "_design/") {
+		ddoc = "_design/" + ddoc
+	}
+	data, err := d.database()
+	if err != nil {
+		return err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	for _, idx := range data.indexes {
+		if idx.DesignDoc == ddoc && idx.Name == name {
+			return nil
+		}
+	}
+	data.indexes = append(data.indexes, driver.Index{
+		DesignDoc:  ddoc,
+		Name:       name,
+		Type:       "json",
+		Definition: def,
+	})
+	return nil
+}
+
+func (d *db) GetIndexes(context.Context, map[string]interface{}) ([]driver.Index, error) {
+	data, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+	return append([]driver.Index{allDocsIndex}, data.indexes...), nil
+}
+
+func (d *db) DeleteIndex(_ context.Context, ddoc, name string, _ map[string]interface{}) error {
+	if !strings.HasPrefix(ddoc, "_design/") {
+		ddoc = "_design/" + ddoc
+	}
+	data, err := d.database()
+	if err != nil {
+		return err
+	}
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	for i, idx := range data.indexes {
+		if idx.DesignDoc == ddoc && idx.Name == name {
+			data.indexes = append(data.indexes[:i], data.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return &kivik.Error{Status: http.StatusNotFound, Message: "index not found"}
+}
+
+func (d *db) Explain(_ context.Context, query interface{}, _ map[string]interface{}) (*driver.QueryPlan, error) {
+	q, err := mango.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.database(); err != nil {
+		return nil, err
+	}
+	fields := make([]interface{}, len(q.Fields))
+	for i, f := range q.Fields {
+		fields[i] = f
+	}
+	return &driver.QueryPlan{
+		DBName: d.name,
+		Index: map[string]interface{}{
+			"ddoc": nil,
+			"name": allDocsIndex.Name,
+			"type": allDocsIndex.Type,
+			"def":  allDocsIndex.Definition,
+		},
+		Selector: q.Selector,
+		Options:  map[string]interface{}{},
+		Limit:    q.Limit,
+		Skip:     q.Skip,
+		Fields:   fields,
+	}, nil
+}
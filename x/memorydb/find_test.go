@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	docs := map[string]map[string]interface{}{
+		"bessie":      {"type": "cow", "age": 7},
+		"daisy":       {"type": "cow", "age": 3},
+		"wilbur":      {"type": "pig", "age": 1},
+		"_design/foo": {"type": "cow"},
+	}
+	for id, doc := range docs {
+		if _, err := db.Put(ctx, id, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := db.Find(ctx, `{"selector":{"type":"cow"},"sort":["age"],"fields":["_id","age"]}`)
+	var got []map[string]interface{}
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, doc)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"_id": "daisy", "age": float64(3)},
+		{"_id": "bessie", "age": float64(7)},
+	}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+
+	err := db.Find(ctx, `{"selector":{"age":{"$bogus":1}}}`).Err()
+	testutil.CheckError(t, "invalid operator $bogus", http.StatusBadRequest, err)
+
+	count, err := db.Count(ctx, `{"type":"cow"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Unexpected count: %d", count)
+	}
+	_, err = db.Count(ctx, map[string]interface{}{"age": map[string]interface{}{"$bogus": 1}})
+	testutil.CheckError(t, "invalid operator $bogus", http.StatusBadRequest, err)
+}
+
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.CreateIndex(ctx, "ddoc", "by-age", map[string]interface{}{"fields": []string{"age"}}); err != nil {
+		t.Fatal(err)
+	}
+	indexes, err := db.GetIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 2 || indexes[1].DesignDoc != "_design/ddoc" || indexes[1].Name != "by-age" {
+		t.Errorf("Unexpected indexes: %+v", indexes)
+	}
+	if err := db.DeleteIndex(ctx, "ddoc", "by-age"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteIndex(ctx, "ddoc", "by-age")
+	testutil.CheckError(t, "index not found", http.StatusNotFound, err)
+}
@@ -0,0 +1,136 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package memorydb provides an in-memory Kivik driver, intended for unit tests
+// and ephemeral data.
+//
+// The driver is registered under the name "memory". Each call to [kivik.New]
+// returns a new, empty server; the data source name is ignored:
+//
+//	import (
+//	    kivik "github.com/go-kivik/kivik/v4"
+//	    _ "github.com/go-kivik/kivik/v4/x/memorydb"
+//	)
+//
+//	client, err := kivik.New("memory", "")
+//
+// Documents, revisions, attachments, the _all_docs, _design_docs and
+// _local_docs views, the changes feed, with the built-in _doc_ids, _selector
+// and _design filters, and Mango queries are supported. Only a
+// single, linear revision history is kept for each document, so conflicts are
+// never created; an update with a stale revision fails with a 409 Conflict
+// error. Views and continuous changes feeds are not supported.
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+func init() {
+	kivik.Register("memory", &memDriver{})
+}
+
+type memDriver struct{}
+
+var _ driver.Driver = &memDriver{}
+
+func (d *memDriver) NewClient(string, map[string]interface{}) (driver.Client, error) {
+	return &client{
+		dbs: make(map[string]*database),
+	}, nil
+}
+
+type client struct {
+	mu  sync.RWMutex
+	dbs map[string]*database
+}
+
+var _ driver.Client = &client{}
+
+// Version is the version reported by the memory driver.
+const Version = kivik.KivikVersion
+
+// Vendor is the vendor string reported by the memory driver.
+const Vendor = "Kivik Memory Adaptor"
+
+func (c *client) Version(context.Context) (*driver.Version, error) {
+	return &driver.Version{
+		Version: Version,
+		Vendor:  Vendor,
+	}, nil
+}
+
+func (c *client) AllDBs(context.Context, map[string]interface{}) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	dbs := make([]string, 0, len(c.dbs))
+	for name := range c.dbs {
+		dbs = append(dbs, name)
+	}
+	sort.Strings(dbs)
+	return dbs, nil
+}
+
+func (c *client) DBExists(_ context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.dbs[dbName]
+	return ok, nil
+}
+
+func (c *client) CreateDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	if !driverutil.ValidDBName(dbName) {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "illegal database name"}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.dbs[dbName]; ok {
+		return &kivik.Error{Status: http.StatusPreconditionFailed, Message: "database exists"}
+	}
+	c.dbs[dbName] = newDatabase()
+	return nil
+}
+
+func (c *client) DestroyDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.dbs[dbName]; !ok {
+		return errDatabaseNotFound
+	}
+	delete(c.dbs, dbName)
+	return nil
+}
+
+func (c *client) DB(dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{
+		client: c,
+		name:   dbName,
+	}, nil
+}
+
+// database returns the named database, or an error if it does not exist.
+func (c *client) database(name string) (*database, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.dbs[name]
+	if !ok {
+		return nil, errDatabaseNotFound
+	}
+	return d, nil
+}
@@ -0,0 +1,88 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+// newDB returns a new, empty database on a new in-memory server.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "animals"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("animals")
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := client.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Vendor != Vendor {
+		t.Errorf("Unexpected vendor: %s", version.Vendor)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = client.CreateDB(ctx, "foo")
+	testutil.CheckError(t, "database exists", http.StatusPreconditionFailed, err)
+	err = client.CreateDB(ctx, "Foo")
+	testutil.CheckError(t, "illegal database name", http.StatusBadRequest, err)
+
+	dbs, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bar", "foo"}, dbs); d != nil {
+		t.Error(d)
+	}
+	if err := client.DestroyDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := client.DBExists(ctx, "foo"); exists {
+		t.Error("foo should no longer exist")
+	}
+	err = client.DestroyDB(ctx, "foo")
+	testutil.CheckError(t, "database does not exist", http.StatusNotFound, err)
+
+	_, err = client.DB("foo").Stats(ctx)
+	testutil.CheckError(t, "database does not exist", http.StatusNotFound, err)
+
+	other, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbs, _ := other.AllDBs(ctx); len(dbs) != 0 {
+		t.Errorf("Expected a new client to be empty, got %v", dbs)
+	}
+}
@@ -0,0 +1,370 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package migrate runs versioned data migrations against a database.
+//
+// Migrations are registered in order, each with a unique, stable ID, and are
+// applied in that order. The IDs of applied migrations are recorded in a
+// state document, by default the local document "_local/kivik-migrations",
+// so that each migration is applied only once, and [Migrator.Run] may safely
+// be called every time an application starts:
+//
+//	m := migrate.New(db, migrate.Config{})
+//	m.Register(migrate.Migration{
+//	    ID:       "2024-01-rename-owner",
+//	    Selector: map[string]interface{}{"owner": map[string]interface{}{"$exists": true}},
+//	    Update: func(doc json.RawMessage) (interface{}, error) {
+//	        var m map[string]interface{}
+//	        if err := json.Unmarshal(doc, &m); err != nil {
+//	            return nil, err
+//	        }
+//	        m["owner_id"] = m["owner"]
+//	        delete(m, "owner")
+//	        return m, nil
+//	    },
+//	})
+//	results, err := m.Run(ctx)
+//
+// A document migration applies its Update function to every document matched
+// by its Mango selector, or returned by its view, and saves the changed
+// documents in batches with [kivik.DB.BulkDocs]. A migration which fails part
+// way is not recorded, and is applied again in full by the next run, so
+// Update functions should leave already migrated documents unchanged, by
+// returning nil.
+//
+// [Migrator.DryRun] reports which migrations are pending, and how many
+// documents each would change, without writing anything.
+//
+// Concurrent runs against the same database are detected when recording
+// state, but not prevented, so applications with several instances should
+// run migrations from only one of them.
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultStateDocID is the ID of the document in which applied migrations
+// are recorded, unless configured otherwise.
+const DefaultStateDocID = "_local/kivik-migrations"
+
+// DefaultBatchSize is the number of documents saved per request when
+// Config.BatchSize is unset.
+const DefaultBatchSize = 100
+
+// Config configures a [Migrator].
+type Config struct {
+	// StateDocID is the ID of the document in which applied migrations are
+	// recorded. A regular document ID may be used for the state to be
+	// replicated along with the data.
+	StateDocID string
+	// BatchSize is the maximum number of documents saved per request.
+	BatchSize int
+}
+
+// Migration is a single, versioned migration. Exactly one of Selector, View
+// or Func must be set.
+type Migration struct {
+	// ID identifies the migration, and must be unique and stable.
+	ID string
+	// Description is an optional, human-readable description.
+	Description string
+
+	// Selector is a Mango selector matching the documents to migrate.
+	Selector interface{}
+	// View is the name of a map-only view, in the form "ddoc/view", whose
+	// rows' documents are migrated. Each document is migrated at most once, even if
+	// it is emitted several times.
+	View string
+	// Update is called with each document matched by Selector or View, as
+	// for [kivik.DB.UpdateBySelector]. It returns the new version of the
+	// document, or nil to leave it unchanged. Any _id or _rev field in the
+	// new version is ignored.
+	Update kivik.UpdateFunc
+
+	// Func, for migrations which are not applied document by document, such
+	// as creating indexes, is called with the database. dryRun is true when
+	// called by [Migrator.DryRun], in which case Func must not modify
+	// anything.
+	Func func(ctx context.Context, db *kivik.DB, dryRun bool) error
+}
+
+// Result reports the outcome of a single migration.
+type Result struct {
+	// ID is the ID of the migration.
+	ID string
+	// Skipped is true if the migration had already been applied.
+	Skipped bool
+	// Changed is the number of documents changed, or which would be changed,
+	// for a dry run. It is always 0 for migrations with Func set.
+	Changed int
+}
+
+// state is the document in which applied migrations are recorded.
+type state struct {
+	Rev     string    `json:"_rev,omitempty"`
+	Applied []applied `json:"applied"`
+}
+
+type applied struct {
+	ID        string    `json:"id"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Migrator applies registered migrations to a database.
+type Migrator struct {
+	db         *kivik.DB
+	config     Config
+	migrations []Migration
+}
+
+// New returns a migrator for db.
+func New(db *kivik.DB, config Config) *Migrator {
+	if config.StateDocID == "" {
+		config.StateDocID = DefaultStateDocID
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	return &Migrator{db: db, config: config}
+}
+
+// Register adds migrations, to be applied after those already registered.
+// Invalid migrations are reported by [Migrator.Run].
+func (m *Migrator) Register(migrations ...Migration) {
+	m.migrations = append(m.migrations, migrations...)
+}
+
+func (m *Migrator) validate() error {
+	seen := make(map[string]bool, len(m.migrations))
+	for i, mig := range m.migrations {
+		if mig.ID == "" {
+			return badRequest(fmt.Sprintf("migration %d has no ID", i))
+		}
+		if seen[mig.ID] {
+			return badRequest("duplicate migration ID " + mig.ID)
+		}
+		seen[mig.ID] = true
+		kinds := 0
+		if mig.Selector != nil {
+			kinds++
+		}
+		if mig.View != "" {
+			kinds++
+		}
+		if mig.Func != nil {
+			kinds++
+		}
+		if kinds != 1 {
+			return badRequest("migration " + mig.ID + " must have exactly one of Selector, View or Func")
+		}
+		if mig.Func == nil && mig.Update == nil {
+			return badRequest("migration " + mig.ID + " has no Update function")
+		}
+		if mig.View != "" && !strings.Contains(strings.TrimPrefix(mig.View, "_design/"), "/") {
+			return badRequest("migration " + mig.ID + " has an invalid view name " + mig.View)
+		}
+	}
+	return nil
+}
+
+func badRequest(msg string) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: " + msg}
+}
+
+func (m *Migrator) state(ctx context.Context) (*state, error) {
+	s := &state{}
+	err := m.db.Get(ctx, m.config.StateDocID).ScanDoc(s)
+	if kivik.HTTPStatus(err) == http.StatusNotFound {
+		return s, nil
+	}
+	return s, err
+}
+
+// Applied returns the IDs of the migrations which have been applied, in the
+// order in which they were applied.
+func (m *Migrator) Applied(ctx context.Context) ([]string, error) {
+	s, err := m.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(s.Applied))
+	for i, a := range s.Applied {
+		ids[i] = a.ID
+	}
+	return ids, nil
+}
+
+// Run applies each registered migration which has not yet been applied, in
+// order, recording each once it succeeds. It stops at the first failure,
+// returning the results so far along with the error. If a batch of
+// documents could not all be saved, the error is a [*kivik.BulkError].
+func (m *Migrator) Run(ctx context.Context) ([]Result, error) {
+	return m.run(ctx, false)
+}
+
+// DryRun reports the migrations which [Migrator.Run] would apply, and the
+// number of documents each would change, without modifying the database.
+// As earlier migrations are not applied, the counts of later migrations
+// which depend on them may differ from those of a real run.
+func (m *Migrator) DryRun(ctx context.Context) ([]Result, error) {
+	return m.run(ctx, true)
+}
+
+func (m *Migrator) run(ctx context.Context, dryRun bool) ([]Result, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	s, err := m.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(s.Applied))
+	for _, a := range s.Applied {
+		done[a.ID] = true
+	}
+	results := make([]Result, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		if done[mig.ID] {
+			results = append(results, Result{ID: mig.ID, Skipped: true})
+			continue
+		}
+		result := Result{ID: mig.ID}
+		if mig.Func != nil {
+			err = mig.Func(ctx, m.db, dryRun)
+		} else {
+			result.Changed, err = m.migrateDocs(ctx, mig, dryRun)
+		}
+		if err != nil {
+			return append(results, result), fmt.Errorf("migration %s: %w", mig.ID, err)
+		}
+		results = append(results, result)
+		if dryRun {
+			continue
+		}
+		s.Applied = append(s.Applied, applied{ID: mig.ID, AppliedAt: time.Now().UTC()})
+		rev, err := m.db.Put(ctx, m.config.StateDocID, s)
+		if err != nil {
+			return results, fmt.Errorf("migration %s: failed to record state: %w", mig.ID, err)
+		}
+		s.Rev = rev
+	}
+	return results, nil
+}
+
+// migrateDocs applies mig.Update to each matching document, returning the
+// number of documents changed.
+func (m *Migrator) migrateDocs(ctx context.Context, mig Migration, dryRun bool) (int, error) {
+	var rs kivik.ResultSet
+	if mig.View != "" {
+		ddoc, view := splitView(mig.View)
+		rs = m.db.Query(ctx, ddoc, view, kivik.IncludeDocs())
+	} else {
+		selector := mig.Selector
+		switch t := selector.(type) {
+		case string:
+			selector = json.RawMessage(t)
+		case []byte:
+			selector = json.RawMessage(t)
+		}
+		rs = m.db.Find(ctx, map[string]interface{}{"selector": selector, "limit": math.MaxInt32})
+	}
+	defer rs.Close() // nolint:errcheck
+
+	changed := 0
+	seen := map[string]bool{}
+	batch := make([]interface{}, 0, m.config.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		results, err := m.db.BulkDocs(ctx, batch)
+		if err != nil {
+			return err
+		}
+		batch = make([]interface{}, 0, m.config.BatchSize)
+		return kivik.NewBulkError(results)
+	}
+	for rs.Next() {
+		var current json.RawMessage
+		if err := rs.ScanDoc(&current); err != nil {
+			return changed, err
+		}
+		var meta struct {
+			ID  string `json:"_id"`
+			Rev string `json:"_rev"`
+		}
+		if err := json.Unmarshal(current, &meta); err != nil {
+			return changed, err
+		}
+		if seen[meta.ID] {
+			continue
+		}
+		seen[meta.ID] = true
+		updated, err := mig.Update(current)
+		if err != nil {
+			return changed, err
+		}
+		if updated == nil {
+			continue
+		}
+		changed++
+		if dryRun {
+			continue
+		}
+		doc, err := toMap(updated)
+		if err != nil {
+			return changed, err
+		}
+		doc["_id"], doc["_rev"] = meta.ID, meta.Rev
+		batch = append(batch, doc)
+		if len(batch) == m.config.BatchSize {
+			if err := flush(); err != nil {
+				return changed, err
+			}
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return changed, err
+	}
+	return changed, flush()
+}
+
+func splitView(name string) (ddoc, view string) {
+	name = strings.TrimPrefix(name, "_design/")
+	i := strings.Index(name, "/")
+	return name[:i], name[i+1:]
+}
+
+// toMap converts doc to a map, via JSON.
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil || m == nil {
+		return nil, badRequest("document must be a JSON object")
+	}
+	return m, nil
+}
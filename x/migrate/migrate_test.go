@@ -0,0 +1,238 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+	"github.com/go-kivik/kivik/v4/x/views"
+)
+
+// allDocs is the view all/docs, which emits every document.
+var allDocs = views.DesignDoc{
+	ID: "_design/all",
+	Views: map[string]views.View{
+		"docs": {
+			Map: func(doc map[string]interface{}, emit views.Emitter) {
+				emit(doc["_id"], nil)
+			},
+		},
+	},
+}
+
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.NewClientFromDriverClient(views.NewClient(proxydb.NewClient(memory), allDocs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	for id, doc := range map[string]interface{}{
+		"cow":   map[string]interface{}{"type": "mammal", "owner": "bob"},
+		"pig":   map[string]interface{}{"type": "mammal", "owner": "alice"},
+		"chick": map[string]interface{}{"type": "bird"},
+	} {
+		if _, err := db.Put(ctx, id, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// renameOwner renames the owner field to owner_id.
+func renameOwner(doc json.RawMessage) (interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, err
+	}
+	owner, ok := m["owner"]
+	if !ok {
+		return nil, nil
+	}
+	m["owner_id"] = owner
+	delete(m, "owner")
+	return m, nil
+}
+
+// addVersion sets the schema version of every document.
+func addVersion(doc json.RawMessage) (interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, err
+	}
+	if m["version"] != nil {
+		return nil, nil
+	}
+	m["version"] = 2
+	return m, nil
+}
+
+func migrations(calls *int) []Migration {
+	return []Migration{
+		{
+			ID:       "rename-owner",
+			Selector: `{"type":"mammal"}`,
+			Update:   renameOwner,
+		},
+		{
+			ID:     "add-version",
+			View:   "_design/all/docs",
+			Update: addVersion,
+		},
+		{
+			ID: "create-index",
+			Func: func(_ context.Context, _ *kivik.DB, dryRun bool) error {
+				if !dryRun {
+					*calls++
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	var calls int
+	m := New(db, Config{BatchSize: 1})
+	m.Register(migrations(&calls)...)
+
+	results, err := m.DryRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{ID: "rename-owner", Changed: 2},
+		{ID: "add-version", Changed: 3},
+		{ID: "create-index"},
+	}
+	if d := testy.DiffInterface(want, results); d != nil {
+		t.Errorf("Unexpected dry run results:\n%s", d)
+	}
+	if applied, _ := m.Applied(ctx); len(applied) != 0 {
+		t.Errorf("Dry run applied migrations: %v", applied)
+	}
+	if calls != 0 {
+		t.Errorf("Dry run modified the database")
+	}
+
+	results, err = m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(want, results); d != nil {
+		t.Errorf("Unexpected results:\n%s", d)
+	}
+	var cow map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&cow); err != nil {
+		t.Fatal(err)
+	}
+	if cow["owner_id"] != "bob" || cow["owner"] != nil || cow["version"] != float64(2) {
+		t.Errorf("Unexpected document: %v", cow)
+	}
+
+	// A second run is a no-op, and later migrations are applied.
+	m = New(db, Config{})
+	m.Register(migrations(&calls)...)
+	m.Register(Migration{ID: "later", Selector: map[string]interface{}{"type": "bird"}, Update: addVersion})
+	results, err = m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Result{
+		{ID: "rename-owner", Skipped: true},
+		{ID: "add-version", Skipped: true},
+		{ID: "create-index", Skipped: true},
+		{ID: "later"},
+	}
+	if d := testy.DiffInterface(want, results); d != nil {
+		t.Errorf("Unexpected results of second run:\n%s", d)
+	}
+	if calls != 1 {
+		t.Errorf("Expected Func to be called once, got %d", calls)
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"rename-owner", "add-version", "create-index", "later"}, applied); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m := New(db, Config{})
+	m.Register(
+		Migration{ID: "ok", Selector: `{"type":"bird"}`, Update: addVersion},
+		Migration{ID: "fails", Func: func(context.Context, *kivik.DB, bool) error {
+			return errors.New("boom")
+		}},
+	)
+	results, err := m.Run(ctx)
+	if err == nil || err.Error() != "migration fails: boom" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Unexpected results: %v", results)
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"ok"}, applied); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	update := func(json.RawMessage) (interface{}, error) { return nil, nil }
+	tests := []struct {
+		name       string
+		migrations []Migration
+		err        string
+	}{
+		{name: "no ID", migrations: []Migration{{Selector: `{}`, Update: update}}, err: "kivik: migration 0 has no ID"},
+		{name: "duplicate", migrations: []Migration{{ID: "a", Selector: `{}`, Update: update}, {ID: "a", Selector: `{}`, Update: update}}, err: "kivik: duplicate migration ID a"},
+		{name: "none", migrations: []Migration{{ID: "a"}}, err: "kivik: migration a must have exactly one of Selector, View or Func"},
+		{name: "no update", migrations: []Migration{{ID: "a", Selector: `{}`}}, err: "kivik: migration a has no Update function"},
+		{name: "bad view", migrations: []Migration{{ID: "a", View: "foo", Update: update}}, err: "kivik: migration a has an invalid view name foo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := New(nil, Config{})
+			m.Register(test.migrations...)
+			_, err := m.Run(context.Background())
+			testy.StatusError(t, test.err, http.StatusBadRequest, err)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package nextdriver
+
+import (
+	"context"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// adapter is implemented by the adapters in this package, which report the
+// features of the value they adapt.
+type adapter interface {
+	adapted() interface{}
+}
+
+// mapOptions returns options as a map for a current driver, or nil if there
+// are no options.
+func mapOptions(options Options) map[string]interface{} {
+	if options == nil {
+		return nil
+	}
+	return Map(options)
+}
+
+// typedOptions returns the options passed by the current API as Options.
+func typedOptions(options map[string]interface{}) Options {
+	if options == nil {
+		return nil
+	}
+	return kivik.Options(options)
+}
+
+// FromDriverClient adapts a client of the current driver API to this API.
+func FromDriverClient(client driver.Client) Client {
+	if c, ok := client.(*toClient); ok {
+		return c.client
+	}
+	return &fromClient{client: client}
+}
+
+type fromClient struct {
+	client driver.Client
+}
+
+var _ Client = &fromClient{}
+
+func (c *fromClient) adapted() interface{} { return c.client }
+
+func (c *fromClient) Version(ctx context.Context) (*driver.Version, error) {
+	return c.client.Version(ctx)
+}
+
+func (c *fromClient) AllDBs(ctx context.Context, options Options) ([]string, error) {
+	return c.client.AllDBs(ctx, mapOptions(options))
+}
+
+func (c *fromClient) DBExists(ctx context.Context, dbName string, options Options) (bool, error) {
+	return c.client.DBExists(ctx, dbName, mapOptions(options))
+}
+
+func (c *fromClient) CreateDB(ctx context.Context, dbName string, options Options) error {
+	return c.client.CreateDB(ctx, dbName, mapOptions(options))
+}
+
+func (c *fromClient) DestroyDB(ctx context.Context, dbName string, options Options) error {
+	return c.client.DestroyDB(ctx, dbName, mapOptions(options))
+}
+
+func (c *fromClient) DB(dbName string, options Options) (DB, error) {
+	db, err := c.client.DB(dbName, mapOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	return FromDriver(db), nil
+}
+
+// ToDriverClient adapts a client written for this API to the current driver
+// API. Only the methods of [driver.Client] and [driver.DB] are exposed; the
+// optional interfaces of the driver package are not.
+func ToDriverClient(client Client) driver.Client {
+	if c, ok := client.(*fromClient); ok {
+		return c.client
+	}
+	return &toClient{client: client}
+}
+
+type toClient struct {
+	client Client
+}
+
+var _ driver.Client = &toClient{}
+
+func (c *toClient) adapted() interface{} { return c.client }
+
+func (c *toClient) Version(ctx context.Context) (*driver.Version, error) {
+	return c.client.Version(ctx)
+}
+
+func (c *toClient) AllDBs(ctx context.Context, options map[string]interface{}) ([]string, error) {
+	return c.client.AllDBs(ctx, typedOptions(options))
+}
+
+func (c *toClient) DBExists(ctx context.Context, dbName string, options map[string]interface{}) (bool, error) {
+	return c.client.DBExists(ctx, dbName, typedOptions(options))
+}
+
+func (c *toClient) CreateDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return c.client.CreateDB(ctx, dbName, typedOptions(options))
+}
+
+func (c *toClient) DestroyDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return c.client.DestroyDB(ctx, dbName, typedOptions(options))
+}
+
+func (c *toClient) DB(dbName string, options map[string]interface{}) (driver.DB, error) {
+	db, err := c.client.DB(dbName, typedOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	return ToDriver(db), nil
+}
+
+// FromDriver adapts a database of the current driver API to this API.
+func FromDriver(db driver.DB) DB {
+	if d, ok := db.(*toDB); ok {
+		return d.db
+	}
+	return &fromDB{db: db}
+}
+
+type fromDB struct {
+	db driver.DB
+}
+
+var _ DB = &fromDB{}
+
+func (d *fromDB) adapted() interface{} { return d.db }
+
+func (d *fromDB) AllDocs(ctx context.Context, options Options) (driver.Rows, error) {
+	return d.db.AllDocs(ctx, mapOptions(options))
+}
+
+func (d *fromDB) Get(ctx context.Context, docID string, options Options) (*driver.Document, error) {
+	return d.db.Get(ctx, docID, mapOptions(options))
+}
+
+func (d *fromDB) CreateDoc(ctx context.Context, doc interface{}, options Options) (string, string, error) {
+	return d.db.CreateDoc(ctx, doc, mapOptions(options))
+}
+
+func (d *fromDB) Put(ctx context.Context, docID string, doc interface{}, options Options) (string, error) {
+	return d.db.Put(ctx, docID, doc, mapOptions(options))
+}
+
+func (d *fromDB) Delete(ctx context.Context, docID string, options Options) (string, error) {
+	return d.db.Delete(ctx, docID, mapOptions(options))
+}
+
+func (d *fromDB) Stats(ctx context.Context) (*driver.DBStats, error) {
+	return d.db.Stats(ctx)
+}
+
+func (d *fromDB) Compact(ctx context.Context) error {
+	return d.db.Compact(ctx)
+}
+
+func (d *fromDB) CompactView(ctx context.Context, ddocID string) error {
+	return d.db.CompactView(ctx, ddocID)
+}
+
+func (d *fromDB) ViewCleanup(ctx context.Context) error {
+	return d.db.ViewCleanup(ctx)
+}
+
+func (d *fromDB) Security(ctx context.Context) (*driver.Security, error) {
+	return d.db.Security(ctx)
+}
+
+func (d *fromDB) SetSecurity(ctx context.Context, security *driver.Security) error {
+	return d.db.SetSecurity(ctx, security)
+}
+
+func (d *fromDB) Changes(ctx context.Context, options Options) (driver.Changes, error) {
+	return d.db.Changes(ctx, mapOptions(options))
+}
+
+func (d *fromDB) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options Options) (string, error) {
+	return d.db.PutAttachment(ctx, docID, att, mapOptions(options))
+}
+
+func (d *fromDB) GetAttachment(ctx context.Context, docID, filename string, options Options) (*driver.Attachment, error) {
+	return d.db.GetAttachment(ctx, docID, filename, mapOptions(options))
+}
+
+func (d *fromDB) DeleteAttachment(ctx context.Context, docID, filename string, options Options) (string, error) {
+	return d.db.DeleteAttachment(ctx, docID, filename, mapOptions(options))
+}
+
+func (d *fromDB) Query(ctx context.Context, ddoc, view string, options Options) (driver.Rows, error) {
+	return d.db.Query(ctx, ddoc, view, mapOptions(options))
+}
+
+// ToDriver adapts a database written for this API to the current driver API.
+// Only the methods of [driver.DB] are exposed; the optional interfaces of the
+// driver package are not.
+func ToDriver(db DB) driver.DB {
+	if d, ok := db.(*fromDB); ok {
+		return d.db
+	}
+	return &toDB{db: db}
+}
+
+type toDB struct {
+	db DB
+}
+
+var _ driver.DB = &toDB{}
+
+func (d *toDB) adapted() interface{} { return d.db }
+
+func (d *toDB) AllDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.db.AllDocs(ctx, typedOptions(options))
+}
+
+func (d *toDB) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	return d.db.Get(ctx, docID, typedOptions(options))
+}
+
+func (d *toDB) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	return d.db.CreateDoc(ctx, doc, typedOptions(options))
+}
+
+func (d *toDB) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	return d.db.Put(ctx, docID, doc, typedOptions(options))
+}
+
+func (d *toDB) Delete(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	return d.db.Delete(ctx, docID, typedOptions(options))
+}
+
+func (d *toDB) Stats(ctx context.Context) (*driver.DBStats, error) {
+	return d.db.Stats(ctx)
+}
+
+func (d *toDB) Compact(ctx context.Context) error {
+	return d.db.Compact(ctx)
+}
+
+func (d *toDB) CompactView(ctx context.Context, ddocID string) error {
+	return d.db.CompactView(ctx, ddocID)
+}
+
+func (d *toDB) ViewCleanup(ctx context.Context) error {
+	return d.db.ViewCleanup(ctx)
+}
+
+func (d *toDB) Security(ctx context.Context) (*driver.Security, error) {
+	return d.db.Security(ctx)
+}
+
+func (d *toDB) SetSecurity(ctx context.Context, security *driver.Security) error {
+	return d.db.SetSecurity(ctx, security)
+}
+
+func (d *toDB) Changes(ctx context.Context, options map[string]interface{}) (driver.Changes, error) {
+	return d.db.Changes(ctx, typedOptions(options))
+}
+
+func (d *toDB) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	return d.db.PutAttachment(ctx, docID, att, typedOptions(options))
+}
+
+func (d *toDB) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	return d.db.GetAttachment(ctx, docID, filename, typedOptions(options))
+}
+
+func (d *toDB) DeleteAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	return d.db.DeleteAttachment(ctx, docID, filename, typedOptions(options))
+}
+
+func (d *toDB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	return d.db.Query(ctx, ddoc, view, typedOptions(options))
+}
@@ -0,0 +1,190 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package nextdriver is a proposal for the next version of the Kivik driver
+// API, in which options are passed to drivers as typed values, rather than as
+// map[string]interface{}.
+//
+// In the current API, options are passed from the caller to the driver as
+// maps, with values of whichever type the caller chose, so that a misspelled
+// key, or a value of a type the driver does not expect, is silently ignored.
+// In this API, options implement [Options], whose Apply method writes them
+// into the form the driver needs: a map, for drivers which evaluate options
+// themselves, or query parameters, encoded as CouchDB expects them, for
+// drivers which forward them to a server. [kivik.Options] and the typed
+// options of the kivik package, such as [kivik.GetOptions], implement
+// Options.
+//
+// As in the current API, rows carry their values and documents as
+// [io.Reader]s, in [driver.Row], so that drivers need not decode them.
+// Optional features are implemented as the optional interfaces of the driver
+// package, which [Features] lists by name, so that they may be discovered
+// without a type assertion for each.
+//
+// Existing drivers keep working through adapters: [FromDriver] and
+// [FromDriverClient] adapt a current driver to this API, and [ToDriver] and
+// [ToDriverClient] adapt a driver written for this API back to the current
+// one, so that it may be used with [kivik.NewClientFromDriverClient].
+package nextdriver
+
+import (
+	"context"
+	"net/url"
+	"sort"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Options are typed options passed to a driver.
+type Options interface {
+	// Apply sets the options on target, which is a map[string]interface{}
+	// or a *[net/url.Values]. Options which do not apply to the target are
+	// ignored.
+	Apply(target interface{})
+}
+
+// Map returns options as a map. Nil options produce an empty map.
+func Map(options Options) map[string]interface{} {
+	m := map[string]interface{}{}
+	if options != nil {
+		options.Apply(m)
+	}
+	return m
+}
+
+// Query returns options as query parameters. Nil options produce empty
+// parameters.
+func Query(options Options) url.Values {
+	query := url.Values{}
+	if options != nil {
+		options.Apply(&query)
+	}
+	return query
+}
+
+// Client is a connection to a database server.
+type Client interface {
+	// Version returns the server implementation's details.
+	Version(ctx context.Context) (*driver.Version, error)
+	// AllDBs returns a list of all existing database names.
+	AllDBs(ctx context.Context, options Options) ([]string, error)
+	// DBExists returns true if the database exists.
+	DBExists(ctx context.Context, dbName string, options Options) (bool, error)
+	// CreateDB creates the requested database.
+	CreateDB(ctx context.Context, dbName string, options Options) error
+	// DestroyDB deletes the requested database.
+	DestroyDB(ctx context.Context, dbName string, options Options) error
+	// DB returns a handle to the requested database.
+	DB(dbName string, options Options) (DB, error)
+}
+
+// DB is a database handle.
+type DB interface {
+	// AllDocs returns all of the documents in the database.
+	AllDocs(ctx context.Context, options Options) (driver.Rows, error)
+	// Get fetches the requested document.
+	Get(ctx context.Context, docID string, options Options) (*driver.Document, error)
+	// CreateDoc creates a new document, with a server-generated ID.
+	CreateDoc(ctx context.Context, doc interface{}, options Options) (docID, rev string, err error)
+	// Put writes the document.
+	Put(ctx context.Context, docID string, doc interface{}, options Options) (rev string, err error)
+	// Delete marks the specified document as deleted.
+	Delete(ctx context.Context, docID string, options Options) (newRev string, err error)
+	// Stats returns database statistics.
+	Stats(ctx context.Context) (*driver.DBStats, error)
+	// Compact initiates compaction of the database.
+	Compact(ctx context.Context) error
+	// CompactView initiates compaction of the view.
+	CompactView(ctx context.Context, ddocID string) error
+	// ViewCleanup cleans up stale view files.
+	ViewCleanup(ctx context.Context) error
+	// Security returns the database's security document.
+	Security(ctx context.Context) (*driver.Security, error)
+	// SetSecurity sets the database's security document.
+	SetSecurity(ctx context.Context, security *driver.Security) error
+	// Changes returns an iterator for the changes feed.
+	Changes(ctx context.Context, options Options) (driver.Changes, error)
+	// PutAttachment uploads an attachment, returning the document's new
+	// revision.
+	PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options Options) (newRev string, err error)
+	// GetAttachment fetches an attachment.
+	GetAttachment(ctx context.Context, docID, filename string, options Options) (*driver.Attachment, error)
+	// DeleteAttachment deletes an attachment, returning the document's new
+	// revision.
+	DeleteAttachment(ctx context.Context, docID, filename string, options Options) (newRev string, err error)
+	// Query performs a query against a view. ddoc is the design document name
+	// without the "_design/" prefix.
+	Query(ctx context.Context, ddoc, view string, options Options) (driver.Rows, error)
+}
+
+// feature is an optional interface of the driver package.
+type feature struct {
+	name string
+	is   func(interface{}) bool
+}
+
+// features are the optional interfaces of the driver package, which may be
+// implemented by a client or a database.
+var features = []feature{
+	// Client features
+	{"ActiveTasker", func(v interface{}) bool { _, ok := v.(driver.ActiveTasker); return ok }},
+	{"Authenticator", func(v interface{}) bool { _, ok := v.(driver.Authenticator); return ok }},
+	{"ClientCloser", func(v interface{}) bool { _, ok := v.(driver.ClientCloser); return ok }},
+	{"ClientReplicator", func(v interface{}) bool { _, ok := v.(driver.ClientReplicator); return ok }},
+	{"Cluster", func(v interface{}) bool { _, ok := v.(driver.Cluster); return ok }},
+	{"Configer", func(v interface{}) bool { _, ok := v.(driver.Configer); return ok }},
+	{"DBUpdater", func(v interface{}) bool { _, ok := v.(driver.DBUpdater); return ok }},
+	{"DBsStatser", func(v interface{}) bool { _, ok := v.(driver.DBsStatser); return ok }},
+	{"NodeInspector", func(v interface{}) bool { _, ok := v.(driver.NodeInspector); return ok }},
+	{"Pinger", func(v interface{}) bool { _, ok := v.(driver.Pinger); return ok }},
+	{"PoolStatser", func(v interface{}) bool { _, ok := v.(driver.PoolStatser); return ok }},
+	{"Sessioner", func(v interface{}) bool { _, ok := v.(driver.Sessioner); return ok }},
+	{"UUIDer", func(v interface{}) bool { _, ok := v.(driver.UUIDer); return ok }},
+	// DB features
+	{"AttachmentMetaGetter", func(v interface{}) bool { _, ok := v.(driver.AttachmentMetaGetter); return ok }},
+	{"BulkDocer", func(v interface{}) bool { _, ok := v.(driver.BulkDocer); return ok }},
+	{"BulkDocsStreamer", func(v interface{}) bool { _, ok := v.(driver.BulkDocsStreamer); return ok }},
+	{"BulkGetter", func(v interface{}) bool { _, ok := v.(driver.BulkGetter); return ok }},
+	{"Copier", func(v interface{}) bool { _, ok := v.(driver.Copier); return ok }},
+	{"Counter", func(v interface{}) bool { _, ok := v.(driver.Counter); return ok }},
+	{"DBCloser", func(v interface{}) bool { _, ok := v.(driver.DBCloser); return ok }},
+	{"DesignDocer", func(v interface{}) bool { _, ok := v.(driver.DesignDocer); return ok }},
+	{"Finder", func(v interface{}) bool { _, ok := v.(driver.Finder); return ok }},
+	{"Flusher", func(v interface{}) bool { _, ok := v.(driver.Flusher); return ok }},
+	{"LocalDocer", func(v interface{}) bool { _, ok := v.(driver.LocalDocer); return ok }},
+	{"MetaGetter", func(v interface{}) bool { _, ok := v.(driver.MetaGetter); return ok }},
+	{"PartitionedDB", func(v interface{}) bool { _, ok := v.(driver.PartitionedDB); return ok }},
+	{"Purger", func(v interface{}) bool { _, ok := v.(driver.Purger); return ok }},
+	{"RevGetter", func(v interface{}) bool { _, ok := v.(driver.RevGetter); return ok }},
+	{"RevsDiffer", func(v interface{}) bool { _, ok := v.(driver.RevsDiffer); return ok }},
+	{"Searcher", func(v interface{}) bool { _, ok := v.(driver.Searcher); return ok }},
+	{"Sharder", func(v interface{}) bool { _, ok := v.(driver.Sharder); return ok }},
+}
+
+// Features returns the names of the optional interfaces of the driver
+// package, such as "Finder" or "BulkDocer", implemented by v, which is a
+// client or database of either this API or the current one, in
+// alphabetical order. Databases and clients returned by the adapters in this
+// package report the features of the driver they adapt.
+func Features(v interface{}) []string {
+	if a, ok := v.(adapter); ok {
+		v = a.adapted()
+	}
+	var names []string
+	for _, f := range features {
+		if f.is(v) {
+			names = append(names, f.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
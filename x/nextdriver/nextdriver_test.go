@@ -0,0 +1,165 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package nextdriver
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+)
+
+func newDriverClient(t *testing.T) driver.Client {
+	t.Helper()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxydb.NewClient(memory)
+}
+
+func TestMapAndQuery(t *testing.T) {
+	if d := testy.DiffInterface(map[string]interface{}{}, Map(nil)); d != nil {
+		t.Error(d)
+	}
+	opts := kivik.QueryOptions{StartKey: "a", IncludeDocs: true}
+	if d := testy.DiffInterface(map[string]interface{}{"startkey": "a", "include_docs": true}, Map(opts)); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(url.Values{"startkey": {`"a"`}, "include_docs": {"true"}}, Query(opts)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestFromDriverClient(t *testing.T) {
+	ctx := context.Background()
+	client := FromDriverClient(newDriverClient(t))
+	if err := client.CreateDB(ctx, "animals", kivik.CreateDBOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := client.DB("animals", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"}, kivik.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := db.Get(ctx, "cow", kivik.GetOptions{Rev: rev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = doc.Body.Close()
+	if doc.Rev != rev {
+		t.Errorf("Unexpected rev: %s", doc.Rev)
+	}
+	rows, err := db.AllDocs(ctx, kivik.QueryOptions{IncludeDocs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close() // nolint:errcheck
+	var row driver.Row
+	if err := rows.Next(&row); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.NewDecoder(row.Doc).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "Bessie" {
+		t.Errorf("Unexpected doc: %v", got)
+	}
+
+	if c := ToDriverClient(client); c != client.(*fromClient).client {
+		t.Error("ToDriverClient should unwrap an adapted client")
+	}
+}
+
+// recordingDB is a DB written for this API, which records the query
+// parameters of each Get.
+type recordingDB struct {
+	DB
+	queries []string
+}
+
+func (d *recordingDB) Get(ctx context.Context, docID string, options Options) (*driver.Document, error) {
+	d.queries = append(d.queries, Query(options).Encode())
+	return d.DB.Get(ctx, docID, options)
+}
+
+type recordingClient struct {
+	Client
+	db *recordingDB
+}
+
+func (c *recordingClient) DB(dbName string, options Options) (DB, error) {
+	db, err := c.Client.DB(dbName, options)
+	if err != nil {
+		return nil, err
+	}
+	c.db.DB = db
+	return c.db, nil
+}
+
+func TestToDriverClient(t *testing.T) {
+	ctx := context.Background()
+	next := &recordingClient{Client: FromDriverClient(newDriverClient(t)), db: &recordingDB{}}
+	client, err := kivik.NewClientFromDriverClient(ToDriverClient(next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	rev, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Name string `json:"name"`
+	}
+	if err := db.Get(ctx, "cow", kivik.Rev(rev)).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "Bessie" {
+		t.Errorf("Unexpected doc: %+v", doc)
+	}
+	if d := testy.DiffInterface([]string{"rev=" + url.QueryEscape(rev)}, next.db.queries); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	ddb, err := newDriverClient(t).DB("animals", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Features(ddb)
+	if len(want) == 0 {
+		t.Fatal("expected the proxy driver to implement optional interfaces")
+	}
+	if d := testy.DiffInterface(want, Features(FromDriver(ddb))); d != nil {
+		t.Errorf("Adapted database:\n%s", d)
+	}
+	if got := Features(struct{}{}); got != nil {
+		t.Errorf("Unexpected features: %v", got)
+	}
+}
@@ -0,0 +1,250 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package outbox provides a write queue for databases with intermittent
+// connectivity. Writes made through a [Queue] are sent to the database
+// immediately when it is reachable, and otherwise persisted in a local
+// [Store], to be replayed later with [Queue.Replay]:
+//
+//	q := outbox.New(db, outbox.NewDBStore(localDB))
+//	rev, err := q.Put(ctx, "reading-123", reading)
+//	if errors.Is(err, outbox.ErrQueued) {
+//	    // Stored locally, to be sent later.
+//	}
+//	...
+//	result, err := q.Replay(ctx)
+//	for _, f := range result.Failed {
+//	    log.Printf("%s %s: %s", f.Entry.Op, f.Entry.DocID, f.Err)
+//	}
+//
+// Queued writes are replayed in the order in which they were made. Once any
+// write has been queued, later writes are queued behind it, even if the
+// database becomes reachable, so that writes are never reordered.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// ErrQueued is returned by [Queue.Put] and [Queue.Delete] when the write
+// was queued rather than applied.
+var ErrQueued = errors.New("kivik: write queued")
+
+// Queued operations.
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// Entry is a queued write.
+type Entry struct {
+	// Seq orders entries. It is assigned by the [Queue].
+	Seq int64 `json:"seq"`
+	// Op is [OpPut] or [OpDelete].
+	Op    string `json:"op"`
+	DocID string `json:"doc_id"`
+	// Rev is the revision to delete, for [OpDelete].
+	Rev string `json:"rev,omitempty"`
+	// Doc is the document to store, for [OpPut].
+	Doc json.RawMessage `json:"doc,omitempty"`
+	// Queued is the time at which the write was queued.
+	Queued time.Time `json:"queued"`
+}
+
+// Store persists queued writes. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Append stores e.
+	Append(ctx context.Context, e *Entry) error
+	// List returns all stored entries, ordered by Seq.
+	List(ctx context.Context) ([]*Entry, error)
+	// Remove removes the entry with the given Seq.
+	Remove(ctx context.Context, seq int64) error
+}
+
+// Queue sends writes to a database, queuing them in a [Store] while the
+// database is unreachable.
+type Queue struct {
+	db          *kivik.DB
+	store       Store
+	unreachable func(error) bool
+
+	// mu serializes writes, so that they are queued and replayed in order.
+	mu      sync.Mutex
+	lastSeq int64
+}
+
+// Option configures a [Queue].
+type Option func(*Queue)
+
+// WithUnreachable sets the function used to decide whether a failed write
+// should be queued. The default queues writes which fail with a network
+// error, or with a 502, 503 or 504 status.
+func WithUnreachable(fn func(error) bool) Option {
+	return func(q *Queue) {
+		q.unreachable = fn
+	}
+}
+
+// New returns a queue of writes to db, persisted in store.
+func New(db *kivik.DB, store Store, options ...Option) *Queue {
+	q := &Queue{
+		db:          db,
+		store:       store,
+		unreachable: unreachable,
+	}
+	for _, opt := range options {
+		opt(q)
+	}
+	return q
+}
+
+func unreachable(err error) bool {
+	switch kivik.HTTPStatus(err) {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Put stores doc under docID, as for [kivik.DB.Put]. If the database is
+// unreachable, or earlier writes are still queued, the write is queued
+// instead, and Put returns [ErrQueued].
+func (q *Queue) Put(ctx context.Context, docID string, doc interface{}) (rev string, err error) {
+	if docID == "" {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: docID required"}
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return q.write(ctx, &Entry{Op: OpPut, DocID: docID, Doc: raw})
+}
+
+// Delete marks the revision rev of docID as deleted, as for
+// [kivik.DB.Delete]. If the database is unreachable, or earlier writes are
+// still queued, the write is queued instead, and Delete returns [ErrQueued].
+func (q *Queue) Delete(ctx context.Context, docID, rev string) (newRev string, err error) {
+	if docID == "" {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: docID required"}
+	}
+	return q.write(ctx, &Entry{Op: OpDelete, DocID: docID, Rev: rev})
+}
+
+func (q *Queue) write(ctx context.Context, e *Entry) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending, err := q.store.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(pending) == 0 {
+		rev, err := q.apply(ctx, e)
+		if err == nil || !q.unreachable(err) {
+			return rev, err
+		}
+	} else if last := pending[len(pending)-1].Seq; last > q.lastSeq {
+		q.lastSeq = last
+	}
+	if err := q.enqueue(ctx, e); err != nil {
+		return "", err
+	}
+	return "", ErrQueued
+}
+
+func (q *Queue) enqueue(ctx context.Context, e *Entry) error {
+	now := time.Now()
+	e.Seq = now.UnixNano()
+	if e.Seq <= q.lastSeq {
+		e.Seq = q.lastSeq + 1
+	}
+	e.Queued = now
+	if err := q.store.Append(ctx, e); err != nil {
+		return err
+	}
+	q.lastSeq = e.Seq
+	return nil
+}
+
+func (q *Queue) apply(ctx context.Context, e *Entry) (string, error) {
+	switch e.Op {
+	case OpPut:
+		return q.db.Put(ctx, e.DocID, e.Doc)
+	case OpDelete:
+		return q.db.Delete(ctx, e.DocID, e.Rev)
+	}
+	return "", &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: unknown queued operation " + e.Op}
+}
+
+// Len returns the number of queued writes.
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	entries, err := q.store.List(ctx)
+	return len(entries), err
+}
+
+// Failure is a queued write which was rejected by the database when
+// replayed.
+type Failure struct {
+	Entry *Entry
+	// Err is the error returned by the database. Conflicts, which occur when
+	// the document was modified by someone else while the write was queued,
+	// have the status 409.
+	Err error
+}
+
+// ReplayResult reports the outcome of [Queue.Replay].
+type ReplayResult struct {
+	// Applied is the number of queued writes which succeeded.
+	Applied int
+	// Failed lists the queued writes which were rejected, including
+	// conflicts. Rejected writes are removed from the queue.
+	Failed []Failure
+}
+
+// Replay sends the queued writes to the database, in order. Writes which
+// succeed, or are rejected by the database, are removed from the queue.
+// Replay stops, leaving the remaining writes queued, if the database is
+// unreachable, in which case it returns the error along with the result so
+// far.
+func (q *Queue) Replay(ctx context.Context) (*ReplayResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries, err := q.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplayResult{}
+	for _, e := range entries {
+		_, err := q.apply(ctx, e)
+		if err != nil && (q.unreachable(err) || ctx.Err() != nil) {
+			return result, err
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, Failure{Entry: e, Err: err})
+		} else {
+			result.Applied++
+		}
+		if err := q.store.Remove(ctx, e.Seq); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,189 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+// newDB returns a new, empty in-memory database, and a function which
+// toggles whether it is reachable.
+func newDB(t *testing.T, name string) (*kivik.DB, func(bool)) {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), name); err != nil {
+		t.Fatal(err)
+	}
+	var down int32
+	client.Use(func(next kivik.Handler) kivik.Handler {
+		return func(ctx context.Context, op *kivik.Operation) error {
+			if atomic.LoadInt32(&down) == 1 {
+				return &kivik.Error{Status: http.StatusServiceUnavailable, Message: "unreachable"}
+			}
+			return next(ctx, op)
+		}
+	})
+	return client.DB(name), func(up bool) {
+		if up {
+			atomic.StoreInt32(&down, 0)
+		} else {
+			atomic.StoreInt32(&down, 1)
+		}
+	}
+}
+
+func queueLen(t *testing.T, q *Queue) int {
+	t.Helper()
+	n, err := q.Len(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("online", func(t *testing.T) {
+		db, _ := newDB(t, "remote")
+		q := New(db, NewMemoryStore())
+		rev, err := q.Put(ctx, "a", map[string]string{"foo": "bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Delete(ctx, "a", rev); err != nil {
+			t.Fatal(err)
+		}
+		if n := queueLen(t, q); n != 0 {
+			t.Errorf("Unexpected queue length: %d", n)
+		}
+	})
+	t.Run("permanent error is not queued", func(t *testing.T) {
+		db, _ := newDB(t, "remote")
+		q := New(db, NewMemoryStore())
+		_, err := q.Delete(ctx, "a", "1-xxx")
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+	t.Run("queue and replay", func(t *testing.T) {
+		db, setUp := newDB(t, "remote")
+		rev, err := db.Put(ctx, "b", map[string]string{"foo": "bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := New(db, NewMemoryStore())
+
+		setUp(false)
+		if _, err := q.Put(ctx, "a", map[string]string{"foo": "bar"}); !errors.Is(err, ErrQueued) {
+			t.Fatalf("Expected ErrQueued, got %v", err)
+		}
+		if _, err := q.Put(ctx, "c", map[string]string{"foo": "bar"}); !errors.Is(err, ErrQueued) {
+			t.Fatalf("Expected ErrQueued, got %v", err)
+		}
+		if _, err := q.Replay(ctx); kivik.HTTPStatus(err) != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected replay error: %v", err)
+		}
+
+		setUp(true)
+		// Queued behind the earlier writes, despite the database being
+		// reachable again.
+		if _, err := q.Delete(ctx, "b", rev); !errors.Is(err, ErrQueued) {
+			t.Fatalf("Expected ErrQueued, got %v", err)
+		}
+		// A conflicting write made by someone else in the meantime.
+		if _, err := db.Put(ctx, "c", map[string]string{"foo": "baz"}); err != nil {
+			t.Fatal(err)
+		}
+		if n := queueLen(t, q); n != 3 {
+			t.Fatalf("Unexpected queue length: %d", n)
+		}
+
+		result, err := q.Replay(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Applied != 2 {
+			t.Errorf("Unexpected applied count: %d", result.Applied)
+		}
+		if len(result.Failed) != 1 {
+			t.Fatalf("Unexpected failures: %v", result.Failed)
+		}
+		if f := result.Failed[0]; f.Entry.DocID != "c" || kivik.HTTPStatus(f.Err) != http.StatusConflict {
+			t.Errorf("Unexpected failure: %s %v", f.Entry.DocID, f.Err)
+		}
+		if n := queueLen(t, q); n != 0 {
+			t.Errorf("Unexpected queue length: %d", n)
+		}
+		if _, err := db.GetRev(ctx, "a"); err != nil {
+			t.Errorf("Expected a to be written: %s", err)
+		}
+		if _, err := db.GetRev(ctx, "b"); kivik.HTTPStatus(err) != http.StatusNotFound {
+			t.Errorf("Expected b to be deleted: %v", err)
+		}
+	})
+}
+
+func TestDBStore(t *testing.T) {
+	ctx := context.Background()
+	local, _ := newDB(t, "local")
+	if _, err := local.Put(ctx, "unrelated", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewDBStore(local)
+	for _, e := range []*Entry{
+		{Seq: 20, Op: OpDelete, DocID: "b", Rev: "1-xxx"},
+		{Seq: 3, Op: OpPut, DocID: "a", Doc: []byte(`{"foo":"bar"}`)},
+	} {
+		if err := s.Append(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Remove(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.DocID)
+	}
+	if d := testy.DiffInterface([]string{"a", "b"}, ids); d != nil {
+		t.Error(d)
+	}
+	if string(entries[0].Doc) != `{"foo":"bar"}` {
+		t.Errorf("Unexpected doc: %s", entries[0].Doc)
+	}
+	if err := s.Remove(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Seq != 20 {
+		t.Errorf("Unexpected entries after remove: %v", entries)
+	}
+}
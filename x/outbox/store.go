@@ -0,0 +1,135 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// MemoryStore is a [Store] which keeps entries in memory. It is mostly
+// useful for tests, as queued writes are lost when the process exits.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append stores e.
+func (s *MemoryStore) Append(_ context.Context, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	sort.SliceStable(s.entries, func(i, j int) bool { return s.entries[i].Seq < s.entries[j].Seq })
+	return nil
+}
+
+// List returns all stored entries, ordered by Seq.
+func (s *MemoryStore) List(context.Context) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Entry(nil), s.entries...), nil
+}
+
+// Remove removes the entry with the given Seq.
+func (s *MemoryStore) Remove(_ context.Context, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.Seq == seq {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// dbStorePrefix is the prefix of the IDs of documents stored by a DBStore.
+const dbStorePrefix = "outbox:"
+
+// DBStore is a [Store] which keeps each entry as a document in a local
+// database, such as one provided by the file system driver,
+// [github.com/go-kivik/kivik/v4/x/fsdb], or the SQLite driver.
+type DBStore struct {
+	db *kivik.DB
+}
+
+var _ Store = &DBStore{}
+
+// NewDBStore returns a store which keeps entries in db. The database may
+// hold other documents, as entries are stored with the ID prefix "outbox:".
+func NewDBStore(db *kivik.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func entryID(seq int64) string {
+	return fmt.Sprintf("%s%020d", dbStorePrefix, seq)
+}
+
+// Append stores e.
+func (s *DBStore) Append(ctx context.Context, e *Entry) error {
+	_, err := s.db.Put(ctx, entryID(e.Seq), e)
+	return err
+}
+
+// List returns all stored entries, ordered by Seq.
+func (s *DBStore) List(ctx context.Context) ([]*Entry, error) {
+	rows := s.db.AllDocs(ctx, kivik.IncludeDocs(), kivik.StartKey(dbStorePrefix), kivik.EndKey(dbStorePrefix+kivik.EndKeySuffix))
+	defer rows.Close() // nolint:errcheck
+	var entries []*Entry
+	for rows.Next() {
+		id, err := rows.ID()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(id, dbStorePrefix) {
+			continue
+		}
+		e := &Entry{}
+		if err := rows.ScanDoc(e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+// Remove removes the entry with the given Seq.
+func (s *DBStore) Remove(ctx context.Context, seq int64) error {
+	id := entryID(seq)
+	rev, err := s.db.GetRev(ctx, id)
+	if kivik.HTTPStatus(err) == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Delete(ctx, id, rev)
+	return err
+}
@@ -0,0 +1,284 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package proxydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+type db struct {
+	remote *kivik.DB
+}
+
+var (
+	_ driver.DB                   = &db{}
+	_ driver.Finder               = &db{}
+	_ driver.Counter              = &db{}
+	_ driver.DesignDocer          = &db{}
+	_ driver.LocalDocer           = &db{}
+	_ driver.RevGetter            = &db{}
+	_ driver.MetaGetter           = &db{}
+	_ driver.AttachmentMetaGetter = &db{}
+	_ driver.Copier               = &db{}
+	_ driver.BulkDocer            = &db{}
+	_ driver.BulkDocsStreamer     = &db{}
+	_ driver.Flusher              = &db{}
+	_ driver.Purger               = &db{}
+)
+
+func (d *db) AllDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return newRows(d.remote.AllDocs(ctx, options))
+}
+
+func (d *db) DesignDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return newRows(d.remote.DesignDocs(ctx, options))
+}
+
+func (d *db) LocalDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return newRows(d.remote.LocalDocs(ctx, options))
+}
+
+func (d *db) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	return newRows(d.remote.Query(ctx, ddoc, view, options))
+}
+
+func (d *db) Find(ctx context.Context, query interface{}, options map[string]interface{}) (driver.Rows, error) {
+	return newRows(d.remote.Find(ctx, query, options))
+}
+
+func (d *db) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	row := d.remote.Get(ctx, docID, options)
+	var body json.RawMessage
+	if err := row.ScanDoc(&body); err != nil {
+		return nil, err
+	}
+	rev, err := row.Rev()
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		Rev:  rev,
+		Body: io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (d *db) GetRev(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	return d.remote.GetRev(ctx, docID, options)
+}
+
+func (d *db) GetMeta(ctx context.Context, docID string, options map[string]interface{}) (*driver.DocMeta, error) {
+	meta, err := d.remote.GetMeta(ctx, docID, options)
+	if err != nil {
+		return nil, err
+	}
+	result := &driver.DocMeta{
+		Rev:     meta.Rev,
+		Deleted: meta.Deleted,
+		Size:    meta.Size,
+	}
+	for _, info := range meta.RevsInfo {
+		result.RevsInfo = append(result.RevsInfo, driver.RevInfo(info))
+	}
+	return result, nil
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	return d.remote.CreateDoc(ctx, doc, options)
+}
+
+func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	return d.remote.Put(ctx, docID, doc, options)
+}
+
+func (d *db) Delete(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	rev, _ := options["rev"].(string)
+	return d.remote.Delete(ctx, docID, rev, withoutRev(options))
+}
+
+// withoutRev returns a copy of options without the rev option, which is
+// passed separately by methods such as [kivik.DB.Delete].
+func withoutRev(options map[string]interface{}) kivik.Options {
+	opts := make(kivik.Options, len(options))
+	for k, v := range options {
+		if k != "rev" {
+			opts[k] = v
+		}
+	}
+	return opts
+}
+
+func (d *db) Copy(ctx context.Context, targetID, sourceID string, options map[string]interface{}) (string, error) {
+	return d.remote.Copy(ctx, targetID, sourceID, options)
+}
+
+func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) ([]driver.BulkResult, error) {
+	results, err := d.remote.BulkDocs(ctx, docs, options)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]driver.BulkResult, len(results))
+	for i, r := range results {
+		converted[i] = driver.BulkResult(r)
+	}
+	return converted, nil
+}
+
+func (d *db) BulkDocsStream(ctx context.Context, docs driver.DocSource, options map[string]interface{}) ([]driver.BulkResult, error) {
+	results, err := d.remote.BulkDocsFrom(ctx, docs, options)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]driver.BulkResult, len(results))
+	for i, r := range results {
+		converted[i] = driver.BulkResult(r)
+	}
+	return converted, nil
+}
+
+func (d *db) Stats(ctx context.Context) (*driver.DBStats, error) {
+	stats, err := d.remote.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return kivikStats2driverStats(stats), nil
+}
+
+func kivikStats2driverStats(s *kivik.DBStats) *driver.DBStats {
+	if s == nil {
+		return nil
+	}
+	var cluster *driver.ClusterStats
+	if s.Cluster != nil {
+		c := driver.ClusterStats(*s.Cluster)
+		cluster = &c
+	}
+	return &driver.DBStats{
+		Name:           s.Name,
+		CompactRunning: s.CompactRunning,
+		DocCount:       s.DocCount,
+		DeletedCount:   s.DeletedCount,
+		UpdateSeq:      s.UpdateSeq,
+		DiskSize:       s.DiskSize,
+		ActiveSize:     s.ActiveSize,
+		ExternalSize:   s.ExternalSize,
+		Cluster:        cluster,
+		RawResponse:    s.RawResponse,
+	}
+}
+
+func (d *db) Compact(ctx context.Context) error {
+	return d.remote.Compact(ctx)
+}
+
+func (d *db) CompactView(ctx context.Context, ddocID string) error {
+	return d.remote.CompactView(ctx, ddocID)
+}
+
+func (d *db) ViewCleanup(ctx context.Context) error {
+	return d.remote.ViewCleanup(ctx)
+}
+
+func (d *db) Flush(ctx context.Context) error {
+	return d.remote.Flush(ctx)
+}
+
+func (d *db) Purge(ctx context.Context, docRevMap map[string][]string) (*driver.PurgeResult, error) {
+	result, err := d.remote.Purge(ctx, docRevMap)
+	if err != nil {
+		return nil, err
+	}
+	return (*driver.PurgeResult)(result), nil
+}
+
+func (d *db) Security(ctx context.Context) (*driver.Security, error) {
+	sec, err := d.remote.Security(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Security{
+		Admins:  driver.Members(sec.Admins),
+		Members: driver.Members(sec.Members),
+	}, nil
+}
+
+func (d *db) SetSecurity(ctx context.Context, security *driver.Security) error {
+	return d.remote.SetSecurity(ctx, &kivik.Security{
+		Admins:  kivik.Members(security.Admins),
+		Members: kivik.Members(security.Members),
+	})
+}
+
+func (d *db) Changes(ctx context.Context, options map[string]interface{}) (driver.Changes, error) {
+	changes := d.remote.Changes(ctx, options)
+	if err := changes.Err(); err != nil {
+		return nil, err
+	}
+	return &changesFeed{Changes: changes}, nil
+}
+
+func (d *db) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	return d.remote.PutAttachment(ctx, docID, (*kivik.Attachment)(att), options)
+}
+
+func (d *db) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	att, err := d.remote.GetAttachment(ctx, docID, filename, options)
+	return (*driver.Attachment)(att), err
+}
+
+func (d *db) GetAttachmentMeta(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	att, err := d.remote.GetAttachmentMeta(ctx, docID, filename, options)
+	return (*driver.Attachment)(att), err
+}
+
+func (d *db) DeleteAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	rev, _ := options["rev"].(string)
+	return d.remote.DeleteAttachment(ctx, docID, rev, filename, withoutRev(options))
+}
+
+func (d *db) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, options map[string]interface{}) error {
+	return d.remote.CreateIndex(ctx, ddoc, name, index, options)
+}
+
+func (d *db) GetIndexes(ctx context.Context, options map[string]interface{}) ([]driver.Index, error) {
+	indexes, err := d.remote.GetIndexes(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]driver.Index, len(indexes))
+	for i, idx := range indexes {
+		converted[i] = driver.Index(idx)
+	}
+	return converted, nil
+}
+
+func (d *db) DeleteIndex(ctx context.Context, ddoc, name string, options map[string]interface{}) error {
+	return d.remote.DeleteIndex(ctx, ddoc, name, options)
+}
+
+func (d *db) Explain(ctx context.Context, query interface{}, options map[string]interface{}) (*driver.QueryPlan, error) {
+	plan, err := d.remote.Explain(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	return (*driver.QueryPlan)(plan), nil
+}
+
+func (d *db) Count(ctx context.Context, selector interface{}, options map[string]interface{}) (int64, error) {
+	return d.remote.Count(ctx, selector, options)
+}
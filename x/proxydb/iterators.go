@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package proxydb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// rows adapts a [kivik.ResultSet] to [driver.Rows].
+type rows struct {
+	kivik.ResultSet
+}
+
+var (
+	_ driver.Rows       = &rows{}
+	_ driver.RowsWarner = &rows{}
+	_ driver.Bookmarker = &rows{}
+)
+
+func newRows(rs kivik.ResultSet) (driver.Rows, error) {
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	return &rows{ResultSet: rs}, nil
+}
+
+func (r *rows) Next(row *driver.Row) error {
+	if !r.ResultSet.Next() {
+		if err := r.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	id, err := r.ID()
+	*row = driver.Row{ID: id}
+	if err != nil {
+		row.Error = err
+		return nil
+	}
+	var key, value, doc json.RawMessage
+	if r.ScanKey(&key) == nil {
+		row.Key = key
+	}
+	if err := r.ScanValue(&value); err == nil && value != nil {
+		row.Value = bytes.NewReader(value)
+	}
+	if r.ScanDoc(&doc) == nil {
+		row.Doc = bytes.NewReader(doc)
+	}
+	return nil
+}
+
+// metadata returns the result set's metadata, which is only available once
+// iteration has completed.
+func (r *rows) metadata() *kivik.ResultMetadata {
+	if meta, err := r.Metadata(); err == nil {
+		return meta
+	}
+	return &kivik.ResultMetadata{}
+}
+
+func (r *rows) Offset() int64     { return r.metadata().Offset }
+func (r *rows) TotalRows() int64  { return r.metadata().TotalRows }
+func (r *rows) UpdateSeq() string { return r.metadata().UpdateSeq }
+func (r *rows) Warning() string   { return r.metadata().Warning }
+func (r *rows) Bookmark() string  { return r.metadata().Bookmark }
+
+// changesFeed adapts [kivik.Changes] to [driver.Changes].
+type changesFeed struct {
+	*kivik.Changes
+}
+
+var _ driver.Changes = &changesFeed{}
+
+func (c *changesFeed) Next(change *driver.Change) error {
+	if !c.Changes.Next() {
+		if err := c.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	*change = driver.Change{
+		ID:      c.ID(),
+		Seq:     c.Seq(),
+		Deleted: c.Deleted(),
+		Changes: c.Changes.Changes(),
+	}
+	var doc json.RawMessage
+	if c.ScanDoc(&doc) == nil && len(doc) > 0 && string(doc) != "null" {
+		change.Doc = doc
+	}
+	return nil
+}
+
+func (c *changesFeed) metadata() *kivik.ChangesMetadata {
+	if meta, err := c.Metadata(); err == nil {
+		return meta
+	}
+	return &kivik.ChangesMetadata{}
+}
+
+func (c *changesFeed) LastSeq() string { return c.metadata().LastSeq }
+func (c *changesFeed) Pending() int64  { return c.metadata().Pending }
+
+// dbUpdates adapts [kivik.DBUpdates] to [driver.DBUpdates].
+type dbUpdates struct {
+	*kivik.DBUpdates
+}
+
+var (
+	_ driver.DBUpdates = &dbUpdates{}
+	_ driver.LastSeqer = &dbUpdates{}
+)
+
+func (u *dbUpdates) Next(update *driver.DBUpdate) error {
+	if !u.DBUpdates.Next() {
+		if err := u.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	*update = driver.DBUpdate{
+		DBName: u.DBName(),
+		Type:   u.Type(),
+		Seq:    u.Seq(),
+	}
+	return nil
+}
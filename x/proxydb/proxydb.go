@@ -0,0 +1,128 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package proxydb provides a Kivik driver which forwards every call to an
+// existing [kivik.Client], so that composite setups, such as a server
+// fronting a remote CouchDB, or additional layers of instrumentation, can be
+// built on any other driver.
+//
+//	remote, err := kivik.New("couch", "http://localhost:5984/")
+//	if err != nil {
+//	    return err
+//	}
+//	client, err := kivik.NewClientFromDriver(proxydb.NewDriver(remote), "")
+//
+// Options are passed through unaltered, and errors are returned as-is, so
+// that their HTTP status is preserved. Multiple result sets, as returned by
+// multi-queries, and replication are not supported.
+package proxydb
+
+import (
+	"context"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+type proxyDriver struct {
+	client *kivik.Client
+}
+
+var _ driver.Driver = &proxyDriver{}
+
+// NewDriver returns a driver whose clients all forward to c. The data source
+// name passed to [kivik.New] or [kivik.NewClientFromDriver] is ignored.
+func NewDriver(c *kivik.Client) driver.Driver {
+	return &proxyDriver{client: c}
+}
+
+func (d *proxyDriver) NewClient(string, map[string]interface{}) (driver.Client, error) {
+	return NewClient(d.client), nil
+}
+
+// NewClient returns a [driver.Client] which forwards to c. Closing the
+// returned client does not close c.
+func NewClient(c *kivik.Client) driver.Client {
+	return &client{remote: c}
+}
+
+type client struct {
+	remote *kivik.Client
+}
+
+var (
+	_ driver.Client     = &client{}
+	_ driver.Pinger     = &client{}
+	_ driver.DBsStatser = &client{}
+	_ driver.DBUpdater  = &client{}
+)
+
+func (c *client) Version(ctx context.Context) (*driver.Version, error) {
+	v, err := c.remote.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Version{
+		Version:     v.Version,
+		Vendor:      v.Vendor,
+		Features:    v.Features,
+		RawResponse: v.RawResponse,
+	}, nil
+}
+
+func (c *client) AllDBs(ctx context.Context, options map[string]interface{}) ([]string, error) {
+	return c.remote.AllDBs(ctx, options)
+}
+
+func (c *client) DBExists(ctx context.Context, dbName string, options map[string]interface{}) (bool, error) {
+	return c.remote.DBExists(ctx, dbName, options)
+}
+
+func (c *client) CreateDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return c.remote.CreateDB(ctx, dbName, options)
+}
+
+func (c *client) DestroyDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	return c.remote.DestroyDB(ctx, dbName, options)
+}
+
+func (c *client) DB(dbName string, options map[string]interface{}) (driver.DB, error) {
+	d := c.remote.DB(dbName, options)
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return &db{remote: d}, nil
+}
+
+func (c *client) Ping(ctx context.Context) (bool, error) {
+	return c.remote.Ping(ctx)
+}
+
+func (c *client) DBsStats(ctx context.Context, dbNames []string) ([]*driver.DBStats, error) {
+	stats, err := c.remote.DBsStats(ctx, dbNames)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*driver.DBStats, len(stats))
+	for i, s := range stats {
+		result[i] = kivikStats2driverStats(s)
+	}
+	return result, nil
+}
+
+func (c *client) DBUpdates(ctx context.Context, options map[string]interface{}) (driver.DBUpdates, error) {
+	updates := c.remote.DBUpdates(ctx, options)
+	if err := updates.Err(); err != nil {
+		return nil, err
+	}
+	return &dbUpdates{DBUpdates: updates}, nil
+}
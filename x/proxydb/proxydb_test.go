@@ -0,0 +1,249 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package proxydb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+// newProxy returns a client forwarding to a new, empty in-memory server,
+// and the server itself.
+func newProxy(t *testing.T) (proxy, remote *kivik.Client) {
+	t.Helper()
+	remote, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err = kivik.NewClientFromDriver(NewDriver(remote), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxy, remote
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	proxy, remote := newProxy(t)
+	version, err := proxy.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Vendor != "Kivik Memory Adaptor" {
+		t.Errorf("Unexpected vendor: %s", version.Vendor)
+	}
+	if err := proxy.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := remote.DBExists(ctx, "animals"); !exists {
+		t.Error("Expected database to be created on the remote client")
+	}
+	err = proxy.CreateDB(ctx, "animals")
+	testutil.CheckError(t, "database exists", http.StatusPreconditionFailed, err)
+	dbs, err := proxy.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"animals"}, dbs); d != nil {
+		t.Error(d)
+	}
+	if ok, err := proxy.Ping(ctx); !ok || err != nil {
+		t.Errorf("Unexpected ping result: %t, %v", ok, err)
+	}
+	err = proxy.DBUpdates(ctx).Err()
+	testutil.CheckError(t, "kivik: driver does not implement DBUpdater", http.StatusNotImplemented, err)
+
+	if err := proxy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.AllDBs(ctx); err != nil {
+		t.Errorf("Closing the proxy closed the remote client: %s", err)
+	}
+}
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	proxy, remote := newProxy(t)
+	if err := remote.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	db := proxy.DB("animals")
+
+	rev, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := remote.DB("animals").Get(ctx, "cow").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["_rev"] != rev || doc["name"] != "Bessie" {
+		t.Errorf("Unexpected remote document: %v", doc)
+	}
+	row := db.Get(ctx, "cow")
+	if got, _ := row.Rev(); got != rev {
+		t.Errorf("Unexpected rev: %s", got)
+	}
+	_, err = db.Put(ctx, "cow", map[string]interface{}{"name": "Daisy"})
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+
+	rev, err = db.PutAttachment(ctx, "cow", &kivik.Attachment{
+		Filename:    "moo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("moo")),
+	}, kivik.Options{"rev": rev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := db.GetAttachment(ctx, "cow", "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if string(content) != "moo" {
+		t.Errorf("Unexpected attachment content: %s", content)
+	}
+	if rev, err = db.DeleteAttachment(ctx, "cow", rev, "moo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(ctx, "cow", rev); err != nil {
+		t.Fatal(err)
+	}
+	err = remote.DB("animals").Get(ctx, "cow").Err()
+	testutil.CheckError(t, "deleted", http.StatusNotFound, err)
+
+	sec := &kivik.Security{Admins: kivik.Members{Names: []string{"bob"}}}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.DB("animals").Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(sec, got); d != nil {
+		t.Error(d)
+	}
+
+	results, err := db.BulkDocsFrom(ctx, kivik.NDJSONSource(strings.NewReader(`{"_id":"a"}
+{"_id":"b"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].ID != "b" || results[1].Error != nil {
+		t.Errorf("Unexpected bulk results: %v", results)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	ctx := context.Background()
+	proxy, remote := newProxy(t)
+	if err := remote.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := remote.DB("animals").Put(ctx, id, map[string]string{"type": "cow"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := proxy.DB("animals")
+
+	t.Run("all docs", func(t *testing.T) {
+		rows := db.AllDocs(ctx, kivik.Options{"skip": 1, "include_docs": true})
+		var ids []string
+		for rows.Next() {
+			id, _ := rows.ID()
+			var doc map[string]interface{}
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc["_id"] != id {
+				t.Errorf("Unexpected doc for %s: %v", id, doc)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"b", "c"}, ids); d != nil {
+			t.Error(d)
+		}
+		meta, _ := rows.Metadata()
+		if meta.TotalRows != 3 || meta.Offset != 1 {
+			t.Errorf("Unexpected metadata: %+v", meta)
+		}
+	})
+	t.Run("find", func(t *testing.T) {
+		rows := db.Find(ctx, `{"selector":{"_id":{"$gt":"a"}},"fields":["_id"]}`)
+		var ids []string
+		for rows.Next() {
+			id, _ := rows.ID()
+			ids = append(ids, id)
+		}
+		if d := testy.DiffInterface([]string{"b", "c"}, ids); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("count", func(t *testing.T) {
+		count, err := db.Count(ctx, `{"_id":{"$gt":"a"}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("Unexpected count: %d", count)
+		}
+	})
+	t.Run("get meta", func(t *testing.T) {
+		meta, err := db.GetMeta(ctx, "a", kivik.GetOptions{RevsInfo: true}.Options())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.ID != "a" || meta.Size <= 0 || meta.RevCount != 1 || meta.RevsInfo[0].Rev != meta.Rev {
+			t.Errorf("Unexpected meta: %+v", meta)
+		}
+	})
+	t.Run("changes", func(t *testing.T) {
+		changes := db.Changes(ctx, kivik.Options{"include_docs": true})
+		var ids []string
+		for changes.Next() {
+			var doc map[string]interface{}
+			if err := changes.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, changes.ID())
+		}
+		if err := changes.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"a", "b", "c"}, ids); d != nil {
+			t.Error(d)
+		}
+		meta, _ := changes.Metadata()
+		if meta.LastSeq != "3" {
+			t.Errorf("Unexpected last seq: %s", meta.LastSeq)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		err := db.Query(ctx, "foo", "bar").Err()
+		testutil.CheckError(t, "kivik: views are not supported by the memory driver", http.StatusNotImplemented, err)
+	})
+}
@@ -0,0 +1,453 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package ryw provides read-your-writes consistency on top of any driver.
+//
+// A CouchDB cluster is eventually consistent: with the default quorum
+// settings, a document read immediately after it was written may be served by
+// a replica which has not yet received the write. For interactive flows, such
+// as redirecting a user to a page showing a record they have just saved, this
+// is surprising.
+//
+// The wrappers in this package remember the revision of each document written
+// through them, and ensure that later reads of those documents see that
+// revision, or a newer one:
+//
+//	client, err := kivik.NewClientFromDriverClient(ryw.NewClient(driverClient, ryw.Config{
+//	    ReadQuorum: 2,
+//	}))
+//
+// Reads of a recently written document without an explicit rev are made with
+// the r (read quorum) option, if Config.ReadQuorum is set, and are retried,
+// with exponential back-off, for as long as they return an older revision.
+// View queries made while recent writes are pending have their stale and
+// update options overridden, so that the index is brought up to date before
+// it is read.
+//
+// Revisions are compared by their generation, so a newer revision written by
+// another client also satisfies the read. Writes are remembered for
+// Config.TTL, after which the cluster is assumed to have converged.
+package ryw
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// DefaultRetries is the number of times a stale read is retried, when
+// Config.Retries is unset.
+const DefaultRetries = 5
+
+// DefaultBackoff is the delay before the first retry of a stale read, when
+// Config.Backoff is unset.
+const DefaultBackoff = 50 * time.Millisecond
+
+// DefaultTTL is the time for which writes are remembered, when Config.TTL is
+// unset.
+const DefaultTTL = time.Minute
+
+// Config configures read-your-writes consistency.
+type Config struct {
+	// ReadQuorum, if greater than zero, is passed as the r option to reads
+	// of recently written documents.
+	ReadQuorum int
+	// Retries is the maximum number of times a stale read is retried, before
+	// an error with status 503 is returned.
+	Retries int
+	// Backoff is the delay before the first retry of a stale read. It is
+	// doubled after each retry.
+	Backoff time.Duration
+	// TTL is the time for which writes are remembered.
+	TTL time.Duration
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+}
+
+func (c Config) withDefaults() Config {
+	if c.Retries <= 0 {
+		c.Retries = DefaultRetries
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = DefaultBackoff
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultTTL
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// write is a revision written through a wrapper.
+type write struct {
+	rev     string
+	gen     int64
+	expires time.Time
+}
+
+// generation returns the generation of rev, or 0 if it is invalid.
+func generation(rev string) int64 {
+	gen, _ := strconv.ParseInt(strings.SplitN(rev, "-", 2)[0], 10, 64)
+	return gen
+}
+
+// tracker remembers the writes made to a single database.
+type tracker struct {
+	config Config
+
+	mu     sync.Mutex
+	writes map[string]write
+}
+
+func newTracker(config Config) *tracker {
+	return &tracker{config: config, writes: map[string]write{}}
+}
+
+// record remembers that rev of docID was written.
+func (t *tracker) record(docID, rev string) {
+	gen := generation(rev)
+	if docID == "" || gen == 0 {
+		return
+	}
+	now := t.config.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, w := range t.writes {
+		if !now.Before(w.expires) {
+			delete(t.writes, id)
+		}
+	}
+	if w, ok := t.writes[docID]; ok && w.gen > gen {
+		return
+	}
+	t.writes[docID] = write{rev: rev, gen: gen, expires: now.Add(t.config.TTL)}
+}
+
+// lookup returns the last write of docID, if it has not expired.
+func (t *tracker) lookup(docID string) (write, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.writes[docID]
+	if ok && !t.config.Now().Before(w.expires) {
+		delete(t.writes, docID)
+		return write{}, false
+	}
+	return w, ok
+}
+
+// pending returns true if any writes have not expired.
+func (t *tracker) pending() bool {
+	now := t.config.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, w := range t.writes {
+		if now.Before(w.expires) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client wraps a [driver.Client], so that its databases provide
+// read-your-writes consistency.
+type Client struct {
+	driver.Client
+	passthrough.ClientFeatures
+	config Config
+
+	mu       sync.Mutex
+	trackers map[string]*tracker
+}
+
+var _ driver.Client = &Client{}
+
+// NewClient returns client wrapped so that its databases provide
+// read-your-writes consistency. Writes are remembered for all uses of each
+// database through the returned client.
+func NewClient(client driver.Client, config Config) *Client {
+	return &Client{
+		Client:         client,
+		ClientFeatures: passthrough.ClientFeatures{Base: client},
+		config:         config.withDefaults(),
+		trackers:       map[string]*tracker{},
+	}
+}
+
+// DB returns the named database, wrapped to provide read-your-writes
+// consistency.
+func (c *Client) DB(name string, options map[string]interface{}) (driver.DB, error) {
+	db, err := c.Client.DB(name, options)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.trackers[name]
+	if !ok {
+		t = newTracker(c.config)
+		c.trackers[name] = t
+	}
+	return wrapDB(db, t), nil
+}
+
+// DestroyDB destroys the named database, and forgets the writes made to it.
+func (c *Client) DestroyDB(ctx context.Context, name string, options map[string]interface{}) error {
+	c.mu.Lock()
+	delete(c.trackers, name)
+	c.mu.Unlock()
+	return c.Client.DestroyDB(ctx, name, options)
+}
+
+// DB wraps a [driver.DB] to provide read-your-writes consistency. In addition
+// to the methods of driver.DB, it implements the optional database interfaces
+// used by Kivik, passing calls through to the underlying driver.
+type DB struct {
+	driver.DB
+	passthrough.DBFeatures
+	tracker *tracker
+}
+
+var _ driver.DB = &DB{}
+
+func wrapDB(db driver.DB, t *tracker) *DB {
+	rdb := &DB{DB: db, tracker: t}
+	rdb.DBFeatures = passthrough.DBFeatures{Base: db, Self: rdb}
+	return rdb
+}
+
+// New returns db wrapped to provide read-your-writes consistency for the
+// writes made through it. To share writes between uses of the same database,
+// as with [kivik.Client.DB], use [NewClient] instead.
+func New(db driver.DB, config Config) *DB {
+	return wrapDB(db, newTracker(config.withDefaults()))
+}
+
+// Put calls the underlying driver's Put method, and remembers the new
+// revision.
+func (db *DB) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	rev, err := db.DB.Put(ctx, docID, doc, options)
+	if err == nil {
+		db.tracker.record(docID, rev)
+	}
+	return rev, err
+}
+
+// CreateDoc calls the underlying driver's CreateDoc method, and remembers the
+// new revision.
+func (db *DB) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	docID, rev, err := db.DB.CreateDoc(ctx, doc, options)
+	if err == nil {
+		db.tracker.record(docID, rev)
+	}
+	return docID, rev, err
+}
+
+// Delete calls the underlying driver's Delete method, and remembers the
+// revision of the deletion.
+func (db *DB) Delete(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	rev, err := db.DB.Delete(ctx, docID, options)
+	if err == nil {
+		db.tracker.record(docID, rev)
+	}
+	return rev, err
+}
+
+// PutAttachment calls the underlying driver's PutAttachment method, and
+// remembers the new revision.
+func (db *DB) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	rev, err := db.DB.PutAttachment(ctx, docID, att, options)
+	if err == nil {
+		db.tracker.record(docID, rev)
+	}
+	return rev, err
+}
+
+// DeleteAttachment calls the underlying driver's DeleteAttachment method,
+// and remembers the new revision.
+func (db *DB) DeleteAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	rev, err := db.DB.DeleteAttachment(ctx, docID, filename, options)
+	if err == nil {
+		db.tracker.record(docID, rev)
+	}
+	return rev, err
+}
+
+// recordResults remembers the revisions written by a bulk operation.
+func (db *DB) recordResults(results []driver.BulkResult) {
+	for _, result := range results {
+		if result.Error == nil {
+			db.tracker.record(result.ID, result.Rev)
+		}
+	}
+}
+
+// BulkDocs calls the underlying driver's BulkDocs method, or falls back to
+// calling Put or CreateDoc for each document, and remembers the new revisions.
+func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) ([]driver.BulkResult, error) {
+	results, err := db.DBFeatures.BulkDocs(ctx, docs, options)
+	db.recordResults(results)
+	return results, err
+}
+
+// BulkDocsStream calls the underlying driver's BulkDocsStream method, or
+// falls back to BulkDocs, and remembers the new revisions.
+func (db *DB) BulkDocsStream(ctx context.Context, docs driver.DocSource, options map[string]interface{}) ([]driver.BulkResult, error) {
+	results, err := db.DBFeatures.BulkDocsStream(ctx, docs, options)
+	db.recordResults(results)
+	return results, err
+}
+
+// Copy calls the underlying driver's Copy method, or falls back to Get and
+// Put, and remembers the new revision of the target.
+func (db *DB) Copy(ctx context.Context, targetID, sourceID string, options map[string]interface{}) (string, error) {
+	rev, err := db.DBFeatures.Copy(ctx, targetID, sourceID, options)
+	if err == nil {
+		db.tracker.record(targetID, rev)
+	}
+	return rev, err
+}
+
+// readOptions returns options, with the read quorum added, unless options
+// already set one.
+func (db *DB) readOptions(options map[string]interface{}) map[string]interface{} {
+	if db.tracker.config.ReadQuorum <= 0 {
+		return options
+	}
+	if _, ok := options["r"]; ok {
+		return options
+	}
+	opts := make(map[string]interface{}, len(options)+1)
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["r"] = db.tracker.config.ReadQuorum
+	return opts
+}
+
+// visible returns true if a read which returned rev, or err, reflects w.
+func visible(w write, rev string, err error) bool {
+	switch {
+	case err == nil:
+		return generation(rev) >= w.gen
+	case kivik.HTTPStatus(err) == http.StatusNotFound:
+		// A replica which has not seen a new document does not have it at
+		// all. Later generations are only missing once deleted.
+		return w.gen > 1
+	}
+	return true
+}
+
+// read calls fn until it returns a revision which reflects the last write of
+// docID, if there was one. discard is called with stale results.
+func (db *DB) read(ctx context.Context, docID string, options map[string]interface{}, fn func(map[string]interface{}) (string, error), discard func()) error {
+	w, ok := db.tracker.lookup(docID)
+	if _, hasRev := options["rev"]; !ok || hasRev {
+		_, err := fn(options)
+		return err
+	}
+	options = db.readOptions(options)
+	backoff := db.tracker.config.Backoff
+	for i := 0; ; i++ {
+		rev, err := fn(options)
+		if visible(w, rev, err) {
+			return err
+		}
+		if err == nil {
+			discard()
+		}
+		if i == db.tracker.config.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return &kivik.Error{Status: http.StatusServiceUnavailable, Message: fmt.Sprintf("ryw: revision %s of %s is not yet visible", w.rev, docID)}
+}
+
+// Get returns the requested document. If it was recently written, the read
+// is retried until it returns the written revision, or a newer one.
+func (db *DB) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	var doc *driver.Document
+	err := db.read(ctx, docID, options, func(opts map[string]interface{}) (string, error) {
+		var err error
+		doc, err = db.DB.Get(ctx, docID, opts)
+		if err != nil {
+			return "", err
+		}
+		return doc.Rev, nil
+	}, func() {
+		_ = doc.Body.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// GetRev returns the current revision of the requested document, by way of
+// the underlying driver's GetRev method, or Get. If the document was recently
+// written, the read is retried until it returns the written revision, or a
+// newer one.
+func (db *DB) GetRev(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	var rev string
+	err := db.read(ctx, docID, options, func(opts map[string]interface{}) (string, error) {
+		var err error
+		rev, err = db.getRev(ctx, docID, opts)
+		return rev, err
+	}, func() {})
+	return rev, err
+}
+
+func (db *DB) getRev(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	if revGetter, ok := db.DB.(driver.RevGetter); ok {
+		return revGetter.GetRev(ctx, docID, options)
+	}
+	doc, err := db.DB.Get(ctx, docID, options)
+	if err != nil {
+		return "", err
+	}
+	_ = doc.Body.Close()
+	return doc.Rev, nil
+}
+
+// Query calls the underlying driver's Query method. While recent writes are
+// pending, the stale option is removed, and an update option of false or
+// "lazy" is replaced by true, so that the view index includes the writes.
+func (db *DB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	if !db.tracker.pending() {
+		return db.DB.Query(ctx, ddoc, view, options)
+	}
+	opts := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		opts[k] = v
+	}
+	delete(opts, "stale")
+	switch fmt.Sprint(opts["update"]) {
+	case "false", "lazy":
+		opts["update"] = true
+	}
+	return db.DB.Query(ctx, ddoc, view, opts)
+}
@@ -0,0 +1,344 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package ryw
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+var notFound = &kivik.Error{Status: http.StatusNotFound, Message: "missing"}
+
+// replicaDB returns a DB whose reads return each of revs in turn, and then
+// the last of them, to simulate replicas catching up with a write. An empty
+// revision is reported as not found.
+func replicaDB(revs []string, gets *int, lastOptions *map[string]interface{}) *mock.DB {
+	return &mock.DB{
+		PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+			return "2-b", nil
+		},
+		CreateDocFunc: func(context.Context, interface{}, map[string]interface{}) (string, string, error) {
+			return "new", "1-a", nil
+		},
+		GetFunc: func(_ context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+			*gets++
+			*lastOptions = options
+			rev := revs[len(revs)-1]
+			if *gets <= len(revs) {
+				rev = revs[*gets-1]
+			}
+			if rev == "" {
+				return nil, notFound
+			}
+			return &driver.Document{
+				Rev:  rev,
+				Body: io.NopCloser(strings.NewReader(`{"_id":"` + docID + `","_rev":"` + rev + `"}`)),
+			}, nil
+		},
+	}
+}
+
+func TestGet(t *testing.T) {
+	type tst struct {
+		config  Config
+		write   func(*DB) (string, error)
+		options map[string]interface{}
+		revs    []string
+		rev     string
+		gets    int
+		wantOpt map[string]interface{}
+		status  int
+		err     string
+	}
+	put := func(db *DB) (string, error) {
+		_, err := db.Put(context.Background(), "foo", map[string]string{}, nil)
+		return "foo", err
+	}
+	create := func(db *DB) (string, error) {
+		docID, _, err := db.CreateDoc(context.Background(), map[string]string{}, nil)
+		return docID, err
+	}
+	tests := testy.NewTable()
+	tests.Add("not written", tst{
+		write: func(*DB) (string, error) { return "foo", nil },
+		revs:  []string{"1-a"},
+		rev:   "1-a",
+		gets:  1,
+	})
+	tests.Add("up to date", tst{
+		write: put,
+		revs:  []string{"2-b"},
+		rev:   "2-b",
+		gets:  1,
+	})
+	tests.Add("newer revision", tst{
+		write: put,
+		revs:  []string{"3-c"},
+		rev:   "3-c",
+		gets:  1,
+	})
+	tests.Add("stale, then up to date", tst{
+		write: put,
+		revs:  []string{"1-a", "1-a", "2-b"},
+		rev:   "2-b",
+		gets:  3,
+	})
+	tests.Add("read quorum", tst{
+		config:  Config{ReadQuorum: 2},
+		write:   put,
+		options: map[string]interface{}{"attachments": true},
+		revs:    []string{"2-b"},
+		rev:     "2-b",
+		gets:    1,
+		wantOpt: map[string]interface{}{"attachments": true, "r": 2},
+	})
+	tests.Add("explicit rev", tst{
+		config:  Config{ReadQuorum: 2},
+		write:   put,
+		options: map[string]interface{}{"rev": "1-a"},
+		revs:    []string{"1-a"},
+		rev:     "1-a",
+		gets:    1,
+		wantOpt: map[string]interface{}{"rev": "1-a"},
+	})
+	tests.Add("new document not yet replicated", tst{
+		write: create,
+		revs:  []string{"", "1-a"},
+		rev:   "1-a",
+		gets:  2,
+	})
+	tests.Add("never visible", tst{
+		config: Config{Retries: 2},
+		write:  put,
+		revs:   []string{"1-a"},
+		gets:   3,
+		status: http.StatusServiceUnavailable,
+		err:    "ryw: revision 2-b of foo is not yet visible",
+	})
+	tests.Add("expired", tst{
+		config: Config{TTL: -time.Hour},
+		write:  put,
+		revs:   []string{"1-a"},
+		rev:    "1-a",
+		gets:   1,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var gets int
+		var lastOptions map[string]interface{}
+		tt.config.Backoff = time.Millisecond
+		if tt.config.TTL < 0 {
+			now := time.Now()
+			tt.config.TTL = time.Second
+			tt.config.Now = func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			}
+		}
+		db := New(replicaDB(tt.revs, &gets, &lastOptions), tt.config)
+		docID, err := tt.write(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := db.Get(context.Background(), docID, tt.options)
+		if gets != tt.gets {
+			t.Errorf("Expected %d reads, got %d", tt.gets, gets)
+		}
+		if tt.wantOpt != nil {
+			if d := testy.DiffInterface(tt.wantOpt, lastOptions); d != nil {
+				t.Errorf("Unexpected options:\n%s", d)
+			}
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+		_ = doc.Body.Close()
+		if doc.Rev != tt.rev {
+			t.Errorf("Unexpected rev: %s", doc.Rev)
+		}
+	})
+}
+
+func TestGetRev(t *testing.T) {
+	var gets int
+	var lastOptions map[string]interface{}
+	db := New(replicaDB([]string{"1-a", "2-b"}, &gets, &lastOptions), Config{Backoff: time.Millisecond})
+	if _, err := db.Put(context.Background(), "foo", map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.GetRev(context.Background(), "foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != "2-b" || gets != 2 {
+		t.Errorf("Unexpected rev %s after %d reads", rev, gets)
+	}
+}
+
+func TestBulkDocs(t *testing.T) {
+	var gets int
+	var lastOptions map[string]interface{}
+	base := replicaDB([]string{"1-a", "3-c"}, &gets, &lastOptions)
+	db := New(&mock.BulkDocer{
+		DB: base,
+		BulkDocsFunc: func(context.Context, []interface{}, map[string]interface{}) ([]driver.BulkResult, error) {
+			return []driver.BulkResult{
+				{ID: "foo", Rev: "3-c"},
+				{ID: "bar", Error: notFound},
+			}, nil
+		},
+	}, Config{Backoff: time.Millisecond})
+	if _, err := db.BulkDocs(context.Background(), []interface{}{map[string]string{}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.tracker.lookup("bar"); ok {
+		t.Error("Failed write should not be tracked")
+	}
+	doc, err := db.Get(context.Background(), "foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = doc.Body.Close()
+	if doc.Rev != "3-c" || gets != 2 {
+		t.Errorf("Unexpected rev %s after %d reads", doc.Rev, gets)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var gotOptions map[string]interface{}
+	db := New(&mock.DB{
+		PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+			return "1-a", nil
+		},
+		QueryFunc: func(_ context.Context, _, _ string, options map[string]interface{}) (driver.Rows, error) {
+			gotOptions = options
+			return nil, nil
+		},
+	}, Config{})
+	options := map[string]interface{}{"stale": "ok", "update": "lazy", "limit": 10}
+	if _, err := db.Query(context.Background(), "ddoc", "view", options); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(options, gotOptions); d != nil {
+		t.Errorf("Options should be unchanged without pending writes:\n%s", d)
+	}
+	if _, err := db.Put(context.Background(), "foo", map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(context.Background(), "ddoc", "view", options); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"update": true, "limit": 10}
+	if d := testy.DiffInterface(want, gotOptions); d != nil {
+		t.Errorf("Unexpected options:\n%s", d)
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := NewClient(&mock.Client{
+		DBFunc: func(string, map[string]interface{}) (driver.DB, error) {
+			return &mock.DB{}, nil
+		},
+		DestroyDBFunc: func(context.Context, string, map[string]interface{}) error {
+			return nil
+		},
+	}, Config{})
+	db1, _ := c.DB("foo", nil)
+	db2, _ := c.DB("foo", nil)
+	other, _ := c.DB("bar", nil)
+	if db1.(*DB).tracker != db2.(*DB).tracker {
+		t.Error("Uses of the same database should share writes")
+	}
+	if db1.(*DB).tracker == other.(*DB).tracker {
+		t.Error("Different databases should not share writes")
+	}
+	if err := c.DestroyDB(context.Background(), "foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	db3, _ := c.DB("foo", nil)
+	if db1.(*DB).tracker == db3.(*DB).tracker {
+		t.Error("Writes should be forgotten when the database is destroyed")
+	}
+}
+
+// featureClient is a driver client which implements some of the optional
+// client interfaces, and records their use.
+type featureClient struct {
+	*mock.Client
+	calls []string
+}
+
+func (c *featureClient) Authenticate(context.Context, interface{}) error {
+	c.calls = append(c.calls, "Authenticate")
+	return nil
+}
+
+func (c *featureClient) Ping(context.Context) (bool, error) {
+	c.calls = append(c.calls, "Ping")
+	return true, nil
+}
+
+func (c *featureClient) Close() error {
+	c.calls = append(c.calls, "Close")
+	return nil
+}
+
+func TestClientPassthrough(t *testing.T) {
+	ctx := context.Background()
+	dc := &featureClient{Client: &mock.Client{}}
+	client, err := kivik.NewClientFromDriverClient(NewClient(dc, Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Authenticate(ctx, "creds"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"Authenticate", "Ping", "Close"}, dc.calls); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestBulkDocsStream(t *testing.T) {
+	db := New(&mock.BulkDocsStreamer{
+		DB: &mock.DB{},
+		BulkDocsStreamFunc: func(context.Context, driver.DocSource, map[string]interface{}) ([]driver.BulkResult, error) {
+			return []driver.BulkResult{
+				{ID: "foo", Rev: "2-a"},
+				{ID: "bar", Error: notFound},
+			}, nil
+		},
+	}, Config{})
+	if _, err := db.BulkDocsStream(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w, ok := db.tracker.lookup("foo"); !ok || w.rev != "2-a" {
+		t.Errorf("Unexpected write of foo: %v, %v", w, ok)
+	}
+	if _, ok := db.tracker.lookup("bar"); ok {
+		t.Error("Failed writes should not be remembered")
+	}
+}
@@ -0,0 +1,137 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package bleve provides a [search.Index] backed by Bleve
+// (https://github.com/blevesearch/bleve), for persistent full-text search
+// indexes with Bleve's complete query string syntax. It is a separate module,
+// so that users of [github.com/go-kivik/kivik/v4/x/search] who do not need
+// Bleve do not depend on it:
+//
+//	names := search.Definition{
+//	    DDoc: "_design/animals",
+//	    Name: "names",
+//	    Open: bleve.Opener("/var/lib/zoo", "names"),
+//	}
+//	client, err := kivik.NewClientFromDriverClient(search.NewClient(driverClient, names))
+//
+// The search package does not persist the last sequence indexed, so a
+// persistent index is updated from the start of the changes feed when the
+// program restarts. Documents are replaced as they are re-indexed, but
+// documents deleted while the program was not running remain in the index
+// unless the changes feed reports their deletion.
+package bleve
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+
+	blevev2 "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/go-kivik/kivik/v4/x/search"
+)
+
+// Index adapts a Bleve index to [search.Index]. Queries use Bleve's query
+// string syntax, except that *:*, which Lucene uses to match all documents,
+// is also accepted. Hits hold the fields stored in the index, which, with the
+// default index mapping, are all of the indexed fields.
+type Index struct {
+	idx blevev2.Index
+}
+
+var _ search.Index = &Index{}
+
+// New returns idx adapted to [search.Index].
+func New(idx blevev2.Index) *Index {
+	return &Index{idx: idx}
+}
+
+// NewMemory returns a new, empty, in-memory Bleve index, with the default
+// index mapping.
+func NewMemory() (*Index, error) {
+	idx, err := blevev2.NewMemOnly(blevev2.NewIndexMapping())
+	if err != nil {
+		return nil, err
+	}
+	return New(idx), nil
+}
+
+// Open opens the Bleve index at path, creating it with the default index
+// mapping if it does not exist.
+func Open(path string) (*Index, error) {
+	idx, err := blevev2.Open(path)
+	if errors.Is(err, blevev2.ErrorIndexPathDoesNotExist) {
+		idx, err = blevev2.New(path, blevev2.NewIndexMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(idx), nil
+}
+
+// Opener returns a function, for use as [search.Definition].Open, which
+// opens the index called name of each database, with [Open], in dir. Each
+// index is stored in dir as <database>.<name>.bleve, with the database name
+// escaped as for a URL path.
+func Opener(dir, name string) func(dbName string) (search.Index, error) {
+	return func(dbName string) (search.Index, error) {
+		return Open(filepath.Join(dir, url.PathEscape(dbName)+"."+name+".bleve"))
+	}
+}
+
+// Bleve returns the underlying Bleve index.
+func (i *Index) Bleve() blevev2.Index {
+	return i.idx
+}
+
+// Index adds or replaces the document with the given ID.
+func (i *Index) Index(id string, fields interface{}) error {
+	return i.idx.Index(id, fields)
+}
+
+// Delete removes the document with the given ID, if it is indexed.
+func (i *Index) Delete(id string) error {
+	return i.idx.Delete(id)
+}
+
+// DocCount returns the number of documents indexed.
+func (i *Index) DocCount() (uint64, error) {
+	return i.idx.DocCount()
+}
+
+// Close closes the underlying Bleve index.
+func (i *Index) Close() error {
+	return i.idx.Close()
+}
+
+// Search answers a query.
+func (i *Index) Search(ctx context.Context, req *search.Request) (*search.Result, error) {
+	var q query.Query
+	if req.Query == "*:*" {
+		q = blevev2.NewMatchAllQuery()
+	} else {
+		q = blevev2.NewQueryStringQuery(req.Query)
+	}
+	sr := blevev2.NewSearchRequestOptions(q, req.Size, req.From, false)
+	sr.Fields = []string{"*"}
+	res, err := i.idx.SearchInContext(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	result := &search.Result{Total: res.Total, Hits: make([]search.Hit, 0, len(res.Hits))}
+	for _, hit := range res.Hits {
+		result.Hits = append(result.Hits, search.Hit{ID: hit.ID, Score: hit.Score, Fields: hit.Fields})
+	}
+	return result, nil
+}
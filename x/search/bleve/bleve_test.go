@@ -0,0 +1,106 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package bleve
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/x/search"
+)
+
+func hitIDs(result *search.Result) []string {
+	ids := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	idx, err := NewMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = idx.Close() })
+	for id, fields := range map[string]interface{}{
+		"cow":     map[string]interface{}{"name": "Bessie", "class": "mammal"},
+		"chicken": map[string]interface{}{"name": "Henrietta", "class": "bird"},
+		"pig":     map[string]interface{}{"name": "Wilbur", "class": "mammal"},
+	} {
+		if err := idx.Index(id, fields); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := idx.Search(ctx, &search.Request{Query: "name:bessie", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 || len(result.Hits) != 1 || result.Hits[0].ID != "cow" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if result.Hits[0].Fields["name"] != "Bessie" {
+		t.Errorf("Unexpected fields: %v", result.Hits[0].Fields)
+	}
+
+	result, err = idx.Search(ctx, &search.Request{Query: "*:*", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 3 || len(result.Hits) != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if err := idx.Delete("pig"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = idx.Search(ctx, &search.Request{Query: "class:mammal", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"cow"}, hitIDs(result)); d != nil {
+		t.Error(d)
+	}
+	if n, err := idx.DocCount(); err != nil || n != 2 {
+		t.Errorf("Unexpected doc count: %d, %v", n, err)
+	}
+}
+
+func TestOpener(t *testing.T) {
+	dir := t.TempDir()
+	open := Opener(dir, "names")
+	idx, err := open("zoo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Index("cow", map[string]interface{}{"name": "Bessie"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.(*Index).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(filepath.Join(dir, "zoo.names.bleve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	if n, err := reopened.DocCount(); err != nil || n != 1 {
+		t.Errorf("Unexpected doc count: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,416 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	defaultLimit = 25
+	maxLimit     = 200
+)
+
+func badRequest(format string, args ...interface{}) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(ddoc, index string) error {
+	return &kivik.Error{Status: http.StatusNotFound, Message: "kivik: no search index " + index + " in _design/" + ddoc}
+}
+
+// searchIndex is an index, with the statistics reported by SearchInfo.
+type searchIndex struct {
+	def      Definition
+	idx      Index
+	delCount int64
+}
+
+// engine maintains the search indexes of a single database.
+type engine struct {
+	dbName string
+	defs   definitions
+
+	mu sync.Mutex
+	// seq is the last update sequence indexed, or empty before the first
+	// update.
+	seq string
+	// indexes holds the open indexes, keyed by design document and index
+	// name. It is nil until the indexes are opened, by the first update.
+	indexes map[string]*searchIndex
+}
+
+func newEngine(dbName string, defs definitions) *engine {
+	return &engine{dbName: dbName, defs: defs}
+}
+
+// open opens all indexes, if they are not already open. e.mu must be held.
+func (e *engine) open() error {
+	if e.indexes != nil {
+		return nil
+	}
+	indexes := map[string]*searchIndex{}
+	for ddoc, defs := range e.defs {
+		for name, def := range defs {
+			var idx Index
+			if def.Open == nil {
+				idx = NewMemoryIndex()
+			} else {
+				var err error
+				if idx, err = def.Open(e.dbName); err != nil {
+					return err
+				}
+			}
+			indexes[ddoc+"/"+name] = &searchIndex{def: def, idx: idx}
+		}
+	}
+	e.indexes = indexes
+	return nil
+}
+
+// update indexes the changes to db since the last update. e.mu must be held.
+func (e *engine) update(ctx context.Context, db driver.DB) error {
+	if err := e.open(); err != nil {
+		return err
+	}
+	opts := map[string]interface{}{"include_docs": true}
+	if e.seq != "" {
+		opts["since"] = e.seq
+	}
+	changes, err := db.Changes(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer changes.Close() // nolint:errcheck
+	var change driver.Change
+	for {
+		change = driver.Change{}
+		err := changes.Next(&change)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.index(ctx, db, &change); err != nil {
+			return err
+		}
+		if change.Seq != "" {
+			e.seq = change.Seq
+		}
+	}
+	if seq := changes.LastSeq(); seq != "" {
+		e.seq = seq
+	}
+	return nil
+}
+
+// index updates the indexes for a changed document.
+func (e *engine) index(ctx context.Context, db driver.DB, change *driver.Change) error {
+	if strings.HasPrefix(change.ID, "_design/") || strings.HasPrefix(change.ID, "_local/") {
+		return nil
+	}
+	var doc map[string]interface{}
+	if !change.Deleted {
+		raw := change.Doc
+		if len(raw) == 0 || string(raw) == "null" {
+			d, err := db.Get(ctx, change.ID, nil)
+			if err != nil {
+				return err
+			}
+			raw, err = io.ReadAll(d.Body)
+			_ = d.Body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+	}
+	for _, idx := range e.indexes {
+		var fields map[string]interface{}
+		if doc != nil {
+			fields = indexFields(idx.def, doc)
+		}
+		if fields == nil {
+			if err := idx.idx.Delete(change.ID); err != nil {
+				return err
+			}
+			if change.Deleted {
+				idx.delCount++
+			}
+			continue
+		}
+		if err := idx.idx.Index(change.ID, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexFields returns the fields of doc to index. If def.Fields panics, the
+// document is not indexed.
+func indexFields(def Definition, doc map[string]interface{}) (fields map[string]interface{}) {
+	if def.Fields == nil {
+		fields = make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			if !strings.HasPrefix(k, "_") {
+				fields[k] = v
+			}
+		}
+		return fields
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fields = nil
+		}
+	}()
+	return def.Fields(doc)
+}
+
+// searchOptions are the parsed options of a search.
+type searchOptions struct {
+	limit       int
+	from        int
+	includeDocs bool
+	update      bool
+}
+
+func parseOptions(options map[string]interface{}) (*searchOptions, error) {
+	opts := &searchOptions{limit: defaultLimit, update: true}
+	for key, value := range options {
+		var err error
+		switch key {
+		case "limit":
+			opts.limit, err = intOption(key, value)
+			if err == nil && opts.limit > maxLimit {
+				err = badRequest("limit must be at most %d", maxLimit)
+			}
+		case "bookmark":
+			bookmark, _ := value.(string)
+			if opts.from, err = decodeBookmark(bookmark); err != nil {
+				err = badRequest("invalid bookmark: %q", bookmark)
+			}
+		case "include_docs":
+			opts.includeDocs, err = boolOption(key, value)
+		case "update":
+			opts.update, err = boolOption(key, value)
+		default:
+			err = badRequest("search option %s is not supported", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+func boolOption(key string, value interface{}) (bool, error) {
+	switch t := value.(type) {
+	case bool:
+		return t, nil
+	case string:
+		if b, err := strconv.ParseBool(t); err == nil {
+			return b, nil
+		}
+	}
+	return false, badRequest("invalid value for %s: %v", key, value)
+}
+
+func intOption(key string, value interface{}) (int, error) {
+	var i int64
+	switch t := value.(type) {
+	case int:
+		i = int64(t)
+	case int64:
+		i = t
+	case float64:
+		i = int64(t)
+	case string:
+		var err error
+		if i, err = strconv.ParseInt(t, 10, 64); err != nil {
+			return 0, badRequest("invalid value for %s: %q", key, t)
+		}
+	default:
+		return 0, badRequest("invalid value for %s: %v", key, value)
+	}
+	if i < 0 {
+		return 0, badRequest("invalid value for %s: %d", key, i)
+	}
+	return int(i), nil
+}
+
+// encodeBookmark returns an opaque bookmark for the results after the first
+// from hits.
+func encodeBookmark(from int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(from)))
+}
+
+func decodeBookmark(bookmark string) (int, error) {
+	if bookmark == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return 0, err
+	}
+	from, err := strconv.Atoi(string(raw))
+	if err == nil && from < 0 {
+		err = fmt.Errorf("negative offset")
+	}
+	return from, err
+}
+
+// search answers a search of the named index, updating it first, unless
+// disabled with update=false.
+func (e *engine) search(ctx context.Context, db driver.DB, ddoc, name, query string, options map[string]interface{}) (driver.Rows, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if opts.update {
+		err = e.update(ctx, db)
+	} else {
+		err = e.open()
+	}
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	result, err := e.indexes[ddoc+"/"+name].idx.Search(ctx, &Request{Query: query, Size: opts.limit, From: opts.from})
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rows{
+		totalRows: int64(result.Total),
+		bookmark:  encodeBookmark(opts.from + len(result.Hits)),
+		rows:      make([]*driver.Row, 0, len(result.Hits)),
+	}
+	for _, hit := range result.Hits {
+		order, err := json.Marshal([]float64{hit.Score})
+		if err != nil {
+			return nil, err
+		}
+		fields := hit.Fields
+		if fields == nil {
+			fields = map[string]interface{}{}
+		}
+		value, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		row := &driver.Row{ID: hit.ID, Key: order, Value: bytes.NewReader(value)}
+		if opts.includeDocs {
+			row.Doc = fetchDoc(ctx, db, hit.ID)
+		}
+		r.rows = append(r.rows, row)
+	}
+	return r, nil
+}
+
+// info returns statistics about the named index, after updating it.
+func (e *engine) info(ctx context.Context, db driver.DB, ddoc, name string) (*driver.SearchInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.update(ctx, db); err != nil {
+		return nil, err
+	}
+	idx := e.indexes[ddoc+"/"+name]
+	count, err := idx.idx.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	seq, _ := strconv.ParseInt(strings.SplitN(e.seq, "-", 2)[0], 10, 64)
+	info := &driver.SearchInfo{
+		Name: "_design/" + ddoc + "/" + name,
+		SearchIndex: driver.SearchIndex{
+			DocCount:     int64(count),
+			DocDelCount:  idx.delCount,
+			CommittedSeq: seq,
+			PendingSeq:   seq,
+		},
+	}
+	info.RawResponse, err = json.Marshal(map[string]interface{}{
+		"name": info.Name,
+		"search_index": map[string]interface{}{
+			"pending_seq":   info.SearchIndex.PendingSeq,
+			"doc_del_count": info.SearchIndex.DocDelCount,
+			"doc_count":     info.SearchIndex.DocCount,
+			"disk_size":     info.SearchIndex.DiskSize,
+			"committed_seq": info.SearchIndex.CommittedSeq,
+		},
+	})
+	return info, err
+}
+
+// fetchDoc returns the current version of docID, or null if it cannot be
+// read.
+func fetchDoc(ctx context.Context, db driver.DB, docID string) io.Reader {
+	doc, err := db.Get(ctx, docID, nil)
+	if err != nil {
+		return bytes.NewReader([]byte("null"))
+	}
+	defer doc.Body.Close() // nolint:errcheck
+	body, err := io.ReadAll(doc.Body)
+	if err != nil {
+		return bytes.NewReader([]byte("null"))
+	}
+	return bytes.NewReader(body)
+}
+
+// rows is a [driver.Rows] over a search result.
+type rows struct {
+	rows      []*driver.Row
+	totalRows int64
+	bookmark  string
+}
+
+var (
+	_ driver.Rows       = &rows{}
+	_ driver.Bookmarker = &rows{}
+)
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *rows) UpdateSeq() string { return "" }
+func (r *rows) Offset() int64     { return 0 }
+func (r *rows) TotalRows() int64  { return r.totalRows }
+func (r *rows) Bookmark() string  { return r.bookmark }
@@ -0,0 +1,344 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Analyze splits text into lowercase tokens, at every character which is
+// neither a letter nor a digit. It is the analyzer used by [MemoryIndex].
+func Analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// MemoryIndex is an in-memory [Index]. Each field is analyzed with
+// [Analyze]; fields of nested objects are named by their path, joined by
+// dots, such as "owner.name", and the elements of arrays are indexed under
+// the array's field name. Numbers and booleans are indexed in their JSON
+// form.
+//
+// Queries are a subset of the Lucene query syntax: a list of clauses, each of
+// which is a term, a "quoted phrase" or a prefix ending with *, optionally
+// restricted to a field with field:, as in name:bessie. Clauses prefixed by +
+// or preceded by AND must match, and those prefixed by - or preceded by NOT
+// must not; of the remaining clauses, at least one must match, unless
+// another clause is required. The query *:* matches all documents. Grouping
+// with parentheses, ranges, fuzzy queries and boosts are not supported.
+//
+// Hits are scored by a simple TF-IDF measure, and hold the indexed fields
+// with string, number or boolean values, or arrays of them.
+type MemoryIndex struct {
+	mu   sync.RWMutex
+	docs map[string]*memoryDoc
+}
+
+var _ Index = &MemoryIndex{}
+
+// memoryDoc is an indexed document.
+type memoryDoc struct {
+	// stored are the fields returned with hits.
+	stored map[string]interface{}
+	// tokens are the analyzed tokens of each field, in order.
+	tokens map[string][]string
+}
+
+// NewMemoryIndex returns a new, empty, in-memory index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{docs: map[string]*memoryDoc{}}
+}
+
+// Index adds or replaces a document. fields must encode to a JSON object.
+func (m *MemoryIndex) Index(id string, fields interface{}) error {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return badRequest("search: fields of %s must be a JSON object", id)
+	}
+	doc := &memoryDoc{
+		stored: map[string]interface{}{},
+		tokens: map[string][]string{},
+	}
+	doc.add("", obj)
+	m.mu.Lock()
+	m.docs[id] = doc
+	m.mu.Unlock()
+	return nil
+}
+
+// add indexes value as the named field.
+func (d *memoryDoc) add(name string, value interface{}) {
+	switch t := value.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			if name != "" {
+				k = name + "." + k
+			}
+			d.add(k, v)
+		}
+		return
+	case []interface{}:
+		for _, v := range t {
+			d.add(name, v)
+		}
+		return
+	case nil:
+		return
+	}
+	switch t := value.(type) {
+	case string:
+		d.tokens[name] = append(d.tokens[name], Analyze(t)...)
+	case float64:
+		d.tokens[name] = append(d.tokens[name], strconv.FormatFloat(t, 'f', -1, 64))
+	case bool:
+		d.tokens[name] = append(d.tokens[name], strconv.FormatBool(t))
+	}
+	switch prev := d.stored[name].(type) {
+	case nil:
+		d.stored[name] = value
+	case []interface{}:
+		d.stored[name] = append(prev, value)
+	default:
+		d.stored[name] = []interface{}{prev, value}
+	}
+}
+
+// Delete removes a document.
+func (m *MemoryIndex) Delete(id string) error {
+	m.mu.Lock()
+	delete(m.docs, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// DocCount returns the number of documents indexed.
+func (m *MemoryIndex) DocCount() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return uint64(len(m.docs)), nil
+}
+
+type occurrence int
+
+const (
+	should occurrence = iota
+	must
+	mustNot
+)
+
+// clause is a single clause of a query.
+type clause struct {
+	occur occurrence
+	// field is the field to match, or empty to match any field.
+	field string
+	// terms are the analyzed terms, which must appear in sequence.
+	terms []string
+	// prefix is true if the last term is a prefix.
+	prefix bool
+	// all is true for a clause which matches every document.
+	all bool
+}
+
+// parseQuery parses a query into clauses.
+func parseQuery(query string) ([]*clause, error) {
+	var clauses []*clause
+	next := should
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		var word string
+		word, rest = nextWord(rest)
+		switch word {
+		case "AND":
+			if len(clauses) > 0 && clauses[len(clauses)-1].occur == should {
+				clauses[len(clauses)-1].occur = must
+			}
+			next = must
+			continue
+		case "OR":
+			continue
+		case "NOT":
+			next = mustNot
+			continue
+		}
+		c := &clause{occur: next}
+		next = should
+		switch word[0] {
+		case '+':
+			c.occur, word = must, word[1:]
+		case '-':
+			c.occur, word = mustNot, word[1:]
+		}
+		if word == "*:*" {
+			c.all = true
+			clauses = append(clauses, c)
+			continue
+		}
+		if i := strings.IndexByte(word, ':'); i > 0 && !strings.HasPrefix(word, `"`) {
+			c.field, word = word[:i], word[i+1:]
+		}
+		if strings.HasPrefix(word, `"`) {
+			word = strings.Trim(word, `"`)
+		} else if strings.HasSuffix(word, "*") {
+			c.prefix = true
+			word = strings.TrimSuffix(word, "*")
+		}
+		c.terms = Analyze(word)
+		if len(c.terms) == 0 {
+			if c.prefix && c.field != "" {
+				// field:* matches documents with the field.
+				c.terms = []string{""}
+			} else {
+				continue
+			}
+		}
+		clauses = append(clauses, c)
+	}
+	if len(clauses) == 0 {
+		return nil, badRequest("search: query %q has no terms", query)
+	}
+	return clauses, nil
+}
+
+// nextWord returns the next whitespace-separated word of s, treating a
+// quoted phrase, possibly following a field name, as a single word.
+func nextWord(s string) (word, rest string) {
+	inQuote := false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// matches returns the number of times c matches in doc.
+func (c *clause) matches(doc *memoryDoc) int {
+	if c.all {
+		return 1
+	}
+	if c.field != "" {
+		return c.count(doc.tokens[c.field])
+	}
+	var n int
+	for _, tokens := range doc.tokens {
+		n += c.count(tokens)
+	}
+	return n
+}
+
+// count returns the number of times c.terms appear in sequence in tokens.
+func (c *clause) count(tokens []string) int {
+	var n int
+	for i := 0; i+len(c.terms) <= len(tokens); i++ {
+		if c.matchAt(tokens[i:]) {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *clause) matchAt(tokens []string) bool {
+	last := len(c.terms) - 1
+	for j, term := range c.terms {
+		if j == last && c.prefix {
+			return strings.HasPrefix(tokens[j], term)
+		}
+		if tokens[j] != term {
+			return false
+		}
+	}
+	return true
+}
+
+// Search answers a query, in the syntax described for [MemoryIndex].
+func (m *MemoryIndex) Search(_ context.Context, req *Request) (*Result, error) {
+	clauses, err := parseQuery(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := make(map[string][]int, len(m.docs))
+	df := make([]int, len(clauses))
+	for id, doc := range m.docs {
+		n := make([]int, len(clauses))
+		for i, c := range clauses {
+			if n[i] = c.matches(doc); n[i] > 0 {
+				df[i]++
+			}
+		}
+		counts[id] = n
+	}
+
+	hits := []Hit{}
+	total := float64(len(m.docs))
+docs:
+	for id, n := range counts {
+		var score float64
+		matched := false
+		for i, c := range clauses {
+			switch {
+			case c.occur == mustNot && n[i] > 0,
+				c.occur == must && n[i] == 0:
+				continue docs
+			case c.occur != mustNot && n[i] > 0:
+				matched = true
+				score += math.Sqrt(float64(n[i])) * (1 + math.Log(total/float64(df[i]+1)))
+			}
+		}
+		if !matched {
+			continue
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	result := &Result{Total: uint64(len(hits))}
+	from := req.From
+	if from > len(hits) {
+		from = len(hits)
+	}
+	hits = hits[from:]
+	if req.Size >= 0 && req.Size < len(hits) {
+		hits = hits[:req.Size]
+	}
+	for i := range hits {
+		stored := m.docs[hits[i].ID].stored
+		hits[i].Fields = make(map[string]interface{}, len(stored))
+		for k, v := range stored {
+			hits[i].Fields[k] = v
+		}
+	}
+	result.Hits = hits
+	return result, nil
+}
@@ -0,0 +1,242 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package search provides full-text search, through [kivik.DB.Search], for
+// drivers and CouchDB servers which do not support it natively.
+//
+// Search indexes are defined in Go, and the database is wrapped so that
+// searches of those indexes are answered by the wrapper:
+//
+//	names := search.Definition{
+//	    DDoc: "_design/animals",
+//	    Name: "names",
+//	    Fields: func(doc map[string]interface{}) map[string]interface{} {
+//	        return map[string]interface{}{"name": doc["name"], "class": doc["class"]}
+//	    },
+//	}
+//	client, err := kivik.NewClientFromDriverClient(search.NewClient(driverClient, names))
+//	rows := client.DB("zoo").Search(ctx, "animals", "names", "name:bessie")
+//
+// Documents are indexed from the database's changes feed, from the last
+// sequence indexed, each time an index is searched, so the underlying driver
+// must support the normal changes feed, with include_docs. Deletions are only
+// removed from indexes if the changes feed reports them.
+//
+// The documents are stored in an [Index]. By default, an in-memory index is
+// used, which is rebuilt from the start of the changes feed when the program
+// restarts, and which supports a subset of the Lucene query syntax; see
+// [MemoryIndex]. For a persistent index with a complete query language, use
+// the Bleve (https://github.com/blevesearch/bleve) index provided by the
+// separate module [github.com/go-kivik/kivik/v4/x/search/bleve]:
+//
+//	names.Open = bleve.Opener("/var/lib/zoo", "names")
+//
+// Other engines may be used by adapting them to the Index interface. Note
+// that a persistent index must also persist the last sequence indexed, which
+// this package does not do, so it is re-indexed from the start of the
+// changes feed when the program restarts.
+//
+// The search options limit (default 25, at most 200), bookmark, include_docs
+// and update are supported. Each row's ID is the ID of the matching document,
+// its key is the order of the result, as an array holding the score, and its
+// value holds the indexed fields. Searches of indexes which are not defined
+// in Go are passed to the underlying driver.
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// Index stores indexed documents, and answers queries. Its methods may be
+// called concurrently for different indexes, but are not called
+// concurrently for the same index.
+type Index interface {
+	// Index adds or replaces the document with the given ID.
+	Index(id string, fields interface{}) error
+	// Delete removes the document with the given ID, if it is indexed.
+	Delete(id string) error
+	// Search answers a query.
+	Search(ctx context.Context, req *Request) (*Result, error)
+	// DocCount returns the number of documents indexed.
+	DocCount() (uint64, error)
+}
+
+// Request is a search request.
+type Request struct {
+	// Query is the query string, in the syntax supported by the index.
+	Query string
+	// Size is the maximum number of hits to return.
+	Size int
+	// From is the number of hits to skip.
+	From int
+}
+
+// Result is the result of a search.
+type Result struct {
+	// Total is the total number of matching documents.
+	Total uint64
+	// Hits are the requested matches, in order of decreasing score.
+	Hits []Hit
+}
+
+// Hit is a document matching a search.
+type Hit struct {
+	ID     string
+	Score  float64
+	Fields map[string]interface{}
+}
+
+// Definition defines a search index.
+type Definition struct {
+	// DDoc is the design document's ID, with or without the "_design/"
+	// prefix.
+	DDoc string
+	// Name is the name of the index.
+	Name string
+	// Fields returns the fields to index for a document, or nil to leave the
+	// document out of the index. The document includes its _id and _rev
+	// fields, and must not be modified. If Fields is nil, every field of the
+	// document not starting with an underscore is indexed.
+	Fields func(doc map[string]interface{}) map[string]interface{}
+	// Open returns the index for the named database. If Open is nil,
+	// [NewMemoryIndex] is used. [New], which is not given the database's
+	// name, passes an empty name.
+	Open func(dbName string) (Index, error)
+}
+
+// definitions are index definitions, keyed by design document name, without
+// the "_design/" prefix, and index name.
+type definitions map[string]map[string]Definition
+
+func newDefinitions(defs []Definition) definitions {
+	result := definitions{}
+	for _, def := range defs {
+		ddoc := strings.TrimPrefix(def.DDoc, "_design/")
+		if result[ddoc] == nil {
+			result[ddoc] = map[string]Definition{}
+		}
+		result[ddoc][def.Name] = def
+	}
+	return result
+}
+
+// Client wraps a [driver.Client], so that its databases answer searches of
+// the indexes defined in Go.
+type Client struct {
+	driver.Client
+	passthrough.ClientFeatures
+	defs definitions
+
+	mu      sync.Mutex
+	engines map[string]*engine
+}
+
+var _ driver.Client = &Client{}
+
+// NewClient returns client wrapped so that its databases answer searches of
+// the indexes in defs. Indexes are shared by all uses of each database
+// through the returned client.
+func NewClient(client driver.Client, defs ...Definition) *Client {
+	return &Client{
+		Client:         client,
+		ClientFeatures: passthrough.ClientFeatures{Base: client},
+		defs:           newDefinitions(defs),
+		engines:        map[string]*engine{},
+	}
+}
+
+// DB returns the named database, wrapped to answer searches.
+func (c *Client) DB(name string, options map[string]interface{}) (driver.DB, error) {
+	db, err := c.Client.DB(name, options)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.engines[name]
+	if !ok {
+		e = newEngine(name, c.defs)
+		c.engines[name] = e
+	}
+	return wrapDB(db, e), nil
+}
+
+// DestroyDB destroys the named database, and discards its indexes.
+func (c *Client) DestroyDB(ctx context.Context, name string, options map[string]interface{}) error {
+	c.mu.Lock()
+	delete(c.engines, name)
+	c.mu.Unlock()
+	return c.Client.DestroyDB(ctx, name, options)
+}
+
+// DB wraps a [driver.DB], answering searches of the indexes defined in Go. In
+// addition to the methods of driver.DB, it implements the optional database
+// interfaces used by Kivik, passing calls through to the underlying driver.
+type DB struct {
+	driver.DB
+	passthrough.DBFeatures
+	engine *engine
+}
+
+var (
+	_ driver.DB       = &DB{}
+	_ driver.Searcher = &DB{}
+)
+
+func wrapDB(db driver.DB, e *engine) *DB {
+	sdb := &DB{DB: db, engine: e}
+	sdb.DBFeatures = passthrough.DBFeatures{Base: db, Self: sdb}
+	return sdb
+}
+
+// New returns db wrapped so that it answers searches of the indexes in defs.
+// To share indexes between uses of the same database, as with
+// [kivik.Client.DB], use [NewClient] instead.
+func New(db driver.DB, defs ...Definition) *DB {
+	return wrapDB(db, newEngine("", newDefinitions(defs)))
+}
+
+// Search answers searches of indexes defined in Go, and passes all others to
+// the underlying driver.
+func (db *DB) Search(ctx context.Context, ddoc, index, query string, options map[string]interface{}) (driver.Rows, error) {
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	if _, ok := db.engine.defs[ddoc][index]; !ok {
+		if searcher, ok := db.DB.(driver.Searcher); ok {
+			return searcher.Search(ctx, ddoc, index, query, options)
+		}
+		return nil, notFound(ddoc, index)
+	}
+	return db.engine.search(ctx, db.DB, ddoc, index, query, options)
+}
+
+// SearchInfo returns statistics about an index defined in Go, after updating
+// it, or passes the request to the underlying driver.
+func (db *DB) SearchInfo(ctx context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	if _, ok := db.engine.defs[ddoc][index]; !ok {
+		if searcher, ok := db.DB.(driver.Searcher); ok {
+			return searcher.SearchInfo(ctx, ddoc, index)
+		}
+		return nil, notFound(ddoc, index)
+	}
+	return db.engine.info(ctx, db.DB, ddoc, index)
+}
+
+// SearchAnalyze returns the tokens of text produced by [Analyze].
+func (db *DB) SearchAnalyze(_ context.Context, text string) ([]string, error) {
+	return Analyze(text), nil
+}
@@ -0,0 +1,405 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+)
+
+var (
+	names = Definition{
+		DDoc: "_design/zoo",
+		Name: "names",
+	}
+	owners = Definition{
+		DDoc: "zoo",
+		Name: "owners",
+		Fields: func(doc map[string]interface{}) map[string]interface{} {
+			owner := doc["owner"].(map[string]interface{})
+			return map[string]interface{}{"owner": owner["name"]}
+		},
+	}
+)
+
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.NewClientFromDriverClient(NewClient(proxydb.NewClient(memory), names, owners))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	for id, doc := range map[string]interface{}{
+		"cow":     map[string]interface{}{"name": "Bessie the Cow", "class": "mammal", "legs": 4, "owner": map[string]interface{}{"name": "Old MacDonald"}},
+		"pig":     map[string]interface{}{"name": "Wilbur", "class": "mammal", "legs": 4, "tags": []string{"pink", "radiant"}},
+		"chicken": map[string]interface{}{"name": "Henny Penny", "class": "bird", "legs": 2, "owner": map[string]interface{}{"name": "Farmer Brown"}},
+		"snake":   map[string]interface{}{"class": "reptile", "legs": 0},
+	} {
+		if _, err := db.Put(ctx, id, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Put(ctx, "_design/zoo", map[string]interface{}{"name": "Zoo"}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// searchRows returns the ID, value and document of each row of a search, as
+// JSON objects.
+func searchRows(t *testing.T, rs kivik.ResultSet) []string {
+	t.Helper()
+	defer rs.Close() // nolint:errcheck
+	var result []string
+	for rs.Next() {
+		row := struct {
+			ID    string          `json:"id"`
+			Value json.RawMessage `json:"value"`
+			Doc   json.RawMessage `json:"doc,omitempty"`
+		}{}
+		row.ID, _ = rs.ID()
+		if err := rs.ScanValue(&row.Value); err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := rs.ScanDoc(&doc); err == nil {
+			row.Doc, _ = json.Marshal(map[string]interface{}{"_id": doc["_id"], "name": doc["name"]})
+		}
+		out, _ := json.Marshal(row)
+		result = append(result, string(out))
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSearch(t *testing.T) {
+	type tt struct {
+		index   string
+		query   string
+		options kivik.Options
+		want    []string
+		status  int
+		err     string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("term", tt{
+		query: "bessie",
+		want:  []string{`{"id":"cow","value":{"class":"mammal","legs":4,"name":"Bessie the Cow","owner.name":"Old MacDonald"}}`},
+	})
+	tests.Add("field", tt{
+		query: "class:mammal",
+		want: []string{
+			`{"id":"cow","value":{"class":"mammal","legs":4,"name":"Bessie the Cow","owner.name":"Old MacDonald"}}`,
+			`{"id":"pig","value":{"class":"mammal","legs":4,"name":"Wilbur","tags":["pink","radiant"]}}`,
+		},
+	})
+	tests.Add("number", tt{
+		query: "legs:2",
+		want:  []string{`{"id":"chicken","value":{"class":"bird","legs":2,"name":"Henny Penny","owner.name":"Farmer Brown"}}`},
+	})
+	tests.Add("required and prohibited", tt{
+		query: "+class:mammal -cow",
+		want:  []string{`{"id":"pig","value":{"class":"mammal","legs":4,"name":"Wilbur","tags":["pink","radiant"]}}`},
+	})
+	tests.Add("and not", tt{
+		query: "legs:4 AND NOT name:wilbur",
+		want:  []string{`{"id":"cow","value":{"class":"mammal","legs":4,"name":"Bessie the Cow","owner.name":"Old MacDonald"}}`},
+	})
+	tests.Add("any", tt{
+		query: "wilbur OR henny",
+		want: []string{
+			`{"id":"chicken","value":{"class":"bird","legs":2,"name":"Henny Penny","owner.name":"Farmer Brown"}}`,
+			`{"id":"pig","value":{"class":"mammal","legs":4,"name":"Wilbur","tags":["pink","radiant"]}}`,
+		},
+	})
+	tests.Add("phrase", tt{
+		query: `name:"the cow"`,
+		want:  []string{`{"id":"cow","value":{"class":"mammal","legs":4,"name":"Bessie the Cow","owner.name":"Old MacDonald"}}`},
+	})
+	tests.Add("phrase out of order", tt{
+		query: `name:"cow the"`,
+		want:  nil,
+	})
+	tests.Add("prefix", tt{
+		query: "tags:rad*",
+		want:  []string{`{"id":"pig","value":{"class":"mammal","legs":4,"name":"Wilbur","tags":["pink","radiant"]}}`},
+	})
+	tests.Add("nested field", tt{
+		query: "owner.name:brown",
+		want:  []string{`{"id":"chicken","value":{"class":"bird","legs":2,"name":"Henny Penny","owner.name":"Farmer Brown"}}`},
+	})
+	tests.Add("all, with limit", tt{
+		query:   "*:*",
+		options: kivik.Param("limit", 2),
+		want: []string{
+			`{"id":"chicken","value":{"class":"bird","legs":2,"name":"Henny Penny","owner.name":"Farmer Brown"}}`,
+			`{"id":"cow","value":{"class":"mammal","legs":4,"name":"Bessie the Cow","owner.name":"Old MacDonald"}}`,
+		},
+	})
+	tests.Add("include docs", tt{
+		query:   "wilbur",
+		options: kivik.Param("include_docs", true),
+		want:    []string{`{"id":"pig","value":{"class":"mammal","legs":4,"name":"Wilbur","tags":["pink","radiant"]},"doc":{"_id":"pig","name":"Wilbur"}}`},
+	})
+	tests.Add("custom fields", tt{
+		index: "owners",
+		query: "macdonald",
+		want:  []string{`{"id":"cow","value":{"owner":"Old MacDonald"}}`},
+	})
+	tests.Add("custom fields panic", tt{
+		index: "owners",
+		query: "*:*",
+		want: []string{
+			`{"id":"chicken","value":{"owner":"Farmer Brown"}}`,
+			`{"id":"cow","value":{"owner":"Old MacDonald"}}`,
+		},
+	})
+	tests.Add("no terms", tt{
+		query:  "-",
+		status: http.StatusBadRequest,
+		err:    `search: query "-" has no terms`,
+	})
+	tests.Add("unsupported option", tt{
+		query:   "wilbur",
+		options: kivik.Param("sort", "name"),
+		status:  http.StatusBadRequest,
+		err:     "search option sort is not supported",
+	})
+	tests.Add("limit too large", tt{
+		query:   "wilbur",
+		options: kivik.Param("limit", 201),
+		status:  http.StatusBadRequest,
+		err:     "limit must be at most 200",
+	})
+	tests.Add("invalid bookmark", tt{
+		query:   "wilbur",
+		options: kivik.Param("bookmark", "!!"),
+		status:  http.StatusBadRequest,
+		err:     `invalid bookmark: "!!"`,
+	})
+	tests.Add("unknown index", tt{
+		index:  "nope",
+		query:  "wilbur",
+		status: http.StatusNotFound,
+		err:    "kivik: no search index nope in _design/zoo",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		db := newDB(t)
+		index := tt.index
+		if index == "" {
+			index = "names"
+		}
+		rs := db.Search(context.Background(), "_design/zoo", index, tt.query, tt.options)
+		if tt.err != "" {
+			testy.StatusError(t, tt.err, tt.status, rs.Err())
+		}
+		if d := testy.DiffInterface(tt.want, searchRows(t, rs)); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSearchPaging(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	var ids []string
+	var bookmark string
+	for {
+		rs := db.Search(ctx, "zoo", "names", "*:*", kivik.Params(map[string]interface{}{"limit": 3, "bookmark": bookmark}))
+		var n int
+		for rs.Next() {
+			id, _ := rs.ID()
+			ids = append(ids, id)
+			n++
+		}
+		if err := rs.Err(); err != nil {
+			t.Fatal(err)
+		}
+		meta, err := rs.Metadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.TotalRows != 4 {
+			t.Errorf("Unexpected total rows: %d", meta.TotalRows)
+		}
+		if n == 0 {
+			break
+		}
+		bookmark = meta.Bookmark
+	}
+	if d := testy.DiffInterface([]string{"chicken", "cow", "pig", "snake"}, ids); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSearchUpdates(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	search := func(options ...kivik.Options) []string {
+		t.Helper()
+		rs := db.Search(ctx, "zoo", "names", "class:mammal", options...)
+		var ids []string
+		for rs.Next() {
+			id, _ := rs.ID()
+			ids = append(ids, id)
+		}
+		if err := rs.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	if d := testy.DiffInterface([]string{"cow", "pig"}, search()); d != nil {
+		t.Fatal(d)
+	}
+
+	rev, err := db.GetRev(ctx, "cow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(ctx, "cow", rev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "goat", map[string]interface{}{"class": "mammal"}); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"cow", "pig"}, search(kivik.Param("update", false))); d != nil {
+		t.Errorf("Stale search:\n%s", d)
+	}
+	if d := testy.DiffInterface([]string{"goat", "pig"}, search()); d != nil {
+		t.Errorf("Updated search:\n%s", d)
+	}
+
+	info, err := db.SearchInfo(ctx, "zoo", "names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "_design/zoo/names" {
+		t.Errorf("Unexpected name: %s", info.Name)
+	}
+	if info.SearchIndex.DocCount != 4 || info.SearchIndex.DocDelCount != 1 {
+		t.Errorf("Unexpected index info: %+v", info.SearchIndex)
+	}
+}
+
+func TestSearchAnalyze(t *testing.T) {
+	db := newDB(t)
+	tokens, err := db.SearchAnalyze(context.Background(), "Bessie the Cow, aged 4!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bessie", "the", "cow", "aged", "4"}, tokens); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	opened := map[string]*MemoryIndex{}
+	def := Definition{
+		DDoc: "zoo",
+		Name: "names",
+		Open: func(dbName string) (Index, error) {
+			opened[dbName] = NewMemoryIndex()
+			return opened[dbName], nil
+		},
+	}
+	client, err := kivik.NewClientFromDriverClient(NewClient(proxydb.NewClient(memory), def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	if _, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Search(ctx, "zoo", "names", "bessie").Close(); err != nil {
+		t.Fatal(err)
+	}
+	idx, ok := opened["animals"]
+	if !ok {
+		t.Fatalf("Index not opened for animals: %v", opened)
+	}
+	if count, _ := idx.DocCount(); count != 1 {
+		t.Errorf("Unexpected doc count: %d", count)
+	}
+}
+
+// featureClient is a driver client which implements some of the optional
+// client interfaces, and records their use.
+type featureClient struct {
+	*mock.Client
+	calls []string
+}
+
+func (c *featureClient) Authenticate(context.Context, interface{}) error {
+	c.calls = append(c.calls, "Authenticate")
+	return nil
+}
+
+func (c *featureClient) Ping(context.Context) (bool, error) {
+	c.calls = append(c.calls, "Ping")
+	return true, nil
+}
+
+func (c *featureClient) Close() error {
+	c.calls = append(c.calls, "Close")
+	return nil
+}
+
+func TestClientPassthrough(t *testing.T) {
+	ctx := context.Background()
+	dc := &featureClient{Client: &mock.Client{}}
+	client, err := kivik.NewClientFromDriverClient(NewClient(dc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Authenticate(ctx, "creds"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"Authenticate", "Ping", "Close"}, dc.calls); d != nil {
+		t.Error(d)
+	}
+}
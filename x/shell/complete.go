@@ -0,0 +1,84 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package shell
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Complete returns the possible completions of line, which is a partial line
+// of input, as complete lines, in sorted order. Command names, database names
+// for the use, copy, diff and verify commands, and design document names for
+// the view command and for document IDs beginning with an underscore, are
+// completed.
+func (s *Shell) Complete(ctx context.Context, line string) []string {
+	line = strings.TrimLeft(line, " \t")
+	name, arg := splitWord(line)
+	if !strings.ContainsAny(line, " \t") {
+		var names []string
+		for cmd := range commands {
+			if strings.HasPrefix(cmd, name) {
+				names = append(names, cmd+" ")
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	if strings.ContainsAny(arg, " \t") {
+		return nil
+	}
+	var candidates []string
+	switch name {
+	case "use", "copy", "diff", "verify":
+		candidates, _ = s.client.AllDBs(ctx)
+	case "view":
+		if s.db == nil || strings.Contains(strings.TrimPrefix(arg, "_design/"), "/") {
+			return nil
+		}
+		for _, id := range s.designDocs(ctx) {
+			candidates = append(candidates, strings.TrimPrefix(id, "_design/")+"/")
+		}
+		arg = strings.TrimPrefix(arg, "_design/")
+	case "get", "put", "rm":
+		if s.db == nil || !strings.HasPrefix(arg, "_") {
+			return nil
+		}
+		candidates = s.designDocs(ctx)
+	default:
+		return nil
+	}
+	var lines []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, arg) {
+			lines = append(lines, name+" "+c)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// designDocs returns the IDs of the design documents in the current
+// database, or nil on error.
+func (s *Shell) designDocs(ctx context.Context) []string {
+	rs := s.db.DesignDocs(ctx)
+	defer rs.Close() // nolint:errcheck
+	var ids []string
+	for rs.Next() {
+		if id, err := rs.ID(); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
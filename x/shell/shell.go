@@ -0,0 +1,521 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package shell provides an interactive shell for exploring a Kivik
+// [kivik.Client], with a current database, pretty-printed JSON results and
+// command history. It is meant to be embedded in a small program which
+// imports the driver of choice:
+//
+//	import (
+//	    "context"
+//	    "os"
+//
+//	    _ "github.com/go-kivik/couchdb/v4"
+//	    kivik "github.com/go-kivik/kivik/v4"
+//	    "github.com/go-kivik/kivik/v4/x/shell"
+//	)
+//
+//	func main() {
+//	    client, err := kivik.New("couch", os.Args[1])
+//	    if err != nil {
+//	        panic(err)
+//	    }
+//	    sh := shell.New(client, os.Stdin, os.Stdout, shell.Config{HistoryFile: ".kivik_history"})
+//	    if err := sh.Run(context.Background()); err != nil {
+//	        panic(err)
+//	    }
+//	}
+//
+// The shell reads whole lines from its input, so line editing is left to the
+// terminal. Programs which use a line-editing library can offer tab
+// completion of commands, database names and design document names with
+// [Shell.Complete], and run each line with [Shell.Exec].
+//
+// Type "help" at the prompt for a list of commands.
+package shell
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/strutil"
+)
+
+// DefaultMaxHistory is the number of lines of history kept when
+// Config.MaxHistory is unset.
+const DefaultMaxHistory = 1000
+
+// Config configures a [Shell].
+type Config struct {
+	// HistoryFile, if set, is the file from which history is loaded when the
+	// shell starts, and to which each line entered is appended.
+	HistoryFile string
+	// MaxHistory is the maximum number of lines of history kept in memory.
+	MaxHistory int
+}
+
+// errExit is returned by Exec for the exit command.
+var errExit = errors.New("exit")
+
+// Shell is an interactive shell. It is not safe for concurrent use.
+type Shell struct {
+	client *kivik.Client
+	in     io.Reader
+	out    io.Writer
+	config Config
+
+	db      *kivik.DB
+	history []string
+}
+
+// New returns a shell for client, which reads commands from in, and writes
+// results to out.
+func New(client *kivik.Client, in io.Reader, out io.Writer, config Config) *Shell {
+	if config.MaxHistory <= 0 {
+		config.MaxHistory = DefaultMaxHistory
+	}
+	return &Shell{
+		client: client,
+		in:     in,
+		out:    out,
+		config: config,
+	}
+}
+
+// Prompt returns the prompt, which includes the current database, if any.
+func (s *Shell) Prompt() string {
+	if s.db == nil {
+		return "kivik> "
+	}
+	return "kivik:" + s.db.Name() + "> "
+}
+
+// History returns the lines entered, oldest first.
+func (s *Shell) History() []string {
+	return append([]string(nil), s.history...)
+}
+
+// Run prompts for and executes commands until the input is exhausted, or the
+// exit command is entered. Errors from individual commands are written to
+// the output, and do not stop the shell.
+func (s *Shell) Run(ctx context.Context) error {
+	if err := s.loadHistory(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(s.in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for {
+		fmt.Fprint(s.out, s.Prompt())
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+		err := s.Exec(ctx, scanner.Text())
+		if err == errExit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %s\n", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Shell) loadHistory() error {
+	if s.config.HistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.config.HistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			s.addHistory(line)
+		}
+	}
+	return nil
+}
+
+func (s *Shell) addHistory(line string) {
+	s.history = append(s.history, line)
+	if over := len(s.history) - s.config.MaxHistory; over > 0 {
+		s.history = s.history[over:]
+	}
+}
+
+func (s *Shell) recordHistory(line string) error {
+	s.addHistory(line)
+	if s.config.HistoryFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(s.config.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// command is a shell command.
+type command struct {
+	usage string
+	help  string
+	// needDB is true for commands which require a current database.
+	needDB bool
+	run    func(s *Shell, ctx context.Context, args string) error
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"help":    {usage: "help", help: "show this help", run: (*Shell).help},
+		"exit":    {usage: "exit", help: "leave the shell", run: func(*Shell, context.Context, string) error { return errExit }},
+		"history": {usage: "history", help: "show command history", run: (*Shell).showHistory},
+		"dbs":     {usage: "dbs", help: "list databases", run: (*Shell).dbs},
+		"use":     {usage: "use <db>", help: "select the current database", run: (*Shell).use},
+		"create":  {usage: "create <db>", help: "create a database", run: (*Shell).create},
+		"info":    {usage: "info", help: "show statistics of the current database", needDB: true, run: (*Shell).info},
+		"ls":      {usage: "ls [limit]", help: "list document IDs", needDB: true, run: (*Shell).ls},
+		"ddocs":   {usage: "ddocs", help: "list design documents", needDB: true, run: (*Shell).ddocs},
+		"get":     {usage: "get <id>", help: "show a document", needDB: true, run: (*Shell).get},
+		"put":     {usage: "put <id> <json>", help: "store a document", needDB: true, run: (*Shell).put},
+		"rm":      {usage: "rm <id> [rev]", help: "delete a document, by default its current revision", needDB: true, run: (*Shell).rm},
+		"find":    {usage: "find <json>", help: "run a Mango query", needDB: true, run: (*Shell).find},
+		"view":    {usage: "view <ddoc>/<view> [json options]", help: "query a view", needDB: true, run: (*Shell).view},
+		"diff":    {usage: "diff <db> [content]", help: "compare the current database with another", needDB: true, run: (*Shell).diff},
+		"copy":    {usage: "copy <db> [json selector]", help: "copy documents from the current database to another", needDB: true, run: (*Shell).copy},
+		"verify":  {usage: "verify <db>", help: "check the replication of the current database to another", needDB: true, run: (*Shell).verify},
+	}
+	commands["quit"] = commands["exit"]
+}
+
+// Exec executes a single line of input, and records it in the history. It
+// returns an error if the command fails.
+func (s *Shell) Exec(ctx context.Context, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	if err := s.recordHistory(line); err != nil {
+		return err
+	}
+	name, args := splitWord(line)
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q; type \"help\" for a list of commands", name)
+	}
+	if cmd.needDB && s.db == nil {
+		return errors.New("no database selected; use \"use <db>\"")
+	}
+	return cmd.run(s, ctx, args)
+}
+
+// splitWord splits the first word from s.
+func splitWord(s string) (word, rest string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+func (s *Shell) help(context.Context, string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		if name != "quit" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %-36s %s\n", commands[name].usage, commands[name].help)
+	}
+	return nil
+}
+
+func (s *Shell) showHistory(context.Context, string) error {
+	for i, line := range s.history {
+		fmt.Fprintf(s.out, "%5d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+// printJSON writes v to the output as indented JSON.
+func (s *Shell) printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "%s\n", out)
+	return err
+}
+
+func (s *Shell) dbs(ctx context.Context, _ string) error {
+	dbs, err := s.client.AllDBs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, db := range dbs {
+		fmt.Fprintln(s.out, db)
+	}
+	return nil
+}
+
+func (s *Shell) use(ctx context.Context, args string) error {
+	if args == "" {
+		return errors.New("usage: use <db>")
+	}
+	exists, err := s.client.DBExists(ctx, args)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("database %q does not exist", args)
+	}
+	s.db = s.client.DB(args)
+	return nil
+}
+
+func (s *Shell) create(ctx context.Context, args string) error {
+	if args == "" {
+		return errors.New("usage: create <db>")
+	}
+	return s.client.CreateDB(ctx, args)
+}
+
+func (s *Shell) info(ctx context.Context, _ string) error {
+	stats, err := s.db.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	return s.printJSON(stats)
+}
+
+func (s *Shell) ls(ctx context.Context, args string) error {
+	opts := kivik.Options{}
+	if args != "" {
+		limit, err := strconv.Atoi(args)
+		if err != nil {
+			return errors.New("usage: ls [limit]")
+		}
+		opts["limit"] = limit
+	}
+	return s.printIDs(s.db.AllDocs(ctx, opts))
+}
+
+func (s *Shell) ddocs(ctx context.Context, _ string) error {
+	return s.printIDs(s.db.DesignDocs(ctx))
+}
+
+func (s *Shell) printIDs(rs kivik.ResultSet) error {
+	defer rs.Close() // nolint:errcheck
+	for rs.Next() {
+		id, err := rs.ID()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, id)
+	}
+	return rs.Err()
+}
+
+func (s *Shell) get(ctx context.Context, args string) error {
+	if args == "" {
+		return errors.New("usage: get <id>")
+	}
+	var doc json.RawMessage
+	if err := s.db.Get(ctx, args).ScanDoc(&doc); err != nil {
+		return err
+	}
+	return s.printJSON(doc)
+}
+
+func (s *Shell) put(ctx context.Context, args string) error {
+	id, body := splitWord(args)
+	if id == "" || body == "" {
+		return errors.New("usage: put <id> <json>")
+	}
+	if !json.Valid([]byte(body)) {
+		return errors.New("invalid JSON")
+	}
+	rev, err := s.db.Put(ctx, id, json.RawMessage(body))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, rev)
+	return nil
+}
+
+func (s *Shell) rm(ctx context.Context, args string) error {
+	id, rev := splitWord(args)
+	if id == "" {
+		return errors.New("usage: rm <id> [rev]")
+	}
+	if rev == "" {
+		var err error
+		if rev, err = s.db.GetRev(ctx, id); err != nil {
+			return err
+		}
+	}
+	newRev, err := s.db.Delete(ctx, id, rev)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, newRev)
+	return nil
+}
+
+func (s *Shell) find(ctx context.Context, args string) error {
+	if !json.Valid([]byte(args)) {
+		return errors.New("usage: find <json>")
+	}
+	rs := s.db.Find(ctx, json.RawMessage(args))
+	defer rs.Close() // nolint:errcheck
+	for rs.Next() {
+		var doc json.RawMessage
+		if err := rs.ScanDoc(&doc); err != nil {
+			return err
+		}
+		if err := s.printJSON(doc); err != nil {
+			return err
+		}
+	}
+	return rs.Err()
+}
+
+func (s *Shell) view(ctx context.Context, args string) error {
+	name, rawOpts := splitWord(args)
+	ddoc, view, ok := strutil.Cut(strings.TrimPrefix(name, "_design/"), "/")
+	if !ok || ddoc == "" || view == "" {
+		return errors.New("usage: view <ddoc>/<view> [json options]")
+	}
+	opts := kivik.Options{}
+	if rawOpts != "" {
+		if err := json.Unmarshal([]byte(rawOpts), &opts); err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	rs := s.db.Query(ctx, ddoc, view, opts)
+	defer rs.Close() // nolint:errcheck
+	for rs.Next() {
+		row := struct {
+			ID    string          `json:"id,omitempty"`
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}{}
+		row.ID, _ = rs.ID()
+		if err := rs.ScanKey(&row.Key); err != nil {
+			return err
+		}
+		if err := rs.ScanValue(&row.Value); err != nil {
+			return err
+		}
+		out, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "%s\n", out)
+	}
+	return rs.Err()
+}
+
+func (s *Shell) diff(ctx context.Context, args string) error {
+	name, mode := splitWord(args)
+	if name == "" || (mode != "" && mode != "content") {
+		return errors.New("usage: diff <db> [content]")
+	}
+	var opts kivik.Options
+	if mode == "content" {
+		opts = kivik.WithCompareContent()
+	}
+	summary, err := kivik.Diff(ctx, s.db, s.client.DB(name), func(d *kivik.DocDiff) error {
+		fmt.Fprintf(s.out, "%s\t%s\t%s\t%s\n", d.Kind, d.ID, d.SourceRev, d.TargetRev)
+		return nil
+	}, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%d compared, %d identical, %d missing in %s, %d missing in %s, %d rev mismatches, %d content mismatches\n",
+		summary.Compared, summary.Identical, summary.MissingInTarget, name, summary.MissingInSource, s.db.Name(),
+		summary.RevMismatches, summary.ContentMismatches)
+	return nil
+}
+
+func (s *Shell) verify(ctx context.Context, args string) error {
+	if args == "" || strings.ContainsAny(args, " \t") {
+		return errors.New("usage: verify <db>")
+	}
+	target := s.client.DB(args)
+	report, err := kivik.VerifyReplication(ctx, s.db, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "docs: %d source, %d target\n", report.SourceDocCount, report.TargetDocCount)
+	fmt.Fprintf(s.out, "deleted: %d source, %d target\n", report.SourceDeletedCount, report.TargetDeletedCount)
+	fmt.Fprintf(s.out, "sampled: %d, missing: %d, rev mismatches: %d\n", report.Sampled, len(report.Missing), len(report.RevMismatches))
+	for _, id := range report.Missing {
+		fmt.Fprintf(s.out, "missing\t%s\n", id)
+	}
+	for _, id := range report.RevMismatches {
+		fmt.Fprintf(s.out, "rev mismatch\t%s\n", id)
+	}
+	lag, err := kivik.ReplicationLag(ctx, s.db, target)
+	if err != nil {
+		return err
+	}
+	pending := strconv.FormatInt(lag.Pending, 10)
+	if lag.Truncated {
+		pending += "+"
+	}
+	fmt.Fprintf(s.out, "lag: %s changes pending\n", pending)
+	if report.OK() && lag.Pending == 0 {
+		fmt.Fprintln(s.out, "OK")
+	}
+	return nil
+}
+
+func (s *Shell) copy(ctx context.Context, args string) error {
+	name, selector := splitWord(args)
+	if name == "" || (selector != "" && !json.Valid([]byte(selector))) {
+		return errors.New("usage: copy <db> [json selector]")
+	}
+	var opts kivik.Options
+	if selector != "" {
+		opts = kivik.WithSelector(selector)
+	}
+	progress, err := kivik.Copy(ctx, s.db, s.client.DB(name), opts, kivik.WithProgress(func(p kivik.CopyProgress) {
+		fmt.Fprintf(s.out, "%d read, %d written, %d failed\n", p.Read, p.Written, p.Failed)
+	}))
+	if progress != nil && progress.Read == 0 && err == nil {
+		fmt.Fprintln(s.out, "no documents to copy")
+	}
+	return err
+}
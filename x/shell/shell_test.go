@@ -0,0 +1,230 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newClient(t *testing.T) *kivik.Client {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, name := range []string{"animals", "plants"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := client.DB("animals")
+	if _, err := db.Put(ctx, "_design/zoo", map[string]interface{}{"language": "javascript"}); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRun(t *testing.T) {
+	client := newClient(t)
+	in := strings.Join([]string{
+		"dbs",
+		"get cow",
+		"use nothing",
+		"use animals",
+		`put cow {"name":"Bessie"}`,
+		"get cow",
+		"ls",
+		"ddocs",
+		`find {"selector":{"name":"Bessie"}}`,
+		"rm cow",
+		"bogus",
+		"exit",
+		"dbs",
+	}, "\n")
+	out := &bytes.Buffer{}
+	history := filepath.Join(t.TempDir(), "history")
+	sh := New(client, strings.NewReader(in), out, Config{HistoryFile: history})
+	if err := sh.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"kivik> animals\nplants\n",
+		"error: no database selected",
+		`error: database "nothing" does not exist`,
+		"kivik:animals> 1-",
+		"{\n  \"_id\": \"cow\",\n",
+		"\"name\": \"Bessie\"\n}\n",
+		"kivik:animals> _design/zoo\ncow\n",
+		"kivik:animals> _design/zoo\nkivik:animals> ",
+		"kivik:animals> 2-",
+		`error: unknown command "bogus"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.HasSuffix(got, "plants\n") {
+		t.Error("Expected commands after exit to be ignored")
+	}
+	saved, err := os.ReadFile(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(saved)), "\n"); len(lines) != 12 {
+		t.Errorf("Expected 12 lines of history, got %d", len(lines))
+	}
+
+	sh = New(client, strings.NewReader("history\n"), &bytes.Buffer{}, Config{HistoryFile: history, MaxHistory: 3})
+	if err := sh.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bogus", "exit", "history"}, sh.History()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	sh := New(client, nil, nil, Config{})
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "c", want: []string{"copy ", "create "}},
+		{line: "d", want: []string{"dbs ", "ddocs ", "diff "}},
+		{line: "diff p", want: []string{"diff plants"}},
+		{line: "use ", want: []string{"use animals", "use plants"}},
+		{line: "use p", want: []string{"use plants"}},
+		{line: "get _", want: nil},
+	}
+	for _, test := range tests {
+		if d := testy.DiffInterface(test.want, sh.Complete(ctx, test.line)); d != nil {
+			t.Errorf("%q: %s", test.line, d)
+		}
+	}
+	if err := sh.Exec(ctx, "use animals"); err != nil {
+		t.Fatal(err)
+	}
+	tests = []struct {
+		line string
+		want []string
+	}{
+		{line: "get _", want: []string{"get _design/zoo"}},
+		{line: "get c", want: nil},
+		{line: "view z", want: []string{"view zoo/"}},
+		{line: "view _design/", want: []string{"view zoo/"}},
+		{line: "view zoo/", want: nil},
+	}
+	for _, test := range tests {
+		if d := testy.DiffInterface(test.want, sh.Complete(ctx, test.line)); d != nil {
+			t.Errorf("%q: %s", test.line, d)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	if _, err := client.DB("plants").Put(ctx, "fern", map[string]interface{}{"green": true}); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	sh := New(client, nil, out, Config{})
+	for _, line := range []string{"use animals", "diff plants"} {
+		if err := sh.Exec(ctx, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "missing in target\t_design/zoo\t1-"
+	if got := out.String(); !strings.Contains(got, want) || !strings.Contains(got, "missing in source\tfern\t\t1-") ||
+		!strings.HasSuffix(got, "0 compared, 0 identical, 1 missing in plants, 1 missing in animals, 0 rev mismatches, 0 content mismatches\n") {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+	err := sh.Exec(ctx, "diff plants bogus")
+	testy.Error(t, "usage: diff <db> [content]", err)
+}
+
+func TestVerify(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	if _, err := client.DB("animals").Put(ctx, "cow", map[string]interface{}{"name": "Bessie"}); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	sh := New(client, nil, out, Config{})
+	for _, line := range []string{"use animals", "verify plants"} {
+		if err := sh.Exec(ctx, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "docs: 2 source, 0 target\ndeleted: 0 source, 0 target\nsampled: 2, missing: 2, rev mismatches: 0\n" +
+		"missing\t_design/zoo\nmissing\tcow\nlag: 2 changes pending\n"
+	if got := out.String(); got != want {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	animals := client.DB("animals")
+	rev, err := animals.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := animals.PutAttachment(ctx, "cow", &kivik.Attachment{
+		Filename:    "moo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("moo")),
+	}, kivik.Rev(rev)); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	sh := New(client, nil, out, Config{})
+	for _, line := range []string{"use animals", "copy plants", "use plants", "get cow", "copy animals {\"name\":\"Bessie\"}"} {
+		if err := sh.Exec(ctx, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := out.String()
+	for _, want := range []string{"2 read, 2 written, 0 failed\n", "\"name\": \"Bessie\"", "1 read, 1 written, 0 failed\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output does not contain %q:\n%s", want, got)
+		}
+	}
+	att, err := client.DB("plants").GetAttachment(ctx, "cow", "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if string(content) != "moo" {
+		t.Errorf("Unexpected attachment content: %q", content)
+	}
+	err = sh.Exec(ctx, "copy animals {")
+	testy.Error(t, "usage: copy <db> [json selector]", err)
+}
@@ -0,0 +1,157 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+func (d *db) AllDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(ctx, options, `id NOT GLOB '_local/*'`)
+}
+
+func (d *db) DesignDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(ctx, options, `id GLOB '_design/*'`)
+}
+
+func (d *db) LocalDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(ctx, options, `id GLOB '_local/*'`)
+}
+
+// allDocs implements the _all_docs view, and its variants, over the
+// documents which satisfy the SQL condition include.
+func (d *db) allDocs(ctx context.Context, options map[string]interface{}, include string) (driver.Rows, error) {
+	q := d.client.db
+	if err := d.checkDB(ctx, q); err != nil {
+		return nil, err
+	}
+	descending := driverutil.BoolOption(options, "descending")
+	startKey, hasStart := driverutil.KeyOption(options, "startkey", "start_key")
+	endKey, hasEnd := driverutil.KeyOption(options, "endkey", "end_key")
+	inclusiveEnd := true
+	if _, ok := options["inclusive_end"]; ok {
+		inclusiveEnd = driverutil.BoolOption(options, "inclusive_end")
+	}
+	if key, ok := driverutil.KeyOption(options, "key"); ok {
+		startKey, endKey = key, key
+		hasStart, hasEnd, inclusiveEnd = true, true, true
+	}
+	before, after, order := "<", ">", "ASC"
+	if descending {
+		before, after, order = ">", "<", "DESC"
+	}
+	if !inclusiveEnd {
+		after += "="
+	}
+	live := winners + ` SELECT id, rev, rev_id FROM winners WHERE pos = 1 AND NOT deleted AND ` + include
+
+	var total, offset int64
+	var precedingArgs []interface{}
+	preceding := `SELECT 0`
+	if hasStart {
+		preceding = `SELECT COUNT(*) FROM live WHERE id ` + before + ` ?`
+		precedingArgs = []interface{}{startKey}
+	}
+	args := append([]interface{}{d.name}, precedingArgs...)
+	if err := q.QueryRowContext(ctx, `WITH live AS (`+live+`)
+		SELECT (SELECT COUNT(*) FROM live), (`+preceding+`)`, args...).Scan(&total, &offset); err != nil {
+		return nil, err
+	}
+
+	query := live
+	args = []interface{}{d.name}
+	if hasStart {
+		query += ` AND NOT id ` + before + ` ?`
+		args = append(args, startKey)
+	}
+	if hasEnd {
+		query += ` AND NOT id ` + after + ` ?`
+		args = append(args, endKey)
+	}
+	query += ` ORDER BY id ` + order
+	limit, ok := driverutil.IntOption(options, "limit")
+	if !ok || limit < 0 {
+		limit = -1
+	}
+	skip, _ := driverutil.IntOption(options, "skip")
+	if skip < 0 {
+		skip = 0
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, skip)
+
+	type docRev struct {
+		id string
+		revision
+	}
+	var found []docRev
+	dbRows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close() // nolint:errcheck
+	for dbRows.Next() {
+		var r docRev
+		if err := dbRows.Scan(&r.id, &r.rev, &r.revID); err != nil {
+			return nil, err
+		}
+		found = append(found, r)
+	}
+	if err := dbRows.Err(); err != nil {
+		return nil, err
+	}
+	_ = dbRows.Close()
+
+	offset += skip
+	if offset > total {
+		offset = total
+	}
+	includeDocs := driverutil.BoolOption(options, "include_docs")
+	result := make([]*driver.Row, 0, len(found))
+	for _, r := range found {
+		key, _ := json.Marshal(r.id)
+		value, _ := json.Marshal(map[string]string{"rev": r.String()})
+		row := &driver.Row{
+			ID:    r.id,
+			Key:   key,
+			Value: bytes.NewReader(value),
+		}
+		if includeDocs {
+			stored, err := getRev(ctx, q, d.name, r.id, r.revision)
+			if err != nil {
+				return nil, err
+			}
+			doc, err := d.render(ctx, q, r.id, stored, false)
+			if err != nil {
+				return nil, err
+			}
+			body, _ := json.Marshal(doc)
+			row.Doc = bytes.NewReader(body)
+		}
+		result = append(result, row)
+	}
+	var updateSeq string
+	if driverutil.BoolOption(options, "update_seq") {
+		stats, err := d.Stats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		updateSeq = stats.UpdateSeq
+	}
+	return driverutil.NewRows(result, offset, total, updateSeq), nil
+}
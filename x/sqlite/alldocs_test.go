@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAllDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"d", "b", "a", "c", "e", "_design/x", "_local/y"} {
+		if _, err := db.Put(ctx, id, map[string]string{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rev, _ := db.GetRev(ctx, "e")
+	if _, err := db.Delete(ctx, "e", rev); err != nil {
+		t.Fatal(err)
+	}
+
+	type tst struct {
+		options kivik.Options
+		ids     []string
+		offset  int64
+	}
+	tests := testy.NewTable()
+	tests.Add("default", tst{ids: []string{"_design/x", "a", "b", "c", "d"}})
+	tests.Add("range", tst{
+		options: kivik.Options{"startkey": "b", "endkey": "c"},
+		ids:     []string{"b", "c"},
+		offset:  2,
+	})
+	tests.Add("exclusive end", tst{
+		options: kivik.Options{"startkey": "b", "endkey": "c", "inclusive_end": false},
+		ids:     []string{"b"},
+		offset:  2,
+	})
+	tests.Add("descending with limit", tst{
+		options: kivik.Options{"descending": true, "limit": 2},
+		ids:     []string{"d", "c"},
+	})
+	tests.Add("descending range", tst{
+		options: kivik.Options{"descending": true, "startkey": "c", "endkey": "b"},
+		ids:     []string{"c", "b"},
+		offset:  1,
+	})
+	tests.Add("skip", tst{
+		options: kivik.Options{"skip": 3},
+		ids:     []string{"c", "d"},
+		offset:  3,
+	})
+	tests.Add("key", tst{
+		options: kivik.Options{"key": "c"},
+		ids:     []string{"c"},
+		offset:  3,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		rows := db.AllDocs(ctx, test.options)
+		var ids []string
+		for rows.Next() {
+			id, _ := rows.ID()
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(test.ids, ids); d != nil {
+			t.Error(d)
+		}
+		meta, _ := rows.Metadata()
+		if meta.TotalRows != 5 || meta.Offset != test.offset {
+			t.Errorf("Unexpected metadata: %+v", meta)
+		}
+	})
+
+	t.Run("include_docs", func(t *testing.T) {
+		rows := db.AllDocs(ctx, kivik.Options{"include_docs": true, "startkey": "a", "limit": 1})
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["id"] != "a" {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		_ = rows.Close()
+	})
+
+	t.Run("design and local docs", func(t *testing.T) {
+		for name, rows := range map[string]kivik.ResultSet{
+			"_design/x": db.DesignDocs(ctx),
+			"_local/y":  db.LocalDocs(ctx),
+		} {
+			var ids []string
+			for rows.Next() {
+				id, _ := rows.ID()
+				ids = append(ids, id)
+			}
+			if d := testy.DiffInterface([]string{name}, ids); d != nil {
+				t.Error(d)
+			}
+		}
+	})
+}
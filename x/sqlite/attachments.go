@@ -0,0 +1,190 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+// attachment is the metadata of an attachment, as stored in the attachments
+// column of kivik_revs. The content is stored in kivik_attachments, keyed by
+// digest, so that it is shared between revisions.
+type attachment struct {
+	ContentType string `json:"content_type"`
+	Digest      string `json:"digest"`
+	Length      int64  `json:"length"`
+	RevPos      int64  `json:"revpos"`
+}
+
+func (a *attachment) stub() map[string]interface{} {
+	return map[string]interface{}{
+		"content_type": a.ContentType,
+		"digest":       a.Digest,
+		"length":       a.Length,
+		"revpos":       a.RevPos,
+		"stub":         true,
+	}
+}
+
+// storeAttachment stores content, and returns its metadata.
+func storeAttachment(ctx context.Context, q querier, dbName, contentType string, content []byte) (*attachment, error) {
+	sum := md5.Sum(content)
+	digest := "md5-" + base64.StdEncoding.EncodeToString(sum[:])
+	if _, err := q.ExecContext(ctx, `INSERT INTO kivik_attachments (db, digest, data)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, dbName, digest, content); err != nil {
+		return nil, err
+	}
+	return &attachment{
+		ContentType: contentType,
+		Digest:      digest,
+		Length:      int64(len(content)),
+	}, nil
+}
+
+func loadAttachment(ctx context.Context, q querier, dbName, digest string) ([]byte, error) {
+	var data []byte
+	err := q.QueryRowContext(ctx, `SELECT data FROM kivik_attachments WHERE db = ? AND digest = ?`, dbName, digest).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errMissing
+	}
+	return data, err
+}
+
+// parseAttachments stores the attachments of a new revision, from the
+// _attachments field of a document. Stubs are copied from prev.
+func parseAttachments(ctx context.Context, q querier, dbName string, atts map[string]json.RawMessage, prev *storedRev) (map[string]*attachment, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*attachment, len(atts))
+	for name, raw := range atts {
+		var att struct {
+			ContentType string `json:"content_type"`
+			Data        []byte `json:"data"`
+			Stub        bool   `json:"stub"`
+		}
+		if err := json.Unmarshal(raw, &att); err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+		if att.Stub {
+			if prev == nil || prev.attachments[name] == nil {
+				return nil, &kivik.Error{Status: http.StatusPreconditionFailed, Message: "invalid attachment stub for " + name}
+			}
+			result[name] = prev.attachments[name]
+			continue
+		}
+		stored, err := storeAttachment(ctx, q, dbName, att.ContentType, att.Data)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = stored
+	}
+	return result, nil
+}
+
+// updateAttachments stores a new revision of docID, with its attachments
+// modified by fn. If fn returns an error, no update is made.
+func (d *db) updateAttachments(ctx context.Context, docID, rev string, fn func(tx *sql.Tx, atts map[string]*attachment) error) (string, error) {
+	var newRev string
+	err := d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		r := &storedRev{
+			body:        json.RawMessage("{}"),
+			attachments: map[string]*attachment{},
+		}
+		current, err := leaves(ctx, tx, d.name, docID)
+		if err != nil {
+			return err
+		}
+		if len(current) > 0 && !current[0].deleted {
+			r.body = current[0].body
+			for name, att := range current[0].attachments {
+				r.attachments[name] = att
+			}
+		}
+		if err := fn(tx, r.attachments); err != nil {
+			return err
+		}
+		if err := update(ctx, tx, d.name, docID, rev, r); err != nil {
+			return err
+		}
+		newRev = r.String()
+		return nil
+	})
+	return newRev, err
+}
+
+func (d *db) PutAttachment(ctx context.Context, docID string, att *driver.Attachment, options map[string]interface{}) (string, error) {
+	content, err := io.ReadAll(att.Content)
+	if err != nil {
+		return "", err
+	}
+	return d.updateAttachments(ctx, docID, driverutil.StringOption(options, "rev"), func(tx *sql.Tx, atts map[string]*attachment) error {
+		stored, err := storeAttachment(ctx, tx, d.name, att.ContentType, content)
+		if err != nil {
+			return err
+		}
+		atts[att.Filename] = stored
+		return nil
+	})
+}
+
+func (d *db) DeleteAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (string, error) {
+	return d.updateAttachments(ctx, docID, driverutil.StringOption(options, "rev"), func(_ *sql.Tx, atts map[string]*attachment) error {
+		if _, ok := atts[filename]; !ok {
+			return errMissing
+		}
+		delete(atts, filename)
+		return nil
+	})
+}
+
+func (d *db) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	if err := d.checkDB(ctx, d.client.db); err != nil {
+		return nil, err
+	}
+	r, err := d.revision(ctx, d.client.db, docID, driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return nil, err
+	}
+	att, ok := r.attachments[filename]
+	if !ok {
+		return nil, errMissing
+	}
+	data, err := loadAttachment(ctx, d.client.db, d.name, att.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: att.ContentType,
+		Content:     io.NopCloser(bytes.NewReader(data)),
+		Size:        att.Length,
+		Digest:      att.Digest,
+		RevPos:      att.RevPos,
+	}, nil
+}
@@ -0,0 +1,154 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+// Changes returns the normal changes feed, supporting the since, limit,
+// descending, include_docs, style and filter options. Of the filters, only the
+// built-in _doc_ids, _selector and _design filters are supported. Continuous
+// and longpoll feeds are not supported.
+func (d *db) Changes(ctx context.Context, options map[string]interface{}) (driver.Changes, error) {
+	switch feed := driverutil.StringOption(options, "feed"); feed {
+	case "", "normal":
+	default:
+		return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: " + feed + " changes feed not supported by the sqlite driver"}
+	}
+	filter, err := driverutil.ChangesFilter(options, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	q := d.client.db
+	stats, err := d.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var since int64
+	switch s := options["since"].(type) {
+	case string:
+		if s == "now" {
+			since, _ = strconv.ParseInt(stats.UpdateSeq, 10, 64)
+		} else {
+			since, _ = strconv.ParseInt(s, 10, 64)
+		}
+	default:
+		since, _ = driverutil.IntOption(options, "since")
+	}
+	order := "ASC"
+	if driverutil.BoolOption(options, "descending") {
+		order = "DESC"
+	}
+	limit, ok := driverutil.IntOption(options, "limit")
+	if !ok || limit <= 0 {
+		limit = -1
+	}
+	// When filtering, the limit is applied to the filtered changes below.
+	queryLimit := limit
+	if driverutil.StringOption(options, "filter") != "" {
+		queryLimit = -1
+	}
+	type entry struct {
+		id  string
+		seq int64
+	}
+	var entries []entry
+	dbRows, err := q.QueryContext(ctx, `
+		SELECT id, MAX(seq) AS last
+		FROM kivik_revs
+		WHERE db = ? AND id NOT GLOB '_local/*'
+		GROUP BY id
+		HAVING last > ?
+		ORDER BY last `+order+`
+		LIMIT ?`, d.name, since, queryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close() // nolint:errcheck
+	for dbRows.Next() {
+		var e entry
+		if err := dbRows.Scan(&e.id, &e.seq); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := dbRows.Err(); err != nil {
+		return nil, err
+	}
+	_ = dbRows.Close()
+
+	includeDocs := driverutil.BoolOption(options, "include_docs")
+	allLeaves := driverutil.StringOption(options, "style") == "all_docs"
+	result := make([]*driver.Change, 0, len(entries))
+	lastSeq := strconv.FormatInt(since, 10)
+	for _, e := range entries {
+		if limit >= 0 && int64(len(result)) >= limit {
+			break
+		}
+		current, err := leaves(ctx, q, d.name, e.id)
+		if err != nil {
+			return nil, err
+		}
+		winner := current[0]
+		ok, err := filter(e.id, func() (map[string]interface{}, error) {
+			if winner.deleted {
+				return nil, nil
+			}
+			doc, err := d.render(ctx, q, e.id, winner, false)
+			if err != nil {
+				return nil, err
+			}
+			raw, _ := json.Marshal(doc)
+			var body map[string]interface{}
+			err = json.Unmarshal(raw, &body)
+			return body, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		change := &driver.Change{
+			ID:      e.id,
+			Seq:     strconv.FormatInt(e.seq, 10),
+			Deleted: winner.deleted,
+			Changes: driver.ChangedRevs{winner.String()},
+		}
+		if allLeaves {
+			for _, leaf := range current[1:] {
+				change.Changes = append(change.Changes, leaf.String())
+			}
+		}
+		if includeDocs {
+			doc, err := d.render(ctx, q, e.id, winner, false)
+			if err != nil {
+				return nil, err
+			}
+			if change.Doc, err = json.Marshal(doc); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, change)
+		lastSeq = change.Seq
+	}
+	return driverutil.NewChanges(result, lastSeq), nil
+}
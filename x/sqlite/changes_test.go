@@ -0,0 +1,145 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"b", "a", "c", "_local/x"} {
+		if _, err := db.Put(ctx, id, map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rev, _ := db.GetRev(ctx, "b")
+	if _, err := db.Delete(ctx, "b", rev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "c", map[string]interface{}{"_rev": "1-conflict"}, kivik.Options{"new_edits": false}); err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		ID      string
+		Deleted bool
+		Revs    int
+	}
+	collect := func(options kivik.Options) ([]change, string) {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var got []change
+		for feed.Next() {
+			got = append(got, change{ID: feed.ID(), Deleted: feed.Deleted(), Revs: len(feed.Changes())})
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := feed.Metadata()
+		return got, meta.LastSeq
+	}
+
+	got, lastSeq := collect(nil)
+	want := []change{{ID: "a", Revs: 1}, {ID: "b", Deleted: true, Revs: 1}, {ID: "c", Revs: 1}}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	got, _ = collect(kivik.Options{"style": "all_docs", "descending": true, "limit": 1})
+	if d := testy.DiffInterface([]change{{ID: "c", Revs: 2}}, got); d != nil {
+		t.Error(d)
+	}
+	if got, _ = collect(kivik.Options{"since": lastSeq}); len(got) != 0 {
+		t.Errorf("Expected no further changes, got %v", got)
+	}
+	if _, err := db.Put(ctx, "d", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = collect(kivik.Options{"since": lastSeq})
+	if d := testy.DiffInterface([]change{{ID: "d", Revs: 1}}, got); d != nil {
+		t.Error(d)
+	}
+	if got, _ = collect(kivik.Options{"since": "now"}); len(got) != 0 {
+		t.Errorf("Expected no changes since now, got %v", got)
+	}
+
+	err := db.Changes(ctx, kivik.Options{"feed": "continuous"}).Err()
+	testutil.CheckError(t, "kivik: continuous changes feed not supported by the sqlite driver", http.StatusNotImplemented, err)
+}
+
+func TestChangesFilters(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	_, _ = db.Put(ctx, "a", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "b", map[string]string{"type": "horse"})
+	_, _ = db.Put(ctx, "c", map[string]string{"type": "cow"})
+	_, _ = db.Put(ctx, "_design/foo", map[string]string{})
+
+	ids := func(t *testing.T, options kivik.Options) []string {
+		t.Helper()
+		feed := db.Changes(ctx, options)
+		var ids []string
+		for feed.Next() {
+			ids = append(ids, feed.ID())
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	tests := []struct {
+		name    string
+		options kivik.Options
+		want    []string
+	}{
+		{
+			name:    "doc ids",
+			options: kivik.DocIDsFilter("c", "a", "z"),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "selector",
+			options: kivik.SelectorFilter(map[string]interface{}{"type": "cow"}),
+			want:    []string{"a", "c"},
+		},
+		{
+			name:    "design",
+			options: kivik.DesignFilter(),
+			want:    []string{"_design/foo"},
+		},
+		{
+			name:    "limit",
+			options: kivik.Options{"doc_ids": []string{"b", "c"}, "limit": 1},
+			want:    []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := testy.DiffInterface(tt.want, ids(t, tt.options)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+	t.Run("unsupported", func(t *testing.T) {
+		err := db.Changes(ctx, kivik.Param("filter", "foo/bar")).Err()
+		testutil.CheckError(t, "kivik: foo/bar filter not supported by the sqlite driver", http.StatusNotImplemented, err)
+	})
+}
@@ -0,0 +1,418 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+var (
+	errDatabaseNotFound = &kivik.Error{Status: http.StatusNotFound, Message: "database does not exist"}
+	errConflict         = &kivik.Error{Status: http.StatusConflict, Message: "document update conflict"}
+	errMissing          = &kivik.Error{Status: http.StatusNotFound, Message: "missing"}
+	errDeleted          = &kivik.Error{Status: http.StatusNotFound, Message: "deleted"}
+)
+
+type db struct {
+	client *client
+	name   string
+}
+
+var (
+	_ driver.DB          = &db{}
+	_ driver.RevGetter   = &db{}
+	_ driver.DesignDocer = &db{}
+	_ driver.LocalDocer  = &db{}
+)
+
+// checkDB returns errDatabaseNotFound if the database does not exist.
+func (d *db) checkDB(ctx context.Context, q querier) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM kivik_databases WHERE name = ?)`, d.name).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errDatabaseNotFound
+	}
+	return nil
+}
+
+// revision returns the requested revision of docID, or the winning revision
+// if rev is empty.
+func (d *db) revision(ctx context.Context, q querier, docID, rev string) (*storedRev, error) {
+	if rev == "" {
+		current, err := leaves(ctx, q, d.name, docID)
+		if err != nil {
+			return nil, err
+		}
+		if len(current) == 0 {
+			return nil, errMissing
+		}
+		if current[0].deleted {
+			return nil, errDeleted
+		}
+		return current[0], nil
+	}
+	parsed, err := parseRev(rev)
+	if err != nil {
+		return nil, err
+	}
+	r, err := getRev(ctx, q, d.name, docID, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if r.body == nil {
+		// Known only from history, or compacted away
+		return nil, errMissing
+	}
+	return r, nil
+}
+
+// docMeta holds the special, underscore-prefixed fields of a document.
+type docMeta struct {
+	ID          string                     `json:"_id"`
+	Rev         string                     `json:"_rev"`
+	Deleted     bool                       `json:"_deleted"`
+	Attachments map[string]json.RawMessage `json:"_attachments"`
+	Revisions   *revisionsField            `json:"_revisions"`
+}
+
+// parseDoc splits doc into its JSON body, without special fields, and its
+// special fields.
+func parseDoc(doc interface{}) (json.RawMessage, *docMeta, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &body); err != nil || body == nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Message: "document must be a JSON object"}
+	}
+	meta := &docMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	for k := range body {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		switch k {
+		case "_id", "_rev", "_deleted", "_attachments", "_revisions", "_conflicts":
+			delete(body, k)
+		default:
+			return nil, nil, &kivik.Error{Status: http.StatusBadRequest, Message: "bad special document member: " + k}
+		}
+	}
+	stripped, _ := json.Marshal(body)
+	return stripped, meta, nil
+}
+
+// render returns r as a CouchDB document. Body fields are left as
+// json.RawMessage values, to preserve numeric precision.
+func (d *db) render(ctx context.Context, q querier, docID string, r *storedRev, withAttachments bool) (map[string]interface{}, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{}, len(body)+4)
+	for k, v := range body {
+		doc[k] = v
+	}
+	doc["_id"] = docID
+	doc["_rev"] = r.String()
+	if r.deleted {
+		doc["_deleted"] = true
+	}
+	if len(r.attachments) > 0 {
+		atts := make(map[string]interface{}, len(r.attachments))
+		for name, att := range r.attachments {
+			stub := att.stub()
+			if withAttachments {
+				data, err := loadAttachment(ctx, q, d.name, att.Digest)
+				if err != nil {
+					return nil, err
+				}
+				delete(stub, "stub")
+				stub["data"] = base64.StdEncoding.EncodeToString(data)
+			}
+			atts[name] = stub
+		}
+		doc["_attachments"] = atts
+	}
+	return doc, nil
+}
+
+// Put stores a new revision of docID. With the new_edits=false option, the
+// revision is stored as given, with its ancestors from the _revisions field,
+// possibly creating a conflict.
+func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	body, meta, err := parseDoc(doc)
+	if err != nil {
+		return "", err
+	}
+	if meta.ID != "" && meta.ID != docID {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Message: "document ID does not match"}
+	}
+	rev := meta.Rev
+	if r := driverutil.StringOption(options, "rev"); r != "" {
+		rev = r
+	}
+	newEdits := true
+	if _, ok := options["new_edits"]; ok {
+		newEdits = driverutil.BoolOption(options, "new_edits")
+	}
+	var newRev string
+	err = d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		r := &storedRev{
+			body:    body,
+			deleted: meta.Deleted,
+		}
+		if !newEdits {
+			if rev == "" {
+				return &kivik.Error{Status: http.StatusBadRequest, Message: "_rev is required when new_edits is false"}
+			}
+			parsed, err := parseRev(rev)
+			if err != nil {
+				return err
+			}
+			r.revision = parsed
+			if r.attachments, err = parseAttachments(ctx, tx, d.name, meta.Attachments, nil); err != nil {
+				return err
+			}
+			newRev = rev
+			return replicate(ctx, tx, d.name, docID, r, meta.Revisions)
+		}
+		var prev *storedRev
+		if current, err := leaves(ctx, tx, d.name, docID); err != nil {
+			return err
+		} else {
+			for _, leaf := range current {
+				if leaf.String() == rev || (rev == "" && prev == nil) {
+					prev = leaf
+				}
+			}
+		}
+		if r.attachments, err = parseAttachments(ctx, tx, d.name, meta.Attachments, prev); err != nil {
+			return err
+		}
+		if meta.Deleted {
+			r.body = json.RawMessage("{}")
+			r.attachments = nil
+		}
+		if err := update(ctx, tx, d.name, docID, rev, r); err != nil {
+			return err
+		}
+		newRev = r.String()
+		return nil
+	})
+	return newRev, err
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	_, meta, err := parseDoc(doc)
+	if err != nil {
+		return "", "", err
+	}
+	docID := meta.ID
+	if docID == "" {
+		docID = driverutil.NewDocID()
+	}
+	rev, err := d.Put(ctx, docID, doc, options)
+	return docID, rev, err
+}
+
+// Get supports the rev, attachments, conflicts and revs options.
+func (d *db) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	q := d.client.db
+	if err := d.checkDB(ctx, q); err != nil {
+		return nil, err
+	}
+	r, err := d.revision(ctx, q, docID, driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := d.render(ctx, q, docID, r, driverutil.BoolOption(options, "attachments"))
+	if err != nil {
+		return nil, err
+	}
+	if driverutil.BoolOption(options, "conflicts") {
+		current, err := leaves(ctx, q, d.name, docID)
+		if err != nil {
+			return nil, err
+		}
+		var conflicts []string
+		for _, leaf := range current {
+			if !leaf.deleted && leaf.revision != r.revision {
+				conflicts = append(conflicts, leaf.String())
+			}
+		}
+		if len(conflicts) > 0 {
+			doc["_conflicts"] = conflicts
+		}
+	}
+	if driverutil.BoolOption(options, "revs") {
+		if doc["_revisions"], err = history(ctx, q, d.name, docID, r); err != nil {
+			return nil, err
+		}
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		Rev:  r.String(),
+		Body: io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (d *db) GetRev(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	if err := d.checkDB(ctx, d.client.db); err != nil {
+		return "", err
+	}
+	r, err := d.revision(ctx, d.client.db, docID, driverutil.StringOption(options, "rev"))
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+func (d *db) Delete(ctx context.Context, docID string, options map[string]interface{}) (string, error) {
+	rev := driverutil.StringOption(options, "rev")
+	if rev == "" {
+		return "", errConflict
+	}
+	var newRev string
+	err := d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		current, err := leaves(ctx, tx, d.name, docID)
+		if err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return errMissing
+		}
+		if current[0].deleted {
+			return errDeleted
+		}
+		r := &storedRev{
+			body:    json.RawMessage("{}"),
+			deleted: true,
+		}
+		if err := update(ctx, tx, d.name, docID, rev, r); err != nil {
+			return err
+		}
+		newRev = r.String()
+		return nil
+	})
+	return newRev, err
+}
+
+func (d *db) Stats(ctx context.Context) (*driver.DBStats, error) {
+	q := d.client.db
+	if err := d.checkDB(ctx, q); err != nil {
+		return nil, err
+	}
+	var seq int64
+	stats := &driver.DBStats{Name: d.name}
+	err := q.QueryRowContext(ctx, winners+`
+		SELECT
+			COALESCE(SUM(NOT deleted), 0),
+			COALESCE(SUM(deleted), 0),
+			(SELECT COALESCE(MAX(seq), 0) FROM kivik_revs WHERE db = ? AND id NOT GLOB '_local/*')
+		FROM winners
+		WHERE pos = 1 AND id NOT GLOB '_local/*'`, d.name, d.name).Scan(&stats.DocCount, &stats.DeletedCount, &seq)
+	if err != nil {
+		return nil, err
+	}
+	stats.UpdateSeq = strconv.FormatInt(seq, 10)
+	return stats, nil
+}
+
+// Compact discards the bodies and attachments of all non-leaf revisions, and
+// any attachment content which is no longer referenced.
+func (d *db) Compact(ctx context.Context) error {
+	return d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE kivik_revs AS r
+			SET doc = NULL, attachments = NULL
+			WHERE db = ? AND NOT `+isLeaf, d.name); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM kivik_attachments
+			WHERE db = ? AND digest NOT IN (
+				SELECT value ->> 'digest'
+				FROM kivik_revs, json_each(kivik_revs.attachments)
+				WHERE kivik_revs.db = ?
+			)`, d.name, d.name)
+		return err
+	})
+}
+
+func (d *db) CompactView(ctx context.Context, _ string) error {
+	return d.checkDB(ctx, d.client.db)
+}
+
+func (d *db) ViewCleanup(ctx context.Context) error {
+	return d.checkDB(ctx, d.client.db)
+}
+
+func (d *db) Security(ctx context.Context) (*driver.Security, error) {
+	var raw string
+	err := d.client.db.QueryRowContext(ctx, `SELECT security FROM kivik_databases WHERE name = ?`, d.name).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, errDatabaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sec := &driver.Security{}
+	return sec, json.Unmarshal([]byte(raw), sec)
+}
+
+func (d *db) SetSecurity(ctx context.Context, security *driver.Security) error {
+	raw, err := json.Marshal(security)
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	result, err := d.client.db.ExecContext(ctx, `UPDATE kivik_databases SET security = ? WHERE name = ?`, string(raw), d.name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errDatabaseNotFound
+	}
+	return nil
+}
+
+func (d *db) Query(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+	return nil, &kivik.Error{Status: http.StatusNotImplemented, Message: "kivik: views are not supported by the sqlite driver"}
+}
@@ -0,0 +1,257 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+func TestDocumentLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	rev1, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev1, "1-") {
+		t.Errorf("Unexpected rev: %s", rev1)
+	}
+	_, err = db.Put(ctx, "cow", map[string]interface{}{"name": "Daisy"})
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+
+	rev2, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": rev1, "name": "Daisy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Put(ctx, "cow", map[string]interface{}{"name": "Clarabelle"}, kivik.Options{"rev": rev1})
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"_id": "cow", "_rev": rev2, "name": "Daisy"}
+	if d := testy.DiffInterface(want, doc); d != nil {
+		t.Error(d)
+	}
+	if err := db.Get(ctx, "cow", kivik.Options{"rev": rev1}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "Bessie" {
+		t.Errorf("Unexpected old revision: %v", doc)
+	}
+	if err := db.Get(ctx, "cow", kivik.Options{"revs": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	wantRevs := map[string]interface{}{
+		"start": float64(2),
+		"ids":   []interface{}{strings.TrimPrefix(rev2, "2-"), strings.TrimPrefix(rev1, "1-")},
+	}
+	if d := testy.DiffInterface(wantRevs, doc["_revisions"]); d != nil {
+		t.Error(d)
+	}
+
+	_, err = db.Delete(ctx, "cow", rev1)
+	testutil.CheckError(t, "document update conflict", http.StatusConflict, err)
+	rev3, err := db.Delete(ctx, "cow", rev2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "cow").Err()
+	testutil.CheckError(t, "deleted", http.StatusNotFound, err)
+	_, err = db.Delete(ctx, "cow", rev3)
+	testutil.CheckError(t, "deleted", http.StatusNotFound, err)
+
+	// Recreating a deleted document extends the deleted branch.
+	rev4, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev4, "4-") {
+		t.Errorf("Unexpected rev: %s", rev4)
+	}
+
+	docID, _, err := db.CreateDoc(ctx, map[string]interface{}{"name": "Wilbur"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docID) != 32 {
+		t.Errorf("Unexpected doc ID: %s", docID)
+	}
+	_, err = db.Put(ctx, "pig", map[string]interface{}{"_bogus": true})
+	testutil.CheckError(t, "bad special document member: _bogus", http.StatusBadRequest, err)
+}
+
+func TestConflicts(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev1, err := db.Put(ctx, "cow", map[string]interface{}{"name": "Bessie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1 := strings.TrimPrefix(rev1, "1-")
+	noEdits := kivik.Options{"new_edits": false}
+	for _, doc := range []map[string]interface{}{
+		{"_rev": "2-aaa", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"aaa", id1}}, "name": "A"},
+		{"_rev": "3-zzz", "_revisions": map[string]interface{}{"start": 3, "ids": []string{"zzz", "yyy", id1}}, "name": "Z"},
+	} {
+		if _, err := db.Put(ctx, "cow", doc, noEdits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Storing an existing revision again has no effect.
+	if _, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": "2-aaa", "name": "A"}, noEdits); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow", kivik.Options{"conflicts": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["_rev"] != "3-zzz" || doc["name"] != "Z" {
+		t.Errorf("Unexpected winner: %v", doc)
+	}
+	if d := testy.DiffInterface([]interface{}{"2-aaa"}, doc["_conflicts"]); d != nil {
+		t.Error(d)
+	}
+	// The known ancestor 2-yyy has no body.
+	err = db.Get(ctx, "cow", kivik.Options{"rev": "2-yyy"}).Err()
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+
+	// Deleting the winner makes the conflict win.
+	if _, err := db.Delete(ctx, "cow", "3-zzz"); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.GetRev(ctx, "cow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != "2-aaa" {
+		t.Errorf("Unexpected winner after delete: %s", rev)
+	}
+	// A conflicting leaf may be updated.
+	if _, err := db.Put(ctx, "cow", map[string]interface{}{"_rev": "2-aaa", "name": "B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Put(ctx, "pig", map[string]interface{}{}, noEdits)
+	testutil.CheckError(t, "_rev is required when new_edits is false", http.StatusBadRequest, err)
+	_, err = db.Put(ctx, "pig", map[string]interface{}{"_rev": "x"}, noEdits)
+	testutil.CheckError(t, "invalid rev format", http.StatusBadRequest, err)
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev1, err := db.PutAttachment(ctx, "cow", &kivik.Attachment{
+		Filename:    "moo.txt",
+		ContentType: "text/plain",
+		Content:     io.NopCloser(strings.NewReader("moo")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev2, err := db.Put(ctx, "cow", map[string]interface{}{
+		"_rev": rev1,
+		"name": "Bessie",
+		"_attachments": map[string]interface{}{
+			"moo.txt":   map[string]interface{}{"stub": true},
+			"hello.txt": map[string]interface{}{"content_type": "text/plain", "data": "aGVsbG8="},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := db.GetAttachment(ctx, "cow", "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if string(content) != "moo" || att.ContentType != "text/plain" || att.RevPos != 1 {
+		t.Errorf("Unexpected attachment: %+v (%s)", att, content)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "cow", kivik.Options{"attachments": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	atts, _ := doc["_attachments"].(map[string]interface{})
+	if hello, _ := atts["hello.txt"].(map[string]interface{}); hello["data"] != "aGVsbG8=" || hello["revpos"] != float64(2) {
+		t.Errorf("Unexpected inline attachment: %v", atts)
+	}
+
+	rev3, err := db.DeleteAttachment(ctx, "cow", rev2, "moo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "cow", "moo.txt")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+	_, err = db.DeleteAttachment(ctx, "cow", rev3, "moo.txt")
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+
+	// Compaction discards old revisions, but not content still in use.
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "cow", "moo.txt", kivik.Options{"rev": rev2})
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+	if _, err := db.GetAttachment(ctx, "cow", "hello.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatsAndSecurity(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"a", "b", "c", "_local/x"} {
+		if _, err := db.Put(ctx, id, map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rev, _ := db.GetRev(ctx, "c")
+	if _, err := db.Delete(ctx, "c", rev); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DocCount != 2 || stats.DeletedCount != 1 || stats.UpdateSeq == "0" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	sec := &kivik.Security{Admins: kivik.Members{Names: []string{"bob"}}}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(sec, got); d != nil {
+		t.Error(d)
+	}
+	_, err = db.Query(ctx, "foo", "bar").Metadata()
+	testutil.CheckError(t, "kivik: views are not supported by the sqlite driver", http.StatusNotImplemented, err)
+}
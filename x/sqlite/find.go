@@ -0,0 +1,229 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+var _ driver.Finder = &db{}
+
+// allDocsIndex is the special index reported for every database.
+var allDocsIndex = driver.Index{
+	Name: "_all_docs",
+	Type: "special",
+	Definition: map[string]interface{}{
+		"fields": []interface{}{map[string]interface{}{"_id": "asc"}},
+	},
+}
+
+// Find translates the query's selector to SQL, as far as possible, and
+// applies the full query to the resulting candidate documents. Indexes are
+// recorded, but not used.
+func (d *db) Find(ctx context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+	q, err := mango.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	conn := d.client.db
+	if err := d.checkDB(ctx, conn); err != nil {
+		return nil, err
+	}
+	where, args, exact := translate(q.Selector)
+	stmt := winners + ` SELECT id, ` + revColumns + ` FROM winners
+		WHERE pos = 1 AND NOT deleted AND id NOT GLOB '_design/*' AND id NOT GLOB '_local/*'
+		AND (` + where + `)
+		ORDER BY id`
+	args = append([]interface{}{d.name}, args...)
+	apply := *q
+	if exact && len(q.Sort) == 0 {
+		// SQL alone determines the result, so it may be paginated in SQL.
+		stmt += ` LIMIT ? OFFSET ?`
+		args = append(args, q.Limit, q.Skip)
+		apply.Skip = 0
+	}
+	type candidate struct {
+		id string
+		*storedRev
+	}
+	var candidates []candidate
+	dbRows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close() // nolint:errcheck
+	for dbRows.Next() {
+		var c candidate
+		c.storedRev, err = scanRev(func(dest ...interface{}) error {
+			return dbRows.Scan(append([]interface{}{&c.id}, dest...)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	if err := dbRows.Err(); err != nil {
+		return nil, err
+	}
+	_ = dbRows.Close()
+
+	docs := make([]map[string]interface{}, len(candidates))
+	for i, c := range candidates {
+		rendered, err := d.render(ctx, conn, c.id, c.storedRev, false)
+		if err != nil {
+			return nil, err
+		}
+		raw, _ := json.Marshal(rendered)
+		if err := json.Unmarshal(raw, &docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	results, err := apply.Apply(docs)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*driver.Row, len(results))
+	for i, result := range results {
+		doc, _ := json.Marshal(result)
+		id, _ := result["_id"].(string)
+		rows[i] = &driver.Row{
+			ID:  id,
+			Doc: bytes.NewReader(doc),
+		}
+	}
+	return driverutil.NewRows(rows, 0, 0, ""), nil
+}
+
+func (d *db) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, _ map[string]interface{}) error {
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if _, ok := def["fields"].([]interface{}); !ok {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "index definition must contain fields"}
+	}
+	if name == "" {
+		name = driverutil.NewDocID()
+	}
+	if ddoc == "" {
+		ddoc = name
+	}
+	if !strings.HasPrefix(ddoc, "_design/") {
+		ddoc = "_design/" + ddoc
+	}
+	raw, _ = json.Marshal(def)
+	return d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO kivik_indexes (db, ddoc, name, def)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`, d.name, ddoc, name, string(raw))
+		return err
+	})
+}
+
+func (d *db) GetIndexes(ctx context.Context, _ map[string]interface{}) ([]driver.Index, error) {
+	conn := d.client.db
+	if err := d.checkDB(ctx, conn); err != nil {
+		return nil, err
+	}
+	dbRows, err := conn.QueryContext(ctx, `SELECT ddoc, name, def FROM kivik_indexes WHERE db = ? ORDER BY ddoc, name`, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close() // nolint:errcheck
+	indexes := []driver.Index{allDocsIndex}
+	for dbRows.Next() {
+		idx := driver.Index{Type: "json"}
+		var def string
+		if err := dbRows.Scan(&idx.DesignDoc, &idx.Name, &def); err != nil {
+			return nil, err
+		}
+		var definition map[string]interface{}
+		if err := json.Unmarshal([]byte(def), &definition); err != nil {
+			return nil, err
+		}
+		idx.Definition = definition
+		indexes = append(indexes, idx)
+	}
+	return indexes, dbRows.Err()
+}
+
+func (d *db) DeleteIndex(ctx context.Context, ddoc, name string, _ map[string]interface{}) error {
+	if !strings.HasPrefix(ddoc, "_design/") {
+		ddoc = "_design/" + ddoc
+	}
+	return d.client.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkDB(ctx, tx); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, `DELETE FROM kivik_indexes WHERE db = ? AND ddoc = ? AND name = ?`, d.name, ddoc, name)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return &kivik.Error{Status: http.StatusNotFound, Message: "index not found"}
+		}
+		return nil
+	})
+}
+
+// Explain reports the SQL condition generated from the query's selector in
+// the plan's options, under the key "sql", and whether it alone determines
+// the result, under the key "exact".
+func (d *db) Explain(ctx context.Context, query interface{}, _ map[string]interface{}) (*driver.QueryPlan, error) {
+	q, err := mango.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkDB(ctx, d.client.db); err != nil {
+		return nil, err
+	}
+	where, _, exact := translate(q.Selector)
+	fields := make([]interface{}, len(q.Fields))
+	for i, f := range q.Fields {
+		fields[i] = f
+	}
+	return &driver.QueryPlan{
+		DBName: d.name,
+		Index: map[string]interface{}{
+			"ddoc": nil,
+			"name": allDocsIndex.Name,
+			"type": allDocsIndex.Type,
+			"def":  allDocsIndex.Definition,
+		},
+		Selector: q.Selector,
+		Options: map[string]interface{}{
+			"sql":   where,
+			"exact": exact,
+		},
+		Limit:  q.Limit,
+		Skip:   q.Skip,
+		Fields: fields,
+	}, nil
+}
@@ -0,0 +1,269 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// revision is a parsed revision ID, such as "2-abc".
+type revision struct {
+	rev   int64
+	revID string
+}
+
+func (r revision) String() string {
+	return strconv.FormatInt(r.rev, 10) + "-" + r.revID
+}
+
+func parseRev(rev string) (revision, error) {
+	parts := strings.SplitN(rev, "-", 2)
+	gen, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 || parts[1] == "" || gen < 1 {
+		return revision{}, &kivik.Error{Status: http.StatusBadRequest, Message: "invalid rev format"}
+	}
+	return revision{rev: gen, revID: parts[1]}, nil
+}
+
+// storedRev is a single revision of a document, as stored in kivik_revs.
+type storedRev struct {
+	revision
+	parent      *revision
+	deleted     bool
+	body        json.RawMessage
+	attachments map[string]*attachment
+}
+
+const revColumns = `rev, rev_id, parent_rev, parent_rev_id, deleted, doc, attachments`
+
+// scanRev scans the columns listed in revColumns.
+func scanRev(scan func(...interface{}) error) (*storedRev, error) {
+	var (
+		r                 storedRev
+		parentRev         sql.NullInt64
+		parentRevID       sql.NullString
+		body, attachments sql.NullString
+	)
+	if err := scan(&r.rev, &r.revID, &parentRev, &parentRevID, &r.deleted, &body, &attachments); err != nil {
+		return nil, err
+	}
+	if parentRev.Valid {
+		r.parent = &revision{rev: parentRev.Int64, revID: parentRevID.String}
+	}
+	if body.Valid {
+		r.body = json.RawMessage(body.String)
+	}
+	if attachments.Valid {
+		if err := json.Unmarshal([]byte(attachments.String), &r.attachments); err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+// isLeaf is an SQL condition which is true if the revision r has no children.
+const isLeaf = `NOT EXISTS (
+	SELECT 1 FROM kivik_revs AS c
+	WHERE c.db = r.db AND c.id = r.id AND c.parent_rev = r.rev AND c.parent_rev_id = r.rev_id
+)`
+
+// winnerOrder sorts leaf revisions so that the winning revision is first:
+// non-deleted revisions win over deleted ones, then the longest revision
+// history wins, with ties broken by the highest revision ID.
+const winnerOrder = `deleted, rev DESC, rev_id DESC`
+
+// winners is a common table expression, which selects the leaf revisions of
+// the database given as its only parameter, with pos = 1 for each document's
+// winning revision.
+const winners = `WITH winners AS (
+	SELECT r.*, ROW_NUMBER() OVER (PARTITION BY r.id ORDER BY ` + winnerOrder + `) AS pos
+	FROM kivik_revs AS r
+	WHERE r.db = ? AND ` + isLeaf + `
+)`
+
+// leaves returns the leaf revisions of docID, with the winning revision first.
+func leaves(ctx context.Context, q querier, dbName, docID string) ([]*storedRev, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+revColumns+` FROM kivik_revs AS r
+		WHERE db = ? AND id = ? AND `+isLeaf+`
+		ORDER BY `+winnerOrder, dbName, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint:errcheck
+	var result []*storedRev
+	for rows.Next() {
+		r, err := scanRev(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// getRev returns the requested revision of docID, or errMissing.
+func getRev(ctx context.Context, q querier, dbName, docID string, rev revision) (*storedRev, error) {
+	row := q.QueryRowContext(ctx, `SELECT `+revColumns+` FROM kivik_revs
+		WHERE db = ? AND id = ? AND rev = ? AND rev_id = ?`, dbName, docID, rev.rev, rev.revID)
+	r, err := scanRev(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, errMissing
+	}
+	return r, err
+}
+
+// history returns the revision IDs of r and its known ancestors, newest
+// first, in the format of the _revisions field.
+func history(ctx context.Context, q querier, dbName, docID string, r *storedRev) (map[string]interface{}, error) {
+	ids := []string{r.revID}
+	parent := r.parent
+	for parent != nil {
+		ids = append(ids, parent.revID)
+		p, err := getRev(ctx, q, dbName, docID, *parent)
+		if err == errMissing {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent = p.parent
+	}
+	return map[string]interface{}{
+		"start": r.rev,
+		"ids":   ids,
+	}, nil
+}
+
+// insertRev stores r, unless a revision with the same ID already exists.
+func insertRev(ctx context.Context, q querier, dbName, docID string, r *storedRev) error {
+	var parentRev, parentRevID interface{}
+	if r.parent != nil {
+		parentRev, parentRevID = r.parent.rev, r.parent.revID
+	}
+	var body, atts interface{}
+	if r.body != nil {
+		body = string(r.body)
+	}
+	if len(r.attachments) > 0 {
+		raw, _ := json.Marshal(r.attachments)
+		atts = string(raw)
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO kivik_revs (db, id, `+revColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		dbName, docID, r.rev, r.revID, parentRev, parentRevID, r.deleted, body, atts)
+	return err
+}
+
+func newRevID(parent string, r *storedRev) string {
+	h := md5.New()
+	digests := make(map[string]string, len(r.attachments))
+	for name, att := range r.attachments {
+		digests[name] = att.Digest
+	}
+	_ = json.NewEncoder(h).Encode([]interface{}{parent, r.body, r.deleted, digests})
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// update stores r as a new child of the leaf revision rev of docID. If rev is
+// empty, docID must not exist, or must be deleted. r.revision is set to the
+// new revision.
+func update(ctx context.Context, q querier, dbName, docID, rev string, r *storedRev) error {
+	current, err := leaves(ctx, q, dbName, docID)
+	if err != nil {
+		return err
+	}
+	var parent *storedRev
+	if rev == "" {
+		if len(current) > 0 {
+			if !current[0].deleted {
+				return errConflict
+			}
+			// Extend the deleted branch, as CouchDB does.
+			parent = current[0]
+		}
+	} else {
+		for _, leaf := range current {
+			if leaf.String() == rev {
+				parent = leaf
+				break
+			}
+		}
+		if parent == nil {
+			return errConflict
+		}
+	}
+	r.rev = 1
+	var parentRev string
+	if parent != nil {
+		r.parent = &parent.revision
+		r.rev = parent.rev + 1
+		parentRev = parent.String()
+	}
+	for _, att := range r.attachments {
+		if att.RevPos == 0 {
+			att.RevPos = r.rev
+		}
+	}
+	r.revID = newRevID(parentRev, r)
+	return insertRev(ctx, q, dbName, docID, r)
+}
+
+// replicate stores r as-is, along with any of its ancestors listed in
+// revisions which are not already known, as when new_edits=false. Storing a
+// revision which already exists has no effect.
+func replicate(ctx context.Context, q querier, dbName, docID string, r *storedRev, revisions *revisionsField) error {
+	if revisions != nil && len(revisions.IDs) > 0 {
+		if revisions.Start != r.rev || revisions.IDs[0] != r.revID {
+			return &kivik.Error{Status: http.StatusBadRequest, Message: "_revisions does not match _rev"}
+		}
+		var parent *revision
+		for i := len(revisions.IDs) - 1; i >= 1; i-- {
+			ancestor := &storedRev{
+				revision: revision{rev: revisions.Start - int64(i), revID: revisions.IDs[i]},
+				parent:   parent,
+			}
+			if ancestor.rev < 1 {
+				return &kivik.Error{Status: http.StatusBadRequest, Message: "invalid _revisions"}
+			}
+			if err := insertRev(ctx, q, dbName, docID, ancestor); err != nil {
+				return err
+			}
+			parent = &ancestor.revision
+		}
+		r.parent = parent
+	}
+	return insertRev(ctx, q, dbName, docID, r)
+}
+
+// revisionsField is the _revisions field of a document.
+type revisionsField struct {
+	Start int64    `json:"start"`
+	IDs   []string `json:"ids"`
+}
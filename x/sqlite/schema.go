@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import "database/sql"
+
+// schema creates the tables used by the driver. Revisions of every database
+// are stored in the one table. seq is the update sequence, shared between all
+// databases; each document appears in a database's changes feed at the
+// highest seq of any of its revisions. doc is NULL for revisions whose body is
+// not known, either because they were received only as part of another
+// revision's history, or because they have been compacted away.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS kivik_databases (
+		name     TEXT PRIMARY KEY,
+		security TEXT NOT NULL DEFAULT '{}'
+	)`,
+	`CREATE TABLE IF NOT EXISTS kivik_revs (
+		seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+		db            TEXT NOT NULL,
+		id            TEXT NOT NULL,
+		rev           INTEGER NOT NULL,
+		rev_id        TEXT NOT NULL,
+		parent_rev    INTEGER,
+		parent_rev_id TEXT,
+		deleted       BOOLEAN NOT NULL DEFAULT FALSE,
+		doc           TEXT,
+		attachments   TEXT,
+		UNIQUE (db, id, rev, rev_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS kivik_revs_parent ON kivik_revs (db, id, parent_rev, parent_rev_id)`,
+	`CREATE TABLE IF NOT EXISTS kivik_attachments (
+		db     TEXT NOT NULL,
+		digest TEXT NOT NULL,
+		data   BLOB NOT NULL,
+		PRIMARY KEY (db, digest)
+	)`,
+	`CREATE TABLE IF NOT EXISTS kivik_indexes (
+		db   TEXT NOT NULL,
+		ddoc TEXT NOT NULL,
+		name TEXT NOT NULL,
+		def  TEXT NOT NULL,
+		PRIMARY KEY (db, ddoc, name)
+	)`,
+}
+
+func createSchema(db *sql.DB) error {
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// condition is an SQL condition over the id and doc columns of kivik_revs.
+// If exact is false, the condition is true for every document matched by the
+// selector it was translated from, but possibly for others as well.
+type condition struct {
+	sql   string
+	args  []interface{}
+	exact bool
+}
+
+// translate translates a Mango selector to an SQL condition. Operators which
+// cannot be translated without changing their meaning, such as $regex, are
+// omitted, in which case exact is false and the selector must be applied to
+// each matching document to complete the query.
+func translate(selector map[string]interface{}) (where string, args []interface{}, exact bool) {
+	c := selectorSQL(selector, nil)
+	if c == nil {
+		return "TRUE", nil, false
+	}
+	return c.sql, c.args, c.exact
+}
+
+// leaf returns an exact condition, which is never NULL, so that it may be
+// safely negated.
+func leaf(sql string, args ...interface{}) *condition {
+	return &condition{
+		sql:   "COALESCE((" + sql + "), FALSE)",
+		args:  args,
+		exact: true,
+	}
+}
+
+// and combines conditions, any of which may be nil if untranslatable.
+func and(conds []*condition) *condition {
+	if len(conds) == 0 {
+		return &condition{sql: "TRUE", exact: true}
+	}
+	result := &condition{exact: true}
+	parts := make([]string, 0, len(conds))
+	for _, c := range conds {
+		if c == nil {
+			result.exact = false
+			continue
+		}
+		parts = append(parts, "("+c.sql+")")
+		result.args = append(result.args, c.args...)
+		result.exact = result.exact && c.exact
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	result.sql = strings.Join(parts, " AND ")
+	return result
+}
+
+// or combines conditions, and is untranslatable if any of them is.
+func or(conds []*condition) *condition {
+	if len(conds) == 0 {
+		return &condition{sql: "FALSE", exact: true}
+	}
+	result := &condition{exact: true}
+	parts := make([]string, 0, len(conds))
+	for _, c := range conds {
+		if c == nil {
+			return nil
+		}
+		parts = append(parts, "("+c.sql+")")
+		result.args = append(result.args, c.args...)
+		result.exact = result.exact && c.exact
+	}
+	result.sql = strings.Join(parts, " OR ")
+	return result
+}
+
+// not negates c, which is only possible if c is exact.
+func not(c *condition) *condition {
+	if c == nil || !c.exact {
+		return nil
+	}
+	return &condition{sql: "NOT (" + c.sql + ")", args: c.args, exact: true}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// selectorSQL translates selector, applied to the value at path.
+func selectorSQL(selector map[string]interface{}, path []string) *condition {
+	conds := make([]*condition, 0, len(selector))
+	for _, key := range sortedKeys(selector) {
+		conds = append(conds, keySQL(key, selector[key], path))
+	}
+	return and(conds)
+}
+
+func keySQL(key string, cond interface{}, path []string) *condition {
+	switch key {
+	case "$and", "$or", "$nor":
+		subs, ok := cond.([]interface{})
+		if !ok {
+			return nil
+		}
+		conds := make([]*condition, len(subs))
+		for i, sub := range subs {
+			subSel, ok := sub.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			conds[i] = selectorSQL(subSel, path)
+		}
+		return combine(key, conds)
+	case "$not":
+		subSel, ok := cond.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return not(selectorSQL(subSel, path))
+	}
+	if strings.HasPrefix(key, "$") {
+		return nil
+	}
+	return condSQL(cond, append(append([]string{}, path...), splitField(key)...))
+}
+
+func combine(op string, conds []*condition) *condition {
+	switch op {
+	case "$and":
+		return and(conds)
+	case "$or":
+		return or(conds)
+	}
+	return not(or(conds))
+}
+
+// splitField splits a dotted field name into its components. A literal dot
+// may be escaped with a backslash.
+func splitField(field string) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == '\\' && i+1 < len(field) && field[i+1] == '.':
+			cur.WriteByte('.')
+			i++
+		case c == '.':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func isOperatorObject(cond interface{}) (map[string]interface{}, bool) {
+	obj, ok := cond.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return nil, false
+	}
+	for k := range obj {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+func condSQL(cond interface{}, path []string) *condition {
+	ops, ok := isOperatorObject(cond)
+	if !ok {
+		if sub, ok := cond.(map[string]interface{}); ok && len(sub) > 0 {
+			// An implicit nested selector, e.g. {"a": {"b": 1}}
+			return selectorSQL(sub, path)
+		}
+		return opSQL("$eq", cond, path)
+	}
+	conds := make([]*condition, 0, len(ops))
+	for _, op := range sortedKeys(ops) {
+		conds = append(conds, opSQL(op, ops[op], path))
+	}
+	return and(conds)
+}
+
+// field holds the SQL expressions for the JSON type and value of a field.
+// Each expression takes args as its arguments.
+type field struct {
+	typ, value string
+	args       []interface{}
+}
+
+// newField returns the field at path, or false if it cannot be expressed in
+// SQL. _id is read from the id column; other special fields are not stored
+// in the document body.
+func newField(path []string) (*field, bool) {
+	if strings.HasPrefix(path[0], "_") {
+		if len(path) == 1 && path[0] == "_id" {
+			return &field{typ: "'text'", value: "id"}, true
+		}
+		return nil, false
+	}
+	var jsonPath strings.Builder
+	jsonPath.WriteString("$")
+	for _, part := range path {
+		if strings.ContainsAny(part, `"\`) {
+			return nil, false
+		}
+		jsonPath.WriteString(`."` + part + `"`)
+	}
+	return &field{
+		typ:   "json_type(doc, ?)",
+		value: "json_extract(doc, ?)",
+		args:  []interface{}{jsonPath.String()},
+	}, true
+}
+
+// repeat returns the field's arguments n times, followed by extra, for a
+// condition which uses the field's expressions n times.
+func (f *field) repeat(n int, extra ...interface{}) []interface{} {
+	args := make([]interface{}, 0, n*len(f.args)+len(extra))
+	for i := 0; i < n; i++ {
+		args = append(args, f.args...)
+	}
+	return append(args, extra...)
+}
+
+// rank is an SQL expression for the field's type, in collation order, as
+// returned by rank.
+func (f *field) rank() string {
+	return "CASE " + f.typ + ` WHEN 'null' THEN 0 WHEN 'false' THEN 1 WHEN 'true' THEN 2
+		WHEN 'integer' THEN 3 WHEN 'real' THEN 3 WHEN 'text' THEN 4
+		WHEN 'array' THEN 5 WHEN 'object' THEN 6 END`
+}
+
+// rank returns the collation rank of a decoded JSON value, as in
+// [github.com/go-kivik/kivik/v4/x/mango.Compare].
+func rank(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+var typeNames = map[string]string{
+	"null":    "'null'",
+	"boolean": "'true', 'false'",
+	"number":  "'integer', 'real'",
+	"string":  "'text'",
+	"array":   "'array'",
+	"object":  "'object'",
+}
+
+var comparisons = map[string]string{
+	"$eq":  "=",
+	"$lt":  "<",
+	"$lte": "<=",
+	"$gt":  ">",
+	"$gte": ">=",
+}
+
+func opSQL(op string, arg interface{}, path []string) *condition {
+	f, ok := newField(path)
+	if !ok {
+		return nil
+	}
+	exists := leaf(f.typ+" IS NOT NULL", f.args...)
+	switch op {
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			return nil
+		}
+		if want {
+			return exists
+		}
+		return not(exists)
+	case "$not":
+		return not(condSQL(arg, path))
+	case "$and", "$or", "$nor":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil
+		}
+		conds := make([]*condition, len(list))
+		for i, c := range list {
+			conds[i] = condSQL(c, path)
+		}
+		return combine(op, conds)
+	case "$ne":
+		return and([]*condition{exists, not(opSQL("$eq", arg, path))})
+	case "$in", "$nin":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil
+		}
+		conds := make([]*condition, len(list))
+		for i, v := range list {
+			conds[i] = opSQL("$eq", v, path)
+		}
+		if op == "$in" {
+			return or(conds)
+		}
+		return and([]*condition{exists, not(or(conds))})
+	case "$type":
+		name, ok := arg.(string)
+		if !ok || typeNames[name] == "" {
+			return nil
+		}
+		return leaf(f.typ+" IN ("+typeNames[name]+")", f.args...)
+	case "$size":
+		size, ok := arg.(float64)
+		if !ok {
+			return nil
+		}
+		if f.value == "id" {
+			return leaf("FALSE")
+		}
+		return leaf(f.typ+" = 'array' AND json_array_length(doc, ?) = ?", f.repeat(2, size)...)
+	}
+	cmp, ok := comparisons[op]
+	if !ok {
+		return nil
+	}
+	r := rank(arg)
+	if r > 4 {
+		// Arrays and objects are compared element-wise.
+		return nil
+	}
+	rankSQL := "(" + f.rank() + ")"
+	rankConst := strconv.Itoa(r)
+	if r < 3 {
+		// null, false and true are each the only value of their rank.
+		return leaf(rankSQL+" "+cmp+" "+rankConst, f.args...)
+	}
+	if op == "$eq" {
+		return leaf(rankSQL+" = "+rankConst+" AND "+f.value+" = ?", f.repeat(2, arg)...)
+	}
+	strict := strings.TrimSuffix(cmp, "=")
+	return leaf(rankSQL+" "+strict+" "+rankConst+" OR ("+rankSQL+" = "+rankConst+" AND "+f.value+" "+cmp+" ?)", f.repeat(3, arg)...)
+}
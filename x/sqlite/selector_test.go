@@ -0,0 +1,155 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+// TestTranslate checks the SQL translation of each selector against
+// mango.Match, over documents with values of every JSON type.
+func TestTranslate(t *testing.T) {
+	ctx := context.Background()
+	c, err := (&sqliteDriver{}).NewClient(":memory:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.(*client).Close() // nolint:errcheck
+	if err := c.CreateDB(ctx, "docs", nil); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := c.DB("docs", nil)
+	values := []string{
+		`null`, `true`, `false`, `0`, `1`, `1.5`, `2`, `-1`, `""`, `"a"`, `"b"`, `"10"`,
+		`[]`, `[1]`, `[1,2]`, `{}`, `{"b":1}`, `{"b":"x"}`,
+	}
+	docs := map[string]map[string]interface{}{
+		"missing": {},
+		"escaped": {"x": map[string]interface{}{"y.z": 1.0}},
+	}
+	for i, v := range values {
+		var value interface{}
+		_ = json.Unmarshal([]byte(v), &value)
+		docs[fmt.Sprintf("d%02d", i)] = map[string]interface{}{"a": value}
+	}
+	for id, doc := range docs {
+		if _, err := d.Put(ctx, id, doc, nil); err != nil {
+			t.Fatal(err)
+		}
+		doc["_id"] = id
+	}
+
+	type tst struct {
+		selector string
+		exact    bool
+	}
+	tests := testy.NewTable()
+	for _, selector := range []string{
+		`{}`,
+		`{"a": 1}`,
+		`{"a": {"$eq": "a"}}`,
+		`{"a": null}`,
+		`{"a": {"$ne": 1}}`,
+		`{"a": {"$gt": 1}}`,
+		`{"a": {"$gte": 1}}`,
+		`{"a": {"$lt": "b"}}`,
+		`{"a": {"$lte": null}}`,
+		`{"a": {"$gt": false}}`,
+		`{"a": {"$lt": true}}`,
+		`{"a": {"$in": [1, "a", null]}}`,
+		`{"a": {"$nin": [1, "a"]}}`,
+		`{"a": {"$exists": false}}`,
+		`{"a": {"$exists": true, "$ne": null}}`,
+		`{"a": {"$type": "number"}}`,
+		`{"a": {"$type": "boolean"}}`,
+		`{"a": {"$size": 2}}`,
+		`{"a.b": 1}`,
+		`{"a": {"b": {"$gt": 0}}}`,
+		`{"x.y\\.z": 1}`,
+		`{"$or": [{"a": 1}, {"a": "a"}]}`,
+		`{"$nor": [{"a": 1}, {"a": {"$type": "string"}}]}`,
+		`{"$not": {"a": {"$gt": 1}}}`,
+		`{"a": {"$not": {"$lt": 1}}}`,
+		`{"a": {"$and": [{"$gt": 0}, {"$lt": 2}]}}`,
+		`{"a": {"$or": [{"$lt": 0}, {"$gt": 1.5}]}}`,
+		`{"_id": {"$gt": "d05"}}`,
+		`{"_id": {"$in": ["d01", "missing"]}}`,
+	} {
+		tests.Add(selector, tst{selector: selector, exact: true})
+	}
+	for _, selector := range []string{
+		`{"a": {"$regex": "^a"}}`,
+		`{"a": [1]}`,
+		`{"a": {"$gt": []}}`,
+		`{"a": {"$mod": [2, 0]}}`,
+		`{"a": {"$all": [1]}}`,
+		`{"a": {"$elemMatch": {"$gt": 1}}}`,
+		`{"a": {"$gt": 0, "$regex": "x"}}`,
+		`{"$or": [{"a": 1}, {"a": {"$regex": "b"}}]}`,
+		`{"$not": {"a": {"$regex": "b"}}}`,
+		`{"_rev": {"$exists": true}}`,
+	} {
+		tests.Add(selector, tst{selector: selector})
+	}
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		var selector map[string]interface{}
+		if err := json.Unmarshal([]byte(test.selector), &selector); err != nil {
+			t.Fatal(err)
+		}
+		where, args, exact := translate(selector)
+		if exact != test.exact {
+			t.Errorf("Unexpected exact: %t", exact)
+		}
+		rows, err := c.(*client).db.QueryContext(ctx, `SELECT id FROM kivik_revs WHERE db = 'docs' AND (`+where+`) ORDER BY id`, args...)
+		if err != nil {
+			t.Fatalf("%s: %s", where, err)
+		}
+		defer rows.Close() // nolint:errcheck
+		got := map[string]bool{}
+		for rows.Next() {
+			var id string
+			_ = rows.Scan(&id)
+			got[id] = true
+		}
+		var missing, extra []string
+		for id, doc := range docs {
+			ok, err := mango.Match(selector, doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case ok && !got[id]:
+				missing = append(missing, id)
+			case !ok && got[id]:
+				extra = append(extra, id)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			t.Errorf("Matching documents excluded by SQL: %v", missing)
+		}
+		if exact && len(extra) > 0 {
+			t.Errorf("Non-matching documents included by exact SQL: %v", extra)
+		}
+	})
+}
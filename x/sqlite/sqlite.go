@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package sqlite provides a Kivik driver backed by an SQLite database, for
+// deployments which cannot run CouchDB, but which need the same application
+// code.
+//
+// The driver is registered under the name "sqlite". The data source name is
+// passed unmodified to the pure-Go [modernc.org/sqlite] driver, and is usually
+// the path to the database file, or ":memory:" for a transient database:
+//
+//	import (
+//	    kivik "github.com/go-kivik/kivik/v4"
+//	    _ "github.com/go-kivik/kivik/v4/x/sqlite"
+//	)
+//
+//	client, err := kivik.New("sqlite", "/path/to/data.db")
+//
+// All Kivik databases are stored in the one SQLite file. Each document keeps
+// its full revision tree, so conflicting revisions may be created with the
+// new_edits=false option, as used by replication, and are reported by the
+// conflicts option and the changes feed. The winning revision is chosen as by
+// CouchDB. Documents are stored as JSON, and Mango selectors are translated
+// to SQL using the SQLite JSON functions, wherever that can be done without
+// changing their meaning; the full selector is then applied to each candidate
+// document. Views and continuous changes feeds are not supported.
+//
+// This package is a separate Go module, so that programs which do not use it
+// do not depend on SQLite.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	_ "modernc.org/sqlite" // SQLite database/sql driver
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/driverutil"
+)
+
+func init() {
+	kivik.Register("sqlite", &sqliteDriver{})
+}
+
+type sqliteDriver struct{}
+
+var _ driver.Driver = &sqliteDriver{}
+
+// NewClient opens the SQLite database named by dsn, creating the schema if
+// necessary.
+func (d *sqliteDriver) NewClient(dsn string, _ map[string]interface{}) (driver.Client, error) {
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	// A single connection serializes writes, and is required for in-memory
+	// databases, which are private to a connection.
+	conn.SetMaxOpenConns(1)
+	if err := createSchema(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &client{db: conn}, nil
+}
+
+type client struct {
+	db *sql.DB
+}
+
+var (
+	_ driver.Client       = &client{}
+	_ driver.ClientCloser = &client{}
+)
+
+// Version is the version reported by the SQLite driver.
+const Version = kivik.KivikVersion
+
+// Vendor is the vendor string reported by the SQLite driver.
+const Vendor = "Kivik SQLite Adaptor"
+
+func (c *client) Version(context.Context) (*driver.Version, error) {
+	return &driver.Version{
+		Version: Version,
+		Vendor:  Vendor,
+	}, nil
+}
+
+func (c *client) AllDBs(ctx context.Context, _ map[string]interface{}) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT name FROM kivik_databases ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint:errcheck
+	dbs := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, name)
+	}
+	return dbs, rows.Err()
+}
+
+func (c *client) DBExists(ctx context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	var exists bool
+	err := c.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM kivik_databases WHERE name = ?)`, dbName).Scan(&exists)
+	return exists, err
+}
+
+func (c *client) CreateDB(ctx context.Context, dbName string, _ map[string]interface{}) error {
+	if !driverutil.ValidDBName(dbName) {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "illegal database name"}
+	}
+	result, err := c.db.ExecContext(ctx, `INSERT INTO kivik_databases (name) VALUES (?) ON CONFLICT DO NOTHING`, dbName)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return &kivik.Error{Status: http.StatusPreconditionFailed, Message: "database exists"}
+	}
+	return nil
+}
+
+func (c *client) DestroyDB(ctx context.Context, dbName string, _ map[string]interface{}) error {
+	return c.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM kivik_databases WHERE name = ?`, dbName)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errDatabaseNotFound
+		}
+		for _, table := range []string{"kivik_revs", "kivik_attachments", "kivik_indexes"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE db = ?`, dbName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *client) DB(dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{
+		client: c,
+		name:   dbName,
+	}, nil
+}
+
+// Close closes the underlying SQLite database.
+func (c *client) Close() error {
+	return c.db.Close()
+}
+
+// inTx calls fn within a transaction, which is committed if fn succeeds, and
+// rolled back otherwise.
+func (c *client) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sqlite
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/testutil"
+)
+
+// newDB returns a new, empty database in a new in-memory SQLite database.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	if err := client.CreateDB(context.Background(), "animals"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("animals")
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() // nolint:errcheck
+	version, err := client.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Vendor != Vendor {
+		t.Errorf("Unexpected vendor: %s", version.Vendor)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = client.CreateDB(ctx, "foo")
+	testutil.CheckError(t, "database exists", http.StatusPreconditionFailed, err)
+	err = client.CreateDB(ctx, "Foo")
+	testutil.CheckError(t, "illegal database name", http.StatusBadRequest, err)
+
+	dbs, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bar", "foo"}, dbs); d != nil {
+		t.Error(d)
+	}
+	if _, err := client.DB("foo").Put(ctx, "cow", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DestroyDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.DestroyDB(ctx, "foo")
+	testutil.CheckError(t, "database does not exist", http.StatusNotFound, err)
+	err = client.DB("foo").Get(ctx, "cow").Err()
+	testutil.CheckError(t, "database does not exist", http.StatusNotFound, err)
+
+	// Recreating the database must not resurrect its documents.
+	if err := client.CreateDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.DB("foo").Get(ctx, "cow").Err()
+	testutil.CheckError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "kivik.db")
+	client, err := kivik.New("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := client.DB("animals").Put(ctx, "cow", map[string]string{"sound": "moo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err = kivik.New("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() // nolint:errcheck
+	got, err := client.DB("animals").GetRev(ctx, "cow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != rev {
+		t.Errorf("Unexpected rev %s, want %s", got, rev)
+	}
+}
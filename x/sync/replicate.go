@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sync
+
+import (
+	"context"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func (s *Syncer) pull(ctx context.Context, result *Result) error {
+	cp, err := s.checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	seq, err := changesSince(ctx, s.remote, cp.PullSeq, func(docID, rev string) error {
+		return s.pullDoc(ctx, docID, rev, result)
+	})
+	if err != nil {
+		return err
+	}
+	cp.PullSeq = seq
+	return s.saveCheckpoint(ctx, cp)
+}
+
+// pullDoc copies the current remote version of docID to the local database,
+// unless it has already been synchronized. changedRev is the revision reported
+// by the changes feed.
+func (s *Syncer) pullDoc(ctx context.Context, docID, changedRev string, result *Result) error {
+	state, err := s.state(ctx, docID)
+	if err != nil || changedRev == state.RemoteRev {
+		return err
+	}
+	remoteDoc, remoteRev, err := getDoc(ctx, s.remote, docID)
+	if err != nil || remoteRev == state.RemoteRev {
+		return err
+	}
+	localDoc, localRev, err := getDoc(ctx, s.local, docID)
+	if err != nil {
+		return err
+	}
+	if localRev != state.LocalRev {
+		return s.resolve(ctx, docID, state, localDoc, localRev, remoteDoc, remoteRev, result)
+	}
+	if state.LocalRev, err = writeDoc(ctx, s.local, docID, localRev, remoteDoc); err != nil {
+		return err
+	}
+	state.RemoteRev = remoteRev
+	result.Pulled++
+	return s.saveState(ctx, docID, state)
+}
+
+func (s *Syncer) push(ctx context.Context, result *Result) error {
+	cp, err := s.checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	seq, err := changesSince(ctx, s.local, cp.PushSeq, func(docID, rev string) error {
+		return s.pushDoc(ctx, docID, rev, result)
+	})
+	if err != nil {
+		return err
+	}
+	cp.PushSeq = seq
+	return s.saveCheckpoint(ctx, cp)
+}
+
+// pushDoc copies the current local version of docID to the remote database,
+// unless it has already been synchronized. changedRev is the revision reported
+// by the changes feed.
+func (s *Syncer) pushDoc(ctx context.Context, docID, changedRev string, result *Result) error {
+	state, err := s.state(ctx, docID)
+	if err != nil || changedRev == state.LocalRev {
+		return err
+	}
+	localDoc, localRev, err := getDoc(ctx, s.local, docID)
+	if err != nil || localRev == state.LocalRev {
+		return err
+	}
+	remoteRev, err := writeDoc(ctx, s.remote, docID, state.RemoteRev, localDoc)
+	if kivik.HTTPStatus(err) == http.StatusConflict {
+		remoteDoc, remoteRev, err := getDoc(ctx, s.remote, docID)
+		if err != nil {
+			return err
+		}
+		return s.resolve(ctx, docID, state, localDoc, localRev, remoteDoc, remoteRev, result)
+	}
+	if err != nil {
+		return err
+	}
+	state.LocalRev, state.RemoteRev = localRev, remoteRev
+	result.Pushed++
+	return s.saveState(ctx, docID, state)
+}
+
+// resolve passes a conflict to the conflict handler, and stores the resolved
+// document on both sides.
+func (s *Syncer) resolve(ctx context.Context, docID string, state *docState, localDoc map[string]interface{}, localRev string, remoteDoc map[string]interface{}, remoteRev string, result *Result) error {
+	resolved, err := s.onConflict(ctx, &Conflict{ID: docID, Local: localDoc, Remote: remoteDoc})
+	if err != nil {
+		return err
+	}
+	if state.RemoteRev, err = writeDoc(ctx, s.remote, docID, remoteRev, resolved); err != nil {
+		return err
+	}
+	if state.LocalRev, err = writeDoc(ctx, s.local, docID, localRev, resolved); err != nil {
+		return err
+	}
+	result.Conflicts++
+	return s.saveState(ctx, docID, state)
+}
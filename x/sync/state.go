@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sync
+
+import (
+	"context"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+const (
+	checkpointID = "_local/kivik-sync"
+	statePrefix  = "_local/kivik-sync:"
+)
+
+// checkpoint records how far each changes feed has been processed.
+type checkpoint struct {
+	Rev     string `json:"_rev,omitempty"`
+	PullSeq string `json:"pull_seq"`
+	PushSeq string `json:"push_seq"`
+}
+
+// docState records the local and remote revisions of a document when it was
+// last synchronized. An empty revision means the document did not exist, or
+// was deleted.
+type docState struct {
+	Rev       string `json:"_rev,omitempty"`
+	LocalRev  string `json:"local_rev"`
+	RemoteRev string `json:"remote_rev"`
+}
+
+// getLocal reads the local document docID into dest, leaving dest unchanged
+// if it does not exist.
+func (s *Syncer) getLocal(ctx context.Context, docID string, dest interface{}) error {
+	err := s.local.Get(ctx, docID).ScanDoc(dest)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Syncer) checkpoint(ctx context.Context) (*checkpoint, error) {
+	cp := &checkpoint{}
+	return cp, s.getLocal(ctx, checkpointID, cp)
+}
+
+func (s *Syncer) saveCheckpoint(ctx context.Context, cp *checkpoint) error {
+	rev, err := s.local.Put(ctx, checkpointID, cp)
+	cp.Rev = rev
+	return err
+}
+
+func (s *Syncer) state(ctx context.Context, docID string) (*docState, error) {
+	state := &docState{}
+	return state, s.getLocal(ctx, statePrefix+docID, state)
+}
+
+func (s *Syncer) saveState(ctx context.Context, docID string, state *docState) error {
+	rev, err := s.local.Put(ctx, statePrefix+docID, state)
+	state.Rev = rev
+	return err
+}
+
+// changesSince calls fn for each non-local document in the changes feed of db
+// since seq, with the document's current revision, or an empty revision if it
+// has been deleted. It returns the last sequence of the feed.
+func changesSince(ctx context.Context, db *kivik.DB, seq string, fn func(docID, rev string) error) (string, error) {
+	opts := kivik.Options{}
+	if seq != "" {
+		opts["since"] = seq
+	}
+	changes := db.Changes(ctx, opts)
+	defer changes.Close() // nolint:errcheck
+	for changes.Next() {
+		id := changes.ID()
+		if isLocal(id) {
+			continue
+		}
+		var rev string
+		if revs := changes.Changes(); len(revs) > 0 && !changes.Deleted() {
+			rev = revs[0]
+		}
+		if err := fn(id, rev); err != nil {
+			return "", err
+		}
+	}
+	if err := changes.Err(); err != nil {
+		return "", err
+	}
+	meta, err := changes.Metadata()
+	if err != nil {
+		return "", err
+	}
+	return meta.LastSeq, nil
+}
@@ -0,0 +1,269 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package sync keeps a local database, such as one provided by the memory,
+// file or SQLite drivers, synchronized with a remote CouchDB database, for
+// applications which must keep working while offline.
+//
+// Writes are made to the local database as usual. A [Syncer] tracks which
+// local documents have been modified since they were last synchronized, and
+// on each call to [Syncer.Sync] pulls remote changes into the local database
+// and pushes pending local writes to the remote one. [Syncer.Run] does the
+// same on a schedule.
+//
+//	local := localClient.DB("notes")
+//	remote := couchClient.DB("notes")
+//	s := sync.New(local, remote, sync.Config{
+//	    OnConflict: func(ctx context.Context, c *sync.Conflict) (interface{}, error) {
+//	        return c.Local, nil // Local edits win
+//	    },
+//	})
+//	go s.Run(ctx, time.Minute)
+//
+// Unlike CouchDB replication, the local database does not need to store the
+// remote revision history, so any driver may be used. Instead, the Syncer
+// records the local and remote revisions of each document as it was last
+// synchronized, in local documents (with the prefix "_local/kivik-sync:") in
+// the local database. A document modified on both sides since then is a
+// conflict, which is passed to [Config.OnConflict] to be resolved.
+//
+// Local deletions are only pushed if the local driver reports them in its
+// changes feed, which the file driver does not.
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Conflict describes a document which has been modified both locally and
+// remotely since it was last synchronized.
+type Conflict struct {
+	ID string
+	// Local is the current local document, or nil if it was deleted locally.
+	Local map[string]interface{}
+	// Remote is the current remote document, or nil if it was deleted
+	// remotely.
+	Remote map[string]interface{}
+}
+
+// ConflictFunc resolves a conflict, by returning the document to be stored
+// both locally and remotely, or nil to delete it on both sides. Any _id and
+// _rev fields in the returned document are ignored.
+type ConflictFunc func(ctx context.Context, c *Conflict) (interface{}, error)
+
+// RemoteWins is a ConflictFunc which keeps the remote version of the document.
+func RemoteWins(_ context.Context, c *Conflict) (interface{}, error) {
+	return c.Remote, nil
+}
+
+// Config configures a [Syncer].
+type Config struct {
+	// OnConflict is called for each document modified on both sides since it
+	// was last synchronized. If it returns an error, the document is left
+	// unchanged on both sides, and the error is returned by Sync. If nil,
+	// [RemoteWins] is used.
+	OnConflict ConflictFunc
+
+	// OnError is called by [Syncer.Run] with any error returned by Sync. If
+	// nil, such errors are discarded, and synchronization is attempted again
+	// at the next interval.
+	OnError func(error)
+}
+
+// Result summarizes one call to [Syncer.Sync].
+type Result struct {
+	// Pulled is the number of remote changes written to the local database.
+	Pulled int
+	// Pushed is the number of local changes written to the remote database.
+	Pushed int
+	// Conflicts is the number of conflicts resolved.
+	Conflicts int
+}
+
+// Syncer synchronizes a local database with a remote one. It is safe for
+// concurrent use, but only one synchronization runs at a time.
+type Syncer struct {
+	local, remote *kivik.DB
+	onConflict    ConflictFunc
+	onError       func(error)
+
+	mu stdsync.Mutex
+}
+
+// New returns a Syncer for the given local and remote databases. No requests
+// are made until Sync or Run is called.
+func New(local, remote *kivik.DB, config Config) *Syncer {
+	s := &Syncer{
+		local:      local,
+		remote:     remote,
+		onConflict: config.OnConflict,
+		onError:    config.OnError,
+	}
+	if s.onConflict == nil {
+		s.onConflict = RemoteWins
+	}
+	return s
+}
+
+// Run calls Sync immediately, and then every interval, until ctx is
+// cancelled.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sync(ctx); err != nil && s.onError != nil && ctx.Err() == nil {
+			s.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pulls remote changes into the local database, resolving any
+// conflicts, and then pushes pending local changes to the remote database.
+func (s *Syncer) Sync(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := &Result{}
+	if err := s.pull(ctx, result); err != nil {
+		return result, err
+	}
+	err := s.push(ctx, result)
+	return result, err
+}
+
+// Pull pulls remote changes into the local database, resolving any
+// conflicts.
+func (s *Syncer) Pull(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := &Result{}
+	err := s.pull(ctx, result)
+	return result, err
+}
+
+// Push pushes pending local changes to the remote database, resolving any
+// conflicts.
+func (s *Syncer) Push(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := &Result{}
+	err := s.push(ctx, result)
+	return result, err
+}
+
+// Pending returns the IDs of local documents which have been modified since
+// they were last synchronized, in the order they were modified.
+func (s *Syncer) Pending(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, err := s.checkpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	_, err = changesSince(ctx, s.local, cp.PushSeq, func(id, rev string) error {
+		state, err := s.state(ctx, id)
+		if err != nil {
+			return err
+		}
+		if rev != state.LocalRev {
+			pending = append(pending, id)
+		}
+		return nil
+	})
+	return pending, err
+}
+
+func isLocal(docID string) bool {
+	return strings.HasPrefix(docID, "_local/")
+}
+
+func isNotFound(err error) bool {
+	return kivik.HTTPStatus(err) == http.StatusNotFound
+}
+
+// getDoc fetches the current version of a document, with its attachments.
+// If the document does not exist, doc and rev are empty.
+func getDoc(ctx context.Context, db *kivik.DB, docID string) (doc map[string]interface{}, rev string, err error) {
+	row := db.Get(ctx, docID, kivik.Options{"attachments": true})
+	if err := row.ScanDoc(&doc); err != nil {
+		if isNotFound(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	rev, _ = doc["_rev"].(string)
+	return doc, rev, nil
+}
+
+// writeDoc stores doc as the new version of docID, replacing rev, and
+// returns the new revision. If doc is nil, or a nil map, the document is
+// deleted, and an empty revision is returned.
+func writeDoc(ctx context.Context, db *kivik.DB, docID, rev string, doc interface{}) (string, error) {
+	body, err := prepare(doc)
+	if err != nil {
+		return "", err
+	}
+	if body == nil {
+		if rev == "" {
+			return "", nil
+		}
+		_, err := db.Delete(ctx, docID, rev)
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	opts := kivik.Options{}
+	if rev != "" {
+		opts["rev"] = rev
+	}
+	return db.Put(ctx, docID, body, opts)
+}
+
+// prepare returns a copy of doc suitable for writing to another database:
+// without revision information, and with inline attachments reduced to
+// their content type and data. A nil document results in a nil map.
+func prepare(doc interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "document must be a JSON object"}
+	}
+	for _, k := range []string{"_id", "_rev", "_revisions", "_conflicts", "_deleted"} {
+		delete(body, k)
+	}
+	if atts, ok := body["_attachments"].(map[string]interface{}); ok {
+		for name, a := range atts {
+			att, _ := a.(map[string]interface{})
+			atts[name] = map[string]interface{}{
+				"content_type": att["content_type"],
+				"data":         att["data"],
+			}
+		}
+	}
+	return body, nil
+}
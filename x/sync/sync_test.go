@@ -0,0 +1,254 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+// newDB returns a new, empty in-memory database.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "notes"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("notes")
+}
+
+func put(t *testing.T, db *kivik.DB, docID string, doc map[string]interface{}) string {
+	t.Helper()
+	ctx := context.Background()
+	if rev, _ := db.GetRev(ctx, docID); rev != "" {
+		doc["_rev"] = rev
+	}
+	rev, err := db.Put(ctx, docID, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+// body returns the document's fields, other than _id and _rev, or nil if it
+// does not exist.
+func body(t *testing.T, db *kivik.DB, docID string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := db.Get(context.Background(), docID).ScanDoc(&doc); err != nil {
+		if kivik.HTTPStatus(err) == http.StatusNotFound {
+			return nil
+		}
+		t.Fatal(err)
+	}
+	delete(doc, "_id")
+	delete(doc, "_rev")
+	return doc
+}
+
+func checkSync(t *testing.T, s *Syncer, want Result) {
+	t.Helper()
+	result, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&want, result); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	local, remote := newDB(t), newDB(t)
+	s := New(local, remote, Config{})
+
+	put(t, remote, "a", map[string]interface{}{"text": "from remote"})
+	put(t, local, "b", map[string]interface{}{"text": "from local"})
+	checkSync(t, s, Result{Pulled: 1, Pushed: 1})
+	if d := testy.DiffInterface(map[string]interface{}{"text": "from remote"}, body(t, local, "a")); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(map[string]interface{}{"text": "from local"}, body(t, remote, "b")); d != nil {
+		t.Error(d)
+	}
+	checkSync(t, s, Result{})
+
+	put(t, local, "b", map[string]interface{}{"text": "edited locally"})
+	pending, err := s.Pending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"b"}, pending); d != nil {
+		t.Error(d)
+	}
+	put(t, remote, "a", map[string]interface{}{"text": "edited remotely"})
+	checkSync(t, s, Result{Pulled: 1, Pushed: 1})
+	if d := testy.DiffInterface(map[string]interface{}{"text": "edited remotely"}, body(t, local, "a")); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface(map[string]interface{}{"text": "edited locally"}, body(t, remote, "b")); d != nil {
+		t.Error(d)
+	}
+	if pending, _ := s.Pending(ctx); len(pending) != 0 {
+		t.Errorf("Unexpected pending documents: %v", pending)
+	}
+
+	t.Run("deletions", func(t *testing.T) {
+		rev, _ := local.GetRev(ctx, "b")
+		if _, err := local.Delete(ctx, "b", rev); err != nil {
+			t.Fatal(err)
+		}
+		rev, _ = remote.GetRev(ctx, "a")
+		if _, err := remote.Delete(ctx, "a", rev); err != nil {
+			t.Fatal(err)
+		}
+		checkSync(t, s, Result{Pulled: 1, Pushed: 1})
+		if doc := body(t, local, "a"); doc != nil {
+			t.Errorf("Expected a to be deleted locally, got %v", doc)
+		}
+		if doc := body(t, remote, "b"); doc != nil {
+			t.Errorf("Expected b to be deleted remotely, got %v", doc)
+		}
+		checkSync(t, s, Result{})
+	})
+	t.Run("attachments", func(t *testing.T) {
+		rev := put(t, local, "c", map[string]interface{}{})
+		if _, err := local.PutAttachment(ctx, "c", &kivik.Attachment{
+			Filename:    "note.txt",
+			ContentType: "text/plain",
+			Content:     io.NopCloser(strings.NewReader("hello")),
+		}, kivik.Options{"rev": rev}); err != nil {
+			t.Fatal(err)
+		}
+		checkSync(t, s, Result{Pushed: 1})
+		att, err := remote.GetAttachment(ctx, "c", "note.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(att.Content)
+		_ = att.Content.Close()
+		if string(content) != "hello" || att.ContentType != "text/plain" {
+			t.Errorf("Unexpected attachment: %s %q", att.ContentType, content)
+		}
+	})
+}
+
+func TestConflicts(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, config Config) (local, remote *kivik.DB, s *Syncer) {
+		t.Helper()
+		local, remote = newDB(t), newDB(t)
+		s = New(local, remote, config)
+		put(t, remote, "a", map[string]interface{}{"n": 1})
+		checkSync(t, s, Result{Pulled: 1})
+		put(t, local, "a", map[string]interface{}{"n": 2})
+		put(t, remote, "a", map[string]interface{}{"n": 3})
+		return local, remote, s
+	}
+
+	t.Run("remote wins by default", func(t *testing.T) {
+		local, remote, s := setup(t, Config{})
+		checkSync(t, s, Result{Conflicts: 1})
+		for _, db := range []*kivik.DB{local, remote} {
+			if d := testy.DiffInterface(map[string]interface{}{"n": 3.0}, body(t, db, "a")); d != nil {
+				t.Error(d)
+			}
+		}
+		checkSync(t, s, Result{})
+	})
+	t.Run("custom resolution", func(t *testing.T) {
+		local, remote, s := setup(t, Config{
+			OnConflict: func(_ context.Context, c *Conflict) (interface{}, error) {
+				if c.ID != "a" {
+					t.Errorf("Unexpected conflict ID: %s", c.ID)
+				}
+				return map[string]interface{}{"n": c.Local["n"].(float64) + c.Remote["n"].(float64)}, nil
+			},
+		})
+		checkSync(t, s, Result{Conflicts: 1})
+		for _, db := range []*kivik.DB{local, remote} {
+			if d := testy.DiffInterface(map[string]interface{}{"n": 5.0}, body(t, db, "a")); d != nil {
+				t.Error(d)
+			}
+		}
+	})
+	t.Run("deleted locally", func(t *testing.T) {
+		local, remote, s := setup(t, Config{
+			OnConflict: func(_ context.Context, c *Conflict) (interface{}, error) {
+				if c.Local != nil {
+					t.Errorf("Expected no local document, got %v", c.Local)
+				}
+				return nil, nil
+			},
+		})
+		rev, _ := local.GetRev(ctx, "a")
+		if _, err := local.Delete(ctx, "a", rev); err != nil {
+			t.Fatal(err)
+		}
+		checkSync(t, s, Result{Conflicts: 1})
+		if doc := body(t, remote, "a"); doc != nil {
+			t.Errorf("Expected a to be deleted remotely, got %v", doc)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		local, _, s := setup(t, Config{
+			OnConflict: func(context.Context, *Conflict) (interface{}, error) {
+				return nil, errors.New("cannot resolve")
+			},
+		})
+		_, err := s.Sync(ctx)
+		if err == nil || err.Error() != "cannot resolve" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"n": 2.0}, body(t, local, "a")); d != nil {
+			t.Error(d)
+		}
+		if pending, _ := s.Pending(ctx); len(pending) != 1 {
+			t.Errorf("Expected local edit to remain pending, got %v", pending)
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	local, remote := newDB(t), newDB(t)
+	s := New(local, remote, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	put(t, local, "a", map[string]interface{}{"text": "hello"})
+	deadline := time.Now().Add(5 * time.Second)
+	for body(t, remote, "a") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Document was not pushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
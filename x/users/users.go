@@ -0,0 +1,273 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package users provides helpers for managing CouchDB users, which are stored
+// as specially-formatted documents in the _users database.
+//
+//	u := users.New(client.DB(users.DBName))
+//	rev, err := u.Create(ctx, "bob", "abc123", "editors")
+//
+// By default, passwords are sent to the server in the clear, to be hashed by
+// CouchDB when the user document is stored. Set [Users.HashIterations] to hash
+// them locally instead.
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DBName is the name of the default CouchDB users database.
+const DBName = "_users"
+
+// User is a CouchDB user.
+type User struct {
+	// Name is the user name.
+	Name string `json:"name"`
+	// Roles lists the roles granted to the user.
+	Roles []string `json:"roles"`
+	// Rev is the revision of the user document.
+	Rev string `json:"_rev"`
+	// PasswordScheme is the scheme used to hash the password, such as
+	// "pbkdf2", or empty if the server has not yet hashed it.
+	PasswordScheme string `json:"password_scheme,omitempty"`
+}
+
+// Users manages the users stored in a users database.
+type Users struct {
+	db *kivik.DB
+
+	// HashIterations, if non-zero, causes passwords to be hashed locally with
+	// PBKDF2-SHA256, using this many iterations, and stored in the format used
+	// by CouchDB 3.4 and later. Otherwise passwords are sent in the clear, and
+	// hashed by the server.
+	HashIterations int
+}
+
+// New returns a Users which manages the users stored in db, which is normally
+// the database named [DBName].
+func New(db *kivik.DB) *Users {
+	return &Users{db: db}
+}
+
+// DocID returns the ID of the document for the user name.
+func DocID(name string) string {
+	return kivik.UserPrefix + name
+}
+
+func validateName(name string) error {
+	switch {
+	case name == "":
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: user name required"}
+	case strings.HasPrefix(name, "_"):
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: user name must not begin with an underscore"}
+	}
+	return nil
+}
+
+// normalizeRoles returns roles sorted and without duplicates, or an error if
+// any role is reserved.
+func normalizeRoles(roles []string) ([]string, error) {
+	seen := make(map[string]bool, len(roles))
+	result := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if strings.HasPrefix(role, "_") {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: role " + role + " is reserved"}
+		}
+		if role == "" || seen[role] {
+			continue
+		}
+		seen[role] = true
+		result = append(result, role)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// setPassword stores password in doc, either in the clear, or hashed.
+func (u *Users) setPassword(doc map[string]interface{}, password string) error {
+	if password == "" {
+		return &kivik.Error{Status: http.StatusBadRequest, Message: "kivik: password required"}
+	}
+	for _, field := range []string{"password", "password_scheme", "pbkdf2_prf", "iterations", "salt", "derived_key", "password_sha"} {
+		delete(doc, field)
+	}
+	if u.HashIterations <= 0 {
+		doc["password"] = password
+		return nil
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
+	doc["password_scheme"] = "pbkdf2"
+	doc["pbkdf2_prf"] = "sha256"
+	doc["iterations"] = u.HashIterations
+	doc["salt"] = salt
+	doc["derived_key"] = derivedKey(password, salt, u.HashIterations)
+	return nil
+}
+
+// Create creates a new user, and returns the revision of the user document.
+// It fails with a 409 Conflict status if the user already exists.
+func (u *Users) Create(ctx context.Context, name, password string, roles ...string) (string, error) {
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	roles, err := normalizeRoles(roles)
+	if err != nil {
+		return "", err
+	}
+	doc := map[string]interface{}{
+		"_id":   DocID(name),
+		"name":  name,
+		"type":  "user",
+		"roles": roles,
+	}
+	if err := u.setPassword(doc, password); err != nil {
+		return "", err
+	}
+	return u.db.Put(ctx, DocID(name), doc)
+}
+
+// Get returns the user name.
+func (u *Users) Get(ctx context.Context, name string) (*User, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	user := &User{}
+	if err := u.db.Get(ctx, DocID(name)).ScanDoc(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List returns the names of all users, in sorted order.
+func (u *Users) List(ctx context.Context) ([]string, error) {
+	rs := u.db.AllDocs(ctx, kivik.StartKey(kivik.UserPrefix), kivik.EndKey(kivik.UserPrefix+"\ufff0"))
+	defer rs.Close() // nolint:errcheck
+	names := []string{}
+	for rs.Next() {
+		id, err := rs.ID()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, strings.TrimPrefix(id, kivik.UserPrefix))
+	}
+	return names, rs.Err()
+}
+
+// update applies fn to the existing document for the user name, retrying on
+// conflicts, and returns the new revision.
+func (u *Users) update(ctx context.Context, name string, fn func(doc map[string]interface{}) error) (string, error) {
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	return u.db.Update(ctx, DocID(name), func(raw json.RawMessage) (interface{}, error) {
+		if raw == nil {
+			return nil, &kivik.Error{Status: http.StatusNotFound, Message: "kivik: user " + name + " not found"}
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if err := fn(doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	})
+}
+
+// SetPassword changes the password of the user name, and returns the new
+// revision of the user document. Other fields of the document are preserved.
+func (u *Users) SetPassword(ctx context.Context, name, password string) (string, error) {
+	return u.update(ctx, name, func(doc map[string]interface{}) error {
+		return u.setPassword(doc, password)
+	})
+}
+
+// SetRoles replaces the roles of the user name, and returns the new revision
+// of the user document.
+func (u *Users) SetRoles(ctx context.Context, name string, roles ...string) (string, error) {
+	roles, err := normalizeRoles(roles)
+	if err != nil {
+		return "", err
+	}
+	return u.update(ctx, name, func(doc map[string]interface{}) error {
+		doc["roles"] = roles
+		return nil
+	})
+}
+
+// AddRoles grants roles to the user name, in addition to any existing roles,
+// and returns the new revision of the user document.
+func (u *Users) AddRoles(ctx context.Context, name string, roles ...string) (string, error) {
+	if _, err := normalizeRoles(roles); err != nil {
+		return "", err
+	}
+	return u.update(ctx, name, func(doc map[string]interface{}) error {
+		merged, err := normalizeRoles(append(docRoles(doc), roles...))
+		if err != nil {
+			return err
+		}
+		doc["roles"] = merged
+		return nil
+	})
+}
+
+// RemoveRoles revokes roles from the user name, and returns the new revision
+// of the user document.
+func (u *Users) RemoveRoles(ctx context.Context, name string, roles ...string) (string, error) {
+	remove := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		remove[role] = true
+	}
+	return u.update(ctx, name, func(doc map[string]interface{}) error {
+		kept := []string{}
+		for _, role := range docRoles(doc) {
+			if !remove[role] {
+				kept = append(kept, role)
+			}
+		}
+		doc["roles"] = kept
+		return nil
+	})
+}
+
+func docRoles(doc map[string]interface{}) []string {
+	list, _ := doc["roles"].([]interface{})
+	roles := make([]string, 0, len(list))
+	for _, role := range list {
+		if s, ok := role.(string); ok {
+			roles = append(roles, s)
+		}
+	}
+	return roles
+}
+
+// Delete deletes the user name, and returns the revision of the deleted user
+// document.
+func (u *Users) Delete(ctx context.Context, name string) (string, error) {
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	rev, err := u.db.GetRev(ctx, DocID(name))
+	if err != nil {
+		return "", err
+	}
+	return u.db.Delete(ctx, DocID(name), rev)
+}
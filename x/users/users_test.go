@@ -0,0 +1,194 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package users
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newUsers(t *testing.T) (*Users, *kivik.DB) {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The memory driver does not permit system database names.
+	if err := client.CreateDB(context.Background(), "users"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("users")
+	return New(db), db
+}
+
+// checkError is like testy.StatusError, but does not end the test when err is
+// non-nil.
+func checkError(t *testing.T, want string, status int, err error) {
+	t.Helper()
+	if !testy.ErrorMatches(want, err) {
+		t.Errorf("Unexpected error: %v (want %q)", err, want)
+	}
+	if err != nil && kivik.HTTPStatus(err) != status {
+		t.Errorf("Unexpected status: %d (want %d)", kivik.HTTPStatus(err), status)
+	}
+}
+
+func getDoc(t *testing.T, db *kivik.DB, name string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := db.Get(context.Background(), DocID(name)).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	u, db := newUsers(t)
+
+	if _, err := u.Create(ctx, "bob", "abc123", "editors", "admins", "editors"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Create(ctx, "alice", "xyz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "other", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	doc := getDoc(t, db, "bob")
+	wantDoc := map[string]interface{}{
+		"_id":      "org.couchdb.user:bob",
+		"_rev":     doc["_rev"],
+		"name":     "bob",
+		"type":     "user",
+		"roles":    []interface{}{"admins", "editors"},
+		"password": "abc123",
+	}
+	if d := testy.DiffInterface(wantDoc, doc); d != nil {
+		t.Error(d)
+	}
+
+	_, err := u.Create(ctx, "bob", "abc123")
+	checkError(t, "document update conflict", http.StatusConflict, err)
+
+	names, err := u.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"alice", "bob"}, names); d != nil {
+		t.Error(d)
+	}
+
+	if _, err := u.AddRoles(ctx, "bob", "readers", "admins"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.RemoveRoles(ctx, "bob", "editors"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.SetPassword(ctx, "bob", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := u.Get(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&User{Name: "bob", Roles: []string{"admins", "readers"}, Rev: user.Rev}, user); d != nil {
+		t.Error(d)
+	}
+	if password := getDoc(t, db, "bob")["password"]; password != "newpass" {
+		t.Errorf("Unexpected password: %v", password)
+	}
+
+	if _, err := u.SetRoles(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if roles := getDoc(t, db, "alice")["roles"]; len(roles.([]interface{})) != 0 {
+		t.Errorf("Unexpected roles: %v", roles)
+	}
+
+	if _, err := u.Delete(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = u.Get(ctx, "alice")
+	checkError(t, "deleted", http.StatusNotFound, err)
+}
+
+func TestUsersErrors(t *testing.T) {
+	ctx := context.Background()
+	u, _ := newUsers(t)
+
+	_, err := u.Create(ctx, "", "abc")
+	checkError(t, "kivik: user name required", http.StatusBadRequest, err)
+	_, err = u.Create(ctx, "_admin", "abc")
+	checkError(t, "kivik: user name must not begin with an underscore", http.StatusBadRequest, err)
+	_, err = u.Create(ctx, "bob", "abc", "_admin")
+	checkError(t, "kivik: role _admin is reserved", http.StatusBadRequest, err)
+	_, err = u.Create(ctx, "bob", "")
+	checkError(t, "kivik: password required", http.StatusBadRequest, err)
+	_, err = u.SetPassword(ctx, "nobody", "abc")
+	checkError(t, "kivik: user nobody not found", http.StatusNotFound, err)
+	_, err = u.Delete(ctx, "nobody")
+	checkError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestHashedPasswords(t *testing.T) {
+	ctx := context.Background()
+	u, db := newUsers(t)
+	u.HashIterations = 10
+
+	if _, err := u.Create(ctx, "bob", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	doc := getDoc(t, db, "bob")
+	if _, ok := doc["password"]; ok {
+		t.Error("password should not be stored in the clear")
+	}
+	salt, _ := doc["salt"].(string)
+	if len(salt) != 32 {
+		t.Errorf("Unexpected salt: %q", salt)
+	}
+	if doc["password_scheme"] != "pbkdf2" || doc["pbkdf2_prf"] != "sha256" || doc["iterations"] != float64(10) {
+		t.Errorf("Unexpected hash parameters: %v", doc)
+	}
+	if want := derivedKey("abc123", salt, 10); doc["derived_key"] != want {
+		t.Errorf("Unexpected derived key: %v (want %s)", doc["derived_key"], want)
+	}
+
+	// Changing the password replaces the hash and salt.
+	if _, err := u.SetPassword(ctx, "bob", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	doc = getDoc(t, db, "bob")
+	if doc["salt"] == salt {
+		t.Error("salt should change along with the password")
+	}
+	if want := derivedKey("newpass", doc["salt"].(string), 10); doc["derived_key"] != want {
+		t.Errorf("Unexpected derived key: %v (want %s)", doc["derived_key"], want)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vector from RFC 7914, section 11.
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("Unexpected key:\n got: %s\nwant: %s", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+// entry is a single row of a view's index.
+type entry struct {
+	id       string
+	key      interface{}
+	rawKey   json.RawMessage
+	value    interface{}
+	rawValue json.RawMessage
+}
+
+// index is the index of a single view.
+type index struct {
+	byDoc map[string][]entry
+	// sorted holds all entries in collation order, or is nil if it must be
+	// rebuilt.
+	sorted []entry
+}
+
+func (idx *index) entries() []entry {
+	if idx.sorted != nil {
+		return idx.sorted
+	}
+	sorted := make([]entry, 0, len(idx.byDoc))
+	for _, entries := range idx.byDoc {
+		sorted = append(sorted, entries...)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := mango.Compare(sorted[i].key, sorted[j].key); c != 0 {
+			return c < 0
+		}
+		return sorted[i].id < sorted[j].id
+	})
+	idx.sorted = sorted
+	return sorted
+}
+
+// engine maintains the indexes of all views for a single database.
+type engine struct {
+	defs definitions
+
+	mu sync.Mutex
+	// seq is the last update sequence indexed, or empty before the first
+	// update.
+	seq     string
+	indexes map[string]*index
+}
+
+func newEngine(defs definitions) *engine {
+	e := &engine{
+		defs:    defs,
+		indexes: map[string]*index{},
+	}
+	for ddoc, views := range defs {
+		for name := range views {
+			e.indexes[ddoc+"/"+name] = &index{byDoc: map[string][]entry{}}
+		}
+	}
+	return e
+}
+
+// update indexes the changes to db since the last update. e.mu must be held.
+func (e *engine) update(ctx context.Context, db driver.DB) error {
+	opts := map[string]interface{}{"include_docs": true}
+	if e.seq != "" {
+		opts["since"] = e.seq
+	}
+	changes, err := db.Changes(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer changes.Close() // nolint:errcheck
+	var change driver.Change
+	for {
+		change = driver.Change{}
+		err := changes.Next(&change)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.index(ctx, db, &change); err != nil {
+			return err
+		}
+		if change.Seq != "" {
+			e.seq = change.Seq
+		}
+	}
+	if seq := changes.LastSeq(); seq != "" {
+		e.seq = seq
+	}
+	return nil
+}
+
+// index replaces the entries for a changed document.
+func (e *engine) index(ctx context.Context, db driver.DB, change *driver.Change) error {
+	if strings.HasPrefix(change.ID, "_design/") || strings.HasPrefix(change.ID, "_local/") {
+		return nil
+	}
+	var doc map[string]interface{}
+	if !change.Deleted {
+		raw := change.Doc
+		if len(raw) == 0 || string(raw) == "null" {
+			d, err := db.Get(ctx, change.ID, nil)
+			if err != nil {
+				return err
+			}
+			raw, err = io.ReadAll(d.Body)
+			_ = d.Body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+	}
+	for ddoc, views := range e.defs {
+		for name, view := range views {
+			idx := e.indexes[ddoc+"/"+name]
+			delete(idx.byDoc, change.ID)
+			idx.sorted = nil
+			if doc != nil {
+				if entries := mapDoc(view.Map, change.ID, doc); len(entries) > 0 {
+					idx.byDoc[change.ID] = entries
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mapDoc calls fn with doc, and returns the rows emitted. If fn panics, or
+// emits a value which cannot be encoded as JSON, no rows are returned.
+func mapDoc(fn MapFunc, docID string, doc map[string]interface{}) (entries []entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			entries = nil
+		}
+	}()
+	fn(doc, func(key, value interface{}) {
+		e := entry{id: docID}
+		var err error
+		if e.rawKey, e.key, err = normalize(key); err != nil {
+			panic(err)
+		}
+		if e.rawValue, e.value, err = normalize(value); err != nil {
+			panic(err)
+		}
+		entries = append(entries, e)
+	})
+	return entries
+}
+
+// normalize returns v encoded as JSON, and decoded again, so that values
+// emitted by map functions, and given as query options, compare as JSON.
+func normalize(v interface{}) (json.RawMessage, interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("views: %w", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, nil, fmt.Errorf("views: %w", err)
+	}
+	return raw, decoded, nil
+}
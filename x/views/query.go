@@ -0,0 +1,380 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package views
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/x/mango"
+)
+
+// queryOptions are the parsed options of a view query.
+type queryOptions struct {
+	keys         []interface{}
+	hasKeys      bool
+	startKey     interface{}
+	hasStart     bool
+	endKey       interface{}
+	hasEnd       bool
+	inclusiveEnd bool
+	descending   bool
+	skip         int64
+	limit        int64
+	includeDocs  bool
+	reduce       bool
+	groupLevel   int // 0 for no grouping, -1 for exact grouping
+	update       bool
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func boolOption(options map[string]interface{}, key string, def bool) (bool, error) {
+	switch t := options[key].(type) {
+	case nil:
+		return def, nil
+	case bool:
+		return t, nil
+	case string:
+		b, err := strconv.ParseBool(t)
+		if err != nil {
+			return false, badRequest("invalid value for %s: %q", key, t)
+		}
+		return b, nil
+	}
+	return false, badRequest("invalid value for %s: %v", key, options[key])
+}
+
+func intOption(options map[string]interface{}, key string, def int64) (int64, error) {
+	var i int64
+	switch t := options[key].(type) {
+	case nil:
+		return def, nil
+	case int:
+		i = int64(t)
+	case int64:
+		i = t
+	case float64:
+		i = int64(t)
+	case string:
+		var err error
+		if i, err = strconv.ParseInt(t, 10, 64); err != nil {
+			return 0, badRequest("invalid value for %s: %q", key, t)
+		}
+	default:
+		return 0, badRequest("invalid value for %s: %v", key, options[key])
+	}
+	if i < 0 {
+		return 0, badRequest("invalid value for %s: %d", key, i)
+	}
+	return i, nil
+}
+
+// keyOption returns the first of the named options which is set, decoded as
+// a JSON value.
+func keyOption(options map[string]interface{}, names ...string) (interface{}, bool, error) {
+	for _, name := range names {
+		if v, ok := options[name]; ok {
+			_, key, err := normalize(v)
+			if err != nil {
+				return nil, false, badRequest("invalid value for %s: %s", name, err)
+			}
+			return key, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func parseOptions(options map[string]interface{}, def View) (*queryOptions, error) {
+	opts := &queryOptions{limit: -1}
+	var err error
+	if opts.startKey, opts.hasStart, err = keyOption(options, "startkey", "start_key"); err != nil {
+		return nil, err
+	}
+	if opts.endKey, opts.hasEnd, err = keyOption(options, "endkey", "end_key"); err != nil {
+		return nil, err
+	}
+	if key, ok, err := keyOption(options, "key"); err != nil {
+		return nil, err
+	} else if ok {
+		opts.startKey, opts.hasStart = key, true
+		opts.endKey, opts.hasEnd = key, true
+	}
+	if keys, ok, err := keyOption(options, "keys"); err != nil {
+		return nil, err
+	} else if ok {
+		if opts.keys, ok = keys.([]interface{}); !ok {
+			return nil, badRequest("keys must be an array")
+		}
+		opts.hasKeys = true
+	}
+	if opts.inclusiveEnd, err = boolOption(options, "inclusive_end", true); err != nil {
+		return nil, err
+	}
+	if opts.descending, err = boolOption(options, "descending", false); err != nil {
+		return nil, err
+	}
+	if opts.includeDocs, err = boolOption(options, "include_docs", false); err != nil {
+		return nil, err
+	}
+	if opts.skip, err = intOption(options, "skip", 0); err != nil {
+		return nil, err
+	}
+	if opts.limit, err = intOption(options, "limit", -1); err != nil {
+		return nil, err
+	}
+	if opts.reduce, err = boolOption(options, "reduce", def.Reduce != nil); err != nil {
+		return nil, err
+	}
+	if opts.reduce && def.Reduce == nil {
+		return nil, badRequest("reduce is invalid for map-only views")
+	}
+	group, err := boolOption(options, "group", false)
+	if err != nil {
+		return nil, err
+	}
+	if group {
+		opts.groupLevel = -1
+	}
+	if _, ok := options["group_level"]; ok {
+		level, err := intOption(options, "group_level", 0)
+		if err != nil {
+			return nil, err
+		}
+		opts.groupLevel = int(level)
+	}
+	if opts.groupLevel != 0 && !opts.reduce {
+		return nil, badRequest("group is invalid for map-only views")
+	}
+	if opts.reduce && opts.includeDocs {
+		return nil, badRequest("include_docs is invalid for reduce")
+	}
+	switch t := options["update"].(type) {
+	case nil:
+		opts.update = true
+	case bool:
+		opts.update = t
+	case string:
+		opts.update = t != "false"
+	}
+	return opts, nil
+}
+
+// query answers a query of the view named name, updating its index first,
+// unless disabled with update=false.
+func (e *engine) query(ctx context.Context, db driver.DB, name string, def View, options map[string]interface{}) (driver.Rows, error) {
+	opts, err := parseOptions(options, def)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if opts.update {
+		if err := e.update(ctx, db); err != nil {
+			e.mu.Unlock()
+			return nil, err
+		}
+	}
+	entries := e.indexes[name].entries()
+	seq := e.seq
+	e.mu.Unlock()
+
+	result := &rows{updateSeq: seq, totalRows: int64(len(entries))}
+	selected, offset := selectEntries(entries, opts)
+	if opts.reduce {
+		if result.rows, err = reduceEntries(selected, def.Reduce, opts.groupLevel); err != nil {
+			return nil, err
+		}
+		result.totalRows = 0
+	} else {
+		result.rows = make([]*driver.Row, len(selected))
+		for i, entry := range selected {
+			result.rows[i] = &driver.Row{ID: entry.id, Key: entry.rawKey, Value: bytes.NewReader(entry.rawValue)}
+		}
+		result.offset = int64(offset)
+	}
+	result.rows = page(result.rows, opts.skip, opts.limit)
+	if !opts.reduce {
+		result.offset += int64(min64(opts.skip, int64(len(selected))))
+	}
+	if opts.includeDocs {
+		for _, row := range result.rows {
+			row.Doc = fetchDoc(ctx, db, row.ID)
+		}
+	}
+	return result, nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// selectEntries returns the entries matched by opts, in the requested order,
+// and the position of the first in the index, in that order.
+func selectEntries(entries []entry, opts *queryOptions) ([]entry, int) {
+	ordered := entries
+	if opts.descending {
+		ordered = make([]entry, len(entries))
+		for i, e := range entries {
+			ordered[len(entries)-1-i] = e
+		}
+	}
+	if opts.hasKeys {
+		var selected []entry
+		for _, key := range opts.keys {
+			for _, e := range ordered {
+				if mango.Compare(e.key, key) == 0 {
+					selected = append(selected, e)
+				}
+			}
+		}
+		return selected, 0
+	}
+	// dir is 1 for ascending order, and -1 for descending order.
+	dir := 1
+	if opts.descending {
+		dir = -1
+	}
+	var selected []entry
+	offset := -1
+	for i, e := range ordered {
+		if opts.hasStart && dir*mango.Compare(e.key, opts.startKey) < 0 {
+			continue
+		}
+		if opts.hasEnd {
+			c := dir * mango.Compare(e.key, opts.endKey)
+			if c > 0 || (c == 0 && !opts.inclusiveEnd) {
+				break
+			}
+		}
+		if offset < 0 {
+			offset = i
+		}
+		selected = append(selected, e)
+	}
+	if offset < 0 {
+		offset = len(ordered)
+	}
+	return selected, offset
+}
+
+// groupKey returns the key by which an entry is grouped, for group level
+// level.
+func groupKey(key interface{}, level int) interface{} {
+	switch {
+	case level == 0:
+		return nil
+	case level < 0:
+		return key
+	}
+	if arr, ok := key.([]interface{}); ok && len(arr) > level {
+		return arr[:level]
+	}
+	return key
+}
+
+// reduceEntries reduces entries, which are in collation order, grouped by
+// group level level.
+func reduceEntries(entries []entry, fn ReduceFunc, level int) ([]*driver.Row, error) {
+	var result []*driver.Row
+	for start := 0; start < len(entries); {
+		key := groupKey(entries[start].key, level)
+		end := start + 1
+		for end < len(entries) && mango.Compare(groupKey(entries[end].key, level), key) == 0 {
+			end++
+		}
+		keys := make([]interface{}, 0, end-start)
+		values := make([]interface{}, 0, end-start)
+		for _, e := range entries[start:end] {
+			keys = append(keys, e.key)
+			values = append(values, e.value)
+		}
+		reduced, err := fn(keys, values)
+		if err != nil {
+			return nil, &kivik.Error{Status: http.StatusInternalServerError, Message: "views: reduce failed", Err: err}
+		}
+		rawKey, _ := json.Marshal(key)
+		rawValue, err := json.Marshal(reduced)
+		if err != nil {
+			return nil, &kivik.Error{Status: http.StatusInternalServerError, Message: "views: reduce failed", Err: err}
+		}
+		result = append(result, &driver.Row{Key: rawKey, Value: bytes.NewReader(rawValue)})
+		start = end
+	}
+	return result, nil
+}
+
+func page(rows []*driver.Row, skip, limit int64) []*driver.Row {
+	if skip >= int64(len(rows)) {
+		return nil
+	}
+	rows = rows[skip:]
+	if limit >= 0 && limit < int64(len(rows)) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// fetchDoc returns the current version of docID, or null if it cannot be
+// read, as CouchDB does for documents deleted since they were indexed.
+func fetchDoc(ctx context.Context, db driver.DB, docID string) io.Reader {
+	doc, err := db.Get(ctx, docID, nil)
+	if err != nil {
+		return bytes.NewReader([]byte("null"))
+	}
+	defer doc.Body.Close() // nolint:errcheck
+	body, err := io.ReadAll(doc.Body)
+	if err != nil {
+		return bytes.NewReader([]byte("null"))
+	}
+	return bytes.NewReader(body)
+}
+
+// rows is a [driver.Rows] over a query result.
+type rows struct {
+	rows      []*driver.Row
+	updateSeq string
+	offset    int64
+	totalRows int64
+}
+
+var _ driver.Rows = &rows{}
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *rows) UpdateSeq() string { return r.updateSeq }
+func (r *rows) Offset() int64     { return r.offset }
+func (r *rows) TotalRows() int64  { return r.totalRows }
@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package views
+
+import (
+	"errors"
+	"math"
+)
+
+// Count is a reduce function which counts the rows, like CouchDB's built-in
+// _count.
+func Count(_, values []interface{}) (interface{}, error) {
+	return len(values), nil
+}
+
+// Sum is a reduce function which sums numeric values, like CouchDB's built-in
+// _sum, except that arrays and objects of numbers are not supported.
+func Sum(_, values []interface{}) (interface{}, error) {
+	var sum float64
+	for _, v := range values {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("views: _sum requires numeric values")
+		}
+		sum += f
+	}
+	return sum, nil
+}
+
+// Stats is a reduce function which summarizes numeric values, like CouchDB's
+// built-in _stats, returning an object with the fields sum, count, min, max
+// and sumsqr.
+func Stats(_, values []interface{}) (interface{}, error) {
+	stats := map[string]float64{
+		"count": 0,
+		"sum":   0,
+		"min":   math.Inf(1),
+		"max":   math.Inf(-1),
+	}
+	var sumsqr float64
+	for _, v := range values {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("views: _stats requires numeric values")
+		}
+		stats["count"]++
+		stats["sum"] += f
+		stats["min"] = math.Min(stats["min"], f)
+		stats["max"] = math.Max(stats["max"], f)
+		sumsqr += f * f
+	}
+	stats["sumsqr"] = sumsqr
+	if len(values) == 0 {
+		stats["min"], stats["max"] = 0, 0
+	}
+	return stats, nil
+}
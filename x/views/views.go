@@ -0,0 +1,191 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package views provides a portable map/reduce view engine, with map and
+// reduce functions written in Go, for drivers which do not support views,
+// such as the memory and file system drivers.
+//
+// Views are defined in Go, grouped into design documents, and the database is
+// wrapped so that [kivik.DB.Query] is answered from indexes maintained by the
+// engine:
+//
+//	byOwner := views.DesignDoc{
+//	    ID: "_design/animals",
+//	    Views: map[string]views.View{
+//	        "by_owner": {
+//	            Map: func(doc map[string]interface{}, emit views.Emitter) {
+//	                if owner, ok := doc["owner"].(string); ok {
+//	                    emit(owner, 1)
+//	                }
+//	            },
+//	            Reduce: views.Count,
+//	        },
+//	    },
+//	}
+//	client, err := kivik.NewClientFromDriverClient(views.NewClient(driverClient, byOwner))
+//	rows := client.DB("zoo").Query(ctx, "animals", "by_owner", kivik.Param("group", true))
+//
+// As with CouchDB, indexes are updated when queried, by reading the
+// database's changes feed from the last sequence indexed, so the underlying
+// driver must support the normal changes feed, with include_docs. Indexes are
+// held in memory, and rebuilt from the start of the changes feed when the
+// program restarts. Deletions are only removed from indexes if the changes
+// feed reports them, which the file system driver does not. Design documents
+// stored in the database are not executed; views which are not defined in Go
+// are passed to the underlying driver.
+//
+// The query options key, keys, startkey, endkey (and their aliases
+// start_key and end_key), inclusive_end, descending, skip, limit,
+// include_docs, reduce, group, group_level and update are supported. Keys are
+// sorted with [mango.Compare], which orders strings by their bytes, rather
+// than by the Unicode Collation Algorithm used by CouchDB.
+package views
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/passthrough"
+)
+
+// Emitter adds a row to a view's index, for the document being mapped.
+type Emitter func(key, value interface{})
+
+// MapFunc is called with each document in the database, except design and
+// local documents, and calls emit for each row to index. The document
+// includes its _id and _rev fields, and must not be modified. A MapFunc which
+// panics indexes nothing for that document.
+type MapFunc func(doc map[string]interface{}, emit Emitter)
+
+// ReduceFunc reduces the values emitted for the given keys, which are the
+// decoded JSON values emitted by the map function, to a single value.
+// Unlike CouchDB, all of the values are reduced in a single call, so there
+// is no rereduce step.
+type ReduceFunc func(keys, values []interface{}) (interface{}, error)
+
+// View is a view definition.
+type View struct {
+	Map MapFunc
+	// Reduce is optional.
+	Reduce ReduceFunc
+}
+
+// DesignDoc is a design document defining views in Go.
+type DesignDoc struct {
+	// ID is the design document's ID, with or without the "_design/" prefix.
+	ID    string
+	Views map[string]View
+}
+
+// definitions are views, keyed by design document name, without the
+// "_design/" prefix, and view name.
+type definitions map[string]map[string]View
+
+func newDefinitions(ddocs []DesignDoc) definitions {
+	defs := definitions{}
+	for _, ddoc := range ddocs {
+		name := strings.TrimPrefix(ddoc.ID, "_design/")
+		if defs[name] == nil {
+			defs[name] = map[string]View{}
+		}
+		for viewName, view := range ddoc.Views {
+			defs[name][viewName] = view
+		}
+	}
+	return defs
+}
+
+// Client wraps a [driver.Client], so that its databases answer queries of
+// the views defined in Go.
+type Client struct {
+	driver.Client
+	passthrough.ClientFeatures
+	defs definitions
+
+	mu      sync.Mutex
+	engines map[string]*engine
+}
+
+var _ driver.Client = &Client{}
+
+// NewClient returns client wrapped so that its databases answer queries of
+// the views in ddocs. Indexes are shared by all uses of each database
+// through the returned client.
+func NewClient(client driver.Client, ddocs ...DesignDoc) *Client {
+	return &Client{
+		Client:         client,
+		ClientFeatures: passthrough.ClientFeatures{Base: client},
+		defs:           newDefinitions(ddocs),
+		engines:        map[string]*engine{},
+	}
+}
+
+// DB returns the named database, wrapped to answer view queries.
+func (c *Client) DB(name string, options map[string]interface{}) (driver.DB, error) {
+	db, err := c.Client.DB(name, options)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.engines[name]
+	if !ok {
+		e = newEngine(c.defs)
+		c.engines[name] = e
+	}
+	return wrapDB(db, e), nil
+}
+
+// DestroyDB destroys the named database, and discards its indexes.
+func (c *Client) DestroyDB(ctx context.Context, name string, options map[string]interface{}) error {
+	c.mu.Lock()
+	delete(c.engines, name)
+	c.mu.Unlock()
+	return c.Client.DestroyDB(ctx, name, options)
+}
+
+// DB wraps a [driver.DB], answering queries of the views defined in Go. In
+// addition to the methods of driver.DB, it implements the optional database
+// interfaces used by Kivik, passing calls through to the underlying driver.
+type DB struct {
+	driver.DB
+	passthrough.DBFeatures
+	engine *engine
+}
+
+var _ driver.DB = &DB{}
+
+func wrapDB(db driver.DB, e *engine) *DB {
+	vdb := &DB{DB: db, engine: e}
+	vdb.DBFeatures = passthrough.DBFeatures{Base: db, Self: vdb}
+	return vdb
+}
+
+// New returns db wrapped so that it answers queries of the views in ddocs.
+// To share indexes between uses of the same database, as with
+// [kivik.Client.DB], use [NewClient] instead.
+func New(db driver.DB, ddocs ...DesignDoc) *DB {
+	return wrapDB(db, newEngine(newDefinitions(ddocs)))
+}
+
+// Query answers queries of views defined in Go, and passes all others to the
+// underlying driver.
+func (db *DB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	def, ok := db.engine.defs[ddoc][view]
+	if !ok {
+		return db.DB.Query(ctx, ddoc, view, options)
+	}
+	return db.engine.query(ctx, db.DB, ddoc+"/"+view, def, options)
+}
@@ -0,0 +1,358 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+	"github.com/go-kivik/kivik/v4/x/proxydb"
+)
+
+var zoo = DesignDoc{
+	ID: "_design/zoo",
+	Views: map[string]View{
+		"by_name": {
+			Map: func(doc map[string]interface{}, emit Emitter) {
+				if name, ok := doc["name"].(string); ok {
+					emit(name, doc["legs"])
+				}
+			},
+		},
+		"legs": {
+			Map: func(doc map[string]interface{}, emit Emitter) {
+				if legs, ok := doc["legs"].(float64); ok {
+					emit([]interface{}{doc["class"], legs}, legs)
+				}
+			},
+			Reduce: Sum,
+		},
+		"count": {
+			Map: func(doc map[string]interface{}, emit Emitter) {
+				emit(doc["class"], nil)
+			},
+			Reduce: Count,
+		},
+		"panics": {
+			Map: func(doc map[string]interface{}, emit Emitter) {
+				emit(doc["_id"], doc["name"].(string))
+			},
+		},
+	},
+}
+
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	memory, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDB(ctx, "animals"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kivik.NewClientFromDriverClient(NewClient(proxydb.NewClient(memory), zoo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB("animals")
+	for id, doc := range map[string]interface{}{
+		"cow":     map[string]interface{}{"name": "Bessie", "legs": 4, "class": "mammal"},
+		"pig":     map[string]interface{}{"name": "Wilbur", "legs": 4, "class": "mammal"},
+		"chicken": map[string]interface{}{"name": "Henny", "legs": 2, "class": "bird"},
+		"snake":   map[string]interface{}{"legs": 0, "class": "reptile"},
+	} {
+		if _, err := db.Put(ctx, id, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Put(ctx, "_design/zoo", map[string]interface{}{"name": "Zoo"}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// queryRows returns the rows of a query, as JSON objects.
+func queryRows(t *testing.T, rs kivik.ResultSet) []string {
+	t.Helper()
+	defer rs.Close() // nolint:errcheck
+	var result []string
+	for rs.Next() {
+		row := struct {
+			ID    string          `json:"id,omitempty"`
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+			Doc   json.RawMessage `json:"doc,omitempty"`
+		}{}
+		row.ID, _ = rs.ID()
+		if err := rs.ScanKey(&row.Key); err != nil {
+			t.Fatal(err)
+		}
+		if err := rs.ScanValue(&row.Value); err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := rs.ScanDoc(&doc); err == nil {
+			delete(doc, "_rev")
+			row.Doc, _ = json.Marshal(doc)
+		}
+		out, _ := json.Marshal(row)
+		result = append(result, string(out))
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestQuery(t *testing.T) {
+	type tt struct {
+		view    string
+		options kivik.Options
+		want    []string
+		status  int
+		err     string
+	}
+
+	tests := testy.NewTable()
+	tests.Add("map", tt{
+		view: "by_name",
+		want: []string{
+			`{"id":"cow","key":"Bessie","value":4}`,
+			`{"id":"chicken","key":"Henny","value":2}`,
+			`{"id":"pig","key":"Wilbur","value":4}`,
+		},
+	})
+	tests.Add("range", tt{
+		view:    "by_name",
+		options: kivik.Options{"startkey": "C", "endkey": "Wilbur", "inclusive_end": false},
+		want:    []string{`{"id":"chicken","key":"Henny","value":2}`},
+	})
+	tests.Add("descending", tt{
+		view:    "by_name",
+		options: kivik.Options{"descending": true, "startkey": "Wilbur", "endkey": "Henny"},
+		want: []string{
+			`{"id":"pig","key":"Wilbur","value":4}`,
+			`{"id":"chicken","key":"Henny","value":2}`,
+		},
+	})
+	tests.Add("key", tt{
+		view:    "by_name",
+		options: kivik.Options{"key": json.RawMessage(`"Henny"`)},
+		want:    []string{`{"id":"chicken","key":"Henny","value":2}`},
+	})
+	tests.Add("keys", tt{
+		view:    "by_name",
+		options: kivik.Options{"keys": []string{"Wilbur", "nobody", "Bessie"}},
+		want: []string{
+			`{"id":"pig","key":"Wilbur","value":4}`,
+			`{"id":"cow","key":"Bessie","value":4}`,
+		},
+	})
+	tests.Add("skip and limit", tt{
+		view:    "by_name",
+		options: kivik.Options{"skip": 1, "limit": 1},
+		want:    []string{`{"id":"chicken","key":"Henny","value":2}`},
+	})
+	tests.Add("include docs", tt{
+		view:    "by_name",
+		options: kivik.Options{"key": "Bessie", "include_docs": true},
+		want:    []string{`{"id":"cow","key":"Bessie","value":4,"doc":{"_id":"cow","class":"mammal","legs":4,"name":"Bessie"}}`},
+	})
+	tests.Add("reduce", tt{
+		view: "legs",
+		want: []string{`{"key":null,"value":10}`},
+	})
+	tests.Add("group level", tt{
+		view:    "legs",
+		options: kivik.Options{"group_level": 1},
+		want: []string{
+			`{"key":["bird"],"value":2}`,
+			`{"key":["mammal"],"value":8}`,
+			`{"key":["reptile"],"value":0}`,
+		},
+	})
+	tests.Add("group", tt{
+		view:    "count",
+		options: kivik.Options{"group": true, "startkey": "c"},
+		want: []string{
+			`{"key":"mammal","value":2}`,
+			`{"key":"reptile","value":1}`,
+		},
+	})
+	tests.Add("reduce disabled", tt{
+		view:    "count",
+		options: kivik.Options{"reduce": false, "key": "bird"},
+		want:    []string{`{"id":"chicken","key":"bird","value":null}`},
+	})
+	tests.Add("map panics", tt{
+		view: "panics",
+		want: []string{
+			`{"id":"chicken","key":"chicken","value":"Henny"}`,
+			`{"id":"cow","key":"cow","value":"Bessie"}`,
+			`{"id":"pig","key":"pig","value":"Wilbur"}`,
+		},
+	})
+	tests.Add("reduce map-only view", tt{
+		view:    "by_name",
+		options: kivik.Options{"reduce": true},
+		status:  http.StatusBadRequest,
+		err:     "reduce is invalid for map-only views",
+	})
+	tests.Add("include docs with reduce", tt{
+		view:    "legs",
+		options: kivik.Options{"include_docs": true},
+		status:  http.StatusBadRequest,
+		err:     "include_docs is invalid for reduce",
+	})
+	tests.Add("invalid limit", tt{
+		view:    "by_name",
+		options: kivik.Options{"limit": -1},
+		status:  http.StatusBadRequest,
+		err:     "kivik: invalid limit option -1: must be a non-negative integer",
+	})
+	tests.Add("undefined view", tt{
+		view:   "nothing",
+		status: http.StatusNotImplemented,
+		err:    "kivik: views are not supported by the memory driver",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		db := newDB(t)
+		rs := db.Query(context.Background(), "_design/zoo", tt.view, tt.options)
+		if tt.err != "" {
+			rs.Next()
+			testy.StatusError(t, tt.err, tt.status, rs.Err())
+			return
+		}
+		if d := testy.DiffInterface(tt.want, queryRows(t, rs)); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestQueryUpdates(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	count := func(options ...kivik.Options) string {
+		t.Helper()
+		return strings.Join(queryRows(t, db.Query(ctx, "zoo", "count", options...)), ",")
+	}
+	if got := count(); got != `{"key":null,"value":4}` {
+		t.Errorf("Unexpected count: %s", got)
+	}
+
+	rev, err := db.GetRev(ctx, "snake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(ctx, "snake", rev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "goat", map[string]interface{}{"class": "mammal"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(kivik.Param("update", "false")); got != `{"key":null,"value":4}` {
+		t.Errorf("Expected a stale count, got %s", got)
+	}
+	want := `{"key":"bird","value":1},{"key":"mammal","value":3}`
+	if got := count(kivik.Param("group", true)); got != want {
+		t.Errorf("Unexpected count: %s", got)
+	}
+
+	// Indexes are shared by each use of the database.
+	other := db.Client().DB("animals")
+	if got := strings.Join(queryRows(t, other.Query(ctx, "zoo", "count", kivik.Param("group", true), kivik.Param("update", false))), ","); got != want {
+		t.Errorf("Unexpected count from another handle: %s", got)
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	db := newDB(t)
+	rs := db.Find(context.Background(), map[string]interface{}{"selector": map[string]interface{}{"name": "Henny"}})
+	var ids []string
+	for rs.Next() {
+		id, _ := rs.ID()
+		ids = append(ids, id)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"chicken"}, ids); d != nil {
+		t.Error(d)
+	}
+}
+
+// featureClient is a driver client which implements some of the optional
+// client interfaces, and records their use.
+type featureClient struct {
+	*mock.Client
+	calls []string
+}
+
+func (c *featureClient) Authenticate(context.Context, interface{}) error {
+	c.calls = append(c.calls, "Authenticate")
+	return nil
+}
+
+func (c *featureClient) Ping(context.Context) (bool, error) {
+	c.calls = append(c.calls, "Ping")
+	return true, nil
+}
+
+func (c *featureClient) Close() error {
+	c.calls = append(c.calls, "Close")
+	return nil
+}
+
+func TestClientPassthrough(t *testing.T) {
+	ctx := context.Background()
+	dc := &featureClient{Client: &mock.Client{}}
+	client, err := kivik.NewClientFromDriverClient(NewClient(dc, zoo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Authenticate(ctx, "creds"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"Authenticate", "Ping", "Close"}, dc.calls); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestStats(t *testing.T) {
+	got, err := Stats(nil, []interface{}{2.0, 4.0, 3.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"count": 3, "sum": 9, "min": 2, "max": 4, "sumsqr": 29}
+	if d := testy.DiffInterface(want, got); d != nil {
+		t.Error(d)
+	}
+	_, err = Sum(nil, []interface{}{"x"})
+	testy.Error(t, "views: _sum requires numeric values", err)
+}